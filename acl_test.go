@@ -0,0 +1,104 @@
+package store
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var aclTests = []struct {
+	name string
+	acl  ACL
+	// wantS3 - заголовок x-amz-acl, wantMode - права локального файла
+	wantS3   string
+	wantMode os.FileMode
+}{
+	{"default", ACLDefault, "", 0},
+	{"private", ACLPrivate, "private", 0600},
+	{"public read", ACLPublicRead, "public-read", 0644},
+}
+
+func TestS3ACL(t *testing.T) {
+	for _, tt := range aclTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newFakeS3(t, S3Config{})
+			if err := s.CreateFileWithOptions("a.txt", []byte("a"), PutOptions{ACL: tt.acl}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CopyFileWithOptions("a.txt", "b.txt", PutOptions{ACL: tt.acl}); err != nil {
+				t.Fatal(err)
+			}
+
+			puts := f.requestsTo(http.MethodPut, "")
+			if len(puts) != 2 {
+				t.Fatalf("%d PUTs, want the upload and the copy", len(puts))
+			}
+			for _, r := range puts {
+				op := "PutObject"
+				if r.Header.Get("X-Amz-Copy-Source") != "" {
+					op = "CopyObject"
+				}
+				if got := r.Header.Get("X-Amz-Acl"); got != tt.wantS3 {
+					t.Errorf("%s x-amz-acl = %q, want %q", op, got, tt.wantS3)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalACL(t *testing.T) {
+	// mode - права файла
+	mode := func(t *testing.T, path string) os.FileMode {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	for _, tt := range aclTests {
+		// права по умолчанию зависят от umask процесса
+		if tt.acl == ACLDefault {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestLocal(t, LocalConfig{})
+			dir := t.TempDir()
+			created, copied, existing := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")
+
+			if err := s.CreateFileWithOptions(created, []byte("a"), PutOptions{ACL: tt.acl}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CopyFileWithOptions(created, copied, PutOptions{ACL: tt.acl}); err != nil {
+				t.Fatal(err)
+			}
+			// права уже существующего файла тоже меняются
+			if err := os.WriteFile(existing, []byte("old"), 0640); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreateFileWithOptions(existing, []byte("c"), PutOptions{ACL: tt.acl}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, path := range []string{created, copied, existing} {
+				if got := mode(t, path); got != tt.wantMode {
+					t.Errorf("%s mode = %v, want %v", filepath.Base(path), got, tt.wantMode)
+				}
+			}
+		})
+	}
+}
+
+func TestWebDavACLIgnored(t *testing.T) {
+	w, _ := newTestWebDavDir(t, WebDavConfig{})
+	for _, tt := range aclTests {
+		if err := w.CreateFileWithOptions("a.txt", []byte(tt.name), PutOptions{ACL: tt.acl}); err != nil {
+			t.Errorf("CreateFileWithOptions with %s ACL: %v, want the ACL ignored", tt.name, err)
+		}
+		if got, err := w.GetFile("a.txt"); err != nil || string(got) != tt.name {
+			t.Errorf("GetFile = %q, %v; want %q", got, err, tt.name)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat - формат архива
+type ArchiveFormat int
+
+const (
+	// ArchiveTar - tar без сжатия
+	ArchiveTar ArchiveFormat = iota
+	// ArchiveZip - zip со сжатием deflate
+	ArchiveZip
+)
+
+// archiveDir - пишет в w архив файлов директории dir
+// пути в архиве относительны dir, время изменения сохраняется;
+// файлы читаются потоком через FileReader и не буферизуются в памяти целиком
+func archiveDir(ctx context.Context, s StoreIFace, dir string, w io.Writer, format ArchiveFormat) error {
+	switch format {
+	case ArchiveTar:
+		tw := tar.NewWriter(w)
+		err := walkDir(ctx, s, dir, func(rel string, info os.FileInfo) error {
+			err := tw.WriteHeader(&tar.Header{
+				Name:    rel,
+				Size:    info.Size(),
+				Mode:    0644,
+				ModTime: info.ModTime(),
+			})
+			if err != nil {
+				return err
+			}
+			return archiveCopy(ctx, s, joinKey(dir, rel), info.Size(), tw)
+		})
+		if err != nil {
+			return err
+		}
+		return tw.Close()
+
+	case ArchiveZip:
+		zw := zip.NewWriter(w)
+		err := walkDir(ctx, s, dir, func(rel string, info os.FileInfo) error {
+			fw, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     rel,
+				Method:   zip.Deflate,
+				Modified: info.ModTime(),
+			})
+			if err != nil {
+				return err
+			}
+			return archiveCopy(ctx, s, joinKey(dir, rel), info.Size(), fw)
+		})
+		if err != nil {
+			return err
+		}
+		return zw.Close()
+
+	default:
+		return fmt.Errorf("unknown archive format %d", format)
+	}
+}
+
+// archiveCopy - дописывает содержимое файла размером size в запись архива
+func archiveCopy(ctx context.Context, s StoreIFace, file string, size int64, w io.Writer) error {
+	stream, err := s.FileReaderWithContext(ctx, file, 0, 0)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		// Local не открывает пустые файлы
+		if size == 0 {
+			return nil
+		}
+		return ErrFileNotFound
+	}
+	defer stream.Close()
+
+	_, err = io.Copy(w, stream)
+	return err
+}
+
+// extractTarget - как ExtractArchive пишет в конкретное хранилище
+// mkdir - создавать директории через MkdirAll (в S3 директорий нет)
+// chtimes - выставляет время изменения записанного файла, nil - не поддерживается
+type extractTarget struct {
+	mkdir   bool
+	chtimes func(path string, t time.Time) error
+}
+
+// extractArchive - распаковывает архив из r в директорию dest
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey до записи чего-либо
+// за пределы dest; символические ссылки и прочие специальные записи пропускаются
+func extractArchive(ctx context.Context, s StoreIFace, r io.Reader, dest string, format ArchiveFormat, target extractTarget) error {
+	created := make(map[string]bool)
+
+	write := func(name string, isDir bool, modTime time.Time, body io.Reader) error {
+		rel, err := archiveEntryPath(name)
+		if err != nil {
+			return err
+		}
+		if rel == "" {
+			return nil
+		}
+		file := joinKey(dest, rel)
+
+		dir := file
+		if !isDir {
+			dir = path.Dir(file)
+		}
+		if target.mkdir && dir != "." && !created[dir] {
+			if err := s.MkdirAllWithContext(ctx, dir); err != nil {
+				return err
+			}
+			created[dir] = true
+		}
+		if isDir {
+			return nil
+		}
+
+		if err := s.StreamToFileWithContext(ctx, body, file, nil); err != nil {
+			return err
+		}
+		if target.chtimes != nil && !modTime.IsZero() {
+			return target.chtimes(file, modTime)
+		}
+		return nil
+	}
+
+	switch format {
+	case ArchiveTar:
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			switch hdr.Typeflag {
+			case tar.TypeDir:
+				err = write(hdr.Name, true, hdr.ModTime, nil)
+			case tar.TypeReg:
+				err = write(hdr.Name, false, hdr.ModTime, tr)
+			default:
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+		}
+
+	case ArchiveZip:
+		// оглавление zip находится в конце, поэтому поток сохраняется во временный файл
+		tmp, err := os.CreateTemp("", "store-extract-*.zip")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		size, err := io.Copy(tmp, r)
+		if err != nil {
+			return err
+		}
+
+		zr, err := zip.NewReader(tmp, size)
+		if err != nil {
+			return err
+		}
+
+		// пути проверяются заранее, чтобы вредоносный архив не был распакован частично
+		for _, f := range zr.File {
+			if _, err := archiveEntryPath(f.Name); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+
+		for _, f := range zr.File {
+			if err := extractZipEntry(f, write); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown archive format %d", format)
+	}
+}
+
+// extractZipEntry - распаковывает одну запись zip
+func extractZipEntry(f *zip.File, write func(name string, isDir bool, modTime time.Time, body io.Reader) error) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return write(f.Name, true, f.Modified, nil)
+	}
+	if !mode.IsRegular() {
+		return nil
+	}
+
+	body, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return write(f.Name, false, f.Modified, body)
+}
+
+// archiveEntryPath - путь записи архива относительно директории распаковки
+// абсолютные пути и выход за пределы директории через ".." отклоняются (zip-slip)
+func archiveEntryPath(name string) (string, error) {
+	if strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("%w: archive entry %q", ErrInvalidKey, name)
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("%w: archive entry %q", ErrInvalidKey, name)
+		}
+	}
+
+	rel := path.Clean(name)
+	if rel == "." {
+		return "", nil
+	}
+	return rel, nil
+}
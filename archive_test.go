@@ -0,0 +1,339 @@
+package store
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// archiveEntry - запись тестового архива
+type archiveEntry struct {
+	name string
+	body string
+}
+
+// buildArchive - архив формата format из записей entries
+func buildArchive(t *testing.T, format ArchiveFormat, entries []archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	switch format {
+	case ArchiveTar:
+		tw := tar.NewWriter(&buf)
+		for _, e := range entries {
+			err := tw.WriteHeader(&tar.Header{Name: e.name, Size: int64(len(e.body)), Mode: 0644, ModTime: modTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case ArchiveZip:
+		zw := zip.NewWriter(&buf)
+		for _, e := range entries {
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate, Modified: modTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// listTree - относительные пути всех файлов и директорий под root
+func listTree(t *testing.T, root string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root {
+			rel, _ := filepath.Rel(root, path)
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestExtractArchive(t *testing.T) {
+	formats := []struct {
+		name   string
+		format ArchiveFormat
+	}{
+		{"tar", ArchiveTar},
+		{"zip", ArchiveZip},
+	}
+
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "dest")
+			s := newTestLocal(t, LocalConfig{})
+
+			data := buildArchive(t, f.format, []archiveEntry{
+				{"a.txt", "a"},
+				{"sub/dir/b.txt", "b"},
+			})
+			if err := s.ExtractArchive(bytes.NewReader(data), dest, f.format); err != nil {
+				t.Fatalf("ExtractArchive: %v", err)
+			}
+
+			for name, want := range map[string]string{"a.txt": "a", "sub/dir/b.txt": "b"} {
+				got, err := os.ReadFile(filepath.Join(dest, name))
+				if err != nil {
+					t.Fatalf("read %s: %v", name, err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+				info, err := os.Stat(filepath.Join(dest, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !info.ModTime().Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+					t.Errorf("%s modtime = %v, want the archive entry time", name, info.ModTime())
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveRejectsZipSlip(t *testing.T) {
+	// абсолютный путь указывает на файл рядом с директорией распаковки
+	outside := filepath.Join(t.TempDir(), "abs-evil")
+
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{"parent", "../evil"},
+		{"nested parent", "sub/../../evil"},
+		{"absolute", outside},
+	}
+
+	for _, f := range []struct {
+		name   string
+		format ArchiveFormat
+	}{{"tar", ArchiveTar}, {"zip", ArchiveZip}} {
+		for _, tt := range tests {
+			t.Run(f.name+"/"+tt.name, func(t *testing.T) {
+				root := t.TempDir()
+				dest := filepath.Join(root, "dest")
+				s := newTestLocal(t, LocalConfig{})
+
+				data := buildArchive(t, f.format, []archiveEntry{
+					{"ok.txt", "ok"},
+					{tt.entry, "evil"},
+				})
+				err := s.ExtractArchive(bytes.NewReader(data), dest, f.format)
+				if !errors.Is(err, ErrInvalidKey) {
+					t.Fatalf("ExtractArchive error = %v, want ErrInvalidKey", err)
+				}
+
+				// вне dest ничего не появилось
+				for _, p := range listTree(t, root) {
+					if p != "dest" && !strings.HasPrefix(p, "dest/") {
+						t.Errorf("extracted outside the destination: %s", p)
+					}
+				}
+				if _, err := os.Stat(filepath.Join(root, "evil")); !os.IsNotExist(err) {
+					t.Errorf("%s/evil exists after a rejected extraction", root)
+				}
+				if _, err := os.Stat(outside); !os.IsNotExist(err) {
+					t.Errorf("%s exists after a rejected extraction", outside)
+				}
+			})
+		}
+	}
+
+	t.Run("zip is rejected before anything is written", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "dest")
+		s := newTestLocal(t, LocalConfig{})
+
+		data := buildArchive(t, ArchiveZip, []archiveEntry{{"ok.txt", "ok"}, {"../evil", "evil"}})
+		if err := s.ExtractArchive(bytes.NewReader(data), dest, ArchiveZip); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("ExtractArchive error = %v, want ErrInvalidKey", err)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "ok.txt")); !os.IsNotExist(err) {
+			t.Error("zip entries were extracted before the malicious entry was rejected")
+		}
+	})
+}
+
+// readArchive - содержимое и время изменения записей архива
+func readArchive(t *testing.T, format ArchiveFormat, data []byte) (map[string]string, map[string]time.Time) {
+	t.Helper()
+	files, times := map[string]string{}, map[string]time.Time{}
+	switch format {
+	case ArchiveTar:
+		tr := tar.NewReader(bytes.NewReader(data))
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("tar: %v", err)
+			}
+			body, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("tar %s: %v", h.Name, err)
+			}
+			files[h.Name], times[h.Name] = string(body), h.ModTime
+		}
+	case ArchiveZip:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("zip %s: %v", f.Name, err)
+			}
+			body, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("zip %s: %v", f.Name, err)
+			}
+			files[f.Name], times[f.Name] = string(body), f.Modified
+		}
+	}
+	return files, times
+}
+
+func TestArchiveDir(t *testing.T) {
+	formats := []struct {
+		name   string
+		format ArchiveFormat
+	}{
+		{"tar", ArchiveTar},
+		{"zip", ArchiveZip},
+	}
+	tree := map[string]string{
+		"a.txt":         "alpha",
+		"empty.txt":     "",
+		"sub/b.txt":     strings.Repeat("b", 100<<10),
+		"sub/dir/c.txt": "gamma",
+	}
+
+	for _, b := range testBackends {
+		for _, f := range formats {
+			t.Run(b.name+"/"+f.name, func(t *testing.T) {
+				s, root := b.store(t)
+				if b.name == "local" {
+					s = newTestLocal(t, LocalConfig{CreateParents: true})
+				}
+				dir := joinKey(root, "src")
+				if b.name == "webdav" {
+					if err := s.MkdirAll("src/sub/dir"); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for name, body := range tree {
+					// мета-файлы в архив не попадают
+					if err := s.CreateFile(joinKey(dir, name), []byte(body), nil, map[string]string{"Owner": "bob"}); err != nil {
+						t.Fatalf("CreateFile(%s): %v", name, err)
+					}
+				}
+				// файл рядом с директорией не архивируется
+				if err := s.CreateFile(joinKey(root, "srcfile.txt"), []byte("x"), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+
+				var buf bytes.Buffer
+				if err := s.ArchiveDir(dir, &buf, f.format); err != nil {
+					t.Fatalf("ArchiveDir: %v", err)
+				}
+				files, times := readArchive(t, f.format, buf.Bytes())
+				if !reflect.DeepEqual(files, tree) {
+					names := make([]string, 0, len(files))
+					for name := range files {
+						names = append(names, name)
+					}
+					t.Fatalf("archive entries = %v, want %d files of the source tree", names, len(tree))
+				}
+				// время записи - время файла в листинге с точностью до секунды:
+				// tar округляет его, zip отбрасывает дробную часть
+				err := walkDir(context.Background(), s, dir, func(rel string, info os.FileInfo) error {
+					want := info.ModTime().Truncate(time.Second)
+					if f.format == ArchiveTar {
+						want = info.ModTime().Round(time.Second)
+					}
+					if !times[rel].Equal(want) {
+						t.Errorf("%s modtime = %v, want %v", rel, times[rel], want)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// архив распаковывается обратно в то же дерево
+				dest := joinKey(root, "dest")
+				if err := s.ExtractArchive(bytes.NewReader(buf.Bytes()), dest, f.format); err != nil {
+					t.Fatalf("ExtractArchive: %v", err)
+				}
+				for name, want := range tree {
+					got, err := s.GetFile(joinKey(dest, name))
+					if err != nil || string(got) != want {
+						t.Errorf("extracted %s = %d bytes, %v; want %d", name, len(got), err, len(want))
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("streams one file at a time", func(t *testing.T) {
+		s, root := newMultiReaderTree(t, map[string]string{"a.txt": "alpha", "empty.txt": "", "b.txt": "beta"})
+		var buf bytes.Buffer
+		if err := archiveDir(context.Background(), s, root, &buf, ArchiveZip); err != nil {
+			t.Fatalf("archiveDir: %v", err)
+		}
+		if _, open := s.state(); open != 0 || s.maxOpen != 1 {
+			t.Errorf("%d readers left open, at most %d at once; want none left and 1 at once", open, s.maxOpen)
+		}
+	})
+
+	t.Run("empty and missing dir", func(t *testing.T) {
+		s := newTestLocal(t, LocalConfig{})
+		var buf bytes.Buffer
+		if err := s.ArchiveDir(t.TempDir(), &buf, ArchiveTar); err != nil {
+			t.Fatalf("ArchiveDir of an empty dir: %v", err)
+		}
+		if files, _ := readArchive(t, ArchiveTar, buf.Bytes()); len(files) != 0 {
+			t.Errorf("archive of an empty dir = %v, want no entries", files)
+		}
+		if err := s.ArchiveDir(filepath.Join(t.TempDir(), "missing"), io.Discard, ArchiveTar); err == nil {
+			t.Error("ArchiveDir of a missing dir succeeded")
+		}
+		if err := s.ArchiveDir(t.TempDir(), io.Discard, ArchiveFormat(42)); err == nil {
+			t.Error("ArchiveDir with an unknown format succeeded")
+		}
+	})
+}
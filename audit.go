@@ -0,0 +1,476 @@
+package store
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Операции в AuditEvent
+const (
+	AuditCreate  = "create"
+	AuditCopy    = "copy"
+	AuditMove    = "move"
+	AuditRemove  = "remove"
+	AuditClear   = "clear_dir"
+	AuditMkdir   = "mkdir"
+	AuditRead    = "read"
+	AuditSymlink = "symlink"
+)
+
+// AuditEvent - запись журнала аудита
+// Dst заполняется для копирования и перемещения
+// Size - количество записанных или прочитанных байт, если оно известно
+// Err - ошибка операции, nil при успехе
+type AuditEvent struct {
+	Op     string
+	Method string
+	Path   string
+	Dst    string
+	Size   int64
+	Actor  string
+	Time   time.Time
+	Err    error
+}
+
+// AuditSink - получатель событий аудита, например БД или Kafka
+type AuditSink interface {
+	Record(ctx context.Context, event AuditEvent)
+}
+
+// AuditOptions - параметры аудита
+// Reads - записывать в журнал и операции чтения
+type AuditOptions struct {
+	Reads bool
+}
+
+type actorKey struct{}
+
+// WithActor - возвращает контекст с инициатором операций для журнала аудита
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext - возвращает инициатора операций из контекста
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// WithAudit - оборачивает хранилище записью всех изменяющих операций в sink
+// инициатор берется из контекста (WithActor), у методов без контекста он пустой
+func WithAudit(s StoreIFace, sink AuditSink) StoreIFace {
+	return WithAuditOptions(s, sink, AuditOptions{})
+}
+
+// WithAuditOptions - оборачивает хранилище записью операций в sink с параметрами
+func WithAuditOptions(s StoreIFace, sink AuditSink, opts AuditOptions) StoreIFace {
+	return &audited{StoreIFace: s, sink: sink, opts: opts}
+}
+
+type audited struct {
+	StoreIFace
+	sink AuditSink
+	opts AuditOptions
+}
+
+func (a *audited) record(ctx context.Context, op, method, path, dst string, size int64, err error) {
+	if op == AuditRead && !a.opts.Reads {
+		return
+	}
+
+	a.sink.Record(ctx, AuditEvent{
+		Op:     op,
+		Method: method,
+		Path:   path,
+		Dst:    dst,
+		Size:   size,
+		Actor:  ActorFromContext(ctx),
+		Time:   time.Now(),
+		Err:    err,
+	})
+}
+
+func (a *audited) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return a.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (a *audited) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	err := a.StoreIFace.CreateFileWithContext(ctx, path, file, ttl, meta)
+	a.record(ctx, AuditCreate, "CreateFile", path, "", int64(len(file)), err)
+	return err
+}
+
+func (a *audited) Reserve(path string) error {
+	return a.ReserveWithContext(context.Background(), path)
+}
+
+func (a *audited) ReserveWithContext(ctx context.Context, path string) error {
+	err := a.StoreIFace.ReserveWithContext(ctx, path)
+	a.record(ctx, AuditCreate, "Reserve", path, "", 0, err)
+	return err
+}
+
+func (a *audited) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return a.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+func (a *audited) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	err := a.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+	a.record(ctx, AuditCreate, "CreateFileWithOptions", path, "", int64(len(file)), err)
+	return err
+}
+
+func (a *audited) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return a.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+func (a *audited) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	err := a.StoreIFace.CreateJsonFileWithContext(ctx, path, data, ttl, meta)
+	a.record(ctx, AuditCreate, "CreateJsonFile", path, "", 0, err)
+	return err
+}
+
+func (a *audited) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return a.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (a *audited) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	counter := &countingReader{r: stream}
+	err := a.StoreIFace.StreamToFileWithContext(ctx, counter, path, ttl)
+	a.record(ctx, AuditCreate, "StreamToFile", path, "", counter.n, err)
+	return err
+}
+
+func (a *audited) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return a.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+func (a *audited) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	n, err := a.StoreIFace.StreamToFileNWithContext(ctx, stream, path, ttl)
+	a.record(ctx, AuditCreate, "StreamToFileN", path, "", n, err)
+	return n, err
+}
+
+func (a *audited) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return a.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+func (a *audited) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	counter := &countingReader{r: r}
+	err := a.StoreIFace.ExtractArchiveWithContext(ctx, counter, path, format)
+	a.record(ctx, AuditCreate, "ExtractArchive", path, "", counter.n, err)
+	return err
+}
+
+func (a *audited) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return a.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+func (a *audited) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	w, err := a.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+	if err != nil {
+		a.record(ctx, AuditCreate, "FileWriter", path, "", 0, err)
+		return nil, err
+	}
+
+	// событие пишется при закрытии, когда известен размер
+	return &auditedWriter{WriteCloser: w, onClose: func(n int64, err error) {
+		a.record(ctx, AuditCreate, "FileWriter", path, "", n, err)
+	}}, nil
+}
+
+func (a *audited) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return a.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+func (a *audited) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	err := a.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+	a.record(ctx, AuditCopy, "CopyFile", src, dst, 0, err)
+	return err
+}
+
+func (a *audited) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return a.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+func (a *audited) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	err := a.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+	a.record(ctx, AuditCopy, "CopyFileWithOptions", src, dst, 0, err)
+	return err
+}
+
+func (a *audited) ExtractRange(src string, offset, length int64, dst string) error {
+	return a.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+func (a *audited) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	err := a.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+	a.record(ctx, AuditCopy, "ExtractRange", src, dst, 0, err)
+	return err
+}
+
+func (a *audited) Truncate(path string, size int64) error {
+	return a.TruncateWithContext(context.Background(), path, size)
+}
+
+func (a *audited) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	err := a.StoreIFace.TruncateWithContext(ctx, path, size)
+	a.record(ctx, AuditCreate, "Truncate", path, "", size, err)
+	return err
+}
+
+func (a *audited) CopyFileIfChanged(src, dst string) (bool, error) {
+	return a.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+func (a *audited) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	copied, err := a.StoreIFace.CopyFileIfChangedWithContext(ctx, src, dst)
+	if copied || err != nil {
+		a.record(ctx, AuditCopy, "CopyFileIfChanged", src, dst, 0, err)
+	}
+	return copied, err
+}
+
+func (a *audited) MoveFile(src, dst string) error {
+	return a.MoveFileWithContext(context.Background(), src, dst)
+}
+
+func (a *audited) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	err := a.StoreIFace.MoveFileWithContext(ctx, src, dst)
+	a.record(ctx, AuditMove, "MoveFile", src, dst, 0, err)
+	return err
+}
+
+func (a *audited) MoveFileNoOverwrite(src, dst string) error {
+	return a.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
+}
+
+func (a *audited) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	err := a.StoreIFace.MoveFileNoOverwriteWithContext(ctx, src, dst)
+	a.record(ctx, AuditMove, "MoveFileNoOverwrite", src, dst, 0, err)
+	return err
+}
+
+func (a *audited) SwapFiles(x, y string) error {
+	return a.SwapFilesWithContext(context.Background(), x, y)
+}
+
+func (a *audited) SwapFilesWithContext(ctx context.Context, x, y string) error {
+	err := a.StoreIFace.SwapFilesWithContext(ctx, x, y)
+	a.record(ctx, AuditMove, "SwapFiles", x, y, 0, err)
+	return err
+}
+
+func (a *audited) Rotate(path string) (string, error) {
+	return a.RotateWithContext(context.Background(), path)
+}
+
+func (a *audited) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated, err := a.StoreIFace.RotateWithContext(ctx, path)
+	a.record(ctx, AuditMove, "Rotate", path, rotated, 0, err)
+	return rotated, err
+}
+
+func (a *audited) CopyMeta(src, dst string) error {
+	return a.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+func (a *audited) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	err := a.StoreIFace.CopyMetaWithContext(ctx, src, dst)
+	a.record(ctx, AuditCopy, "CopyMeta", src, dst, 0, err)
+	return err
+}
+
+func (a *audited) Symlink(oldname, newname string) error {
+	return a.SymlinkWithContext(context.Background(), oldname, newname)
+}
+
+func (a *audited) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	err := a.StoreIFace.SymlinkWithContext(ctx, oldname, newname)
+	a.record(ctx, AuditSymlink, "Symlink", oldname, newname, 0, err)
+	return err
+}
+
+func (a *audited) RemoveFile(path string) error {
+	return a.RemoveFileWithContext(context.Background(), path)
+}
+
+func (a *audited) RemoveFileWithContext(ctx context.Context, path string) error {
+	err := a.StoreIFace.RemoveFileWithContext(ctx, path)
+	a.record(ctx, AuditRemove, "RemoveFile", path, "", 0, err)
+	return err
+}
+
+func (a *audited) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	return a.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+// RemoveFileIfMatchWithContext - записывается только состоявшееся удаление или ошибка
+func (a *audited) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	removed, err := a.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+	if removed || err != nil {
+		a.record(ctx, AuditRemove, "RemoveFileIfMatch", path, "", 0, err)
+	}
+	return removed, err
+}
+
+func (a *audited) ClearDir(path string) error {
+	return a.ClearDirWithContext(context.Background(), path)
+}
+
+func (a *audited) ClearDirWithContext(ctx context.Context, path string) error {
+	err := a.StoreIFace.ClearDirWithContext(ctx, path)
+	a.record(ctx, AuditClear, "ClearDir", path, "", 0, err)
+	return err
+}
+
+func (a *audited) MkdirAll(path string) error {
+	return a.MkdirAllWithContext(context.Background(), path)
+}
+
+func (a *audited) MkdirAllWithContext(ctx context.Context, path string) error {
+	err := a.StoreIFace.MkdirAllWithContext(ctx, path)
+	a.record(ctx, AuditMkdir, "MkdirAll", path, "", 0, err)
+	return err
+}
+
+func (a *audited) GetFile(path string) ([]byte, error) {
+	return a.GetFileWithContext(context.Background(), path)
+}
+
+func (a *audited) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	content, err := a.StoreIFace.GetFileWithContext(ctx, path)
+	a.record(ctx, AuditRead, "GetFile", path, "", int64(len(content)), err)
+	return content, err
+}
+
+func (a *audited) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return a.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+func (a *audited) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	content, err := a.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+	a.record(ctx, AuditRead, "GetFilePartially", path, "", int64(len(content)), err)
+	return content, err
+}
+
+func (a *audited) GetFileVerified(path string) ([]byte, error) {
+	return a.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+func (a *audited) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	content, err := a.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+	a.record(ctx, AuditRead, "GetFileVerified", path, "", int64(len(content)), err)
+	return content, err
+}
+
+func (a *audited) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	return a.GetFileIfModifiedSinceWithContext(context.Background(), path, t)
+}
+
+func (a *audited) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	content, modified, err := a.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, t)
+	a.record(ctx, AuditRead, "GetFileIfModifiedSince", path, "", int64(len(content)), err)
+	return content, modified, err
+}
+
+func (a *audited) Peek(path string, n int) ([]byte, error) {
+	return a.PeekWithContext(context.Background(), path, n)
+}
+
+func (a *audited) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	content, err := a.StoreIFace.PeekWithContext(ctx, path, n)
+	a.record(ctx, AuditRead, "Peek", path, "", int64(len(content)), err)
+	return content, err
+}
+
+func (a *audited) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return a.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+func (a *audited) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	stream, err := a.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	a.record(ctx, AuditRead, "FileReader", path, "", 0, err)
+	return stream, err
+}
+
+// MultiReader - каждая часть попадает в журнал отдельным событием FileReader
+func (a *audited) MultiReader(paths []string) (io.ReadCloser, error) {
+	return a.MultiReaderWithContext(context.Background(), paths)
+}
+
+func (a *audited) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, a, paths)
+}
+
+func (a *audited) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return a.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+func (a *audited) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	blocks, err := a.StoreIFace.BlockChecksumsWithContext(ctx, path, blockSize)
+	a.record(ctx, AuditRead, "BlockChecksums", path, "", 0, err)
+	return blocks, err
+}
+
+func (a *audited) WriteTo(path string, w io.Writer) (int64, error) {
+	return a.WriteToWithContext(context.Background(), path, w)
+}
+
+func (a *audited) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	n, err := a.StoreIFace.WriteToWithContext(ctx, path, w)
+	a.record(ctx, AuditRead, "WriteTo", path, "", n, err)
+	return n, err
+}
+
+func (a *audited) GetJsonFile(path string, file interface{}) error {
+	return a.GetJsonFileWithContext(context.Background(), path, file)
+}
+
+func (a *audited) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	err := a.StoreIFace.GetJsonFileWithContext(ctx, path, file)
+	a.record(ctx, AuditRead, "GetJsonFile", path, "", 0, err)
+	return err
+}
+
+func (a *audited) GetJsonMap(path string) (map[string]interface{}, error) {
+	return a.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (a *audited) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	m, err := a.StoreIFace.GetJsonMapWithContext(ctx, path)
+	a.record(ctx, AuditRead, "GetJsonMap", path, "", 0, err)
+	return m, err
+}
+
+// countingReader - считает прочитанные байты
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// auditedWriter - считает записанные байты и сообщает итог при закрытии
+type auditedWriter struct {
+	io.WriteCloser
+	n       int64
+	onClose func(int64, error)
+}
+
+func (w *auditedWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *auditedWriter) Close() error {
+	err := w.WriteCloser.Close()
+	w.onClose(w.n, err)
+	return err
+}
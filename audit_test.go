@@ -0,0 +1,308 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink - AuditSink, запоминающий события
+type recordingSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+func (r *recordingSink) Record(_ context.Context, event AuditEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// take - возвращает накопленные события и очищает журнал
+func (r *recordingSink) take() []AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestAuditMutations(t *testing.T) {
+	// auditCase - операция над хранилищем с файлом root/src.txt ("source") и директорией root/dir
+	// withDst - заранее создать и root/dst.txt
+	type auditCase struct {
+		method  string
+		op      string
+		call    func(ctx context.Context, s StoreIFace, root string) error
+		path    string
+		dst     string
+		size    int64
+		withDst bool
+	}
+
+	tests := []auditCase{
+		{"CreateFile", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.CreateFileWithContext(ctx, filepath.Join(root, "new.txt"), []byte("hello"), nil, nil)
+		}, "new.txt", "", 5, false},
+		{"Reserve", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.ReserveWithContext(ctx, filepath.Join(root, "new.txt"))
+		}, "new.txt", "", 0, false},
+		{"CreateFileWithOptions", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.CreateFileWithOptionsWithContext(ctx, filepath.Join(root, "new.txt"), []byte("hello"), PutOptions{})
+		}, "new.txt", "", 5, false},
+		{"CreateJsonFile", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.CreateJsonFileWithContext(ctx, filepath.Join(root, "new.json"), map[string]int{"a": 1}, nil, nil)
+		}, "new.json", "", 0, false},
+		{"StreamToFile", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.StreamToFileWithContext(ctx, strings.NewReader("streamed"), filepath.Join(root, "new.txt"), nil)
+		}, "new.txt", "", 8, false},
+		{"StreamToFileN", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			_, err := s.StreamToFileNWithContext(ctx, strings.NewReader("streamed"), filepath.Join(root, "new.txt"), nil)
+			return err
+		}, "new.txt", "", 8, false},
+		{"FileWriter", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			w, err := s.FileWriterWithContext(ctx, filepath.Join(root, "new.txt"), nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, "written"); err != nil {
+				return err
+			}
+			return w.Close()
+		}, "new.txt", "", 7, false},
+		{"CopyFile", AuditCopy, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.CopyFileWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"), nil, nil)
+		}, "src.txt", "dst.txt", 0, false},
+		{"CopyFileWithOptions", AuditCopy, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.CopyFileWithOptionsWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"), PutOptions{})
+		}, "src.txt", "dst.txt", 0, false},
+		{"ExtractRange", AuditCopy, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.ExtractRangeWithContext(ctx, filepath.Join(root, "src.txt"), 1, 3, filepath.Join(root, "dst.txt"))
+		}, "src.txt", "dst.txt", 0, false},
+		{"CopyFileIfChanged", AuditCopy, func(ctx context.Context, s StoreIFace, root string) error {
+			_, err := s.CopyFileIfChangedWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"))
+			return err
+		}, "src.txt", "dst.txt", 0, false},
+		{"CopyMeta", AuditCopy, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.CopyMetaWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"))
+		}, "src.txt", "dst.txt", 0, true},
+		{"Truncate", AuditCreate, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.TruncateWithContext(ctx, filepath.Join(root, "src.txt"), 2)
+		}, "src.txt", "", 2, false},
+		{"MoveFile", AuditMove, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.MoveFileWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"))
+		}, "src.txt", "dst.txt", 0, false},
+		{"MoveFileNoOverwrite", AuditMove, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.MoveFileNoOverwriteWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"))
+		}, "src.txt", "dst.txt", 0, false},
+		{"SwapFiles", AuditMove, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.SwapFilesWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "dst.txt"))
+		}, "src.txt", "dst.txt", 0, true},
+		{"Symlink", AuditSymlink, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.SymlinkWithContext(ctx, filepath.Join(root, "src.txt"), filepath.Join(root, "link.txt"))
+		}, "src.txt", "link.txt", 0, false},
+		{"RemoveFile", AuditRemove, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.RemoveFileWithContext(ctx, filepath.Join(root, "src.txt"))
+		}, "src.txt", "", 0, false},
+		{"RemoveFileIfMatch", AuditRemove, func(ctx context.Context, s StoreIFace, root string) error {
+			obj, err := s.StatObject(filepath.Join(root, "src.txt"))
+			if err != nil {
+				return err
+			}
+			_, err = s.RemoveFileIfMatchWithContext(ctx, filepath.Join(root, "src.txt"), obj.ETag)
+			return err
+		}, "src.txt", "", 0, false},
+		{"ClearDir", AuditClear, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.ClearDirWithContext(ctx, filepath.Join(root, "dir"))
+		}, "dir", "", 0, false},
+		{"MkdirAll", AuditMkdir, func(ctx context.Context, s StoreIFace, root string) error {
+			return s.MkdirAllWithContext(ctx, filepath.Join(root, "made", "deep"))
+		}, "made/deep", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			root := t.TempDir()
+			sink := &recordingSink{}
+			s := WithAudit(newTestLocal(t, LocalConfig{}), sink)
+
+			// события подготовки не относятся к проверяемой операции
+			if err := s.CreateFile(filepath.Join(root, "src.txt"), []byte("source"), nil, map[string]string{"K": "v"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.MkdirAll(filepath.Join(root, "dir")); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreateFile(filepath.Join(root, "dir", "f.txt"), []byte("f"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if tt.withDst {
+				if err := s.CreateFile(filepath.Join(root, "dst.txt"), []byte("x"), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sink.take()
+
+			before := time.Now()
+			ctx := WithActor(context.Background(), "alice")
+			if err := tt.call(ctx, s, root); err != nil {
+				t.Fatalf("%s: %v", tt.method, err)
+			}
+			after := time.Now()
+
+			events := sink.take()
+			if len(events) != 1 {
+				t.Fatalf("%s emitted %d events, want 1: %+v", tt.method, len(events), events)
+			}
+			e := events[0]
+
+			wantDst := ""
+			if tt.dst != "" {
+				wantDst = filepath.Join(root, filepath.FromSlash(tt.dst))
+			}
+			if e.Op != tt.op || e.Method != tt.method || e.Path != filepath.Join(root, filepath.FromSlash(tt.path)) || e.Dst != wantDst {
+				t.Errorf("event = %s %s %s -> %s, want %s %s %s -> %s",
+					e.Op, e.Method, e.Path, e.Dst, tt.op, tt.method, tt.path, tt.dst)
+			}
+			if e.Size != tt.size || e.Actor != "alice" || e.Err != nil {
+				t.Errorf("event size %d, actor %q, err %v; want %d, alice, nil", e.Size, e.Actor, e.Err, tt.size)
+			}
+			if e.Time.Before(before) || e.Time.After(after) {
+				t.Errorf("event time %v outside the call [%v, %v]", e.Time, before, after)
+			}
+		})
+	}
+
+	t.Run("Rotate", func(t *testing.T) {
+		root := t.TempDir()
+		sink := &recordingSink{}
+		s := WithAudit(newTestLocal(t, LocalConfig{}), sink)
+		path := filepath.Join(root, "app.log")
+		if err := s.CreateFile(path, []byte("log"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		sink.take()
+
+		rotated, err := s.RotateWithContext(WithActor(context.Background(), "alice"), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events := sink.take()
+		if len(events) != 1 || events[0].Op != AuditMove || events[0].Path != path || events[0].Dst != rotated {
+			t.Errorf("Rotate events = %+v, want one move %s -> %s", events, path, rotated)
+		}
+	})
+
+	t.Run("ExtractArchive", func(t *testing.T) {
+		root := t.TempDir()
+		sink := &recordingSink{}
+		s := WithAudit(newTestLocal(t, LocalConfig{}), sink)
+		data := buildArchive(t, ArchiveTar, []archiveEntry{{"a.txt", "a"}, {"b/c.txt", "c"}})
+
+		if err := s.ExtractArchiveWithContext(WithActor(context.Background(), "alice"), bytes.NewReader(data), root, ArchiveTar); err != nil {
+			t.Fatal(err)
+		}
+		events := sink.take()
+		if len(events) != 1 || events[0].Op != AuditCreate || events[0].Path != root || events[0].Size != int64(len(data)) {
+			t.Errorf("ExtractArchive events = %+v, want one create of %s with %d bytes", events, root, len(data))
+		}
+	})
+
+	t.Run("failed operation records the error", func(t *testing.T) {
+		sink := &recordingSink{}
+		s := WithAudit(newTestLocal(t, LocalConfig{}), sink)
+		missing := filepath.Join(t.TempDir(), "missing.txt")
+
+		err := s.MoveFile(missing, missing+".bak")
+		events := sink.take()
+		if err == nil || len(events) != 1 || events[0].Err != err || events[0].Actor != "" {
+			t.Errorf("MoveFile of a missing file: err %v, events %+v; want one event with the error", err, events)
+		}
+	})
+}
+
+func TestAuditReads(t *testing.T) {
+	reads := []struct {
+		method string
+		call   func(ctx context.Context, s StoreIFace, path string) error
+	}{
+		{"GetFile", func(ctx context.Context, s StoreIFace, path string) error {
+			_, err := s.GetFileWithContext(ctx, path)
+			return err
+		}},
+		{"GetFilePartially", func(ctx context.Context, s StoreIFace, path string) error {
+			_, err := s.GetFilePartiallyWithContext(ctx, path, 1, 2)
+			return err
+		}},
+		{"GetFileIfModifiedSince", func(ctx context.Context, s StoreIFace, path string) error {
+			_, _, err := s.GetFileIfModifiedSinceWithContext(ctx, path, time.Time{})
+			return err
+		}},
+		{"Peek", func(ctx context.Context, s StoreIFace, path string) error {
+			_, err := s.PeekWithContext(ctx, path, 2)
+			return err
+		}},
+		{"FileReader", func(ctx context.Context, s StoreIFace, path string) error {
+			r, err := s.FileReaderWithContext(ctx, path, 0, 0)
+			if err != nil {
+				return err
+			}
+			return r.Close()
+		}},
+		{"BlockChecksums", func(ctx context.Context, s StoreIFace, path string) error {
+			_, err := s.BlockChecksumsWithContext(ctx, path, 4)
+			return err
+		}},
+		{"WriteTo", func(ctx context.Context, s StoreIFace, path string) error {
+			_, err := s.WriteToWithContext(ctx, path, io.Discard)
+			return err
+		}},
+		{"GetJsonFile", func(ctx context.Context, s StoreIFace, path string) error {
+			var v map[string]int
+			return s.GetJsonFileWithContext(ctx, path, &v)
+		}},
+		{"GetJsonMap", func(ctx context.Context, s StoreIFace, path string) error {
+			_, err := s.GetJsonMapWithContext(ctx, path)
+			return err
+		}},
+	}
+
+	for _, withReads := range []bool{false, true} {
+		name := "default"
+		if withReads {
+			name = "reads enabled"
+		}
+		for _, tt := range reads {
+			t.Run(name+"/"+tt.method, func(t *testing.T) {
+				sink := &recordingSink{}
+				s := WithAuditOptions(newTestLocal(t, LocalConfig{}), sink, AuditOptions{Reads: withReads})
+				path := filepath.Join(t.TempDir(), "data.json")
+				if err := s.CreateFile(path, []byte(`{"a":1}`), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				sink.take()
+
+				if err := tt.call(WithActor(context.Background(), "bob"), s, path); err != nil {
+					t.Fatalf("%s: %v", tt.method, err)
+				}
+				// чтения без собственного события не пишут ничего и со включенным аудитом чтений
+				_, _ = s.StatObject(path)
+				_, _ = s.ListDirDepth(filepath.Dir(path), 1)
+
+				events := sink.take()
+				switch {
+				case !withReads && len(events) != 0:
+					t.Errorf("%s emitted %+v with reads disabled, want nothing", tt.method, events)
+				case withReads && (len(events) != 1 || events[0].Op != AuditRead || events[0].Method != tt.method ||
+					events[0].Path != path || events[0].Actor != "bob"):
+					t.Errorf("%s emitted %+v with reads enabled, want one read by bob", tt.method, events)
+				}
+			})
+		}
+	}
+}
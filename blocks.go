@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"strconv"
+	"time"
+)
+
+// BlockHash - контрольные суммы блока файла для rsync-подобной синхронизации
+// Index - номер блока
+// Offset - смещение блока от начала файла
+// Size - размер блока, последний блок может быть короче
+// Weak - быстрая сумма adler-32, по ней отбираются кандидаты
+// Strong - sha256 блока (hex), подтверждает совпадение
+type BlockHash struct {
+	Index  int
+	Offset int64
+	Size   int
+	Weak   uint32
+	Strong string
+}
+
+// BlockOp - шаг сборки нового файла из BlockDiff
+// Offset, Size - положение блока в новом файле
+// BaseOffset - смещение такого же блока в старом файле; -1 - блок изменился
+// и читается из нового файла
+type BlockOp struct {
+	Offset     int64
+	Size       int
+	BaseOffset int64
+}
+
+// blockChecksums - читает файл одним потоком FileReader и считает суммы блоков
+// пустой файл дает пустой список
+func blockChecksums(ctx context.Context, s StoreIFace, path string, blockSize int) ([]BlockHash, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size %d", blockSize)
+	}
+
+	r, err := s.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		// Local не открывает пустые файлы
+		if info, _, statErr := s.StatWithContext(ctx, path); statErr == nil && info != nil && info.Size() == 0 {
+			return nil, nil
+		}
+		return nil, ErrFileNotFound
+	}
+	defer r.Close()
+
+	var result []BlockHash
+	var offset int64
+	buf := make([]byte, blockSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			strong := sha256.Sum256(buf[:n])
+			result = append(result, BlockHash{
+				Index:  len(result),
+				Offset: offset,
+				Size:   n,
+				Weak:   adler32.Checksum(buf[:n]),
+				Strong: hex.EncodeToString(strong[:]),
+			})
+			offset += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// BlockDiff - сравнивает суммы старого (base) и нового (target) файла
+// base - BlockChecksums файла, который обновляется
+// target - BlockChecksums файла, содержимое которого нужно получить
+// блок target, совпадающий с любым блоком base (не обязательно на том же месте),
+// берется из base, остальные - из нового файла. Размеры блоков обоих списков
+// должны совпадать, иначе совпадут только блоки одинаковой длины.
+func BlockDiff(base, target []BlockHash) []BlockOp {
+	byWeak := make(map[uint32][]BlockHash, len(base))
+	for _, b := range base {
+		byWeak[b.Weak] = append(byWeak[b.Weak], b)
+	}
+
+	ops := make([]BlockOp, 0, len(target))
+	for _, t := range target {
+		op := BlockOp{Offset: t.Offset, Size: t.Size, BaseOffset: -1}
+		for _, b := range byWeak[t.Weak] {
+			if b.Size == t.Size && b.Strong == t.Strong {
+				op.BaseOffset = b.Offset
+				break
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// ApplyBlockPatch - обновляет файл dst по BlockDiff, читая из src только измененные блоки
+// dst - хранилище с файлом, который обновляется
+// path - путь к файлу в dst
+// src - хранилище с новым файлом
+// srcPath - путь к новому файлу в src
+// ops - результат BlockDiff(dst.BlockChecksums(path), src.BlockChecksums(srcPath))
+func ApplyBlockPatch(dst StoreIFace, path string, src StoreIFace, srcPath string, ops []BlockOp) error {
+	return ApplyBlockPatchWithContext(context.Background(), dst, path, src, srcPath, ops)
+}
+
+// ApplyBlockPatchWithContext - обновляет файл dst по BlockDiff, читая из src только измененные блоки
+// dst - хранилище с файлом, который обновляется
+// path - путь к файлу в dst
+// src - хранилище с новым файлом
+// srcPath - путь к новому файлу в src
+// ops - результат BlockDiff
+// Новое содержимое собирается во временный файл рядом с path и переносится MoveFile,
+// поэтому при ошибке path не меняется. Соседние блоки одного источника читаются
+// одним запросом. Метаданные path не переносятся: контрольные суммы в них
+// относились бы к старому содержимому.
+func ApplyBlockPatchWithContext(ctx context.Context, dst StoreIFace, path string, src StoreIFace, srcPath string, ops []BlockOp) error {
+	tmp := path + ".patch-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	r := &patchReader{ctx: ctx, dst: dst, path: path, src: src, srcPath: srcPath, ops: ops}
+	defer r.Close()
+
+	if err := dst.StreamToFileWithContext(ctx, r, tmp, nil); err != nil {
+		if removeErr := dst.RemoveFileWithContext(context.WithoutCancel(ctx), tmp); removeErr != nil && !errors.Is(removeErr, ErrFileNotFound) {
+			return errors.Join(err, removeErr)
+		}
+		return err
+	}
+	if err := dst.MoveFileWithContext(ctx, tmp, path); err != nil {
+		return errors.Join(err, dst.RemoveFileWithContext(context.WithoutCancel(ctx), tmp))
+	}
+	return nil
+}
+
+// patchReader - содержимое нового файла по шагам BlockDiff, части открываются по мере чтения
+type patchReader struct {
+	ctx     context.Context
+	dst     StoreIFace
+	path    string
+	src     StoreIFace
+	srcPath string
+	ops     []BlockOp
+	cur     io.ReadCloser
+}
+
+func (p *patchReader) Read(b []byte) (int, error) {
+	for {
+		if p.cur != nil {
+			n, err := p.cur.Read(b)
+			if err == io.EOF {
+				p.cur.Close()
+				p.cur = nil
+				if n > 0 {
+					return n, nil
+				}
+				continue
+			}
+			return n, err
+		}
+		if len(p.ops) == 0 {
+			return 0, io.EOF
+		}
+
+		// соседние шаги одного источника, идущие подряд и в источнике
+		first := p.ops[0]
+		length := int64(first.Size)
+		n := 1
+		for ; n < len(p.ops); n++ {
+			op := p.ops[n]
+			if (op.BaseOffset < 0) != (first.BaseOffset < 0) {
+				break
+			}
+			if first.BaseOffset >= 0 && op.BaseOffset != first.BaseOffset+length {
+				break
+			}
+			length += int64(op.Size)
+		}
+		p.ops = p.ops[n:]
+		if length == 0 {
+			continue
+		}
+
+		s, path, offset := p.src, p.srcPath, first.Offset
+		if first.BaseOffset >= 0 {
+			s, path, offset = p.dst, p.path, first.BaseOffset
+		}
+		r, err := s.FileReaderWithContext(p.ctx, path, offset, length)
+		if err == nil && r == nil {
+			err = ErrFileNotFound
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		p.cur = &exactReader{r: r, left: length}
+	}
+}
+
+func (p *patchReader) Close() error {
+	if p.cur != nil {
+		return p.cur.Close()
+	}
+	return nil
+}
+
+// exactReader - часть файла ровно заданной длины; файл, укоротившийся
+// после BlockChecksums, дает ошибку, а не файл с пропущенными байтами
+type exactReader struct {
+	r    io.ReadCloser
+	left int64
+}
+
+func (e *exactReader) Read(b []byte) (int, error) {
+	if e.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > e.left {
+		b = b[:e.left]
+	}
+	n, err := e.r.Read(b)
+	e.left -= int64(n)
+	if err == io.EOF && e.left > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (e *exactReader) Close() error {
+	return e.r.Close()
+}
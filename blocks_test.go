@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash/adler32"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// wantBlocks - ожидаемые суммы блоков data
+func wantBlocks(data []byte, blockSize int) []BlockHash {
+	var blocks []BlockHash
+	for offset := 0; offset < len(data); offset += blockSize {
+		block := data[offset:min(offset+blockSize, len(data))]
+		strong := sha256.Sum256(block)
+		blocks = append(blocks, BlockHash{
+			Index:  len(blocks),
+			Offset: int64(offset),
+			Size:   len(block),
+			Weak:   adler32.Checksum(block),
+			Strong: hex.EncodeToString(strong[:]),
+		})
+	}
+	return blocks
+}
+
+func TestBlockChecksums(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			path, same, changed := joinKey(dir, "a.bin"), joinKey(dir, "same.bin"), joinKey(dir, "changed.bin")
+			// changed отличается от data одним байтом во втором блоке
+			changedData := append([]byte(nil), data...)
+			changedData[9] = 'X'
+			for p, body := range map[string][]byte{path: data, same: data, changed: changedData, joinKey(dir, "empty.bin"): nil} {
+				if err := s.CreateFile(p, body, nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// блоки 8, 8 и короткий последний 4
+			blocks, err := s.BlockChecksums(path, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := wantBlocks(data, 8); !reflect.DeepEqual(blocks, want) {
+				t.Errorf("BlockChecksums = %+v, want %+v", blocks, want)
+			}
+
+			sameBlocks, err := s.BlockChecksums(same, 8)
+			if err != nil || !reflect.DeepEqual(sameBlocks, blocks) {
+				t.Errorf("identical file = %+v, %v; want the same list", sameBlocks, err)
+			}
+
+			changedBlocks, err := s.BlockChecksums(changed, 8)
+			if err != nil || len(changedBlocks) != len(blocks) {
+				t.Fatalf("changed file = %+v, %v", changedBlocks, err)
+			}
+			for i := range blocks {
+				if differs := changedBlocks[i] != blocks[i]; differs != (i == 1) {
+					t.Errorf("block %d differs = %v, want only block 1 changed", i, differs)
+				}
+			}
+			if changedBlocks[1].Weak == blocks[1].Weak || changedBlocks[1].Strong == blocks[1].Strong {
+				t.Errorf("changed block sums = %+v, want both sums to change", changedBlocks[1])
+			}
+
+			if blocks, err := s.BlockChecksums(joinKey(dir, "empty.bin"), 8); err != nil || len(blocks) != 0 {
+				t.Errorf("empty file = %+v, %v; want no blocks", blocks, err)
+			}
+			if _, err := s.BlockChecksums(joinKey(dir, "missing.bin"), 8); !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("missing file = %v, want ErrFileNotFound", err)
+			}
+			if _, err := s.BlockChecksums(path, 0); err == nil {
+				t.Error("zero block size accepted, want an error")
+			}
+		})
+	}
+}
+
+func TestBlockDiff(t *testing.T) {
+	base := []byte("aaaabbbbccccdd")
+
+	tests := []struct {
+		name   string
+		target string
+		want   []BlockOp
+	}{
+		{"identical", "aaaabbbbccccdd", []BlockOp{{0, 4, 0}, {4, 4, 4}, {8, 4, 8}, {12, 2, 12}}},
+		{"one byte changed", "aaaabXbbccccdd", []BlockOp{{0, 4, 0}, {4, 4, -1}, {8, 4, 8}, {12, 2, 12}}},
+		{"blocks moved", "ccccaaaabbbbdd", []BlockOp{{0, 4, 8}, {4, 4, 0}, {8, 4, 4}, {12, 2, 12}}},
+		{"grown", "aaaabbbbccccddee", []BlockOp{{0, 4, 0}, {4, 4, 4}, {8, 4, 8}, {12, 4, -1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BlockDiff(wantBlocks(base, 4), wantBlocks([]byte(tt.target), 4))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BlockDiff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// rangeRecorder - хранилище, запоминающее диапазоны чтения FileReader
+type rangeRecorder struct {
+	StoreIFace
+
+	mu     sync.Mutex
+	ranges [][2]int64
+}
+
+func (r *rangeRecorder) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	r.mu.Lock()
+	r.ranges = append(r.ranges, [2]int64{offset, length})
+	r.mu.Unlock()
+	return r.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+}
+
+func TestApplyBlockPatch(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			path, srcPath := joinKey(dir, "old.bin"), joinKey(dir, "new.bin")
+			if err := s.CreateFile(path, []byte("aaaabbbbccccdd"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreateFile(srcPath, []byte("aaaabXbbccccdd"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			base, err := s.BlockChecksums(path, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target, err := s.BlockChecksums(srcPath, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			src := &rangeRecorder{StoreIFace: s}
+			if err := ApplyBlockPatch(s, path, src, srcPath, BlockDiff(base, target)); err != nil {
+				t.Fatalf("ApplyBlockPatch: %v", err)
+			}
+			if got, err := s.GetFile(path); err != nil || string(got) != "aaaabXbbccccdd" {
+				t.Errorf("patched file = %q, %v; want the new content", got, err)
+			}
+			// из нового файла читается только измененный блок
+			if want := [][2]int64{{4, 4}}; !reflect.DeepEqual(src.ranges, want) {
+				t.Errorf("reads from the new file = %v, want %v", src.ranges, want)
+			}
+		})
+	}
+}
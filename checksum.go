@@ -0,0 +1,59 @@
+package store
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Ключи метаданных с контрольными суммами содержимого (hex)
+const (
+	MetaSHA256 = "sha256"
+	MetaMD5    = "md5"
+)
+
+// ChecksumAlgo - алгоритм контрольной суммы
+type ChecksumAlgo string
+
+const (
+	ChecksumMD5    ChecksumAlgo = MetaMD5
+	ChecksumSHA256 ChecksumAlgo = MetaSHA256
+)
+
+// verifyChecksum - сверяет содержимое с контрольными суммами из метаданных
+// content - содержимое файла
+// meta - метаданные файла, ключи сравниваются без учета регистра
+// etag - ETag объекта S3, используется если в метаданных нет контрольных сумм;
+// ETag multipart загрузки (с "-") не является md5 и пропускается
+func verifyChecksum(content []byte, meta map[string]string, etag string) error {
+	checked := false
+
+	for key, value := range meta {
+		var sum []byte
+		switch strings.ToLower(key) {
+		case MetaSHA256:
+			s := sha256.Sum256(content)
+			sum = s[:]
+		case MetaMD5:
+			s := md5.Sum(content)
+			sum = s[:]
+		default:
+			continue
+		}
+
+		checked = true
+		if !strings.EqualFold(hex.EncodeToString(sum), value) {
+			return ErrChecksumMismatch
+		}
+	}
+
+	if !checked && etag != "" && !strings.Contains(etag, "-") {
+		sum := md5.Sum(content)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), strings.Trim(etag, `"`)) {
+			return ErrChecksumMismatch
+		}
+	}
+
+	return nil
+}
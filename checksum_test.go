@@ -0,0 +1,121 @@
+package store
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// corruptible - хранилище и функция, подменяющая байт файла в обход хранилища
+type corruptible struct {
+	name  string
+	store func(t *testing.T) (s StoreIFace, dir string, corrupt func(t *testing.T, path string))
+}
+
+// flipFirstByte - меняет первый байт файла на диске
+func flipFirstByte(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var corruptibleBackends = []corruptible{
+	{"local", func(t *testing.T) (StoreIFace, string, func(*testing.T, string)) {
+		return newTestLocal(t, LocalConfig{}), t.TempDir(), flipFirstByte
+	}},
+	{"webdav", func(t *testing.T) (StoreIFace, string, func(*testing.T, string)) {
+		w, root := newTestWebDavDir(t, WebDavConfig{})
+		return w, "", func(t *testing.T, path string) { flipFirstByte(t, filepath.Join(root, path)) }
+	}},
+	{"s3", func(t *testing.T) (StoreIFace, string, func(*testing.T, string)) {
+		s, f := newFakeS3(t, S3Config{})
+		// ETag остается от исходного содержимого, как при порче на диске сервера
+		return s, "", func(t *testing.T, path string) { f.object(path).data[0] ^= 0xff }
+	}},
+}
+
+func TestGetFileVerified(t *testing.T) {
+	data := []byte("stored content")
+	sha := sha256.Sum256(data)
+	md := md5.Sum(data)
+
+	tests := []struct {
+		name string
+		meta map[string]string
+	}{
+		{"sha256", map[string]string{MetaSHA256: hex.EncodeToString(sha[:])}},
+		{"md5", map[string]string{MetaMD5: hex.EncodeToString(md[:])}},
+		{"upper case key and sum", map[string]string{"SHA256": hex.EncodeToString(sha[:])}},
+	}
+
+	for _, b := range corruptibleBackends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				s, dir, corrupt := b.store(t)
+				path := joinKey(dir, "a.txt")
+				if err := s.CreateFile(path, data, nil, tt.meta); err != nil {
+					t.Fatal(err)
+				}
+
+				if got, err := s.GetFileVerified(path); err != nil || string(got) != string(data) {
+					t.Fatalf("GetFileVerified = %q, %v; want the stored content", got, err)
+				}
+
+				corrupt(t, path)
+				if got, err := s.GetFileVerified(path); !errors.Is(err, ErrChecksumMismatch) || got != nil {
+					t.Errorf("GetFileVerified after a flipped byte = %q, %v; want ErrChecksumMismatch", got, err)
+				}
+			})
+		}
+
+		t.Run(b.name+"/missing file", func(t *testing.T) {
+			s, dir, _ := b.store(t)
+			if _, err := s.GetFileVerified(joinKey(dir, "missing.txt")); !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("GetFileVerified = %v, want ErrFileNotFound", err)
+			}
+		})
+	}
+
+	t.Run("without checksums behaves like GetFile", func(t *testing.T) {
+		for _, b := range corruptibleBackends[:2] {
+			s, dir, corrupt := b.store(t)
+			path := joinKey(dir, "a.txt")
+			if err := s.CreateFile(path, data, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			corrupt(t, path)
+			want, _ := s.GetFile(path)
+			if got, err := s.GetFileVerified(path); err != nil || string(got) != string(want) {
+				t.Errorf("%s: GetFileVerified = %q, %v; want %q as GetFile", b.name, got, err, want)
+			}
+		}
+	})
+
+	t.Run("s3 single-part ETag", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("a.txt", data, nil)
+		if _, err := s.GetFileVerified("a.txt"); err != nil {
+			t.Fatalf("GetFileVerified = %v, want the ETag to match", err)
+		}
+		f.object("a.txt").data[0] ^= 0xff
+		if _, err := s.GetFileVerified("a.txt"); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("GetFileVerified after a flipped byte = %v, want ErrChecksumMismatch", err)
+		}
+
+		// ETag multipart загрузки не md5 содержимого и не проверяется
+		f.object("a.txt").etag = "0123456789abcdef0123456789abcdef-2"
+		if _, err := s.GetFileVerified("a.txt"); err != nil {
+			t.Errorf("GetFileVerified with a multipart ETag = %v, want no check", err)
+		}
+	})
+}
@@ -0,0 +1,1074 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"time"
+)
+
+// chunkManifestName - имя манифеста рядом с частями разбитого файла
+const chunkManifestName = "manifest.json"
+
+// WithChunking - оборачивает хранилище разбиением больших файлов на части
+// s - хранилище
+// chunkSize - максимальный размер одной части в байтах
+// CreateFile с содержимым больше chunkSize записывается частями path/000, path/001, ...
+// и манифестом path/manifest.json с общим размером; метаданные и TTL назначаются манифесту.
+// Размер потока в StreamToFile заранее не известен, поэтому поток всегда пишется частями.
+// FileWriter, как и StreamToFile, пишет частями.
+// GetFile, FileReader, GetFilePartially (в том числе через границу частей), GetFileVerified,
+// GetFileIfModifiedSince, Stat и StatObject собирают файл по манифесту и возвращают общий размер;
+// RemoveFile, RemoveFileIfMatch, CopyFile(WithOptions), MoveFile(NoOverwrite), Rotate и CopyMeta
+// обрабатывают все части. SwapFiles разбитых файлов возвращает errors.ErrUnsupported.
+// ListDirChan, ListDirDepth, ListModifiedSince, ListMeta, Latest, Manifest и ArchiveDir
+// отдают разбитый файл как файл с общим размером; ExtractArchive пишет файлы частями;
+// IsEmpty разбитого файла возвращает ErrIsNotDir, Reserve занятого им пути - ErrAlreadyExists.
+// Файлы без манифеста читаются как есть.
+// Каждая операция с файлом и каждая поддиректория листинга делают лишний запрос за манифестом.
+// Остальные методы (ExistMany, Lstat, ClearDir и т.п.) видят разбитый файл как директорию с частями.
+func WithChunking(s StoreIFace, chunkSize int64) StoreIFace {
+	if chunkSize <= 0 {
+		return s
+	}
+	return &chunked{StoreIFace: s, chunkSize: chunkSize}
+}
+
+type chunked struct {
+	StoreIFace
+	chunkSize int64
+}
+
+// chunkManifest - описание разбитого файла
+type chunkManifest struct {
+	Size      int64 `json:"size"`
+	ChunkSize int64 `json:"chunk_size"`
+	Chunks    int   `json:"chunks"`
+}
+
+// chunkPath - путь к части с номером i
+func chunkPath(path string, i int) string {
+	return fmt.Sprintf("%s/%03d", path, i)
+}
+
+// manifestPath - путь к манифесту разбитого файла
+func manifestPath(path string) string {
+	return path + "/" + chunkManifestName
+}
+
+// manifest - манифест файла, nil - файл не разбит
+func (c *chunked) manifest(ctx context.Context, path string) (*chunkManifest, error) {
+	raw, err := c.StoreIFace.GetFileWithContext(ctx, manifestPath(path))
+	if errors.Is(err, ErrFileNotFound) || errors.Is(err, ErrIsNotDir) {
+		return nil, nil
+	}
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	m := &chunkManifest{}
+	if err := json.Unmarshal(raw, m); err != nil {
+		return nil, fmt.Errorf("chunk manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// writeChunks - записывает поток частями и манифест с opts
+func (c *chunked) writeChunks(ctx context.Context, r io.Reader, path string, opts PutOptions) (int64, error) {
+	old, err := c.manifest(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	if old == nil {
+		// на месте будущей директории частей может лежать обычный файл
+		if err := c.StoreIFace.RemoveFileWithContext(ctx, path); err != nil && !errors.Is(err, ErrFileNotFound) {
+			return 0, err
+		}
+	}
+	// в S3 директория - это пустой объект, который закрыл бы путь к файлу
+	if c.StoreIFace.Backend() != S3Store {
+		if err := c.StoreIFace.MkdirAllWithContext(ctx, path); err != nil {
+			return 0, err
+		}
+	}
+
+	br := bufio.NewReader(r)
+	m := chunkManifest{ChunkSize: c.chunkSize}
+	for {
+		n, err := c.StoreIFace.StreamToFileNWithContext(ctx, io.LimitReader(br, c.chunkSize), chunkPath(path, m.Chunks), opts.TTL)
+		if err != nil {
+			return m.Size, err
+		}
+		m.Size += n
+		m.Chunks++
+
+		if _, err := br.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			return m.Size, err
+		}
+	}
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return m.Size, err
+	}
+	if err := c.StoreIFace.CreateFileWithOptionsWithContext(ctx, manifestPath(path), raw, opts); err != nil {
+		return m.Size, err
+	}
+
+	if old != nil {
+		for i := m.Chunks; i < old.Chunks; i++ {
+			if err := c.StoreIFace.RemoveFileWithContext(ctx, chunkPath(path, i)); err != nil && !errors.Is(err, ErrFileNotFound) {
+				return m.Size, err
+			}
+		}
+	}
+	return m.Size, nil
+}
+
+// removeChunks - удаляет манифест, части и директорию разбитого файла
+func (c *chunked) removeChunks(ctx context.Context, path string, m *chunkManifest) error {
+	if err := c.StoreIFace.RemoveFileWithContext(ctx, manifestPath(path)); err != nil && !errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	for i := 0; i < m.Chunks; i++ {
+		if err := c.StoreIFace.RemoveFileWithContext(ctx, chunkPath(path, i)); err != nil && !errors.Is(err, ErrFileNotFound) {
+			return err
+		}
+	}
+	if err := c.StoreIFace.RemoveFileWithContext(ctx, path); err != nil && !errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// exists - есть ли файл path, разбитый или целый
+func (c *chunked) exists(ctx context.Context, path string) (bool, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if m != nil {
+		return true, nil
+	}
+	if _, err := c.StoreIFace.StatLiteWithContext(ctx, path); err == nil {
+		return true, nil
+	}
+	return false, nil
+}
+
+// metaPath - путь, метаданные которого принадлежат файлу: у разбитого файла это манифест
+func (c *chunked) metaPath(ctx context.Context, path string) (string, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if m != nil {
+		return manifestPath(path), nil
+	}
+	return path, nil
+}
+
+// prepareWhole - перед записью файла целиком удаляет его прежние части
+func (c *chunked) prepareWhole(ctx context.Context, path string) error {
+	m, err := c.manifest(ctx, path)
+	if err != nil || m == nil {
+		return err
+	}
+	return c.removeChunks(ctx, path, m)
+}
+
+func (c *chunked) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return c.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (c *chunked) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return c.CreateFileWithOptionsWithContext(ctx, path, file, PutOptions{TTL: ttl, Meta: meta})
+}
+
+func (c *chunked) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return c.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+// CreateFileWithOptionsWithContext - без перезаписи (opts.Overwrite) существование файла
+// проверяется отдельным запросом, поэтому проверка и запись разбитого файла не атомарны
+func (c *chunked) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	if opts.Overwrite != OverwriteAllow {
+		exists, err := c.exists(ctx, path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return opts.Overwrite.result(fmt.Errorf("%w: %s", ErrAlreadyExists, path))
+		}
+	}
+
+	if int64(len(file)) > c.chunkSize {
+		_, err := c.writeChunks(ctx, bytes.NewReader(file), path, opts)
+		return err
+	}
+
+	if err := c.prepareWhole(ctx, path); err != nil {
+		return err
+	}
+	return c.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+}
+
+func (c *chunked) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return c.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+func (c *chunked) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	file, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.CreateFileWithContext(ctx, path, file, ttl, meta)
+}
+
+func (c *chunked) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return c.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (c *chunked) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	_, err := c.writeChunks(ctx, stream, path, PutOptions{TTL: ttl})
+	return err
+}
+
+func (c *chunked) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return c.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+func (c *chunked) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return c.writeChunks(ctx, stream, path, PutOptions{TTL: ttl})
+}
+
+func (c *chunked) IsExist(path string) bool {
+	if m, err := c.manifest(context.Background(), path); err == nil && m != nil {
+		return true
+	}
+	return c.StoreIFace.IsExist(path)
+}
+
+func (c *chunked) GetFile(path string) ([]byte, error) {
+	return c.GetFileWithContext(context.Background(), path)
+}
+
+func (c *chunked) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.GetFileWithContext(ctx, path)
+	}
+
+	stream := &chunkReader{ctx: ctx, s: c.StoreIFace, path: path, m: m, remaining: m.Size}
+	defer stream.Close()
+
+	buf := bytes.NewBuffer(make([]byte, 0, m.Size))
+	if _, err := buf.ReadFrom(stream); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *chunked) GetJsonFile(path string, file interface{}) error {
+	return c.GetJsonFileWithContext(context.Background(), path, file)
+}
+
+func (c *chunked) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	content, err := c.GetFileWithContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		return nil
+	}
+	return json.Unmarshal(content, file)
+}
+
+func (c *chunked) GetJsonMap(path string) (map[string]interface{}, error) {
+	return c.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (c *chunked) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	content, err := c.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, ErrFileNotFound
+	}
+	return decodeJsonMap(content)
+}
+
+func (c *chunked) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return c.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+func (c *chunked) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+	}
+
+	stream, err := c.reader(ctx, path, m, offset, length)
+	if err != nil {
+		return []byte{}, err
+	}
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+func (c *chunked) Peek(path string, n int) ([]byte, error) {
+	return c.PeekWithContext(context.Background(), path, n)
+}
+
+func (c *chunked) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.PeekWithContext(ctx, path, n)
+	}
+	return c.GetFilePartiallyWithContext(ctx, path, 0, int64(n))
+}
+
+func (c *chunked) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return c.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+func (c *chunked) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	}
+	return c.reader(ctx, path, m, offset, length)
+}
+
+// MultiReader - файлы, разбитые на чанки, читаются целиком через FileReader
+func (c *chunked) MultiReader(paths []string) (io.ReadCloser, error) {
+	return c.MultiReaderWithContext(context.Background(), paths)
+}
+
+func (c *chunked) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, c, paths)
+}
+
+func (c *chunked) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return c.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+func (c *chunked) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, c, path, blockSize)
+}
+
+func (c *chunked) WriteTo(path string, w io.Writer) (int64, error) {
+	return c.WriteToWithContext(context.Background(), path, w)
+}
+
+func (c *chunked) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	if m == nil {
+		return c.StoreIFace.WriteToWithContext(ctx, path, w)
+	}
+
+	stream := &chunkReader{ctx: ctx, s: c.StoreIFace, path: path, m: m, remaining: m.Size}
+	defer stream.Close()
+
+	return io.Copy(w, stream)
+}
+
+// reader - поток части разбитого файла с границами как у GetFilePartially
+func (c *chunked) reader(ctx context.Context, path string, m *chunkManifest, offset, length int64) (io.ReadCloser, error) {
+	length, err := partialLength(m.Size, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkReader{
+		ctx:       ctx,
+		s:         c.StoreIFace,
+		path:      path,
+		m:         m,
+		chunk:     int(offset / m.ChunkSize),
+		offset:    offset % m.ChunkSize,
+		remaining: length,
+	}, nil
+}
+
+// chunkReader - последовательно читает части разбитого файла
+type chunkReader struct {
+	ctx  context.Context
+	s    StoreIFace
+	path string
+	m    *chunkManifest
+
+	// chunk - номер текущей части, offset - смещение в ней
+	chunk  int
+	offset int64
+	// remaining - сколько байт осталось прочитать
+	remaining int64
+	cur       io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.remaining <= 0 {
+			return 0, io.EOF
+		}
+
+		if r.cur == nil {
+			if r.chunk >= r.m.Chunks {
+				return 0, io.ErrUnexpectedEOF
+			}
+			n := min(r.remaining, r.m.ChunkSize-r.offset)
+			stream, err := r.s.FileReaderWithContext(r.ctx, chunkPath(r.path, r.chunk), r.offset, n)
+			if err != nil {
+				return 0, err
+			}
+			if stream == nil {
+				return 0, ErrFileNotFound
+			}
+			r.cur = stream
+		}
+
+		n, err := r.cur.Read(p)
+		r.remaining -= int64(n)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			r.chunk++
+			r.offset = 0
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
+
+// chunkFileInfo - информация о разбитом файле с общим размером
+type chunkFileInfo struct {
+	os.FileInfo
+	name string
+	size int64
+}
+
+func (f chunkFileInfo) Name() string {
+	return f.name
+}
+
+func (f chunkFileInfo) Size() int64 {
+	return f.size
+}
+
+func (c *chunked) Stat(path string) (os.FileInfo, map[string]string, error) {
+	return c.StatWithContext(context.Background(), path)
+}
+
+// StatWithContext - для разбитого файла время изменения и метаданные берутся у манифеста
+func (c *chunked) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.StatWithContext(ctx, path)
+	}
+
+	info, meta, err := c.StoreIFace.StatWithContext(ctx, manifestPath(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	return chunkFileInfo{FileInfo: info, name: pathpkg.Base(path), size: m.Size}, meta, nil
+}
+
+func (c *chunked) StatLite(path string) (os.FileInfo, error) {
+	return c.StatLiteWithContext(context.Background(), path)
+}
+
+func (c *chunked) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.StatLiteWithContext(ctx, path)
+	}
+
+	info, err := c.StoreIFace.StatLiteWithContext(ctx, manifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return chunkFileInfo{FileInfo: info, name: pathpkg.Base(path), size: m.Size}, nil
+}
+
+func (c *chunked) StatObject(path string) (ObjectInfo, error) {
+	return c.StatObjectWithContext(context.Background(), path)
+}
+
+func (c *chunked) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if m == nil {
+		return c.StoreIFace.StatObjectWithContext(ctx, path)
+	}
+
+	obj, err := c.StoreIFace.StatObjectWithContext(ctx, manifestPath(path))
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	// S3 возвращает ключ целиком, остальные хранилища - имя файла
+	if obj.Name == manifestPath(path) {
+		obj.Name = path
+	} else {
+		obj.Name = pathpkg.Base(path)
+	}
+	obj.Size = m.Size
+	return obj, nil
+}
+
+func (c *chunked) RemoveFile(path string) error {
+	return c.RemoveFileWithContext(context.Background(), path)
+}
+
+func (c *chunked) RemoveFileWithContext(ctx context.Context, path string) error {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return c.StoreIFace.RemoveFileWithContext(ctx, path)
+	}
+	return c.removeChunks(ctx, path, m)
+}
+
+func (c *chunked) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return c.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+// CopyFileWithContext - разбитый файл копируется по частям, метаданные дополняют метаданные манифеста
+func (c *chunked) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	m, err := c.manifest(ctx, src)
+	if err != nil {
+		return err
+	}
+	if err := c.prepareWhole(ctx, dst); err != nil {
+		return err
+	}
+	if m == nil {
+		return c.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+	}
+
+	return c.copyChunks(ctx, src, dst, m, func(src, dst string, manifest bool) error {
+		if manifest {
+			return c.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+		}
+		return c.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, nil)
+	})
+}
+
+func (c *chunked) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return c.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+// CopyFileWithOptionsWithContext - разбитый файл копируется по частям, opts применяются к манифесту,
+// частям - только TTL; без перезаписи (opts.Overwrite) существование dst проверяется
+// отдельным запросом, как в CreateFileWithOptions
+func (c *chunked) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	if opts.Overwrite != OverwriteAllow {
+		exists, err := c.exists(ctx, dst)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return opts.Overwrite.result(fmt.Errorf("%w: %s", ErrAlreadyExists, dst))
+		}
+	}
+
+	m, err := c.manifest(ctx, src)
+	if err != nil {
+		return err
+	}
+	if err := c.prepareWhole(ctx, dst); err != nil {
+		return err
+	}
+	if m == nil {
+		return c.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+	}
+
+	chunkOpts := PutOptions{TTL: opts.TTL}
+	opts.Overwrite = OverwriteAllow
+	return c.copyChunks(ctx, src, dst, m, func(src, dst string, manifest bool) error {
+		if manifest {
+			return c.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+		}
+		return c.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, chunkOpts)
+	})
+}
+
+// copyChunks - копирует части и манифест разбитого файла src в dst функцией copyOne;
+// манифест копируется последним, поэтому до конца копирования dst не считается разбитым файлом
+func (c *chunked) copyChunks(ctx context.Context, src, dst string, m *chunkManifest, copyOne func(src, dst string, manifest bool) error) error {
+	if err := c.StoreIFace.RemoveFileWithContext(ctx, dst); err != nil && !errors.Is(err, ErrFileNotFound) {
+		return err
+	}
+	if c.StoreIFace.Backend() != S3Store {
+		if err := c.StoreIFace.MkdirAllWithContext(ctx, dst); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < m.Chunks; i++ {
+		if err := copyOne(chunkPath(src, i), chunkPath(dst, i), false); err != nil {
+			return err
+		}
+	}
+	return copyOne(manifestPath(src), manifestPath(dst), true)
+}
+
+func (c *chunked) ExtractRange(src string, offset, length int64, dst string) error {
+	return c.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+// ExtractRangeWithContext - часть разбитого файла читается по частям и пишется как поток,
+// то есть тоже частями
+func (c *chunked) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	m, err := c.manifest(ctx, src)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		if err := c.prepareWhole(ctx, dst); err != nil {
+			return err
+		}
+		return c.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+	}
+
+	stream, err := c.reader(ctx, src, m, offset, length)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	_, err = c.writeChunks(ctx, stream, dst, PutOptions{})
+	return err
+}
+
+func (c *chunked) Truncate(path string, size int64) error {
+	return c.TruncateWithContext(context.Background(), path, size)
+}
+
+// TruncateWithContext - разбитый файл перезаписывается потоком и снова разбивается на части
+func (c *chunked) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return c.StoreIFace.TruncateWithContext(ctx, path, size)
+	}
+	return truncateRewrite(ctx, c, path, size)
+}
+
+func (c *chunked) CopyFileIfChanged(src, dst string) (bool, error) {
+	return c.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+func (c *chunked) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	return copyFileIfChanged(ctx, c, src, dst)
+}
+
+func (c *chunked) MoveFile(src, dst string) error {
+	return c.MoveFileWithContext(context.Background(), src, dst)
+}
+
+// MoveFileWithContext - разбитый файл копируется по частям, затем исходные части удаляются
+func (c *chunked) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	m, err := c.manifest(ctx, src)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		if err := c.prepareWhole(ctx, dst); err != nil {
+			return err
+		}
+		return c.StoreIFace.MoveFileWithContext(ctx, src, dst)
+	}
+
+	if err := c.CopyFileWithContext(ctx, src, dst, nil, nil); err != nil {
+		return err
+	}
+	return c.removeChunks(ctx, src, m)
+}
+
+func (c *chunked) MoveFileNoOverwrite(src, dst string) error {
+	return c.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
+}
+
+// MoveFileNoOverwriteWithContext - занятость dst, в том числе разбитым файлом, проверяется
+// отдельным запросом, поэтому для разбитых файлов проверка и перенос не атомарны
+func (c *chunked) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	m, err := c.manifest(ctx, src)
+	if err != nil {
+		return err
+	}
+	dstManifest, err := c.manifest(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if dstManifest != nil {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, dst)
+	}
+	if m == nil {
+		return c.StoreIFace.MoveFileNoOverwriteWithContext(ctx, src, dst)
+	}
+
+	if _, err := c.StoreIFace.StatLiteWithContext(ctx, dst); err == nil {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, dst)
+	}
+	return c.MoveFileWithContext(ctx, src, dst)
+}
+
+func (c *chunked) SwapFiles(a, b string) error {
+	return c.SwapFilesWithContext(context.Background(), a, b)
+}
+
+// SwapFilesWithContext - обмен разбитых файлов не поддерживается: части пришлось бы
+// переставлять по одной, и файл был бы собран из частей обоих
+func (c *chunked) SwapFilesWithContext(ctx context.Context, a, b string) error {
+	for _, path := range []string{a, b} {
+		m, err := c.manifest(ctx, path)
+		if err != nil {
+			return err
+		}
+		if m != nil {
+			return fmt.Errorf("swap chunked file %s: %w", path, errors.ErrUnsupported)
+		}
+	}
+	return c.StoreIFace.SwapFilesWithContext(ctx, a, b)
+}
+
+func (c *chunked) Rotate(path string) (string, error) {
+	return c.RotateWithContext(context.Background(), path)
+}
+
+// RotateWithContext - разбитый файл переносится по частям через MoveFileNoOverwrite
+func (c *chunked) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated := rotatedPath(path, time.Now())
+	if err := rotateTo(ctx, c, path, rotated); err != nil {
+		return "", err
+	}
+	return rotated, nil
+}
+
+func (c *chunked) CopyMeta(src, dst string) error {
+	return c.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+// CopyMetaWithContext - метаданные разбитого файла хранятся у манифеста
+func (c *chunked) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	src, err := c.metaPath(ctx, src)
+	if err != nil {
+		return err
+	}
+	dst, err = c.metaPath(ctx, dst)
+	if err != nil {
+		return err
+	}
+	return c.StoreIFace.CopyMetaWithContext(ctx, src, dst)
+}
+
+func (c *chunked) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	return c.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+// RemoveFileIfMatchWithContext - ETag разбитого файла - это ETag манифеста (см. StatObject):
+// манифест удаляется условно, а части - только после него
+func (c *chunked) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if m == nil {
+		return c.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+	}
+
+	removed, err := c.StoreIFace.RemoveFileIfMatchWithContext(ctx, manifestPath(path), etag)
+	if err != nil || !removed {
+		return removed, err
+	}
+	return true, c.removeChunks(ctx, path, m)
+}
+
+func (c *chunked) GetFileVerified(path string) ([]byte, error) {
+	return c.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+// GetFileVerifiedWithContext - разбитый файл собирается целиком и сверяется
+// с контрольной суммой из метаданных манифеста
+func (c *chunked) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return c.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+	}
+
+	_, meta, err := c.StoreIFace.StatWithContext(ctx, manifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	content, err := c.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(content, meta, ""); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (c *chunked) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	return c.GetFileIfModifiedSinceWithContext(context.Background(), path, t)
+}
+
+// GetFileIfModifiedSinceWithContext - время изменения разбитого файла - время записи манифеста
+func (c *chunked) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	if m == nil {
+		return c.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, t)
+	}
+
+	info, err := c.StoreIFace.StatLiteWithContext(ctx, manifestPath(path))
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.ModTime().After(t) {
+		return nil, false, nil
+	}
+	content, err := c.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+func (c *chunked) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return c.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+// FileWriterWithContext - размер записи заранее не известен, поэтому она, как StreamToFile,
+// всегда пишется частями; метаданные назначаются манифесту, ошибка записи возвращается при Close
+func (c *chunked) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return newPipeWriter(func(r io.Reader) error {
+		_, err := c.writeChunks(ctx, r, path, PutOptions{TTL: ttl, Meta: meta})
+		return err
+	}), nil
+}
+
+func (c *chunked) Reserve(path string) error {
+	return c.ReserveWithContext(context.Background(), path)
+}
+
+// ReserveWithContext - разбитый файл тоже занимает путь; его наличие проверяется
+// отдельным запросом, поэтому для разбитых файлов проверка и создание не атомарны
+func (c *chunked) ReserveWithContext(ctx context.Context, path string) error {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return err
+	}
+	if m != nil {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, path)
+	}
+	return c.StoreIFace.ReserveWithContext(ctx, path)
+}
+
+// chunkInfo - информация о разбитом файле path с именем name, nil - файл не разбит
+func (c *chunked) chunkInfo(ctx context.Context, path, name string) (os.FileInfo, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil || m == nil {
+		return nil, err
+	}
+	info, err := c.StoreIFace.StatLiteWithContext(ctx, manifestPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return chunkFileInfo{FileInfo: info, name: name, size: m.Size}, nil
+}
+
+func (c *chunked) ListDirChan(path string) <-chan DirEntry {
+	return c.ListDirChanWithContext(context.Background(), path)
+}
+
+// ListDirChanWithContext - директория разбитого файла отдается как файл с общим размером
+// и временем изменения манифеста; каждая поддиректория требует лишнего запроса за манифестом
+func (c *chunked) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		// останавливает листинг, если читатель перестал читать канал
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		for entry := range c.StoreIFace.ListDirChanWithContext(ctx, path) {
+			if entry.Err == nil && entry.Info.IsDir() {
+				info, err := c.chunkInfo(ctx, joinKey(path, entry.Info.Name()), entry.Info.Name())
+				if err != nil {
+					sendDirEntry(ctx, ch, DirEntry{Err: err})
+					return
+				}
+				if info != nil {
+					entry.Info = info
+				}
+			}
+			if !sendDirEntry(ctx, ch, entry) || entry.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (c *chunked) Latest(path string) (os.FileInfo, error) {
+	return c.LatestWithContext(context.Background(), path)
+}
+
+// LatestWithContext - разбитые файлы участвуют наравне с целыми
+func (c *chunked) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var latest os.FileInfo
+	for entry := range c.ListDirChanWithContext(ctx, path) {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		if entry.Info.IsDir() {
+			continue
+		}
+		if latest == nil || entry.Info.ModTime().After(latest.ModTime()) {
+			latest = entry.Info
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, ErrFileNotFound
+	}
+	return latest, nil
+}
+
+func (c *chunked) IsEmpty(path string) (bool, error) {
+	return c.IsEmptyWithContext(context.Background(), path)
+}
+
+// IsEmptyWithContext - разбитый файл - не директория, хотя хранится как директория частей
+func (c *chunked) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	m, err := c.manifest(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if m != nil {
+		return false, fmt.Errorf("%w: %s", ErrIsNotDir, path)
+	}
+	return c.StoreIFace.IsEmptyWithContext(ctx, path)
+}
+
+func (c *chunked) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return c.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+func (c *chunked) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	return listModifiedSince(ctx, c, path, since)
+}
+
+func (c *chunked) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return c.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
+
+func (c *chunked) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	return listDirDepth(ctx, c, path, maxDepth)
+}
+
+func (c *chunked) ListMeta(path string) (map[string]map[string]string, error) {
+	return c.ListMetaWithContext(context.Background(), path)
+}
+
+// ListMetaWithContext - метаданные разбитого файла берутся у манифеста (см. Stat)
+func (c *chunked) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	return listMeta(ctx, c, path)
+}
+
+func (c *chunked) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return c.ManifestWithContext(context.Background(), path, algo)
+}
+
+// ManifestWithContext - разбитый файл хешируется целиком, собранным из частей
+func (c *chunked) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return manifest(ctx, c, path, algo)
+}
+
+func (c *chunked) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return c.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+// ArchiveDirWithContext - разбитый файл попадает в архив одной записью
+func (c *chunked) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	return archiveDir(ctx, c, path, w, format)
+}
+
+func (c *chunked) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return c.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - файлы пишутся как StreamToFile, то есть частями;
+// время изменения из архива не сохраняется: у разбитого файла это время записи манифеста
+func (c *chunked) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, c, r, path, format, extractTarget{mkdir: c.StoreIFace.Backend() != S3Store})
+}
@@ -0,0 +1,446 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testChunkSize = 10
+
+// newTestChunked - разбиение на части по testChunkSize байт поверх Local в t.TempDir()
+func newTestChunked(t *testing.T) (StoreIFace, string) {
+	t.Helper()
+	return WithChunking(newTestLocal(t, LocalConfig{}), testChunkSize), t.TempDir()
+}
+
+func TestChunkingRoundTrip(t *testing.T) {
+	write := map[string]func(s StoreIFace, path string, data []byte) error{
+		"CreateFile": func(s StoreIFace, path string, data []byte) error {
+			return s.CreateFile(path, data, nil, nil)
+		},
+		"StreamToFile": func(s StoreIFace, path string, data []byte) error {
+			return s.StreamToFile(bytes.NewReader(data), path, nil)
+		},
+		"FileWriter": func(s StoreIFace, path string, data []byte) error {
+			w, err := s.FileWriter(path, nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				w.Close()
+				return err
+			}
+			return w.Close()
+		},
+	}
+
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{"one chunk", 7, 1},
+		{"exact chunks", 2 * testChunkSize, 2},
+		{"several chunks", 3*testChunkSize + 5, 4},
+	}
+
+	for _, tt := range tests {
+		for method, call := range write {
+			t.Run(tt.name+"/"+method, func(t *testing.T) {
+				s, dir := newTestChunked(t)
+				path := filepath.Join(dir, "a.bin")
+				data := make([]byte, tt.size)
+				for i := range data {
+					data[i] = byte('a' + i%26)
+				}
+
+				if err := call(s, path, data); err != nil {
+					t.Fatalf("%s: %v", method, err)
+				}
+
+				got, err := s.GetFile(path)
+				if err != nil || !bytes.Equal(got, data) {
+					t.Fatalf("GetFile = %q, %v; want %q", got, err, data)
+				}
+				if info, err := s.StatLite(path); err != nil || info.Size() != int64(tt.size) {
+					t.Errorf("StatLite size = %v, %v; want %d", info, err, tt.size)
+				}
+				// CreateFile не разбивает файл не больше части
+				if method == "CreateFile" && tt.size <= testChunkSize {
+					return
+				}
+				if _, err := os.Stat(manifestPath(path)); err != nil {
+					t.Errorf("manifest: %v", err)
+				}
+				if _, err := os.Stat(chunkPath(path, tt.chunks-1)); err != nil {
+					t.Errorf("last chunk %d: %v", tt.chunks-1, err)
+				}
+				if _, err := os.Stat(chunkPath(path, tt.chunks)); !os.IsNotExist(err) {
+					t.Errorf("unexpected chunk %d: %v", tt.chunks, err)
+				}
+			})
+		}
+	}
+}
+
+func TestChunkingRangedRead(t *testing.T) {
+	s, dir := newTestChunked(t)
+	path := filepath.Join(dir, "a.txt")
+	data := []byte("0123456789abcdefghijABCDEFGHIJxyz")
+	if err := s.CreateFile(path, data, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           string
+		wantErr        error
+	}{
+		{"inside one chunk", 2, 5, "23456", nil},
+		{"across a boundary", 8, 5, "89abc", nil},
+		{"across two boundaries", 5, 20, "56789abcdefghijABCDE", nil},
+		{"to the end", 28, 0, "IJxyz", nil},
+		{"past the end", 40, 1, "", ErrRangeNotSatisfiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetFilePartially(path, tt.offset, tt.length)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFilePartially error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("GetFilePartially = %q, want %q", got, tt.want)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			r, err := s.FileReader(path, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("FileReader: %v", err)
+			}
+			defer r.Close()
+			if got, err := io.ReadAll(r); err != nil || string(got) != tt.want {
+				t.Errorf("FileReader = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkingManifestAware(t *testing.T) {
+	data := []byte("0123456789abcdefghijABCDEFGHIJxyz")
+	sum := sha256.Sum256(data)
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s StoreIFace, src, other string)
+	}{
+		{"GetFileVerified", func(t *testing.T, s StoreIFace, src, other string) {
+			if got, err := s.GetFileVerified(src); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("GetFileVerified = %q, %v", got, err)
+			}
+			bad := map[string]string{MetaSHA256: hex.EncodeToString(make([]byte, 32))}
+			if err := s.CreateFile(other, data, nil, bad); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.GetFileVerified(other); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("GetFileVerified with a wrong sum = %v, want ErrChecksumMismatch", err)
+			}
+		}},
+		{"GetFileIfModifiedSince", func(t *testing.T, s StoreIFace, src, other string) {
+			if got, ok, err := s.GetFileIfModifiedSince(src, time.Time{}); err != nil || !ok || !bytes.Equal(got, data) {
+				t.Errorf("modified since zero = %q, %v, %v", got, ok, err)
+			}
+			if _, ok, err := s.GetFileIfModifiedSince(src, time.Now().Add(time.Hour)); err != nil || ok {
+				t.Errorf("modified since the future = %v, %v; want false", ok, err)
+			}
+		}},
+		{"RemoveFileIfMatch", func(t *testing.T, s StoreIFace, src, other string) {
+			if removed, err := s.RemoveFileIfMatch(src, "stale"); !errors.Is(err, ErrConflict) || removed {
+				t.Fatalf("RemoveFileIfMatch with a stale etag = %v, %v; want ErrConflict", removed, err)
+			}
+			obj, err := s.StatObject(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed, err := s.RemoveFileIfMatch(src, obj.ETag); err != nil || !removed {
+				t.Fatalf("RemoveFileIfMatch = %v, %v", removed, err)
+			}
+			if _, err := os.Stat(src); !os.IsNotExist(err) {
+				t.Errorf("chunks left after RemoveFileIfMatch: %v", err)
+			}
+		}},
+		{"CopyFileWithOptions", func(t *testing.T, s StoreIFace, src, other string) {
+			if err := s.CopyFileWithOptions(src, other, PutOptions{Meta: map[string]string{"k": "v"}}); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := s.GetFile(other); !bytes.Equal(got, data) {
+				t.Errorf("copy = %q", got)
+			}
+			if _, meta, _ := s.Stat(other); meta["k"] != "v" {
+				t.Errorf("copy meta = %v", meta)
+			}
+			err := s.CopyFileWithOptions(src, other, PutOptions{Overwrite: OverwriteFail})
+			if !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("copy onto a chunked file with OverwriteFail = %v, want ErrAlreadyExists", err)
+			}
+		}},
+		{"MoveFileNoOverwrite", func(t *testing.T, s StoreIFace, src, other string) {
+			if err := s.CreateFile(other, []byte("x"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.MoveFileNoOverwrite(src, other); !errors.Is(err, ErrAlreadyExists) {
+				t.Fatalf("move onto an existing file = %v, want ErrAlreadyExists", err)
+			}
+			if err := s.MoveFileNoOverwrite(other, src); !errors.Is(err, ErrAlreadyExists) {
+				t.Fatalf("move onto a chunked file = %v, want ErrAlreadyExists", err)
+			}
+			dst := other + ".moved"
+			if err := s.MoveFileNoOverwrite(src, dst); err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := s.GetFile(dst); !bytes.Equal(got, data) {
+				t.Errorf("moved file = %q", got)
+			}
+		}},
+		{"SwapFiles", func(t *testing.T, s StoreIFace, src, other string) {
+			if err := s.CreateFile(other, []byte("x"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SwapFiles(src, other); !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("SwapFiles = %v, want errors.ErrUnsupported", err)
+			}
+		}},
+		{"Rotate", func(t *testing.T, s StoreIFace, src, other string) {
+			rotated, err := s.Rotate(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := s.GetFile(rotated); !bytes.Equal(got, data) {
+				t.Errorf("rotated file = %q", got)
+			}
+			if info, err := s.StatLite(src); err != nil || info.Size() != 0 {
+				t.Errorf("active file after Rotate = %v, %v; want empty", info, err)
+			}
+		}},
+		{"CopyMeta", func(t *testing.T, s StoreIFace, src, other string) {
+			if err := s.CreateFile(other, data, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CopyMeta(src, other); err != nil {
+				t.Fatal(err)
+			}
+			_, meta, err := s.Stat(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string]string{MetaSHA256: hex.EncodeToString(sum[:])}; !reflect.DeepEqual(meta, want) {
+				t.Errorf("meta = %v, want %v", meta, want)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestChunked(t)
+			src := filepath.Join(dir, "src.txt")
+			meta := map[string]string{MetaSHA256: hex.EncodeToString(sum[:])}
+			if err := s.CreateFile(src, data, nil, meta); err != nil {
+				t.Fatal(err)
+			}
+			tt.run(t, s, src, filepath.Join(dir, "other.txt"))
+		})
+	}
+}
+
+// newChunkedTree - разбитые big.bin и sub/deep.bin и целый small.txt в директории dir
+func newChunkedTree(t *testing.T, s StoreIFace, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{
+		"big.bin":      []byte("0123456789abcdefghijABCDEFGHIJxyz"),
+		"small.txt":    []byte("small"),
+		"sub/deep.bin": []byte("deep file split into three chunks"),
+	}
+	if err := s.MkdirAll(filepath.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := s.CreateFile(filepath.Join(dir, name), data, nil, map[string]string{"name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func TestChunkingListing(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s StoreIFace, dir string, files map[string][]byte)
+	}{
+		{"ListDirChan", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			got := map[string]int64{}
+			for entry := range s.ListDirChan(dir) {
+				if entry.Err != nil {
+					t.Fatal(entry.Err)
+				}
+				if entry.Info.IsDir() {
+					got[entry.Info.Name()+"/"] = 0
+					continue
+				}
+				got[entry.Info.Name()] = entry.Info.Size()
+			}
+			want := map[string]int64{"big.bin": 33, "small.txt": 5, "sub/": 0}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListDirChan = %v, want %v", got, want)
+			}
+		}},
+		{"ListDirDepth", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			infos, err := s.ListDirDepth(dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int64{}
+			for _, info := range infos {
+				got[info.Name()] = info.Size()
+			}
+			for name, data := range files {
+				if size, ok := got[name]; !ok || size != int64(len(data)) {
+					t.Errorf("%s: size %d, listed %v; want %d", name, size, ok, len(data))
+				}
+			}
+			for name := range got {
+				if filepath.Base(name) == chunkManifestName || filepath.Base(name) == "000" {
+					t.Errorf("chunk %s is listed", name)
+				}
+			}
+		}},
+		{"ListModifiedSince", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			infos, err := s.ListModifiedSince(dir, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]int64{}
+			for _, info := range infos {
+				got[info.Name()] = info.Size()
+			}
+			want := map[string]int64{"big.bin": 33, "small.txt": 5, "sub/deep.bin": 33}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListModifiedSince = %v, want %v", got, want)
+			}
+		}},
+		{"ListMeta", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			got, err := s.ListMeta(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]map[string]string{}
+			for name := range files {
+				want[name] = map[string]string{"name": name}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListMeta = %v, want %v", got, want)
+			}
+		}},
+		{"Latest", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			future := time.Now().Add(time.Hour)
+			if err := os.Chtimes(manifestPath(filepath.Join(dir, "big.bin")), future, future); err != nil {
+				t.Fatal(err)
+			}
+			info, err := s.Latest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Name() != "big.bin" || info.Size() != 33 || info.IsDir() {
+				t.Errorf("Latest = %s (%d bytes, dir %v), want the chunked big.bin", info.Name(), info.Size(), info.IsDir())
+			}
+		}},
+		{"Manifest", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			entries, err := s.Manifest(dir, ChecksumSHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []ManifestEntry
+			for _, name := range []string{"big.bin", "small.txt", "sub/deep.bin"} {
+				sum := sha256.Sum256(files[name])
+				want = append(want, ManifestEntry{Path: name, Size: int64(len(files[name])), Checksum: hex.EncodeToString(sum[:])})
+			}
+			if !reflect.DeepEqual(entries, want) {
+				t.Errorf("Manifest = %v, want %v", entries, want)
+			}
+		}},
+		{"IsEmpty", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			if _, err := s.IsEmpty(filepath.Join(dir, "big.bin")); !errors.Is(err, ErrIsNotDir) {
+				t.Errorf("IsEmpty of a chunked file = %v, want ErrIsNotDir", err)
+			}
+			if empty, err := s.IsEmpty(dir); err != nil || empty {
+				t.Errorf("IsEmpty = %v, %v; want false", empty, err)
+			}
+		}},
+		{"Reserve", func(t *testing.T, s StoreIFace, dir string, files map[string][]byte) {
+			if err := s.Reserve(filepath.Join(dir, "big.bin")); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("Reserve of a chunked file = %v, want ErrAlreadyExists", err)
+			}
+			if got, _ := s.GetFile(filepath.Join(dir, "big.bin")); !bytes.Equal(got, files["big.bin"]) {
+				t.Errorf("chunked file after Reserve = %q", got)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestChunked(t)
+			files := newChunkedTree(t, s, dir)
+			tt.run(t, s, dir, files)
+		})
+	}
+}
+
+func TestChunkingArchive(t *testing.T) {
+	for _, f := range []struct {
+		name   string
+		format ArchiveFormat
+	}{{"tar", ArchiveTar}, {"zip", ArchiveZip}} {
+		t.Run(f.name, func(t *testing.T) {
+			s, dir := newTestChunked(t)
+			files := newChunkedTree(t, s, filepath.Join(dir, "src"))
+
+			var buf bytes.Buffer
+			if err := s.ArchiveDir(filepath.Join(dir, "src"), &buf, f.format); err != nil {
+				t.Fatalf("ArchiveDir: %v", err)
+			}
+
+			// архив содержит файлы целиком, без частей и манифестов
+			plain := filepath.Join(t.TempDir(), "plain")
+			if err := newTestLocal(t, LocalConfig{}).ExtractArchive(bytes.NewReader(buf.Bytes()), plain, f.format); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := listTree(t, plain), []string{"big.bin", "small.txt", "sub", "sub/deep.bin"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("archive entries = %v, want %v", got, want)
+			}
+
+			dest := filepath.Join(dir, "dest")
+			if err := s.ExtractArchive(bytes.NewReader(buf.Bytes()), dest, f.format); err != nil {
+				t.Fatalf("ExtractArchive: %v", err)
+			}
+			for name, data := range files {
+				if got, err := s.GetFile(filepath.Join(dest, name)); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("%s = %q, %v; want %q", name, got, err, data)
+				}
+			}
+			// распакованный большой файл записан частями
+			if _, err := os.Stat(manifestPath(filepath.Join(dest, "big.bin"))); err != nil {
+				t.Errorf("extracted big.bin is not chunked: %v", err)
+			}
+		})
+	}
+}
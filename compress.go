@@ -0,0 +1,110 @@
+package store
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	pathpkg "path"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// decoderReadCloser - распаковывает поток, Close освобождает распаковщик и закрывает исходный поток
+type decoderReadCloser struct {
+	io.Reader
+	closeDecoder func() error
+	body         io.ReadCloser
+}
+
+// newDecoder - распаковщик body для Content-Encoding encoding
+func newDecoder(body io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		return &decoderReadCloser{Reader: zr, closeDecoder: zr.Close, body: body}, nil
+	case "br":
+		return &decoderReadCloser{Reader: brotli.NewReader(body), body: body}, nil
+	case "zstd":
+		zr, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		return &decoderReadCloser{Reader: zr, closeDecoder: func() error { zr.Close(); return nil }, body: body}, nil
+	default:
+		return body, nil
+	}
+}
+
+func (d *decoderReadCloser) Close() error {
+	var zerr error
+	if d.closeDecoder != nil {
+		zerr = d.closeDecoder()
+	}
+	if err := d.body.Close(); err != nil {
+		return err
+	}
+	return zerr
+}
+
+// DecompressReader - поток распакованного содержимого файла
+// s - хранилище
+// path - путь к файлу
+// Сжатие определяется по расширению (.gz, .br, .zst), а без него - по Content-Encoding
+// объекта (S3) или метаданным Content-Encoding. Файл без сжатия или с неизвестным
+// сжатием отдается как есть
+func DecompressReader(s StoreIFace, path string) (io.ReadCloser, error) {
+	return DecompressReaderWithContext(context.Background(), s, path)
+}
+
+// DecompressReaderWithContext - поток распакованного содержимого файла
+// s - хранилище
+// path - путь к файлу
+func DecompressReaderWithContext(ctx context.Context, s StoreIFace, path string) (io.ReadCloser, error) {
+	encoding := encodingByExt(path)
+	if encoding == "" {
+		obj, err := s.StatObjectWithContext(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		encoding = obj.ContentEncoding
+		if encoding == "" {
+			for k, v := range obj.Meta {
+				if strings.EqualFold(k, "Content-Encoding") {
+					encoding = v
+					break
+				}
+			}
+		}
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+	}
+
+	stream, err := s.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrFileNotFound
+	}
+
+	return newDecoder(stream, encoding)
+}
+
+// encodingByExt - Content-Encoding по расширению файла, "" - расширение не говорит о сжатии
+func encodingByExt(path string) string {
+	switch strings.ToLower(pathpkg.Ext(path)) {
+	case ".gz":
+		return "gzip"
+	case ".br":
+		return "br"
+	case ".zst":
+		return "zstd"
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestDecompressReader(t *testing.T) {
+	content := bytes.Repeat([]byte("compressible content "), 100)
+
+	gzipped := func(t *testing.T) []byte {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write(content)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	brotlied := func(t *testing.T) []byte {
+		var buf bytes.Buffer
+		w := brotli.NewWriter(&buf)
+		w.Write(content)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	zstded := func(t *testing.T) []byte {
+		w, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+		return w.EncodeAll(content, nil)
+	}
+	plain := func(t *testing.T) []byte { return content }
+
+	tests := []struct {
+		name   string
+		file   string
+		encode func(t *testing.T) []byte
+		meta   map[string]string
+	}{
+		{"gzip by extension", "a.txt.gz", gzipped, nil},
+		{"brotli by extension", "a.txt.br", brotlied, nil},
+		{"zstd by extension", "a.txt.zst", zstded, nil},
+		{"gzip by meta", "a.bin", gzipped, map[string]string{"Content-Encoding": "gzip"}},
+		{"brotli by meta", "a.bin", brotlied, map[string]string{"Content-Encoding": "br"}},
+		{"zstd by meta", "a.bin", zstded, map[string]string{"content-encoding": "ZSTD"}},
+		{"uncompressed", "a.txt", plain, nil},
+		{"unknown encoding", "a.bin", plain, map[string]string{"Content-Encoding": "identity"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			s := newTestLocal(t, LocalConfig{})
+			if err := s.CreateFile(path, tt.encode(t), nil, tt.meta); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := DecompressReader(s, path)
+			if err != nil {
+				t.Fatalf("DecompressReader: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if err := r.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("decompressed %d bytes differing from the original %d", len(got), len(content))
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		s := newTestLocal(t, LocalConfig{})
+		if _, err := DecompressReader(s, filepath.Join(t.TempDir(), "a.gz")); err == nil {
+			t.Error("DecompressReader of a missing file succeeded")
+		}
+	})
+}
+
+func TestS3GzipRead(t *testing.T) {
+	content := bytes.Repeat([]byte("legacy log line\n"), 200)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(content)
+	zw.Close()
+	gzipped := buf.Bytes()
+
+	// объекты: сжатый с Content-Encoding, сжатый архив без него и обычный
+	newStore := func(t *testing.T, cfg S3Config) *S3 {
+		s, f := newFakeS3(t, cfg)
+		f.put("encoded.log", gzipped, http.Header{"Content-Encoding": {"gzip"}})
+		f.put("archive.gz", gzipped, http.Header{"Content-Type": {"application/gzip"}})
+		f.put("plain.log", content, nil)
+		return s
+	}
+
+	t.Run("GetFile", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			decompress bool
+			path       string
+			want       []byte
+		}{
+			{"gzip encoding decompressed", true, "encoded.log", content},
+			{"gzip archive kept", true, "archive.gz", gzipped},
+			{"plain object", true, "plain.log", content},
+			{"gzip encoding without the flag", false, "encoded.log", gzipped},
+			{"plain object without the flag", false, "plain.log", content},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				s := newStore(t, S3Config{AutoDecompress: tt.decompress})
+				if got, err := s.GetFile(tt.path); err != nil || !bytes.Equal(got, tt.want) {
+					t.Errorf("GetFile(%s) = %d bytes, %v; want %d bytes", tt.path, len(got), err, len(tt.want))
+				}
+			})
+		}
+
+		s := newStore(t, S3Config{AutoDecompress: true})
+		if _, err := s.GetFile("missing.log"); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("GetFile(missing.log) = %v, want ErrFileNotFound", err)
+		}
+	})
+
+	t.Run("GzipReader", func(t *testing.T) {
+		s := newStore(t, S3Config{})
+		for _, path := range []string{"encoded.log", "archive.gz"} {
+			r, err := s.GzipReader(path)
+			if err != nil {
+				t.Fatalf("GzipReader(%s): %v", path, err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("GzipReader(%s) = %d bytes, %v; want the decompressed %d", path, len(got), err, len(content))
+			}
+		}
+
+		if _, err := s.GzipReader("plain.log"); !errors.Is(err, gzip.ErrHeader) {
+			t.Errorf("GzipReader(plain.log) = %v, want gzip.ErrHeader", err)
+		}
+		if _, err := s.GzipReader("missing.log"); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("GzipReader(missing.log) = %v, want ErrFileNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"mime"
+	"net/http"
+	pathpkg "path"
+)
+
+// ContentTypeResolver - определяет Content-Type файла, записанного без явного типа
+// (PutOptions.ContentType)
+// path - путь к файлу
+// content - содержимое файла
+type ContentTypeResolver func(path string, content []byte) string
+
+// ContentTypeByExtension - Content-Type по расширению файла (mime.TypeByExtension),
+// для файла без известного расширения - по содержимому; используется по умолчанию
+func ContentTypeByExtension(path string, content []byte) string {
+	if contentType := mime.TypeByExtension(pathpkg.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return ContentTypeSniff(path, content)
+}
+
+// ContentTypeSniff - Content-Type по первым 512 байтам содержимого (http.DetectContentType);
+// SVG, JSON, CSS и т.п. при этом определяются как text/plain
+func ContentTypeSniff(path string, content []byte) string {
+	return http.DetectContentType(content)
+}
+
+// metaContentType - ключ мета-файла, под которым Local и WebDav хранят Content-Type
+// в метаданных, возвращаемых Stat, этого ключа нет
+const metaContentType = "Content-Type"
+
+// resolveContentType - Content-Type записываемого файла: явный или определенный resolve
+// resolve - nil означает ContentTypeByExtension
+func resolveContentType(explicit string, resolve ContentTypeResolver, path string, content []byte) string {
+	if explicit != "" {
+		return explicit
+	}
+	if resolve == nil {
+		resolve = ContentTypeByExtension
+	}
+	return resolve(path, content)
+}
+
+// withContentType - копия meta с Content-Type под служебным ключом
+// тип, который StatObject и так выведет из расширения, и application/octet-stream
+// файла без расширения не сохраняются, чтобы не заводить мета-файл ради них
+func withContentType(meta map[string]string, path, contentType string) map[string]string {
+	byExt := mime.TypeByExtension(pathpkg.Ext(path))
+	if contentType == "" || contentType == byExt ||
+		byExt == "" && contentType == "application/octet-stream" {
+		return meta
+	}
+
+	m := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		m[k] = v
+	}
+	m[metaContentType] = contentType
+	return m
+}
+
+// sidecarContentType - Content-Type из мета-файла, а без него - по расширению
+func sidecarContentType(meta map[string]string, path string) string {
+	if contentType := meta[metaContentType]; contentType != "" {
+		return contentType
+	}
+	return mime.TypeByExtension(pathpkg.Ext(path))
+}
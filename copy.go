@@ -0,0 +1,319 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Copy - копирует файл из одного хранилища в другое потоком
+// src - хранилище-источник
+// srcPath - путь к файлу в источнике
+// dst - хранилище-приемник
+// dstPath - путь к файлу в приемнике
+// ttl - время жизни
+func Copy(src StoreIFace, srcPath string, dst StoreIFace, dstPath string, ttl *time.Time) error {
+	return CopyWithContext(context.Background(), src, srcPath, dst, dstPath, ttl)
+}
+
+// CopyWithContext - копирует файл из одного хранилища в другое потоком
+// src - хранилище-источник
+// srcPath - путь к файлу в источнике
+// dst - хранилище-приемник
+// dstPath - путь к файлу в приемнике
+// ttl - время жизни
+func CopyWithContext(ctx context.Context, src StoreIFace, srcPath string, dst StoreIFace, dstPath string, ttl *time.Time) error {
+	stream, err := openSource(ctx, src, srcPath)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return dst.StreamToFileWithContext(ctx, stream, dstPath, ttl)
+}
+
+// openSource - открывает файл-источник копирования целиком
+// Local не открывает пустые файлы, такой файл читается как пустой поток
+func openSource(ctx context.Context, s StoreIFace, path string) (io.ReadCloser, error) {
+	stream, err := s.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil || stream != nil {
+		return stream, err
+	}
+	if info, _, err := s.StatWithContext(ctx, path); err == nil && info != nil && info.Size() == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return nil, ErrFileNotFound
+}
+
+// CopyVerifiedOptions - параметры CopyVerified
+// DeleteOnMismatch - удалить копию, не совпавшую с источником
+type CopyVerifiedOptions struct {
+	DeleteOnMismatch bool
+}
+
+// CopyVerified - копирует файл из одного хранилища в другое потоком и сверяет копию с источником
+// src - хранилище-источник
+// srcPath - путь к файлу в источнике
+// dst - хранилище-приемник
+// dstPath - путь к файлу в приемнике
+// ttl - время жизни
+// opts - параметры
+// контрольная сумма источника считается во время копирования, без отдельного чтения;
+// копия в S3 сверяется по ETag (md5 содержимого или ETag multipart загрузки частями
+// по S3PartSize), если он не совпал - копия читается заново.
+// При расхождении возвращается ErrChecksumMismatch
+func CopyVerified(src StoreIFace, srcPath string, dst StoreIFace, dstPath string, ttl *time.Time, opts CopyVerifiedOptions) error {
+	return CopyVerifiedWithContext(context.Background(), src, srcPath, dst, dstPath, ttl, opts)
+}
+
+// CopyVerifiedWithContext - копирует файл из одного хранилища в другое потоком и сверяет копию с источником
+// src - хранилище-источник
+// srcPath - путь к файлу в источнике
+// dst - хранилище-приемник
+// dstPath - путь к файлу в приемнике
+// ttl - время жизни
+// opts - параметры
+func CopyVerifiedWithContext(ctx context.Context, src StoreIFace, srcPath string, dst StoreIFace, dstPath string, ttl *time.Time, opts CopyVerifiedOptions) error {
+	stream, err := openSource(ctx, src, srcPath)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	md5Sum, sha256Sum, parts := md5.New(), sha256.New(), newS3PartsHash(S3PartSize)
+	if err := dst.StreamToFileWithContext(ctx, io.TeeReader(stream, io.MultiWriter(md5Sum, sha256Sum, parts)), dstPath, ttl); err != nil {
+		return err
+	}
+
+	etags := []string{hex.EncodeToString(md5Sum.Sum(nil)), parts.etag()}
+	match, err := copiedContentMatches(ctx, dst, dstPath, etags, sha256Sum.Sum(nil))
+	if err != nil {
+		return err
+	}
+	if match {
+		return nil
+	}
+
+	mismatch := fmt.Errorf("%w: %s -> %s", ErrChecksumMismatch, srcPath, dstPath)
+	if opts.DeleteOnMismatch {
+		if err := dst.RemoveFileWithContext(ctx, dstPath); err != nil {
+			return errors.Join(mismatch, err)
+		}
+	}
+	return mismatch
+}
+
+// copiedContentMatches - совпадает ли копия с контрольными суммами источника
+// etags - ETag, которые S3 дал бы содержимому источника
+// ETag S3 совпадает с ними не всегда (SSE-KMS, обертки над S3), поэтому
+// по нему копия только подтверждается, а при несовпадении читается заново
+func copiedContentMatches(ctx context.Context, dst StoreIFace, dstPath string, etags []string, sha256Sum []byte) (bool, error) {
+	if dst.Backend() == S3Store {
+		obj, err := dst.StatObjectWithContext(ctx, dstPath)
+		if err != nil {
+			return false, err
+		}
+		for _, etag := range etags {
+			if strings.EqualFold(obj.ETag, etag) {
+				return true, nil
+			}
+		}
+	}
+
+	dstSum, err := syncSHA256(ctx, dst, dstPath)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dstSum, sha256Sum), nil
+}
+
+// CopyResume - копирует файл из одного хранилища в другое потоком с докачкой
+// src - хранилище-источник
+// srcPath - путь к файлу в источнике
+// dst - хранилище-приемник
+// dstPath - путь к файлу в приемнике
+// ttl - время жизни
+// Если в приемнике уже есть файл меньше источника (прерванное копирование), источник
+// читается с его размера и остаток дописывается в конец. Дописывать умеет только Local,
+// поэтому докачка работает при копировании из любого хранилища в Local, в том числе
+// с ShardDepth и обернутый New (DefaultTTL, MaxGetSize, NormalizeKeys); в WebDav, S3 и
+// Local под другими декораторами (кеш, ограничение скорости и т.п.) файл копируется заново. После копирования sha256 источника и приемника сверяются;
+// если после докачки они не совпали, файл копируется заново целиком, а при повторном
+// расхождении возвращается ErrChecksumMismatch.
+// int64 - с какого смещения продолжено копирование, 0 - скопировано целиком
+func CopyResume(src StoreIFace, srcPath string, dst StoreIFace, dstPath string, ttl *time.Time) (int64, error) {
+	return CopyResumeWithContext(context.Background(), src, srcPath, dst, dstPath, ttl)
+}
+
+// CopyResumeWithContext - копирует файл из одного хранилища в другое потоком с докачкой
+// src - хранилище-источник
+// srcPath - путь к файлу в источнике
+// dst - хранилище-приемник
+// dstPath - путь к файлу в приемнике
+// ttl - время жизни
+func CopyResumeWithContext(ctx context.Context, src StoreIFace, srcPath string, dst StoreIFace, dstPath string, ttl *time.Time) (int64, error) {
+	srcInfo, err := src.StatLiteWithContext(ctx, srcPath)
+	if err != nil {
+		return 0, err
+	}
+
+	offset, err := resumeOffset(ctx, dst, dstPath, srcInfo.Size())
+	if err != nil {
+		return 0, err
+	}
+
+	if offset > 0 {
+		err = appendFrom(ctx, src, srcPath, dst, dstPath, offset)
+	} else {
+		err = CopyWithContext(ctx, src, srcPath, dst, dstPath, ttl)
+	}
+	if err != nil {
+		return offset, err
+	}
+
+	equal, err := sameContent(ctx, src, srcPath, dst, dstPath)
+	if err != nil || equal {
+		return offset, err
+	}
+	if offset == 0 {
+		return 0, ErrChecksumMismatch
+	}
+
+	// уже записанная часть отличается от источника
+	if err := CopyWithContext(ctx, src, srcPath, dst, dstPath, ttl); err != nil {
+		return 0, err
+	}
+	equal, err = sameContent(ctx, src, srcPath, dst, dstPath)
+	if err != nil {
+		return 0, err
+	}
+	if !equal {
+		return 0, ErrChecksumMismatch
+	}
+	return 0, nil
+}
+
+// appendable - хранилище, в котором CopyResume может дописать файл
+// appendTarget - Local и путь в нем для файла path; false - дописывать нельзя.
+// Реализуют Local и декораторы, которые не меняют содержимое и передают вызов хранилищу под собой
+type appendable interface {
+	appendTarget(path string) (*Local, string, bool)
+}
+
+// appendTarget - Local и путь в нем, куда дописывается файл path хранилища s
+func appendTarget(s StoreIFace, path string) (*Local, string, bool) {
+	a, ok := s.(appendable)
+	if !ok {
+		return nil, "", false
+	}
+	return a.appendTarget(path)
+}
+
+// resumeOffset - с какого смещения можно продолжить копирование в приемник, 0 - копировать заново
+func resumeOffset(ctx context.Context, dst StoreIFace, dstPath string, srcSize int64) (int64, error) {
+	if _, _, ok := appendTarget(dst, dstPath); !ok {
+		return 0, nil
+	}
+
+	dstInfo, err := dst.StatLiteWithContext(ctx, dstPath)
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if dstInfo.Size() >= srcSize {
+		return 0, nil
+	}
+	return dstInfo.Size(), nil
+}
+
+// appendFrom - дописывает в приемник содержимое источника начиная со смещения offset
+func appendFrom(ctx context.Context, src StoreIFace, srcPath string, dst StoreIFace, dstPath string, offset int64) error {
+	local, localPath, ok := appendTarget(dst, dstPath)
+	if !ok {
+		return fmt.Errorf("%s: append is not supported", dstPath)
+	}
+
+	stream, err := src.FileReaderWithContext(ctx, srcPath, offset, 0)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		return ErrFileNotFound
+	}
+	defer stream.Close()
+
+	return local.appendStream(stream, localPath)
+}
+
+// sameContent - совпадает ли sha256 файлов двух хранилищ
+func sameContent(ctx context.Context, src StoreIFace, srcPath string, dst StoreIFace, dstPath string) (bool, error) {
+	srcSum, err := syncSHA256(ctx, src, srcPath)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := syncSHA256(ctx, dst, dstPath)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(srcSum, dstSum), nil
+}
+
+// copyFileIfChanged - копирует src в dst того же хранилища, только если содержимое отличается
+// bool - выполнено ли копирование; отсутствующий dst копируется всегда
+func copyFileIfChanged(ctx context.Context, s StoreIFace, src, dst string) (bool, error) {
+	same, err := unchanged(ctx, s, src, dst)
+	if err != nil || same {
+		return false, err
+	}
+	if err := s.CopyFileWithContext(ctx, src, dst, nil, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// unchanged - совпадает ли содержимое двух файлов хранилища
+// сравниваются размеры, затем sha256 из метаданных (MetaSHA256), в S3 - ETag;
+// если этого недостаточно, оба файла читаются и сравнивается sha256 содержимого
+func unchanged(ctx context.Context, s StoreIFace, src, dst string) (bool, error) {
+	srcObj, err := s.StatObjectWithContext(ctx, src)
+	if err != nil {
+		return false, err
+	}
+	dstObj, err := s.StatObjectWithContext(ctx, dst)
+	if errors.Is(err, ErrFileNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if dstObj.Size != srcObj.Size {
+		return false, nil
+	}
+
+	if srcObj.Meta[MetaSHA256] != "" && dstObj.Meta[MetaSHA256] != "" {
+		return strings.EqualFold(srcObj.Meta[MetaSHA256], dstObj.Meta[MetaSHA256]), nil
+	}
+
+	// ETag Local и WebDav не зависит от содержимого, поэтому сравнивается только в S3:
+	// одинаковый ETag - одинаковое содержимое, разный доказывает различие,
+	// только если оба ETag - md5 содержимого (не multipart)
+	if s.Backend() == S3Store && srcObj.ETag != "" && dstObj.ETag != "" {
+		if strings.EqualFold(srcObj.ETag, dstObj.ETag) {
+			return true, nil
+		}
+		if !strings.Contains(srcObj.ETag, "-") && !strings.Contains(dstObj.ETag, "-") {
+			return false, nil
+		}
+	}
+
+	return sameContent(ctx, s, src, s, dst)
+}
@@ -0,0 +1,500 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var errInterrupted = errors.New("connection reset")
+
+// interruptingStore - хранилище-источник, которое считает отданные FileReader байты
+// и при failAfter > 0 обрывает поток после failAfter байт, как разорванное соединение
+type interruptingStore struct {
+	StoreIFace
+	failAfter int64
+	read      int64
+}
+
+func (s *interruptingStore) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	r, err := s.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	if err != nil || r == nil {
+		return r, err
+	}
+	return &interruptingReader{ReadCloser: r, s: s}, nil
+}
+
+type interruptingReader struct {
+	io.ReadCloser
+	s *interruptingStore
+}
+
+func (r *interruptingReader) Read(p []byte) (int, error) {
+	if r.s.failAfter > 0 {
+		left := r.s.failAfter - r.s.read
+		if left <= 0 {
+			return 0, errInterrupted
+		}
+		if int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.s.read += int64(n)
+	return n, err
+}
+
+func TestCopyResume(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 16<<10)
+	const interruptAt = 100 << 10
+
+	tests := []struct {
+		name string
+		// existing - что лежит в приемнике до CopyResume, nil - ничего
+		existing   []byte
+		wantOffset int64
+		// wantRead - сколько байт прочитано из источника, -1 - не проверяется
+		wantRead int64
+	}{
+		{"no destination", nil, 0, int64(len(data))},
+		{"interrupted copy", data[:interruptAt], interruptAt, int64(len(data)) - interruptAt},
+		{"destination is complete", data, 0, -1},
+		{"partial destination differs from source", bytes.Repeat([]byte("x"), interruptAt), 0, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			srcPath, dstPath := filepath.Join(dir, "src.bin"), filepath.Join(dir, "dst.bin")
+			local := newTestLocal(t, LocalConfig{})
+			if err := local.CreateFile(srcPath, data, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing != nil {
+				if err := os.WriteFile(dstPath, tt.existing, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			src := &interruptingStore{StoreIFace: local}
+			offset, err := CopyResume(src, srcPath, local, dstPath, nil)
+			if err != nil {
+				t.Fatalf("CopyResume: %v", err)
+			}
+			if offset != tt.wantOffset {
+				t.Errorf("offset = %d, want %d", offset, tt.wantOffset)
+			}
+			if tt.wantRead >= 0 && src.read != tt.wantRead {
+				t.Errorf("read %d bytes from the source, want %d", src.read, tt.wantRead)
+			}
+			if got, _ := os.ReadFile(dstPath); !bytes.Equal(got, data) {
+				t.Errorf("destination has %d bytes differing from the source", len(got))
+			}
+		})
+	}
+
+	t.Run("resume after interruption", func(t *testing.T) {
+		dir := t.TempDir()
+		srcPath, dstPath := filepath.Join(dir, "src.bin"), filepath.Join(dir, "dst.bin")
+		local := newTestLocal(t, LocalConfig{})
+		if err := local.CreateFile(srcPath, data, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		broken := &interruptingStore{StoreIFace: local, failAfter: interruptAt}
+		if _, err := CopyResume(broken, srcPath, local, dstPath, nil); !errors.Is(err, errInterrupted) {
+			t.Fatalf("interrupted CopyResume error = %v, want %v", err, errInterrupted)
+		}
+		info, err := os.Stat(dstPath)
+		if err != nil || info.Size() != interruptAt {
+			t.Fatalf("destination after interruption: %v, %v; want %d bytes", info, err, interruptAt)
+		}
+
+		src := &interruptingStore{StoreIFace: local}
+		offset, err := CopyResume(src, srcPath, local, dstPath, nil)
+		if err != nil {
+			t.Fatalf("resumed CopyResume: %v", err)
+		}
+		if offset != interruptAt {
+			t.Errorf("resumed from %d, want %d", offset, interruptAt)
+		}
+		if got, _ := os.ReadFile(dstPath); !bytes.Equal(got, data) {
+			t.Error("resumed copy differs from the source")
+		}
+	})
+}
+
+func TestCopyResumeWrappedLocal(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 16<<10)
+	const interruptAt = 100 << 10
+
+	tests := []struct {
+		name string
+		dst  func(t *testing.T) StoreIFace
+		// wantOffset - interruptAt, если приемник дописывается, 0 - копируется заново
+		wantOffset int64
+	}{
+		{"New with decorators", func(t *testing.T) StoreIFace {
+			s, err := New(Config{StoreType: LocalStore, SkipValidation: true, DefaultTTL: time.Hour, MaxGetSize: 1 << 10, NormalizeKeys: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}, interruptAt},
+		{"sharded", func(t *testing.T) StoreIFace {
+			s, err := NewLocal(LocalConfig{SkipValidation: true, ShardDepth: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}, interruptAt},
+		// кеш не узнал бы о дописанном файле, поэтому файл копируется заново
+		{"lru cache", func(t *testing.T) StoreIFace {
+			return WithLRUCache(newTestLocal(t, LocalConfig{}), 1<<20, 0)
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NormalizeKeys делает пути Local относительными рабочей директории
+			chdir(t, t.TempDir())
+			local := newTestLocal(t, LocalConfig{})
+			if err := local.CreateFile("src.bin", data, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			dst := tt.dst(t)
+			if err := dst.CreateFile("dst.bin", data[:interruptAt], nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			src := &interruptingStore{StoreIFace: local}
+			offset, err := CopyResume(src, "src.bin", dst, "dst.bin", nil)
+			if err != nil {
+				t.Fatalf("CopyResume: %v", err)
+			}
+			if offset != tt.wantOffset {
+				t.Errorf("offset = %d, want %d", offset, tt.wantOffset)
+			}
+			if want := int64(len(data)) - tt.wantOffset; src.read != want {
+				t.Errorf("read %d bytes from the source, want %d", src.read, want)
+			}
+			var got bytes.Buffer
+			if _, err := dst.WriteTo("dst.bin", &got); err != nil || !bytes.Equal(got.Bytes(), data) {
+				t.Errorf("destination has %d bytes differing from the source, %v", got.Len(), err)
+			}
+		})
+	}
+}
+
+func TestCopyFileIfChanged(t *testing.T) {
+	tests := []struct {
+		name string
+		// dst - содержимое приемника до копирования, "" - приемника нет
+		src, dst   string
+		wantCopied bool
+	}{
+		{"identical", "same body", "same body", false},
+		{"changed content of the same size", "new body", "old body", true},
+		{"changed size", "longer body", "body", true},
+		{"missing destination", "body", "", true},
+	}
+
+	for _, b := range testBackends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				s, dir := b.store(t)
+				src, dst := joinKey(dir, "src.txt"), joinKey(dir, "dst.txt")
+				if err := s.CreateFile(src, []byte(tt.src), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				if tt.dst != "" {
+					if err := s.CreateFile(dst, []byte(tt.dst), nil, nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+				// время изменения приемника показывает, перезаписывался ли он
+				var before time.Time
+				if tt.dst != "" {
+					info, _, err := s.Stat(dst)
+					if err != nil {
+						t.Fatal(err)
+					}
+					before = info.ModTime()
+					time.Sleep(10 * time.Millisecond)
+				}
+
+				copied, err := s.CopyFileIfChanged(src, dst)
+				if err != nil || copied != tt.wantCopied {
+					t.Fatalf("CopyFileIfChanged = %v, %v; want %v", copied, err, tt.wantCopied)
+				}
+				if got, err := s.GetFile(dst); err != nil || string(got) != tt.src {
+					t.Errorf("dst = %q, %v; want %q", got, err, tt.src)
+				}
+				if !tt.wantCopied && b.name == "local" {
+					if info, _, err := s.Stat(dst); err != nil || !info.ModTime().Equal(before) {
+						t.Errorf("dst modified after a skipped copy (%v), want untouched", err)
+					}
+				}
+			})
+		}
+
+		t.Run(b.name+"/missing source", func(t *testing.T) {
+			s, dir := b.store(t)
+			copied, err := s.CopyFileIfChanged(joinKey(dir, "missing.txt"), joinKey(dir, "dst.txt"))
+			if copied || !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("CopyFileIfChanged = %v, %v; want ErrFileNotFound", copied, err)
+			}
+		})
+	}
+}
+
+func TestS3CopyFileIfChangedComparesETag(t *testing.T) {
+	// copies - число CopyObject запросов
+	copies := func(f *fakeS3) int {
+		n := 0
+		for _, r := range f.requestsTo(http.MethodPut, "") {
+			if r.Header.Get("X-Amz-Copy-Source") != "" {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("equal md5 ETags skip without reading", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("src", []byte("body"), nil)
+		f.put("dst", []byte("body"), nil)
+
+		if copied, err := s.CopyFileIfChanged("src", "dst"); err != nil || copied {
+			t.Fatalf("CopyFileIfChanged = %v, %v; want a skip", copied, err)
+		}
+		if n := len(f.requestsTo(http.MethodGet, "")); n != 0 {
+			t.Errorf("%d GETs, want ETags to decide without reading", n)
+		}
+		if n := copies(f); n != 0 {
+			t.Errorf("%d copies, want none", n)
+		}
+	})
+
+	t.Run("different md5 ETags copy without reading", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("src", []byte("new!"), nil)
+		f.put("dst", []byte("old!"), nil)
+
+		if copied, err := s.CopyFileIfChanged("src", "dst"); err != nil || !copied {
+			t.Fatalf("CopyFileIfChanged = %v, %v; want a copy", copied, err)
+		}
+		if n := len(f.requestsTo(http.MethodGet, "")); n != 0 {
+			t.Errorf("%d GETs, want ETags to decide without reading", n)
+		}
+		if n := copies(f); n != 1 {
+			t.Errorf("%d copies, want 1", n)
+		}
+	})
+
+	t.Run("multipart ETag falls back to the content", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("src", []byte("body"), nil)
+		f.put("dst", []byte("body"), nil)
+		// ETag multipart загрузки - не md5 содержимого
+		f.object("src").etag = "0123456789abcdef0123456789abcdef-2"
+
+		if copied, err := s.CopyFileIfChanged("src", "dst"); err != nil || copied {
+			t.Fatalf("CopyFileIfChanged = %v, %v; want a skip", copied, err)
+		}
+		if n := len(f.requestsTo(http.MethodGet, "")); n != 2 {
+			t.Errorf("%d GETs, want both files read", n)
+		}
+		if n := copies(f); n != 0 {
+			t.Errorf("%d copies, want none", n)
+		}
+	})
+}
+
+func TestCopyMeta(t *testing.T) {
+	ttl := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			src, dst, bare := joinKey(dir, "src.bin"), joinKey(dir, "dst.bin"), joinKey(dir, "bare.bin")
+			// ключи в каноническом виде, как их возвращает S3
+			srcMeta := map[string]string{"Owner": "alice", "Stage": "raw"}
+			if err := s.CreateFile(src, []byte("old body"), nil, srcMeta); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreateFile(bare, []byte("bare"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			// тело перегенерировано, у dst свои метаданные и заголовки
+			if err := s.CreateFileWithOptions(dst, []byte("new body"), PutOptions{
+				TTL:          &ttl,
+				Meta:         map[string]string{"Owner": "bob", "Stale": "yes"},
+				CacheControl: "no-cache",
+				ContentType:  "application/x-report",
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.CopyMeta(src, dst); err != nil {
+				t.Fatalf("CopyMeta: %v", err)
+			}
+			if got, err := s.GetFile(dst); err != nil || string(got) != "new body" {
+				t.Errorf("dst body = %q, %v; want it untouched", got, err)
+			}
+			obj, err := s.StatObject(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(obj.Meta, srcMeta) {
+				t.Errorf("dst meta = %v, want %v", obj.Meta, srcMeta)
+			}
+			if obj.ContentType != "application/x-report" || obj.CacheControl != "no-cache" || !obj.Expires.Equal(ttl) {
+				t.Errorf("dst Content-Type %q, Cache-Control %q, Expires %v; want the ones dst was written with",
+					obj.ContentType, obj.CacheControl, obj.Expires)
+			}
+			if _, meta, err := s.Stat(src); err != nil || !reflect.DeepEqual(meta, srcMeta) {
+				t.Errorf("src meta = %v, %v; want it untouched", meta, err)
+			}
+
+			// у src нет метаданных - у dst они удаляются
+			if err := s.CopyMeta(bare, dst); err != nil {
+				t.Fatalf("CopyMeta from a file without meta: %v", err)
+			}
+			if _, meta, err := s.Stat(dst); err != nil || len(meta) != 0 {
+				t.Errorf("dst meta = %v, %v; want none", meta, err)
+			}
+
+			missing := joinKey(dir, "missing.bin")
+			for _, pair := range [][2]string{{missing, dst}, {src, missing}} {
+				if err := s.CopyMeta(pair[0], pair[1]); !errors.Is(err, ErrFileNotFound) {
+					t.Errorf("CopyMeta(%s, %s) = %v, want ErrFileNotFound", pair[0], pair[1], err)
+				}
+			}
+			if s.IsExist(missing) {
+				t.Error("CopyMeta created the missing dst")
+			}
+		})
+	}
+}
+
+// corruptingStore - приемник, портящий первый байт записываемого потока
+type corruptingStore struct {
+	StoreIFace
+}
+
+func (c *corruptingStore) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	first := true
+	return c.StoreIFace.StreamToFileWithContext(ctx, readerFunc(func(b []byte) (int, error) {
+		n, err := stream.Read(b)
+		if first && n > 0 {
+			b[0] ^= 0xff
+			first = false
+		}
+		return n, err
+	}), path, ttl)
+}
+
+func TestCopyVerified(t *testing.T) {
+	small := []byte("verified payload")
+	large := bytes.Repeat([]byte("0123456789abcdef"), int(S3PartSize)/16+1)
+
+	for _, b := range testBackends {
+		t.Run("to "+b.name, func(t *testing.T) {
+			dst, dir := b.store(t)
+			src := newTestLocal(t, LocalConfig{})
+			root := t.TempDir()
+
+			for name, data := range map[string][]byte{"small.bin": small, "large.bin": large, "empty.bin": {}} {
+				srcPath, dstPath := filepath.Join(root, name), joinKey(dir, name)
+				if err := os.WriteFile(srcPath, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := CopyVerified(src, srcPath, dst, dstPath, nil, CopyVerifiedOptions{}); err != nil {
+					t.Errorf("CopyVerified(%s): %v", name, err)
+					continue
+				}
+				if obj, err := dst.StatObject(dstPath); err != nil || obj.Size != int64(len(data)) {
+					t.Errorf("copied %s = %+v, %v; want %d bytes", name, obj, err, len(data))
+				}
+			}
+
+			// испорченная копия не проходит сверку
+			bad := joinKey(dir, "bad.bin")
+			srcPath := filepath.Join(root, "small.bin")
+			err := CopyVerified(src, srcPath, &corruptingStore{dst}, bad, nil, CopyVerifiedOptions{})
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("CopyVerified into a corrupting store = %v, want ErrChecksumMismatch", err)
+			}
+			if got, _ := dst.GetFile(bad); len(got) != len(small) {
+				t.Errorf("bad copy = %q, want it kept without DeleteOnMismatch", got)
+			}
+
+			err = CopyVerified(src, srcPath, &corruptingStore{dst}, bad, nil, CopyVerifiedOptions{DeleteOnMismatch: true})
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("CopyVerified with DeleteOnMismatch = %v, want ErrChecksumMismatch", err)
+			}
+			if dst.IsExist(bad) {
+				t.Error("bad copy exists after DeleteOnMismatch")
+			}
+		})
+	}
+
+	t.Run("S3 ETag", func(t *testing.T) {
+		src := newTestLocal(t, LocalConfig{})
+		root := t.TempDir()
+		dst, f := newFakeS3(t, S3Config{})
+		for name, data := range map[string][]byte{"small.bin": small, "large.bin": large} {
+			if err := os.WriteFile(filepath.Join(root, name), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := CopyVerified(src, filepath.Join(root, name), dst, name, nil, CopyVerifiedOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// ETag multipart загрузки считается во время копирования, копия не читается заново
+		if s, l := getsOf(f, "small.bin"), getsOf(f, "large.bin"); s != 0 || l != 0 {
+			t.Errorf("read back small.bin %d times, large.bin %d times; want neither", s, l)
+		}
+
+		// ETag, не совпавший с md5 (SSE-KMS), не считается расхождением
+		f.intercept = func(r *http.Request) *http.Response {
+			if r.Method == http.MethodPut {
+				return nil
+			}
+			if _, key := bucketKey(r); key == "kms.bin" && r.Method == http.MethodHead {
+				obj := f.object("kms.bin")
+				return fakeResponse(r, http.StatusOK, http.Header{
+					"Etag":           {`"0123456789abcdef0123456789abcdef"`},
+					"Content-Length": {strconv.Itoa(len(obj.data))},
+				}, nil)
+			}
+			return nil
+		}
+		if err := CopyVerified(src, filepath.Join(root, "small.bin"), dst, "kms.bin", nil, CopyVerifiedOptions{}); err != nil {
+			t.Errorf("CopyVerified with a non-md5 ETag = %v, want it verified by reading back", err)
+		}
+		if n := getsOf(f, "kms.bin"); n != 1 {
+			t.Errorf("read back kms.bin %d times, want 1", n)
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		dst, f := newFakeS3(t, S3Config{})
+		err := CopyVerified(newTestLocal(t, LocalConfig{}), filepath.Join(t.TempDir(), "missing.bin"), dst, "a.bin", nil, CopyVerifiedOptions{})
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("CopyVerified of a missing source = %v, want ErrFileNotFound", err)
+		}
+		if n := len(f.requestsTo("", "")); n != 0 {
+			t.Errorf("%d requests to the destination, want none", n)
+		}
+	})
+}
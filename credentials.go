@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/studio-b12/gowebdav"
+)
+
+// rotatingProvider - поставщик учетных данных S3, заменяемый на лету (RefreshCredentials);
+// клиент S3 кеширует полученные значения, пока они не истекут
+type rotatingProvider struct {
+	cur atomic.Pointer[credentials.Credentials]
+}
+
+func (p *rotatingProvider) Retrieve() (credentials.Value, error) {
+	return p.cur.Load().Get()
+}
+
+func (p *rotatingProvider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	return p.cur.Load().GetWithContext(ctx)
+}
+
+func (p *rotatingProvider) IsExpired() bool {
+	return p.cur.Load().IsExpired()
+}
+
+// RefreshCredentials - заменяет учетные данные S3 без пересоздания хранилища
+// creds - новые учетные данные, например credentials.NewStaticCredentials
+// Запросы, уже подписанные старыми данными, завершаются с ними, все следующие
+// подписываются новыми; пул соединений сохраняется. Хранилище с анонимным доступом
+// (credentials.AnonymousCredentials) и переход на анонимный доступ не поддерживаются.
+func (s *S3) RefreshCredentials(creds *credentials.Credentials) error {
+	if creds == nil || creds == credentials.AnonymousCredentials {
+		return errors.New("s3: RefreshCredentials requires non-anonymous credentials")
+	}
+	if s.creds == nil {
+		return errors.New("s3: store uses anonymous credentials")
+	}
+
+	s.creds.cur.Store(creds)
+	s.client.Config.Credentials.Expire()
+	return nil
+}
+
+// rotatingAuth - авторизация gowebdav, заменяемая на лету (RefreshCredentials, RefreshToken);
+// Authenticator создается на каждый запрос, поэтому новые данные применяются со следующего
+type rotatingAuth struct {
+	cur atomic.Pointer[gowebdav.Authorizer]
+}
+
+func newRotatingAuth(auth gowebdav.Authorizer) *rotatingAuth {
+	r := &rotatingAuth{}
+	r.cur.Store(&auth)
+	return r
+}
+
+func (r *rotatingAuth) NewAuthenticator(body io.Reader) (gowebdav.Authenticator, io.Reader) {
+	return (*r.cur.Load()).NewAuthenticator(body)
+}
+
+func (r *rotatingAuth) AddAuthenticator(key string, fn gowebdav.AuthFactory) {
+	(*r.cur.Load()).AddAuthenticator(key, fn)
+}
+
+// RefreshCredentials - заменяет пользователя и пароль WebDav без пересоздания хранилища
+// user - пользователь
+// pass - пароль
+// способ авторизации (WebDavConfig.AuthType) не меняется; для WebDavAuthBearer - RefreshToken
+func (w *WebDav) RefreshCredentials(user, pass string) error {
+	if w.authType == WebDavAuthBearer {
+		return errors.New("webdav: bearer auth is refreshed with RefreshToken")
+	}
+	return w.refreshAuth(WebDavConfig{AuthType: w.authType, WebDavUser: user, WebDavPass: pass})
+}
+
+// RefreshToken - заменяет токен WebDavAuthBearer без пересоздания хранилища
+// token - новый токен
+func (w *WebDav) RefreshToken(token string) error {
+	if w.authType != WebDavAuthBearer {
+		return errors.New("webdav: RefreshToken requires bearer auth")
+	}
+	return w.refreshAuth(WebDavConfig{AuthType: w.authType, WebDavToken: token})
+}
+
+// refreshAuth - строит авторизацию по cfg и подменяет текущую
+func (w *WebDav) refreshAuth(cfg WebDavConfig) error {
+	auth, err := webdavAuthorizer(cfg)
+	if err != nil {
+		return err
+	}
+	w.auth.cur.Store(&auth)
+	return nil
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+func TestS3RefreshCredentials(t *testing.T) {
+	s, fake := newFakeS3(t, S3Config{})
+	client := s.client
+
+	signedBy := func() string {
+		t.Helper()
+		if err := s.CreateFile("a.txt", []byte("a"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		reqs := fake.requestsTo(http.MethodPut, "")
+		auth := reqs[len(reqs)-1].Header.Get("Authorization")
+		_, rest, ok := strings.Cut(auth, "Credential=")
+		if !ok {
+			t.Fatalf("Authorization = %q, want a SigV4 credential", auth)
+		}
+		key, _, _ := strings.Cut(rest, "/")
+		return key
+	}
+
+	if got := signedBy(); got != "id" {
+		t.Fatalf("signed by %q before the rotation, want id", got)
+	}
+	if err := s.RefreshCredentials(credentials.NewStaticCredentials("rotated", "secret2", "")); err != nil {
+		t.Fatal(err)
+	}
+	if got := signedBy(); got != "rotated" {
+		t.Errorf("signed by %q after RefreshCredentials, want rotated", got)
+	}
+	if s.client != client {
+		t.Error("RefreshCredentials rebuilt the S3 client")
+	}
+
+	if err := s.RefreshCredentials(credentials.AnonymousCredentials); err == nil {
+		t.Error("RefreshCredentials to anonymous credentials succeeded")
+	}
+}
+
+func TestWebDavRefreshCredentials(t *testing.T) {
+	var mu sync.Mutex
+	var last string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		last = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	})
+	authorization := func(t *testing.T, w *WebDav) string {
+		t.Helper()
+		if err := w.CreateFile("a.txt", []byte("a"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+
+	t.Run("basic", func(t *testing.T) {
+		w := newTestWebDav(t, WebDavConfig{AuthType: WebDavAuthBasic, WebDavUser: "user", WebDavPass: "old"}, handler)
+		if got, want := authorization(t, w), basicHeader("user", "old"); got != want {
+			t.Fatalf("Authorization = %q, want %q", got, want)
+		}
+		if err := w.RefreshCredentials("user", "new"); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := authorization(t, w), basicHeader("user", "new"); got != want {
+			t.Errorf("Authorization after RefreshCredentials = %q, want %q", got, want)
+		}
+		if err := w.RefreshToken("token"); err == nil {
+			t.Error("RefreshToken on basic auth succeeded")
+		}
+	})
+
+	t.Run("bearer", func(t *testing.T) {
+		w := newTestWebDav(t, WebDavConfig{AuthType: WebDavAuthBearer, WebDavToken: "old"}, handler)
+		if got := authorization(t, w); got != "Bearer old" {
+			t.Fatalf("Authorization = %q, want Bearer old", got)
+		}
+		if err := w.RefreshToken("new"); err != nil {
+			t.Fatal(err)
+		}
+		if got := authorization(t, w); got != "Bearer new" {
+			t.Errorf("Authorization after RefreshToken = %q, want Bearer new", got)
+		}
+	})
+}
+
+// basicHeader - значение заголовка Authorization для Basic
+func basicHeader(user, pass string) string {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth(user, pass)
+	return r.Header.Get("Authorization")
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DiffResult - различия двух директорий, пути относительно сравниваемых директорий
+// OnlyInA - файлы, которые есть только в a
+// OnlyInB - файлы, которые есть только в b
+// Changed - файлы, которые есть в обеих директориях, но отличаются содержимым
+type DiffResult struct {
+	OnlyInA []string
+	OnlyInB []string
+	Changed []string
+}
+
+// Equal - директории совпадают
+func (d DiffResult) Equal() bool {
+	return len(d.OnlyInA) == 0 && len(d.OnlyInB) == 0 && len(d.Changed) == 0
+}
+
+// Diff - сравнивает директории двух хранилищ (или одного хранилища)
+// a - первое хранилище
+// aPrefix - директория в первом хранилище
+// b - второе хранилище
+// bPrefix - директория во втором хранилище
+// обе директории обходятся со всеми поддиректориями, мета-файлы не сравниваются.
+// Файлы сравниваются по размеру, затем по sha256 из метаданных (MetaSHA256), если он
+// есть у обоих, и только затем по sha256 содержимого; время изменения не учитывается.
+// Отсутствующая директория считается пустой. Списки результата отсортированы
+func Diff(ctx context.Context, a StoreIFace, aPrefix string, b StoreIFace, bPrefix string) (DiffResult, error) {
+	var result DiffResult
+
+	aFiles, err := diffList(ctx, a, aPrefix)
+	if err != nil {
+		return result, err
+	}
+	bFiles, err := diffList(ctx, b, bPrefix)
+	if err != nil {
+		return result, err
+	}
+
+	for rel, aInfo := range aFiles {
+		if _, ok := bFiles[rel]; !ok {
+			result.OnlyInA = append(result.OnlyInA, rel)
+			continue
+		}
+
+		aPath, bPath := joinKey(aPrefix, rel), joinKey(bPrefix, rel)
+		changed, err := syncChanged(ctx, a, aPath, aInfo, b, bPath, SyncOptions{Checksum: true})
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", aPath, err)
+		}
+		if changed {
+			result.Changed = append(result.Changed, rel)
+		}
+	}
+	for rel := range bFiles {
+		if _, ok := aFiles[rel]; !ok {
+			result.OnlyInB = append(result.OnlyInB, rel)
+		}
+	}
+
+	sort.Strings(result.OnlyInA)
+	sort.Strings(result.OnlyInB)
+	sort.Strings(result.Changed)
+	return result, nil
+}
+
+// diffList - файлы директории со всеми поддиректориями по относительному пути
+func diffList(ctx context.Context, s StoreIFace, prefix string) (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo)
+	err := walkDir(ctx, s, prefix, func(rel string, info os.FileInfo) error {
+		files[rel] = info
+		return nil
+	})
+	if errors.Is(err, ErrFileNotFound) {
+		return files, nil
+	}
+	return files, err
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStore - минимальное хранилище в памяти для тестов: листинг, Stat и WriteTo,
+// остальные методы - заглушки Empty
+type memStore struct {
+	*Empty
+	files map[string]memFile
+}
+
+type memFile struct {
+	data []byte
+	meta map[string]string
+}
+
+func newMemStore(files map[string]string) *memStore {
+	m := &memStore{Empty: &Empty{}, files: make(map[string]memFile)}
+	for path, data := range files {
+		m.files[path] = memFile{data: []byte(data)}
+	}
+	return m
+}
+
+// memFileInfo - os.FileInfo файла или директории memStore
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return 0644 }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.dir }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+func (m *memStore) Backend() string {
+	return "memory"
+}
+
+func (m *memStore) ListDirChanWithContext(ctx context.Context, dir string) <-chan DirEntry {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	seen := map[string]bool{}
+	var entries []DirEntry
+	for path, f := range m.files {
+		rel, ok := strings.CutPrefix(path, prefix)
+		if !ok {
+			continue
+		}
+		name, _, nested := strings.Cut(rel, "/")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		info := memFileInfo{name: name, dir: nested}
+		if !nested {
+			info.size = int64(len(f.data))
+		}
+		entries = append(entries, DirEntry{Info: info})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Info.Name() < entries[j].Info.Name() })
+	if len(entries) == 0 {
+		entries = append(entries, DirEntry{Err: ErrFileNotFound})
+	}
+
+	ch := make(chan DirEntry, len(entries))
+	for _, entry := range entries {
+		ch <- entry
+	}
+	close(ch)
+	return ch
+}
+
+func (m *memStore) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	f, ok := m.files[path]
+	if !ok {
+		return nil, nil, ErrFileNotFound
+	}
+	return memFileInfo{name: filepath.Base(path), size: int64(len(f.data))}, f.meta, nil
+}
+
+func (m *memStore) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	f, ok := m.files[path]
+	if !ok {
+		return 0, ErrFileNotFound
+	}
+	n, err := w.Write(f.data)
+	return int64(n), err
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b map[string]string
+		want DiffResult
+	}{
+		{
+			name: "equal",
+			a:    map[string]string{"x.txt": "x", "dir/y.txt": "y"},
+			b:    map[string]string{"x.txt": "x", "dir/y.txt": "y"},
+		},
+		{
+			name: "only in one side",
+			a:    map[string]string{"x.txt": "x", "dir/a.txt": "a"},
+			b:    map[string]string{"x.txt": "x", "dir/sub/b.txt": "b"},
+			want: DiffResult{OnlyInA: []string{"dir/a.txt"}, OnlyInB: []string{"dir/sub/b.txt"}},
+		},
+		{
+			name: "same size, different content",
+			a:    map[string]string{"x.txt": "abc", "y.txt": "same"},
+			b:    map[string]string{"x.txt": "abd", "y.txt": "same"},
+			want: DiffResult{Changed: []string{"x.txt"}},
+		},
+		{
+			name: "different size",
+			a:    map[string]string{"dir/x.txt": "short"},
+			b:    map[string]string{"dir/x.txt": "much longer"},
+			want: DiffResult{Changed: []string{"dir/x.txt"}},
+		},
+		{
+			name: "missing directory is empty",
+			a:    map[string]string{"x.txt": "x", "dir/y.txt": "y"},
+			b:    map[string]string{},
+			want: DiffResult{OnlyInA: []string{"dir/y.txt", "x.txt"}},
+		},
+	}
+
+	// fill - хранилище с файлами files под своей директорией
+	type fill func(t *testing.T, files map[string]string) (StoreIFace, string)
+	memory := func(t *testing.T, files map[string]string) (StoreIFace, string) {
+		prefixed := make(map[string]string, len(files))
+		for rel, data := range files {
+			prefixed["root/"+rel] = data
+		}
+		return newMemStore(prefixed), "root"
+	}
+	local := func(t *testing.T, files map[string]string) (StoreIFace, string) {
+		root := filepath.Join(t.TempDir(), "root")
+		s := newTestLocal(t, LocalConfig{CreateParents: true})
+		for rel, data := range files {
+			if err := s.CreateFile(filepath.Join(root, rel), []byte(data), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s, root
+	}
+	pairs := []struct {
+		name string
+		a, b fill
+	}{
+		{"memory-memory", memory, memory},
+		{"memory-local", memory, local},
+		{"local-memory", local, memory},
+		{"local-local", local, local},
+	}
+
+	for _, tt := range tests {
+		for _, pair := range pairs {
+			t.Run(tt.name+"/"+pair.name, func(t *testing.T) {
+				a, aPrefix := pair.a(t, tt.a)
+				b, bPrefix := pair.b(t, tt.b)
+
+				got, err := Diff(context.Background(), a, aPrefix, b, bPrefix)
+				if err != nil {
+					t.Fatalf("Diff: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Diff = %+v, want %+v", got, tt.want)
+				}
+				if got.Equal() != tt.want.Equal() {
+					t.Errorf("Equal = %v, want %v", got.Equal(), tt.want.Equal())
+				}
+			})
+		}
+	}
+}
+
+func TestDiffMetaChecksum(t *testing.T) {
+	// одинаковый sha256 в метаданных обоих файлов считается совпадением без чтения содержимого
+	a := newMemStore(nil)
+	b := newMemStore(nil)
+	a.files["root/x.txt"] = memFile{data: []byte("abc"), meta: map[string]string{MetaSHA256: "1"}}
+	b.files["root/x.txt"] = memFile{data: []byte("abd"), meta: map[string]string{MetaSHA256: "1"}}
+
+	got, err := Diff(context.Background(), a, "root", b, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal() {
+		t.Errorf("Diff = %+v, want equal by metadata checksum", got)
+	}
+
+	b.files["root/x.txt"] = memFile{data: []byte("abc"), meta: map[string]string{MetaSHA256: "2"}}
+	if got, err = Diff(context.Background(), a, "root", b, "root"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x.txt"}; !reflect.DeepEqual(got.Changed, want) {
+		t.Errorf("Changed = %v, want %v", got.Changed, want)
+	}
+}
@@ -2,126 +2,561 @@ package store
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
 type Empty struct {
+	// recording - записывать вызовы (EmptyConfig.Record)
+	recording bool
+	mu        sync.Mutex
+	calls     []Call
+}
+
+// Call - вызов метода Empty, записанный при EmptyConfig.Record
+// Method - имя вызванного метода (CreateFile, CreateFileWithContext и т.д.)
+// Args - аргументы в порядке объявления, без context.Context
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
 }
 
 func (l *Empty) init(cfg EmptyConfig) error {
+	l.recording = cfg.Record
 	return nil
 }
 
+// record - записывает вызов, если включена запись
+func (l *Empty) record(method string, args ...interface{}) {
+	if !l.recording {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, Call{Method: method, Args: args})
+}
+
+// Calls - записанные вызовы в порядке выполнения (копия списка)
+// без EmptyConfig.Record список пуст; Backend не записывается, его вызывают
+// вспомогательные функции пакета
+func (l *Empty) Calls() []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Call(nil), l.calls...)
+}
+
+func (l *Empty) Backend() string {
+	return EmptyStore
+}
+
 func (l *Empty) IsExist(filePath string) bool {
+	l.record("IsExist", filePath)
 	return false
 }
 
+func (l *Empty) ExistMany(paths []string) (map[string]bool, error) {
+	l.record("ExistMany", paths)
+	return emptyExistMany(paths), nil
+}
+
+// emptyExistMany - результат ExistMany: все пути отсутствуют
+func emptyExistMany(paths []string) map[string]bool {
+	result := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		result[path] = false
+	}
+	return result
+}
+
 func (l *Empty) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	l.record("CreateFile", path, file, ttl, meta)
+	return nil
+}
+
+func (l *Empty) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	l.record("CreateFileWithOptions", path, file, opts)
+	return nil
+}
+
+func (l *Empty) Reserve(path string) error {
+	l.record("Reserve", path)
 	return nil
 }
 
 func (l *Empty) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	l.record("CopyFile", src, dst, ttl, meta)
+	return nil
+}
+
+func (l *Empty) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	l.record("CopyFileWithOptions", src, dst, opts)
+	return nil
+}
+
+func (l *Empty) CopyFileIfChanged(src, dst string) (bool, error) {
+	l.record("CopyFileIfChanged", src, dst)
+	return false, nil
+}
+
+func (l *Empty) ExtractRange(src string, offset, length int64, dst string) error {
+	l.record("ExtractRange", src, offset, length, dst)
+	return nil
+}
+
+func (l *Empty) Truncate(path string, size int64) error {
+	l.record("Truncate", path, size)
 	return nil
 }
 
 func (l *Empty) MoveFile(src, dst string) error {
+	l.record("MoveFile", src, dst)
+	return nil
+}
+
+func (l *Empty) MoveFileNoOverwrite(src, dst string) error {
+	l.record("MoveFileNoOverwrite", src, dst)
+	return nil
+}
+
+func (l *Empty) SwapFiles(a, b string) error {
+	l.record("SwapFiles", a, b)
+	return nil
+}
+
+func (l *Empty) Rotate(path string) (string, error) {
+	l.record("Rotate", path)
+	return "", nil
+}
+
+func (l *Empty) CopyMeta(src, dst string) error {
+	l.record("CopyMeta", src, dst)
 	return nil
 }
 
 func (l *Empty) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	l.record("StreamToFile", stream, path, ttl)
 	return nil
 }
 
+func (l *Empty) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	l.record("StreamToFileN", stream, path, ttl)
+	return 0, nil
+}
+
+func (l *Empty) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	l.record("FileWriter", path, ttl, meta)
+	return nopWriteCloser{io.Discard}, nil
+}
+
 func (l *Empty) RemoveFile(path string) error {
+	l.record("RemoveFile", path)
 	return nil
 }
 
+func (l *Empty) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	l.record("RemoveFileIfMatch", path, etag)
+	return false, nil
+}
+
 func (l *Empty) GetFile(path string) ([]byte, error) {
+	l.record("GetFile", path)
 	return nil, nil
 }
 
 func (l *Empty) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	l.record("GetFilePartially", path, offset, length)
+	return nil, nil
+}
+
+func (l *Empty) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	l.record("GetFileIfModifiedSince", path, t)
+	return nil, false, nil
+}
+
+func (l *Empty) Peek(path string, n int) ([]byte, error) {
+	l.record("Peek", path, n)
+	return nil, nil
+}
+
+func (l *Empty) GetFileVerified(path string) ([]byte, error) {
+	l.record("GetFileVerified", path)
 	return nil, nil
 }
 
 func (l *Empty) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	l.record("FileReader", path, offset, length)
+	return nil, nil
+}
+
+func (l *Empty) MultiReader(paths []string) (io.ReadCloser, error) {
+	l.record("MultiReader", paths)
 	return nil, nil
 }
 
+func (l *Empty) WriteTo(path string, w io.Writer) (int64, error) {
+	l.record("WriteTo", path, w)
+	return 0, nil
+}
+
 func (l *Empty) Stat(path string) (os.FileInfo, map[string]string, error) {
+	l.record("Stat", path)
 	return nil, nil, nil
 }
 
+func (l *Empty) StatLite(path string) (os.FileInfo, error) {
+	l.record("StatLite", path)
+	return nil, nil
+}
+
+func (l *Empty) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	l.record("Lstat", path)
+	return nil, nil, nil
+}
+
+func (l *Empty) Symlink(oldname, newname string) error {
+	l.record("Symlink", oldname, newname)
+	return nil
+}
+
+func (l *Empty) StatObject(path string) (ObjectInfo, error) {
+	l.record("StatObject", path)
+	return ObjectInfo{}, nil
+}
+
+func (l *Empty) PublicURL(path string) (string, error) {
+	l.record("PublicURL", path)
+	return "", errors.ErrUnsupported
+}
+
+func (l *Empty) Latest(dir string) (os.FileInfo, error) {
+	l.record("Latest", dir)
+	return nil, nil
+}
+
+func (l *Empty) IsEmpty(dir string) (bool, error) {
+	l.record("IsEmpty", dir)
+	return true, nil
+}
+
+func (l *Empty) ListModifiedSince(dir string, since time.Time) ([]os.FileInfo, error) {
+	l.record("ListModifiedSince", dir, since)
+	return nil, nil
+}
+
+func (l *Empty) ListDirDepth(dir string, maxDepth int) ([]os.FileInfo, error) {
+	l.record("ListDirDepth", dir, maxDepth)
+	return nil, nil
+}
+
+func (l *Empty) ListDirChan(dir string) <-chan DirEntry {
+	l.record("ListDirChan", dir)
+	return emptyDirChan()
+}
+
+// emptyDirChan - закрытый канал пустого листинга
+func emptyDirChan() <-chan DirEntry {
+	ch := make(chan DirEntry)
+	close(ch)
+	return ch
+}
+
+func (l *Empty) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	l.record("ArchiveDir", path, w, format)
+	return nil
+}
+
+func (l *Empty) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	l.record("ExtractArchive", r, path, format)
+	return nil
+}
+
+func (l *Empty) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	l.record("Manifest", path, algo)
+	return nil, nil
+}
+
+func (l *Empty) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	l.record("BlockChecksums", path, blockSize)
+	return nil, nil
+}
+
+func (l *Empty) ListMeta(path string) (map[string]map[string]string, error) {
+	l.record("ListMeta", path)
+	return nil, nil
+}
+
 func (l *Empty) ClearDir(dir string) error {
+	l.record("ClearDir", dir)
 	return nil
 }
 
 func (l *Empty) MkdirAll(path string) error {
+	l.record("MkdirAll", path)
 	return nil
 }
 
 func (l *Empty) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	l.record("CreateJsonFile", path, data, ttl, meta)
 	return nil
 }
 
 func (l *Empty) GetJsonFile(path string, file interface{}) error {
+	l.record("GetJsonFile", path, file)
 	return nil
 }
 
+func (l *Empty) GetJsonMap(path string) (map[string]interface{}, error) {
+	l.record("GetJsonMap", path)
+	return nil, nil
+}
+
 func (l *Empty) IsExistWithContext(ctx context.Context, filePath string) bool {
+	l.record("IsExistWithContext", filePath)
 	return false
 }
 
+func (l *Empty) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	l.record("ExistManyWithContext", paths)
+	return emptyExistMany(paths), nil
+}
+
 func (l *Empty) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	l.record("CreateFileWithContext", path, file, ttl, meta)
+	return nil
+}
+
+func (l *Empty) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	l.record("CreateFileWithOptionsWithContext", path, file, opts)
+	return nil
+}
+
+func (l *Empty) ReserveWithContext(ctx context.Context, path string) error {
+	l.record("ReserveWithContext", path)
 	return nil
 }
 
 func (l *Empty) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	l.record("CopyFileWithContext", src, dst, ttl, meta)
+	return nil
+}
+
+func (l *Empty) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	l.record("CopyFileWithOptionsWithContext", src, dst, opts)
+	return nil
+}
+
+func (l *Empty) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	l.record("CopyFileIfChangedWithContext", src, dst)
+	return false, nil
+}
+
+func (l *Empty) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	l.record("ExtractRangeWithContext", src, offset, length, dst)
+	return nil
+}
+
+func (l *Empty) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	l.record("TruncateWithContext", path, size)
 	return nil
 }
 
 func (l *Empty) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	l.record("MoveFileWithContext", src, dst)
+	return nil
+}
+
+func (l *Empty) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	l.record("MoveFileNoOverwriteWithContext", src, dst)
+	return nil
+}
+
+func (l *Empty) SwapFilesWithContext(ctx context.Context, a, b string) error {
+	l.record("SwapFilesWithContext", a, b)
+	return nil
+}
+
+func (l *Empty) RotateWithContext(ctx context.Context, path string) (string, error) {
+	l.record("RotateWithContext", path)
+	return "", nil
+}
+
+func (l *Empty) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	l.record("CopyMetaWithContext", src, dst)
 	return nil
 }
 
 func (l *Empty) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	l.record("StreamToFileWithContext", stream, path, ttl)
 	return nil
 }
 
+func (l *Empty) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	l.record("StreamToFileNWithContext", stream, path, ttl)
+	return 0, nil
+}
+
+func (l *Empty) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	l.record("FileWriterWithContext", path, ttl, meta)
+	return nopWriteCloser{io.Discard}, nil
+}
+
 func (l *Empty) RemoveFileWithContext(ctx context.Context, path string) error {
+	l.record("RemoveFileWithContext", path)
 	return nil
 }
 
+func (l *Empty) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	l.record("RemoveFileIfMatchWithContext", path, etag)
+	return false, nil
+}
+
 func (l *Empty) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	l.record("GetFileWithContext", path)
 	return nil, nil
 }
 
 func (l *Empty) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	l.record("GetFilePartiallyWithContext", path, offset, length)
+	return nil, nil
+}
+
+func (l *Empty) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	l.record("GetFileIfModifiedSinceWithContext", path, t)
+	return nil, false, nil
+}
+
+func (l *Empty) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	l.record("PeekWithContext", path, n)
+	return nil, nil
+}
+
+func (l *Empty) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	l.record("GetFileVerifiedWithContext", path)
 	return nil, nil
 }
 
 func (l *Empty) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	l.record("FileReaderWithContext", path, offset, length)
 	return nil, nil
 }
 
+func (l *Empty) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	l.record("MultiReaderWithContext", paths)
+	return nil, nil
+}
+
+func (l *Empty) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	l.record("WriteToWithContext", path, w)
+	return 0, nil
+}
+
 func (l *Empty) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	l.record("StatWithContext", path)
 	return nil, nil, nil
 }
 
+func (l *Empty) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	l.record("StatLiteWithContext", path)
+	return nil, nil
+}
+
+func (l *Empty) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	l.record("LstatWithContext", path)
+	return nil, nil, nil
+}
+
+func (l *Empty) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	l.record("SymlinkWithContext", oldname, newname)
+	return nil
+}
+
+func (l *Empty) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	l.record("StatObjectWithContext", path)
+	return ObjectInfo{}, nil
+}
+
+func (l *Empty) LatestWithContext(ctx context.Context, dir string) (os.FileInfo, error) {
+	l.record("LatestWithContext", dir)
+	return nil, nil
+}
+
+func (l *Empty) IsEmptyWithContext(ctx context.Context, dir string) (bool, error) {
+	l.record("IsEmptyWithContext", dir)
+	return true, nil
+}
+
+func (l *Empty) ListModifiedSinceWithContext(ctx context.Context, dir string, since time.Time) ([]os.FileInfo, error) {
+	l.record("ListModifiedSinceWithContext", dir, since)
+	return nil, nil
+}
+
+func (l *Empty) ListDirDepthWithContext(ctx context.Context, dir string, maxDepth int) ([]os.FileInfo, error) {
+	l.record("ListDirDepthWithContext", dir, maxDepth)
+	return nil, nil
+}
+
+func (l *Empty) ListDirChanWithContext(ctx context.Context, dir string) <-chan DirEntry {
+	l.record("ListDirChanWithContext", dir)
+	return emptyDirChan()
+}
+
+func (l *Empty) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	l.record("ArchiveDirWithContext", path, w, format)
+	return nil
+}
+
+func (l *Empty) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	l.record("ExtractArchiveWithContext", r, path, format)
+	return nil
+}
+
+func (l *Empty) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	l.record("ManifestWithContext", path, algo)
+	return nil, nil
+}
+
+func (l *Empty) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	l.record("BlockChecksumsWithContext", path, blockSize)
+	return nil, nil
+}
+
+func (l *Empty) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	l.record("ListMetaWithContext", path)
+	return nil, nil
+}
+
 func (l *Empty) ClearDirWithContext(ctx context.Context, dir string) error {
+	l.record("ClearDirWithContext", dir)
 	return nil
 }
 
 func (l *Empty) MkdirAllWithContext(ctx context.Context, path string) error {
+	l.record("MkdirAllWithContext", path)
 	return nil
 }
 
 func (l *Empty) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	l.record("CreateJsonFileWithContext", path, data, ttl, meta)
 	return nil
 }
 
 func (l *Empty) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	l.record("GetJsonFileWithContext", path, file)
 	return nil
 }
+
+func (l *Empty) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	l.record("GetJsonMapWithContext", path)
+	return nil, nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRecordingEmpty - Empty с записью вызовов
+func newRecordingEmpty(t *testing.T) *Empty {
+	t.Helper()
+	s, err := NewEmpty(EmptyConfig{Record: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*Empty)
+}
+
+func TestEmptyRecordsCalls(t *testing.T) {
+	s := newRecordingEmpty(t)
+	ctx := context.Background()
+	ttl := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := map[string]string{"Owner": "alice"}
+	stream := strings.NewReader("body")
+
+	// вызовы возвращают результаты пустого хранилища
+	if err := s.CreateFile("a.txt", []byte("a"), &ttl, meta); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetFile("a.txt"); got != nil || err != nil {
+		t.Errorf("GetFile = %q, %v; want nil, nil", got, err)
+	}
+	if s.IsExist("a.txt") {
+		t.Error("IsExist = true, want false")
+	}
+	if err := s.MoveFile("a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StreamToFileWithContext(ctx, stream, "c.txt", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetFilePartially("c.txt", 2, 10); got != nil || err != nil {
+		t.Errorf("GetFilePartially = %q, %v; want nil, nil", got, err)
+	}
+	for range s.ListDirChan("dir") {
+		t.Error("ListDirChan returned an entry, want none")
+	}
+	if err := s.RemoveFileWithContext(ctx, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	// Backend не записывается
+	s.Backend()
+
+	want := []Call{
+		{"CreateFile", []interface{}{"a.txt", []byte("a"), &ttl, meta}},
+		{"GetFile", []interface{}{"a.txt"}},
+		{"IsExist", []interface{}{"a.txt"}},
+		{"MoveFile", []interface{}{"a.txt", "b.txt"}},
+		{"StreamToFileWithContext", []interface{}{stream, "c.txt", (*time.Time)(nil)}},
+		{"GetFilePartially", []interface{}{"c.txt", int64(2), int64(10)}},
+		{"ListDirChan", []interface{}{"dir"}},
+		{"RemoveFileWithContext", []interface{}{"b.txt"}},
+	}
+	if got := s.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Calls() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestEmptyCallsCopy(t *testing.T) {
+	s := newRecordingEmpty(t)
+	s.RemoveFile("a.txt")
+
+	calls := s.Calls()
+	calls[0].Method = "changed"
+	s.RemoveFile("b.txt")
+
+	got := s.Calls()
+	if len(got) != 2 || got[0].Method != "RemoveFile" || got[1].Args[0] != "b.txt" {
+		t.Errorf("Calls() = %v, want both RemoveFile calls unaffected by the caller", got)
+	}
+}
+
+func TestEmptyWithoutRecord(t *testing.T) {
+	s, err := NewEmpty(EmptyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.CreateFile("a.txt", []byte("a"), nil, nil)
+	s.RemoveFile("a.txt")
+	if calls := s.(*Empty).Calls(); len(calls) != 0 {
+		t.Errorf("Calls() = %v without Record, want none", calls)
+	}
+}
+
+func TestEmptyRecordsThroughWrappers(t *testing.T) {
+	s := newRecordingEmpty(t)
+	if err := WithKeyNormalization(s).CreateFile("/dir//./a.txt", []byte("a"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// запись показывает, с какими аргументами обертка вызвала хранилище
+	calls := s.Calls()
+	if len(calls) != 1 || calls[0].Method != "CreateFile" || calls[0].Args[0] != "dir/a.txt" {
+		t.Errorf("Calls() = %v, want one CreateFile of dir/a.txt", calls)
+	}
+}
+
+func TestEmptyRecordsConcurrentCalls(t *testing.T) {
+	s := newRecordingEmpty(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.IsExist("a.txt")
+		}()
+	}
+	wg.Wait()
+
+	if n := len(s.Calls()); n != 50 {
+		t.Errorf("%d calls recorded, want 50", n)
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// NewFromEnv - создает хранилище по переменным окружения
+// Общие переменные:
+//
+//	STORE_TYPE            - тип хранилища: local, webdav, s3, empty или зарегистрированный
+//	                        через Register (обязательна)
+//	STORE_SKIP_VALIDATION - не проверять доступность хранилища при создании (true/false)
+//	STORE_NORMALIZE_KEYS  - нормализовать пути (true/false)
+//	STORE_DEFAULT_TTL     - время жизни файлов без ttl, например 24h
+//	STORE_MAX_GET_SIZE    - наибольший размер файла для GetFile в байтах
+//
+// S3:
+//
+//	S3_BUCKET             - бакет (обязательна)
+//	S3_REGION             - регион
+//	S3_ENDPOINT           - адрес S3-совместимого сервиса
+//	S3_FORCE_PATH_STYLE   - адресовать бакет в пути, а не в имени хоста (true/false)
+//	S3_ACCESS_KEY_ID      - ключ доступа; вместе с S3_SECRET_ACCESS_KEY, без них
+//	S3_SECRET_ACCESS_KEY    используется стандартная цепочка SDK (AWS_*, профиль, роль)
+//
+// WebDav:
+//
+//	WEBDAV_HOST           - адрес сервера (обязательна)
+//	WEBDAV_USER           - пользователь
+//	WEBDAV_PASS           - пароль
+//	WEBDAV_AUTH_TYPE      - авторизация: basic, digest, bearer; по умолчанию по ответу сервера
+//	WEBDAV_TOKEN          - токен для bearer (обязательна для bearer)
+//
+// Local разрешает пути относительно рабочей директории, поэтому LOCAL_ROOT
+// не поддерживается и возвращает ошибку, а не молча игнорируется.
+// Если обязательные для выбранного типа переменные не заданы, в ошибке перечисляются все.
+func NewFromEnv() (StoreIFace, error) {
+	cfg, err := configFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// configFromEnv - собирает Config из переменных окружения (см. NewFromEnv)
+func configFromEnv() (Config, error) {
+	var cfg Config
+	var missing []string
+	required := func(name string) string {
+		v := os.Getenv(name)
+		if v == "" {
+			missing = append(missing, name)
+		}
+		return v
+	}
+
+	cfg.StoreType = os.Getenv("STORE_TYPE")
+	if cfg.StoreType == "" {
+		return cfg, fmt.Errorf("store: environment variable STORE_TYPE is not set")
+	}
+
+	var err error
+	if cfg.SkipValidation, err = envBool("STORE_SKIP_VALIDATION"); err != nil {
+		return cfg, err
+	}
+	if cfg.NormalizeKeys, err = envBool("STORE_NORMALIZE_KEYS"); err != nil {
+		return cfg, err
+	}
+	if v := os.Getenv("STORE_DEFAULT_TTL"); v != "" {
+		if cfg.DefaultTTL, err = time.ParseDuration(v); err != nil {
+			return cfg, fmt.Errorf("store: STORE_DEFAULT_TTL: %w", err)
+		}
+	}
+	if v := os.Getenv("STORE_MAX_GET_SIZE"); v != "" {
+		if cfg.MaxGetSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return cfg, fmt.Errorf("store: STORE_MAX_GET_SIZE: %w", err)
+		}
+	}
+
+	switch cfg.StoreType {
+	case LocalStore:
+		if os.Getenv("LOCAL_ROOT") != "" {
+			return cfg, fmt.Errorf("store: LOCAL_ROOT is not supported, local paths are relative to the working directory")
+		}
+	case WebDavStore:
+		cfg.WebDavConfig.WebDavHost = required("WEBDAV_HOST")
+		cfg.WebDavConfig.WebDavUser = os.Getenv("WEBDAV_USER")
+		cfg.WebDavConfig.WebDavPass = os.Getenv("WEBDAV_PASS")
+		switch v := os.Getenv("WEBDAV_AUTH_TYPE"); strings.ToLower(v) {
+		case "":
+		case "basic":
+			cfg.WebDavConfig.AuthType = WebDavAuthBasic
+		case "digest":
+			cfg.WebDavConfig.AuthType = WebDavAuthDigest
+		case "bearer":
+			cfg.WebDavConfig.AuthType = WebDavAuthBearer
+			cfg.WebDavConfig.WebDavToken = required("WEBDAV_TOKEN")
+		default:
+			return cfg, fmt.Errorf("store: unknown WEBDAV_AUTH_TYPE %q", v)
+		}
+	case S3Store:
+		cfg.S3Config.S3Bucket = required("S3_BUCKET")
+		if v := os.Getenv("S3_REGION"); v != "" {
+			cfg.S3Config.Config.Region = aws.String(v)
+		}
+		if v := os.Getenv("S3_ENDPOINT"); v != "" {
+			cfg.S3Config.Config.Endpoint = aws.String(v)
+		}
+		pathStyle, err := envBool("S3_FORCE_PATH_STYLE")
+		if err != nil {
+			return cfg, err
+		}
+		if pathStyle {
+			cfg.S3Config.Config.S3ForcePathStyle = aws.Bool(true)
+		}
+
+		keyId, secret := os.Getenv("S3_ACCESS_KEY_ID"), os.Getenv("S3_SECRET_ACCESS_KEY")
+		switch {
+		case keyId != "" && secret != "":
+			cfg.S3Config.Config.Credentials = credentials.NewStaticCredentials(keyId, secret, "")
+		case keyId != "":
+			missing = append(missing, "S3_SECRET_ACCESS_KEY")
+		case secret != "":
+			missing = append(missing, "S3_ACCESS_KEY_ID")
+		}
+	case EmptyStore:
+	default:
+		// переменные стороннего хранилища читает его фабрика (см. Register)
+		if _, ok := registered(cfg.StoreType); !ok {
+			return cfg, fmt.Errorf("store: unknown STORE_TYPE %q", cfg.StoreType)
+		}
+	}
+
+	if len(missing) > 0 {
+		return cfg, fmt.Errorf("store: environment variables required for %s are not set: %s", cfg.StoreType, strings.Join(missing, ", "))
+	}
+	return cfg, nil
+}
+
+// envBool - булева переменная окружения, пустая - false
+func envBool(name string) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("store: %s: %w", name, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// envVars - все переменные, которые читает NewFromEnv
+var envVars = []string{
+	"STORE_TYPE", "STORE_SKIP_VALIDATION", "STORE_NORMALIZE_KEYS", "STORE_DEFAULT_TTL", "STORE_MAX_GET_SIZE",
+	"S3_BUCKET", "S3_REGION", "S3_ENDPOINT", "S3_FORCE_PATH_STYLE", "S3_ACCESS_KEY_ID", "S3_SECRET_ACCESS_KEY",
+	"WEBDAV_HOST", "WEBDAV_USER", "WEBDAV_PASS", "WEBDAV_AUTH_TYPE", "WEBDAV_TOKEN",
+	"LOCAL_ROOT",
+}
+
+// setEnv - задает env, остальные переменные NewFromEnv очищает
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range envVars {
+		t.Setenv(name, env[name])
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+	}{
+		{"local with common options", map[string]string{
+			"STORE_TYPE":            "local",
+			"STORE_SKIP_VALIDATION": "true",
+			"STORE_NORMALIZE_KEYS":  "1",
+			"STORE_DEFAULT_TTL":     "24h",
+			"STORE_MAX_GET_SIZE":    "1048576",
+		}, Config{StoreType: LocalStore, SkipValidation: true, NormalizeKeys: true, DefaultTTL: 24 * time.Hour, MaxGetSize: 1 << 20}},
+		{"webdav", map[string]string{
+			"STORE_TYPE":       "webdav",
+			"WEBDAV_HOST":      "https://dav.example.com",
+			"WEBDAV_USER":      "alice",
+			"WEBDAV_PASS":      "secret",
+			"WEBDAV_AUTH_TYPE": "Digest",
+		}, Config{StoreType: WebDavStore, WebDavConfig: WebDavConfig{
+			WebDavHost: "https://dav.example.com", WebDavUser: "alice", WebDavPass: "secret", AuthType: WebDavAuthDigest,
+		}}},
+		{"webdav bearer", map[string]string{
+			"STORE_TYPE":       "webdav",
+			"WEBDAV_HOST":      "https://dav.example.com",
+			"WEBDAV_AUTH_TYPE": "bearer",
+			"WEBDAV_TOKEN":     "token",
+		}, Config{StoreType: WebDavStore, WebDavConfig: WebDavConfig{
+			WebDavHost: "https://dav.example.com", AuthType: WebDavAuthBearer, WebDavToken: "token",
+		}}},
+		{"s3 without keys", map[string]string{
+			"STORE_TYPE":          "s3",
+			"S3_BUCKET":           "bucket",
+			"S3_REGION":           "eu-west-1",
+			"S3_ENDPOINT":         "http://minio:9000",
+			"S3_FORCE_PATH_STYLE": "true",
+		}, Config{StoreType: S3Store, S3Config: S3Config{S3Bucket: "bucket", Config: aws.Config{
+			Region: aws.String("eu-west-1"), Endpoint: aws.String("http://minio:9000"), S3ForcePathStyle: aws.Bool(true),
+		}}}},
+		{"empty", map[string]string{"STORE_TYPE": "empty"}, Config{StoreType: EmptyStore}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			cfg, err := configFromEnv()
+			if err != nil {
+				t.Fatalf("configFromEnv: %v", err)
+			}
+			if !reflect.DeepEqual(cfg, tt.want) {
+				t.Errorf("Config = %+v, want %+v", cfg, tt.want)
+			}
+		})
+	}
+
+	t.Run("s3 static credentials", func(t *testing.T) {
+		setEnv(t, map[string]string{
+			"STORE_TYPE":           "s3",
+			"S3_BUCKET":            "bucket",
+			"S3_ACCESS_KEY_ID":     "AKID",
+			"S3_SECRET_ACCESS_KEY": "SECRET",
+		})
+		cfg, err := configFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.S3Config.Config.Credentials == nil {
+			t.Fatal("no credentials, want static ones from the environment")
+		}
+		v, err := cfg.S3Config.Config.Credentials.Get()
+		if err != nil || v.AccessKeyID != "AKID" || v.SecretAccessKey != "SECRET" {
+			t.Errorf("credentials = %s/%s, %v; want AKID/SECRET", v.AccessKeyID, v.SecretAccessKey, err)
+		}
+	})
+}
+
+func TestConfigFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		// want - части текста ошибки
+		want []string
+	}{
+		{"no store type", nil, []string{"STORE_TYPE"}},
+		{"unknown store type", map[string]string{"STORE_TYPE": "ftp"}, []string{`unknown STORE_TYPE "ftp"`}},
+		{"s3 without a bucket", map[string]string{"STORE_TYPE": "s3"}, []string{"required for s3", "S3_BUCKET"}},
+		{"s3 key without a secret", map[string]string{"STORE_TYPE": "s3", "S3_BUCKET": "bucket", "S3_ACCESS_KEY_ID": "AKID"}, []string{"S3_SECRET_ACCESS_KEY"}},
+		{"webdav lists every missing variable", map[string]string{"STORE_TYPE": "webdav", "WEBDAV_AUTH_TYPE": "bearer"}, []string{"required for webdav", "WEBDAV_HOST, WEBDAV_TOKEN"}},
+		{"unknown webdav auth", map[string]string{"STORE_TYPE": "webdav", "WEBDAV_HOST": "h", "WEBDAV_AUTH_TYPE": "ntlm"}, []string{"WEBDAV_AUTH_TYPE"}},
+		{"local root", map[string]string{"STORE_TYPE": "local", "LOCAL_ROOT": "/data"}, []string{"LOCAL_ROOT"}},
+		{"invalid bool", map[string]string{"STORE_TYPE": "local", "STORE_SKIP_VALIDATION": "maybe"}, []string{"STORE_SKIP_VALIDATION"}},
+		{"invalid ttl", map[string]string{"STORE_TYPE": "local", "STORE_DEFAULT_TTL": "day"}, []string{"STORE_DEFAULT_TTL"}},
+		{"invalid max get size", map[string]string{"STORE_TYPE": "local", "STORE_MAX_GET_SIZE": "1MB"}, []string{"STORE_MAX_GET_SIZE"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			if _, err := NewFromEnv(); err == nil {
+				t.Fatal("NewFromEnv succeeded, want an error")
+			} else {
+				for _, want := range tt.want {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error %q does not mention %q", err, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewFromEnvBackend(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"local", map[string]string{"STORE_TYPE": "local", "STORE_SKIP_VALIDATION": "true"}, LocalStore},
+		{"webdav", map[string]string{"STORE_TYPE": "webdav", "STORE_SKIP_VALIDATION": "true", "WEBDAV_HOST": "http://127.0.0.1:1"}, WebDavStore},
+		{"s3", map[string]string{"STORE_TYPE": "s3", "STORE_SKIP_VALIDATION": "true", "S3_BUCKET": "bucket", "S3_REGION": "us-east-1"}, S3Store},
+		{"empty", map[string]string{"STORE_TYPE": "empty"}, EmptyStore},
+		{"decorated local", map[string]string{"STORE_TYPE": "local", "STORE_SKIP_VALIDATION": "true", "STORE_NORMALIZE_KEYS": "true", "STORE_DEFAULT_TTL": "1h"}, LocalStore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			s, err := NewFromEnv()
+			if err != nil {
+				t.Fatalf("NewFromEnv: %v", err)
+			}
+			if got := s.Backend(); got != tt.want {
+				t.Errorf("Backend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/studio-b12/gowebdav"
+)
+
+// Code - класс ошибки хранилища, не зависящий от бэкенда
+type Code int
+
+const (
+	// CodeOK - ошибки нет
+	CodeOK Code = iota
+	// CodeInternal - ошибка, не попавшая ни в один из классов
+	CodeInternal
+	// CodeNotFound - файл не существует
+	CodeNotFound
+	// CodeConflict - файл уже существует или находится в неподходящем состоянии
+	CodeConflict
+	// CodePermission - нет доступа
+	CodePermission
+	// CodeUnavailable - хранилище временно недоступно, операцию можно повторить
+	CodeUnavailable
+	// CodeInvalidArgument - некорректный путь или метаданные
+	CodeInvalidArgument
+)
+
+func (c Code) String() string {
+	switch c {
+	case CodeOK:
+		return "ok"
+	case CodeNotFound:
+		return "not found"
+	case CodeConflict:
+		return "conflict"
+	case CodePermission:
+		return "permission"
+	case CodeUnavailable:
+		return "unavailable"
+	case CodeInvalidArgument:
+		return "invalid argument"
+	default:
+		return "internal"
+	}
+}
+
+// ErrorCode - определяет класс ошибки хранилища
+// err - ошибка любого хранилища, в том числе обернутая
+// Сначала проверяются ошибки пакета (ErrFileNotFound и т.п.), затем исходные
+// ошибки aws-sdk-go, gowebdav и os, поэтому вызывающему коду не нужно их импортировать.
+func ErrorCode(err error) Code {
+	if err == nil {
+		return CodeOK
+	}
+
+	switch {
+	case errors.Is(err, ErrFileNotFound), errors.Is(err, os.ErrNotExist):
+		return CodeNotFound
+	case errors.Is(err, ErrAlreadyExists), errors.Is(err, os.ErrExist),
+		errors.Is(err, ErrIsNotDir), errors.Is(err, ErrObjectArchived),
+		errors.Is(err, ErrParentNotExist):
+		return CodeConflict
+	case errors.Is(err, ErrPermission), errors.Is(err, os.ErrPermission):
+		return CodePermission
+	case errors.Is(err, ErrInsufficientStorage), errors.Is(err, ErrNotConfirmed),
+		errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return CodeUnavailable
+	case errors.Is(err, ErrInvalidKey), errors.Is(err, ErrMetadataTooLarge),
+		errors.Is(err, ErrRangeNotSatisfiable):
+		return CodeInvalidArgument
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case "NotFound", s3.ErrCodeNoSuchKey, s3.ErrCodeNoSuchBucket, s3.ErrCodeNoSuchUpload:
+			return CodeNotFound
+		case s3.ErrCodeInvalidObjectState:
+			return CodeConflict
+		case "InvalidRange":
+			return CodeInvalidArgument
+		case "AccessDenied", "Forbidden":
+			return CodePermission
+		case "SlowDown", "RequestTimeout", "ServiceUnavailable", request.ErrCodeRequestError:
+			return CodeUnavailable
+		}
+	}
+
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		return statusCode(reqErr.StatusCode())
+	}
+
+	var davErr gowebdav.StatusError
+	if errors.As(err, &davErr) {
+		return statusCode(davErr.Status)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return CodeUnavailable
+	}
+
+	return CodeInternal
+}
+
+// statusCode - класс ошибки по HTTP статусу ответа хранилища
+func statusCode(status int) Code {
+	switch status {
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict, http.StatusMethodNotAllowed, http.StatusPreconditionFailed:
+		return CodeConflict
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return CodePermission
+	case http.StatusBadRequest, http.StatusRequestedRangeNotSatisfiable:
+		return CodeInvalidArgument
+	case http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return CodeUnavailable
+	}
+
+	if status >= http.StatusInternalServerError {
+		return CodeUnavailable
+	}
+	return CodeInternal
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/studio-b12/gowebdav"
+)
+
+func TestErrorCodeClassifies(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, CodeOK},
+		{"unknown", errors.New("boom"), CodeInternal},
+
+		{"ErrFileNotFound wrapped", fmt.Errorf("read a.txt: %w", ErrFileNotFound), CodeNotFound},
+		{"ErrAlreadyExists", ErrAlreadyExists, CodeConflict},
+		{"ErrObjectArchived", ErrObjectArchived, CodeConflict},
+		{"ErrParentNotExist", ErrParentNotExist, CodeConflict},
+		{"ErrPermission", ErrPermission, CodePermission},
+		{"ErrInsufficientStorage", ErrInsufficientStorage, CodeUnavailable},
+		{"ErrInvalidKey", ErrInvalidKey, CodeInvalidArgument},
+		{"ErrRangeNotSatisfiable", ErrRangeNotSatisfiable, CodeInvalidArgument},
+		{"deadline", fmt.Errorf("get a.txt: %w", context.DeadlineExceeded), CodeUnavailable},
+		{"canceled", context.Canceled, CodeUnavailable},
+		// ошибки пакета проверяются раньше остальных
+		{"not found over deadline", fmt.Errorf("%w: %w", ErrFileNotFound, context.DeadlineExceeded), CodeNotFound},
+
+		{"os not exist", &os.PathError{Op: "open", Path: "a", Err: os.ErrNotExist}, CodeNotFound},
+		{"os exist", &os.LinkError{Op: "link", Old: "a", New: "b", Err: os.ErrExist}, CodeConflict},
+		{"os permission", &os.PathError{Op: "open", Path: "a", Err: os.ErrPermission}, CodePermission},
+
+		{"s3 NoSuchKey", awserr.New("NoSuchKey", "missing", nil), CodeNotFound},
+		{"s3 NoSuchBucket", awserr.New("NoSuchBucket", "missing", nil), CodeNotFound},
+		{"s3 InvalidObjectState", awserr.New("InvalidObjectState", "archived", nil), CodeConflict},
+		{"s3 AccessDenied", awserr.New("AccessDenied", "denied", nil), CodePermission},
+		{"s3 SlowDown", awserr.New("SlowDown", "slow down", nil), CodeUnavailable},
+		{"s3 InvalidRange", awserr.New("InvalidRange", "range", nil), CodeInvalidArgument},
+		{"s3 request error", awserr.New("RequestError", "send request failed", nil), CodeUnavailable},
+		// неизвестный код классифицируется по статусу ответа
+		{"s3 412", awserr.NewRequestFailure(awserr.New("PreconditionFailed", "", nil), http.StatusPreconditionFailed, "id"), CodeConflict},
+		{"s3 500", awserr.NewRequestFailure(awserr.New("InternalError", "", nil), http.StatusInternalServerError, "id"), CodeUnavailable},
+		{"s3 418", awserr.NewRequestFailure(awserr.New("Teapot", "", nil), http.StatusTeapot, "id"), CodeInternal},
+
+		{"webdav 404", gowebdav.NewPathError("Read", "a", http.StatusNotFound), CodeNotFound},
+		{"webdav 409", gowebdav.NewPathError("Write", "a", http.StatusConflict), CodeConflict},
+		{"webdav 401", gowebdav.NewPathError("Read", "a", http.StatusUnauthorized), CodePermission},
+		{"webdav 429", gowebdav.NewPathError("Read", "a", http.StatusTooManyRequests), CodeUnavailable},
+		{"webdav 502", gowebdav.NewPathError("Read", "a", http.StatusBadGateway), CodeUnavailable},
+		{"webdav 400", gowebdav.NewPathError("Read", "a", http.StatusBadRequest), CodeInvalidArgument},
+
+		{"network", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, CodeUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCode(tt.err); got != tt.want {
+				t.Errorf("ErrorCode(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorCodeBackends(t *testing.T) {
+	// s3Status - S3, отвечающий на любой запрос ошибкой code со статусом status
+	s3Status := func(t *testing.T, status int, code string) StoreIFace {
+		s, f := newFakeS3(t, S3Config{})
+		f.intercept = func(r *http.Request) *http.Response {
+			return fakeError(r, status, code)
+		}
+		return s
+	}
+	get := func(t *testing.T, s StoreIFace) error {
+		_, err := s.GetFile("dir/a.txt")
+		return err
+	}
+
+	tests := []struct {
+		name  string
+		store func(t *testing.T) StoreIFace
+		call  func(t *testing.T, s StoreIFace) error
+		want  Code
+	}{
+		{"s3 missing", func(t *testing.T) StoreIFace {
+			s, _ := newFakeS3(t, S3Config{})
+			return s
+		}, get, CodeNotFound},
+		{"s3 access denied", func(t *testing.T) StoreIFace {
+			return s3Status(t, http.StatusForbidden, "AccessDenied")
+		}, get, CodePermission},
+		{"s3 archived", func(t *testing.T) StoreIFace {
+			return s3Status(t, http.StatusForbidden, "InvalidObjectState")
+		}, get, CodeConflict},
+		{"s3 slow down", func(t *testing.T) StoreIFace {
+			return s3Status(t, http.StatusServiceUnavailable, "SlowDown")
+		}, get, CodeUnavailable},
+		{"s3 internal error", func(t *testing.T) StoreIFace {
+			return s3Status(t, http.StatusInternalServerError, "InternalError")
+		}, get, CodeUnavailable},
+		{"s3 unreachable", func(t *testing.T) StoreIFace {
+			return newTestS3(t, S3Config{}, roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			}))
+		}, get, CodeUnavailable},
+
+		{"webdav missing", func(t *testing.T) StoreIFace {
+			w, _ := newTestWebDavDir(t, WebDavConfig{})
+			return w
+		}, func(t *testing.T, s StoreIFace) error {
+			_, err := s.StatObject("a.txt")
+			return err
+		}, CodeNotFound},
+		{"webdav forbidden", func(t *testing.T) StoreIFace {
+			return newTestWebDav(t, WebDavConfig{SkipExistCheck: true}, statusHandler(http.StatusForbidden))
+		}, get, CodePermission},
+		{"webdav overwrite", func(t *testing.T) StoreIFace {
+			w, _ := newTestWebDavDir(t, WebDavConfig{})
+			for _, path := range []string{"a.txt", "b.txt"} {
+				if err := w.CreateFile(path, []byte(path), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return w
+		}, func(t *testing.T, s StoreIFace) error {
+			return s.MoveFileNoOverwrite("a.txt", "b.txt")
+		}, CodeConflict},
+		{"webdav bad gateway", func(t *testing.T) StoreIFace {
+			return newTestWebDav(t, WebDavConfig{SkipExistCheck: true}, statusHandler(http.StatusBadGateway))
+		}, get, CodeUnavailable},
+		{"webdav unreachable", func(t *testing.T) StoreIFace {
+			srv := httptest.NewServer(statusHandler(http.StatusOK))
+			srv.Close()
+			s, err := NewWebDav(WebDavConfig{WebDavHost: srv.URL, SkipValidation: true, SkipExistCheck: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}, get, CodeUnavailable},
+
+		{"local missing", func(t *testing.T) StoreIFace {
+			return newTestLocal(t, LocalConfig{})
+		}, func(t *testing.T, s StoreIFace) error {
+			_, err := s.StatObject(filepath.Join(t.TempDir(), "a.txt"))
+			return err
+		}, CodeNotFound},
+		{"local missing parent", func(t *testing.T) StoreIFace {
+			return newTestLocal(t, LocalConfig{})
+		}, func(t *testing.T, s StoreIFace) error {
+			return s.CreateFile(filepath.Join(t.TempDir(), "dir/a.txt"), []byte("a"), nil, nil)
+		}, CodeConflict},
+		{"local overwrite", func(t *testing.T) StoreIFace {
+			return newTestLocal(t, LocalConfig{})
+		}, func(t *testing.T, s StoreIFace) error {
+			dir := t.TempDir()
+			for _, name := range []string{"a.txt", "b.txt"} {
+				if err := s.CreateFile(filepath.Join(dir, name), []byte(name), nil, nil); err != nil {
+					return err
+				}
+			}
+			return s.MoveFileNoOverwrite(filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"))
+		}, CodeConflict},
+		{"local invalid key", func(t *testing.T) StoreIFace {
+			return WithKeyNormalization(newTestLocal(t, LocalConfig{}))
+		}, func(t *testing.T, s StoreIFace) error {
+			return s.CreateFile("../a.txt", []byte("a"), nil, nil)
+		}, CodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call(t, tt.store(t))
+			if got := ErrorCode(err); got != tt.want {
+				t.Errorf("ErrorCode(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strconv"
+)
+
+// S3PartSize - размер части multipart загрузки StreamToFile (5MB)
+const S3PartSize = 5 << 20
+
+// S3MultipartETag - ETag, который S3 присвоит объекту, загруженному multipart частями partSize
+// data - содержимое объекта
+// partSize - размер части, 0 и меньше - S3PartSize (как в StreamToFile)
+// формат S3 без кавычек: md5 от склеенных md5 частей и "-" с числом частей, например
+// "hello world" частями по 5 байт - "df349a9519959b17a605009540f4b31d-3"; сравнивается
+// с ObjectInfo.ETag. Объект,
+// загруженный одним PutObject (CreateFile), имеет ETag = md5 содержимого без суффикса.
+func S3MultipartETag(data []byte, partSize int64) string {
+	etag, _ := S3MultipartETagReader(bytes.NewReader(data), partSize)
+	return etag
+}
+
+// S3MultipartETagReader - ETag multipart загрузки, посчитанный по потоку
+// r - содержимое объекта, читается до конца
+// partSize - размер части, 0 и меньше - S3PartSize
+// в памяти держится только md5 частей, а не сами части
+func S3MultipartETagReader(r io.Reader, partSize int64) (string, error) {
+	if partSize <= 0 {
+		partSize = S3PartSize
+	}
+
+	h := newS3PartsHash(partSize)
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return h.etag(), nil
+}
+
+// s3PartsHash - ETag multipart загрузки, который считается по мере записи содержимого
+type s3PartsHash struct {
+	partSize int64
+	part     hash.Hash
+	// size - сколько байт записано в текущую часть
+	size  int64
+	sums  []byte
+	parts int
+}
+
+func newS3PartsHash(partSize int64) *s3PartsHash {
+	return &s3PartsHash{partSize: partSize, part: md5.New()}
+}
+
+func (h *s3PartsHash) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(int64(len(p)), h.partSize-h.size)
+		h.part.Write(p[:n])
+		h.size += n
+		p = p[n:]
+		if h.size == h.partSize {
+			h.sums = appendSum(h.sums, h.part)
+			h.parts, h.size = h.parts+1, 0
+		}
+	}
+	return written, nil
+}
+
+// etag - ETag записанного содержимого
+// пустой объект - одна пустая часть, иначе пустой остаток не считается частью
+func (h *s3PartsHash) etag() string {
+	sums, parts := h.sums, h.parts
+	if h.size > 0 || parts == 0 {
+		sums = h.part.Sum(append([]byte(nil), sums...))
+		parts++
+	}
+	total := md5.Sum(sums)
+	return hex.EncodeToString(total[:]) + "-" + strconv.Itoa(parts)
+}
+
+// appendSum - добавляет md5 части и сбрасывает хеш для следующей
+func appendSum(sums []byte, h hash.Hash) []byte {
+	sums = h.Sum(sums)
+	h.Reset()
+	return sums
+}
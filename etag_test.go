@@ -0,0 +1,40 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestS3MultipartETag(t *testing.T) {
+	// ожидаемые значения посчитаны независимо: md5 от склеенных md5 частей
+	tests := []struct {
+		name     string
+		data     []byte
+		partSize int64
+		want     string
+	}{
+		{"empty", nil, 5, "59adb24ef3cdbe0297f05b395827453f-1"},
+		{"single part", []byte("abc"), 5, "af5da9f45af7a300e3aded972f8ff687-1"},
+		{"exactly one part", []byte("abcde"), 5, "d4600bfd5be6d65a1d7158b29d2908db-1"},
+		{"exact multiple", []byte("abcdefghij"), 5, "8e18a6d3619b553c27c7028ea9067e05-2"},
+		{"remainder", []byte("abcdefgh"), 5, "05e0b3c3832fe9dd2df28984e528110e-2"},
+		{"doc example", []byte("hello world"), 5, "df349a9519959b17a605009540f4b31d-3"},
+		{"default part size", make([]byte, 2*S3PartSize), 0, "a7d414b9133d6483d9a1c4e04e856e3b-2"},
+		{"default part size with remainder", append(make([]byte, 2*S3PartSize), 'x'), 0, "5f833834c766704109091a6f716b150f-3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := S3MultipartETag(tt.data, tt.partSize); got != tt.want {
+				t.Errorf("S3MultipartETag = %s, want %s", got, tt.want)
+			}
+			got, err := S3MultipartETagReader(bytes.NewReader(tt.data), tt.partSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("S3MultipartETagReader = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
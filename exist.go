@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// existManyConcurrency - сколько путей ExistMany проверяет одновременно
+const existManyConcurrency = 16
+
+// existMany - проверяет существование путей параллельно с ограничением existManyConcurrency
+// exists - проверка одного пути, для отсутствующего файла false без ошибки
+// в результат попадают только пути, проверенные без ошибки; ошибки остальных
+// объединяются в возвращаемую ошибку с указанием пути
+func existMany(ctx context.Context, paths []string, exists func(context.Context, string) (bool, error)) (map[string]bool, error) {
+	result := make(map[string]bool, len(paths))
+	var errs []error
+	var mu sync.Mutex
+
+	sem := make(chan struct{}, existManyConcurrency)
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		// при свободном слоте select выбрал бы ветку случайно, поэтому отмена проверяется и после него
+		if ctx.Err() == nil {
+			select {
+			case <-ctx.Done():
+			case sem <- struct{}{}:
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ok, err := exists(ctx, path)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				return
+			}
+			result[path] = ok
+		}(path)
+	}
+	wg.Wait()
+
+	return result, errors.Join(errs...)
+}
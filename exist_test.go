@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExistMany(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			var paths []string
+			for i := 0; i < 40; i++ {
+				path := joinKey(dir, fmt.Sprintf("%02d.txt", i))
+				paths = append(paths, path)
+				// четные есть, нечетные отсутствуют
+				if i%2 == 0 {
+					if err := s.CreateFile(path, []byte("x"), nil, nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			empty := joinKey(dir, "empty.txt")
+			if err := s.CreateFile(empty, nil, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, empty, joinKey(dir, "missing/deep.txt"))
+
+			got, err := s.ExistMany(paths)
+			if err != nil {
+				t.Fatalf("ExistMany: %v", err)
+			}
+			if len(got) != len(paths) {
+				t.Fatalf("ExistMany returned %d paths, want %d", len(got), len(paths))
+			}
+			// результат совпадает с IsExist, в том числе для пустого файла
+			for _, path := range paths {
+				if want := s.IsExist(path); got[path] != want {
+					t.Errorf("ExistMany[%s] = %v, IsExist = %v", path, got[path], want)
+				}
+			}
+
+			if got, err := s.ExistMany(nil); err != nil || len(got) != 0 {
+				t.Errorf("ExistMany(nil) = %v, %v; want an empty map", got, err)
+			}
+		})
+	}
+}
+
+func TestExistManyErrors(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	f.put("a.txt", []byte("a"), nil)
+	f.intercept = func(r *http.Request) *http.Response {
+		if strings.HasSuffix(r.URL.Path, "/denied.txt") {
+			return fakeError(r, http.StatusForbidden, "AccessDenied")
+		}
+		return nil
+	}
+
+	got, err := s.ExistMany([]string{"a.txt", "b.txt", "denied.txt"})
+	if !got["a.txt"] || got["b.txt"] {
+		t.Errorf("ExistMany = %v, want a.txt present and b.txt absent", got)
+	}
+	// ошибка проверки не выдается за отсутствие файла
+	if _, ok := got["denied.txt"]; ok {
+		t.Errorf("ExistMany reported denied.txt as %v, want it left out", got["denied.txt"])
+	}
+	if err == nil || !strings.Contains(err.Error(), "denied.txt") || ErrorCode(err) != CodePermission {
+		t.Errorf("ExistMany error = %v, want the AccessDenied of denied.txt", err)
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		got, err := s.ExistManyWithContext(ctx, []string{"a.txt", "b.txt"})
+		if len(got) != 0 || !errors.Is(err, context.Canceled) {
+			t.Errorf("ExistMany = %v, %v; want nothing checked and context.Canceled", got, err)
+		}
+	})
+}
+
+func TestExistManyConcurrency(t *testing.T) {
+	var active, maxActive atomic.Int32
+	paths := make([]string, 100)
+	for i := range paths {
+		paths[i] = fmt.Sprint(i)
+	}
+
+	got, err := existMany(context.Background(), paths, func(ctx context.Context, path string) (bool, error) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for m := maxActive.Load(); n > m && !maxActive.CompareAndSwap(m, n); m = maxActive.Load() {
+		}
+		time.Sleep(2 * time.Millisecond)
+		return true, nil
+	})
+	if err != nil || len(got) != len(paths) {
+		t.Fatalf("existMany = %d paths, %v; want all %d", len(got), err, len(paths))
+	}
+	if m := maxActive.Load(); m > existManyConcurrency || m < 2 {
+		t.Errorf("at most %d checks at once, want parallel checks bounded by %d", m, existManyConcurrency)
+	}
+}
+
+// BenchmarkExistMany - ExistMany против последовательных IsExist при задержке 1ms на запрос
+func BenchmarkExistMany(b *testing.B) {
+	s, f := newFakeS3(b, S3Config{})
+	paths := make([]string, 64)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%02d.txt", i)
+		if i%2 == 0 {
+			f.put(paths[i], []byte("x"), nil)
+		}
+	}
+	f.intercept = func(r *http.Request) *http.Response {
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	b.Run("sequential IsExist", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, path := range paths {
+				s.IsExist(path)
+			}
+		}
+	})
+	b.Run("ExistMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.ExistMany(paths); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// metaExpires - ключ мета-файла, под которым Local и WebDav хранят время жизни (TTL)
+// в метаданных, возвращаемых Stat, этого ключа нет
+const metaExpires = "Expires"
+
+// withExpires - копия meta со временем жизни под служебным ключом
+// при ttl == nil meta возвращается как есть
+func withExpires(meta map[string]string, ttl *time.Time) map[string]string {
+	if ttl == nil {
+		return meta
+	}
+	return withMetaValue(meta, metaExpires, ttl.UTC().Format(time.RFC3339Nano))
+}
+
+// withMetaValue - копия meta с value под ключом key; при пустом value meta возвращается как есть
+func withMetaValue(meta map[string]string, key, value string) map[string]string {
+	if value == "" {
+		return meta
+	}
+
+	m := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		m[k] = v
+	}
+	m[key] = value
+	return m
+}
+
+// sidecarExpires - время жизни из мета-файла; нулевое время - без срока
+func sidecarExpires(meta map[string]string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, meta[metaExpires])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// startExpiryScavenger - сразу и затем каждые interval удаляет под root файлы,
+// время жизни которых (ObjectInfo.Expires) прошло; останавливается с отменой ctx
+// ошибка первого прохода (например, root не существует) возвращается,
+// ошибки следующих проходов пропускаются до следующего тика
+func startExpiryScavenger(ctx context.Context, s StoreIFace, root string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid scavenger interval %s", interval)
+	}
+	if err := sweepExpired(ctx, s, root, time.Now()); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				sweepExpired(ctx, s, root, now)
+			}
+		}
+	}()
+	return nil
+}
+
+// sweepExpired - один проход: удаляет файлы под root, время жизни которых истекло к now
+// файл удаляется RemoveFileIfMatch по ETag, прочитанному вместе со сроком, поэтому
+// файл, перезаписанный во время прохода, не удаляется; ошибки отдельных файлов
+// не прерывают проход и объединяются в результат
+func sweepExpired(ctx context.Context, s StoreIFace, root string, now time.Time) error {
+	var errs []error
+	err := walkDir(ctx, s, root, func(rel string, _ os.FileInfo) error {
+		path := joinKey(root, rel)
+		obj, err := s.StatObjectWithContext(ctx, path)
+		if err != nil {
+			if !errors.Is(err, ErrFileNotFound) {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			}
+			return nil
+		}
+		if obj.Expires.IsZero() || obj.Expires.After(now) {
+			return nil
+		}
+
+		// ErrConflict - файл перезаписан после StatObject и больше не истек
+		if _, err := s.RemoveFileIfMatchWithContext(ctx, path, obj.ETag); err != nil && !errors.Is(err, ErrConflict) {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+		}
+		return nil
+	})
+	return errors.Join(append([]error{err}, errs...)...)
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalExpiryScavenger(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	now := time.Now()
+	past, soon, later := now.Add(-time.Minute), now.Add(100*time.Millisecond), now.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		file string
+		ttl  *time.Time
+		// removed - удаляется ли файл, пока работает сборщик
+		removed bool
+	}{
+		{"already expired", "past.txt", &past, true},
+		{"expires while running", "soon.txt", &soon, true},
+		{"nested, expires while running", "dir/soon.txt", &soon, true},
+		{"expires later", "later.txt", &later, false},
+		{"no ttl", "forever.txt", nil, false},
+	}
+
+	root := t.TempDir()
+	s := newTestLocal(t, LocalConfig{CreateParents: true})
+	for _, tt := range tests {
+		if err := s.CreateFile(filepath.Join(root, tt.file), []byte("data"), tt.ttl, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.StartExpiryScavenger(ctx, root, interval); err != nil {
+		t.Fatalf("StartExpiryScavenger: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "past.txt")); !os.IsNotExist(err) {
+		t.Errorf("expired file survived the first pass: %v", err)
+	}
+
+	time.Sleep(time.Until(soon) + 10*interval)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(root, tt.file)
+			_, err := os.Stat(path)
+			if removed := os.IsNotExist(err); removed != tt.removed {
+				t.Errorf("removed = %v, want %v (stat: %v)", removed, tt.removed, err)
+			}
+			if tt.removed {
+				if _, err := os.Stat(path + META_PREFIX); !os.IsNotExist(err) {
+					t.Errorf("sidecar of a removed file is left: %v", err)
+				}
+			}
+		})
+	}
+
+	t.Run("stops with the context", func(t *testing.T) {
+		cancel()
+		time.Sleep(2 * interval)
+
+		path := filepath.Join(root, "after-cancel.txt")
+		if err := s.CreateFile(path, []byte("data"), &past, nil); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * interval)
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("file removed after the scavenger was stopped: %v", err)
+		}
+	})
+}
+
+func TestLocalExpiryScavengerErrors(t *testing.T) {
+	s := newTestLocal(t, LocalConfig{})
+	tests := []struct {
+		name     string
+		root     string
+		interval time.Duration
+	}{
+		{"zero interval", t.TempDir(), 0},
+		{"missing root", filepath.Join(t.TempDir(), "missing"), time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := s.StartExpiryScavenger(ctx, tt.root, tt.interval); err == nil {
+				t.Error("StartExpiryScavenger succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RemoveGlobOptions - параметры RemoveGlob
+// Rate - сколько файлов удалять в секунду, 0 - без ограничения
+// DryRun - только вернуть подходящие пути, ничего не удаляя
+type RemoveGlobOptions struct {
+	Rate   int
+	DryRun bool
+}
+
+// RemoveGlob - удаляет файлы, пути которых подходят под шаблон
+// s - хранилище
+// pattern - шаблон path.Match ("logs/2024-*/*.tmp"); "*" и "?" не выходят за "/"
+// opts - параметры
+// обходится только директория до первого спецсимвола шаблона со всеми поддиректориями;
+// ошибки удаления отдельных файлов не прерывают удаление и объединяются в возвращаемую ошибку
+// []string - удаленные пути по возрастанию (при DryRun - подходящие под шаблон)
+func RemoveGlob(s StoreIFace, pattern string, opts RemoveGlobOptions) ([]string, error) {
+	return RemoveGlobWithContext(context.Background(), s, pattern, opts)
+}
+
+// RemoveGlobWithContext - удаляет файлы, пути которых подходят под шаблон
+// s - хранилище
+// pattern - шаблон path.Match
+// opts - параметры
+// контекст проверяется перед каждым удалением, при отмене оставшиеся файлы не удаляются
+func RemoveGlobWithContext(ctx context.Context, s StoreIFace, pattern string, opts RemoveGlobOptions) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	base := globBase(pattern)
+	var matched []string
+	err := walkDir(ctx, s, base, func(rel string, info os.FileInfo) error {
+		key := joinKey(base, rel)
+		if ok, _ := path.Match(pattern, key); ok {
+			matched = append(matched, key)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Strings(matched)
+	if opts.DryRun {
+		return matched, nil
+	}
+
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Second / time.Duration(opts.Rate)
+	}
+
+	var removed []string
+	var errs []error
+	for i, key := range matched {
+		if i > 0 && interval > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(interval):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		if err := s.RemoveFileWithContext(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		removed = append(removed, key)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// globBase - директория шаблона до первого спецсимвола
+func globBase(pattern string) string {
+	i := strings.IndexAny(pattern, `*?[\`)
+	if i < 0 {
+		i = len(pattern)
+	}
+	j := strings.LastIndex(pattern[:i], "/")
+	if j < 0 {
+		return ""
+	}
+	return pattern[:j]
+}
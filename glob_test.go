@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// removeHook - хранилище, вызывающее hook перед каждым RemoveFile
+type removeHook struct {
+	StoreIFace
+	hook func(path string) error
+}
+
+func (r *removeHook) RemoveFileWithContext(ctx context.Context, path string) error {
+	if err := r.hook(path); err != nil {
+		return err
+	}
+	return r.StoreIFace.RemoveFileWithContext(ctx, path)
+}
+
+// newGlobTree - хранилище с файлами tree и корень путей
+func newGlobTree(t *testing.T, backend string, tree []string) (StoreIFace, string) {
+	t.Helper()
+	var s StoreIFace
+	var root string
+	for _, b := range testBackends {
+		if b.name == backend {
+			s, root = b.store(t)
+		}
+	}
+	if backend == "local" {
+		s = newTestLocal(t, LocalConfig{CreateParents: true})
+	}
+	for _, name := range tree {
+		key := joinKey(root, name)
+		if backend == "webdav" {
+			if err := s.MkdirAll(key[:strings.LastIndex(key, "/")]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.CreateFile(key, []byte(name), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s, root
+}
+
+var globTree = []string{
+	"logs/2024-01/a.tmp",
+	"logs/2024-01/b.log",
+	"logs/2024-02/c.tmp",
+	"logs/2024-02/deep/d.tmp",
+	"logs/2023/e.tmp",
+	"other/f.tmp",
+}
+
+func TestRemoveGlob(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, root := newGlobTree(t, b.name, globTree)
+			pattern := joinKey(root, "logs/2024-*/*.tmp")
+			want := []string{joinKey(root, "logs/2024-01/a.tmp"), joinKey(root, "logs/2024-02/c.tmp")}
+
+			// DryRun только перечисляет подходящие пути
+			got, err := RemoveGlob(s, pattern, RemoveGlobOptions{DryRun: true})
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Fatalf("dry run = %v, %v; want %v", got, err, want)
+			}
+			for _, name := range globTree {
+				if !s.IsExist(joinKey(root, name)) {
+					t.Errorf("%s removed by a dry run", name)
+				}
+			}
+
+			got, err = RemoveGlob(s, pattern, RemoveGlobOptions{})
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Fatalf("RemoveGlob = %v, %v; want %v", got, err, want)
+			}
+			for _, name := range globTree {
+				key := joinKey(root, name)
+				removed := key == want[0] || key == want[1]
+				if s.IsExist(key) == removed {
+					t.Errorf("%s exists = %v after RemoveGlob, want %v", name, !removed, !removed)
+				}
+			}
+
+			// повторный запуск ничего не находит
+			if got, err := RemoveGlob(s, pattern, RemoveGlobOptions{}); err != nil || len(got) != 0 {
+				t.Errorf("second RemoveGlob = %v, %v; want nothing", got, err)
+			}
+		})
+	}
+}
+
+func TestRemoveGlobArguments(t *testing.T) {
+	s, _ := newFakeS3(t, S3Config{})
+	if _, err := RemoveGlob(s, "logs/[", RemoveGlobOptions{}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("malformed pattern: %v, want ErrInvalidKey", err)
+	}
+
+	local := newTestLocal(t, LocalConfig{})
+	if got, err := RemoveGlob(local, joinKey(t.TempDir(), "missing/*.tmp"), RemoveGlobOptions{}); err != nil || got != nil {
+		t.Errorf("missing base dir = %v, %v; want nothing", got, err)
+	}
+}
+
+func TestRemoveGlobRate(t *testing.T) {
+	raw, _ := newGlobTree(t, "s3", []string{"a.tmp", "b.tmp", "c.tmp", "d.tmp"})
+	var mu sync.Mutex
+	var at []time.Time
+	s := &removeHook{StoreIFace: raw, hook: func(string) error {
+		mu.Lock()
+		at = append(at, time.Now())
+		mu.Unlock()
+		return nil
+	}}
+
+	got, err := RemoveGlob(s, "*.tmp", RemoveGlobOptions{Rate: 20})
+	if err != nil || len(got) != 4 {
+		t.Fatalf("RemoveGlob = %v, %v; want 4 files", got, err)
+	}
+	// 20 файлов в секунду - не чаще одного за 50ms
+	for i := 1; i < len(at); i++ {
+		if gap := at[i].Sub(at[i-1]); gap < 45*time.Millisecond {
+			t.Errorf("delete %d came %v after the previous one, want at least 50ms", i, gap)
+		}
+	}
+}
+
+func TestRemoveGlobCancel(t *testing.T) {
+	tree := []string{"a.tmp", "b.tmp", "c.tmp", "d.tmp"}
+
+	t.Run("between deletes", func(t *testing.T) {
+		raw, _ := newGlobTree(t, "s3", tree)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		calls := 0
+		s := &removeHook{StoreIFace: raw, hook: func(string) error {
+			calls++
+			if calls == 2 {
+				cancel()
+			}
+			return nil
+		}}
+
+		got, err := RemoveGlobWithContext(ctx, s, "*.tmp", RemoveGlobOptions{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+		if calls != 2 {
+			t.Errorf("%d deletes attempted, want none after the cancellation", calls)
+		}
+		for _, name := range tree[2:] {
+			if !raw.IsExist(name) {
+				t.Errorf("%s removed after the cancellation", name)
+			}
+		}
+		// удаление, начатое до отмены, на отмененном контексте может не завершиться
+		for _, key := range got {
+			if raw.IsExist(key) {
+				t.Errorf("%s reported removed but exists", key)
+			}
+		}
+	})
+
+	t.Run("during the rate wait", func(t *testing.T) {
+		raw, _ := newGlobTree(t, "s3", tree)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		got, err := RemoveGlobWithContext(ctx, raw, "*.tmp", RemoveGlobOptions{Rate: 1})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want context.DeadlineExceeded", err)
+		}
+		if !reflect.DeepEqual(got, []string{"a.tmp"}) {
+			t.Errorf("removed %v, want only the first file", got)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("returned after %v, want the wait interrupted by the deadline", elapsed)
+		}
+	})
+}
+
+func TestRemoveGlobErrors(t *testing.T) {
+	raw, _ := newGlobTree(t, "s3", []string{"a.tmp", "b.tmp", "c.tmp", "d.tmp"})
+	s := &removeHook{StoreIFace: raw, hook: func(path string) error {
+		if path == "b.tmp" || path == "d.tmp" {
+			return ErrPermission
+		}
+		return nil
+	}}
+
+	got, err := RemoveGlob(s, "*.tmp", RemoveGlobOptions{})
+	// ошибка одного файла не прерывает удаление остальных
+	if want := []string{"a.tmp", "c.tmp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed %v, want %v", got, want)
+	}
+	if !errors.Is(err, ErrPermission) || !strings.Contains(err.Error(), "b.tmp: ") || !strings.Contains(err.Error(), "d.tmp: ") {
+		t.Errorf("error = %v, want ErrPermission naming b.tmp and d.tmp", err)
+	}
+}
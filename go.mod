@@ -3,11 +3,21 @@ module github.com/Citix-ltd/go-store
 go 1.22.0
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/aws/aws-sdk-go v1.54.19
+	github.com/klauspost/compress v1.17.9
 	github.com/studio-b12/gowebdav v0.9.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+	golang.org/x/net v0.11.0
 )
 
 require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
-	golang.org/x/net v0.11.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
@@ -3,13 +3,17 @@ package store
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 )
 
 const (
@@ -22,8 +26,21 @@ const (
 )
 
 var (
-	ErrFileNotFound = errors.New("file not found")
-	ErrIsNotDir     = errors.New("is not a directory")
+	ErrFileNotFound        = errors.New("file not found")
+	ErrIsNotDir            = errors.New("is not a directory")
+	ErrPermission          = errors.New("permission denied")
+	ErrAlreadyExists       = errors.New("file already exists")
+	ErrInsufficientStorage = errors.New("insufficient storage")
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrMetadataTooLarge    = errors.New("metadata too large")
+	ErrInvalidKey          = errors.New("invalid key")
+	ErrObjectArchived      = errors.New("object is archived")
+	ErrParentNotExist      = errors.New("parent directory does not exist")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	ErrNotConfirmed        = errors.New("write is not confirmed")
+	ErrFileTooLarge        = errors.New("file too large")
+	ErrLockLost            = errors.New("lock lost")
+	ErrConflict            = errors.New("conflict")
 )
 
 type StoreConfigIFace interface {
@@ -32,45 +49,268 @@ type StoreConfigIFace interface {
 
 type StoreIFace interface {
 	IsExist(string) bool
+	ExistMany([]string) (map[string]bool, error)
 	CreateFile(string, []byte, *time.Time, map[string]string) error
+	CreateFileWithOptions(string, []byte, PutOptions) error
+	Reserve(string) error
 	CopyFile(string, string, *time.Time, map[string]string) error
+	CopyFileWithOptions(string, string, PutOptions) error
+	CopyFileIfChanged(string, string) (bool, error)
+	ExtractRange(string, int64, int64, string) error
+	Truncate(string, int64) error
 	MoveFile(string, string) error
+	MoveFileNoOverwrite(string, string) error
+	SwapFiles(string, string) error
+	Rotate(string) (string, error)
+	CopyMeta(string, string) error
+	Symlink(string, string) error
 	StreamToFile(io.Reader, string, *time.Time) error
+	StreamToFileN(io.Reader, string, *time.Time) (int64, error)
+	FileWriter(string, *time.Time, map[string]string) (io.WriteCloser, error)
 	GetFile(string) ([]byte, error)
 	GetFilePartially(string, int64, int64) ([]byte, error)
+	GetFileIfModifiedSince(string, time.Time) ([]byte, bool, error)
+	Peek(string, int) ([]byte, error)
+	GetFileVerified(string) ([]byte, error)
 	FileReader(string, int64, int64) (io.ReadCloser, error)
+	MultiReader([]string) (io.ReadCloser, error)
+	WriteTo(string, io.Writer) (int64, error)
 	RemoveFile(string) error
+	RemoveFileIfMatch(string, string) (bool, error)
 	CreateJsonFile(string, interface{}, *time.Time, map[string]string) error
 	ClearDir(string) error
 	GetJsonFile(string, interface{}) error
+	GetJsonMap(string) (map[string]interface{}, error)
 	Stat(string) (os.FileInfo, map[string]string, error)
+	StatLite(string) (os.FileInfo, error)
+	Lstat(string) (os.FileInfo, map[string]string, error)
+	StatObject(string) (ObjectInfo, error)
+	PublicURL(string) (string, error)
+	Latest(string) (os.FileInfo, error)
+	IsEmpty(string) (bool, error)
+	ListModifiedSince(string, time.Time) ([]os.FileInfo, error)
+	ListDirDepth(string, int) ([]os.FileInfo, error)
+	ListDirChan(string) <-chan DirEntry
+	ArchiveDir(string, io.Writer, ArchiveFormat) error
+	ExtractArchive(io.Reader, string, ArchiveFormat) error
+	Manifest(string, ChecksumAlgo) ([]ManifestEntry, error)
+	BlockChecksums(string, int) ([]BlockHash, error)
+	ListMeta(string) (map[string]map[string]string, error)
 	MkdirAll(string) error
+	Backend() string
 	// with ctx
+	ExistManyWithContext(context.Context, []string) (map[string]bool, error)
 	CreateFileWithContext(context.Context, string, []byte, *time.Time, map[string]string) error
+	CreateFileWithOptionsWithContext(context.Context, string, []byte, PutOptions) error
+	ReserveWithContext(context.Context, string) error
 	CopyFileWithContext(context.Context, string, string, *time.Time, map[string]string) error
+	CopyFileWithOptionsWithContext(context.Context, string, string, PutOptions) error
+	CopyFileIfChangedWithContext(context.Context, string, string) (bool, error)
+	ExtractRangeWithContext(context.Context, string, int64, int64, string) error
+	TruncateWithContext(context.Context, string, int64) error
 	MoveFileWithContext(context.Context, string, string) error
+	MoveFileNoOverwriteWithContext(context.Context, string, string) error
+	SwapFilesWithContext(context.Context, string, string) error
+	RotateWithContext(context.Context, string) (string, error)
+	CopyMetaWithContext(context.Context, string, string) error
+	SymlinkWithContext(context.Context, string, string) error
 	StreamToFileWithContext(context.Context, io.Reader, string, *time.Time) error
+	StreamToFileNWithContext(context.Context, io.Reader, string, *time.Time) (int64, error)
+	FileWriterWithContext(context.Context, string, *time.Time, map[string]string) (io.WriteCloser, error)
 	GetFileWithContext(context.Context, string) ([]byte, error)
 	GetFilePartiallyWithContext(context.Context, string, int64, int64) ([]byte, error)
+	GetFileIfModifiedSinceWithContext(context.Context, string, time.Time) ([]byte, bool, error)
+	PeekWithContext(context.Context, string, int) ([]byte, error)
+	GetFileVerifiedWithContext(context.Context, string) ([]byte, error)
 	FileReaderWithContext(context.Context, string, int64, int64) (io.ReadCloser, error)
+	MultiReaderWithContext(context.Context, []string) (io.ReadCloser, error)
+	WriteToWithContext(context.Context, string, io.Writer) (int64, error)
 	RemoveFileWithContext(context.Context, string) error
+	RemoveFileIfMatchWithContext(context.Context, string, string) (bool, error)
 	CreateJsonFileWithContext(context.Context, string, interface{}, *time.Time, map[string]string) error
 	ClearDirWithContext(context.Context, string) error
 	GetJsonFileWithContext(context.Context, string, interface{}) error
+	GetJsonMapWithContext(context.Context, string) (map[string]interface{}, error)
 	StatWithContext(context.Context, string) (os.FileInfo, map[string]string, error)
+	StatLiteWithContext(context.Context, string) (os.FileInfo, error)
+	LstatWithContext(context.Context, string) (os.FileInfo, map[string]string, error)
+	StatObjectWithContext(context.Context, string) (ObjectInfo, error)
+	LatestWithContext(context.Context, string) (os.FileInfo, error)
+	IsEmptyWithContext(context.Context, string) (bool, error)
+	ListModifiedSinceWithContext(context.Context, string, time.Time) ([]os.FileInfo, error)
+	ListDirDepthWithContext(context.Context, string, int) ([]os.FileInfo, error)
+	ListDirChanWithContext(context.Context, string) <-chan DirEntry
+	ArchiveDirWithContext(context.Context, string, io.Writer, ArchiveFormat) error
+	ExtractArchiveWithContext(context.Context, io.Reader, string, ArchiveFormat) error
+	ManifestWithContext(context.Context, string, ChecksumAlgo) ([]ManifestEntry, error)
+	BlockChecksumsWithContext(context.Context, string, int) ([]BlockHash, error)
+	ListMetaWithContext(context.Context, string) (map[string]map[string]string, error)
 	MkdirAllWithContext(context.Context, string) error
 }
 
+// ACL - видимость файла
+type ACL string
+
+const (
+	ACLDefault    ACL = ""
+	ACLPrivate    ACL = "private"
+	ACLPublicRead ACL = "public-read"
+)
+
+// fileMode - права локального файла, соответствующие ACL
+func (a ACL) fileMode() os.FileMode {
+	switch a {
+	case ACLPublicRead:
+		return 0644
+	case ACLPrivate:
+		return 0600
+	default:
+		return perm
+	}
+}
+
+// OverwriteMode - поведение CreateFileWithOptions, если файл уже существует
+type OverwriteMode int
+
+const (
+	// OverwriteAllow - перезаписать файл (по умолчанию)
+	OverwriteAllow OverwriteMode = iota
+	// OverwriteFail - не записывать и вернуть ErrAlreadyExists
+	OverwriteFail
+	// OverwriteSkip - не записывать и вернуть nil
+	OverwriteSkip
+)
+
+// result - ошибка записи с учетом режима: при OverwriteSkip занятый путь не ошибка
+func (m OverwriteMode) result(err error) error {
+	if m == OverwriteSkip && errors.Is(err, ErrAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// PutOptions - параметры записи файла
+// TTL - время жизни
+// Meta - метаданные
+// ACL - видимость файла: в S3 передается как ACL объекта,
+// в Local переводится в права файла, в WebDav не поддерживается
+// CacheControl - заголовок Cache-Control: в S3 задается объекту,
+// в Local и WebDav хранится в мета-файле
+// ContentType - заголовок Content-Type; пустой определяется ContentType из конфигурации
+// хранилища. В S3 задается объекту, в Local и WebDav хранится в мета-файле, если
+// отличается от типа по расширению. Учитывается при записи содержимого (CreateFile)
+// Lock - блокировка объекта (Object Lock), только S3; бакет должен быть создан с Object Lock
+// Overwrite - что делать, если файл уже существует; проверка и запись атомарны
+// (S3 - If-None-Match, Local - O_EXCL или жесткая ссылка, WebDav - MOVE без перезаписи).
+// Без перезаписи мета-файл пишется после файла. CopyFileWithOptions режим не учитывает
+type PutOptions struct {
+	TTL          *time.Time
+	Meta         map[string]string
+	ACL          ACL
+	CacheControl string
+	ContentType  string
+	Lock         *ObjectLock
+	Overwrite    OverwriteMode
+}
+
+// ObjectLock - параметры блокировки объекта S3 (WORM)
+// Mode - режим хранения: s3.ObjectLockModeGovernance или s3.ObjectLockModeCompliance,
+// пустая строка - без срока хранения
+// RetainUntil - до какого момента объект нельзя изменить или удалить
+// LegalHold - бессрочная блокировка до ее явного снятия
+type ObjectLock struct {
+	Mode        string
+	RetainUntil time.Time
+	LegalHold   bool
+}
+
+// metaCacheControl - ключ мета-файла, под которым Local и WebDav хранят CacheControl
+// в метаданных, возвращаемых Stat, этого ключа нет
+const metaCacheControl = "Cache-Control"
+
+// sidecarMeta - метаданные для мета-файла: пользовательские, CacheControl и TTL
+func (o PutOptions) sidecarMeta() map[string]string {
+	return withExpires(withCacheControl(o.Meta, o.CacheControl), o.TTL)
+}
+
+// withCacheControl - копия meta с CacheControl под служебным ключом
+// при пустом cacheControl meta возвращается как есть
+func withCacheControl(meta map[string]string, cacheControl string) map[string]string {
+	return withMetaValue(meta, metaCacheControl, cacheControl)
+}
+
+// splitCacheControl - отделяет CacheControl от пользовательских метаданных мета-файла
+// служебные Content-Type и Expires из пользовательских метаданных тоже убираются
+func splitCacheControl(meta map[string]string) (map[string]string, string) {
+	delete(meta, metaContentType)
+	delete(meta, metaExpires)
+	cacheControl, ok := meta[metaCacheControl]
+	if !ok {
+		return meta, ""
+	}
+
+	delete(meta, metaCacheControl)
+	return meta, cacheControl
+}
+
 type Config struct {
 	StoreType    string
 	EmptyConfig  EmptyConfig
 	LocalConfig  LocalConfig
 	WebDavConfig WebDavConfig
 	S3Config     S3Config
+	// SkipValidation - не проверять доступность хранилища при создании
+	SkipValidation bool
+	// NormalizeKeys - нормализовать пути перед каждой операцией (см. NormalizeKey)
+	NormalizeKeys bool
+	// DefaultTTL - время жизни файлов, записанных без ttl (см. WithDefaultTTL); 0 - не задано
+	DefaultTTL time.Duration
+	// MaxGetSize - наибольший размер файла, читаемого в память целиком (см. WithMaxGetSize);
+	// 0 - без ограничения
+	MaxGetSize int64
 }
 
 type S3Config struct {
 	S3Bucket string
+	// UseAccelerate - использовать S3 Transfer Acceleration
+	UseAccelerate bool
+	// UseDualStack - использовать dual-stack (IPv4/IPv6) эндпоинты
+	UseDualStack bool
+	// AutoDecompress - распаковывать в GetFile объекты с Content-Encoding: gzip
+	AutoDecompress bool
+	// SkipValidation - не проверять доступность бакета при создании (HeadBucket)
+	SkipValidation bool
+	// PartRetries - сколько раз повторять неудавшуюся часть multipart загрузки
+	// в StreamToFile, прежде чем прервать всю загрузку; 0 - не повторять
+	PartRetries int
+	// PartRetryBackoff - пауза перед первым повтором части, далее удваивается;
+	// по умолчанию 500ms
+	PartRetryBackoff time.Duration
+	// PublicBaseURL - базовый URL для PublicURL (CDN, статический хостинг) вместо адреса бакета
+	PublicBaseURL string
+	// ContentType - как определять Content-Type файла, записанного без явного
+	// PutOptions.ContentType: ContentTypeByExtension (по умолчанию), ContentTypeSniff
+	// или своя функция; при потоковой записи функция получает первую часть (до 5MB)
+	ContentType ContentTypeResolver
+	// ReadBufferSize - размер буфера FileReader, чтобы мелкие чтения не уходили в сеть
+	// по одному; 0 - 64KB, отрицательное значение - без буфера
+	ReadBufferSize int
+	// MaxRetries - сколько раз SDK повторяет неудавшийся запрос; nil - по умолчанию SDK (3),
+	// aws.Int(0) - не повторять. Повторы SDK - единственный уровень повторов в пакете:
+	// декоратора повторов поверх StoreIFace нет, поэтому при повторах на стороне вызывающего
+	// кода стоит задать 0, чтобы число попыток не перемножалось. Переход в регион бакета по
+	// ответу 301 расходует один повтор: при 0 первый запрос завершается ошибкой, а регион
+	// применяется со следующего
+	MaxRetries *int
+	// Retryer - своя политика повторов запросов SDK; если задана, MaxRetries не используется
+	Retryer request.Retryer
+	// MoveWaitAttempts - сколько раз MoveFile проверяет, что копия появилась, а исходный
+	// объект исчез; 0 - по умолчанию SDK (20)
+	MoveWaitAttempts int
+	// MoveWaitDelay - пауза между проверками MoveFile; 0 - по умолчанию SDK (5s)
+	MoveWaitDelay time.Duration
+	// SkipMoveWait - не проверять результат MoveFile, полагаясь на строгую согласованность S3
+	SkipMoveWait bool
 	aws.Config
 }
 
@@ -78,12 +318,199 @@ type WebDavConfig struct {
 	WebDavHost string
 	WebDavUser string
 	WebDavPass string
+	// AuthType - способ авторизации; по умолчанию выбирается по ответу сервера
+	// (Basic или Digest с WebDavUser/WebDavPass)
+	AuthType WebDavAuthType
+	// WebDavToken - токен для WebDavAuthBearer
+	WebDavToken string
+	// SkipExistCheck - не проверять существование файла перед чтением,
+	// отсутствующий файл определяется по ответу 404 и возвращается ErrFileNotFound
+	SkipExistCheck bool
+	// SkipValidation - не проверять доступность сервера при создании (PROPFIND корня)
+	SkipValidation bool
+	// AtomicWrites - писать файл и мета-файл во временный путь и переносить через MOVE
+	AtomicWrites bool
+	// CreateParents - создавать отсутствующую родительскую коллекцию при записи;
+	// без него запись в отсутствующую коллекцию возвращает ErrParentNotExist
+	// (родитель проверяется отдельным PROPFIND)
+	CreateParents bool
+	// PublicBaseURL - базовый URL для PublicURL (CDN) вместо WebDavHost
+	PublicBaseURL string
+	// SidecarFormat - формат мета-файлов, по умолчанию key=value
+	SidecarFormat SidecarFormat
+	// ContentType - как определять Content-Type файла, записанного без явного
+	// PutOptions.ContentType: ContentTypeByExtension (по умолчанию), ContentTypeSniff
+	// или своя функция
+	ContentType ContentTypeResolver
+	// MaxIdleConns, MaxIdleConnsPerHost, IdleConnTimeout - пул keep-alive соединений
+	// http.Transport; нулевое значение - значение http.DefaultTransport
+	// (MaxIdleConnsPerHost по умолчанию 2, под нагрузкой его стоит увеличить)
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// CaseInsensitive - приводить пути к нижнему регистру перед каждой операцией,
+	// чтобы Foo.txt и foo.txt адресовали один файл; имеет смысл только для серверов,
+	// не различающих регистр (IIS), иначе файлы с заглавными буквами станут недоступны
+	CaseInsensitive bool
+}
+
+// FileEntry - элемент списка директории с метаданными
+type FileEntry struct {
+	Info os.FileInfo
+	Meta map[string]string
+}
+
+// DirEntry - элемент ListDirChan: файл директории или ошибка листинга
+// ошибка всегда последний элемент перед закрытием канала
+type DirEntry struct {
+	Info os.FileInfo
+	Err  error
 }
 
-type EmptyConfig struct{}
-type LocalConfig struct{}
+// sendDirEntry - отправляет элемент листинга, false - контекст отменен и листинг нужно прервать
+func sendDirEntry(ctx context.Context, ch chan<- DirEntry, e DirEntry) bool {
+	select {
+	case ch <- e:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ObjectInfo - полная информация о файле
+// поля, которые хранилище не поддерживает, остаются пустыми
+// StorageClass и VersionId заполняются только для S3
+// ETag в Local строится из времени изменения и размера файла (см. RemoveFileIfMatch)
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	ModTime      time.Time
+	IsDir        bool
+	Meta         map[string]string
+	ContentType  string
+	ETag         string
+	StorageClass string
+	VersionId    string
+	CacheControl string
+	// ContentEncoding - Content-Encoding объекта (S3), для остальных хранилищ пусто
+	ContentEncoding string
+	// Expires - время жизни (TTL), заданное при записи; нулевое - без срока
+	Expires time.Time
+}
+
+type EmptyConfig struct {
+	// Record - записывать вызовы методов с аргументами (см. Empty.Calls),
+	// например, чтобы проверить в тестах, какие операции выполнил код
+	Record bool
+}
+type LocalConfig struct {
+	// SkipValidation - не проверять при создании, что рабочая директория доступна на запись
+	SkipValidation bool
+	// AtomicWrites - писать файл и мета-файл через временный файл и переименование,
+	// чтобы читатель не увидел частично записанный файл
+	AtomicWrites bool
+	// CopyMode - способ копирования содержимого в CopyFile
+	CopyMode CopyMode
+	// CreateParents - создавать отсутствующую родительскую директорию при записи;
+	// без него запись в отсутствующую директорию возвращает ErrParentNotExist
+	CreateParents bool
+	// PublicBaseURL - базовый URL, по которому раздается рабочая директория;
+	// без него PublicURL возвращает file:// URL
+	PublicBaseURL string
+	// ContentType - как определять Content-Type файла, записанного без явного
+	// PutOptions.ContentType: ContentTypeByExtension (по умолчанию), ContentTypeSniff
+	// или своя функция
+	ContentType ContentTypeResolver
+	// ShardDepth - раскладывать файлы по ShardDepth уровням поддиректорий из хеша имени
+	// (a/b.txt хранится как a/_3f/_a1/b.txt), чтобы в одной директории не было миллионов файлов;
+	// вызывающий код работает с логическими путями, листинг отдает файлы без директорий шарда.
+	// Пути с директориями вида "_3f" ("_" и два символа [0-9a-f]) отклоняются с ErrInvalidKey,
+	// а директории шарда создаются автоматически (как при CreateParents). 0 - без шардирования
+	ShardDepth int
+	// ShardHash - хеш имени файла для ShardDepth, строка из символов [0-9a-f]
+	// длиной не меньше 2*ShardDepth; по умолчанию hex sha1
+	ShardHash func(name string) string
+	// MetaBackend - где хранить метаданные файлов, по умолчанию в мета-файлах рядом с файлом
+	MetaBackend MetaBackend
+	// SidecarFormat - формат мета-файлов, по умолчанию key=value
+	SidecarFormat SidecarFormat
+	// TempDir - директория для временных файлов CreateTempFile; по умолчанию os.TempDir()
+	TempDir string
+	// Scratch - при пустом TempDir размещать временные файлы в памяти (/dev/shm),
+	// если такой tmpfs есть, иначе в os.TempDir()
+	Scratch bool
+}
+
+// scratchDir - tmpfs, доступный на Linux без настройки
+const scratchDir = "/dev/shm"
+
+// CopyMode - способ копирования файла в Local
+type CopyMode int
+
+const (
+	// CopyModeCopy - побайтовое копирование
+	CopyModeCopy CopyMode = iota
+	// CopyModeHardlink - жесткая ссылка, файлы разделяют inode и изменения одного видны в другом;
+	// между файловыми системами - побайтовое копирование
+	CopyModeHardlink
+	// CopyModeReflink - copy-on-write клон (FICLONE на Linux для btrfs/XFS);
+	// если клонирование не поддерживается - побайтовое копирование
+	CopyModeReflink
+)
+
+// MetaBackend - способ хранения метаданных в Local
+type MetaBackend int
+
+const (
+	// MetaSidecar - мета-файл рядом с файлом (path + META_PREFIX)
+	MetaSidecar MetaBackend = iota
+	// MetaXattr - расширенные атрибуты файла user.<ключ> (Linux);
+	// если ФС или ОС их не поддерживает - мета-файл. Мета-файлы, записанные раньше,
+	// читаются, пока у файла нет атрибутов. При CopyModeHardlink атрибуты общие
+	// для обеих ссылок, поэтому метаданные копии меняют и метаданные исходного файла
+	MetaXattr
+)
+
+// WebDavAuthType - способ авторизации на WebDav сервере
+type WebDavAuthType int
+
+const (
+	// WebDavAuthAuto - метод из WWW-Authenticate ответа сервера (Basic или Digest)
+	WebDavAuthAuto WebDavAuthType = iota
+	// WebDavAuthBasic - Basic с WebDavUser/WebDavPass в каждом запросе, без согласования
+	WebDavAuthBasic
+	// WebDavAuthDigest - только Digest с WebDavUser/WebDavPass; сервер,
+	// предлагающий лишь Basic, получит ошибку авторизации, а не пароль
+	WebDavAuthDigest
+	// WebDavAuthBearer - заголовок Authorization: Bearer WebDavToken в каждом запросе
+	WebDavAuthBearer
+)
 
 func New(cfg Config) (StoreIFace, error) {
+	s, err := newStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DefaultTTL > 0 {
+		s = WithDefaultTTL(s, cfg.DefaultTTL)
+	}
+	if cfg.MaxGetSize > 0 {
+		s = WithMaxGetSize(s, cfg.MaxGetSize)
+	}
+	if cfg.NormalizeKeys {
+		s = WithKeyNormalization(s)
+	}
+	return s, nil
+}
+
+func newStore(cfg Config) (StoreIFace, error) {
+	if cfg.SkipValidation {
+		cfg.LocalConfig.SkipValidation = true
+		cfg.WebDavConfig.SkipValidation = true
+		cfg.S3Config.SkipValidation = true
+	}
+
 	switch cfg.StoreType {
 	case LocalStore:
 		return NewLocal(cfg.LocalConfig)
@@ -94,6 +521,9 @@ func New(cfg Config) (StoreIFace, error) {
 	case EmptyStore:
 		return NewEmpty(cfg.EmptyConfig)
 	default:
+		if factory, ok := registered(cfg.StoreType); ok {
+			return factory(cfg)
+		}
 		return nil, errors.New("unknown store type")
 	}
 }
@@ -107,10 +537,22 @@ func NewEmpty(cfg EmptyConfig) (StoreIFace, error) {
 }
 
 func NewLocal(cfg LocalConfig) (StoreIFace, error) {
+	if cfg.ShardDepth > 0 {
+		cfg.CreateParents = true
+	}
+
 	s := new(Local)
 	if err := s.init(cfg); err != nil {
 		return nil, err
 	}
+
+	if cfg.ShardDepth > 0 {
+		hash := cfg.ShardHash
+		if hash == nil {
+			hash = sha1Hex
+		}
+		return newShardedStore(s, sharding{depth: cfg.ShardDepth, hash: hash}), nil
+	}
 	return s, nil
 }
 
@@ -119,6 +561,9 @@ func NewWebDav(cfg WebDavConfig) (StoreIFace, error) {
 	if err := s.init(cfg); err != nil {
 		return nil, err
 	}
+	if cfg.CaseInsensitive {
+		return &keyNormalized{StoreIFace: s, normalize: foldKeyCase}, nil
+	}
 	return s, nil
 }
 
@@ -148,13 +593,18 @@ func meta2Bytes(meta map[string]string) []byte {
 }
 
 // bytes2Meta - преобразует байты в метаданные
+// формат определяется по содержимому: JSON объект (SidecarJSON) или строки key=value
 func bytes2Meta(b []byte) map[string]string {
+	if meta, ok := decodeJsonSidecar(b); ok {
+		return meta
+	}
+
 	meta := make(map[string]string)
 	for _, line := range bytes.Split(b, []byte{'\n'}) {
 		if len(line) == 0 {
 			continue
 		}
-		pair := bytes.Split(line, []byte{'='})
+		pair := bytes.SplitN(line, []byte{'='}, 2)
 		if len(pair) != 2 {
 			continue
 		}
@@ -162,3 +612,41 @@ func bytes2Meta(b []byte) map[string]string {
 	}
 	return meta
 }
+
+// decodeJsonMap - декодирует JSON объект в map
+// числа декодируются как json.Number, чтобы большие целые не теряли точность
+func decodeJsonMap(content []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// joinURL - добавляет путь к файлу к базовому URL, экранируя сегменты пути
+func joinURL(base, path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.Join(segments, "/")
+}
+
+// partialLength - длина части файла, которую возвращает GetFilePartially
+// size - размер файла
+// offset - смещение от начала
+// length - запрошенная длина, 0 и меньше - до конца файла
+// длина, выходящая за конец файла, обрезается по нему; смещение, равное размеру,
+// дает пустую часть, а смещение за концом файла - ErrRangeNotSatisfiable
+func partialLength(size, offset, length int64) (int64, error) {
+	if offset < 0 || offset > size {
+		return 0, ErrRangeNotSatisfiable
+	}
+	if length <= 0 || length > size-offset {
+		length = size - offset
+	}
+	return length, nil
+}
@@ -0,0 +1,679 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// inlineMetaMagic - сигнатура заголовка метаданных в начале объекта
+var inlineMetaMagic = []byte("GSM1")
+
+// inlineMetaPrefix - длина сигнатуры и длины JSON заголовка
+const inlineMetaPrefix = 8
+
+// WithInlineMeta - оборачивает хранилище записью метаданных внутрь файла
+// s - хранилище
+// Перед содержимым пишется заголовок: "GSM1", длина JSON (uint32 big-endian) и JSON метаданных.
+// Так метаданные переживают копирование инструментами, теряющими x-amz-meta заголовки.
+// Заголовок снимается при чтении через это хранилище (GetFile, FileReader, WriteTo, Peek,
+// GetFilePartially со сдвигом на длину заголовка), а Stat и StatObject возвращают
+// метаданные из него и размер без него. Файлы без заголовка читаются как есть.
+// Листинги (ListDirChan, ListDirDepth, ListModifiedSince, Latest, ListMeta) отдают размеры
+// без заголовка, Manifest и ArchiveDir читают содержимое без него, а ExtractArchive
+// и Reserve пишут файлы с заголовком.
+// Инструменты, читающие объект напрямую (aws s3 cp, браузер по PublicURL), получают
+// содержимое вместе с заголовком. Чтение части файла, Stat и каждый файл листинга
+// делают лишний запрос за заголовком.
+func WithInlineMeta(s StoreIFace) StoreIFace {
+	return &inlineMeta{StoreIFace: s}
+}
+
+type inlineMeta struct {
+	StoreIFace
+}
+
+// inlineHeader - заголовок с метаданными
+func inlineHeader(meta map[string]string) ([]byte, error) {
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	js, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, inlineMetaPrefix, inlineMetaPrefix+len(js))
+	copy(header, inlineMetaMagic)
+	binary.BigEndian.PutUint32(header[len(inlineMetaMagic):], uint32(len(js)))
+	return append(header, js...), nil
+}
+
+// splitInline - отделяет заголовок от содержимого
+// для файла без заголовка возвращается он сам и nil метаданные
+func splitInline(raw []byte) ([]byte, map[string]string, error) {
+	if len(raw) < inlineMetaPrefix || !bytes.Equal(raw[:len(inlineMetaMagic)], inlineMetaMagic) {
+		return raw, nil, nil
+	}
+	n := int64(binary.BigEndian.Uint32(raw[len(inlineMetaMagic):inlineMetaPrefix]))
+	if n > int64(len(raw)-inlineMetaPrefix) {
+		return raw, nil, nil
+	}
+
+	meta := map[string]string{}
+	if err := json.Unmarshal(raw[inlineMetaPrefix:inlineMetaPrefix+n], &meta); err != nil {
+		return nil, nil, fmt.Errorf("inline meta: %w", err)
+	}
+	return raw[inlineMetaPrefix+n:], meta, nil
+}
+
+// header - метаданные и длина заголовка файла; 0 - заголовка нет
+func (m *inlineMeta) header(ctx context.Context, path string) (map[string]string, int64, error) {
+	prefix, err := m.StoreIFace.GetFilePartiallyWithContext(ctx, path, 0, inlineMetaPrefix)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(prefix) < inlineMetaPrefix || !bytes.Equal(prefix[:len(inlineMetaMagic)], inlineMetaMagic) {
+		return nil, 0, nil
+	}
+
+	n := int64(binary.BigEndian.Uint32(prefix[len(inlineMetaMagic):]))
+	js, err := m.StoreIFace.GetFilePartiallyWithContext(ctx, path, inlineMetaPrefix, n)
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(js)) < n {
+		return nil, 0, nil
+	}
+
+	meta := map[string]string{}
+	if err := json.Unmarshal(js, &meta); err != nil {
+		return nil, 0, fmt.Errorf("inline meta: %w", err)
+	}
+	return meta, inlineMetaPrefix + n, nil
+}
+
+// withInline - содержимое с заголовком
+func withInline(file []byte, meta map[string]string) ([]byte, error) {
+	header, err := inlineHeader(meta)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, file...), nil
+}
+
+func (m *inlineMeta) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return m.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (m *inlineMeta) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	raw, err := withInline(file, meta)
+	if err != nil {
+		return err
+	}
+	return m.StoreIFace.CreateFileWithContext(ctx, path, raw, ttl, nil)
+}
+
+func (m *inlineMeta) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return m.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+// CreateFileWithOptionsWithContext - внутрь файла пишутся только opts.Meta,
+// остальные параметры передаются хранилищу
+func (m *inlineMeta) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	raw, err := withInline(file, opts.Meta)
+	if err != nil {
+		return err
+	}
+	opts.Meta = nil
+	return m.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, raw, opts)
+}
+
+func (m *inlineMeta) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return m.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+func (m *inlineMeta) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	file, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return m.CreateFileWithContext(ctx, path, file, ttl, meta)
+}
+
+func (m *inlineMeta) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return m.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (m *inlineMeta) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	_, err := m.StreamToFileNWithContext(ctx, stream, path, ttl)
+	return err
+}
+
+func (m *inlineMeta) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return m.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFileNWithContext - пишется заголовок без метаданных, int64 - длина содержимого без него
+func (m *inlineMeta) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	header, err := inlineHeader(nil)
+	if err != nil {
+		return 0, err
+	}
+	n, err := m.StoreIFace.StreamToFileNWithContext(ctx, io.MultiReader(bytes.NewReader(header), stream), path, ttl)
+	return max(n-int64(len(header)), 0), err
+}
+
+func (m *inlineMeta) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return m.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+func (m *inlineMeta) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	header, err := inlineHeader(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := m.StoreIFace.FileWriterWithContext(ctx, path, ttl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (m *inlineMeta) GetFile(path string) ([]byte, error) {
+	return m.GetFileWithContext(context.Background(), path)
+}
+
+func (m *inlineMeta) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	raw, err := m.StoreIFace.GetFileWithContext(ctx, path)
+	if err != nil || raw == nil {
+		return raw, err
+	}
+	file, _, err := splitInline(raw)
+	return file, err
+}
+
+func (m *inlineMeta) GetFileVerified(path string) ([]byte, error) {
+	return m.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+// GetFileVerifiedWithContext - хранилище сверяет свои суммы по файлу вместе с заголовком,
+// а суммы из заголовка сверяются по содержимому без него
+func (m *inlineMeta) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	raw, err := m.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+	if err != nil || raw == nil {
+		return raw, err
+	}
+	file, meta, err := splitInline(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(file, meta, ""); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (m *inlineMeta) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	return m.GetFileIfModifiedSinceWithContext(context.Background(), path, t)
+}
+
+func (m *inlineMeta) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	raw, modified, err := m.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, t)
+	if err != nil || !modified || raw == nil {
+		return raw, modified, err
+	}
+	file, _, err := splitInline(raw)
+	return file, modified, err
+}
+
+func (m *inlineMeta) GetJsonFile(path string, file interface{}) error {
+	return m.GetJsonFileWithContext(context.Background(), path, file)
+}
+
+func (m *inlineMeta) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	content, err := m.GetFileWithContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		return nil
+	}
+	return json.Unmarshal(content, file)
+}
+
+func (m *inlineMeta) GetJsonMap(path string) (map[string]interface{}, error) {
+	return m.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (m *inlineMeta) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	content, err := m.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, ErrFileNotFound
+	}
+	return decodeJsonMap(content)
+}
+
+func (m *inlineMeta) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return m.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+func (m *inlineMeta) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	_, size, err := m.header(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	offset, err = contentOffset(size, offset)
+	if err != nil {
+		return nil, err
+	}
+	return m.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+}
+
+func (m *inlineMeta) Peek(path string, n int) ([]byte, error) {
+	return m.PeekWithContext(context.Background(), path, n)
+}
+
+func (m *inlineMeta) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	stream, err := m.FileReaderWithContext(ctx, path, 0, int64(n))
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return nil, ErrFileNotFound
+	}
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+func (m *inlineMeta) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return m.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+func (m *inlineMeta) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	_, size, err := m.header(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	offset, err = contentOffset(size, offset)
+	if err != nil {
+		return nil, err
+	}
+	return m.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+}
+
+// MultiReader - заголовок метаданных снимается с каждого файла
+func (m *inlineMeta) MultiReader(paths []string) (io.ReadCloser, error) {
+	return m.MultiReaderWithContext(context.Background(), paths)
+}
+
+func (m *inlineMeta) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, m, paths)
+}
+
+func (m *inlineMeta) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return m.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+func (m *inlineMeta) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, m, path, blockSize)
+}
+
+func (m *inlineMeta) WriteTo(path string, w io.Writer) (int64, error) {
+	return m.WriteToWithContext(context.Background(), path, w)
+}
+
+func (m *inlineMeta) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	stream, err := m.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	if stream == nil {
+		return 0, ErrFileNotFound
+	}
+	defer stream.Close()
+
+	return io.Copy(w, stream)
+}
+
+// inlineFileInfo - информация о файле с размером без заголовка
+type inlineFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (f inlineFileInfo) Size() int64 {
+	return f.size
+}
+
+// stat - информация о файле без заголовка и метаданные из него
+func (m *inlineMeta) stat(ctx context.Context, path string, info os.FileInfo) (os.FileInfo, map[string]string, error) {
+	meta, size, err := m.header(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inlineFileInfo{FileInfo: info, size: info.Size() - size}, meta, nil
+}
+
+func (m *inlineMeta) Stat(path string) (os.FileInfo, map[string]string, error) {
+	return m.StatWithContext(context.Background(), path)
+}
+
+func (m *inlineMeta) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	info, err := m.StoreIFace.StatLiteWithContext(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		return info, nil, nil
+	}
+	return m.stat(ctx, path, info)
+}
+
+func (m *inlineMeta) StatLite(path string) (os.FileInfo, error) {
+	return m.StatLiteWithContext(context.Background(), path)
+}
+
+func (m *inlineMeta) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	info, _, err := m.StatWithContext(ctx, path)
+	return info, err
+}
+
+func (m *inlineMeta) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	return m.LstatWithContext(context.Background(), path)
+}
+
+func (m *inlineMeta) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	info, _, err := m.StoreIFace.LstatWithContext(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return info, nil, nil
+	}
+	return m.stat(ctx, path, info)
+}
+
+func (m *inlineMeta) StatObject(path string) (ObjectInfo, error) {
+	return m.StatObjectWithContext(context.Background(), path)
+}
+
+func (m *inlineMeta) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	obj, err := m.StoreIFace.StatObjectWithContext(ctx, path)
+	if err != nil || obj.IsDir {
+		return obj, err
+	}
+
+	meta, size, err := m.header(ctx, path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	obj.Size -= size
+	obj.Meta = meta
+	return obj, nil
+}
+
+func (m *inlineMeta) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return m.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+// CopyFileWithContext - без meta файл копируется как есть вместе с заголовком,
+// с meta - читается и записывается заново с объединенными метаданными
+func (m *inlineMeta) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	if meta == nil {
+		return m.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, nil)
+	}
+
+	file, current, err := m.read(ctx, src)
+	if err != nil {
+		return err
+	}
+	for k, v := range meta {
+		current[k] = v
+	}
+	return m.CreateFileWithContext(ctx, dst, file, ttl, current)
+}
+
+func (m *inlineMeta) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return m.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+func (m *inlineMeta) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	if opts.Meta == nil {
+		return m.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+	}
+
+	file, current, err := m.read(ctx, src)
+	if err != nil {
+		return err
+	}
+	for k, v := range opts.Meta {
+		current[k] = v
+	}
+	opts.Meta = current
+	return m.CreateFileWithOptionsWithContext(ctx, dst, file, opts)
+}
+
+func (m *inlineMeta) ExtractRange(src string, offset, length int64, dst string) error {
+	return m.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+// ExtractRangeWithContext - смещение отсчитывается от содержимого без заголовка,
+// dst записывается без заголовка и читается как есть
+func (m *inlineMeta) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	_, size, err := m.header(ctx, src)
+	if err != nil {
+		return err
+	}
+	offset, err = contentOffset(size, offset)
+	if err != nil {
+		return err
+	}
+	return m.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+}
+
+// contentOffset - смещение в файле с заголовком длины size для смещения offset в содержимом;
+// отрицательное смещение отклоняется, иначе оно указало бы внутрь заголовка
+func contentOffset(size, offset int64) (int64, error) {
+	if offset < 0 {
+		return 0, ErrRangeNotSatisfiable
+	}
+	return size + offset, nil
+}
+
+func (m *inlineMeta) Truncate(path string, size int64) error {
+	return m.TruncateWithContext(context.Background(), path, size)
+}
+
+// TruncateWithContext - длина считается без заголовка, файл перезаписывается
+// вместе с заголовком метаданных
+func (m *inlineMeta) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	return truncateRewrite(ctx, m, path, size)
+}
+
+func (m *inlineMeta) CopyFileIfChanged(src, dst string) (bool, error) {
+	return m.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+func (m *inlineMeta) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	return copyFileIfChanged(ctx, m, src, dst)
+}
+
+func (m *inlineMeta) CopyMeta(src, dst string) error {
+	return m.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+// CopyMetaWithContext - dst перезаписывается с метаданными src
+func (m *inlineMeta) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	meta, _, err := m.header(ctx, src)
+	if err != nil {
+		return err
+	}
+	file, _, err := m.read(ctx, dst)
+	if err != nil {
+		return err
+	}
+	return m.CreateFileWithContext(ctx, dst, file, nil, meta)
+}
+
+// read - содержимое файла и метаданные из заголовка (не nil)
+func (m *inlineMeta) read(ctx context.Context, path string) ([]byte, map[string]string, error) {
+	raw, err := m.StoreIFace.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if raw == nil {
+		return nil, nil, ErrFileNotFound
+	}
+
+	file, meta, err := splitInline(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	return file, meta, nil
+}
+
+func (m *inlineMeta) Reserve(path string) error {
+	return m.ReserveWithContext(context.Background(), path)
+}
+
+// ReserveWithContext - заглушка пишется с пустым заголовком без перезаписи (OverwriteFail)
+func (m *inlineMeta) ReserveWithContext(ctx context.Context, path string) error {
+	return m.CreateFileWithOptionsWithContext(ctx, path, nil, PutOptions{Overwrite: OverwriteFail})
+}
+
+func (m *inlineMeta) ListDirChan(path string) <-chan DirEntry {
+	return m.ListDirChanWithContext(context.Background(), path)
+}
+
+// ListDirChanWithContext - размеры файлов отдаются без заголовка
+func (m *inlineMeta) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		// останавливает листинг, если читатель перестал читать канал
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		for entry := range m.StoreIFace.ListDirChanWithContext(ctx, path) {
+			if entry.Err == nil && !entry.Info.IsDir() {
+				info, _, err := m.stat(ctx, joinKey(path, entry.Info.Name()), entry.Info)
+				if err != nil {
+					sendDirEntry(ctx, ch, DirEntry{Err: err})
+					return
+				}
+				entry.Info = info
+			}
+			if !sendDirEntry(ctx, ch, entry) || entry.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (m *inlineMeta) Latest(path string) (os.FileInfo, error) {
+	return m.LatestWithContext(context.Background(), path)
+}
+
+// LatestWithContext - заголовок читается только у найденного файла
+func (m *inlineMeta) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var latest os.FileInfo
+	for entry := range m.StoreIFace.ListDirChanWithContext(ctx, path) {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		if entry.Info.IsDir() {
+			continue
+		}
+		if latest == nil || entry.Info.ModTime().After(latest.ModTime()) {
+			latest = entry.Info
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, ErrFileNotFound
+	}
+	info, _, err := m.stat(ctx, joinKey(path, latest.Name()), latest)
+	return info, err
+}
+
+func (m *inlineMeta) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return m.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+func (m *inlineMeta) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	return listModifiedSince(ctx, m, path, since)
+}
+
+func (m *inlineMeta) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return m.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
+
+func (m *inlineMeta) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	return listDirDepth(ctx, m, path, maxDepth)
+}
+
+func (m *inlineMeta) ListMeta(path string) (map[string]map[string]string, error) {
+	return m.ListMetaWithContext(context.Background(), path)
+}
+
+// ListMetaWithContext - метаданные читаются из заголовков файлов
+func (m *inlineMeta) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	return listMeta(ctx, m, path)
+}
+
+func (m *inlineMeta) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return m.ManifestWithContext(context.Background(), path, algo)
+}
+
+// ManifestWithContext - контрольные суммы считаются по содержимому без заголовка,
+// поэтому ETag из листинга не используется
+func (m *inlineMeta) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return manifest(ctx, m, path, algo)
+}
+
+func (m *inlineMeta) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return m.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+// ArchiveDirWithContext - в архив попадает содержимое без заголовка
+func (m *inlineMeta) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	return archiveDir(ctx, m, path, w, format)
+}
+
+func (m *inlineMeta) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return m.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - файлы пишутся как StreamToFile, с пустым заголовком;
+// время изменения из архива не сохраняется
+func (m *inlineMeta) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, m, r, path, format, extractTarget{mkdir: m.StoreIFace.Backend() != S3Store})
+}
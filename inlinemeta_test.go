@@ -0,0 +1,224 @@
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestInlineMeta - метаданные внутри файла поверх Local в t.TempDir()
+func newTestInlineMeta(t *testing.T) (StoreIFace, string) {
+	t.Helper()
+	return WithInlineMeta(newTestLocal(t, LocalConfig{})), t.TempDir()
+}
+
+func TestInlineMetaRoundTrip(t *testing.T) {
+	s, dir := newTestInlineMeta(t)
+	data := []byte("inline meta survives a plain copy")
+	meta := map[string]string{"owner": "alice", "stage": "raw"}
+
+	src := filepath.Join(dir, "src.txt")
+	if err := s.CreateFile(src, data, nil, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	// копия инструментом, который знает только байты объекта
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(raw, data) {
+		t.Fatal("file is written without the inline header")
+	}
+	copied := filepath.Join(dir, "copy", "dst.txt")
+	if err := os.MkdirAll(filepath.Dir(copied), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(copied, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := s.GetFile(copied); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("GetFile = %q, %v; want %q", got, err, data)
+	}
+	info, got, err := s.Stat(copied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, meta) {
+		t.Errorf("Stat meta = %v, want %v", got, meta)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("Stat size = %d, want %d", info.Size(), len(data))
+	}
+
+	dirs := filepath.Join(dir, "copy")
+	for entry := range s.ListDirChan(dirs) {
+		if entry.Err != nil {
+			t.Fatal(entry.Err)
+		}
+		if entry.Info.Size() != int64(len(data)) {
+			t.Errorf("ListDirChan size of %s = %d, want %d", entry.Info.Name(), entry.Info.Size(), len(data))
+		}
+	}
+	if latest, err := s.Latest(dirs); err != nil || latest.Size() != int64(len(data)) {
+		t.Errorf("Latest = %v, %v; want %d bytes", latest, err, len(data))
+	}
+	if infos, err := s.ListDirDepth(dirs, 0); err != nil || len(infos) != 1 || infos[0].Size() != int64(len(data)) {
+		t.Errorf("ListDirDepth = %v, %v", infos, err)
+	}
+	if infos, err := s.ListModifiedSince(dirs, time.Time{}); err != nil || len(infos) != 1 || infos[0].Size() != int64(len(data)) {
+		t.Errorf("ListModifiedSince = %v, %v", infos, err)
+	}
+	if all, err := s.ListMeta(dirs); err != nil || !reflect.DeepEqual(all, map[string]map[string]string{"dst.txt": meta}) {
+		t.Errorf("ListMeta = %v, %v", all, err)
+	}
+
+	sum := sha256.Sum256(data)
+	entries, err := s.Manifest(dirs, ChecksumSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ManifestEntry{{Path: "dst.txt", Size: int64(len(data)), Checksum: hex.EncodeToString(sum[:])}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("Manifest = %v, want %v", entries, want)
+	}
+}
+
+func TestInlineMetaVerified(t *testing.T) {
+	s, dir := newTestInlineMeta(t)
+	data := []byte("checksum lives in the inline header")
+	sum := sha256.Sum256(data)
+	path := filepath.Join(dir, "a.txt")
+	if err := s.CreateFile(path, data, nil, map[string]string{MetaSHA256: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetFileVerified(path); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("GetFileVerified = %q, %v; want %q", got, err, data)
+	}
+
+	// порча содержимого за заголовком
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 0xff
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetFileVerified(path); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("GetFileVerified of a corrupted file = %q, %v; want ErrChecksumMismatch", got, err)
+	}
+}
+
+func TestInlineMetaArchive(t *testing.T) {
+	for _, f := range []struct {
+		name   string
+		format ArchiveFormat
+	}{{"tar", ArchiveTar}, {"zip", ArchiveZip}} {
+		t.Run(f.name, func(t *testing.T) {
+			s, dir := newTestInlineMeta(t)
+			files := map[string][]byte{"a.txt": []byte("a"), "sub/b.txt": []byte("bb")}
+			if err := s.MkdirAll(filepath.Join(dir, "src", "sub")); err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range files {
+				if err := s.CreateFile(filepath.Join(dir, "src", name), data, nil, map[string]string{"k": "v"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := s.ArchiveDir(filepath.Join(dir, "src"), &buf, f.format); err != nil {
+				t.Fatalf("ArchiveDir: %v", err)
+			}
+
+			// в архиве содержимое без заголовка
+			plain := filepath.Join(t.TempDir(), "plain")
+			if err := newTestLocal(t, LocalConfig{}).ExtractArchive(bytes.NewReader(buf.Bytes()), plain, f.format); err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range files {
+				if got, err := os.ReadFile(filepath.Join(plain, name)); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("archived %s = %q, %v; want %q", name, got, err, data)
+				}
+			}
+
+			dest := filepath.Join(dir, "dest")
+			if err := s.ExtractArchive(bytes.NewReader(buf.Bytes()), dest, f.format); err != nil {
+				t.Fatalf("ExtractArchive: %v", err)
+			}
+			for name, data := range files {
+				if got, err := s.GetFile(filepath.Join(dest, name)); err != nil || !bytes.Equal(got, data) {
+					t.Errorf("extracted %s = %q, %v; want %q", name, got, err, data)
+				}
+				raw, err := os.ReadFile(filepath.Join(dest, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.HasPrefix(raw, inlineMetaMagic) {
+					t.Errorf("extracted %s is written without the inline header", name)
+				}
+			}
+		})
+	}
+}
+
+func TestInlineMetaReserve(t *testing.T) {
+	s, dir := newTestInlineMeta(t)
+	path := filepath.Join(dir, "slot")
+
+	if err := s.Reserve(path); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	info, meta, err := s.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 || len(meta) != 0 {
+		t.Errorf("placeholder = %d bytes, meta %v; want empty", info.Size(), meta)
+	}
+	if err := s.Reserve(path); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("second Reserve = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestInlineMetaRanges(t *testing.T) {
+	s, dir := newTestInlineMeta(t)
+	path := filepath.Join(dir, "a.txt")
+	if err := s.CreateFile(path, []byte("0123456789"), nil, map[string]string{"owner": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// смещения считаются от содержимого, заголовок не виден
+	if got, err := s.GetFilePartially(path, 2, 3); err != nil || string(got) != "234" {
+		t.Errorf("GetFilePartially(2, 3) = %q, %v; want 234", got, err)
+	}
+	stream, err := s.FileReader(path, 7, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(stream)
+	stream.Close()
+	if err != nil || string(got) != "789" {
+		t.Errorf("FileReader(7) = %q, %v; want 789", got, err)
+	}
+
+	// отрицательное смещение указало бы внутрь заголовка
+	if got, err := s.GetFilePartially(path, -1, 1); !errors.Is(err, ErrRangeNotSatisfiable) {
+		t.Errorf("GetFilePartially(-1) = %q, %v; want ErrRangeNotSatisfiable", got, err)
+	}
+	if stream, err := s.FileReader(path, -1, 1); !errors.Is(err, ErrRangeNotSatisfiable) {
+		if stream != nil {
+			stream.Close()
+		}
+		t.Errorf("FileReader(-1) = %v, want ErrRangeNotSatisfiable", err)
+	}
+}
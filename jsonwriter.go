@@ -0,0 +1,362 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// JsonArrayWriter - записывает JSON массив в файл поэлементно, не держа его в памяти
+type JsonArrayWriter struct {
+	w     io.WriteCloser
+	count int
+}
+
+// NewJsonArrayWriter - открывает файл на запись JSON массива
+// s - хранилище
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные
+func NewJsonArrayWriter(s StoreIFace, path string, ttl *time.Time, meta map[string]string) (*JsonArrayWriter, error) {
+	return NewJsonArrayWriterWithContext(context.Background(), s, path, ttl, meta)
+}
+
+// NewJsonArrayWriterWithContext - открывает файл на запись JSON массива
+// s - хранилище
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные
+func NewJsonArrayWriterWithContext(ctx context.Context, s StoreIFace, path string, ttl *time.Time, meta map[string]string) (*JsonArrayWriter, error) {
+	w, err := s.FileWriterWithContext(ctx, path, ttl, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	return &JsonArrayWriter{w: w}, nil
+}
+
+// Append - дописывает элемент в массив
+// v - элемент
+func (j *JsonArrayWriter) Append(v interface{}) error {
+	content, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	sep := "\n  "
+	if j.count > 0 {
+		sep = ",\n  "
+	}
+
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	if _, err := j.w.Write(content); err != nil {
+		return err
+	}
+
+	j.count++
+	return nil
+}
+
+// Close - закрывает массив и завершает запись файла
+func (j *JsonArrayWriter) Close() error {
+	end := "]"
+	if j.count > 0 {
+		end = "\n]"
+	}
+
+	if _, err := io.WriteString(j.w, end); err != nil {
+		j.w.Close()
+		return err
+	}
+
+	return j.w.Close()
+}
+
+// jsonBufferSize - документ CreateJsonFile до этого размера пишется целиком, как CreateFile;
+// больший передается потоком (равен части multipart загрузки S3)
+const jsonBufferSize = S3PartSize
+
+// createJsonFile - кодирует data в формате json.MarshalIndent(data, "", "  ") и записывает:
+// документ не больше jsonBufferSize - через whole одним вызовом, как раньше,
+// больший - через stream, не держа его в памяти целиком.
+// Ошибка кодирования, случившаяся до jsonBufferSize, возвращается без записи;
+// после - приходит в stream как ошибка чтения.
+func createJsonFile(data interface{}, whole func([]byte) error, stream func(io.Reader) error) error {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		bw := bufio.NewWriterSize(pw, 64*1024)
+		err := writeJson(bw, reflect.ValueOf(data), "")
+		if err == nil {
+			err = bw.Flush()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	head := make([]byte, jsonBufferSize)
+	n, err := io.ReadFull(pr, head)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return whole(head[:n])
+	case err != nil:
+		return err
+	}
+
+	err = stream(io.MultiReader(bytes.NewReader(head), pr))
+	// останавливает кодирование, если stream завершился раньше
+	pr.CloseWithError(err)
+	return err
+}
+
+// jsonStreamDepth - глубже этой вложенности writeJson отдает значение json.MarshalIndent,
+// который обнаруживает циклические ссылки
+const jsonStreamDepth = 1000
+
+// writeJson - пишет v так же, как json.MarshalIndent(v, prefix, "  "), но массивы,
+// словари со строковыми ключами и структуры - поэлементно, поэтому в памяти одновременно
+// находится только один элемент. Остальные значения (значения с MarshalJSON/MarshalText,
+// []byte, структуры со встроенными полями или опциями string/omitzero) кодируются
+// json.MarshalIndent целиком.
+func writeJson(w io.Writer, v reflect.Value, prefix string) error {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !isJsonMarshaler(v) {
+		if v.IsNil() {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		v = v.Elem()
+	}
+
+	inner := prefix + "  "
+	switch {
+	case !v.IsValid():
+		_, err := io.WriteString(w, "null")
+		return err
+	case isJsonMarshaler(v), len(prefix) > 2*jsonStreamDepth:
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8, v.Kind() == reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		if v.Len() == 0 {
+			_, err := io.WriteString(w, "[]")
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			sep := ",\n" + inner
+			if i == 0 {
+				sep = "[\n" + inner
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			if err := writeJson(w, v.Index(i), inner); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "\n"+prefix+"]")
+		return err
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if v.IsNil() {
+			_, err := io.WriteString(w, "null")
+			return err
+		}
+		if v.Len() == 0 {
+			_, err := io.WriteString(w, "{}")
+			return err
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for i, key := range keys {
+			name, err := json.Marshal(key.String())
+			if err != nil {
+				return err
+			}
+			sep := ",\n" + inner
+			if i == 0 {
+				sep = "{\n" + inner
+			}
+			if _, err := io.WriteString(w, sep+string(name)+": "); err != nil {
+				return err
+			}
+			if err := writeJson(w, v.MapIndex(key), inner); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "\n"+prefix+"}")
+		return err
+	case v.Kind() == reflect.Struct:
+		fields, ok := jsonStructFields(v.Type())
+		if !ok {
+			break
+		}
+		written := 0
+		for _, f := range fields {
+			field := v.Field(f.index)
+			if f.omitEmpty && isEmptyJsonValue(field) {
+				continue
+			}
+			name, err := json.Marshal(f.name)
+			if err != nil {
+				return err
+			}
+			sep := ",\n" + inner
+			if written == 0 {
+				sep = "{\n" + inner
+			}
+			if _, err := io.WriteString(w, sep+string(name)+": "); err != nil {
+				return err
+			}
+			if err := writeJson(w, field, inner); err != nil {
+				return err
+			}
+			written++
+		}
+		end := "\n" + prefix + "}"
+		if written == 0 {
+			end = "{}"
+		}
+		_, err := io.WriteString(w, end)
+		return err
+	}
+
+	value := v.Interface()
+	if v.CanAddr() {
+		// как json, вызывает методы с указателем-получателем
+		value = v.Addr().Interface()
+	}
+	content, err := json.MarshalIndent(value, prefix, "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// jsonField - поле структуры, которое кодирует encoding/json
+type jsonField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+// jsonStructFields - поля структуры t в порядке и под именами encoding/json;
+// false - структура кодируется json.MarshalIndent целиком: для встроенных полей,
+// опций string и omitzero, недопустимых имен в теге и совпадающих имен нужны правила,
+// которые здесь не повторяются
+func jsonStructFields(t reflect.Type) ([]jsonField, bool) {
+	var fields []jsonField
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			return nil, false
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		} else if !isValidJsonTag(name) {
+			return nil, false
+		}
+		omitEmpty := false
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				omitEmpty = true
+			case "string", "omitzero":
+				return nil, false
+			}
+		}
+		if names[name] {
+			return nil, false
+		}
+		names[name] = true
+		fields = append(fields, jsonField{index: i, name: name, omitEmpty: omitEmpty})
+	}
+	return fields, true
+}
+
+// isValidJsonTag - имя из тега, которое encoding/json принимает вместо имени поля
+func isValidJsonTag(s string) bool {
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyJsonValue - значение, которое omitempty пропускает
+func isEmptyJsonValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isJsonMarshaler - значение кодируется своим MarshalJSON или MarshalText
+func isJsonMarshaler(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	t := v.Type()
+	marshaler := reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	text := reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	if t.Implements(marshaler) || t.Implements(text) {
+		return true
+	}
+	// методы с указателем-получателем json вызывает для адресуемых значений
+	pt := reflect.PointerTo(t)
+	return v.CanAddr() && (pt.Implements(marshaler) || pt.Implements(text))
+}
+
+// writeJsonStream - записывает большой документ через FileWriter; FileWriter нельзя
+// отменить, поэтому при ошибке кодирования недописанный файл удаляется
+func writeJsonStream(s StoreIFace, path string, r io.Reader, ttl *time.Time, meta map[string]string) error {
+	w, err := s.FileWriter(path, ttl, meta)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		return closeErr
+	}
+	return errors.Join(err, s.RemoveFile(path))
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonInner struct {
+	ID   int               `json:"id"`
+	Tags []string          `json:"tags,omitempty"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+type jsonEmbedded struct {
+	jsonInner
+	Name string
+}
+
+type jsonDoc struct {
+	Title    string       `json:"title"`
+	Items    []jsonInner  `json:"items"`
+	Next     *jsonDoc     `json:"next,omitempty"`
+	Any      interface{}  `json:"any"`
+	At       time.Time    `json:"at"`
+	Raw      []byte       `json:"raw,omitempty"`
+	Skip     string       `json:"-"`
+	Dash     string       `json:"-,"`
+	Untagged float64      ``
+	Zero     float64      `json:",omitempty"`
+	Ptr      *int         `json:"ptr,omitempty"`
+	Embedded jsonEmbedded `json:"embedded"`
+	hidden   string
+}
+
+type jsonOptions struct {
+	Count int `json:"count,string"`
+}
+
+type jsonBadTag struct {
+	Bad string `json:"bad\"name"`
+}
+
+type jsonDuplicate struct {
+	A string `json:"C"`
+	C string
+}
+
+func TestWriteJsonMatchesMarshalIndent(t *testing.T) {
+	n := 7
+	doc := jsonDoc{
+		Title: "<doc> & more",
+		Items: []jsonInner{{ID: 1, Tags: []string{"a", "b"}}, {ID: 2, Meta: map[string]string{"k": "v"}}, {}},
+		Next:  &jsonDoc{Title: "next", Any: map[string]interface{}{"n": 1.5, "list": []interface{}{}}},
+		Any:   jsonInner{ID: 3},
+		At:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Raw:   []byte("raw"),
+		Skip:  "skipped",
+		Dash:  "dash",
+		Ptr:   &n,
+		Embedded: jsonEmbedded{
+			jsonInner: jsonInner{ID: 4},
+			Name:      "embedded",
+		},
+		hidden: "hidden",
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"struct", doc},
+		{"pointer to struct", &doc},
+		{"empty struct", struct{}{}},
+		{"all fields omitted", jsonInner{}},
+		{"slice of structs", []jsonDoc{doc, {}}},
+		{"map of structs", map[string]jsonInner{"b": {ID: 2}, "a": {ID: 1}}},
+		{"string option", jsonOptions{Count: 5}},
+		{"invalid tag name", jsonBadTag{Bad: "bad"}},
+		{"duplicate names", jsonDuplicate{A: "a", C: "c"}},
+		{"nil pointer", (*jsonDoc)(nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := json.MarshalIndent(tt.v, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := writeJson(&buf, reflect.ValueOf(tt.v), ""); err != nil {
+				t.Fatalf("writeJson: %v", err)
+			}
+			if buf.String() != string(want) {
+				t.Errorf("writeJson =\n%s\nwant\n%s", buf.String(), want)
+			}
+		})
+	}
+
+	if _, ok := jsonStructFields(reflect.TypeOf(jsonDoc{})); !ok {
+		t.Error("jsonDoc is encoded whole, want field by field")
+	}
+
+	t.Run("cycle", func(t *testing.T) {
+		cyclic := &jsonDoc{Title: "loop"}
+		cyclic.Next = cyclic
+		if err := writeJson(&bytes.Buffer{}, reflect.ValueOf(cyclic), ""); err == nil {
+			t.Error("writeJson of a cyclic value succeeded, want the json error")
+		}
+	})
+}
+
+// jsonBenchItem - элемент большого документа BenchmarkCreateJsonFile
+type jsonBenchItem struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	Payload string            `json:"payload"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// jsonBenchDoc - большой документ из структур
+type jsonBenchDoc struct {
+	Title string          `json:"title"`
+	Items []jsonBenchItem `json:"items"`
+}
+
+// peakHeap - наибольший прирост живой кучи во время f; перед каждым замером
+// запускается сборка мусора, чтобы замер не зависел от того, когда она пришла бы сама
+func peakHeap(f func()) uint64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	base := ms.HeapAlloc
+
+	var peak uint64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.GC()
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			if ms.HeapAlloc > base && ms.HeapAlloc-base > peak {
+				peak = ms.HeapAlloc - base
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	f()
+	close(done)
+	<-sampled
+	return peak
+}
+
+// BenchmarkCreateJsonFile - пик памяти при записи документа из структур:
+// у потоковой записи он почти не зависит от размера документа (буфер первой части
+// и кодируемый элемент), у json.MarshalIndent растет вместе с документом
+func BenchmarkCreateJsonFile(b *testing.B) {
+	cfg := LocalConfig{SkipValidation: true}
+	local, err := NewLocal(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, n := range []int{1 << 14, 1 << 16, 1 << 18} {
+		// строка общая, поэтому документ в памяти намного меньше, чем в JSON
+		payload := strings.Repeat("x", 200)
+		doc := jsonBenchDoc{Title: "bench", Items: make([]jsonBenchItem, n)}
+		for i := range doc.Items {
+			doc.Items[i] = jsonBenchItem{ID: i, Name: "item", Payload: payload}
+		}
+
+		writes := []struct {
+			name  string
+			write func(path string) error
+		}{
+			{"stream", func(path string) error {
+				return local.CreateJsonFile(path, doc, nil, nil)
+			}},
+			{"MarshalIndent", func(path string) error {
+				content, err := json.MarshalIndent(doc, "", "  ")
+				if err != nil {
+					return err
+				}
+				return local.CreateFile(path, content, nil, nil)
+			}},
+		}
+		for _, wr := range writes {
+			b.Run(fmt.Sprintf("%s/items=%d", wr.name, n), func(b *testing.B) {
+				path := filepath.Join(b.TempDir(), "doc.json")
+				b.ReportAllocs()
+				var peak uint64
+				for i := 0; i < b.N; i++ {
+					var err error
+					if p := peakHeap(func() { err = wr.write(path) }); p > peak {
+						peak = p
+					}
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+			})
+		}
+	}
+}
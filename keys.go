@@ -0,0 +1,914 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// NormalizeKey - приводит путь к единому виду ключа для всех хранилищ
+// убирает ведущий "/", схлопывает "//" и "./", сохраняет завершающий "/";
+// пустой путь и "/" дают пустой ключ - корень хранилища;
+// пути с ".." отклоняются с ErrInvalidKey
+func NormalizeKey(key string) (string, error) {
+	parts := strings.Split(key, "/")
+	clean := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+		clean = append(clean, part)
+	}
+
+	normalized := strings.Join(clean, "/")
+	if normalized != "" && strings.HasSuffix(key, "/") {
+		normalized += "/"
+	}
+	return normalized, nil
+}
+
+// WithKeyNormalization - оборачивает хранилище нормализацией путей (NormalizeKey)
+// перед каждой операцией, чтобы один и тот же логический путь адресовал
+// один и тот же объект в любом хранилище.
+// Абсолютные пути Local при этом становятся относительными рабочей директории.
+func WithKeyNormalization(s StoreIFace) StoreIFace {
+	return &keyNormalized{StoreIFace: s, normalize: NormalizeKey}
+}
+
+// foldKeyCase - приводит путь к нижнему регистру для серверов, не различающих регистр
+func foldKeyCase(key string) (string, error) {
+	return strings.ToLower(key), nil
+}
+
+// keyNormalized - хранилище, приводящее пути функцией normalize перед каждой операцией
+type keyNormalized struct {
+	StoreIFace
+	normalize func(string) (string, error)
+}
+
+// errDirEntries - канал ListDirChan, содержащий только ошибку
+func errDirEntries(err error) <-chan DirEntry {
+	ch := make(chan DirEntry, 1)
+	ch <- DirEntry{Err: err}
+	close(ch)
+	return ch
+}
+
+// ExistMany - результат и ошибки возвращаются по исходным путям вызывающего
+func (k *keyNormalized) ExistMany(paths []string) (map[string]bool, error) {
+	return k.ExistManyWithContext(context.Background(), paths)
+}
+
+func (k *keyNormalized) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	keys := make([]string, 0, len(paths))
+	origin := make(map[string][]string, len(paths))
+	var errs []error
+	for _, path := range paths {
+		key, err := k.normalize(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if _, ok := origin[key]; !ok {
+			keys = append(keys, key)
+		}
+		origin[key] = append(origin[key], path)
+	}
+
+	exists, err := k.StoreIFace.ExistManyWithContext(ctx, keys)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	result := make(map[string]bool, len(paths))
+	for key, ok := range exists {
+		for _, path := range origin[key] {
+			result[path] = ok
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// Symlink - нормализуется только путь ссылки: цель может быть абсолютной
+// или относительной директории ссылки и хранится как есть
+func (k *keyNormalized) Symlink(oldname, newname string) error {
+	return k.SymlinkWithContext(context.Background(), oldname, newname)
+}
+
+func (k *keyNormalized) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	newname, err := k.normalize(newname)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.SymlinkWithContext(ctx, oldname, newname)
+}
+
+// MultiReader - части читаются через FileReader, поэтому каждый путь нормализуется,
+// а ошибка Read содержит путь вызывающего
+func (k *keyNormalized) MultiReader(paths []string) (io.ReadCloser, error) {
+	return k.MultiReaderWithContext(context.Background(), paths)
+}
+
+func (k *keyNormalized) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, k, paths)
+}
+
+func (k *keyNormalized) IsExist(path string) bool {
+	path, err := k.normalize(path)
+	if err != nil {
+		return false
+	}
+	return k.StoreIFace.IsExist(path)
+}
+
+func (k *keyNormalized) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CreateFile(path, file, ttl, meta)
+}
+
+func (k *keyNormalized) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CreateFileWithOptions(path, file, opts)
+}
+
+func (k *keyNormalized) Reserve(path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.Reserve(path)
+}
+
+func (k *keyNormalized) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CopyFile(src, dst, ttl, meta)
+}
+
+func (k *keyNormalized) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CopyFileWithOptions(src, dst, opts)
+}
+
+func (k *keyNormalized) CopyFileIfChanged(src, dst string) (bool, error) {
+	src, err := k.normalize(src)
+	if err != nil {
+		return false, err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return false, err
+	}
+	return k.StoreIFace.CopyFileIfChanged(src, dst)
+}
+
+func (k *keyNormalized) ExtractRange(src string, offset, length int64, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ExtractRange(src, offset, length, dst)
+}
+
+func (k *keyNormalized) Truncate(path string, offset int64) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.Truncate(path, offset)
+}
+
+func (k *keyNormalized) MoveFile(src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.MoveFile(src, dst)
+}
+
+func (k *keyNormalized) MoveFileNoOverwrite(src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.MoveFileNoOverwrite(src, dst)
+}
+
+func (k *keyNormalized) SwapFiles(src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.SwapFiles(src, dst)
+}
+
+func (k *keyNormalized) Rotate(path string) (string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return "", err
+	}
+	return k.StoreIFace.Rotate(path)
+}
+
+func (k *keyNormalized) CopyMeta(src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CopyMeta(src, dst)
+}
+
+func (k *keyNormalized) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.StreamToFile(stream, path, ttl)
+}
+
+func (k *keyNormalized) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return 0, err
+	}
+	return k.StoreIFace.StreamToFileN(stream, path, ttl)
+}
+
+func (k *keyNormalized) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.FileWriter(path, ttl, meta)
+}
+
+func (k *keyNormalized) GetFile(path string) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetFile(path)
+}
+
+func (k *keyNormalized) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetFilePartially(path, offset, length)
+}
+
+func (k *keyNormalized) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return k.StoreIFace.GetFileIfModifiedSince(path, t)
+}
+
+func (k *keyNormalized) Peek(path string, n int) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.Peek(path, n)
+}
+
+func (k *keyNormalized) GetFileVerified(path string) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetFileVerified(path)
+}
+
+func (k *keyNormalized) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.FileReader(path, offset, length)
+}
+
+func (k *keyNormalized) WriteTo(path string, w io.Writer) (int64, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return 0, err
+	}
+	return k.StoreIFace.WriteTo(path, w)
+}
+
+func (k *keyNormalized) RemoveFile(path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.RemoveFile(path)
+}
+
+func (k *keyNormalized) RemoveFileIfMatch(path, etag string) (bool, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return false, err
+	}
+	return k.StoreIFace.RemoveFileIfMatch(path, etag)
+}
+
+func (k *keyNormalized) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CreateJsonFile(path, data, ttl, meta)
+}
+
+func (k *keyNormalized) ClearDir(path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ClearDir(path)
+}
+
+func (k *keyNormalized) GetJsonFile(path string, file interface{}) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.GetJsonFile(path, file)
+}
+
+func (k *keyNormalized) GetJsonMap(path string) (map[string]interface{}, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetJsonMap(path)
+}
+
+func (k *keyNormalized) Stat(path string) (os.FileInfo, map[string]string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k.StoreIFace.Stat(path)
+}
+
+func (k *keyNormalized) StatLite(path string) (os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.StatLite(path)
+}
+
+func (k *keyNormalized) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k.StoreIFace.Lstat(path)
+}
+
+func (k *keyNormalized) StatObject(path string) (ObjectInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return k.StoreIFace.StatObject(path)
+}
+
+func (k *keyNormalized) PublicURL(path string) (string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return "", err
+	}
+	return k.StoreIFace.PublicURL(path)
+}
+
+func (k *keyNormalized) Latest(path string) (os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.Latest(path)
+}
+
+func (k *keyNormalized) IsEmpty(path string) (bool, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return false, err
+	}
+	return k.StoreIFace.IsEmpty(path)
+}
+
+func (k *keyNormalized) ListModifiedSince(path string, t time.Time) ([]os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ListModifiedSince(path, t)
+}
+
+func (k *keyNormalized) ListDirDepth(path string, n int) ([]os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ListDirDepth(path, n)
+}
+
+func (k *keyNormalized) ListDirChan(path string) <-chan DirEntry {
+	path, err := k.normalize(path)
+	if err != nil {
+		return errDirEntries(err)
+	}
+	return k.StoreIFace.ListDirChan(path)
+}
+
+func (k *keyNormalized) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ArchiveDir(path, w, format)
+}
+
+func (k *keyNormalized) ExtractArchive(stream io.Reader, path string, format ArchiveFormat) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ExtractArchive(stream, path, format)
+}
+
+func (k *keyNormalized) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.Manifest(path, algo)
+}
+
+func (k *keyNormalized) BlockChecksums(path string, n int) ([]BlockHash, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.BlockChecksums(path, n)
+}
+
+func (k *keyNormalized) ListMeta(path string) (map[string]map[string]string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ListMeta(path)
+}
+
+func (k *keyNormalized) MkdirAll(path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.MkdirAll(path)
+}
+
+func (k *keyNormalized) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CreateFileWithContext(ctx, path, file, ttl, meta)
+}
+
+func (k *keyNormalized) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+}
+
+func (k *keyNormalized) ReserveWithContext(ctx context.Context, path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ReserveWithContext(ctx, path)
+}
+
+func (k *keyNormalized) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+}
+
+func (k *keyNormalized) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+}
+
+func (k *keyNormalized) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	src, err := k.normalize(src)
+	if err != nil {
+		return false, err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return false, err
+	}
+	return k.StoreIFace.CopyFileIfChangedWithContext(ctx, src, dst)
+}
+
+func (k *keyNormalized) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+}
+
+func (k *keyNormalized) TruncateWithContext(ctx context.Context, path string, offset int64) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.TruncateWithContext(ctx, path, offset)
+}
+
+func (k *keyNormalized) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.MoveFileWithContext(ctx, src, dst)
+}
+
+func (k *keyNormalized) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.MoveFileNoOverwriteWithContext(ctx, src, dst)
+}
+
+func (k *keyNormalized) SwapFilesWithContext(ctx context.Context, src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.SwapFilesWithContext(ctx, src, dst)
+}
+
+func (k *keyNormalized) RotateWithContext(ctx context.Context, path string) (string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return "", err
+	}
+	return k.StoreIFace.RotateWithContext(ctx, path)
+}
+
+func (k *keyNormalized) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	src, err := k.normalize(src)
+	if err != nil {
+		return err
+	}
+	dst, err = k.normalize(dst)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CopyMetaWithContext(ctx, src, dst)
+}
+
+func (k *keyNormalized) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.StreamToFileWithContext(ctx, stream, path, ttl)
+}
+
+func (k *keyNormalized) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return 0, err
+	}
+	return k.StoreIFace.StreamToFileNWithContext(ctx, stream, path, ttl)
+}
+
+func (k *keyNormalized) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+}
+
+func (k *keyNormalized) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetFileWithContext(ctx, path)
+}
+
+func (k *keyNormalized) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+}
+
+func (k *keyNormalized) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return k.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, t)
+}
+
+func (k *keyNormalized) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.PeekWithContext(ctx, path, n)
+}
+
+func (k *keyNormalized) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+}
+
+func (k *keyNormalized) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+}
+
+func (k *keyNormalized) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return 0, err
+	}
+	return k.StoreIFace.WriteToWithContext(ctx, path, w)
+}
+
+func (k *keyNormalized) RemoveFileWithContext(ctx context.Context, path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.RemoveFileWithContext(ctx, path)
+}
+
+func (k *keyNormalized) RemoveFileIfMatchWithContext(ctx context.Context, path, etag string) (bool, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return false, err
+	}
+	return k.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+}
+
+func (k *keyNormalized) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.CreateJsonFileWithContext(ctx, path, data, ttl, meta)
+}
+
+func (k *keyNormalized) ClearDirWithContext(ctx context.Context, path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ClearDirWithContext(ctx, path)
+}
+
+func (k *keyNormalized) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.GetJsonFileWithContext(ctx, path, file)
+}
+
+func (k *keyNormalized) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.GetJsonMapWithContext(ctx, path)
+}
+
+func (k *keyNormalized) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k.StoreIFace.StatWithContext(ctx, path)
+}
+
+func (k *keyNormalized) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.StatLiteWithContext(ctx, path)
+}
+
+func (k *keyNormalized) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k.StoreIFace.LstatWithContext(ctx, path)
+}
+
+func (k *keyNormalized) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return k.StoreIFace.StatObjectWithContext(ctx, path)
+}
+
+func (k *keyNormalized) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.LatestWithContext(ctx, path)
+}
+
+func (k *keyNormalized) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return false, err
+	}
+	return k.StoreIFace.IsEmptyWithContext(ctx, path)
+}
+
+func (k *keyNormalized) ListModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ListModifiedSinceWithContext(ctx, path, t)
+}
+
+func (k *keyNormalized) ListDirDepthWithContext(ctx context.Context, path string, n int) ([]os.FileInfo, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ListDirDepthWithContext(ctx, path, n)
+}
+
+func (k *keyNormalized) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	path, err := k.normalize(path)
+	if err != nil {
+		return errDirEntries(err)
+	}
+	return k.StoreIFace.ListDirChanWithContext(ctx, path)
+}
+
+func (k *keyNormalized) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ArchiveDirWithContext(ctx, path, w, format)
+}
+
+func (k *keyNormalized) ExtractArchiveWithContext(ctx context.Context, stream io.Reader, path string, format ArchiveFormat) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.ExtractArchiveWithContext(ctx, stream, path, format)
+}
+
+func (k *keyNormalized) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ManifestWithContext(ctx, path, algo)
+}
+
+func (k *keyNormalized) BlockChecksumsWithContext(ctx context.Context, path string, n int) ([]BlockHash, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.BlockChecksumsWithContext(ctx, path, n)
+}
+
+func (k *keyNormalized) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, err
+	}
+	return k.StoreIFace.ListMetaWithContext(ctx, path)
+}
+
+func (k *keyNormalized) MkdirAllWithContext(ctx context.Context, path string) error {
+	path, err := k.normalize(path)
+	if err != nil {
+		return err
+	}
+	return k.StoreIFace.MkdirAllWithContext(ctx, path)
+}
+
+// appendTarget - докачка CopyResume дописывает файл по нормализованному пути
+func (k *keyNormalized) appendTarget(path string) (*Local, string, bool) {
+	path, err := k.normalize(path)
+	if err != nil {
+		return nil, "", false
+	}
+	return appendTarget(k.StoreIFace, path)
+}
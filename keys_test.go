@@ -0,0 +1,83 @@
+package store
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestNormalizeKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		{"a/b.txt", "a/b.txt", nil},
+		{"/a/b.txt", "a/b.txt", nil},
+		{"//a/./b/", "a/b/", nil},
+		{"a//b///c.txt", "a/b/c.txt", nil},
+		{"./a/./b.txt", "a/b.txt", nil},
+		{"/", "", nil},
+		{"", "", nil},
+		{"../x", "", ErrInvalidKey},
+		{"a/../x", "", ErrInvalidKey},
+		{"a/b/..", "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := NormalizeKey(tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeKey(%q) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyNormalizationAddressesNormalizedKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr error
+	}{
+		{"//a/./b/c.txt", "a/b/c.txt", nil},
+		{"/a/c.txt", "a/c.txt", nil},
+		{"a//c.txt", "a/c.txt", nil},
+		{"../x", "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			raw, f := newFakeS3(t, S3Config{})
+			s := WithKeyNormalization(raw)
+
+			err := s.CreateFile(tt.key, []byte("data"), nil, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateFile(%q) error = %v, want %v", tt.key, err, tt.wantErr)
+			}
+
+			puts := f.requestsTo(http.MethodPut, "")
+			if tt.wantErr != nil {
+				if len(puts) != 0 {
+					t.Errorf("rejected key reached the backend: %d PUT requests", len(puts))
+				}
+				return
+			}
+			if len(puts) != 1 {
+				t.Fatalf("PUT requests = %d, want 1", len(puts))
+			}
+			if _, key := bucketKey(puts[0]); key != tt.want {
+				t.Errorf("backend key = %q, want %q", key, tt.want)
+			}
+
+			// запись и чтение по разным написаниям пути адресуют один объект
+			got, err := s.GetFile("/" + tt.want)
+			if err != nil || string(got) != "data" {
+				t.Errorf("GetFile(/%s) = %q, %v; want data", tt.want, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+)
+
+// defaultMaxLineLength - максимальная длина строки ReadLines по умолчанию
+const defaultMaxLineLength = 1024 * 1024
+
+// ReadLines - читает файл построчно потоком через FileReader, не загружая его целиком
+// s - хранилище
+// path - путь к файлу
+// maxLineLength - максимальная длина строки в байтах вместе с переводом строки, 0 - 1MB;
+// более длинная строка прерывает чтение с ошибкой bufio.ErrTooLong
+// fn - вызывается для каждой строки без завершающего "\n" ("\r\n");
+// line действителен только до возврата из fn, ошибка fn прерывает чтение и возвращается
+func ReadLines(s StoreIFace, path string, maxLineLength int, fn func(line []byte) error) error {
+	return ReadLinesWithContext(context.Background(), s, path, maxLineLength, fn)
+}
+
+// ReadLinesWithContext - читает файл построчно потоком через FileReader, не загружая его целиком
+// s - хранилище
+// path - путь к файлу
+// maxLineLength - максимальная длина строки в байтах, 0 - 1MB
+// fn - вызывается для каждой строки
+func ReadLinesWithContext(ctx context.Context, s StoreIFace, path string, maxLineLength int, fn func(line []byte) error) error {
+	if maxLineLength <= 0 {
+		maxLineLength = defaultMaxLineLength
+	}
+
+	stream, err := s.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil {
+		return err
+	}
+	if stream == nil {
+		// Local не открывает пустые файлы, а в пустом файле просто нет строк
+		if info, _, statErr := s.StatWithContext(ctx, path); statErr == nil && info != nil && info.Size() == 0 {
+			return nil
+		}
+		return ErrFileNotFound
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, min(maxLineLength, 64*1024)), maxLineLength)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: line %d: %w", path, line+1, err)
+	}
+	return nil
+}
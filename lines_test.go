@@ -0,0 +1,197 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// collectLines - строки файла, прочитанные ReadLines
+func collectLines(s StoreIFace, path string, maxLineLength int) ([]string, error) {
+	var lines []string
+	err := ReadLines(s, path, maxLineLength, func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	return lines, err
+}
+
+func TestReadLines(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"lf", "a\nbb\nccc\n", []string{"a", "bb", "ccc"}},
+		{"no trailing newline", "a\nbb", []string{"a", "bb"}},
+		{"crlf", "a\r\nbb\r\n", []string{"a", "bb"}},
+		{"empty lines", "a\n\n\nb\n", []string{"a", "", "", "b"}},
+		{"single newline", "\n", []string{""}},
+		{"empty file", "", nil},
+	}
+
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			for _, tt := range tests {
+				path := joinKey(dir, strings.ReplaceAll(tt.name, " ", "-")+".txt")
+				if err := s.CreateFile(path, []byte(tt.body), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				got, err := collectLines(s, path, 0)
+				if err != nil || !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("%s: lines = %q, %v; want %q", tt.name, got, err, tt.want)
+				}
+			}
+
+			if _, err := collectLines(s, joinKey(dir, "missing.txt"), 0); !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("missing file: %v, want ErrFileNotFound", err)
+			}
+		})
+	}
+}
+
+func TestReadLinesMaxLineLength(t *testing.T) {
+	s := newTestLocal(t, LocalConfig{})
+	path := joinKey(t.TempDir(), "a.txt")
+	const limit = 16
+
+	// предел учитывает перевод строки
+	fits := strings.Repeat("x", limit-1)
+	if err := s.CreateFile(path, []byte("a\n"+fits+"\n"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := collectLines(s, path, limit); err != nil || !reflect.DeepEqual(got, []string{"a", fits}) {
+		t.Errorf("line of %d bytes = %q, %v; want it read", limit-1, got, err)
+	}
+
+	if err := s.CreateFile(path, []byte("a\nb\n"+strings.Repeat("x", limit)+"\nc\n"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := collectLines(s, path, limit)
+	if !errors.Is(err, bufio.ErrTooLong) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("line of %d bytes: %v, want bufio.ErrTooLong at line 3", limit, err)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("lines before the long one = %q, want a, b", got)
+	}
+
+	// по умолчанию строка ограничена 1MB
+	long := strings.Repeat("x", defaultMaxLineLength)
+	if err := s.CreateFile(path, []byte(long+"\n"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collectLines(s, path, 0); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("line of 1MB with the default limit: %v, want bufio.ErrTooLong", err)
+	}
+}
+
+func TestReadLinesStops(t *testing.T) {
+	s, root := newMultiReaderTree(t, map[string]string{"a.txt": "1\n2\n3\n4\n"})
+	path := joinKey(root, "a.txt")
+
+	stop := errors.New("stop")
+	var seen []string
+	err := ReadLines(s, path, 0, func(line []byte) error {
+		seen = append(seen, string(line))
+		if len(seen) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || !reflect.DeepEqual(seen, []string{"1", "2"}) {
+		t.Errorf("ReadLines = %v after %q, want the fn error after 2 lines", err, seen)
+	}
+	if _, open := s.state(); open != 0 {
+		t.Errorf("%d readers left open after fn stopped reading", open)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ReadLinesWithContext(ctx, s, path, 0, func([]byte) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadLinesWithContext with a cancelled context = %v, want context.Canceled", err)
+	}
+	if _, open := s.state(); open != 0 {
+		t.Errorf("%d readers left open after cancellation", open)
+	}
+}
+
+// readCounter - хранилище, считающее байты, прочитанные из потоков FileReader
+type readCounter struct {
+	StoreIFace
+	read int64
+}
+
+func (c *readCounter) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	stream, err := c.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	if err != nil || stream == nil {
+		return stream, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{readerFunc(func(b []byte) (int, error) {
+		n, err := stream.Read(b)
+		c.read += int64(n)
+		return n, err
+	}), stream}, nil
+}
+
+// readerFunc - io.Reader из функции
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(b []byte) (int, error) {
+	return f(b)
+}
+
+func TestReadLinesNDJSON(t *testing.T) {
+	type record struct {
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+
+	path := joinKey(t.TempDir(), "log.ndjson")
+	var buf bytes.Buffer
+	const records = 50000
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&buf, `{"id":%d,"message":"%s"}`+"\n", i, strings.Repeat("m", 100))
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(buf.Len())
+
+	s := &readCounter{StoreIFace: newTestLocal(t, LocalConfig{})}
+	var n, delivered, ahead int64
+	var last record
+	err := ReadLines(s, path, 0, func(line []byte) error {
+		if err := json.Unmarshal(line, &last); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		n++
+		delivered += int64(len(line)) + 1
+		ahead = max(ahead, s.read-delivered)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadLines: %v", err)
+	}
+	if n != records || last.ID != records-1 {
+		t.Errorf("%d records ending with id %d, want %d ending with %d", n, last.ID, records, records-1)
+	}
+	if s.read != size {
+		t.Errorf("read %d bytes, want the whole %d byte file", s.read, size)
+	}
+	// память не растет с размером файла: вперед читается не больше буфера сканера
+	if ahead > 64<<10 {
+		t.Errorf("read up to %d bytes ahead of the delivered lines of a %d byte file, want at most 64KB", ahead, size)
+	}
+}
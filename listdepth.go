@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"os"
+)
+
+// listDirDepth - файлы и поддиректории директории не глубже maxDepth уровней
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+// Name() результата - путь относительно dir; мета-файлы не включаются.
+// Каждый уровень читается ListDirChan (в S3 - листингом с разделителем "/"),
+// поэтому директории глубже maxDepth не листаются вовсе
+func listDirDepth(ctx context.Context, s StoreIFace, dir string, maxDepth int) ([]os.FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var result []os.FileInfo
+	var dirs []string
+	for entry := range s.ListDirChanWithContext(ctx, dir) {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		result = append(result, entry.Info)
+		if entry.Info.IsDir() {
+			dirs = append(dirs, entry.Info.Name())
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if maxDepth == 1 {
+		return result, nil
+	}
+
+	for _, sub := range dirs {
+		children, err := listDirDepth(ctx, s, joinKey(dir, sub), maxDepth-1)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			result = append(result, relFileInfo{FileInfo: child, name: sub + "/" + child.Name()})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// listMetaConcurrency - сколько файлов ListMeta опрашивает одновременно
+const listMetaConcurrency = 16
+
+// listMeta - обходит директорию и читает метаданные файлов через Stat
+// параллельно с ограничением listMetaConcurrency; мета-файлы не обходятся.
+// Ключ результата - путь относительно директории.
+func listMeta(ctx context.Context, s StoreIFace, dir string) (map[string]map[string]string, error) {
+	var paths []string
+	err := walkDir(ctx, s, dir, func(rel string, info os.FileInfo) error {
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := make(map[string]map[string]string, len(paths))
+	var firstErr error
+	var mu sync.Mutex
+
+	sem := make(chan struct{}, listMetaConcurrency)
+	var wg sync.WaitGroup
+	for _, rel := range paths {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(rel string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			_, meta, err := s.StatWithContext(ctx, joinKey(dir, rel))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// остальные файлы уже не нужны
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", rel, err)
+					cancel()
+				}
+				return
+			}
+			if meta == nil {
+				meta = map[string]string{}
+			}
+			result[rel] = meta
+		}(rel)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListMeta(t *testing.T) {
+	ttl := time.Now().Add(time.Hour)
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, root := b.store(t)
+			if b.name == "local" {
+				s = newTestLocal(t, LocalConfig{CreateParents: true})
+			}
+			if b.name == "webdav" {
+				if err := s.MkdirAll("sub/dir"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			files := []struct {
+				path string
+				opts PutOptions
+			}{
+				{"plain.txt", PutOptions{}},
+				{"owned.txt", PutOptions{Meta: map[string]string{"Owner": "bob"}}},
+				{"sub/tagged.txt", PutOptions{Meta: map[string]string{"Owner": "alice", "Project": "x", "Reviewed": "yes"}}},
+				// служебные ключи TTL и Cache-Control в метаданные не попадают
+				{"sub/dir/cached.txt", PutOptions{Meta: map[string]string{"Owner": "carol"}, CacheControl: "no-cache", TTL: &ttl}},
+			}
+			for _, f := range files {
+				if err := s.CreateFileWithOptions(joinKey(root, f.path), []byte(f.path), f.opts); err != nil {
+					t.Fatalf("CreateFileWithOptions(%s): %v", f.path, err)
+				}
+			}
+
+			got, err := s.ListMeta(root)
+			if err != nil {
+				t.Fatalf("ListMeta: %v", err)
+			}
+			want := map[string]map[string]string{
+				"plain.txt":          {},
+				"owned.txt":          {"Owner": "bob"},
+				"sub/tagged.txt":     {"Owner": "alice", "Project": "x", "Reviewed": "yes"},
+				"sub/dir/cached.txt": {"Owner": "carol"},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListMeta = %v, want %v", got, want)
+			}
+
+			got, err = s.ListMeta(joinKey(root, "sub"))
+			if err != nil {
+				t.Fatalf("ListMeta(sub): %v", err)
+			}
+			want = map[string]map[string]string{
+				"tagged.txt":     {"Owner": "alice", "Project": "x", "Reviewed": "yes"},
+				"dir/cached.txt": {"Owner": "carol"},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListMeta(sub) = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestListMetaErrors(t *testing.T) {
+	t.Run("stat error", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+			f.put(key, []byte(key), nil)
+		}
+		f.intercept = func(r *http.Request) *http.Response {
+			if _, key := bucketKey(r); r.Method == http.MethodHead && key == "b.txt" {
+				return fakeError(r, http.StatusForbidden, "AccessDenied")
+			}
+			return nil
+		}
+		got, err := s.ListMeta("")
+		if err == nil || got != nil {
+			t.Fatalf("ListMeta = %v, %v; want an error for b.txt", got, err)
+		}
+		if ErrorCode(err) != CodePermission || !strings.HasPrefix(err.Error(), "b.txt: ") {
+			t.Errorf("error = %v, want AccessDenied naming b.txt", err)
+		}
+	})
+
+	t.Run("empty and missing dir", func(t *testing.T) {
+		s := newTestLocal(t, LocalConfig{})
+		if got, err := s.ListMeta(t.TempDir()); err != nil || len(got) != 0 {
+			t.Errorf("ListMeta of an empty dir = %v, %v; want an empty map", got, err)
+		}
+		if _, err := s.ListMeta(joinKey(t.TempDir(), "missing")); err == nil {
+			t.Error("ListMeta of a missing dir succeeded")
+		}
+	})
+}
+
+func TestListMetaConcurrency(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	n := 3 * listMetaConcurrency
+	for i := 0; i < n; i++ {
+		f.put(strings.Repeat("a", i+1), nil, nil)
+	}
+
+	var mu sync.Mutex
+	var inFlight, peak int
+	f.intercept = func(r *http.Request) *http.Response {
+		if r.Method != http.MethodHead {
+			return nil
+		}
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+
+	got, err := s.ListMeta("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != n {
+		t.Errorf("%d entries, want %d", len(got), n)
+	}
+	if peak > listMetaConcurrency || peak < 2 {
+		t.Errorf("%d HEAD requests at once, want between 2 and %d", peak, listMetaConcurrency)
+	}
+}
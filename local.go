@@ -3,17 +3,99 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// listDirChunk - сколько записей директории ListDirChan читает за раз
+const listDirChunk = 256
+
 type Local struct {
+	atomicWrites  bool
+	copyMode      CopyMode
+	publicBaseURL string
+	createParents bool
+	contentType   ContentTypeResolver
+	sidecarFormat SidecarFormat
+	metaBackend   MetaBackend
+	// removeMu - сериализует сравнение и удаление в RemoveFileIfMatch
+	removeMu sync.Mutex
+	// tempDir - директория CreateTempFile
+	tempDir string
 }
 
 func (l *Local) init(cfg LocalConfig) error {
-	return nil
+	l.atomicWrites = cfg.AtomicWrites
+	l.copyMode = cfg.CopyMode
+	l.publicBaseURL = cfg.PublicBaseURL
+	l.contentType = cfg.ContentType
+	l.sidecarFormat = cfg.SidecarFormat
+	l.createParents = cfg.CreateParents
+	l.metaBackend = cfg.MetaBackend
+	l.tempDir = cfg.TempDir
+	if l.tempDir == "" {
+		l.tempDir = os.TempDir()
+		if info, err := os.Stat(scratchDir); cfg.Scratch && err == nil && info.IsDir() {
+			l.tempDir = scratchDir
+		}
+	}
+
+	if cfg.SkipValidation {
+		return nil
+	}
+	return l.validate()
+}
+
+// validate - проверяет, что рабочая директория, относительно которой
+// разрешаются пути, доступна на запись
+func (l *Local) validate() error {
+	f, err := os.CreateTemp(".", ".store-validate-*")
+	if err != nil {
+		return fmt.Errorf("local: working directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// CreateTempFile - создает пустой файл с уникальным именем во временной директории
+// (LocalConfig.TempDir или Scratch)
+// pattern - шаблон имени как в os.CreateTemp: последняя "*" заменяется случайной строкой
+// string - путь к файлу; файл удаляется через RemoveFile
+func (l *Local) CreateTempFile(pattern string) (string, error) {
+	f, err := os.CreateTemp(l.tempDir, pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// CreateTempFileWithContext - создает пустой файл с уникальным именем во временной директории
+// pattern - шаблон имени как в os.CreateTemp
+func (l *Local) CreateTempFileWithContext(ctx context.Context, pattern string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+		return l.CreateTempFile(pattern)
+	}
+}
+
+// Backend - возвращает тип хранилища (LocalStore)
+func (l *Local) Backend() string {
+	return LocalStore
 }
 
 // IsExist - проверяет существование файла
@@ -23,15 +105,35 @@ func (l *Local) IsExist(filePath string) bool {
 	return err == nil && info.Size() > 0
 }
 
+// ExistMany - проверяет существование нескольких файлов параллельно
+// paths - пути к файлам
+// как и в IsExist, пустой файл считается отсутствующим; пути, которые не удалось
+// проверить, в результат не попадают, а их ошибки объединяются в возвращаемую ошибку
+func (l *Local) ExistMany(paths []string) (map[string]bool, error) {
+	return l.ExistManyWithContext(context.Background(), paths)
+}
+
+// ExistManyWithContext - проверяет существование нескольких файлов параллельно
+// paths - пути к файлам
+func (l *Local) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	return existMany(ctx, paths, func(ctx context.Context, path string) (bool, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return info.Size() > 0, nil
+	})
+}
+
 // CreateFile - создает файл
 // path - путь к файлу
 // file - содержимое файла
 // meta - метаданные файла
 func (l *Local) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
-	if meta != nil {
-		return os.WriteFile(path+META_PREFIX, meta2Bytes(meta), perm)
-	}
-	return os.WriteFile(path, file, perm)
+	return l.CreateFileWithOptions(path, file, PutOptions{TTL: ttl, Meta: meta})
 }
 
 // CreateFileWithContext - создает файл
@@ -47,62 +149,361 @@ func (l *Local) CreateFileWithContext(ctx context.Context, path string, file []b
 	}
 }
 
-// CopyFile - копирует файл
-// src - исходный путь к файлу
-// dst - путь куда копировать
-// ttl - время жизни
-// meta - метаданные
-func (l *Local) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
-	//Main file
-	source, err := os.Open(src)
-	if err != nil {
+// Reserve - резервирует путь: создает пустой файл, только если его еще нет
+// path - путь к файлу
+// пустой файл - заглушка, которую позже перезапишут; если путь занят, возвращается ErrAlreadyExists.
+// Проверка и создание атомарны (O_EXCL)
+func (l *Local) Reserve(path string) error {
+	if err := l.prepareParent(path); err != nil {
 		return err
 	}
-	defer source.Close()
 
-	destination, err := os.Create(dst)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
 	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+		}
 		return err
 	}
-	defer destination.Close()
+	return f.Close()
+}
 
-	if _, err := io.Copy(destination, source); err != nil {
+// ReserveWithContext - резервирует путь: создает пустой файл, только если его еще нет
+// path - путь к файлу
+func (l *Local) ReserveWithContext(ctx context.Context, path string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.Reserve(path)
+	}
+}
+
+// CreateFileWithOptions - создает файл
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи, ACL переводится в права файла
+func (l *Local) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	if err := l.prepareParent(path); err != nil {
 		return err
 	}
 
-	if err := destination.Sync(); err != nil {
+	if opts.Overwrite != OverwriteAllow {
+		return opts.Overwrite.result(l.createExclusive(path, file, opts))
+	}
+
+	// мета-файл пишется первым: когда появляется файл, метаданные уже на месте;
+	// атрибутам нужен уже записанный файл
+	meta := l.sidecarMeta(path, file, opts)
+	if meta != nil && l.metaBackend == MetaSidecar {
+		if err := l.writeMeta(path, meta); err != nil {
+			return err
+		}
+	}
+
+	if err := l.writeFile(path, file, opts.ACL.fileMode()); err != nil {
 		return err
 	}
 
-	//Meta file
-	currentMetaInfo, err := os.Stat(src + META_PREFIX)
+	if meta != nil && l.metaBackend == MetaXattr {
+		if err := l.writeMeta(path, meta); err != nil {
+			return err
+		}
+	}
+
+	return l.applyACL(path, opts.ACL)
+}
+
+// prepareParent - проверяет родительскую директорию path перед записью,
+// при CreateParents создает ее
+func (l *Local) prepareParent(path string) error {
+	dir := filepath.Dir(path)
+	if l.createParents {
+		return os.MkdirAll(dir, perm)
+	}
+
+	info, err := os.Stat(dir)
 	if err != nil {
-		if !os.IsNotExist(err) {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrParentNotExist, dir)
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrIsNotDir, dir)
+	}
+	return nil
+}
+
+// createExclusive - создает файл, только если его еще нет, иначе ErrAlreadyExists;
+// метаданные пишутся после файла, чтобы не затереть метаданные существующего
+func (l *Local) createExclusive(path string, file []byte, opts PutOptions) error {
+	if err := l.writeFileExcl(path, file, opts.ACL.fileMode()); err != nil {
+		return err
+	}
+
+	if meta := l.sidecarMeta(path, file, opts); meta != nil {
+		if err := l.writeMeta(path, meta); err != nil {
+			return err
+		}
+	}
+
+	return l.applyACL(path, opts.ACL)
+}
+
+// sidecarMeta - метаданные записываемого файла вместе с CacheControl и Content-Type,
+// который не следует из расширения (см. withContentType)
+func (l *Local) sidecarMeta(path string, file []byte, opts PutOptions) map[string]string {
+	contentType := resolveContentType(opts.ContentType, l.contentType, path, file)
+	return withContentType(opts.sidecarMeta(), path, contentType)
+}
+
+// writeFile - записывает файл, при AtomicWrites через временный файл и переименование
+func (l *Local) writeFile(path string, data []byte, mode os.FileMode) error {
+	if !l.atomicWrites {
+		return os.WriteFile(path, data, mode)
+	}
+
+	tmp, err := writeTemp(path, data, mode)
+	if err != nil {
+		return err
+	}
+	// после успешного переименования удалять уже нечего
+	defer os.Remove(tmp)
+
+	return os.Rename(tmp, path)
+}
+
+// writeFileExcl - записывает файл, только если его еще нет, иначе ErrAlreadyExists;
+// при AtomicWrites временный файл публикуется жесткой ссылкой, которая, в отличие
+// от переименования, не заменяет существующий файл
+func (l *Local) writeFileExcl(path string, data []byte, mode os.FileMode) error {
+	if !l.atomicWrites {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if err != nil {
+			if os.IsExist(err) {
+				return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+			}
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
 			return err
 		}
+		return f.Close()
+	}
+
+	tmp, err := writeTemp(path, data, mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, path); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+		}
+		return err
 	}
+	return nil
+}
+
+// localWriter - файл, открытый на запись StreamToFile и FileWriter
+// при AtomicWrites это временный файл рядом с path, который Close переносит на место path
+type localWriter struct {
+	*os.File
+	path string
+	tmp  bool
+	// afterClose - вызывается после успешного Close, когда файл уже на месте path
+	afterClose func() error
+}
 
-	if currentMetaInfo != nil && currentMetaInfo.Size() > 0 {
-		currentMeta, err := os.ReadFile(src + META_PREFIX)
+// openWriter - открывает path на запись, при AtomicWrites - временный файл рядом с ним
+func (l *Local) openWriter(path string) (*localWriter, error) {
+	if !l.atomicWrites {
+		f, err := os.Create(path)
 		if err != nil {
+			return nil, err
+		}
+		return &localWriter{File: f, path: path}, nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: f, path: path, tmp: true}, nil
+}
+
+// Close - закрывает файл, при AtomicWrites сохраняет его на диск и переносит на место path;
+// при ошибке временный файл удаляется, а прежний path остается нетронутым
+func (w *localWriter) Close() error {
+	if !w.tmp {
+		if err := w.File.Close(); err != nil {
 			return err
 		}
+		return w.runAfterClose()
+	}
+
+	tmp := w.File.Name()
+	err := w.File.Sync()
+	if closeErr := w.File.Close(); err == nil {
+		err = closeErr
+	}
+	// CreateTemp создает файл с правами 0600
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return w.runAfterClose()
+}
+
+func (w *localWriter) runAfterClose() error {
+	if w.afterClose == nil {
+		return nil
+	}
+	return w.afterClose()
+}
+
+// abort - прерывает запись: при AtomicWrites временный файл удаляется, и path не меняется
+func (w *localWriter) abort() {
+	w.File.Close()
+	if w.tmp {
+		os.Remove(w.File.Name())
+	}
+}
+
+// writeTemp - записывает данные во временный файл рядом с path и возвращает его путь
+func writeTemp(path string, data []byte, mode os.FileMode) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	// CreateTemp создает файл с правами 0600
+	if mode == perm {
+		mode = 0644
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// CreateFileWithOptionsWithContext - создает файл
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи, ACL переводится в права файла
+func (l *Local) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.CreateFileWithOptions(path, file, opts)
+	}
+}
+
+// CopyFile - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// ttl - время жизни, nil - время жизни src сохраняется
+// meta - метаданные
+func (l *Local) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	if err := l.prepareParent(dst); err != nil {
+		return err
+	}
+	meta = withExpires(meta, ttl)
+
+	//Main file
+	if err := l.copyContent(src, dst); err != nil {
+		return err
+	}
 
-		currentMetaMap := bytes2Meta(currentMeta)
+	//Meta file
+	currentMetaMap, err := l.readMeta(src)
+	if err != nil {
+		return err
+	}
 
+	if len(currentMetaMap) > 0 {
 		for k, v := range meta {
 			currentMetaMap[k] = v
 		}
 
-		return os.WriteFile(dst+META_PREFIX, meta2Bytes(currentMetaMap), perm)
+		return l.writeMeta(dst, currentMetaMap)
 
 	} else if meta != nil {
-		return os.WriteFile(dst+META_PREFIX, meta2Bytes(meta), perm)
+		return l.writeMeta(dst, meta)
 	}
 
 	return nil
 }
 
+// copyContent - копирует содержимое файла способом из CopyMode
+func (l *Local) copyContent(src, dst string) error {
+	if l.copyMode == CopyModeHardlink {
+		srcInfo, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		// dst уже указывает на src, удалять его нельзя
+		if dstInfo, err := os.Stat(dst); err == nil && os.SameFile(srcInfo, dstInfo) {
+			return nil
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Link(src, dst); err == nil {
+			return nil
+		}
+		// например, src и dst на разных файловых системах
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	if l.copyMode == CopyModeReflink && reflink(destination, source) == nil {
+		return nil
+	}
+
+	if _, err := io.Copy(destination, source); err != nil {
+		return err
+	}
+
+	return destination.Sync()
+}
+
 // CopyFileWithContext - копирует файл
 // src - исходный путь к файлу
 // dst - путь куда копировать
@@ -117,6 +518,56 @@ func (l *Local) CopyFileWithContext(ctx context.Context, src, dst string, ttl *t
 	}
 }
 
+// CopyFileWithOptions - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// opts - параметры записи, ACL переводится в права файла
+func (l *Local) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	if err := l.CopyFile(src, dst, opts.TTL, opts.sidecarMeta()); err != nil {
+		return err
+	}
+
+	return l.applyACL(dst, opts.ACL)
+}
+
+// CopyFileWithOptionsWithContext - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// opts - параметры записи, ACL переводится в права файла
+func (l *Local) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.CopyFileWithOptions(src, dst, opts)
+	}
+}
+
+// CopyFileIfChanged - копирует файл, только если содержимое src и dst отличается
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// bool - выполнено ли копирование; отсутствующий dst копируется всегда.
+// Сравниваются размер, затем sha256 из метаданных, иначе sha256 содержимого обоих файлов
+func (l *Local) CopyFileIfChanged(src, dst string) (bool, error) {
+	return l.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+// CopyFileIfChangedWithContext - копирует файл, только если содержимое src и dst отличается
+// src - исходный путь к файлу
+// dst - путь куда копировать
+func (l *Local) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	return copyFileIfChanged(ctx, l, src, dst)
+}
+
+// applyACL - выставляет права файла по ACL
+// права при создании файла не применяются к уже существующему файлу
+func (l *Local) applyACL(path string, acl ACL) error {
+	if acl == ACLDefault {
+		return nil
+	}
+	return os.Chmod(path, acl.fileMode())
+}
+
 // MoveFile - перемещает файл
 // src - исходный путь к файлу
 // dst - путь куда переместить
@@ -125,6 +576,20 @@ func (l *Local) MoveFile(src, dst string) error {
 		return ErrFileNotFound
 	}
 
+	if err := l.prepareParent(dst); err != nil {
+		return err
+	}
+
+	// содержимое копируется в новый файл, поэтому атрибуты переносятся отдельно
+	var xattrMeta map[string]string
+	if l.metaBackend == MetaXattr {
+		meta, err := getXattrMeta(src)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+		xattrMeta = meta
+	}
+
 	inputFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -152,6 +617,12 @@ func (l *Local) MoveFile(src, dst string) error {
 		return err
 	}
 
+	if len(xattrMeta) > 0 {
+		if err := setXattrMeta(dst, xattrMeta); err != nil {
+			return err
+		}
+	}
+
 	metaFile, err := os.Stat(src + META_PREFIX)
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -183,211 +654,1315 @@ func (l *Local) MoveFile(src, dst string) error {
 			return err
 		}
 
-		if err := metaOutputFile.Sync(); err != nil {
-			return err
+		if err := metaOutputFile.Sync(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MoveFileWithContext - перемещает файл
+// src - исходный путь к файлу
+// dst - путь куда переместить
+func (l *Local) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.MoveFile(src, dst)
+	}
+}
+
+// MoveFileNoOverwrite - перемещает файл, если dst не существует
+// src - исходный путь к файлу
+// dst - путь куда переместить
+// dst занимается жесткой ссылкой (os.Link), которая не создается поверх существующего
+// файла, поэтому проверка и перемещение атомарны. Если жесткие ссылки недоступны
+// (другая файловая система), dst занимается пустым файлом (O_EXCL) и файл копируется в него
+func (l *Local) MoveFileNoOverwrite(src, dst string) error {
+	if _, err := os.Lstat(src); os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+
+	if err := l.prepareParent(dst); err != nil {
+		return err
+	}
+
+	err := os.Link(src, dst)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	}
+	if err != nil {
+		return l.moveExcl(src, dst)
+	}
+
+	if err := os.Remove(src); err != nil {
+		return err
+	}
+
+	// атрибуты остаются у файла, мета-файл переносится за ним;
+	// мета-файл без файла на месте dst к перенесенному файлу не относится
+	if err := os.Rename(src+META_PREFIX, dst+META_PREFIX); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(dst + META_PREFIX); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveExcl - занимает dst пустым файлом (O_EXCL) и переносит в него src через MoveFile
+func (l *Local) moveExcl(src, dst string) error {
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+		}
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := l.MoveFile(src, dst); err != nil {
+		// пока src на месте, заглушка dst никому не нужна
+		if _, serr := os.Lstat(src); serr == nil {
+			os.Remove(dst)
+		}
+		return err
+	}
+	return nil
+}
+
+// MoveFileNoOverwriteWithContext - перемещает файл, если dst не существует
+// src - исходный путь к файлу
+// dst - путь куда переместить
+func (l *Local) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.MoveFileNoOverwrite(src, dst)
+	}
+}
+
+// SwapFiles - меняет местами содержимое и метаданные двух файлов
+// a - путь к первому файлу
+// b - путь ко второму файлу
+// Каждый путь заменяется одним rename, поэтому ни один из файлов не пропадает даже на время;
+// на время обмена берется Lock обоих путей, поэтому одновременные SwapFiles с этими путями,
+// в том числе из других процессов, выполняются по очереди
+func (l *Local) SwapFiles(a, b string) error {
+	return l.SwapFilesWithContext(context.Background(), a, b)
+}
+
+// SwapFilesWithContext - меняет местами содержимое и метаданные двух файлов
+// a - путь к первому файлу
+// b - путь ко второму файлу
+func (l *Local) SwapFilesWithContext(ctx context.Context, a, b string) (err error) {
+	unlock, err := lockPaths(ctx, l, a, b)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+
+	for _, path := range []string{a, b} {
+		if _, err := os.Lstat(path); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("%w: %s", ErrFileNotFound, path)
+			}
+			return err
+		}
+	}
+
+	if err := l.swapPaths(a, b); err != nil {
+		return err
+	}
+
+	_, errA := os.Lstat(a + META_PREFIX)
+	_, errB := os.Lstat(b + META_PREFIX)
+	switch {
+	case errA == nil && errB == nil:
+		return l.swapPaths(a+META_PREFIX, b+META_PREFIX)
+	case errA == nil:
+		return os.Rename(a+META_PREFIX, b+META_PREFIX)
+	case errB == nil:
+		return os.Rename(b+META_PREFIX, a+META_PREFIX)
+	}
+	return nil
+}
+
+// swapPaths - меняет местами два существующих файла: их копии (жесткие ссылки, если ФС их
+// поддерживает) под временными именами переносятся rename поверх друг друга
+func (l *Local) swapPaths(a, b string) error {
+	suffix := ".swap-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	tmpA := filepath.Join(filepath.Dir(a), "."+filepath.Base(a)+suffix)
+	tmpB := filepath.Join(filepath.Dir(b), "."+filepath.Base(b)+suffix)
+
+	if err := l.linkOrCopy(a, tmpA); err != nil {
+		return err
+	}
+	if err := l.linkOrCopy(b, tmpB); err != nil {
+		os.Remove(tmpA)
+		return err
+	}
+	if err := os.Rename(tmpB, a); err != nil {
+		os.Remove(tmpA)
+		os.Remove(tmpB)
+		return err
+	}
+	return os.Rename(tmpA, b)
+}
+
+// linkOrCopy - создает dst как жесткую ссылку на src, а если это невозможно - как копию
+func (l *Local) linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return l.copyContent(src, dst)
+}
+
+// Rotate - переносит файл под имя с меткой времени и создает на его месте пустой
+// path - путь к файлу
+// string - путь, под которым сохранено прежнее содержимое
+func (l *Local) Rotate(path string) (string, error) {
+	return l.RotateWithContext(context.Background(), path)
+}
+
+// RotateWithContext - переносит файл под имя с меткой времени и создает на его месте пустой
+// path - путь к файлу
+// string - путь, под которым сохранено прежнее содержимое
+func (l *Local) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated := rotatedPath(path, time.Now())
+	if err := rotateTo(ctx, l, path, rotated); err != nil {
+		return "", err
+	}
+	return rotated, nil
+}
+
+// ExtractRange - создает файл dst из части содержимого src
+// src - исходный путь к файлу
+// offset - смещение от начала src
+// length - длина части, 0 и меньше - до конца файла; часть, выходящая за конец файла, обрезается по нему
+// dst - путь к новому файлу, метаданные src не копируются
+func (l *Local) ExtractRange(src string, offset, length int64, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	length, err = partialLength(info.Size(), offset, length)
+	if err != nil {
+		return err
+	}
+
+	return l.StreamToFile(io.NewSectionReader(f, offset, length), dst, nil)
+}
+
+// ExtractRangeWithContext - создает файл dst из части содержимого src
+// src - исходный путь к файлу
+// offset - смещение от начала src
+// length - длина части, 0 и меньше - до конца файла
+// dst - путь к новому файлу
+func (l *Local) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.ExtractRange(src, offset, length, dst)
+	}
+}
+
+// Truncate - изменяет длину файла
+// path - путь к файлу
+// size - новая длина; файл короче size дополняется нулевыми байтами
+// контрольные суммы из метаданных (MetaSHA256, MetaMD5) удаляются, остальные метаданные сохраняются
+func (l *Local) Truncate(path string, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return err
+	}
+	if info.Size() == size {
+		return nil
+	}
+
+	if err := os.Truncate(path, size); err != nil {
+		return err
+	}
+
+	meta, err := l.readMeta(path)
+	if err != nil {
+		return err
+	}
+	if meta, dropped := withoutChecksums(meta); dropped {
+		if len(meta) == 0 {
+			return l.removeMeta(path)
+		}
+		return l.writeMeta(path, meta)
+	}
+	return nil
+}
+
+// TruncateWithContext - изменяет длину файла
+// path - путь к файлу
+// size - новая длина; файл короче size дополняется нулевыми байтами
+func (l *Local) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.Truncate(path, size)
+	}
+}
+
+// CopyMeta - копирует метаданные src в dst, не изменяя содержимое dst
+// src - путь к файлу, метаданные которого копируются
+// dst - путь к файлу, которому они назначаются
+// если у src нет метаданных, метаданные dst удаляются; CacheControl, Content-Type и TTL dst сохраняются
+func (l *Local) CopyMeta(src, dst string) error {
+	_, meta, err := l.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	_, dstMeta, err := l.stat(dst, os.Stat)
+	if err != nil {
+		return err
+	}
+	contentType, expires := dstMeta[metaContentType], dstMeta[metaExpires]
+	_, cacheControl := splitCacheControl(dstMeta)
+	meta = withContentType(withCacheControl(meta, cacheControl), dst, contentType)
+	meta = withMetaValue(meta, metaExpires, expires)
+
+	if len(meta) == 0 {
+		return l.removeMeta(dst)
+	}
+
+	return l.writeMeta(dst, meta)
+}
+
+// CopyMetaWithContext - копирует метаданные src в dst, не изменяя содержимое dst
+// src - путь к файлу, метаданные которого копируются
+// dst - путь к файлу, которому они назначаются
+func (l *Local) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.CopyMeta(src, dst)
+	}
+}
+
+// StreamToFile - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// при AtomicWrites поток пишется во временный файл, который переносится на место path
+// только после успешной записи: читатель не увидит частично записанный файл
+func (l *Local) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	if err := l.prepareParent(path); err != nil {
+		return err
+	}
+
+	// время жизни хранится в метаданных, как в CreateFile
+	file, err := l.openMetaWriter(path, withExpires(nil, ttl))
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, stream); err != nil {
+		file.abort()
+		return err
+	}
+	return file.Close()
+}
+
+// appendTarget - Local дописывает файлы сам (см. appendable)
+func (l *Local) appendTarget(path string) (*Local, string, bool) {
+	return l, path, true
+}
+
+// appendStream - дописывает содержимое потока в конец существующего файла
+// используется CopyResume для докачки
+func (l *Local) appendStream(stream io.Reader, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrFileNotFound
+		}
+		return err
+	}
+
+	if _, err := io.Copy(file, stream); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// StreamToFileWithContext - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+func (l *Local) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.StreamToFile(stream, path, ttl)
+	}
+}
+
+// StreamToFileN - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (l *Local) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return l.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFileNWithContext - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (l *Local) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	counter := &countingReader{r: stream}
+	err := l.StreamToFileWithContext(ctx, counter, path, ttl)
+	return counter.n, err
+}
+
+// FileWriter - открывает файл на запись
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные файла
+// при AtomicWrites файл появляется на месте path только при Close
+func (l *Local) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	if err := l.prepareParent(path); err != nil {
+		return nil, err
+	}
+
+	f, err := l.openMetaWriter(path, withExpires(meta, ttl))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// openMetaWriter - открывает файл на запись вместе с метаданными meta
+// мета-файл пишется первым, как в CreateFile; атрибуты принадлежат файлу:
+// при AtomicWrites им станет временный файл после Close
+func (l *Local) openMetaWriter(path string, meta map[string]string) (*localWriter, error) {
+	if meta != nil && l.metaBackend == MetaSidecar {
+		if err := l.writeMeta(path, meta); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := l.openWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil || l.metaBackend != MetaXattr {
+		return f, nil
+	}
+
+	if f.tmp {
+		f.afterClose = func() error { return l.writeMeta(path, meta) }
+		return f, nil
+	}
+	if err := l.writeMeta(path, meta); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// FileWriterWithContext - открывает файл на запись
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные файла
+func (l *Local) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.FileWriter(path, ttl, meta)
+	}
+}
+
+// GetFile - возвращает содержимое файла
+// path - путь к файлу
+func (l *Local) GetFile(path string) ([]byte, error) {
+	if !l.IsExist(path) {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+// GetFileWithContext - возвращает содержимое файла
+// path - путь к файлу
+func (l *Local) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.GetFile(path)
+	}
+}
+
+// GetFilePartially - возвращает часть содержимого файла
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина, 0 и меньше - до конца файла; часть, выходящая за конец файла, обрезается по нему
+// смещение, равное размеру файла, дает пустую часть, а смещение за концом файла -
+// пустую часть и ErrRangeNotSatisfiable
+func (l *Local) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	if !l.IsExist(path) {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	length, err = partialLength(info.Size(), offset, length)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	buf := make([]byte, length)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return buf[:n], nil
+}
+
+// GetFilePartiallyWithContext - возвращает часть содержимого файла
+// path - путь к файлу
+// offset - смещение от начала
+func (l *Local) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.GetFilePartially(path, offset, length)
+	}
+}
+
+// GetFileIfModifiedSince - возвращает содержимое файла, если он изменен после t
+// path - путь к файлу
+// t - время последней известной версии
+// bool - true, если файл изменен и содержимое прочитано
+func (l *Local) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, ErrFileNotFound
+		}
+		return nil, false, err
+	}
+
+	if !info.ModTime().After(t) {
+		return nil, false, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+// GetFileIfModifiedSinceWithContext - возвращает содержимое файла, если он изменен после t
+// path - путь к файлу
+// t - время последней известной версии
+// bool - true, если файл изменен и содержимое прочитано
+func (l *Local) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	default:
+		return l.GetFileIfModifiedSince(path, t)
+	}
+}
+
+// Peek - возвращает не более n первых байт файла
+// path - путь к файлу
+// n - количество байт
+func (l *Local) Peek(path string, n int) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	if n <= 0 {
+		return []byte{}, nil
+	}
+
+	buf := make([]byte, n)
+	read, err := file.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return buf[:read], nil
+}
+
+// PeekWithContext - возвращает не более n первых байт файла
+// path - путь к файлу
+// n - количество байт
+func (l *Local) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.Peek(path, n)
+	}
+}
+
+// GetFileVerified - возвращает содержимое файла, проверяя контрольную сумму из метаданных
+// path - путь к файлу
+// при расхождении с sha256/md5 из метаданных возвращается ErrChecksumMismatch
+func (l *Local) GetFileVerified(path string) ([]byte, error) {
+	_, meta, err := l.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := l.GetFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyChecksum(content, meta, ""); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// GetFileVerifiedWithContext - возвращает содержимое файла, проверяя контрольную сумму из метаданных
+// path - путь к файлу
+// при расхождении с sha256/md5 из метаданных возвращается ErrChecksumMismatch
+func (l *Local) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.GetFileVerified(path)
+	}
+}
+
+// FileReader - открывает файл на чтение
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина, 0 - до конца файла
+func (l *Local) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	if !l.IsExist(path) {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if length > 0 {
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(file, length), file}, nil
+	}
+	return file, nil
+}
+
+// FileReaderWithContext - открывает файл на чтение
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина
+func (l *Local) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.FileReader(path, offset, length)
+	}
+}
+
+// MultiReader - поток, в котором файлы идут один за другим в порядке paths
+// paths - пути к файлам
+// Файлы открываются по очереди, по мере чтения; отсутствующий файл приводит
+// к ошибке Read с его путем
+func (l *Local) MultiReader(paths []string) (io.ReadCloser, error) {
+	return l.MultiReaderWithContext(context.Background(), paths)
+}
+
+// MultiReaderWithContext - поток, в котором файлы идут один за другим в порядке paths
+// paths - пути к файлам
+func (l *Local) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, l, paths)
+}
+
+// WriteTo - записывает содержимое файла в w
+// path - путь к файлу
+// int64 - количество записанных байт
+func (l *Local) WriteTo(path string, w io.Writer) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrFileNotFound
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	// io.Copy отдаст *os.File в ReadFrom получателя, что позволяет использовать sendfile
+	return io.Copy(w, file)
+}
+
+// WriteToWithContext - записывает содержимое файла в w
+// path - путь к файлу
+// int64 - количество записанных байт
+func (l *Local) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return l.WriteTo(path, w)
+	}
+}
+
+// RemoveFile - удаляет файл
+// path - путь к файлу
+func (l *Local) RemoveFile(path string) error {
+	os.Remove(path + META_PREFIX)
+	err := os.Remove(path)
+	if err != nil && os.IsNotExist(err) {
+		return ErrFileNotFound
+	}
+	return err
+}
+
+// RemoveFileWithContext - удаляет файл
+// path - путь к файлу
+func (l *Local) RemoveFileWithContext(ctx context.Context, path string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return l.RemoveFile(path)
+	}
+}
+
+// RemoveFileIfMatch - удаляет файл, только если он не изменился
+// path - путь к файлу
+// etag - ETag из StatObject; в Local он строится из времени изменения и размера
+// bool - true, если файл удален; отсутствующий файл не удаляется и не считается ошибкой,
+// измененный не удаляется и возвращает ErrConflict
+// сравнение и удаление выполняются под блокировкой, поэтому конкурентные RemoveFileIfMatch
+// этого хранилища не удалят записанную между ними замену; запись, выполненная
+// в обход хранилища (другим процессом), блокировкой не учитывается
+func (l *Local) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	l.removeMu.Lock()
+	defer l.removeMu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if localETag(info) != etag {
+		return false, fmt.Errorf("%w: %s", ErrConflict, path)
+	}
+
+	if err := l.RemoveFile(path); err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveFileIfMatchWithContext - удаляет файл, только если он не изменился
+// path - путь к файлу
+// etag - ETag из StatObject
+func (l *Local) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+		return l.RemoveFileIfMatch(path, etag)
+	}
+}
+
+// localETag - ETag файла из времени изменения и размера
+func localETag(info os.FileInfo) string {
+	return fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+}
+
+// Stat - возвращает информацию о файле и метаданные
+// path - путь к файлу
+// для отсутствующего файла возвращается ошибка, оборачивающая ErrFileNotFound
+func (l *Local) Stat(path string) (os.FileInfo, map[string]string, error) {
+	info, meta, err := l.stat(path, os.Stat)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta, _ = splitCacheControl(meta)
+	return info, meta, nil
+}
+
+// stat - возвращает информацию о файле и содержимое мета-файла вместе со служебными ключами
+// statFn - os.Stat или os.Lstat
+func (l *Local) stat(path string, statFn func(string) (os.FileInfo, error)) (os.FileInfo, map[string]string, error) {
+	info, err := statFn(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return nil, nil, err
+	}
+
+	// get meta data
+	meta, err := l.readMeta(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, meta, nil
+}
+
+// readMeta - метаданные файла: при MetaXattr из атрибутов, а если их нет
+// или ФС их не поддерживает - из мета-файла
+func (l *Local) readMeta(path string) (map[string]string, error) {
+	if l.metaBackend == MetaXattr {
+		meta, err := getXattrMeta(path)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return nil, err
+		}
+		if len(meta) > 0 {
+			return meta, nil
+		}
+	}
+
+	meta, err := l.GetFile(path + META_PREFIX)
+	if err != nil {
+		return nil, err
+	}
+	return bytes2Meta(meta), nil
+}
+
+// writeMeta - записывает метаданные файла: при MetaXattr в атрибуты уже существующего
+// файла (мета-файл, оставшийся от записи без атрибутов, удаляется), а если ФС
+// их не поддерживает - в мета-файл
+func (l *Local) writeMeta(path string, meta map[string]string) error {
+	if l.metaBackend == MetaXattr {
+		err := setXattrMeta(path, meta)
+		if err == nil {
+			if err := os.Remove(path + META_PREFIX); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
+		}
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+
+	return l.writeFile(path+META_PREFIX, l.sidecarFormat.encode(meta), perm)
+}
+
+// removeMeta - удаляет метаданные файла из мета-файла и, при MetaXattr, из атрибутов
+func (l *Local) removeMeta(path string) error {
+	if l.metaBackend == MetaXattr {
+		if err := setXattrMeta(path, nil); err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+
+	if err := os.Remove(path + META_PREFIX); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// StatWithContext - возвращает информацию о файле и метаданные
+// path - путь к файлу
+func (l *Local) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	default:
+		return l.Stat(path)
+	}
+}
+
+// StatLite - возвращает информацию о файле без чтения мета-файла
+// path - путь к файлу
+// для отсутствующего файла возвращается ошибка, оборачивающая ErrFileNotFound
+func (l *Local) StatLite(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %w", ErrFileNotFound, err)
 		}
+		return nil, err
 	}
-
-	return nil
+	return info, nil
 }
 
-// MoveFileWithContext - перемещает файл
-// src - исходный путь к файлу
-// dst - путь куда переместить
-func (l *Local) MoveFileWithContext(ctx context.Context, src, dst string) error {
+// StatLiteWithContext - возвращает информацию о файле без чтения мета-файла
+// path - путь к файлу
+func (l *Local) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
-		return l.MoveFile(src, dst)
+		return l.StatLite(path)
 	}
 }
 
-// StreamToFile - записывает содержимое потока в файл
-// stream - поток
+// Lstat - возвращает информацию о файле и метаданные, не переходя по символической ссылке
 // path - путь к файлу
-func (l *Local) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
-	file, err := os.Create(path)
+// для ссылки возвращается информация о самой ссылке и ее собственный мета-файл
+func (l *Local) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	info, meta, err := l.stat(path, os.Lstat)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer file.Close()
 
-	buf := make([]byte, 1024*1024) // 1MB
+	meta, _ = splitCacheControl(meta)
+	return info, meta, nil
+}
 
-	for {
-		n, err := stream.Read(buf)
-		if err != nil && err != io.EOF {
-			return err
-		}
-		if n == 0 {
-			break
-		}
-		_, err = file.Write(buf[:n])
-		if err != nil {
+// LstatWithContext - возвращает информацию о файле и метаданные, не переходя по символической ссылке
+// path - путь к файлу
+func (l *Local) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	default:
+		return l.Lstat(path)
+	}
+}
+
+// Symlink - создает символическую ссылку newname на oldname
+// oldname - путь, на который указывает ссылка; относительный путь считается от директории newname
+// newname - путь к ссылке
+// существующая ссылка newname заменяется атомарно (через временную ссылку и переименование),
+// поэтому читатели видят либо старую, либо новую цель; если newname не ссылка - ErrAlreadyExists
+func (l *Local) Symlink(oldname, newname string) error {
+	if err := l.prepareParent(newname); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(newname)
+	if err != nil {
+		if !os.IsNotExist(err) {
 			return err
 		}
+		return os.Symlink(oldname, newname)
+	}
+
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%w: %s", ErrAlreadyExists, newname)
 	}
 
+	tmp := filepath.Join(filepath.Dir(newname), "."+filepath.Base(newname)+".tmp-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	if err := os.Symlink(oldname, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, newname); err != nil {
+		os.Remove(tmp)
+		return err
+	}
 	return nil
 }
 
-// StreamToFileWithContext - записывает содержимое потока в файл
-// stream - поток
-// path - путь к файлу
-func (l *Local) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+// SymlinkWithContext - создает символическую ссылку newname на oldname
+// oldname - путь, на который указывает ссылка
+// newname - путь к ссылке
+func (l *Local) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
-		return l.StreamToFile(stream, path, ttl)
+		return l.Symlink(oldname, newname)
 	}
 }
 
-// GetFile - возвращает содержимое файла
+// StatObject - возвращает полную информацию о файле
 // path - путь к файлу
-func (l *Local) GetFile(path string) ([]byte, error) {
-	if !l.IsExist(path) {
-		return nil, nil
+// ContentType берется из мета-файла, а без него определяется по расширению файла
+func (l *Local) StatObject(path string) (ObjectInfo, error) {
+	info, meta, err := l.stat(path, os.Stat)
+	if err != nil {
+		return ObjectInfo{}, err
 	}
-	return os.ReadFile(path)
+	contentType, expires := sidecarContentType(meta, path), sidecarExpires(meta)
+	meta, cacheControl := splitCacheControl(meta)
+
+	return ObjectInfo{
+		Name:         info.Name(),
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		IsDir:        info.IsDir(),
+		Meta:         meta,
+		ContentType:  contentType,
+		ETag:         localETag(info),
+		CacheControl: cacheControl,
+		Expires:      expires,
+	}, nil
 }
 
-// GetFileWithContext - возвращает содержимое файла
+// StatObjectWithContext - возвращает полную информацию о файле
 // path - путь к файлу
-func (l *Local) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+// ContentType определяется по расширению файла
+func (l *Local) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return ObjectInfo{}, ctx.Err()
 	default:
-		return l.GetFile(path)
+		return l.StatObject(path)
 	}
 }
 
-// GetFilePartially - возвращает часть содержимого файла
+// PublicURL - возвращает постоянный URL файла
 // path - путь к файлу
-// offset - смещение от начала
-func (l *Local) GetFilePartially(path string, offset, length int64) ([]byte, error) {
-	if !l.IsExist(path) {
-		return nil, nil
+// без PublicBaseURL возвращается file:// URL абсолютного пути
+func (l *Local) PublicURL(path string) (string, error) {
+	if l.publicBaseURL != "" {
+		return joinURL(l.publicBaseURL, path), nil
 	}
 
-	file, err := os.Open(path)
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	return u.String(), nil
+}
+
+// Latest - возвращает самый новый по времени изменения файл директории
+// path - путь к директории
+// мета-файлы и поддиректории не учитываются
+func (l *Local) Latest(path string) (os.FileInfo, error) {
+	entries, err := os.ReadDir(path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
 		return nil, err
 	}
-	defer file.Close()
 
-	if length < 0 {
-		info, _, err := l.Stat(path)
+	var latest os.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), META_PREFIX) {
+			continue
+		}
+
+		info, err := entry.Info()
 		if err != nil {
 			return nil, err
 		}
-		length = info.Size() - offset
+		if latest == nil || info.ModTime().After(latest.ModTime()) {
+			latest = info
+		}
 	}
 
-	buf := make([]byte, length)
-	_, err = file.ReadAt(buf, offset)
-	if err != nil && err != io.EOF {
-		return nil, err
+	if latest == nil {
+		return nil, ErrFileNotFound
 	}
-
-	return buf, nil
+	return latest, nil
 }
 
-// GetFilePartiallyWithContext - возвращает часть содержимого файла
-// path - путь к файлу
-// offset - смещение от начала
-func (l *Local) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+// LatestWithContext - возвращает самый новый по времени изменения файл директории
+// path - путь к директории
+// мета-файлы и поддиректории не учитываются
+func (l *Local) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
-		return l.GetFilePartially(path, offset, length)
+		return l.Latest(path)
 	}
 }
 
-// FileReader - открывает файл на чтение
-// path - путь к файлу
-// offset - смещение от начала
-// length - длина
-func (l *Local) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
-	if !l.IsExist(path) {
-		return nil, nil
+// IsEmpty - проверяет, что в директории нет файлов и поддиректорий
+// path - путь к директории
+// мета-файлы не учитываются; директория читается порциями до первой записи
+func (l *Local) IsEmpty(path string) (bool, error) {
+	if path == "" {
+		path = "."
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return false, err
 	}
+	defer dir.Close()
 
-	return os.Open(path)
+	for {
+		entries, err := dir.ReadDir(listDirChunk)
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), META_PREFIX) {
+				return false, nil
+			}
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
 }
 
-// FileReaderWithContext - открывает файл на чтение
-// path - путь к файлу
-// offset - смещение от начала
-// length - длина
-func (l *Local) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+// IsEmptyWithContext - проверяет, что в директории нет файлов и поддиректорий
+// path - путь к директории
+// мета-файлы не учитываются
+func (l *Local) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return false, ctx.Err()
 	default:
-		return l.FileReader(path, offset, length)
+		return l.IsEmpty(path)
 	}
 }
 
-// RemoveFile - удаляет файл
-// path - путь к файлу
-func (l *Local) RemoveFile(path string) error {
-	os.Remove(path + META_PREFIX)
-	err := os.Remove(path)
-	if err != nil && os.IsNotExist(err) {
-		return ErrFileNotFound
-	}
-	return err
+// ListDirChan - отдает файлы директории в канал по мере чтения
+// path - путь к директории
+// мета-файлы не отдаются; канал закрывается после последнего файла или ошибки
+// канал нужно дочитать до конца, иначе используйте ListDirChanWithContext и отменяйте контекст
+func (l *Local) ListDirChan(path string) <-chan DirEntry {
+	return l.ListDirChanWithContext(context.Background(), path)
 }
 
-// RemoveFileWithContext - удаляет файл
+// ListDirChanWithContext - отдает файлы директории в канал по мере чтения
+// path - путь к директории
+// после отмены контекста листинг прерывается и канал закрывается
+func (l *Local) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		// пустой путь - рабочая директория, как корень бакета в S3
+		if path == "" {
+			path = "."
+		}
+		dir, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				err = fmt.Errorf("%w: %w", ErrFileNotFound, err)
+			}
+			sendDirEntry(ctx, ch, DirEntry{Err: err})
+			return
+		}
+		defer dir.Close()
+
+		for {
+			entries, err := dir.ReadDir(listDirChunk)
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), META_PREFIX) {
+					continue
+				}
+
+				info, err := entry.Info()
+				if err != nil {
+					sendDirEntry(ctx, ch, DirEntry{Err: err})
+					return
+				}
+				if !sendDirEntry(ctx, ch, DirEntry{Info: info}) {
+					return
+				}
+			}
+
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				sendDirEntry(ctx, ch, DirEntry{Err: err})
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// ArchiveDir - пишет в w архив файлов директории со всеми поддиректориями
+// path - путь к директории
+// w - куда писать архив
+// format - формат архива
+// пути в архиве относительны path, время изменения файлов сохраняется
+func (l *Local) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return l.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+// ArchiveDirWithContext - пишет в w архив файлов директории со всеми поддиректориями
+// path - путь к директории
+// w - куда писать архив
+// format - формат архива
+func (l *Local) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	return archiveDir(ctx, l, path, w, format)
+}
+
+// ExtractArchive - распаковывает архив в директорию
+// r - поток архива
+// path - путь к директории, куда распаковывается архив
+// format - формат архива
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey
+// время изменения файлов из архива сохраняется
+func (l *Local) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return l.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - распаковывает архив в директорию
+// r - поток архива
+// path - путь к директории, куда распаковывается архив
+// format - формат архива
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey
+func (l *Local) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, l, r, path, format, extractTarget{
+		mkdir: true,
+		chtimes: func(path string, t time.Time) error {
+			return os.Chtimes(path, t, t)
+		},
+	})
+}
+
+// Manifest - манифест директории со всеми поддиректориями: путь, размер и контрольная сумма каждого файла
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+// записи отсортированы по пути, мета-файлы не включаются
+func (l *Local) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return l.ManifestWithContext(context.Background(), path, algo)
+}
+
+// ManifestWithContext - манифест директории со всеми поддиректориями: путь, размер и контрольная сумма каждого файла
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+func (l *Local) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return manifest(ctx, l, path, algo)
+}
+
+// BlockChecksums - контрольные суммы блоков файла для rsync-подобной синхронизации
 // path - путь к файлу
-func (l *Local) RemoveFileWithContext(ctx context.Context, path string) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-		return l.RemoveFile(path)
-	}
+// blockSize - размер блока в байтах
+// файл читается одним потоком FileReader; разница двух списков - BlockDiff,
+// применение - ApplyBlockPatch
+func (l *Local) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return l.BlockChecksumsWithContext(context.Background(), path, blockSize)
 }
 
-// Stat - возвращает информацию о файле и метаданные
+// BlockChecksumsWithContext - контрольные суммы блоков файла для rsync-подобной синхронизации
 // path - путь к файлу
-func (l *Local) Stat(path string) (os.FileInfo, map[string]string, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil, ErrFileNotFound
-		}
-		return nil, nil, err
-	}
+// blockSize - размер блока в байтах
+func (l *Local) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, l, path, blockSize)
+}
 
-	// get meta data
-	meta, err := l.GetFile(path + META_PREFIX)
-	if err != nil {
-		return nil, nil, err
-	}
+// ListModifiedSince - файлы директории со всеми поддиректориями, измененные не раньше since
+// path - путь к директории
+// since - момент времени
+// Name() результата - путь файла относительно path, мета-файлы не включаются
+func (l *Local) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return l.ListModifiedSinceWithContext(context.Background(), path, since)
+}
 
-	return info, bytes2Meta(meta), nil
+// ListModifiedSinceWithContext - файлы директории со всеми поддиректориями, измененные не раньше since
+// path - путь к директории
+// since - момент времени
+func (l *Local) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	return listModifiedSince(ctx, l, path, since)
 }
 
-// StatWithContext - возвращает информацию о файле и метаданные
-// path - путь к файлу
-func (l *Local) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
-	select {
-	case <-ctx.Done():
-		return nil, nil, ctx.Err()
-	default:
-		return l.Stat(path)
-	}
+// ListDirDepth - файлы и поддиректории директории не глубже maxDepth уровней
+// path - путь к директории
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+// Name() результата - путь относительно path, мета-файлы не включаются
+func (l *Local) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return l.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
+
+// ListDirDepthWithContext - файлы и поддиректории директории не глубже maxDepth уровней
+// path - путь к директории
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+func (l *Local) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	return listDirDepth(ctx, l, path, maxDepth)
+}
+
+// ListMeta - метаданные всех файлов директории со всеми поддиректориями
+// path - путь к директории
+// ключ результата - путь файла относительно path, мета-файлы не включаются
+// метаданные читаются из мета-файлов
+func (l *Local) ListMeta(path string) (map[string]map[string]string, error) {
+	return l.ListMetaWithContext(context.Background(), path)
+}
+
+// ListMetaWithContext - метаданные всех файлов директории со всеми поддиректориями
+// path - путь к директории
+func (l *Local) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	return listMeta(ctx, l, path)
 }
 
 // ClearDir - очищает директорию
 // path - путь к директории
 func (l *Local) ClearDir(path string) error {
+	return l.ClearDirWithContext(context.Background(), path)
+}
+
+// ClearDirWithContext - очищает директорию
+// path - путь к директории
+// контекст проверяется перед удалением каждого файла и директории: при отмене
+// удаление прекращается, а еще не удаленные файлы остаются на месте
+func (l *Local) ClearDirWithContext(ctx context.Context, path string) error {
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -400,33 +1975,94 @@ func (l *Local) ClearDir(path string) error {
 		return ErrIsNotDir
 	}
 
-	d, err := os.Open(path)
+	return removeChildren(ctx, path)
+}
+
+// removeChildren - удаляет содержимое директории рекурсивно, проверяя ctx перед каждым удалением
+func removeChildren(ctx context.Context, dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
 		return err
 	}
-	defer d.Close()
 	names, err := d.Readdirnames(-1)
+	d.Close()
 	if err != nil {
 		return err
 	}
+
 	for _, name := range names {
-		err = os.RemoveAll(filepath.Join(path, name))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, name)
+		info, err := os.Lstat(path)
 		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if info.IsDir() {
+			if err := removeChildren(ctx, path); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 			return err
 		}
 	}
 	return nil
 }
 
-// ClearDirWithContext - очищает директорию
-// path - путь к директории
-func (l *Local) ClearDirWithContext(ctx context.Context, path string) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-		return l.ClearDir(path)
-	}
+// GarbageCollectMeta - удаляет мета-файлы, у которых нет основного файла
+// path - путь к директории, обходится со всеми поддиректориями
+// int - количество удаленных мета-файлов
+// повторный запуск безопасен: мета-файлы существующих файлов не трогаются
+func (l *Local) GarbageCollectMeta(path string) (int, error) {
+	return l.GarbageCollectMetaWithContext(context.Background(), path)
+}
+
+// GarbageCollectMetaWithContext - удаляет мета-файлы, у которых нет основного файла
+// path - путь к директории, обходится со всеми поддиректориями
+// int - количество удаленных мета-файлов
+func (l *Local) GarbageCollectMetaWithContext(ctx context.Context, path string) (int, error) {
+	removed := 0
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == path {
+				return ErrFileNotFound
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(file, META_PREFIX) {
+			return nil
+		}
+
+		_, err = os.Lstat(strings.TrimSuffix(file, META_PREFIX))
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// StartExpiryScavenger - запускает фоновое удаление файлов с истекшим временем жизни
+// root - путь к директории, обходится со всеми поддиректориями
+// interval - период обхода
+// TTL, переданный при записи, хранится в мета-файле, но сам по себе файл не удаляет;
+// первый обход выполняется сразу, и его ошибка возвращается, дальше обход повторяется
+// каждые interval до отмены ctx. Файл, перезаписанный во время обхода, не удаляется
+func (l *Local) StartExpiryScavenger(ctx context.Context, root string, interval time.Duration) error {
+	return startExpiryScavenger(ctx, l, root, interval)
 }
 
 // MkdirAll - создает директорию
@@ -450,13 +2086,13 @@ func (l *Local) MkdirAllWithContext(ctx context.Context, path string) error {
 // path - путь к файлу
 // data - данные
 // meta - метаданные
+// документ больше 5MB кодируется и записывается потоком, не занимая память целиком
 func (l *Local) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
-	content, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return l.CreateFile(path, content, ttl, meta)
+	return createJsonFile(data, func(content []byte) error {
+		return l.CreateFile(path, content, ttl, meta)
+	}, func(r io.Reader) error {
+		return writeJsonStream(l, path, r, ttl, meta)
+	})
 }
 
 // CreateJsonFileWithContext - создает файл с данными в формате JSON
@@ -497,3 +2133,29 @@ func (l *Local) GetJsonFileWithContext(ctx context.Context, path string, file in
 		return l.GetJsonFile(path, file)
 	}
 }
+
+// GetJsonMap - возвращает содержимое JSON файла в виде map
+// path - путь к файлу
+// числа возвращаются как json.Number
+func (l *Local) GetJsonMap(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+	return decodeJsonMap(content)
+}
+
+// GetJsonMapWithContext - возвращает содержимое JSON файла в виде map
+// path - путь к файлу
+// числа возвращаются как json.Number
+func (l *Local) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return l.GetJsonMap(path)
+	}
+}
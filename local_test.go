@@ -0,0 +1,704 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLocalWriteTo(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"small", 10},
+		{"larger than a copy buffer", 100 << 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.bin")
+			s := newTestLocal(t, LocalConfig{})
+			data := bytes.Repeat([]byte{'x'}, tt.size)
+			if err := s.CreateFile(path, data, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			n, err := s.WriteTo(path, &buf)
+			if err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if n != int64(tt.size) {
+				t.Errorf("WriteTo returned %d, want file size %d", n, tt.size)
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				t.Error("WriteTo content differs from the file")
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		s := newTestLocal(t, LocalConfig{})
+		_, err := s.WriteTo(filepath.Join(t.TempDir(), "missing"), &bytes.Buffer{})
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("error = %v, want ErrFileNotFound", err)
+		}
+	})
+}
+
+func TestLocalCreateFileWritesBodyAndMeta(t *testing.T) {
+	tests := []struct {
+		name   string
+		atomic bool
+		meta   map[string]string
+	}{
+		{"no meta", false, nil},
+		{"meta", false, map[string]string{"owner": "alice"}},
+		{"atomic no meta", true, nil},
+		{"atomic meta", true, map[string]string{"owner": "alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a.txt")
+			s := newTestLocal(t, LocalConfig{AtomicWrites: tt.atomic})
+
+			if err := s.CreateFile(path, []byte("content"), nil, tt.meta); err != nil {
+				t.Fatalf("CreateFile: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil || string(got) != "content" {
+				t.Fatalf("file content = %q, %v; want %q", got, err, "content")
+			}
+			_, statErr := os.Stat(path + META_PREFIX)
+			if tt.meta == nil {
+				if !os.IsNotExist(statErr) {
+					t.Errorf("sidecar exists without meta: %v", statErr)
+				}
+			} else {
+				_, meta, err := s.Stat(path)
+				if err != nil {
+					t.Fatalf("Stat: %v", err)
+				}
+				if !reflect.DeepEqual(meta, tt.meta) {
+					t.Errorf("meta = %v, want %v", meta, tt.meta)
+				}
+			}
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+// assertNoTempFiles - в dir не осталось временных файлов атомарной записи
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file left behind: %s", entry.Name())
+		}
+	}
+}
+
+// observingReader - отдает data, а перед последним чтением вызывает observe;
+// при fail вместо конца потока возвращает ошибку
+type observingReader struct {
+	data    []byte
+	observe func()
+	fail    bool
+}
+
+func (r *observingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		r.observe()
+		if r.fail {
+			return 0, errors.New("stream broken")
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[:1])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestLocalAtomicStreamedWrites(t *testing.T) {
+	write := map[string]func(s *Local, path string, r io.Reader) error{
+		"StreamToFile": func(s *Local, path string, r io.Reader) error {
+			return s.StreamToFile(r, path, nil)
+		},
+		"FileWriter": func(s *Local, path string, r io.Reader) error {
+			w, err := s.FileWriter(path, nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				// частичная запись через FileWriter публикуется вызывающим только Close
+				return err
+			}
+			return w.Close()
+		},
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		fail    bool
+		want    string
+		wantErr bool
+	}{
+		{"stream replaces file", "StreamToFile", false, "new content", false},
+		{"broken stream keeps file", "StreamToFile", true, "old", true},
+		{"writer replaces file on close", "FileWriter", false, "new content", false},
+		{"broken writer keeps file", "FileWriter", true, "old", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "a.txt")
+			s := newTestLocal(t, LocalConfig{AtomicWrites: true})
+			if err := s.CreateFile(path, []byte("old"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			r := &observingReader{data: []byte("new content"), fail: tt.fail, observe: func() {
+				// все данные уже переданы, но читатель все еще видит прежний файл
+				if got, _ := os.ReadFile(path); string(got) != "old" {
+					t.Errorf("reader saw %q while writing, want %q", got, "old")
+				}
+			}}
+			err := write[tt.method](s, path, r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got, _ := os.ReadFile(path); string(got) != tt.want {
+				t.Errorf("file content = %q, want %q", got, tt.want)
+			}
+			if !tt.fail {
+				assertNoTempFiles(t, dir)
+			}
+		})
+	}
+}
+
+func TestLocalCopyMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       CopyMode
+		sharesData bool
+	}{
+		{"copy", CopyModeCopy, false},
+		{"hardlink", CopyModeHardlink, true},
+		// на ФС без FICLONE (ext4, tmpfs) - побайтовое копирование без ошибки
+		{"reflink", CopyModeReflink, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "src.txt"), filepath.Join(dir, "dst.txt")
+			s := newTestLocal(t, LocalConfig{CopyMode: tt.mode})
+			meta := map[string]string{"k": "v"}
+			if err := s.CreateFile(src, []byte("original"), nil, meta); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.CopyFile(src, dst, nil, nil); err != nil {
+				t.Fatalf("CopyFile: %v", err)
+			}
+
+			srcInfo, err := os.Stat(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dstInfo, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := os.SameFile(srcInfo, dstInfo); same != tt.sharesData {
+				t.Errorf("src and dst share an inode = %v, want %v", same, tt.sharesData)
+			}
+			if got, _ := os.ReadFile(dst); string(got) != "original" {
+				t.Errorf("dst content = %q, want %q", got, "original")
+			}
+			if _, got, err := s.Stat(dst); err != nil || !reflect.DeepEqual(got, meta) {
+				t.Errorf("dst meta = %v, %v; want %v", got, err, meta)
+			}
+
+			// изменение src на месте видно в dst только у жесткой ссылки
+			f, err := os.OpenFile(src, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteAt([]byte("CHANGED!"), 0); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			want := "original"
+			if tt.sharesData {
+				want = "CHANGED!"
+			}
+			if got, _ := os.ReadFile(dst); string(got) != want {
+				t.Errorf("dst after in-place change of src = %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("hardlink onto itself", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "src.txt"), filepath.Join(dir, "dst.txt")
+		s := newTestLocal(t, LocalConfig{CopyMode: CopyModeHardlink})
+		if err := s.CreateFile(src, []byte("data"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := s.CopyFile(src, dst, nil, nil); err != nil {
+				t.Fatalf("CopyFile #%d: %v", i+1, err)
+			}
+		}
+		if got, _ := os.ReadFile(src); string(got) != "data" {
+			t.Errorf("src content after repeated hardlink = %q", got)
+		}
+	})
+}
+
+func TestLocalSwapFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		metaA, metaB map[string]string
+	}{
+		{"no meta", nil, nil},
+		{"both meta", map[string]string{"name": "a"}, map[string]string{"name": "b"}},
+		{"only first meta", map[string]string{"name": "a"}, nil},
+		{"only second meta", nil, map[string]string{"name": "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+			s := newTestLocal(t, LocalConfig{})
+			if err := s.CreateFile(a, []byte("content a"), nil, tt.metaA); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.CreateFile(b, []byte("content b"), nil, tt.metaB); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.SwapFiles(a, b); err != nil {
+				t.Fatalf("SwapFiles: %v", err)
+			}
+
+			for path, want := range map[string]struct {
+				content string
+				meta    map[string]string
+			}{a: {"content b", tt.metaB}, b: {"content a", tt.metaA}} {
+				if got, _ := os.ReadFile(path); string(got) != want.content {
+					t.Errorf("%s content = %q, want %q", filepath.Base(path), got, want.content)
+				}
+				_, meta, err := s.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(meta) != 0 || len(want.meta) != 0 {
+					if !reflect.DeepEqual(meta, want.meta) {
+						t.Errorf("%s meta = %v, want %v", filepath.Base(path), meta, want.meta)
+					}
+				}
+			}
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), LOCK_SUFFIX) || strings.Contains(entry.Name(), ".swap-") {
+					t.Errorf("left behind after SwapFiles: %s", entry.Name())
+				}
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a.txt")
+		s := newTestLocal(t, LocalConfig{})
+		if err := s.CreateFile(a, []byte("a"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SwapFiles(a, filepath.Join(dir, "missing")); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("error = %v, want ErrFileNotFound", err)
+		}
+		if _, err := os.Stat(a + LOCK_SUFFIX); !os.IsNotExist(err) {
+			t.Errorf("lock file left after a failed swap: %v", err)
+		}
+	})
+
+	t.Run("concurrent swaps", func(t *testing.T) {
+		dir := t.TempDir()
+		a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+		s := newTestLocal(t, LocalConfig{})
+		if err := s.CreateFile(a, []byte("content a"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateFile(b, []byte("content b"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		stop := make(chan struct{})
+		missing := make(chan string, 1)
+		go func() {
+			for {
+				select {
+				case <-stop:
+					close(missing)
+					return
+				default:
+				}
+				for _, path := range []string{a, b} {
+					if _, err := os.Stat(path); err != nil {
+						select {
+						case missing <- filepath.Base(path):
+						default:
+						}
+					}
+				}
+			}
+		}()
+
+		// четное число обменов, в том числе в обратном порядке путей, возвращает файлы на место
+		const swaps = 20
+		errs := make(chan error, swaps)
+		for i := 0; i < swaps; i++ {
+			x, y := a, b
+			if i%2 == 1 {
+				x, y = b, a
+			}
+			go func() { errs <- s.SwapFiles(x, y) }()
+		}
+		for i := 0; i < swaps; i++ {
+			if err := <-errs; err != nil {
+				t.Errorf("SwapFiles: %v", err)
+			}
+		}
+		close(stop)
+
+		if path, ok := <-missing; ok {
+			t.Errorf("%s was absent during a swap", path)
+		}
+		if got, _ := os.ReadFile(a); string(got) != "content a" {
+			t.Errorf("a after %d swaps = %q, want %q", swaps, got, "content a")
+		}
+		if got, _ := os.ReadFile(b); string(got) != "content b" {
+			t.Errorf("b after %d swaps = %q, want %q", swaps, got, "content b")
+		}
+	})
+}
+
+func TestLocalMoveFileNoOverwriteRace(t *testing.T) {
+	s := newTestLocal(t, LocalConfig{})
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "dst.txt")
+
+	const movers = 16
+	for i := 0; i < movers; i++ {
+		if err := s.CreateFile(filepath.Join(dir, fmt.Sprintf("src%d.txt", i)), []byte(strconv.Itoa(i)), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// все переносы начинаются одновременно, занять dst должен ровно один
+	start := make(chan struct{})
+	errs := make([]error, movers)
+	var wg sync.WaitGroup
+	for i := 0; i < movers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = s.MoveFileNoOverwrite(filepath.Join(dir, fmt.Sprintf("src%d.txt", i)), dst)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil && winner >= 0:
+			t.Fatalf("both %d and %d moved onto dst", winner, i)
+		case err == nil:
+			winner = i
+		case !errors.Is(err, ErrAlreadyExists):
+			t.Errorf("mover %d: %v, want ErrAlreadyExists", i, err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no mover took dst")
+	}
+	if got, _ := os.ReadFile(dst); string(got) != strconv.Itoa(winner) {
+		t.Errorf("dst = %q, want the content of src%d", got, winner)
+	}
+	for i := 0; i < movers; i++ {
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("src%d.txt", i)))
+		if i == winner && !os.IsNotExist(err) {
+			t.Errorf("src%d is left after the move", i)
+		}
+		if i != winner && err != nil {
+			t.Errorf("src%d is lost: %v", i, err)
+		}
+	}
+}
+
+// cancelAfterCtx - контекст, который считается отмененным после checks вызовов Err
+type cancelAfterCtx struct {
+	context.Context
+	mu     sync.Mutex
+	checks int
+}
+
+func (c *cancelAfterCtx) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.checks <= 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+func TestLocalClearDirCanceled(t *testing.T) {
+	s := newTestLocal(t, LocalConfig{})
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		for _, d := range []string{dir, filepath.Join(dir, "sub")} {
+			if err := os.WriteFile(filepath.Join(d, fmt.Sprintf("f%d", i)), []byte("x"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	before := len(listTree(t, dir))
+
+	// отмена после трех удалений
+	ctx := &cancelAfterCtx{Context: context.Background(), checks: 3}
+	err := s.ClearDirWithContext(ctx, dir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ClearDirWithContext = %v, want context.Canceled", err)
+	}
+
+	left := len(listTree(t, dir))
+	if left == 0 || left == before {
+		t.Errorf("%d of %d entries left, want a partial removal", left, before)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("the cleared directory itself is removed: %v", err)
+	}
+
+	if err := s.ClearDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if left := listTree(t, dir); len(left) != 0 {
+		t.Errorf("entries left after a full ClearDir: %v", left)
+	}
+}
+
+func TestLocalSymlink(t *testing.T) {
+	root := t.TempDir()
+	s := newTestLocal(t, LocalConfig{CreateParents: true})
+	for _, v := range []string{"v1", "v2"} {
+		if err := s.CreateFile(filepath.Join(root, "releases", v, "app.txt"), []byte(v), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := filepath.Join(root, "current")
+
+	// абсолютная цель, затем переключение на относительную
+	for _, tt := range []struct{ target, want string }{
+		{filepath.Join(root, "releases", "v1"), "v1"},
+		{filepath.Join("releases", "v2"), "v2"},
+	} {
+		if err := s.Symlink(tt.target, current); err != nil {
+			t.Fatalf("Symlink(%s): %v", tt.target, err)
+		}
+		if got, err := s.GetFile(filepath.Join(current, "app.txt")); err != nil || string(got) != tt.want {
+			t.Errorf("read through current = %q, %v; want %q", got, err, tt.want)
+		}
+		if target, err := os.Readlink(current); err != nil || target != tt.target {
+			t.Errorf("current -> %q, %v; want %q", target, err, tt.target)
+		}
+	}
+	// переключение не оставляет временных ссылок
+	assertNoTempFiles(t, root)
+
+	// Stat переходит по ссылке, Lstat - нет
+	if info, _, err := s.Stat(current); err != nil || !info.IsDir() {
+		t.Errorf("Stat(current) = %v, %v; want the release directory", info, err)
+	}
+	if info, _, err := s.Lstat(current); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Lstat(current) = %v, %v; want the link itself", info, err)
+	}
+
+	t.Run("file link", func(t *testing.T) {
+		target, link := filepath.Join(root, "data.txt"), filepath.Join(root, "link.txt")
+		if err := s.CreateFile(target, []byte("payload"), nil, map[string]string{"owner": "alice"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetFile(link); err != nil || string(got) != "payload" {
+			t.Errorf("GetFile(link) = %q, %v; want payload", got, err)
+		}
+		if info, _, err := s.Stat(link); err != nil || info.Size() != int64(len("payload")) {
+			t.Errorf("Stat(link) = %v, %v; want the target size", info, err)
+		}
+		// у самой ссылки свой мета-файл, метаданные цели не видны
+		if info, meta, err := s.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 || len(meta) != 0 {
+			t.Errorf("Lstat(link) = %v, %v, %v; want the link without meta", info, meta, err)
+		}
+	})
+
+	t.Run("dangling link", func(t *testing.T) {
+		link := filepath.Join(root, "dangling")
+		if err := s.Symlink(filepath.Join(root, "missing"), link); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.Stat(link); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("Stat of a dangling link = %v, want ErrFileNotFound", err)
+		}
+		if _, _, err := s.Lstat(link); err != nil {
+			t.Errorf("Lstat of a dangling link = %v, want the link", err)
+		}
+	})
+
+	t.Run("regular file in the way", func(t *testing.T) {
+		path := filepath.Join(root, "regular.txt")
+		if err := s.CreateFile(path, []byte("keep"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Symlink(filepath.Join(root, "data.txt"), path); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("Symlink over a regular file = %v, want ErrAlreadyExists", err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != "keep" {
+			t.Errorf("regular file = %q after a rejected Symlink, want it untouched", got)
+		}
+	})
+
+	t.Run("unsupported backends", func(t *testing.T) {
+		w, _ := newTestWebDavDir(t, WebDavConfig{})
+		b, _ := newFakeS3(t, S3Config{})
+		for _, other := range []StoreIFace{w, b} {
+			if err := other.Symlink("a", "b"); !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("%s Symlink = %v, want errors.ErrUnsupported", other.Backend(), err)
+			}
+		}
+	})
+}
+
+func TestLocalCreateTempFile(t *testing.T) {
+	t.Run("configured dir", func(t *testing.T) {
+		dir := t.TempDir()
+		s := newTestLocal(t, LocalConfig{TempDir: dir, Scratch: true})
+
+		seen := map[string]bool{}
+		var first string
+		for i := 0; i < 20; i++ {
+			path, err := s.CreateTempFile("scratch-*.bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Base(path)
+			if filepath.Dir(path) != dir || !strings.HasPrefix(name, "scratch-") || !strings.HasSuffix(name, ".bin") {
+				t.Errorf("temp file %s, want scratch-*.bin in %s", path, dir)
+			}
+			if seen[path] {
+				t.Errorf("temp file %s created twice", path)
+			}
+			seen[path] = true
+			if first == "" {
+				first = path
+			}
+			if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+				t.Errorf("temp file %s = %v, %v; want an empty file", path, info, err)
+			}
+		}
+
+		// временный файл - обычный файл хранилища
+		if err := s.CreateFile(first, []byte("data"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetFile(first); err != nil || string(got) != "data" {
+			t.Errorf("GetFile = %q, %v; want data", got, err)
+		}
+		for path := range seen {
+			if err := s.RemoveFile(path); err != nil {
+				t.Errorf("RemoveFile(%s): %v", path, err)
+			}
+		}
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+			t.Errorf("%d entries left in %s, %v; want none after RemoveFile", len(entries), dir, err)
+		}
+	})
+
+	t.Run("default dir", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		scratch := tmp
+		if info, err := os.Stat(scratchDir); err == nil && info.IsDir() {
+			scratch = scratchDir
+		}
+
+		for _, tt := range []struct {
+			name    string
+			scratch bool
+			want    string
+		}{
+			{"os temp dir", false, tmp},
+			{"scratch", true, scratch},
+		} {
+			s := newTestLocal(t, LocalConfig{Scratch: tt.scratch})
+			path, err := s.CreateTempFile("scratch-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Remove(path) })
+			if filepath.Dir(path) != tt.want {
+				t.Errorf("%s: temp file %s, want it in %s", tt.name, path, tt.want)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		s := newTestLocal(t, LocalConfig{TempDir: filepath.Join(t.TempDir(), "missing")})
+		if path, err := s.CreateTempFile("scratch-*"); err == nil {
+			t.Errorf("CreateTempFile in a missing dir = %s, want an error", path)
+		}
+
+		s = newTestLocal(t, LocalConfig{TempDir: t.TempDir()})
+		if path, err := s.CreateTempFile("sub/scratch-*"); err == nil {
+			t.Errorf("CreateTempFile with a separator in the pattern = %s, want an error", path)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := s.CreateTempFileWithContext(ctx, "scratch-*"); !errors.Is(err, context.Canceled) {
+			t.Errorf("CreateTempFileWithContext = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// LOCK_SUFFIX - суффикс файла блокировки, который Lock создает рядом с path
+	LOCK_SUFFIX = ".lock"
+	// defaultLockTTL - время жизни блокировки, если ttl не задан
+	defaultLockTTL = 30 * time.Second
+	// lockRetryDelay - пауза между попытками взять занятую блокировку
+	lockRetryDelay = 50 * time.Millisecond
+)
+
+// Lock - берет блокировку path в хранилище s, ожидая ее освобождения
+// ctx - контекст, отмена прерывает ожидание
+// s - хранилище
+// path - путь, который блокируется; блокировка хранится в path+LOCK_SUFFIX
+// ttl - время, через которое блокировка, не снятая держателем (например, после падения процесса),
+// может быть снята другим; 0 - defaultLockTTL
+// Возвращает функцию снятия блокировки; она возвращает ErrLockLost, если за это время
+// блокировку сняли по ttl или ее взял другой держатель.
+// Блокировка работает между процессами, использующими одно хранилище: файл создается
+// атомарно (PutOptions.Overwrite = OverwriteFail), а снимается RemoveFileIfMatch.
+// Пока блокировка взята, файл блокировки виден в листингах (ListDirChan, ListDirDepth и т.д.)
+func Lock(ctx context.Context, s StoreIFace, path string, ttl time.Duration) (func() error, error) {
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	lockPath := path + LOCK_SUFFIX
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+
+	for {
+		expires := time.Now().Add(ttl)
+		body := []byte(token + "\n" + expires.UTC().Format(time.RFC3339Nano))
+		err := s.CreateFileWithOptionsWithContext(ctx, lockPath, body, PutOptions{Overwrite: OverwriteFail})
+		if err == nil {
+			return func() error { return unlock(s, lockPath, token) }, nil
+		}
+		if !errors.Is(err, ErrAlreadyExists) {
+			return nil, err
+		}
+
+		if err := breakExpiredLock(ctx, s, lockPath); err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryDelay):
+		}
+	}
+}
+
+// lockPaths - берет Lock каждого из paths в порядке сортировки, чтобы одновременные
+// вызовы с теми же путями в другом порядке не ждали друг друга бесконечно
+// возвращает функцию, снимающую все блокировки
+func lockPaths(ctx context.Context, s StoreIFace, paths ...string) (func() error, error) {
+	paths = append([]string(nil), paths...)
+	sort.Strings(paths)
+
+	var unlocks []func() error
+	unlockAll := func() error {
+		var errs []error
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			errs = append(errs, unlocks[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	for i, path := range paths {
+		if i > 0 && path == paths[i-1] {
+			continue
+		}
+		unlock, err := Lock(ctx, s, path, 0)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// breakExpiredLock - удаляет файл блокировки lockPath, если ее время жизни прошло
+// файл удаляется только в той версии, которая была прочитана, поэтому блокировку,
+// взятую заново между чтением и удалением, он не снимает
+func breakExpiredLock(ctx context.Context, s StoreIFace, lockPath string) error {
+	obj, err := s.StatObjectWithContext(ctx, lockPath)
+	if errors.Is(err, ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	body, err := s.GetFileWithContext(ctx, lockPath)
+	if err != nil || body == nil {
+		// блокировку сняли после StatObject
+		return nil
+	}
+
+	_, expires, ok := parseLock(body)
+	if !ok {
+		// файл еще не дописан или поврежден - отсчитываем срок от времени изменения
+		expires = obj.ModTime.Add(defaultLockTTL)
+	}
+	if time.Now().Before(expires) {
+		return nil
+	}
+	// ErrConflict - блокировку уже перехватили после StatObject
+	if _, err = s.RemoveFileIfMatchWithContext(ctx, lockPath, obj.ETag); errors.Is(err, ErrConflict) {
+		return nil
+	}
+	return err
+}
+
+// unlock - снимает блокировку lockPath, если она все еще принадлежит token
+func unlock(s StoreIFace, lockPath, token string) error {
+	ctx := context.Background()
+	obj, err := s.StatObjectWithContext(ctx, lockPath)
+	if errors.Is(err, ErrFileNotFound) {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	body, err := s.GetFileWithContext(ctx, lockPath)
+	if err != nil {
+		return err
+	}
+	if owner, _, _ := parseLock(body); body == nil || owner != token {
+		return ErrLockLost
+	}
+
+	removed, err := s.RemoveFileIfMatchWithContext(ctx, lockPath, obj.ETag)
+	if errors.Is(err, ErrConflict) {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// parseLock - токен держателя и время жизни из содержимого файла блокировки
+func parseLock(body []byte) (string, time.Time, bool) {
+	token, rest, ok := strings.Cut(string(body), "\n")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	expires, err := time.Parse(time.RFC3339Nano, rest)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return token, expires, true
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s StoreIFace, path string)
+	}{
+		{"second holder waits for unlock", func(t *testing.T, s StoreIFace, path string) {
+			unlock, err := Lock(context.Background(), s, path, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			acquired := make(chan func() error)
+			go func() {
+				unlock2, err := Lock(context.Background(), s, path, time.Minute)
+				if err != nil {
+					t.Error(err)
+				}
+				acquired <- unlock2
+			}()
+
+			select {
+			case <-acquired:
+				t.Fatal("second Lock acquired a held lock")
+			case <-time.After(200 * time.Millisecond):
+			}
+			if err := unlock(); err != nil {
+				t.Fatalf("unlock: %v", err)
+			}
+			select {
+			case unlock2 := <-acquired:
+				if err := unlock2(); err != nil {
+					t.Errorf("second unlock: %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("second Lock did not acquire a released lock")
+			}
+		}},
+		{"expired lock is broken", func(t *testing.T, s StoreIFace, path string) {
+			unlock, err := Lock(context.Background(), s, path, 50*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			unlock2, err := Lock(ctx, s, path, time.Minute)
+			if err != nil {
+				t.Fatalf("Lock over an expired lock: %v", err)
+			}
+			if err := unlock(); !errors.Is(err, ErrLockLost) {
+				t.Errorf("unlock of a broken lock = %v, want ErrLockLost", err)
+			}
+			if err := unlock2(); err != nil {
+				t.Errorf("unlock of the new lock: %v", err)
+			}
+		}},
+		{"cancel while waiting", func(t *testing.T, s StoreIFace, path string) {
+			unlock, err := Lock(context.Background(), s, path, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer unlock()
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if _, err := Lock(ctx, s, path, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want context.DeadlineExceeded", err)
+			}
+		}},
+		{"unlock removes the lock file", func(t *testing.T, s StoreIFace, path string) {
+			unlock, err := Lock(context.Background(), s, path, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := unlock(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(path + LOCK_SUFFIX); !os.IsNotExist(err) {
+				t.Errorf("lock file left after unlock: %v", err)
+			}
+			if err := unlock(); !errors.Is(err, ErrLockLost) {
+				t.Errorf("repeated unlock = %v, want ErrLockLost", err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newTestLocal(t, LocalConfig{}), filepath.Join(t.TempDir(), "a.log"))
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	s := newTestLocal(t, LocalConfig{})
+	if err := s.CreateFile(path, []byte("old\n"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := s.Rotate(path)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if got, _ := os.ReadFile(rotated); string(got) != "old\n" {
+		t.Errorf("rotated content = %q, want %q", got, "old\n")
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("active file after Rotate: %v, %v; want an empty file", info, err)
+	}
+	if _, err := os.Stat(path + LOCK_SUFFIX); !os.IsNotExist(err) {
+		t.Errorf("lock file left after Rotate: %v", err)
+	}
+}
+
+func TestRotateConcurrentAppends(t *testing.T) {
+	const (
+		writers = 4
+		lines   = 25
+	)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	s := newTestLocal(t, LocalConfig{})
+	if err := s.CreateFile(path, []byte{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// appendLine - дописывает строку в path под той же блокировкой, что и Rotate
+	appendLine := func(line string) error {
+		unlock, err := Lock(context.Background(), s, path, 0)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = s.CreateFile(path, append(data, line+"\n"...), nil, nil)
+		}
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				if err := appendLine(fmt.Sprintf("%d-%d", w, i)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for rotating := true; rotating; {
+		select {
+		case <-done:
+			rotating = false
+		case <-time.After(5 * time.Millisecond):
+			// метка времени в имени - миллисекунды, повтор в ту же миллисекунду дает ErrAlreadyExists
+			if _, err := s.Rotate(path); err != nil && !errors.Is(err, ErrAlreadyExists) {
+				t.Fatalf("Rotate: %v", err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]int{}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), META_PREFIX) || strings.HasSuffix(entry.Name(), LOCK_SUFFIX) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Fields(string(data)) {
+			seen[line]++
+		}
+	}
+	for w := 0; w < writers; w++ {
+		for i := 0; i < lines; i++ {
+			if line := fmt.Sprintf("%d-%d", w, i); seen[line] != 1 {
+				t.Errorf("line %s found %d times, want once", line, seen[line])
+			}
+		}
+	}
+}
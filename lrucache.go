@@ -0,0 +1,423 @@
+package store
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithLRUCache - оборачивает хранилище кешем содержимого файлов в памяти процесса
+// s - хранилище
+// maxBytes - наибольший суммарный размер закешированных файлов; при превышении
+// вытесняются давно не читавшиеся файлы, файл больше maxBytes не кешируется
+// ttl - сколько файл живет в кеше, 0 - пока не вытеснен
+// кешируются GetFile, GetJsonFile и GetJsonMap; GetFilePartially отдается из кеша,
+// только если файл закеширован целиком. Запись, перемещение и удаление файла через
+// это хранилище сбрасывают его из кеша, изменения в обход него видны только по истечении ttl
+func WithLRUCache(s StoreIFace, maxBytes int64, ttl time.Duration) StoreIFace {
+	return &lruCached{
+		StoreIFace: s,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+type lruCached struct {
+	StoreIFace
+	maxBytes int64
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	order *list.List // в начале - последние прочитанные
+	items map[string]*list.Element
+	size  int64
+	// gen - номер изменения: чтение, начатое до записи, не кладет в кеш устаревшее содержимое
+	gen uint64
+}
+
+type lruEntry struct {
+	path    string
+	file    []byte
+	expires time.Time
+}
+
+// get - содержимое файла из кеша
+func (c *lruCached) get(path string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[path]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.file, true
+}
+
+// put - кладет содержимое файла в кеш, если с начала чтения (gen) файлы не менялись
+func (c *lruCached) put(path string, file []byte, gen uint64) {
+	size := int64(len(file))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	if el, ok := c.items[path]; ok {
+		c.remove(el)
+	}
+
+	entry := &lruEntry{path: path, file: bytes.Clone(file)}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	c.items[path] = c.order.PushFront(entry)
+	c.size += size
+
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove - удаляет запись кеша, вызывается под c.mu
+func (c *lruCached) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*lruEntry)
+	delete(c.items, entry.path)
+	c.size -= int64(len(entry.file))
+}
+
+// generation - номер изменения перед чтением из хранилища
+func (c *lruCached) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// invalidate - сбрасывает файлы из кеша
+func (c *lruCached) invalidate(paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, path := range paths {
+		if el, ok := c.items[path]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// invalidateDir - сбрасывает из кеша все файлы директории
+func (c *lruCached) invalidateDir(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for path, el := range c.items {
+		if dir == "" || dir == "." || strings.HasPrefix(path, prefix) {
+			c.remove(el)
+		}
+	}
+}
+
+// getFile - содержимое файла из кеша или из хранилища
+// возвращаемый срез общий с кешем и не должен изменяться
+func (c *lruCached) getFile(ctx context.Context, path string) ([]byte, error) {
+	if file, ok := c.get(path); ok {
+		return file, nil
+	}
+
+	gen := c.generation()
+	file, err := c.StoreIFace.GetFileWithContext(ctx, path)
+	if err != nil || file == nil {
+		return file, err
+	}
+	c.put(path, file, gen)
+	return file, nil
+}
+
+func (c *lruCached) GetFile(path string) ([]byte, error) {
+	return c.GetFileWithContext(context.Background(), path)
+}
+
+func (c *lruCached) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	file, err := c.getFile(ctx, path)
+	if err != nil || file == nil {
+		return file, err
+	}
+	return bytes.Clone(file), nil
+}
+
+func (c *lruCached) GetJsonFile(path string, file interface{}) error {
+	return c.GetJsonFileWithContext(context.Background(), path, file)
+}
+
+func (c *lruCached) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	content, err := c.getFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		return nil
+	}
+	return json.Unmarshal(content, file)
+}
+
+func (c *lruCached) GetJsonMap(path string) (map[string]interface{}, error) {
+	return c.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (c *lruCached) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	content, err := c.getFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, ErrFileNotFound
+	}
+	return decodeJsonMap(content)
+}
+
+func (c *lruCached) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return c.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+// GetFilePartiallyWithContext - часть отдается из кеша, только если файл закеширован целиком,
+// иначе читается из хранилища без кеширования
+func (c *lruCached) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	file, ok := c.get(path)
+	if !ok {
+		return c.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+	}
+
+	length, err := partialLength(int64(len(file)), offset, length)
+	if err != nil {
+		return []byte{}, err
+	}
+	return bytes.Clone(file[offset : offset+length]), nil
+}
+
+func (c *lruCached) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return c.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (c *lruCached) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.CreateFileWithContext(ctx, path, file, ttl, meta)
+}
+
+func (c *lruCached) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return c.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+func (c *lruCached) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+}
+
+func (c *lruCached) Reserve(path string) error {
+	return c.ReserveWithContext(context.Background(), path)
+}
+
+func (c *lruCached) ReserveWithContext(ctx context.Context, path string) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.ReserveWithContext(ctx, path)
+}
+
+func (c *lruCached) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return c.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+func (c *lruCached) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	defer c.invalidate(dst)
+	return c.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+}
+
+func (c *lruCached) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return c.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+func (c *lruCached) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	defer c.invalidate(dst)
+	return c.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+}
+
+func (c *lruCached) ExtractRange(src string, offset, length int64, dst string) error {
+	return c.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+func (c *lruCached) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	defer c.invalidate(dst)
+	return c.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+}
+
+func (c *lruCached) Truncate(path string, size int64) error {
+	return c.TruncateWithContext(context.Background(), path, size)
+}
+
+func (c *lruCached) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.TruncateWithContext(ctx, path, size)
+}
+
+func (c *lruCached) CopyFileIfChanged(src, dst string) (bool, error) {
+	return c.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+func (c *lruCached) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	defer c.invalidate(dst)
+	return c.StoreIFace.CopyFileIfChangedWithContext(ctx, src, dst)
+}
+
+func (c *lruCached) MoveFile(src, dst string) error {
+	return c.MoveFileWithContext(context.Background(), src, dst)
+}
+
+func (c *lruCached) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	defer c.invalidate(src, dst)
+	return c.StoreIFace.MoveFileWithContext(ctx, src, dst)
+}
+
+func (c *lruCached) MoveFileNoOverwrite(src, dst string) error {
+	return c.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
+}
+
+func (c *lruCached) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	defer c.invalidate(src, dst)
+	return c.StoreIFace.MoveFileNoOverwriteWithContext(ctx, src, dst)
+}
+
+func (c *lruCached) SwapFiles(a, b string) error {
+	return c.SwapFilesWithContext(context.Background(), a, b)
+}
+
+func (c *lruCached) SwapFilesWithContext(ctx context.Context, a, b string) error {
+	defer c.invalidate(a, b)
+	return c.StoreIFace.SwapFilesWithContext(ctx, a, b)
+}
+
+func (c *lruCached) Rotate(path string) (string, error) {
+	return c.RotateWithContext(context.Background(), path)
+}
+
+func (c *lruCached) RotateWithContext(ctx context.Context, path string) (string, error) {
+	defer c.invalidate(path)
+	return c.StoreIFace.RotateWithContext(ctx, path)
+}
+
+func (c *lruCached) Symlink(oldname, newname string) error {
+	return c.SymlinkWithContext(context.Background(), oldname, newname)
+}
+
+// SymlinkWithContext - ссылка может указывать на директорию, поэтому сбрасываются
+// и сам newname, и все закешированные пути под ним
+func (c *lruCached) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	defer c.invalidateDir(newname)
+	defer c.invalidate(newname)
+	return c.StoreIFace.SymlinkWithContext(ctx, oldname, newname)
+}
+
+func (c *lruCached) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return c.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (c *lruCached) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.StreamToFileWithContext(ctx, stream, path, ttl)
+}
+
+func (c *lruCached) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return c.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+func (c *lruCached) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	defer c.invalidate(path)
+	return c.StoreIFace.StreamToFileNWithContext(ctx, stream, path, ttl)
+}
+
+func (c *lruCached) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return c.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+// FileWriterWithContext - файл сбрасывается из кеша при открытии и еще раз при закрытии записи
+func (c *lruCached) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	c.invalidate(path)
+	w, err := c.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+	if err != nil {
+		return nil, err
+	}
+	return &lruInvalidatingWriter{WriteCloser: w, cache: c, path: path}, nil
+}
+
+// lruInvalidatingWriter - сбрасывает файл из кеша после закрытия записи
+type lruInvalidatingWriter struct {
+	io.WriteCloser
+	cache *lruCached
+	path  string
+}
+
+func (w *lruInvalidatingWriter) Close() error {
+	defer w.cache.invalidate(w.path)
+	return w.WriteCloser.Close()
+}
+
+func (c *lruCached) RemoveFile(path string) error {
+	return c.RemoveFileWithContext(context.Background(), path)
+}
+
+func (c *lruCached) RemoveFileWithContext(ctx context.Context, path string) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.RemoveFileWithContext(ctx, path)
+}
+
+func (c *lruCached) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	return c.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+func (c *lruCached) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	defer c.invalidate(path)
+	return c.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+}
+
+func (c *lruCached) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return c.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+func (c *lruCached) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	defer c.invalidate(path)
+	return c.StoreIFace.CreateJsonFileWithContext(ctx, path, data, ttl, meta)
+}
+
+func (c *lruCached) ClearDir(path string) error {
+	return c.ClearDirWithContext(context.Background(), path)
+}
+
+func (c *lruCached) ClearDirWithContext(ctx context.Context, path string) error {
+	defer c.invalidateDir(path)
+	return c.StoreIFace.ClearDirWithContext(ctx, path)
+}
+
+func (c *lruCached) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return c.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+func (c *lruCached) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	defer c.invalidateDir(path)
+	return c.StoreIFace.ExtractArchiveWithContext(ctx, r, path, format)
+}
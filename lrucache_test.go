@@ -0,0 +1,148 @@
+package store
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// getsOf - количество GET-запросов к ключу key бакета "bucket"
+func getsOf(f *fakeS3, key string) int {
+	n := 0
+	for _, r := range f.requestsTo(http.MethodGet, "") {
+		if bucket, k := bucketKey(r); bucket == "bucket" && k == key {
+			n++
+		}
+	}
+	return n
+}
+
+// readThrough - читает файл и проверяет содержимое
+func readThrough(t *testing.T, s StoreIFace, path, want string) {
+	t.Helper()
+	got, err := s.GetFile(path)
+	if err != nil || string(got) != want {
+		t.Fatalf("GetFile(%s) = %q, %v; want %q", path, got, err, want)
+	}
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	raw, f := newFakeS3(t, S3Config{})
+	f.put("a", []byte("aaaa"), nil)
+	f.put("b", []byte("bbbb"), nil)
+	f.put("c", []byte("cccc"), nil)
+	f.put("big", []byte("0123456789ab"), nil)
+	s := WithLRUCache(raw, 10, 0)
+
+	readThrough(t, s, "a", "aaaa")
+	readThrough(t, s, "b", "bbbb")
+	// a становится последним прочитанным, b - самым давним
+	readThrough(t, s, "a", "aaaa")
+	if n := getsOf(f, "a"); n != 1 {
+		t.Fatalf("GET a = %d after a cached read, want 1", n)
+	}
+
+	// c не помещается вместе с a и b: вытесняется b
+	readThrough(t, s, "c", "cccc")
+	readThrough(t, s, "a", "aaaa")
+	readThrough(t, s, "c", "cccc")
+	if a, c := getsOf(f, "a"), getsOf(f, "c"); a != 1 || c != 1 {
+		t.Errorf("GET a = %d, c = %d after eviction, want both still cached (1)", a, c)
+	}
+	readThrough(t, s, "b", "bbbb")
+	if n := getsOf(f, "b"); n != 2 {
+		t.Errorf("GET b = %d, want 2: the least recently used file is evicted", n)
+	}
+
+	// файл больше maxBytes не кешируется и не вытесняет остальные
+	readThrough(t, s, "big", "0123456789ab")
+	readThrough(t, s, "big", "0123456789ab")
+	if n := getsOf(f, "big"); n != 2 {
+		t.Errorf("GET big = %d, want 2: a file above maxBytes is not cached", n)
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	raw, f := newFakeS3(t, S3Config{})
+	f.put("a", []byte("v1"), nil)
+	s := WithLRUCache(raw, 1<<10, 30*time.Millisecond)
+
+	readThrough(t, s, "a", "v1")
+	// изменение в обход кеша видно только по истечении ttl
+	f.put("a", []byte("v2"), nil)
+	readThrough(t, s, "a", "v1")
+	time.Sleep(50 * time.Millisecond)
+	readThrough(t, s, "a", "v2")
+}
+
+func TestLRUCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name string
+		// mutate - изменение через кеш; want - содержимое путей после него, "" - файла нет
+		mutate func(s StoreIFace) error
+		want   map[string]string
+	}{
+		{"CreateFile", func(s StoreIFace) error {
+			return s.CreateFile("a", []byte("new a"), nil, nil)
+		}, map[string]string{"a": "new a", "b": "b"}},
+		{"RemoveFile", func(s StoreIFace) error {
+			return s.RemoveFile("a")
+		}, map[string]string{"a": "", "b": "b"}},
+		{"MoveFile", func(s StoreIFace) error {
+			return s.MoveFile("a", "b")
+		}, map[string]string{"a": "", "b": "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, f := newFakeS3(t, S3Config{})
+			f.put("a", []byte("a"), nil)
+			f.put("b", []byte("b"), nil)
+			s := WithLRUCache(raw, 1<<10, 0)
+
+			readThrough(t, s, "a", "a")
+			readThrough(t, s, "b", "b")
+
+			if err := tt.mutate(s); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+
+			for path, want := range tt.want {
+				got, err := s.GetFile(path)
+				if want == "" {
+					if err == nil && got != nil {
+						t.Errorf("GetFile(%s) = %q after %s, want no file", path, got, tt.name)
+					}
+					continue
+				}
+				if err != nil || string(got) != want {
+					t.Errorf("GetFile(%s) = %q, %v after %s; want %q", path, got, err, tt.name, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLRUCacheSymlinkDir(t *testing.T) {
+	root := t.TempDir()
+	s := WithLRUCache(newTestLocal(t, LocalConfig{CreateParents: true}), 1<<10, 0)
+	for _, v := range []string{"v1", "v2"} {
+		if err := s.CreateFile(filepath.Join(root, v, "app.txt"), []byte(v), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	current := filepath.Join(root, "current")
+	app := filepath.Join(current, "app.txt")
+
+	if err := s.Symlink("v1", current); err != nil {
+		t.Fatal(err)
+	}
+	readThrough(t, s, app, "v1")
+
+	// переключение ссылки на директорию сбрасывает закешированные файлы под ней
+	if err := s.Symlink("v2", current); err != nil {
+		t.Fatal(err)
+	}
+	readThrough(t, s, app, "v2")
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// manifestConcurrency - сколько файлов Manifest хеширует одновременно
+const manifestConcurrency = 8
+
+// ManifestEntry - файл манифеста директории
+// Path - путь относительно директории манифеста
+// Size - размер в байтах
+// Checksum - контрольная сумма содержимого (hex)
+type ManifestEntry struct {
+	Path     string
+	Size     int64
+	Checksum string
+}
+
+// manifest - обходит директорию и считает контрольные суммы файлов
+// параллельно с ограничением manifestConcurrency; мета-файлы не обходятся.
+// Для md5 используется ETag из листинга S3, если он является md5 содержимого
+// (не multipart), иначе содержимое читается потоком.
+// Записи отсортированы по пути.
+func manifest(ctx context.Context, s StoreIFace, dir string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	if algo != ChecksumMD5 && algo != ChecksumSHA256 {
+		return nil, fmt.Errorf("unknown checksum algo %q", algo)
+	}
+
+	var entries []ManifestEntry
+	var infos []os.FileInfo
+	err := walkDir(ctx, s, dir, func(rel string, info os.FileInfo) error {
+		entries = append(entries, ManifestEntry{Path: rel, Size: info.Size()})
+		infos = append(infos, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var once sync.Once
+	sem := make(chan struct{}, manifestConcurrency)
+	var wg sync.WaitGroup
+	for i := range entries {
+		select {
+		case <-ctx.Done():
+		case sem <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			sum, err := manifestChecksum(ctx, s, joinKey(dir, entries[i].Path), infos[i], algo)
+			if err != nil {
+				// остальные файлы уже не нужны
+				once.Do(func() {
+					firstErr = fmt.Errorf("%s: %w", entries[i].Path, err)
+					cancel()
+				})
+				return
+			}
+			entries[i].Checksum = sum
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// manifestChecksum - контрольная сумма одного файла
+func manifestChecksum(ctx context.Context, s StoreIFace, path string, info os.FileInfo, algo ChecksumAlgo) (string, error) {
+	if algo == ChecksumMD5 {
+		// ETag WebDav сервера строится из времени изменения и размера, а не из содержимого
+		if f, ok := info.(*File); ok {
+			if etag := f.ETag(); etag != "" && !strings.Contains(etag, "-") {
+				return strings.ToLower(etag), nil
+			}
+		}
+	}
+
+	var h hash.Hash
+	if algo == ChecksumMD5 {
+		h = md5.New()
+	} else {
+		h = sha256.New()
+	}
+	if _, err := s.WriteToWithContext(ctx, path, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
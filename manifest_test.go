@@ -0,0 +1,164 @@
+package store
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manifestTree - дерево с заранее посчитанными контрольными суммами
+var manifestTree = []struct {
+	path, body, md5, sha256 string
+}{
+	{"a.txt", "hello", "5d41402abc4b2a76b9719d911017c592", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	{"empty.txt", "", "d41d8cd98f00b204e9800998ecf8427e", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{"sub/b.txt", "abc", "900150983cd24fb0d6963f7d28e17f72", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	{"sub/dir/c.txt", "hello", "5d41402abc4b2a76b9719d911017c592", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+}
+
+func TestManifest(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, root := b.store(t)
+			if b.name == "local" {
+				s = newTestLocal(t, LocalConfig{CreateParents: true})
+			}
+			if b.name == "webdav" {
+				if err := s.MkdirAll("sub/dir"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// файлы пишутся в обратном порядке, мета-файлы в манифест не попадают
+			for i := len(manifestTree) - 1; i >= 0; i-- {
+				f := manifestTree[i]
+				if err := s.CreateFile(joinKey(root, f.path), []byte(f.body), nil, map[string]string{"Owner": "bob"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, algo := range []ChecksumAlgo{ChecksumMD5, ChecksumSHA256} {
+				var want []ManifestEntry
+				for _, f := range manifestTree {
+					sum := f.md5
+					if algo == ChecksumSHA256 {
+						sum = f.sha256
+					}
+					want = append(want, ManifestEntry{Path: f.path, Size: int64(len(f.body)), Checksum: sum})
+				}
+				got, err := s.Manifest(root, algo)
+				if err != nil {
+					t.Fatalf("Manifest(%s): %v", algo, err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Manifest(%s) = %v, want %v", algo, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestS3ETag(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	f.put("a.txt", []byte("hello"), nil)
+	f.put("big.bin", []byte("abc"), nil)
+	// ETag multipart загрузки не является md5 содержимого
+	f.object("big.bin").etag = "0123456789abcdef0123456789abcdef-2"
+
+	got, err := s.Manifest("", ChecksumMD5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ManifestEntry{
+		{Path: "a.txt", Size: 5, Checksum: "5d41402abc4b2a76b9719d911017c592"},
+		{Path: "big.bin", Size: 3, Checksum: "900150983cd24fb0d6963f7d28e17f72"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Manifest = %v, want %v", got, want)
+	}
+	// читается только объект с multipart ETag
+	if n, m := getsOf(f, "a.txt"), getsOf(f, "big.bin"); n != 0 || m != 1 {
+		t.Errorf("GET a.txt = %d, big.bin = %d; want 0 and 1", n, m)
+	}
+
+	// для sha256 ETag не подходит
+	if _, err := s.Manifest("", ChecksumSHA256); err != nil {
+		t.Fatal(err)
+	}
+	if n := getsOf(f, "a.txt"); n != 1 {
+		t.Errorf("GET a.txt = %d for sha256, want 1", n)
+	}
+}
+
+func TestManifestErrors(t *testing.T) {
+	t.Run("unknown algo", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		if _, err := s.Manifest("", ChecksumAlgo("crc32")); err == nil {
+			t.Error("Manifest with an unknown algo succeeded")
+		}
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		for _, key := range []string{"a.txt", "b.txt", "c.txt"} {
+			f.put(key, []byte(key), nil)
+		}
+		f.intercept = func(r *http.Request) *http.Response {
+			if _, key := bucketKey(r); r.Method == http.MethodGet && key == "b.txt" {
+				return fakeError(r, http.StatusForbidden, "AccessDenied")
+			}
+			return nil
+		}
+		entries, err := s.Manifest("", ChecksumSHA256)
+		if err == nil || entries != nil {
+			t.Fatalf("Manifest = %v, %v; want an error for b.txt", entries, err)
+		}
+		if ErrorCode(err) != CodePermission || !strings.HasPrefix(err.Error(), "b.txt: ") {
+			t.Errorf("error = %v, want AccessDenied naming b.txt", err)
+		}
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		s := newTestLocal(t, LocalConfig{})
+		if _, err := s.Manifest(joinKey(t.TempDir(), "missing"), ChecksumMD5); err == nil {
+			t.Error("Manifest of a missing dir succeeded")
+		}
+	})
+}
+
+func TestManifestConcurrency(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	for i := 0; i < 3*manifestConcurrency; i++ {
+		f.put(string(rune('a'+i))+".txt", []byte{byte(i)}, nil)
+	}
+
+	var mu sync.Mutex
+	var inFlight, peak int
+	f.intercept = func(r *http.Request) *http.Response {
+		if r.Method != http.MethodGet || r.URL.Query().Has("list-type") {
+			return nil
+		}
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+
+	entries, err := s.Manifest("", ChecksumSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3*manifestConcurrency {
+		t.Errorf("%d entries, want %d", len(entries), 3*manifestConcurrency)
+	}
+	if peak > manifestConcurrency || peak < 2 {
+		t.Errorf("%d reads at once, want between 2 and %d", peak, manifestConcurrency)
+	}
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithMaxGetSize - оборачивает хранилище ограничением размера файлов, читаемых в память
+// s - хранилище
+// max - наибольший размер в байтах, 0 - без ограничения
+// GetFile, GetFileVerified, GetFileIfModifiedSince, GetJsonFile, GetJsonMap,
+// GetFilePartially и Peek сначала запрашивают размер (StatLite) и для файла или его
+// части больше max возвращают ErrFileTooLarge, не читая содержимое. Большие файлы читаются
+// потоком через FileReader или WriteTo, на них ограничение не действует.
+// Размер проверяется до чтения, поэтому файл, выросший между проверкой и чтением,
+// будет прочитан целиком.
+func WithMaxGetSize(s StoreIFace, max int64) StoreIFace {
+	if max <= 0 {
+		return s
+	}
+	return &maxGetSize{StoreIFace: s, max: max}
+}
+
+type maxGetSize struct {
+	StoreIFace
+	max int64
+}
+
+// check - проверяет, что часть файла, начиная с offset длиной length, не больше max;
+// отсутствующий файл и недопустимый диапазон обрабатывает само хранилище
+func (m *maxGetSize) check(ctx context.Context, path string, offset, length int64) error {
+	if length > 0 && length <= m.max {
+		return nil
+	}
+
+	info, err := m.StoreIFace.StatLiteWithContext(ctx, path)
+	if errors.Is(err, ErrFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	length, err = partialLength(info.Size(), offset, length)
+	if err != nil {
+		return nil
+	}
+	if length > m.max {
+		return fmt.Errorf("%w: %s is %d bytes, limit %d", ErrFileTooLarge, path, length, m.max)
+	}
+	return nil
+}
+
+func (m *maxGetSize) GetFile(path string) ([]byte, error) {
+	return m.GetFileWithContext(context.Background(), path)
+}
+
+func (m *maxGetSize) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	if err := m.check(ctx, path, 0, 0); err != nil {
+		return nil, err
+	}
+	return m.StoreIFace.GetFileWithContext(ctx, path)
+}
+
+func (m *maxGetSize) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return m.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+func (m *maxGetSize) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	if err := m.check(ctx, path, offset, length); err != nil {
+		return nil, err
+	}
+	return m.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+}
+
+func (m *maxGetSize) Peek(path string, n int) ([]byte, error) {
+	return m.PeekWithContext(context.Background(), path, n)
+}
+
+func (m *maxGetSize) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	if n > 0 {
+		if err := m.check(ctx, path, 0, int64(n)); err != nil {
+			return nil, err
+		}
+	}
+	return m.StoreIFace.PeekWithContext(ctx, path, n)
+}
+
+func (m *maxGetSize) GetFileIfModifiedSince(path string, since time.Time) ([]byte, bool, error) {
+	return m.GetFileIfModifiedSinceWithContext(context.Background(), path, since)
+}
+
+func (m *maxGetSize) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]byte, bool, error) {
+	if err := m.check(ctx, path, 0, 0); err != nil {
+		return nil, false, err
+	}
+	return m.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, since)
+}
+
+func (m *maxGetSize) GetFileVerified(path string) ([]byte, error) {
+	return m.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+func (m *maxGetSize) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	if err := m.check(ctx, path, 0, 0); err != nil {
+		return nil, err
+	}
+	return m.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+}
+
+func (m *maxGetSize) GetJsonFile(path string, v interface{}) error {
+	return m.GetJsonFileWithContext(context.Background(), path, v)
+}
+
+func (m *maxGetSize) GetJsonFileWithContext(ctx context.Context, path string, v interface{}) error {
+	if err := m.check(ctx, path, 0, 0); err != nil {
+		return err
+	}
+	return m.StoreIFace.GetJsonFileWithContext(ctx, path, v)
+}
+
+func (m *maxGetSize) GetJsonMap(path string) (map[string]interface{}, error) {
+	return m.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (m *maxGetSize) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	if err := m.check(ctx, path, 0, 0); err != nil {
+		return nil, err
+	}
+	return m.StoreIFace.GetJsonMapWithContext(ctx, path)
+}
+
+// appendTarget - дописывание ничего не читает, поэтому ограничение ему не мешает
+func (m *maxGetSize) appendTarget(path string) (*Local, string, bool) {
+	return appendTarget(m.StoreIFace, path)
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxGetSize(t *testing.T) {
+	const limit = 16
+	small := `{"a":"0123456"}` // 15 байт
+	big := `{"a":"012345678"}` // 17 байт
+
+	tests := []struct {
+		name string
+		// read - чтение path; tooLarge - ожидается ErrFileTooLarge для big
+		read     func(s StoreIFace, path string) error
+		tooLarge bool
+	}{
+		{"GetFile", func(s StoreIFace, path string) error {
+			_, err := s.GetFile(path)
+			return err
+		}, true},
+		{"GetFileVerified", func(s StoreIFace, path string) error {
+			_, err := s.GetFileVerified(path)
+			return err
+		}, true},
+		{"GetFileIfModifiedSince", func(s StoreIFace, path string) error {
+			_, _, err := s.GetFileIfModifiedSince(path, time.Time{})
+			return err
+		}, true},
+		{"GetJsonFile", func(s StoreIFace, path string) error {
+			var v map[string]string
+			return s.GetJsonFile(path, &v)
+		}, true},
+		{"GetJsonMap", func(s StoreIFace, path string) error {
+			_, err := s.GetJsonMap(path)
+			return err
+		}, true},
+		{"GetFilePartially to the end", func(s StoreIFace, path string) error {
+			_, err := s.GetFilePartially(path, 0, 0)
+			return err
+		}, true},
+		{"GetFilePartially within the limit", func(s StoreIFace, path string) error {
+			_, err := s.GetFilePartially(path, 0, limit)
+			return err
+		}, false},
+		{"GetFilePartially short tail", func(s StoreIFace, path string) error {
+			_, err := s.GetFilePartially(path, 5, 0)
+			return err
+		}, false},
+		{"Peek past the limit", func(s StoreIFace, path string) error {
+			_, err := s.Peek(path, limit+1)
+			return err
+		}, true},
+		{"Peek within the limit", func(s StoreIFace, path string) error {
+			_, err := s.Peek(path, 4)
+			return err
+		}, false},
+		// потоковое чтение не ограничивается
+		{"FileReader", func(s StoreIFace, path string) error {
+			stream, err := s.FileReader(path, 0, 0)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+			_, err = io.ReadAll(stream)
+			return err
+		}, false},
+		{"WriteTo", func(s StoreIFace, path string) error {
+			_, err := s.WriteTo(path, io.Discard)
+			return err
+		}, false},
+	}
+
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			raw, dir := b.store(t)
+			s := WithMaxGetSize(raw, limit)
+			smallPath, bigPath := joinKey(dir, "small.json"), joinKey(dir, "big.json")
+			for path, body := range map[string]string{smallPath: small, bigPath: big} {
+				if err := s.CreateFile(path, []byte(body), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					if err := tt.read(s, smallPath); err != nil {
+						t.Errorf("small file: %v", err)
+					}
+					err := tt.read(s, bigPath)
+					if tt.tooLarge != errors.Is(err, ErrFileTooLarge) {
+						t.Errorf("big file = %v, want ErrFileTooLarge %v", err, tt.tooLarge)
+					}
+					if tt.tooLarge && !strings.Contains(err.Error(), bigPath) {
+						t.Errorf("error %q does not name %s", err, bigPath)
+					}
+					if !tt.tooLarge && err != nil {
+						t.Errorf("big file: %v", err)
+					}
+				})
+			}
+
+			// отсутствующий файл обрабатывает само хранилище
+			if _, err := s.GetFile(joinKey(dir, "missing.json")); errors.Is(err, ErrFileTooLarge) {
+				t.Errorf("GetFile of a missing file = %v", err)
+			}
+		})
+	}
+
+	t.Run("no bytes read", func(t *testing.T) {
+		raw, f := newFakeS3(t, S3Config{})
+		f.put("big.json", []byte(big), nil)
+		s := WithMaxGetSize(raw, limit)
+		if _, err := s.GetFile("big.json"); !errors.Is(err, ErrFileTooLarge) {
+			t.Fatalf("GetFile = %v, want ErrFileTooLarge", err)
+		}
+		if n := getsOf(f, "big.json"); n != 0 {
+			t.Errorf("%d GET requests, want the size checked by HEAD only", n)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		raw := newTestLocal(t, LocalConfig{})
+		if s := WithMaxGetSize(raw, 0); s != StoreIFace(raw) {
+			t.Error("WithMaxGetSize(0) wrapped the store")
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// relFileInfo - информация о файле с путем относительно директории вместо имени
+type relFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (f relFileInfo) Name() string {
+	return f.name
+}
+
+// listModifiedSince - обходит директорию и отбирает файлы, измененные не раньше since;
+// мета-файлы не обходятся. Name() результата - путь относительно директории.
+func listModifiedSince(ctx context.Context, s StoreIFace, dir string, since time.Time) ([]os.FileInfo, error) {
+	var result []os.FileInfo
+	err := walkDir(ctx, s, dir, func(rel string, info os.FileInfo) error {
+		if !info.ModTime().Before(since) {
+			result = append(result, relFileInfo{FileInfo: info, name: rel})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
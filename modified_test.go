@@ -0,0 +1,109 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestListModifiedSince(t *testing.T) {
+	since := time.Now().Add(-time.Hour).Truncate(time.Second)
+	// files - путь -> время изменения; пустое время - только что записанный файл
+	files := map[string]time.Time{
+		"old.txt":          since.Add(-time.Hour),
+		"sub/old.txt":      since.Add(-time.Minute),
+		"edge.txt":         since,
+		"new.txt":          {},
+		"sub/new.txt":      {},
+		"sub/deep/new.txt": {},
+	}
+	want := []string{"edge.txt", "new.txt", "sub/deep/new.txt", "sub/new.txt"}
+
+	backends := []struct {
+		name string
+		// store - хранилище и корень; touch - выставляет время изменения ключа в обход хранилища
+		store func(t *testing.T) (StoreIFace, string, func(key string, mod time.Time))
+	}{
+		{"local", func(t *testing.T) (StoreIFace, string, func(string, time.Time)) {
+			return newTestLocal(t, LocalConfig{CreateParents: true}), t.TempDir(), func(key string, mod time.Time) {
+				if err := os.Chtimes(key, mod, mod); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}},
+		{"webdav", func(t *testing.T) (StoreIFace, string, func(string, time.Time)) {
+			w, root := newTestWebDavDir(t, WebDavConfig{})
+			if err := w.MkdirAll("sub/deep"); err != nil {
+				t.Fatal(err)
+			}
+			return w, "", func(key string, mod time.Time) {
+				if err := os.Chtimes(filepath.Join(root, key), mod, mod); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}},
+		{"s3", func(t *testing.T) (StoreIFace, string, func(string, time.Time)) {
+			s, f := newFakeS3(t, S3Config{})
+			return s, "", func(key string, mod time.Time) {
+				f.mu.Lock()
+				defer f.mu.Unlock()
+				f.objects["bucket/"+key].modified = mod
+			}
+		}},
+	}
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s, root, touch := b.store(t)
+			for name, mod := range files {
+				key := joinKey(root, name)
+				// у нового файла есть мета-файл, он в результат не попадает
+				if err := s.CreateFile(key, []byte(name), nil, map[string]string{"Owner": "bob"}); err != nil {
+					t.Fatal(err)
+				}
+				if !mod.IsZero() {
+					touch(key, mod)
+				}
+			}
+
+			infos, err := s.ListModifiedSince(root, since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, info := range infos {
+				got = append(got, info.Name())
+				if info.IsDir() || info.Size() != int64(len(info.Name())) || info.ModTime().Before(since) {
+					t.Errorf("%s: dir %v, size %d, modified %v; want a file of %d bytes modified since %v",
+						info.Name(), info.IsDir(), info.Size(), info.ModTime(), len(info.Name()), since)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ListModifiedSince = %v, want %v", got, want)
+			}
+
+			// поддиректория: имена относительно нее
+			infos, err = s.ListModifiedSince(joinKey(root, "sub"), since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = got[:0]
+			for _, info := range infos {
+				got = append(got, info.Name())
+			}
+			sort.Strings(got)
+			if want := []string{"deep/new.txt", "new.txt"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("ListModifiedSince(sub) = %v, want %v", got, want)
+			}
+
+			// время в будущем - ничего не изменено
+			if infos, err := s.ListModifiedSince(root, time.Now().Add(time.Hour)); err != nil || len(infos) != 0 {
+				t.Errorf("ListModifiedSince(future) = %d files, %v; want none", len(infos), err)
+			}
+		})
+	}
+}
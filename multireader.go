@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// multiReader - поток, в котором файлы paths идут один за другим
+// Каждый файл открывается FileReader только после того, как предыдущий прочитан
+// до конца, и сразу закрывается, поэтому открыт не больше одного потока.
+// Отсутствующий файл обнаруживается при переходе к нему: Read возвращает ошибку
+// с путем этого файла, уже прочитанные данные остаются у вызывающего
+func multiReader(ctx context.Context, s StoreIFace, paths []string) (io.ReadCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	return &multiReadCloser{ctx: ctx, s: s, paths: append([]string(nil), paths...)}, nil
+}
+
+// multiReadCloser - io.ReadCloser для multiReader
+type multiReadCloser struct {
+	ctx   context.Context
+	s     StoreIFace
+	paths []string
+	cur   io.ReadCloser
+	err   error
+}
+
+func (m *multiReadCloser) Read(b []byte) (int, error) {
+	for m.err == nil {
+		if m.cur == nil {
+			if len(m.paths) == 0 {
+				return 0, io.EOF
+			}
+			if err := m.open(); err != nil {
+				m.err = err
+				break
+			}
+		}
+
+		n, err := m.cur.Read(b)
+		if err == io.EOF {
+			err = m.cur.Close()
+			m.cur = nil
+			if err != nil {
+				m.err = fmt.Errorf("%s: %w", m.paths[0], err)
+			}
+			m.paths = m.paths[1:]
+			if n > 0 || m.err != nil {
+				return n, m.err
+			}
+			continue
+		}
+		if err != nil {
+			m.err = fmt.Errorf("%s: %w", m.paths[0], err)
+		}
+		return n, m.err
+	}
+	return 0, m.err
+}
+
+// open - открывает очередной файл
+func (m *multiReadCloser) open() error {
+	path := m.paths[0]
+	stream, err := m.s.FileReaderWithContext(m.ctx, path, 0, 0)
+	if err == nil && stream == nil {
+		// Local не открывает пустые файлы, а пустая часть - не ошибка
+		if info, _, statErr := m.s.StatWithContext(m.ctx, path); statErr == nil && info != nil && info.Size() == 0 {
+			stream = io.NopCloser(strings.NewReader(""))
+		} else {
+			err = ErrFileNotFound
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	m.cur = stream
+	return nil
+}
+
+func (m *multiReadCloser) Close() error {
+	m.paths = nil
+	if m.err == nil {
+		m.err = os.ErrClosed
+	}
+	if m.cur == nil {
+		return nil
+	}
+	err := m.cur.Close()
+	m.cur = nil
+	return err
+}
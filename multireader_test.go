@@ -0,0 +1,201 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// openTracker - хранилище, запоминающее открытые FileReader потоки
+type openTracker struct {
+	StoreIFace
+
+	mu     sync.Mutex
+	opened []string
+	open   int
+	// maxOpen - наибольшее число одновременно открытых потоков
+	maxOpen int
+}
+
+func (o *openTracker) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	stream, err := o.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	if err != nil || stream == nil {
+		return stream, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.opened = append(o.opened, path)
+	o.open++
+	o.maxOpen = max(o.maxOpen, o.open)
+	return &trackedStream{ReadCloser: stream, o: o}, nil
+}
+
+// state - открытые за все время пути и число открытых сейчас потоков
+func (o *openTracker) state() ([]string, int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.opened...), o.open
+}
+
+type trackedStream struct {
+	io.ReadCloser
+	o      *openTracker
+	closed bool
+}
+
+func (t *trackedStream) Close() error {
+	t.o.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		t.o.open--
+	}
+	t.o.mu.Unlock()
+	return t.ReadCloser.Close()
+}
+
+// newMultiReaderTree - openTracker над Local с файлами parts в root
+func newMultiReaderTree(t *testing.T, parts map[string]string) (*openTracker, string) {
+	t.Helper()
+	root := t.TempDir()
+	s := newTestLocal(t, LocalConfig{})
+	for name, body := range parts {
+		if err := s.CreateFile(joinKey(root, name), []byte(body), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &openTracker{StoreIFace: s}, root
+}
+
+func TestMultiReader(t *testing.T) {
+	parts := map[string]string{"1.part": "alpha-", "2.part": "", "3.part": "beta-", "4.part": "gamma"}
+
+	t.Run("concatenation order", func(t *testing.T) {
+		s, root := newMultiReaderTree(t, parts)
+		paths := []string{joinKey(root, "4.part"), joinKey(root, "1.part"), joinKey(root, "2.part"), joinKey(root, "3.part"), joinKey(root, "1.part")}
+
+		r, err := multiReader(context.Background(), s, paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		if want := "gammaalpha-beta-alpha-"; string(got) != want {
+			t.Errorf("content = %q, want %q", got, want)
+		}
+		if err := r.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+
+		// пустую 2.part Local не открывает, она пропускается
+		opened, open := s.state()
+		if len(opened) != len(paths)-1 || open != 0 || s.maxOpen != 1 {
+			t.Errorf("opened %v, %d left open, at most %d at once; want each non-empty part once, none left, 1 at once", opened, open, s.maxOpen)
+		}
+	})
+
+	t.Run("lazy opening", func(t *testing.T) {
+		s, root := newMultiReaderTree(t, parts)
+		r, err := multiReader(context.Background(), s, []string{joinKey(root, "1.part"), joinKey(root, "3.part")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+
+		if opened, _ := s.state(); len(opened) != 0 {
+			t.Fatalf("opened %v before the first Read, want nothing", opened)
+		}
+		buf := make([]byte, len("alpha-"))
+		if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "alpha-" {
+			t.Fatalf("first part = %q, %v", buf, err)
+		}
+		if opened, _ := s.state(); len(opened) != 1 {
+			t.Errorf("opened %v after reading the first part, want only 1.part", opened)
+		}
+	})
+
+	t.Run("missing middle file", func(t *testing.T) {
+		s, root := newMultiReaderTree(t, parts)
+		missing := joinKey(root, "missing.part")
+		r, err := multiReader(context.Background(), s, []string{joinKey(root, "1.part"), missing, joinKey(root, "3.part")})
+		if err != nil {
+			t.Fatalf("MultiReader = %v, want the error to surface on Read", err)
+		}
+		defer r.Close()
+
+		got, err := io.ReadAll(r)
+		if string(got) != "alpha-" {
+			t.Errorf("read before the error = %q, want the first part", got)
+		}
+		if !errors.Is(err, ErrFileNotFound) || !strings.Contains(err.Error(), missing) {
+			t.Errorf("error = %v, want ErrFileNotFound naming %s", err, missing)
+		}
+		if opened, open := s.state(); len(opened) != 1 || open != 0 {
+			t.Errorf("opened %v with %d open, want only 1.part and nothing left open", opened, open)
+		}
+		if _, err2 := r.Read(make([]byte, 1)); !errors.Is(err2, ErrFileNotFound) {
+			t.Errorf("Read after the error = %v, want the same error", err2)
+		}
+	})
+
+	t.Run("close after a partial read", func(t *testing.T) {
+		s, root := newMultiReaderTree(t, parts)
+		r, err := multiReader(context.Background(), s, []string{joinKey(root, "1.part"), joinKey(root, "3.part")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([]byte, 3)
+		if _, err := io.ReadFull(r, buf); err != nil || !bytes.Equal(buf, []byte("alp")) {
+			t.Fatalf("partial read = %q, %v", buf, err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		if opened, open := s.state(); len(opened) != 1 || open != 0 {
+			t.Errorf("opened %v with %d open after Close, want 1.part closed and 3.part never opened", opened, open)
+		}
+		if _, err := r.Read(buf); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Read after Close = %v, want os.ErrClosed", err)
+		}
+	})
+}
+
+func TestMultiReaderBackends(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			var paths []string
+			var want []byte
+			// пустая часть тоже участвует в склейке
+			for i, part := range []string{"c", "", "a", "b"} {
+				path := joinKey(dir, strconv.Itoa(i)+".part")
+				body := bytes.Repeat([]byte(part), 3)
+				if err := s.CreateFile(path, body, nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+				want = append(want, body...)
+			}
+
+			r, err := s.MultiReader(paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, want) {
+				t.Errorf("MultiReader = %q, %v; want %q", got, err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WritePolicy - условие успешной записи в MultiStore
+type WritePolicy int
+
+const (
+	// WritePrimary - запись успешна, если она успешна в основном хранилище
+	WritePrimary WritePolicy = iota
+	// WriteAll - запись успешна, только если она успешна во всех хранилищах
+	WriteAll
+)
+
+// MultiStoreOptions - параметры MultiStore
+// Policy - условие успешной записи
+// Concurrency - сколько хранилищ пишется одновременно, 0 - все сразу
+// OnReplicaError - вызывается с ошибками реплик, которые не вернулись из метода при WritePrimary
+type MultiStoreOptions struct {
+	Policy         WritePolicy
+	Concurrency    int
+	OnReplicaError func(error)
+}
+
+// MultiStore - хранилище, реплицирующее записи в несколько хранилищ
+// Чтение выполняется из основного хранилища, запись - во все параллельно.
+// Поток в StreamToFile и FileWriter читается один раз, поэтому пишется в основное
+// хранилище, а в реплики копируется из него после завершения записи.
+type MultiStore struct {
+	StoreIFace
+	replicas []StoreIFace
+	opts     MultiStoreOptions
+}
+
+// NewMultiStore - создает MultiStore
+// primary - основное хранилище
+// replicas - реплики
+// opts - параметры
+func NewMultiStore(primary StoreIFace, replicas []StoreIFace, opts MultiStoreOptions) *MultiStore {
+	return &MultiStore{
+		StoreIFace: primary,
+		replicas:   replicas,
+		opts:       opts,
+	}
+}
+
+// fanOut - выполняет fn во всех хранилищах и сводит ошибки согласно политике
+func (m *MultiStore) fanOut(ctx context.Context, fn func(context.Context, StoreIFace) error) error {
+	stores := append([]StoreIFace{m.StoreIFace}, m.replicas...)
+	return m.result(m.run(ctx, stores, fn))
+}
+
+// run - выполняет fn в хранилищах с ограничением параллельности
+// хранилища, до которых не дошла очередь к моменту отмены контекста, не пишутся
+func (m *MultiStore) run(ctx context.Context, stores []StoreIFace, fn func(context.Context, StoreIFace) error) []error {
+	errs := make([]error, len(stores))
+
+	limit := m.opts.Concurrency
+	if limit <= 0 || limit > len(stores) {
+		limit = len(stores)
+	}
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		select {
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, s StoreIFace) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// result - сводит ошибки хранилищ в ошибку метода согласно политике
+// errs[0] - ошибка основного хранилища
+func (m *MultiStore) result(errs []error) error {
+	var replicaErrs []error
+	for i, err := range errs[1:] {
+		if err != nil {
+			replicaErrs = append(replicaErrs, fmt.Errorf("replica %d: %w", i, err))
+		}
+	}
+
+	var primaryErr error
+	if errs[0] != nil {
+		primaryErr = fmt.Errorf("primary: %w", errs[0])
+	}
+
+	if m.opts.Policy == WriteAll {
+		return errors.Join(append([]error{primaryErr}, replicaErrs...)...)
+	}
+
+	if len(replicaErrs) > 0 && m.opts.OnReplicaError != nil {
+		m.opts.OnReplicaError(errors.Join(replicaErrs...))
+	}
+	return primaryErr
+}
+
+// replicate - копирует записанный в основное хранилище файл в реплики
+func (m *MultiStore) replicate(ctx context.Context, path string, ttl *time.Time) error {
+	errs := m.run(ctx, m.replicas, func(ctx context.Context, s StoreIFace) error {
+		return CopyWithContext(ctx, m.StoreIFace, path, s, path, ttl)
+	})
+	// основное хранилище к этому моменту уже записано
+	return m.result(append([]error{nil}, errs...))
+}
+
+func (m *MultiStore) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return m.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (m *MultiStore) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.CreateFileWithContext(ctx, path, file, ttl, meta)
+	})
+}
+
+func (m *MultiStore) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return m.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+func (m *MultiStore) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+	})
+}
+
+func (m *MultiStore) Reserve(path string) error {
+	return m.ReserveWithContext(context.Background(), path)
+}
+
+// ReserveWithContext - путь резервируется в основном хранилище, а в реплики
+// после этого пишется пустой файл без проверки занятости
+func (m *MultiStore) ReserveWithContext(ctx context.Context, path string) error {
+	if err := m.StoreIFace.ReserveWithContext(ctx, path); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	errs := m.run(ctx, m.replicas, func(ctx context.Context, s StoreIFace) error {
+		return s.CreateFileWithContext(ctx, path, nil, nil, nil)
+	})
+	return m.result(append([]error{nil}, errs...))
+}
+
+func (m *MultiStore) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return m.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+func (m *MultiStore) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.CopyFileWithContext(ctx, src, dst, ttl, meta)
+	})
+}
+
+func (m *MultiStore) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return m.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+func (m *MultiStore) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+	})
+}
+
+func (m *MultiStore) ExtractRange(src string, offset, length int64, dst string) error {
+	return m.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+func (m *MultiStore) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.ExtractRangeWithContext(ctx, src, offset, length, dst)
+	})
+}
+
+func (m *MultiStore) Truncate(path string, size int64) error {
+	return m.TruncateWithContext(context.Background(), path, size)
+}
+
+func (m *MultiStore) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.TruncateWithContext(ctx, path, size)
+	})
+}
+
+func (m *MultiStore) CopyFileIfChanged(src, dst string) (bool, error) {
+	return m.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+// CopyFileIfChangedWithContext - каждое хранилище сравнивает свои копии само;
+// true, если копирование выполнено хотя бы в одном
+func (m *MultiStore) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	var copied atomic.Bool
+	err := m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		ok, err := s.CopyFileIfChangedWithContext(ctx, src, dst)
+		if ok {
+			copied.Store(true)
+		}
+		return err
+	})
+	return copied.Load(), err
+}
+
+func (m *MultiStore) MoveFile(src, dst string) error {
+	return m.MoveFileWithContext(context.Background(), src, dst)
+}
+
+func (m *MultiStore) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.MoveFileWithContext(ctx, src, dst)
+	})
+}
+
+func (m *MultiStore) MoveFileNoOverwrite(src, dst string) error {
+	return m.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
+}
+
+func (m *MultiStore) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.MoveFileNoOverwriteWithContext(ctx, src, dst)
+	})
+}
+
+func (m *MultiStore) SwapFiles(a, b string) error {
+	return m.SwapFilesWithContext(context.Background(), a, b)
+}
+
+func (m *MultiStore) SwapFilesWithContext(ctx context.Context, a, b string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.SwapFilesWithContext(ctx, a, b)
+	})
+}
+
+func (m *MultiStore) Rotate(path string) (string, error) {
+	return m.RotateWithContext(context.Background(), path)
+}
+
+// RotateWithContext - имя с меткой времени выбирается одно на все хранилища
+func (m *MultiStore) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated := rotatedPath(path, time.Now())
+	err := m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return rotateTo(ctx, s, path, rotated)
+	})
+	if err != nil {
+		return "", err
+	}
+	return rotated, nil
+}
+
+func (m *MultiStore) CopyMeta(src, dst string) error {
+	return m.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+func (m *MultiStore) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.CopyMetaWithContext(ctx, src, dst)
+	})
+}
+
+func (m *MultiStore) Symlink(oldname, newname string) error {
+	return m.SymlinkWithContext(context.Background(), oldname, newname)
+}
+
+func (m *MultiStore) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.SymlinkWithContext(ctx, oldname, newname)
+	})
+}
+
+func (m *MultiStore) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return m.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (m *MultiStore) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	if err := m.StoreIFace.StreamToFileWithContext(ctx, stream, path, ttl); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	return m.replicate(ctx, path, ttl)
+}
+
+func (m *MultiStore) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return m.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+func (m *MultiStore) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	n, err := m.StoreIFace.StreamToFileNWithContext(ctx, stream, path, ttl)
+	if err != nil {
+		return n, fmt.Errorf("primary: %w", err)
+	}
+	return n, m.replicate(ctx, path, ttl)
+}
+
+func (m *MultiStore) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return m.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+func (m *MultiStore) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	w, err := m.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+	if err != nil {
+		return nil, fmt.Errorf("primary: %w", err)
+	}
+	return &replicatingWriter{WriteCloser: w, close: func() error {
+		return m.replicate(ctx, path, ttl)
+	}}, nil
+}
+
+func (m *MultiStore) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return m.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - архив распаковывается в основное хранилище,
+// затем распакованная директория копируется в реплики через Sync
+func (m *MultiStore) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	if err := m.StoreIFace.ExtractArchiveWithContext(ctx, r, path, format); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	errs := m.run(ctx, m.replicas, func(ctx context.Context, s StoreIFace) error {
+		_, err := SyncWithContext(ctx, m.StoreIFace, path, s, path, SyncOptions{})
+		return err
+	})
+	return m.result(append([]error{nil}, errs...))
+}
+
+func (m *MultiStore) RemoveFile(path string) error {
+	return m.RemoveFileWithContext(context.Background(), path)
+}
+
+func (m *MultiStore) RemoveFileWithContext(ctx context.Context, path string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.RemoveFileWithContext(ctx, path)
+	})
+}
+
+func (m *MultiStore) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	return m.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+// RemoveFileIfMatchWithContext - ETag сравнивается только в основном хранилище
+// (у реплик он свой), и только после удаления из него файл удаляется из реплик
+func (m *MultiStore) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	removed, err := m.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+	if err != nil {
+		return false, fmt.Errorf("primary: %w", err)
+	}
+	if !removed {
+		return false, nil
+	}
+	errs := m.run(ctx, m.replicas, func(ctx context.Context, s StoreIFace) error {
+		err := s.RemoveFileWithContext(ctx, path)
+		if errors.Is(err, ErrFileNotFound) {
+			return nil
+		}
+		return err
+	})
+	return true, m.result(append([]error{nil}, errs...))
+}
+
+func (m *MultiStore) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return m.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+func (m *MultiStore) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.CreateJsonFileWithContext(ctx, path, data, ttl, meta)
+	})
+}
+
+func (m *MultiStore) ClearDir(path string) error {
+	return m.ClearDirWithContext(context.Background(), path)
+}
+
+func (m *MultiStore) ClearDirWithContext(ctx context.Context, path string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.ClearDirWithContext(ctx, path)
+	})
+}
+
+func (m *MultiStore) MkdirAll(path string) error {
+	return m.MkdirAllWithContext(context.Background(), path)
+}
+
+func (m *MultiStore) MkdirAllWithContext(ctx context.Context, path string) error {
+	return m.fanOut(ctx, func(ctx context.Context, s StoreIFace) error {
+		return s.MkdirAllWithContext(ctx, path)
+	})
+}
+
+// replicatingWriter - после закрытия writer основного хранилища копирует файл в реплики
+type replicatingWriter struct {
+	io.WriteCloser
+	close func() error
+}
+
+func (r *replicatingWriter) Close() error {
+	if err := r.WriteCloser.Close(); err != nil {
+		return fmt.Errorf("primary: %w", err)
+	}
+	return r.close()
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeGauge - счетчик одновременных записей в несколько хранилищ
+type writeGauge struct {
+	active atomic.Int32
+	max    atomic.Int32
+}
+
+func (g *writeGauge) enter() {
+	n := g.active.Add(1)
+	for {
+		m := g.max.Load()
+		if n <= m || g.max.CompareAndSwap(m, n) {
+			return
+		}
+	}
+}
+
+// slowStore - хранилище, в котором CreateFile длится delay и возвращает err
+type slowStore struct {
+	Empty
+	gauge *writeGauge
+	delay time.Duration
+	err   error
+	calls atomic.Int32
+}
+
+func (s *slowStore) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	s.calls.Add(1)
+	s.gauge.enter()
+	defer s.gauge.active.Add(-1)
+	time.Sleep(s.delay)
+	return s.err
+}
+
+// newSlowStores - n хранилищ с общим счетчиком gauge
+func newSlowStores(n int, gauge *writeGauge, delay time.Duration) []*slowStore {
+	stores := make([]*slowStore, n)
+	for i := range stores {
+		stores[i] = &slowStore{gauge: gauge, delay: delay}
+	}
+	return stores
+}
+
+// newTestMultiStore - MultiStore с первым хранилищем stores в роли основного
+func newTestMultiStore(stores []*slowStore, opts MultiStoreOptions) *MultiStore {
+	replicas := make([]StoreIFace, 0, len(stores)-1)
+	for _, s := range stores[1:] {
+		replicas = append(replicas, s)
+	}
+	return NewMultiStore(stores[0], replicas, opts)
+}
+
+func TestMultiStoreConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		want        int32
+	}{
+		{"limited", 2, 2},
+		{"single", 1, 1},
+		{"unlimited", 0, 7},
+		{"above the store count", 20, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gauge := &writeGauge{}
+			stores := newSlowStores(7, gauge, 20*time.Millisecond)
+			m := newTestMultiStore(stores, MultiStoreOptions{Concurrency: tt.concurrency})
+
+			if err := m.CreateFile("a.txt", []byte("a"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := gauge.max.Load(); got != tt.want {
+				t.Errorf("max concurrent writes = %d, want %d", got, tt.want)
+			}
+			for i, s := range stores {
+				if s.calls.Load() != 1 {
+					t.Errorf("store %d written %d times, want 1", i, s.calls.Load())
+				}
+			}
+		})
+	}
+}
+
+func TestMultiStoreErrors(t *testing.T) {
+	errSlow := errors.New("replica timed out")
+	errFull := errors.New("replica is full")
+
+	// хранилища: основное, реплика 0, реплика 1 (errSlow), реплика 2, реплика 3 (errFull)
+	newStores := func() []*slowStore {
+		stores := newSlowStores(5, &writeGauge{}, 5*time.Millisecond)
+		stores[2].err = errSlow
+		stores[4].err = errFull
+		return stores
+	}
+
+	t.Run("write all aggregates replica errors", func(t *testing.T) {
+		m := newTestMultiStore(newStores(), MultiStoreOptions{Policy: WriteAll, Concurrency: 2})
+
+		err := m.CreateFile("a.txt", []byte("a"), nil, nil)
+		if !errors.Is(err, errSlow) || !errors.Is(err, errFull) {
+			t.Fatalf("CreateFile error = %v, want both replica errors", err)
+		}
+		for _, want := range []string{"replica 1: replica timed out", "replica 3: replica is full"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error %q does not name %q", err, want)
+			}
+		}
+	})
+
+	t.Run("write primary reports replica errors to the callback", func(t *testing.T) {
+		var reported error
+		m := newTestMultiStore(newStores(), MultiStoreOptions{
+			Policy:         WritePrimary,
+			Concurrency:    2,
+			OnReplicaError: func(err error) { reported = err },
+		})
+
+		if err := m.CreateFile("a.txt", []byte("a"), nil, nil); err != nil {
+			t.Fatalf("CreateFile error = %v, want nil with a healthy primary", err)
+		}
+		if !errors.Is(reported, errSlow) || !errors.Is(reported, errFull) {
+			t.Errorf("OnReplicaError got %v, want both replica errors", reported)
+		}
+	})
+
+	t.Run("write primary fails with the primary", func(t *testing.T) {
+		stores := newStores()
+		stores[0].err = errFull
+		m := newTestMultiStore(stores, MultiStoreOptions{Policy: WritePrimary, Concurrency: 2})
+
+		err := m.CreateFile("a.txt", []byte("a"), nil, nil)
+		if !errors.Is(err, errFull) || !strings.HasPrefix(err.Error(), "primary: ") {
+			t.Errorf("CreateFile error = %v, want the primary error", err)
+		}
+	})
+}
+
+func TestMultiStoreCancelStopsPendingWrites(t *testing.T) {
+	gauge := &writeGauge{}
+	stores := newSlowStores(4, gauge, 50*time.Millisecond)
+	m := newTestMultiStore(stores, MultiStoreOptions{Policy: WriteAll, Concurrency: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// отмена во время записи в основное хранилище
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := m.CreateFileWithContext(ctx, "a.txt", []byte("a"), nil, nil)
+	wg.Wait()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CreateFile error = %v, want context.Canceled", err)
+	}
+
+	var written []string
+	for i, s := range stores {
+		if s.calls.Load() > 0 {
+			written = append(written, fmt.Sprint(i))
+		}
+	}
+	if len(written) != 1 || written[0] != "0" {
+		t.Errorf("written stores = %v, want only the primary (0)", written)
+	}
+}
@@ -0,0 +1,30 @@
+package store
+
+import "io"
+
+// pipeWriteCloser - io.WriteCloser поверх io.Pipe, читающая сторона которого
+// обрабатывается в отдельной горутине; Close дожидается ее завершения
+type pipeWriteCloser struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// newPipeWriter - запускает consume в горутине и возвращает writer в ее поток
+func newPipeWriter(consume func(io.Reader) error) io.WriteCloser {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := consume(pr)
+		// разблокирует Write, если consume завершился раньше времени
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &pipeWriteCloser{PipeWriter: pw, done: done}
+}
+
+func (p *pipeWriteCloser) Close() error {
+	p.PipeWriter.Close()
+	return <-p.done
+}
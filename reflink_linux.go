@@ -0,0 +1,18 @@
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// FICLONE из linux/fs.h
+const ficlone = 0x40049409
+
+// reflink - клонирует содержимое src в dst без копирования данных (btrfs, XFS)
+func reflink(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
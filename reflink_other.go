@@ -0,0 +1,13 @@
+//go:build !linux
+
+package store
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink - клонирование поддерживается только на Linux, иначе копируем байты
+func reflink(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
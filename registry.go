@@ -0,0 +1,43 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func(Config) (StoreIFace, error){}
+)
+
+// Register - регистрирует стороннее хранилище для New
+// storeType - значение Config.StoreType, по которому New вызывает factory
+// factory - создает хранилище по Config; обертки Config (DefaultTTL, MaxGetSize,
+// NormalizeKeys) New применяет сам
+// Как database/sql.Register, паникует при nil factory, повторной регистрации
+// и при попытке заменить встроенный тип (local, webdav, s3, empty).
+// Обычно вызывается из init пакета с реализацией.
+func Register(storeType string, factory func(Config) (StoreIFace, error)) {
+	if factory == nil {
+		panic("store: Register factory is nil")
+	}
+	switch storeType {
+	case LocalStore, WebDavStore, S3Store, EmptyStore:
+		panic(fmt.Sprintf("store: Register of built-in store type %q", storeType))
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[storeType]; ok {
+		panic(fmt.Sprintf("store: Register called twice for store type %q", storeType))
+	}
+	registry[storeType] = factory
+}
+
+// registered - фабрика зарегистрированного типа хранилища
+func registered(storeType string) (func(Config) (StoreIFace, error), bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := registry[storeType]
+	return factory, ok
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ipfsStore - стороннее хранилище для тестов реестра
+type ipfsStore struct {
+	Empty
+	cfg Config
+}
+
+func (s *ipfsStore) Backend() string {
+	return "ipfs"
+}
+
+// registerTest - регистрирует factory под storeType на время теста
+func registerTest(t *testing.T, storeType string, factory func(Config) (StoreIFace, error)) {
+	t.Helper()
+	Register(storeType, factory)
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, storeType)
+		registryMu.Unlock()
+	})
+}
+
+// mustPanic - f паникует с сообщением, содержащим want
+func mustPanic(t *testing.T, want string, f func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if r == nil {
+			t.Fatalf("no panic, want one mentioning %q", want)
+		}
+		if msg := fmt.Sprint(r); !strings.Contains(msg, want) {
+			t.Errorf("panic %q does not mention %q", msg, want)
+		}
+	}()
+	f()
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("New builds a registered backend", func(t *testing.T) {
+		var got *ipfsStore
+		registerTest(t, "test-ipfs", func(cfg Config) (StoreIFace, error) {
+			got = &ipfsStore{cfg: cfg}
+			return got, nil
+		})
+
+		s, err := New(Config{StoreType: "test-ipfs", DefaultTTL: time.Hour})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if s.Backend() != "ipfs" || got == nil || got.cfg.StoreType != "test-ipfs" {
+			t.Fatalf("New = %s backend, factory got %+v; want the registered store", s.Backend(), got)
+		}
+		// обертки из Config New применяет и к стороннему хранилищу
+		if _, ok := s.(*defaultTTL); !ok {
+			t.Errorf("New = %T, want the store wrapped for DefaultTTL", s)
+		}
+	})
+
+	t.Run("NewFromEnv builds a registered backend", func(t *testing.T) {
+		registerTest(t, "test-env", func(cfg Config) (StoreIFace, error) {
+			return &ipfsStore{cfg: cfg}, nil
+		})
+		setEnv(t, map[string]string{"STORE_TYPE": "test-env"})
+
+		s, err := NewFromEnv()
+		if err != nil || s.Backend() != "ipfs" {
+			t.Fatalf("NewFromEnv = %v, %v; want the registered store", s, err)
+		}
+	})
+
+	t.Run("factory error", func(t *testing.T) {
+		errDown := errors.New("ipfs node is down")
+		registerTest(t, "test-down", func(Config) (StoreIFace, error) {
+			return nil, errDown
+		})
+		if _, err := New(Config{StoreType: "test-down"}); !errors.Is(err, errDown) {
+			t.Errorf("New = %v, want the factory error", err)
+		}
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		if _, err := New(Config{StoreType: "test-unregistered"}); err == nil || err.Error() != "unknown store type" {
+			t.Errorf("New = %v, want unknown store type", err)
+		}
+	})
+
+	t.Run("duplicate name", func(t *testing.T) {
+		factory := func(cfg Config) (StoreIFace, error) { return &ipfsStore{cfg: cfg}, nil }
+		registerTest(t, "test-dup", factory)
+		mustPanic(t, `called twice for store type "test-dup"`, func() { Register("test-dup", factory) })
+	})
+
+	t.Run("built-in name", func(t *testing.T) {
+		for _, name := range []string{LocalStore, WebDavStore, S3Store, EmptyStore} {
+			mustPanic(t, "built-in", func() {
+				Register(name, func(Config) (StoreIFace, error) { return nil, nil })
+			})
+		}
+	})
+
+	t.Run("nil factory", func(t *testing.T) {
+		mustPanic(t, "nil", func() { Register("test-nil", nil) })
+	})
+
+	t.Run("concurrent registration", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("test-concurrent-%d", i)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				Register(name, func(cfg Config) (StoreIFace, error) { return &ipfsStore{cfg: cfg}, nil })
+			}()
+			t.Cleanup(func() {
+				registryMu.Lock()
+				delete(registry, name)
+				registryMu.Unlock()
+			})
+		}
+		wg.Wait()
+
+		for i := 0; i < 20; i++ {
+			if _, ok := registered(fmt.Sprintf("test-concurrent-%d", i)); !ok {
+				t.Errorf("test-concurrent-%d is not registered", i)
+			}
+		}
+	})
+}
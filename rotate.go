@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// rotatedPath - имя, под которым Rotate сохраняет текущий файл:
+// метка времени UTC вставляется перед расширением, app.log -> app.20240102T150405.000Z.log
+func rotatedPath(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	if strings.Contains(ext, "/") {
+		ext = ""
+	}
+	return strings.TrimSuffix(path, ext) + "." + t.UTC().Format("20060102T150405.000Z") + ext
+}
+
+// rotateTo - переносит path в rotated и создает на месте path пустой файл
+// перенос не перезаписывает существующий rotated (ErrAlreadyExists);
+// на время переноса берется Lock(path), поэтому писатели, дописывающие path под той же
+// блокировкой, не теряют записи: они попадают либо в rotated, либо в новый файл
+func rotateTo(ctx context.Context, s StoreIFace, path, rotated string) (err error) {
+	unlock, err := Lock(ctx, s, path, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+
+	if err := s.MoveFileNoOverwriteWithContext(ctx, path, rotated); err != nil {
+		return err
+	}
+	return s.CreateFileWithContext(ctx, path, []byte{}, nil, nil)
+}
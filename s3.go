@@ -1,16 +1,26 @@
 package store
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
@@ -21,6 +31,7 @@ type File struct {
 	size     int64
 	modified time.Time
 	isdir    bool
+	etag     string
 }
 
 func (f File) Name() string {
@@ -52,17 +63,104 @@ func (f File) Sys() interface{} {
 	return nil
 }
 
+// ETag - ETag объекта из листинга без кавычек, пустой для директорий
+func (f File) ETag() string {
+	return f.etag
+}
+
+// s3MaxMetadataSize - ограничение S3 на пользовательские метаданные:
+// сумма длин ключей и значений в байтах UTF-8 не больше 2KB
+const s3MaxMetadataSize = 2 * 1024
+
 type S3 struct {
-	client   *s3.S3
-	S3Bucket *string
+	client           *s3.S3
+	S3Bucket         *string
+	autoDecompress   bool
+	partRetries      int
+	partRetryBackoff time.Duration
+	publicBaseURL    string
+	readBufferSize   int
+	contentType      ContentTypeResolver
+	// moveWait - параметры ожидания в MoveFile, nil - не ждать
+	moveWait []request.WaiterOption
+	// bucketRegion - регион бакета, если он отличается от настроенного (см. followBucketRegion)
+	bucketRegion atomic.Value
+	// creds - заменяемые учетные данные (RefreshCredentials), nil при анонимном доступе
+	creds *rotatingProvider
 }
 
+// defaultPartRetryBackoff - пауза перед первым повтором части по умолчанию
+const defaultPartRetryBackoff = 500 * time.Millisecond
+
+// defaultReadBufferSize - размер буфера FileReader по умолчанию
+const defaultReadBufferSize = 64 * 1024
+
 func (s *S3) init(cfg S3Config) error {
-	s.client = s3.New(session.Must(session.NewSession(&cfg.Config)))
+	if cfg.UseAccelerate {
+		cfg.Config.S3UseAccelerate = aws.Bool(true)
+	}
+	if cfg.UseDualStack {
+		cfg.Config.UseDualStackEndpoint = endpoints.DualStackEndpointStateEnabled
+	}
+	if cfg.MaxRetries != nil {
+		cfg.Config.MaxRetries = cfg.MaxRetries
+	}
+	if cfg.Retryer != nil {
+		request.WithRetryer(&cfg.Config, cfg.Retryer)
+	}
+
+	sess, err := session.NewSession(&cfg.Config)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+
+	if creds := sess.Config.Credentials; creds != nil && creds != credentials.AnonymousCredentials {
+		s.creds = &rotatingProvider{}
+		s.creds.cur.Store(creds)
+		sess.Config.Credentials = credentials.NewCredentials(s.creds)
+	}
+
+	s.client = s3.New(sess)
+	s.client.Handlers.Build.PushBack(s.applyBucketRegion)
+	s.client.Handlers.Retry.PushBack(s.followBucketRegion)
 	s.S3Bucket = aws.String(cfg.S3Bucket)
+	s.autoDecompress = cfg.AutoDecompress
+	s.partRetries = cfg.PartRetries
+	s.publicBaseURL = cfg.PublicBaseURL
+	s.contentType = cfg.ContentType
+	s.readBufferSize = cfg.ReadBufferSize
+	if s.readBufferSize == 0 {
+		s.readBufferSize = defaultReadBufferSize
+	}
+	s.partRetryBackoff = cfg.PartRetryBackoff
+	if !cfg.SkipMoveWait {
+		s.moveWait = []request.WaiterOption{}
+		if cfg.MoveWaitAttempts > 0 {
+			s.moveWait = append(s.moveWait, request.WithWaiterMaxAttempts(cfg.MoveWaitAttempts))
+		}
+		if cfg.MoveWaitDelay > 0 {
+			s.moveWait = append(s.moveWait, request.WithWaiterDelay(request.ConstantWaiterDelay(cfg.MoveWaitDelay)))
+		}
+	}
+	if s.partRetryBackoff <= 0 {
+		s.partRetryBackoff = defaultPartRetryBackoff
+	}
+
+	if cfg.SkipValidation {
+		return nil
+	}
+	_, err = s.client.HeadBucket(&s3.HeadBucketInput{Bucket: s.S3Bucket})
+	if err != nil {
+		return fmt.Errorf("s3: bucket %q is not accessible: %w", cfg.S3Bucket, err)
+	}
 	return nil
 }
 
+// Backend - возвращает тип хранилища (S3Store)
+func (s *S3) Backend() string {
+	return S3Store
+}
+
 // IsExist - проверяет существование файла
 // filePath - путь к файлу
 func (s *S3) IsExist(filePath string) bool {
@@ -84,6 +182,35 @@ func (s *S3) IsExist(filePath string) bool {
 	return true
 }
 
+// ExistMany - проверяет существование нескольких файлов параллельными HeadObject
+// paths - пути к файлам
+// пути, которые не удалось проверить, в результат не попадают,
+// а их ошибки объединяются в возвращаемую ошибку
+func (s *S3) ExistMany(paths []string) (map[string]bool, error) {
+	return s.ExistManyWithContext(context.Background(), paths)
+}
+
+// ExistManyWithContext - проверяет существование нескольких файлов параллельными HeadObject
+// paths - пути к файлам
+func (s *S3) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	return existMany(ctx, paths, func(ctx context.Context, path string) (bool, error) {
+		_, err := s.client.HeadObjectWithContext(
+			ctx,
+			&s3.HeadObjectInput{
+				Bucket: s.S3Bucket,
+				Key:    aws.String(path),
+			})
+
+		if err != nil {
+			if isS3NotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+}
+
 // CreateFile - создает файл
 // path - путь к файлу
 // file - содержимое файла
@@ -97,19 +224,82 @@ func (s *S3) CreateFile(path string, file []byte, ttl *time.Time, meta map[strin
 // file - содержимое файла
 // meta - метаданные файла
 func (s *S3) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
-	_, err := s.client.PutObjectWithContext(
-		ctx,
-		&s3.PutObjectInput{
-			Bucket:   s.S3Bucket,
-			Key:      aws.String(path),
-			Body:     bytes.NewReader(file),
-			Metadata: aws.StringMap(meta),
-			Expires:  ttl,
-		})
+	return s.CreateFileWithOptionsWithContext(ctx, path, file, PutOptions{TTL: ttl, Meta: meta})
+}
+
+// Reserve - резервирует путь: создает пустой объект, только если его еще нет
+// path - путь к файлу
+// пустой объект - заглушка, которую позже перезапишут; если путь занят, возвращается ErrAlreadyExists
+func (s *S3) Reserve(path string) error {
+	return s.ReserveWithContext(context.Background(), path)
+}
+
+// ReserveWithContext - резервирует путь: создает пустой объект, только если его еще нет
+// path - путь к файлу
+// занятость пути атомарно проверяет сам S3 (PutObject с If-None-Match: *);
+// PutObjectInput в используемой версии SDK не имеет поля IfNoneMatch, и заголовок ставится напрямую
+func (s *S3) ReserveWithContext(ctx context.Context, path string) error {
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: s.S3Bucket,
+		Key:    aws.String(path),
+		Body:   bytes.NewReader(nil),
+	})
+	req.SetContext(ctx)
+	req.HTTPRequest.Header.Set("If-None-Match", "*")
+
+	return s3ExistsError(req.Send())
+}
 
+// s3ExistsError - приводит отказ условной записи с If-None-Match к ErrAlreadyExists
+// 412 - ключ занят, 409 - параллельная условная запись того же ключа
+func s3ExistsError(err error) error {
+	if reqErr, ok := err.(awserr.RequestFailure); ok &&
+		(reqErr.StatusCode() == http.StatusPreconditionFailed || reqErr.StatusCode() == http.StatusConflict) {
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	}
 	return err
 }
 
+// CreateFileWithOptions - создает файл
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи
+func (s *S3) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return s.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+// CreateFileWithOptionsWithContext - создает файл
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи
+func (s *S3) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	if err := validateS3Meta(opts.Meta); err != nil {
+		return err
+	}
+
+	req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:                    s.S3Bucket,
+		Key:                       aws.String(path),
+		Body:                      bytes.NewReader(file),
+		Metadata:                  aws.StringMap(opts.Meta),
+		Expires:                   opts.TTL,
+		ACL:                       s3ACL(opts.ACL),
+		CacheControl:              s3OptionalString(opts.CacheControl),
+		ContentType:               s3OptionalString(resolveContentType(opts.ContentType, s.contentType, path, file)),
+		ObjectLockMode:            opts.Lock.mode(),
+		ObjectLockRetainUntilDate: opts.Lock.retainUntil(),
+		ObjectLockLegalHoldStatus: opts.Lock.legalHold(),
+	})
+	req.SetContext(ctx)
+	if opts.Overwrite == OverwriteAllow {
+		return req.Send()
+	}
+
+	// PutObjectInput в используемой версии SDK не имеет поля IfNoneMatch
+	req.HTTPRequest.Header.Set("If-None-Match", "*")
+	return opts.Overwrite.result(s3ExistsError(req.Send()))
+}
+
 // CopyFile - копирует файл
 // src - исходный путь к файлу
 // dst - путь куда копировать
@@ -125,6 +315,61 @@ func (s *S3) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) e
 // ttl - время жизни
 // meta - метаданные
 func (s *S3) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	return s.CopyFileWithOptionsWithContext(ctx, src, dst, PutOptions{TTL: ttl, Meta: meta})
+}
+
+// CopyFileWithOptions - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// opts - параметры записи
+func (s *S3) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return s.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+// CopyFileWithOptionsWithContext - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// opts - параметры записи
+func (s *S3) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	return s.copyObject(ctx, src, s.S3Bucket, dst, opts)
+}
+
+// CopyFileIfChanged - копирует файл, только если содержимое src и dst отличается
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// bool - выполнено ли копирование; отсутствующий dst копируется всегда.
+// Сравниваются размер, затем sha256 из метаданных и ETag объектов, иначе sha256 содержимого обоих файлов
+func (s *S3) CopyFileIfChanged(src, dst string) (bool, error) {
+	return s.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+// CopyFileIfChangedWithContext - копирует файл, только если содержимое src и dst отличается
+// src - исходный путь к файлу
+// dst - путь куда копировать
+func (s *S3) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	return copyFileIfChanged(ctx, s, src, dst)
+}
+
+// CopyFileToBucket - копирует файл в другой бакет
+// src - исходный путь к файлу
+// dstBucket - бакет куда копировать
+// dst - путь куда копировать
+// meta - метаданные, дополняют метаданные исходного файла
+func (s *S3) CopyFileToBucket(src, dstBucket, dst string, meta map[string]string) error {
+	return s.CopyFileToBucketWithContext(context.Background(), src, dstBucket, dst, meta)
+}
+
+// CopyFileToBucketWithContext - копирует файл в другой бакет
+// src - исходный путь к файлу
+// dstBucket - бакет куда копировать
+// dst - путь куда копировать
+// meta - метаданные, дополняют метаданные исходного файла
+func (s *S3) CopyFileToBucketWithContext(ctx context.Context, src, dstBucket, dst string, meta map[string]string) error {
+	return s.copyObject(ctx, src, aws.String(dstBucket), dst, PutOptions{Meta: meta})
+}
+
+// copyObject - копирует объект из текущего бакета в dstBucket
+func (s *S3) copyObject(ctx context.Context, src string, dstBucket *string, dst string, opts PutOptions) error {
 	// Тянем метаданные из исходного файла
 	// и обогащаем их новыми данными если таковые есть
 	head, err := s.client.HeadObjectWithContext(
@@ -145,19 +390,45 @@ func (s *S3) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time
 
 	currentMeta := aws.StringValueMap(head.Metadata)
 
-	for k, v := range meta {
+	// при REPLACE заголовки не копируются, поэтому переносим их явно
+	cacheControl := head.CacheControl
+	if opts.CacheControl != "" {
+		cacheControl = aws.String(opts.CacheControl)
+	}
+	// HeadObject отдает Expires строкой, CopyObject ждет время
+	expires := opts.TTL
+	if expires == nil && head.Expires != nil {
+		if t, err := http.ParseTime(*head.Expires); err == nil {
+			expires = &t
+		}
+	}
+
+	for k, v := range opts.Meta {
 		currentMeta[k] = v
 	}
 
+	if err := validateS3Meta(currentMeta); err != nil {
+		return err
+	}
+
 	_, err = s.client.CopyObjectWithContext(
 		ctx,
 		&s3.CopyObjectInput{
-			Bucket:            s.S3Bucket,
-			CopySource:        aws.String(fmt.Sprintf("%s/%s", *s.S3Bucket, src)),
-			Key:               aws.String(dst),
-			Metadata:          aws.StringMap(currentMeta),
-			MetadataDirective: aws.String("REPLACE"),
-			Expires:           ttl,
+			Bucket:                    dstBucket,
+			CopySource:                aws.String(fmt.Sprintf("%s/%s", *s.S3Bucket, src)),
+			Key:                       aws.String(dst),
+			Metadata:                  aws.StringMap(currentMeta),
+			MetadataDirective:         aws.String("REPLACE"),
+			Expires:                   expires,
+			ACL:                       s3ACL(opts.ACL),
+			ContentType:               head.ContentType,
+			ContentEncoding:           head.ContentEncoding,
+			ContentDisposition:        head.ContentDisposition,
+			ContentLanguage:           head.ContentLanguage,
+			CacheControl:              cacheControl,
+			ObjectLockMode:            opts.Lock.mode(),
+			ObjectLockRetainUntilDate: opts.Lock.retainUntil(),
+			ObjectLockLegalHoldStatus: opts.Lock.legalHold(),
 		})
 
 	return err
@@ -173,6 +444,8 @@ func (s *S3) MoveFile(src, dst string) error {
 // MoveFileWithContext - перемещает файл
 // src - исходный путь к файлу
 // dst - путь куда переместить
+// после копирования и удаления MoveFile дожидается их результата (см. S3Config.MoveWaitAttempts,
+// MoveWaitDelay, SkipMoveWait); отмена ctx прерывает ожидание
 func (s *S3) MoveFileWithContext(ctx context.Context, src, dst string) error {
 	_, err := s.client.CopyObjectWithContext(
 		ctx,
@@ -191,15 +464,18 @@ func (s *S3) MoveFileWithContext(ctx context.Context, src, dst string) error {
 		return err
 	}
 
-	err = s.client.WaitUntilObjectExistsWithContext(
-		ctx,
-		&s3.HeadObjectInput{
-			Bucket: s.S3Bucket,
-			Key:    aws.String(dst),
-		})
+	if s.moveWait != nil {
+		err = s.client.WaitUntilObjectExistsWithContext(
+			ctx,
+			&s3.HeadObjectInput{
+				Bucket: s.S3Bucket,
+				Key:    aws.String(dst),
+			},
+			s.moveWait...)
 
-	if err != nil {
-		return err
+		if err != nil {
+			return moveWaitErr(ctx, err)
+		}
 	}
 
 	_, err = s.client.DeleteObjectWithContext(
@@ -209,7 +485,7 @@ func (s *S3) MoveFileWithContext(ctx context.Context, src, dst string) error {
 			Key:    aws.String(src),
 		})
 
-	if err != nil {
+	if err != nil || s.moveWait == nil {
 		return err
 	}
 
@@ -218,224 +494,1470 @@ func (s *S3) MoveFileWithContext(ctx context.Context, src, dst string) error {
 		&s3.HeadObjectInput{
 			Bucket: s.S3Bucket,
 			Key:    aws.String(src),
-		})
+		},
+		s.moveWait...)
+	return moveWaitErr(ctx, err)
+}
 
+// moveWaitErr - ошибка ожидания MoveFile; при отмене ctx SDK отдает awserr RequestCanceled
+// без Unwrap, поэтому возвращается сама ошибка ctx
+func moveWaitErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
 	return err
 }
 
-// StreamToFile - записывает содержимое потока в файл
-// stream - поток
-// path - путь к файлу
-func (s *S3) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
-	return s.StreamToFileWithContext(context.Background(), stream, path, ttl)
+// MoveFileNoOverwrite - перемещает файл, если dst не существует
+// src - исходный путь к файлу
+// dst - путь куда переместить
+// S3 не умеет условное копирование, поэтому dst проверяется через HeadObject заранее:
+// объект, созданный между проверкой и копированием, будет перезаписан
+func (s *S3) MoveFileNoOverwrite(src, dst string) error {
+	return s.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
 }
 
-// StreamToFile - записывает содержимое потока в файл
-// stream - поток
-// path - путь к файлу
-func (s *S3) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
-	buf := make([]byte, 1024*1024*5) // 5MB
-
-	resp, err := s.client.CreateMultipartUploadWithContext(
+// MoveFileNoOverwriteWithContext - перемещает файл, если dst не существует
+// src - исходный путь к файлу
+// dst - путь куда переместить
+func (s *S3) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	_, err := s.client.HeadObjectWithContext(
 		ctx,
-		&s3.CreateMultipartUploadInput{
-			Bucket:  s.S3Bucket,
-			Key:     aws.String(path),
-			Expires: ttl,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(dst),
 		})
-	if err != nil {
+
+	if err == nil {
+		return ErrAlreadyExists
+	}
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != "NotFound" {
 		return err
 	}
 
-	var partNumber int64 = 1
-	var completedParts []*s3.CompletedPart
-
-	for {
-		n, err := stream.Read(buf)
-		if err != nil && err != io.EOF {
-			return err
-		}
-		if n == 0 {
-			break
-		}
-
-		//fmt.Println("Uploading part", partNumber, "of", path, "size:", n)
+	return s.MoveFileWithContext(ctx, src, dst)
+}
 
-		completedPart, err := s.client.UploadPartWithContext(
-			ctx,
-			&s3.UploadPartInput{
-				Bucket:     s.S3Bucket,
-				Key:        aws.String(path),
-				UploadId:   resp.UploadId,
-				PartNumber: aws.Int64(partNumber),
-				Body:       bytes.NewReader(buf[:n]),
-			})
+// SwapFiles - меняет местами содержимое и метаданные двух объектов
+// a - путь к первому объекту
+// b - путь ко второму объекту
+func (s *S3) SwapFiles(a, b string) error {
+	return s.SwapFilesWithContext(context.Background(), a, b)
+}
 
-		if err != nil {
-			if abortErr := s.abortMultipartUpload(ctx, resp); abortErr != nil {
-				return abortErr
-			}
-			return err
+// SwapFilesWithContext - меняет местами содержимое и метаданные двух объектов
+// a - путь к первому объекту
+// b - путь ко второму объекту
+// S3 не умеет переименовывать объекты, поэтому обмен не атомарен: a копируется во временный
+// объект, b копируется в a, временный объект - в b и удаляется. Ни один ключ не пропадает,
+// но между копированиями оба ключа могут содержать одно и то же; на время обмена берется
+// Lock обоих путей, поэтому одновременные SwapFiles с этими путями, в том числе других
+// клиентов, выполняются по очереди
+func (s *S3) SwapFilesWithContext(ctx context.Context, a, b string) (err error) {
+	unlock, err := lockPaths(ctx, s, a, b)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
 		}
+	}()
 
-		completedParts = append(completedParts, &s3.CompletedPart{
-			ETag:       completedPart.ETag,
-			PartNumber: aws.Int64(partNumber),
-		})
-
-		partNumber++
+	tmp := a + ".swap-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := s.copyObject(ctx, a, s.S3Bucket, tmp, PutOptions{}); err != nil {
+		return err
 	}
-
-	_, err = s.completeMultipartUpload(ctx, resp, completedParts)
-
-	return err
+	if err := s.copyObject(ctx, b, s.S3Bucket, a, PutOptions{}); err != nil {
+		s.RemoveFileWithContext(ctx, tmp)
+		return err
+	}
+	// при ошибке временный объект остается: это единственная копия прежнего содержимого a
+	if err := s.copyObject(ctx, tmp, s.S3Bucket, b, PutOptions{}); err != nil {
+		return fmt.Errorf("swap %s: previous content of %s kept in %s: %w", b, a, tmp, err)
+	}
+	return s.RemoveFileWithContext(ctx, tmp)
 }
 
-// GetFile - получает файл
+// Rotate - переносит файл под имя с меткой времени и создает на его месте пустой
 // path - путь к файлу
-func (s *S3) GetFile(path string) ([]byte, error) {
-	return s.GetFileWithContext(context.Background(), path)
+// string - путь, под которым сохранено прежнее содержимое
+func (s *S3) Rotate(path string) (string, error) {
+	return s.RotateWithContext(context.Background(), path)
 }
 
-// GetFileWithContext - получает файл
+// RotateWithContext - переносит файл под имя с меткой времени и создает на его месте пустой
 // path - путь к файлу
-func (s *S3) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
-	stream, err := s.FileReaderWithContext(ctx, path, 0, 0)
-	if err != nil {
-		return nil, err
+// string - путь, под которым сохранено прежнее содержимое
+func (s *S3) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated := rotatedPath(path, time.Now())
+	if err := rotateTo(ctx, s, path, rotated); err != nil {
+		return "", err
 	}
+	return rotated, nil
+}
 
-	defer stream.Close()
+// maxCopyPartSize - максимальный размер части UploadPartCopy
+const maxCopyPartSize = 5 << 30
 
-	return io.ReadAll(stream)
+// ExtractRange - создает объект dst из части содержимого src
+// src - исходный путь к файлу
+// offset - смещение от начала src
+// length - длина части, 0 и меньше - до конца файла; часть, выходящая за конец файла, обрезается по нему
+// dst - путь к новому объекту, метаданные src не копируются
+func (s *S3) ExtractRange(src string, offset, length int64, dst string) error {
+	return s.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
 }
 
-// GetFilePartially - получает часть файла
-// path - путь к файлу
-// offset - смещение от начала
-// length - длина
-// https://www.rfc-editor.org/rfc/rfc9110.html#name-range
-func (s *S3) GetFilePartially(path string, offset, length int64) ([]byte, error) {
-	return s.GetFilePartiallyWithContext(context.Background(), path, offset, length)
-}
+// ExtractRangeWithContext - создает объект dst из части содержимого src
+// src - исходный путь к файлу
+// offset - смещение от начала src
+// length - длина части, 0 и меньше - до конца файла
+// dst - путь к новому объекту
+// Часть копируется на стороне S3 через multipart загрузку из UploadPartCopy с CopySourceRange,
+// данные не проходят через клиента; части больше 5GB делятся на несколько
+func (s *S3) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	head, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(src),
+		})
+	if err != nil {
+		if isS3NotFound(err) {
+			return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return err
+	}
 
-// GetFilePartiallyWithContext - получает часть файла
-// path - путь к файлу
-// offset - смещение от начала
-// length - длина
-// https://www.rfc-editor.org/rfc/rfc9110.html#name-range
-func (s *S3) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
-	stream, err := s.FileReaderWithContext(ctx, path, offset, length)
+	length, err = partialLength(aws.Int64Value(head.ContentLength), offset, length)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	// пустой диапазон в CopySourceRange не выразить
+	if length == 0 {
+		return s.CreateFileWithContext(ctx, dst, []byte{}, nil, nil)
 	}
 
-	defer stream.Close()
+	upload, err := s.client.CreateMultipartUploadWithContext(
+		ctx,
+		&s3.CreateMultipartUploadInput{
+			Bucket:      s.S3Bucket,
+			Key:         aws.String(dst),
+			ContentType: head.ContentType,
+		})
+	if err != nil {
+		return err
+	}
 
-	return io.ReadAll(stream)
+	var parts []*s3.CompletedPart
+	for start, end := offset, offset+length; start < end; start += maxCopyPartSize {
+		last := min(start+maxCopyPartSize, end) - 1
+		out, err := s.client.UploadPartCopyWithContext(
+			ctx,
+			&s3.UploadPartCopyInput{
+				Bucket:          s.S3Bucket,
+				Key:             aws.String(dst),
+				UploadId:        upload.UploadId,
+				PartNumber:      aws.Int64(int64(len(parts) + 1)),
+				CopySource:      aws.String(fmt.Sprintf("%s/%s", *s.S3Bucket, src)),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, last)),
+			})
+		if err != nil {
+			if abortErr := s.abortMultipartUpload(ctx, upload); abortErr != nil {
+				return errors.Join(err, abortErr)
+			}
+			return err
+		}
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       out.CopyPartResult.ETag,
+			PartNumber: aws.Int64(int64(len(parts) + 1)),
+		})
+	}
+
+	_, err = s.completeMultipartUpload(ctx, upload, parts)
+	return err
 }
 
-// FileReader - возвращает io.ReadCloser для чтения файла
+// Truncate - изменяет длину объекта
 // path - путь к файлу
-// offset - смещение от начала
-// length - длина
-func (s *S3) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
-	return s.FileReaderWithContext(context.Background(), path, offset, length)
+// size - новая длина; объект короче size дополняется нулевыми байтами
+// S3 не изменяет объекты на месте, поэтому объект всегда перезаписывается целиком.
+// При укорачивании начало объекта копируется сервером (UploadPartCopy) в тот же ключ,
+// при удлинении - читается и загружается заново вместе с нулями. Заголовки и
+// метаданные сохраняются, кроме контрольных сумм (MetaSHA256, MetaMD5); ETag меняется
+func (s *S3) Truncate(path string, size int64) error {
+	return s.TruncateWithContext(context.Background(), path, size)
 }
 
-// FileReaderWithContext - возвращает io.ReadCloser для чтения файла
+// TruncateWithContext - изменяет длину объекта
 // path - путь к файлу
-// offset - смещение от начала
-// length - длина
-func (s *S3) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
-	_range := ""
-
-	if length > 0 {
-		_range = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
-	} else {
-		_range = fmt.Sprintf("bytes=%d-", offset)
+// size - новая длина; объект короче size дополняется нулевыми байтами
+func (s *S3) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
 	}
 
-	out, err := s.client.GetObjectWithContext(
+	head, err := s.client.HeadObjectWithContext(
 		ctx,
-		&s3.GetObjectInput{
+		&s3.HeadObjectInput{
 			Bucket: s.S3Bucket,
 			Key:    aws.String(path),
-			Range:  aws.String(_range),
 		})
-
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			if awsErr.Code() == "NotFound" {
-				return nil, ErrFileNotFound
-			}
+		if isS3NotFound(err) {
+			return fmt.Errorf("%w: %w", ErrFileNotFound, err)
 		}
-		return nil, err
+		return err
+	}
+
+	current := aws.Int64Value(head.ContentLength)
+	if size == current {
+		return nil
+	}
+
+	meta, _ := withoutChecksums(aws.StringValueMap(head.Metadata))
+	// HeadObject отдает Expires строкой, загрузка ждет время
+	var expires *time.Time
+	if head.Expires != nil {
+		if t, err := http.ParseTime(*head.Expires); err == nil {
+			expires = &t
+		}
+	}
+
+	// пустую multipart загрузку завершить нельзя
+	if size == 0 {
+		_, err := s.client.PutObjectWithContext(
+			ctx,
+			&s3.PutObjectInput{
+				Bucket:             s.S3Bucket,
+				Key:                aws.String(path),
+				Body:               bytes.NewReader(nil),
+				Metadata:           aws.StringMap(meta),
+				ContentType:        head.ContentType,
+				ContentEncoding:    head.ContentEncoding,
+				ContentDisposition: head.ContentDisposition,
+				ContentLanguage:    head.ContentLanguage,
+				CacheControl:       head.CacheControl,
+				Expires:            expires,
+			})
+		return err
+	}
+
+	upload, err := s.client.CreateMultipartUploadWithContext(
+		ctx,
+		&s3.CreateMultipartUploadInput{
+			Bucket:             s.S3Bucket,
+			Key:                aws.String(path),
+			Metadata:           aws.StringMap(meta),
+			ContentType:        head.ContentType,
+			ContentEncoding:    head.ContentEncoding,
+			ContentDisposition: head.ContentDisposition,
+			ContentLanguage:    head.ContentLanguage,
+			CacheControl:       head.CacheControl,
+			Expires:            expires,
+		})
+	if err != nil {
+		return err
+	}
+
+	var parts []*s3.CompletedPart
+	if size < current {
+		parts, err = s.truncateCopyParts(ctx, upload, path, head.ETag, size)
+	} else {
+		parts, err = s.truncateUploadParts(ctx, upload, path, head.ETag, current, size)
+	}
+	if err != nil {
+		if abortErr := s.abortMultipartUpload(ctx, upload); abortErr != nil {
+			return errors.Join(err, abortErr)
+		}
+		return err
+	}
+
+	_, err = s.completeMultipartUpload(ctx, upload, parts)
+	return err
+}
+
+// truncateCopyParts - копирует первые size байт объекта в его же multipart загрузку
+// etag - ETag объекта из HeadObject; если объект изменился, копирование прерывается
+func (s *S3) truncateCopyParts(ctx context.Context, upload *s3.CreateMultipartUploadOutput, path string, etag *string, size int64) ([]*s3.CompletedPart, error) {
+	var parts []*s3.CompletedPart
+	for start := int64(0); start < size; start += maxCopyPartSize {
+		last := min(start+maxCopyPartSize, size) - 1
+		out, err := s.client.UploadPartCopyWithContext(
+			ctx,
+			&s3.UploadPartCopyInput{
+				Bucket:            s.S3Bucket,
+				Key:               aws.String(path),
+				UploadId:          upload.UploadId,
+				PartNumber:        aws.Int64(int64(len(parts) + 1)),
+				CopySource:        aws.String(fmt.Sprintf("%s/%s", *s.S3Bucket, path)),
+				CopySourceIfMatch: etag,
+				CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, last)),
+			})
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       out.CopyPartResult.ETag,
+			PartNumber: aws.Int64(int64(len(parts) + 1)),
+		})
+	}
+	return parts, nil
+}
+
+// truncateUploadParts - загружает в multipart загрузку содержимое объекта и нули до size
+// части, кроме последней, должны быть не меньше 5MB, поэтому начало объекта
+// не копируется сервером, а читается и загружается вместе с нулями
+func (s *S3) truncateUploadParts(ctx context.Context, upload *s3.CreateMultipartUploadOutput, path string, etag *string, current, size int64) ([]*s3.CompletedPart, error) {
+	var prefix io.Reader = bytes.NewReader(nil)
+	if current > 0 {
+		obj, err := s.client.GetObjectWithContext(
+			ctx,
+			&s3.GetObjectInput{
+				Bucket:  s.S3Bucket,
+				Key:     aws.String(path),
+				IfMatch: etag,
+			})
+		if err != nil {
+			return nil, err
+		}
+		defer obj.Body.Close()
+		prefix = &exactReader{r: obj.Body, left: current}
+	}
+	stream := io.MultiReader(prefix, io.LimitReader(zeroReader{}, size-current))
+
+	buf := make([]byte, S3PartSize)
+	var parts []*s3.CompletedPart
+	for {
+		n, err := io.ReadFull(stream, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if n == 0 {
+			return parts, nil
+		}
+
+		partNumber := int64(len(parts) + 1)
+		out, err := s.uploadPart(ctx, path, upload.UploadId, partNumber, buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       out.ETag,
+			PartNumber: aws.Int64(partNumber),
+		})
+
+		if n < len(buf) {
+			return parts, nil
+		}
+	}
+}
+
+// CopyMeta - копирует метаданные src в dst, не изменяя содержимое dst
+// src - путь к файлу, метаданные которого копируются
+// dst - путь к файлу, которому они назначаются
+// S3 не умеет менять метаданные на месте, поэтому dst копируется сам в себя
+// с MetadataDirective REPLACE; заголовки dst (Content-Type и т.п.) сохраняются
+func (s *S3) CopyMeta(src, dst string) error {
+	return s.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+// CopyMetaWithContext - копирует метаданные src в dst, не изменяя содержимое dst
+// src - путь к файлу, метаданные которого копируются
+// dst - путь к файлу, которому они назначаются
+func (s *S3) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	_, meta, err := s.StatWithContext(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	if err := validateS3Meta(meta); err != nil {
+		return err
+	}
+
+	head, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(dst),
+		})
+
+	if err != nil {
+		if isS3NotFound(err) {
+			return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return err
+	}
+
+	// HeadObject отдает Expires строкой, CopyObject ждет время
+	var expires *time.Time
+	if head.Expires != nil {
+		if t, err := http.ParseTime(*head.Expires); err == nil {
+			expires = &t
+		}
+	}
+
+	_, err = s.client.CopyObjectWithContext(
+		ctx,
+		&s3.CopyObjectInput{
+			Bucket:             s.S3Bucket,
+			CopySource:         aws.String(fmt.Sprintf("%s/%s", *s.S3Bucket, dst)),
+			Key:                aws.String(dst),
+			Metadata:           aws.StringMap(meta),
+			MetadataDirective:  aws.String("REPLACE"),
+			ContentType:        head.ContentType,
+			ContentEncoding:    head.ContentEncoding,
+			ContentDisposition: head.ContentDisposition,
+			ContentLanguage:    head.ContentLanguage,
+			CacheControl:       head.CacheControl,
+			Expires:            expires,
+		})
+
+	return err
+}
+
+// StreamToFile - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+func (s *S3) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return s.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFile - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+func (s *S3) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	return s.streamToFile(ctx, stream, path, PutOptions{TTL: ttl})
+}
+
+// StreamToFileN - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (s *S3) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return s.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFileNWithContext - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (s *S3) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	counter := &countingReader{r: stream}
+	err := s.StreamToFileWithContext(ctx, counter, path, ttl)
+	return counter.n, err
+}
+
+// FileWriter - открывает файл на запись
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные файла
+// запись завершается и ошибка загрузки возвращается при Close
+func (s *S3) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return s.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+// FileWriterWithContext - открывает файл на запись
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные файла
+// запись завершается и ошибка загрузки возвращается при Close
+func (s *S3) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return newPipeWriter(func(r io.Reader) error {
+		return s.streamToFile(ctx, r, path, PutOptions{TTL: ttl, Meta: meta})
+	}), nil
+}
+
+// streamToFile - загружает поток multipart загрузкой частями по 5MB
+func (s *S3) streamToFile(ctx context.Context, stream io.Reader, path string, opts PutOptions) error {
+	if err := validateS3Meta(opts.Meta); err != nil {
+		return err
+	}
+
+	buf := make([]byte, S3PartSize)
+
+	// части, кроме последней, должны быть не меньше 5MB,
+	// поэтому дочитываем буфер полностью
+	n, err := io.ReadFull(stream, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	// Content-Type определяется до начала загрузки по пути и первой части
+	resp, err := s.client.CreateMultipartUploadWithContext(
+		ctx,
+		&s3.CreateMultipartUploadInput{
+			Bucket:                    s.S3Bucket,
+			Key:                       aws.String(path),
+			Expires:                   opts.TTL,
+			Metadata:                  aws.StringMap(opts.Meta),
+			ACL:                       s3ACL(opts.ACL),
+			CacheControl:              s3OptionalString(opts.CacheControl),
+			ContentType:               s3OptionalString(resolveContentType(opts.ContentType, s.contentType, path, buf[:n])),
+			ObjectLockMode:            opts.Lock.mode(),
+			ObjectLockRetainUntilDate: opts.Lock.retainUntil(),
+			ObjectLockLegalHoldStatus: opts.Lock.legalHold(),
+		})
+	if err != nil {
+		return err
+	}
+
+	var partNumber int64 = 1
+	var completedParts []*s3.CompletedPart
+
+	// S3 не завершает загрузку без частей, поэтому пустой поток - одна пустая часть
+	for partNumber == 1 || n > 0 {
+		completedPart, err := s.uploadPart(ctx, path, resp.UploadId, partNumber, buf[:n])
+
+		if err != nil {
+			if abortErr := s.abortMultipartUpload(ctx, resp); abortErr != nil {
+				return errors.Join(err, abortErr)
+			}
+			return err
+		}
+
+		completedParts = append(completedParts, &s3.CompletedPart{
+			ETag:       completedPart.ETag,
+			PartNumber: aws.Int64(partNumber),
+		})
+
+		partNumber++
+
+		if n < len(buf) {
+			break
+		}
+
+		n, err = io.ReadFull(stream, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			if abortErr := s.abortMultipartUpload(ctx, resp); abortErr != nil {
+				return errors.Join(err, abortErr)
+			}
+			return err
+		}
+	}
+
+	_, err = s.completeMultipartUpload(ctx, resp, completedParts)
+
+	return err
+}
+
+// uploadPart - загружает часть multipart загрузки, повторяя ее при временных ошибках
+// часть целиком лежит в буфере, поэтому повтор отправляет те же байты заново
+// повторяется не больше PartRetries раз с удваивающейся паузой
+func (s *S3) uploadPart(ctx context.Context, path string, uploadId *string, partNumber int64, data []byte) (*s3.UploadPartOutput, error) {
+	backoff := s.partRetryBackoff
+	for attempt := 0; ; attempt++ {
+		out, err := s.client.UploadPartWithContext(
+			ctx,
+			&s3.UploadPartInput{
+				Bucket:     s.S3Bucket,
+				Key:        aws.String(path),
+				UploadId:   uploadId,
+				PartNumber: aws.Int64(partNumber),
+				Body:       bytes.NewReader(data),
+			})
+
+		if err == nil || attempt >= s.partRetries || ctx.Err() != nil {
+			return out, err
+		}
+		// отказ в доступе, отсутствующая загрузка и т.п. повтором не исправить
+		if code := ErrorCode(err); code != CodeUnavailable && code != CodeInternal {
+			return out, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// GetFile - получает файл
+// path - путь к файлу
+func (s *S3) GetFile(path string) ([]byte, error) {
+	return s.GetFileWithContext(context.Background(), path)
+}
+
+// GetFileWithContext - получает файл
+// path - путь к файлу
+func (s *S3) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	if s.autoDecompress {
+		return s.getFileDecompressed(ctx, path)
+	}
+
+	stream, err := s.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+// getFileDecompressed - получает файл, распаковывая его при Content-Encoding: gzip
+// path - путь к файлу
+func (s *S3) getFileDecompressed(ctx context.Context, path string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey {
+				return nil, ErrFileNotFound
+			}
+			if awsErr.Code() == s3.ErrCodeInvalidObjectState {
+				return nil, fmt.Errorf("%w: %w", ErrObjectArchived, err)
+			}
+		}
+		return nil, err
+	}
+
+	stream := out.Body
+	if strings.EqualFold(aws.StringValue(out.ContentEncoding), "gzip") {
+		if stream, err = newDecoder(out.Body, "gzip"); err != nil {
+			return nil, err
+		}
+	}
+
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+// GzipReader - возвращает io.ReadCloser для чтения gzip файла в распакованном виде
+// path - путь к файлу
+func (s *S3) GzipReader(path string) (io.ReadCloser, error) {
+	return s.GzipReaderWithContext(context.Background(), path)
+}
+
+// GzipReaderWithContext - возвращает io.ReadCloser для чтения gzip файла в распакованном виде
+// path - путь к файлу
+func (s *S3) GzipReaderWithContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	stream, err := s.FileReaderWithContext(ctx, path, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecoder(stream, "gzip")
+}
+
+// GetFilePartially - получает часть файла
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина, 0 и меньше - до конца файла; часть, выходящая за конец файла, обрезается по нему
+// смещение, равное размеру файла, дает пустую часть, а смещение за концом файла -
+// пустую часть и ErrRangeNotSatisfiable
+// https://www.rfc-editor.org/rfc/rfc9110.html#name-range
+func (s *S3) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return s.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+// GetFilePartiallyWithContext - получает часть файла
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина
+// https://www.rfc-editor.org/rfc/rfc9110.html#name-range
+func (s *S3) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	stream, err := s.FileReaderWithContext(ctx, path, offset, length)
+	if err != nil {
+		if !errors.Is(err, ErrRangeNotSatisfiable) {
+			return nil, err
+		}
+		// S3 отвечает 416 и на смещение, равное размеру объекта
+		head, headErr := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+		if headErr == nil && aws.Int64Value(head.ContentLength) == offset {
+			return []byte{}, nil
+		}
+		return []byte{}, err
+	}
+
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+// GetFileIfModifiedSince - возвращает содержимое файла, если он изменен после t
+// path - путь к файлу
+// t - время последней известной версии
+// bool - true, если файл изменен и содержимое прочитано
+func (s *S3) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	return s.GetFileIfModifiedSinceWithContext(context.Background(), path, t)
+}
+
+// GetFileIfModifiedSinceWithContext - возвращает содержимое файла, если он изменен после t
+// path - путь к файлу
+// t - время последней известной версии
+// bool - true, если файл изменен и содержимое прочитано
+func (s *S3) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	out, err := s.client.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket:          s.S3Bucket,
+			Key:             aws.String(path),
+			IfModifiedSince: aws.Time(t),
+		})
+
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotModified {
+			return nil, false, nil
+		}
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey {
+				return nil, false, ErrFileNotFound
+			}
+			if awsErr.Code() == s3.ErrCodeInvalidObjectState {
+				return nil, false, fmt.Errorf("%w: %w", ErrObjectArchived, err)
+			}
+		}
+		return nil, false, err
+	}
+
+	defer out.Body.Close()
+
+	content, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return content, true, nil
+}
+
+// Peek - возвращает не более n первых байт файла
+// path - путь к файлу
+// n - количество байт
+func (s *S3) Peek(path string, n int) ([]byte, error) {
+	return s.PeekWithContext(context.Background(), path, n)
+}
+
+// PeekWithContext - возвращает не более n первых байт файла
+// path - путь к файлу
+// n - количество байт
+func (s *S3) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	if n <= 0 {
+		if _, _, err := s.StatWithContext(ctx, path); err != nil {
+			return nil, err
+		}
+		return []byte{}, nil
+	}
+
+	stream, err := s.FileReaderWithContext(ctx, path, 0, int64(n))
+	if err != nil {
+		// Range за пределами пустого объекта
+		if errors.Is(err, ErrRangeNotSatisfiable) {
+			return []byte{}, nil
+		}
+		return nil, err
+	}
+	defer stream.Close()
+
+	return io.ReadAll(io.LimitReader(stream, int64(n)))
+}
+
+// GetFileVerified - возвращает содержимое файла, проверяя контрольную сумму
+// path - путь к файлу
+// сверяется с sha256/md5 из метаданных, а при их отсутствии - с ETag
+// объекта, загруженного одной частью; при расхождении возвращается ErrChecksumMismatch
+func (s *S3) GetFileVerified(path string) ([]byte, error) {
+	return s.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+// GetFileVerifiedWithContext - возвращает содержимое файла, проверяя контрольную сумму
+// path - путь к файлу
+// сверяется с sha256/md5 из метаданных, а при их отсутствии - с ETag
+// объекта, загруженного одной частью; при расхождении возвращается ErrChecksumMismatch
+func (s *S3) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	out, err := s.client.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey {
+				return nil, ErrFileNotFound
+			}
+			if awsErr.Code() == s3.ErrCodeInvalidObjectState {
+				return nil, fmt.Errorf("%w: %w", ErrObjectArchived, err)
+			}
+		}
+		return nil, err
+	}
+
+	defer out.Body.Close()
+
+	content, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyChecksum(content, aws.StringValueMap(out.Metadata), aws.StringValue(out.ETag)); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// FileReader - возвращает io.ReadCloser для чтения файла
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина
+func (s *S3) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return s.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+// FileReaderWithContext - возвращает io.ReadCloser для чтения файла
+// path - путь к файлу
+// offset - смещение от начала
+// length - длина
+func (s *S3) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	// файл целиком читается без Range: на "bytes=0-" пустого объекта S3 отвечает 416
+	var _range *string
+
+	if length > 0 {
+		_range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		_range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	out, err := s.client.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+			Range:  _range,
+		})
+
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey {
+				return nil, ErrFileNotFound
+			}
+			if awsErr.Code() == s3.ErrCodeInvalidObjectState {
+				return nil, fmt.Errorf("%w: %w", ErrObjectArchived, err)
+			}
+			if awsErr.Code() == "InvalidRange" {
+				return nil, fmt.Errorf("%w: %w", ErrRangeNotSatisfiable, err)
+			}
+		}
+		return nil, err
+	}
+
+	if s.readBufferSize < 0 {
+		return out.Body, nil
+	}
+	return &bufferedReadCloser{
+		Reader: bufio.NewReaderSize(out.Body, s.readBufferSize),
+		Closer: out.Body,
+	}, nil
+}
+
+// MultiReader - поток, в котором файлы идут один за другим в порядке paths
+// paths - пути к файлам
+// Файлы открываются по очереди, по мере чтения; отсутствующий файл приводит
+// к ошибке Read с его путем
+func (s *S3) MultiReader(paths []string) (io.ReadCloser, error) {
+	return s.MultiReaderWithContext(context.Background(), paths)
+}
+
+// MultiReaderWithContext - поток, в котором файлы идут один за другим в порядке paths
+// paths - пути к файлам
+func (s *S3) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, s, paths)
+}
+
+// bufferedReadCloser - буферизованное чтение с закрытием исходного потока
+type bufferedReadCloser struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// WriteTo - записывает содержимое файла в w
+// path - путь к файлу
+// int64 - количество записанных байт
+func (s *S3) WriteTo(path string, w io.Writer) (int64, error) {
+	return s.WriteToWithContext(context.Background(), path, w)
+}
+
+// WriteToWithContext - записывает содержимое файла в w
+// path - путь к файлу
+// int64 - количество записанных байт
+func (s *S3) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	out, err := s.client.GetObjectWithContext(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey {
+				return 0, ErrFileNotFound
+			}
+			if awsErr.Code() == s3.ErrCodeInvalidObjectState {
+				return 0, fmt.Errorf("%w: %w", ErrObjectArchived, err)
+			}
+		}
+		return 0, err
+	}
+
+	defer out.Body.Close()
+
+	return io.Copy(w, out.Body)
+}
+
+// RemoveFile - удаляет файл
+// path - путь к файлу
+func (s *S3) RemoveFile(path string) error {
+	return s.RemoveFileWithContext(context.Background(), path)
+}
+
+// RemoveFileWithContext - удаляет файл
+// path - путь к файлу
+func (s *S3) RemoveFileWithContext(ctx context.Context, path string) error {
+	_, err := s.client.DeleteObjectWithContext(
+		ctx,
+		&s3.DeleteObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "NotFound" {
+				return ErrFileNotFound
+			}
+		}
+	}
+
+	return err
+}
+
+// RemoveFileIfMatch - удаляет объект, только если его ETag не изменился
+// path - путь к файлу
+// etag - ETag из StatObject
+// bool - true, если объект удален; отсутствующий объект не удаляется и не считается ошибкой,
+// измененный не удаляется и возвращает ErrConflict
+func (s *S3) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	return s.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+// RemoveFileIfMatchWithContext - удаляет объект, только если его ETag не изменился
+// path - путь к файлу
+// etag - ETag из StatObject
+// условие проверяет сам S3 (DeleteObject с If-Match), поэтому гонки с другими клиентами нет;
+// DeleteObjectInput в используемой версии SDK не имеет поля IfMatch, и заголовок ставится напрямую
+func (s *S3) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	req, _ := s.client.DeleteObjectRequest(&s3.DeleteObjectInput{
+		Bucket: s.S3Bucket,
+		Key:    aws.String(path),
+	})
+	req.SetContext(ctx)
+	req.HTTPRequest.Header.Set("If-Match", `"`+strings.Trim(etag, `"`)+`"`)
+
+	if err := req.Send(); err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusPreconditionFailed {
+			return false, fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Stat - возвращает информацию о файле
+// path - путь к файлу
+// os.FileInfo - возвращается неполный
+func (s *S3) Stat(path string) (os.FileInfo, map[string]string, error) {
+	return s.StatWithContext(context.Background(), path)
+}
+
+// Stat - возвращает информацию о файле
+// path - путь к файлу
+// os.FileInfo - возвращается неполный
+// для отсутствующего файла возвращается ошибка, оборачивающая ErrFileNotFound
+func (s *S3) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	out, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if isS3NotFound(err) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return nil, nil, err
+	}
+
+	f := new(File)
+	f.name = path
+	f.size = *out.ContentLength
+	f.modified = *out.LastModified
+
+	return f, aws.StringValueMap(out.Metadata), nil
+}
+
+// StatLite - возвращает информацию о файле без метаданных
+// path - путь к файлу
+// в S3 метаданные приходят в том же HeadObject, поэтому запрос тот же, что и в Stat
+func (s *S3) StatLite(path string) (os.FileInfo, error) {
+	return s.StatLiteWithContext(context.Background(), path)
+}
+
+// StatLiteWithContext - возвращает информацию о файле без метаданных
+// path - путь к файлу
+func (s *S3) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	info, _, err := s.StatWithContext(ctx, path)
+	return info, err
+}
+
+// Lstat - возвращает информацию о файле и метаданные
+// path - путь к файлу
+// символических ссылок в S3 нет, поэтому Lstat совпадает со Stat
+func (s *S3) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	return s.Stat(path)
+}
+
+// LstatWithContext - возвращает информацию о файле и метаданные
+// path - путь к файлу
+func (s *S3) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	return s.StatWithContext(ctx, path)
+}
+
+// Symlink - символические ссылки в S3 не поддерживаются
+func (s *S3) Symlink(oldname, newname string) error {
+	return fmt.Errorf("s3: symlink: %w", errors.ErrUnsupported)
+}
+
+// SymlinkWithContext - символические ссылки в S3 не поддерживаются
+func (s *S3) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	return s.Symlink(oldname, newname)
+}
+
+// StatObject - возвращает полную информацию о файле
+// path - путь к файлу
+func (s *S3) StatObject(path string) (ObjectInfo, error) {
+	return s.StatObjectWithContext(context.Background(), path)
+}
+
+// StatObjectWithContext - возвращает полную информацию о файле
+// path - путь к файлу
+func (s *S3) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	out, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if isS3NotFound(err) {
+			return ObjectInfo{}, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return ObjectInfo{}, err
+	}
+
+	// HeadObject отдает Expires строкой
+	var expires time.Time
+	if out.Expires != nil {
+		expires, _ = http.ParseTime(*out.Expires)
+	}
+
+	// S3 не возвращает StorageClass для STANDARD
+	storageClass := aws.StringValue(out.StorageClass)
+	if storageClass == "" {
+		storageClass = s3.StorageClassStandard
+	}
+
+	return ObjectInfo{
+		Name:            path,
+		Size:            aws.Int64Value(out.ContentLength),
+		ModTime:         aws.TimeValue(out.LastModified),
+		Meta:            aws.StringValueMap(out.Metadata),
+		ContentType:     aws.StringValue(out.ContentType),
+		ContentEncoding: aws.StringValue(out.ContentEncoding),
+		ETag:            strings.Trim(aws.StringValue(out.ETag), `"`),
+		StorageClass:    storageClass,
+		VersionId:       aws.StringValue(out.VersionId),
+		CacheControl:    aws.StringValue(out.CacheControl),
+		Expires:         expires,
+	}, nil
+}
+
+// PublicURL - возвращает постоянный (не подписанный) URL объекта
+// path - путь к файлу
+// URL строится от PublicBaseURL, а без него от эндпоинта клиента (в том числе
+// пользовательского для S3-совместимых хранилищ): virtual-hosted, либо path-style
+// при S3ForcePathStyle и для бакетов с точкой в имени, которые не подходят для TLS поддомена
+// доступность объекта по URL определяется политикой бакета, а не этим методом
+func (s *S3) PublicURL(path string) (string, error) {
+	if s.publicBaseURL != "" {
+		return joinURL(s.publicBaseURL, path), nil
+	}
+
+	bucketURL, err := s.bucketURL()
+	if err != nil {
+		return "", err
+	}
+	return joinURL(bucketURL, path), nil
+}
+
+// bucketURL - адрес бакета от эндпоинта клиента: virtual-hosted, либо path-style
+// при S3ForcePathStyle и для бакетов с точкой в имени
+func (s *S3) bucketURL() (string, error) {
+	endpoint, err := url.Parse(s.client.Endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	bucket := aws.StringValue(s.S3Bucket)
+	if aws.BoolValue(s.client.Config.S3ForcePathStyle) || strings.Contains(bucket, ".") {
+		return joinURL(endpoint.String(), bucket), nil
+	}
+
+	endpoint.Host = bucket + "." + endpoint.Host
+	return endpoint.String(), nil
+}
+
+// Latest - возвращает самый новый по времени изменения файл директории
+// path - путь к директории
+// учитываются только непосредственные потомки, мета-файлы пропускаются
+func (s *S3) Latest(path string) (os.FileInfo, error) {
+	return s.LatestWithContext(context.Background(), path)
+}
+
+// LatestWithContext - возвращает самый новый по времени изменения файл директории
+// path - путь к директории
+// учитываются только непосредственные потомки, мета-файлы пропускаются
+func (s *S3) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	prefix := s3DirPrefix(path)
+
+	var latest *s3.Object
+	err := s.client.ListObjectsV2PagesWithContext(
+		ctx,
+		&s3.ListObjectsV2Input{
+			Bucket:    s.S3Bucket,
+			Prefix:    aws.String(prefix),
+			Delimiter: aws.String("/"),
+		},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, obj := range page.Contents {
+				key := aws.StringValue(obj.Key)
+				// маркер "path/" и мета-файлы пропускаем
+				if key == prefix || strings.HasSuffix(key, META_PREFIX) {
+					continue
+				}
+				if latest == nil || aws.TimeValue(obj.LastModified).After(aws.TimeValue(latest.LastModified)) {
+					latest = obj
+				}
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
 	}
+	if latest == nil {
+		return nil, ErrFileNotFound
+	}
+
+	// имя относительно директории, как в ListDirChan
+	f := new(File)
+	f.name = strings.TrimPrefix(aws.StringValue(latest.Key), prefix)
+	f.size = aws.Int64Value(latest.Size)
+	f.modified = aws.TimeValue(latest.LastModified)
+
+	return f, nil
+}
 
-	return out.Body, nil
+// IsEmpty - проверяет, что в директории нет файлов и поддиректорий
+// path - путь к директории
+// листинг запрашивается по одному ключу и прерывается на первом файле;
+// маркер директории от MkdirAll и мета-файлы не учитываются.
+// ErrFileNotFound - под префиксом нет ключей и нет маркера директории
+func (s *S3) IsEmpty(path string) (bool, error) {
+	return s.IsEmptyWithContext(context.Background(), path)
 }
 
-// RemoveFile - удаляет файл
-// path - путь к файлу
-func (s *S3) RemoveFile(path string) error {
-	return s.RemoveFileWithContext(context.Background(), path)
-}
+// IsEmptyWithContext - проверяет, что в директории нет файлов и поддиректорий
+// path - путь к директории
+func (s *S3) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	prefix := s3DirPrefix(path)
+
+	empty, found := true, false
+	err := s.client.ListObjectsV2PagesWithContext(
+		ctx,
+		&s3.ListObjectsV2Input{
+			Bucket:  s.S3Bucket,
+			Prefix:  aws.String(prefix),
+			MaxKeys: aws.Int64(1),
+		},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, obj := range page.Contents {
+				found = true
+				key := aws.StringValue(obj.Key)
+				// маркер "path/" и мета-файлы директорию не наполняют
+				if key == prefix || strings.HasSuffix(key, META_PREFIX) {
+					continue
+				}
+				empty = false
+				return false
+			}
+			return true
+		})
+
+	if err != nil {
+		return false, err
+	}
+	if !empty || found || prefix == "" {
+		return empty, nil
+	}
 
-// RemoveFileWithContext - удаляет файл
-// path - путь к файлу
-func (s *S3) RemoveFileWithContext(ctx context.Context, path string) error {
-	_, err := s.client.DeleteObjectWithContext(
+	// MkdirAll создает маркер без завершающего "/"
+	_, err = s.client.HeadObjectWithContext(
 		ctx,
-		&s3.DeleteObjectInput{
+		&s3.HeadObjectInput{
 			Bucket: s.S3Bucket,
-			Key:    aws.String(path),
+			Key:    aws.String(strings.TrimSuffix(prefix, "/")),
 		})
-
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			if awsErr.Code() == "NotFound" {
-				return ErrFileNotFound
-			}
+		if isS3NotFound(err) {
+			return false, ErrFileNotFound
 		}
+		return false, err
 	}
+	return true, nil
+}
 
-	return err
+// ListDirChan - отдает файлы директории в канал постранично
+// path - путь к директории
+// следующая страница ListObjectsV2 запрашивается, когда предыдущая вычитана;
+// вложенные "директории" отдаются с IsDir; канал закрывается после последнего файла или ошибки
+// канал нужно дочитать до конца, иначе используйте ListDirChanWithContext и отменяйте контекст
+func (s *S3) ListDirChan(path string) <-chan DirEntry {
+	return s.ListDirChanWithContext(context.Background(), path)
 }
 
-// Stat - возвращает информацию о файле
+// ListDirChanWithContext - отдает файлы директории в канал постранично
+// path - путь к директории
+// после отмены контекста листинг прерывается и канал закрывается
+func (s *S3) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		prefix := s3DirPrefix(path)
+		cancelled := false
+		err := s.client.ListObjectsV2PagesWithContext(
+			ctx,
+			&s3.ListObjectsV2Input{
+				Bucket:    s.S3Bucket,
+				Prefix:    aws.String(prefix),
+				Delimiter: aws.String("/"),
+			},
+			func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+				for _, p := range page.CommonPrefixes {
+					f := &File{
+						name:  strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(p.Prefix), prefix), "/"),
+						isdir: true,
+					}
+					if !sendDirEntry(ctx, ch, DirEntry{Info: f}) {
+						cancelled = true
+						return false
+					}
+				}
+
+				for _, obj := range page.Contents {
+					key := aws.StringValue(obj.Key)
+					// маркер "path/" и мета-файлы пропускаем
+					if key == prefix || strings.HasSuffix(key, META_PREFIX) {
+						continue
+					}
+
+					f := &File{
+						name:     strings.TrimPrefix(key, prefix),
+						size:     aws.Int64Value(obj.Size),
+						modified: aws.TimeValue(obj.LastModified),
+						etag:     strings.Trim(aws.StringValue(obj.ETag), `"`),
+					}
+					if !sendDirEntry(ctx, ch, DirEntry{Info: f}) {
+						cancelled = true
+						return false
+					}
+				}
+				return true
+			})
+
+		if err != nil && !cancelled {
+			sendDirEntry(ctx, ch, DirEntry{Err: err})
+		}
+	}()
+
+	return ch
+}
+
+// ArchiveDir - пишет в w архив файлов директории со всеми поддиректориями
+// path - путь к директории
+// w - куда писать архив
+// format - формат архива
+// пути в архиве относительны path, время изменения файлов сохраняется
+func (s *S3) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return s.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+// ArchiveDirWithContext - пишет в w архив файлов директории со всеми поддиректориями
+// path - путь к директории
+// w - куда писать архив
+// format - формат архива
+func (s *S3) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	return archiveDir(ctx, s, path, w, format)
+}
+
+// ExtractArchive - распаковывает архив в директорию
+// r - поток архива
+// path - путь к директории, куда распаковывается архив
+// format - формат архива
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey
+func (s *S3) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return s.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - распаковывает архив в директорию
+// r - поток архива
+// path - путь к директории, куда распаковывается архив
+// format - формат архива
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey
+func (s *S3) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, s, r, path, format, extractTarget{})
+}
+
+// Manifest - манифест директории со всеми поддиректориями: путь, размер и контрольная сумма каждого файла
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+// записи отсортированы по пути, мета-файлы не включаются
+// для md5 используется ETag объекта, кроме загруженных multipart
+func (s *S3) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return s.ManifestWithContext(context.Background(), path, algo)
+}
+
+// ManifestWithContext - манифест директории со всеми поддиректориями: путь, размер и контрольная сумма каждого файла
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+func (s *S3) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return manifest(ctx, s, path, algo)
+}
+
+// BlockChecksums - контрольные суммы блоков файла для rsync-подобной синхронизации
 // path - путь к файлу
-// os.FileInfo - возвращается неполный
-func (s *S3) Stat(path string) (os.FileInfo, map[string]string, error) {
-	return s.StatWithContext(context.Background(), path)
+// blockSize - размер блока в байтах
+// файл читается одним потоком FileReader; разница двух списков - BlockDiff,
+// применение - ApplyBlockPatch
+func (s *S3) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return s.BlockChecksumsWithContext(context.Background(), path, blockSize)
 }
 
-// Stat - возвращает информацию о файле
+// BlockChecksumsWithContext - контрольные суммы блоков файла для rsync-подобной синхронизации
 // path - путь к файлу
-// os.FileInfo - возвращается неполный
-func (s *S3) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
-	out, err := s.client.HeadObjectWithContext(
+// blockSize - размер блока в байтах
+func (s *S3) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, s, path, blockSize)
+}
+
+// ListModifiedSince - файлы директории со всеми поддиректориями, измененные не раньше since
+// path - путь к директории
+// since - момент времени
+// Name() результата - путь файла относительно path, мета-файлы не включаются
+func (s *S3) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return s.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+// ListModifiedSinceWithContext - файлы директории со всеми поддиректориями, измененные не раньше since
+// path - путь к директории
+// since - момент времени
+// Префикс листается целиком без разделителя, а файлы отбираются по LastModified
+func (s *S3) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	prefix := s3DirPrefix(path)
+
+	var result []os.FileInfo
+	err := s.client.ListObjectsV2PagesWithContext(
 		ctx,
-		&s3.HeadObjectInput{
+		&s3.ListObjectsV2Input{
 			Bucket: s.S3Bucket,
-			Key:    aws.String(path),
+			Prefix: aws.String(prefix),
+		},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, obj := range page.Contents {
+				key := aws.StringValue(obj.Key)
+				// "каталоги", созданные MkdirAll, и мета-файлы пропускаем
+				if strings.HasSuffix(key, "/") || key == prefix || strings.HasSuffix(key, META_PREFIX) {
+					continue
+				}
+				if aws.TimeValue(obj.LastModified).Before(since) {
+					continue
+				}
+				result = append(result, &File{
+					name:     strings.TrimPrefix(key, prefix),
+					size:     aws.Int64Value(obj.Size),
+					modified: aws.TimeValue(obj.LastModified),
+					etag:     strings.Trim(aws.StringValue(obj.ETag), `"`),
+				})
+			}
+			return true
 		})
 
 	if err != nil {
-		if awsErr, ok := err.(awserr.Error); ok {
-			if awsErr.Code() == "NotFound" {
-				return nil, nil, ErrFileNotFound
-			}
-		}
-		return nil, nil, err
+		return nil, err
 	}
+	return result, nil
+}
 
-	f := new(File)
-	f.name = path
-	f.size = *out.ContentLength
-	f.modified = *out.LastModified
+// ListDirDepth - файлы и поддиректории директории не глубже maxDepth уровней
+// path - путь к директории
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+// Name() результата - путь относительно path, мета-файлы не включаются
+func (s *S3) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return s.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
 
-	return f, aws.StringValueMap(out.Metadata), nil
+// ListDirDepthWithContext - файлы и поддиректории директории не глубже maxDepth уровней
+// path - путь к директории
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+func (s *S3) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	return listDirDepth(ctx, s, path, maxDepth)
+}
+
+// ListMeta - метаданные всех файлов директории со всеми поддиректориями
+// path - путь к директории
+// ключ результата - путь файла относительно path, мета-файлы не включаются
+// метаданные читаются параллельными HeadObject
+func (s *S3) ListMeta(path string) (map[string]map[string]string, error) {
+	return s.ListMetaWithContext(context.Background(), path)
+}
+
+// ListMetaWithContext - метаданные всех файлов директории со всеми поддиректориями
+// path - путь к директории
+func (s *S3) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	return listMeta(ctx, s, path)
 }
 
 // ClearDir - очищает директорию
@@ -496,6 +2018,7 @@ func (s *S3) MkdirAllWithContext(ctx context.Context, path string) error {
 // CreateJsonFile - создает json файл
 // path - путь к файлу
 // data - данные для записи
+// документ больше 5MB кодируется и записывается потоком, не занимая память целиком
 func (s *S3) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
 	return s.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
 }
@@ -504,11 +2027,12 @@ func (s *S3) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta
 // path - путь к файлу
 // data - данные для записи
 func (s *S3) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
-	content, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return err
-	}
-	return s.CreateFile(path, content, ttl, meta)
+	return createJsonFile(data, func(content []byte) error {
+		return s.CreateFileWithContext(ctx, path, content, ttl, meta)
+	}, func(r io.Reader) error {
+		// ошибка кодирования прерывает multipart загрузку, объект не создается
+		return s.streamToFile(ctx, r, path, PutOptions{TTL: ttl, Meta: meta})
+	})
 }
 
 // GetJsonFile - получает файл и десериализует его в переменную
@@ -532,7 +2056,352 @@ func (s *S3) GetJsonFileWithContext(ctx context.Context, path string, file inter
 	return json.Unmarshal(content, file)
 }
 
+// IncompleteUpload - незавершенная multipart загрузка
+type IncompleteUpload struct {
+	Key       string
+	UploadId  string
+	Initiated time.Time
+}
+
+// ListIncompleteUploads - возвращает список незавершенных multipart загрузок
+// prefix - префикс ключей
+func (s *S3) ListIncompleteUploads(prefix string) ([]IncompleteUpload, error) {
+	return s.ListIncompleteUploadsWithContext(context.Background(), prefix)
+}
+
+// ListIncompleteUploadsWithContext - возвращает список незавершенных multipart загрузок
+// prefix - префикс ключей
+func (s *S3) ListIncompleteUploadsWithContext(ctx context.Context, prefix string) ([]IncompleteUpload, error) {
+	var uploads []IncompleteUpload
+
+	err := s.client.ListMultipartUploadsPagesWithContext(
+		ctx,
+		&s3.ListMultipartUploadsInput{
+			Bucket: s.S3Bucket,
+			Prefix: aws.String(prefix),
+		},
+		func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+			for _, u := range page.Uploads {
+				uploads = append(uploads, IncompleteUpload{
+					Key:       aws.StringValue(u.Key),
+					UploadId:  aws.StringValue(u.UploadId),
+					Initiated: aws.TimeValue(u.Initiated),
+				})
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return uploads, nil
+}
+
+// AbortIncompleteUploads - прерывает незавершенные multipart загрузки, начатые раньше olderThan
+// olderThan - граница времени начала загрузки
+// int - количество прерванных загрузок
+func (s *S3) AbortIncompleteUploads(olderThan time.Time) (int, error) {
+	return s.AbortIncompleteUploadsWithContext(context.Background(), olderThan)
+}
+
+// AbortIncompleteUploadsWithContext - прерывает незавершенные multipart загрузки, начатые раньше olderThan
+// olderThan - граница времени начала загрузки
+// int - количество прерванных загрузок
+func (s *S3) AbortIncompleteUploadsWithContext(ctx context.Context, olderThan time.Time) (int, error) {
+	uploads, err := s.ListIncompleteUploadsWithContext(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, u := range uploads {
+		if !u.Initiated.Before(olderThan) {
+			continue
+		}
+
+		_, err := s.client.AbortMultipartUploadWithContext(
+			ctx,
+			&s3.AbortMultipartUploadInput{
+				Bucket:   s.S3Bucket,
+				Key:      aws.String(u.Key),
+				UploadId: aws.String(u.UploadId),
+			})
+		if err != nil {
+			return aborted, err
+		}
+		aborted++
+	}
+
+	return aborted, nil
+}
+
+// StorageClass - возвращает класс хранения объекта
+// path - путь к файлу
+// для объектов в STANDARD S3 не отдает класс, поэтому он подставляется явно
+func (s *S3) StorageClass(path string) (string, error) {
+	return s.StorageClassWithContext(context.Background(), path)
+}
+
+// StorageClassWithContext - возвращает класс хранения объекта
+// path - путь к файлу
+func (s *S3) StorageClassWithContext(ctx context.Context, path string) (string, error) {
+	head, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if isS3NotFound(err) {
+			return "", fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return "", err
+	}
+
+	if head.StorageClass == nil {
+		return s3.StorageClassStandard, nil
+	}
+	return *head.StorageClass, nil
+}
+
+// RestoreObject - запускает восстановление архивного объекта (Glacier, Deep Archive)
+// path - путь к файлу
+// days - сколько дней восстановленная копия будет доступна
+// tier - скорость восстановления: Expedited, Standard, Bulk; пустая строка - по умолчанию S3
+// восстановление асинхронно: пока оно не завершится, GetFile возвращает ErrObjectArchived;
+// повторный вызов во время восстановления не считается ошибкой
+func (s *S3) RestoreObject(path string, days int, tier string) error {
+	return s.RestoreObjectWithContext(context.Background(), path, days, tier)
+}
+
+// RestoreObjectWithContext - запускает восстановление архивного объекта (Glacier, Deep Archive)
+// path - путь к файлу
+// days - сколько дней восстановленная копия будет доступна
+// tier - скорость восстановления: Expedited, Standard, Bulk; пустая строка - по умолчанию S3
+func (s *S3) RestoreObjectWithContext(ctx context.Context, path string, days int, tier string) error {
+	req := &s3.RestoreRequest{
+		Days: aws.Int64(int64(days)),
+	}
+	if tier != "" {
+		req.GlacierJobParameters = &s3.GlacierJobParameters{
+			Tier: aws.String(tier),
+		}
+	}
+
+	_, err := s.client.RestoreObjectWithContext(
+		ctx,
+		&s3.RestoreObjectInput{
+			Bucket:         s.S3Bucket,
+			Key:            aws.String(path),
+			RestoreRequest: req,
+		})
+
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok {
+			if awsErr.Code() == "RestoreAlreadyInProgress" {
+				return nil
+			}
+		}
+		if isS3NotFound(err) {
+			return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SetObjectLock - задает срок хранения объекта (Object Lock retention)
+// path - путь к файлу
+// mode - s3.ObjectLockModeGovernance или s3.ObjectLockModeCompliance
+// retainUntil - до какого момента объект нельзя изменить или удалить
+// в режиме COMPLIANCE срок можно только продлить
+func (s *S3) SetObjectLock(path string, mode string, retainUntil time.Time) error {
+	return s.SetObjectLockWithContext(context.Background(), path, mode, retainUntil)
+}
+
+// SetObjectLockWithContext - задает срок хранения объекта (Object Lock retention)
+// path - путь к файлу
+// mode - s3.ObjectLockModeGovernance или s3.ObjectLockModeCompliance
+// retainUntil - до какого момента объект нельзя изменить или удалить
+func (s *S3) SetObjectLockWithContext(ctx context.Context, path string, mode string, retainUntil time.Time) error {
+	_, err := s.client.PutObjectRetentionWithContext(
+		ctx,
+		&s3.PutObjectRetentionInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+			Retention: &s3.ObjectLockRetention{
+				Mode:            aws.String(mode),
+				RetainUntilDate: aws.Time(retainUntil),
+			},
+		})
+
+	if err != nil && isS3NotFound(err) {
+		return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+	}
+	return err
+}
+
+// SetLegalHold - включает или снимает legal hold объекта
+// path - путь к файлу
+// on - true - включить, false - снять
+func (s *S3) SetLegalHold(path string, on bool) error {
+	return s.SetLegalHoldWithContext(context.Background(), path, on)
+}
+
+// SetLegalHoldWithContext - включает или снимает legal hold объекта
+// path - путь к файлу
+// on - true - включить, false - снять
+func (s *S3) SetLegalHoldWithContext(ctx context.Context, path string, on bool) error {
+	status := s3.ObjectLockLegalHoldStatusOff
+	if on {
+		status = s3.ObjectLockLegalHoldStatusOn
+	}
+
+	_, err := s.client.PutObjectLegalHoldWithContext(
+		ctx,
+		&s3.PutObjectLegalHoldInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+			LegalHold: &s3.ObjectLockLegalHold{
+				Status: aws.String(status),
+			},
+		})
+
+	if err != nil && isS3NotFound(err) {
+		return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+	}
+	return err
+}
+
+// GetObjectLock - возвращает текущую блокировку объекта
+// path - путь к файлу
+// для объекта без блокировки возвращается пустой ObjectLock
+func (s *S3) GetObjectLock(path string) (ObjectLock, error) {
+	return s.GetObjectLockWithContext(context.Background(), path)
+}
+
+// GetObjectLockWithContext - возвращает текущую блокировку объекта
+// path - путь к файлу
+func (s *S3) GetObjectLockWithContext(ctx context.Context, path string) (ObjectLock, error) {
+	head, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+
+	if err != nil {
+		if isS3NotFound(err) {
+			return ObjectLock{}, fmt.Errorf("%w: %w", ErrFileNotFound, err)
+		}
+		return ObjectLock{}, err
+	}
+
+	return ObjectLock{
+		Mode:        aws.StringValue(head.ObjectLockMode),
+		RetainUntil: aws.TimeValue(head.ObjectLockRetainUntilDate),
+		LegalHold:   aws.StringValue(head.ObjectLockLegalHoldStatus) == s3.ObjectLockLegalHoldStatusOn,
+	}, nil
+}
+
+// isS3NotFound - объект не существует
+// HeadObject возвращает код NotFound, GetObject - NoSuchKey
+func isS3NotFound(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() == "NotFound" || awsErr.Code() == s3.ErrCodeNoSuchKey
+	}
+	return false
+}
+
+// mode - режим хранения для запроса, nil - без срока хранения
+func (l *ObjectLock) mode() *string {
+	if l == nil || l.Mode == "" {
+		return nil
+	}
+	return aws.String(l.Mode)
+}
+
+// retainUntil - срок хранения для запроса
+func (l *ObjectLock) retainUntil() *time.Time {
+	if l == nil || l.Mode == "" {
+		return nil
+	}
+	return aws.Time(l.RetainUntil)
+}
+
+// legalHold - статус legal hold для запроса
+func (l *ObjectLock) legalHold() *string {
+	if l == nil || !l.LegalHold {
+		return nil
+	}
+	return aws.String(s3.ObjectLockLegalHoldStatusOn)
+}
+
+// s3OptionalString - строковый параметр запроса, пустая строка - параметр не передается
+func s3OptionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return aws.String(v)
+}
+
+// s3DirPrefix - префикс ключей для содержимого "директории" path
+func s3DirPrefix(path string) string {
+	if path == "" || strings.HasSuffix(path, "/") {
+		return path
+	}
+	return path + "/"
+}
+
+// validateS3Meta - проверяет, что метаданные укладываются в ограничение S3 в 2KB
+func validateS3Meta(meta map[string]string) error {
+	size := 0
+	for k, v := range meta {
+		size += len(k) + len(v)
+	}
+	if size > s3MaxMetadataSize {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrMetadataTooLarge, size, s3MaxMetadataSize)
+	}
+	return nil
+}
+
+// s3ACL - canned ACL объекта для ACL, nil - ACL бакета по умолчанию
+func s3ACL(acl ACL) *string {
+	switch acl {
+	case ACLPublicRead:
+		return aws.String(s3.ObjectCannedACLPublicRead)
+	case ACLPrivate:
+		return aws.String(s3.ObjectCannedACLPrivate)
+	default:
+		return nil
+	}
+}
+
+// GetJsonMap - получает JSON файл в виде map
+// path - путь к файлу
+// числа возвращаются как json.Number
+func (s *S3) GetJsonMap(path string) (map[string]interface{}, error) {
+	return s.GetJsonMapWithContext(context.Background(), path)
+}
+
+// GetJsonMapWithContext - получает JSON файл в виде map
+// path - путь к файлу
+// числа возвращаются как json.Number
+func (s *S3) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	content, err := s.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJsonMap(content)
+}
+
+// abortMultipartUpload - прерывает загрузку; загрузка прерывается и после отмены ctx,
+// иначе ее части остались бы в бакете
 func (s *S3) abortMultipartUpload(ctx context.Context, resp *s3.CreateMultipartUploadOutput) error {
+	ctx = context.WithoutCancel(ctx)
 	abortInput := &s3.AbortMultipartUploadInput{
 		Bucket:   resp.Bucket,
 		Key:      resp.Key,
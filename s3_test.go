@@ -0,0 +1,1468 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// roundTripFunc - http.RoundTripper из функции, подменяет S3 в тестах
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// s3Recorder - запоминает запросы к S3 и отвечает на них пустым 200
+type s3Recorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (rec *s3Recorder) roundTrip(r *http.Request) (*http.Response, error) {
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, r)
+	rec.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Length": []string{"0"}},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    r,
+	}, nil
+}
+
+func (rec *s3Recorder) last(t *testing.T) *http.Request {
+	t.Helper()
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.requests) == 0 {
+		t.Fatal("no requests recorded")
+	}
+	return rec.requests[len(rec.requests)-1]
+}
+
+// newTestS3 - S3 без сети: запросы уходят в rt
+func newTestS3(t testing.TB, cfg S3Config, rt http.RoundTripper) *S3 {
+	t.Helper()
+	// бандл сертификатов из окружения не нужен и может отсутствовать
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	if cfg.S3Bucket == "" {
+		cfg.S3Bucket = "bucket"
+	}
+	cfg.SkipValidation = true
+	// ожидание MoveFile по умолчанию SDK - до 100s, оно включается только явными настройками
+	if cfg.MoveWaitAttempts == 0 && cfg.MoveWaitDelay == 0 {
+		cfg.SkipMoveWait = true
+	}
+	if cfg.MaxRetries == nil {
+		cfg.MaxRetries = aws.Int(0)
+	}
+	if cfg.Region == nil {
+		cfg.Region = aws.String("us-east-1")
+	}
+	cfg.Credentials = credentials.NewStaticCredentials("id", "secret", "")
+	cfg.HTTPClient = &http.Client{Transport: rt}
+
+	s, err := NewS3(cfg)
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s.(*S3)
+}
+
+// fakeS3 - S3 в памяти: понимает ту часть REST API, которой пользуется пакет
+// (объекты, копирование, multipart, листинги), и запоминает все запросы
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]*fakeObject
+	uploads  map[string]*fakeUpload
+	requests []*http.Request
+	nextID   int
+	// intercept - если вернул ответ, запрос до хранилища не доходит
+	intercept func(r *http.Request) *http.Response
+	// maxParts - размер страницы ListParts без max-parts в запросе, 0 - 1000 как у S3
+	maxParts int
+}
+
+// fakeObject - объект fakeS3
+type fakeObject struct {
+	data     []byte
+	header   http.Header
+	modified time.Time
+	etag     string
+}
+
+// fakeUpload - незавершенная multipart загрузка fakeS3
+type fakeUpload struct {
+	bucket, key string
+	header      http.Header
+	parts       map[int][]byte
+	initiated   time.Time
+}
+
+// newFakeS3 - S3 поверх нового fakeS3
+func newFakeS3(t testing.TB, cfg S3Config) (*S3, *fakeS3) {
+	t.Helper()
+	f := &fakeS3{objects: map[string]*fakeObject{}, uploads: map[string]*fakeUpload{}}
+	return newTestS3(t, cfg, f), f
+}
+
+// put - кладет объект в бакет "bucket" в обход клиента
+func (f *fakeS3) put(key string, data []byte, header http.Header) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if header == nil {
+		header = http.Header{}
+	}
+	f.objects["bucket/"+key] = &fakeObject{data: data, header: header, modified: time.Now().UTC(), etag: md5Hex(data)}
+}
+
+// object - объект бакета "bucket", nil если его нет
+func (f *fakeS3) object(key string) *fakeObject {
+	return f.objectIn("bucket", key)
+}
+
+// objectIn - объект бакета bucket, nil если его нет
+func (f *fakeS3) objectIn(bucket, key string) *fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[bucket+"/"+key]
+}
+
+// requestsTo - запросы с методом method (пустой - любым) и параметром запроса query (пустой - любым)
+func (f *fakeS3) requestsTo(method, query string) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*http.Request
+	for _, r := range f.requests {
+		if (method == "" || r.Method == method) && (query == "" || r.URL.Query().Has(query)) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// persistedHeaders - заголовки запроса, которые S3 хранит вместе с объектом
+func persistedHeaders(h http.Header) http.Header {
+	out := http.Header{}
+	for k, v := range h {
+		lk := strings.ToLower(k)
+		switch {
+		case lk == "content-type", lk == "content-encoding", lk == "content-disposition",
+			lk == "content-language", lk == "cache-control", lk == "expires",
+			lk == "x-amz-storage-class", lk == "x-amz-acl", lk == "x-amz-tagging",
+			strings.HasPrefix(lk, "x-amz-meta-"), strings.HasPrefix(lk, "x-amz-object-lock-"):
+			out[k] = append([]string(nil), v...)
+		}
+	}
+	return out
+}
+
+func fakeResponse(r *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		StatusCode:    status,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+func fakeError(r *http.Request, status int, code string) *http.Response {
+	if r.Method == http.MethodHead {
+		return fakeResponse(r, status, nil, nil)
+	}
+	return fakeResponse(r, status, http.Header{"Content-Type": {"application/xml"}},
+		[]byte(fmt.Sprintf("<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)))
+}
+
+func fakeXML(r *http.Request, v interface{}) *http.Response {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return fakeResponse(r, http.StatusOK, http.Header{"Content-Type": {"application/xml"}}, body)
+}
+
+// bucketKey - бакет и ключ запроса в virtual-hosted или path-style адресации
+func bucketKey(r *http.Request) (string, string) {
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	if i := strings.Index(r.URL.Host, ".s3"); i > 0 {
+		return r.URL.Host[:i], p
+	}
+	bucket, key, _ := strings.Cut(p, "/")
+	return bucket, key
+}
+
+func (f *fakeS3) RoundTrip(r *http.Request) (*http.Response, error) {
+	// как и http.Transport, запрос с отмененным контекстом не отправляется
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+	if r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	intercept := f.intercept
+	f.mu.Unlock()
+	if intercept != nil {
+		if resp := intercept(r); resp != nil {
+			return resp, nil
+		}
+	}
+
+	var body []byte
+	if r.GetBody != nil {
+		rc, _ := r.GetBody()
+		body, _ = io.ReadAll(rc)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key := bucketKey(r)
+	q := r.URL.Query()
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			return fakeResponse(r, http.StatusOK, nil, nil), nil
+		case q.Get("list-type") == "2":
+			return f.listObjects(r, bucket), nil
+		case q.Has("uploads"):
+			return f.listUploads(r, bucket), nil
+		}
+		return fakeError(r, http.StatusNotImplemented, "NotImplemented"), nil
+	}
+
+	id := bucket + "/" + key
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		if q.Has("uploadId") {
+			return f.listParts(r, q.Get("uploadId")), nil
+		}
+		// HEAD архивного объекта отвечает, GET - нет, пока объект не восстановлен
+		if obj := f.objects[id]; r.Method == http.MethodGet && obj != nil && archived(obj) {
+			return fakeError(r, http.StatusForbidden, "InvalidObjectState"), nil
+		}
+		return f.getObject(r, f.objects[id]), nil
+
+	case http.MethodPut:
+		switch {
+		case q.Has("retention"), q.Has("legal-hold"):
+			obj := f.objects[id]
+			if obj == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchKey"), nil
+			}
+			if q.Has("retention") {
+				var ret struct{ Mode, RetainUntilDate string }
+				xml.Unmarshal(body, &ret)
+				obj.header.Set("X-Amz-Object-Lock-Mode", ret.Mode)
+				obj.header.Set("X-Amz-Object-Lock-Retain-Until-Date", ret.RetainUntilDate)
+			} else {
+				var hold struct{ Status string }
+				xml.Unmarshal(body, &hold)
+				obj.header.Set("X-Amz-Object-Lock-Legal-Hold", hold.Status)
+			}
+			return fakeResponse(r, http.StatusOK, nil, nil), nil
+
+		case r.Header.Get("X-Amz-Copy-Source") != "":
+			src, err := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+			if err != nil {
+				return fakeError(r, http.StatusBadRequest, "InvalidArgument"), nil
+			}
+			obj := f.objects[src]
+			if obj == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchKey"), nil
+			}
+			if match := r.Header.Get("X-Amz-Copy-Source-If-Match"); match != "" && strings.Trim(match, `"`) != obj.etag {
+				return fakeError(r, http.StatusPreconditionFailed, "PreconditionFailed"), nil
+			}
+			if q.Has("uploadId") {
+				return f.uploadPartCopy(r, obj), nil
+			}
+			header := obj.header
+			if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+				header = persistedHeaders(r.Header)
+			}
+			data := append([]byte(nil), obj.data...)
+			copied := &fakeObject{data: data, header: header.Clone(), modified: time.Now().UTC(), etag: obj.etag}
+			f.objects[id] = copied
+			return fakeXML(r, struct {
+				XMLName      xml.Name `xml:"CopyObjectResult"`
+				ETag         string
+				LastModified string
+			}{ETag: `"` + copied.etag + `"`, LastModified: copied.modified.Format(time.RFC3339)}), nil
+
+		case q.Has("uploadId"):
+			up := f.uploads[q.Get("uploadId")]
+			if up == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchUpload"), nil
+			}
+			n, _ := strconv.Atoi(q.Get("partNumber"))
+			up.parts[n] = body
+			return fakeResponse(r, http.StatusOK, http.Header{"Etag": {`"` + md5Hex(body) + `"`}}, nil), nil
+		}
+
+		if obj := f.objects[id]; obj != nil && r.Header.Get("If-None-Match") == "*" {
+			return fakeError(r, http.StatusPreconditionFailed, "PreconditionFailed"), nil
+		}
+		obj := &fakeObject{data: body, header: persistedHeaders(r.Header), modified: time.Now().UTC(), etag: md5Hex(body)}
+		f.objects[id] = obj
+		return fakeResponse(r, http.StatusOK, http.Header{"Etag": {`"` + obj.etag + `"`}}, nil), nil
+
+	case http.MethodDelete:
+		if q.Has("uploadId") {
+			if f.uploads[q.Get("uploadId")] == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchUpload"), nil
+			}
+			delete(f.uploads, q.Get("uploadId"))
+			return fakeResponse(r, http.StatusNoContent, nil, nil), nil
+		}
+		if match := r.Header.Get("If-Match"); match != "" {
+			obj := f.objects[id]
+			if obj == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchKey"), nil
+			}
+			if strings.Trim(match, `"`) != obj.etag {
+				return fakeError(r, http.StatusPreconditionFailed, "PreconditionFailed"), nil
+			}
+		}
+		delete(f.objects, id)
+		return fakeResponse(r, http.StatusNoContent, nil, nil), nil
+
+	case http.MethodPost:
+		switch {
+		case q.Has("uploads"):
+			f.nextID++
+			uploadID := "upload-" + strconv.Itoa(f.nextID)
+			f.uploads[uploadID] = &fakeUpload{bucket: bucket, key: key, header: persistedHeaders(r.Header),
+				parts: map[int][]byte{}, initiated: time.Now().UTC()}
+			return fakeXML(r, struct {
+				XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+				Bucket   string
+				Key      string
+				UploadId string
+			}{Bucket: bucket, Key: key, UploadId: uploadID}), nil
+
+		case q.Has("uploadId"):
+			up := f.uploads[q.Get("uploadId")]
+			if up == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchUpload"), nil
+			}
+			var complete struct {
+				Parts []struct {
+					PartNumber int
+					ETag       string
+				} `xml:"Part"`
+			}
+			// как и S3, завершение без частей отклоняется
+			if err := xml.Unmarshal(body, &complete); err != nil || len(complete.Parts) == 0 {
+				return fakeError(r, http.StatusBadRequest, "MalformedXML"), nil
+			}
+			var data, sums []byte
+			for i, p := range complete.Parts {
+				// как и S3, части сверяются по ETag, полученному при их загрузке
+				part, ok := up.parts[p.PartNumber]
+				if !ok || strings.Trim(p.ETag, `"`) != md5Hex(part) ||
+					(i > 0 && complete.Parts[i-1].PartNumber >= p.PartNumber) {
+					return fakeError(r, http.StatusBadRequest, "InvalidPart"), nil
+				}
+				data = append(data, part...)
+				sum := md5.Sum(part)
+				sums = append(sums, sum[:]...)
+			}
+			etag := fmt.Sprintf("%s-%d", md5Hex(sums), len(complete.Parts))
+			f.objects[id] = &fakeObject{data: data, header: up.header, modified: time.Now().UTC(), etag: etag}
+			delete(f.uploads, q.Get("uploadId"))
+			return fakeXML(r, struct {
+				XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+				Bucket  string
+				Key     string
+				ETag    string
+			}{Bucket: bucket, Key: key, ETag: `"` + etag + `"`}), nil
+
+		case q.Has("restore"):
+			obj := f.objects[id]
+			if obj == nil {
+				return fakeError(r, http.StatusNotFound, "NoSuchKey"), nil
+			}
+			if obj.header.Get("X-Amz-Restore") == `ongoing-request="true"` {
+				return fakeError(r, http.StatusConflict, "RestoreAlreadyInProgress"), nil
+			}
+			obj.header.Set("X-Amz-Restore", `ongoing-request="true"`)
+			return fakeResponse(r, http.StatusAccepted, nil, nil), nil
+		}
+	}
+	return fakeError(r, http.StatusNotImplemented, "NotImplemented"), nil
+}
+
+// archived - объект в Glacier или Deep Archive без завершенного восстановления
+func archived(obj *fakeObject) bool {
+	switch obj.header.Get("X-Amz-Storage-Class") {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return !strings.Contains(obj.header.Get("X-Amz-Restore"), `ongoing-request="false"`)
+	}
+	return false
+}
+
+// getObject - GetObject/HeadObject с условиями и диапазоном
+func (f *fakeS3) getObject(r *http.Request, obj *fakeObject) *http.Response {
+	if obj == nil {
+		return fakeError(r, http.StatusNotFound, "NoSuchKey")
+	}
+	if match := r.Header.Get("If-Match"); match != "" && strings.Trim(match, `"`) != obj.etag {
+		return fakeError(r, http.StatusPreconditionFailed, "PreconditionFailed")
+	}
+	if match := r.Header.Get("If-None-Match"); match != "" && strings.Trim(match, `"`) == obj.etag {
+		return fakeResponse(r, http.StatusNotModified, nil, nil)
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !obj.modified.Truncate(time.Second).After(since) {
+		return fakeResponse(r, http.StatusNotModified, nil, nil)
+	}
+
+	header := obj.header.Clone()
+	header.Set("Etag", `"`+obj.etag+`"`)
+	header.Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+	q := r.URL.Query()
+	for param, name := range map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-disposition": "Content-Disposition",
+		"response-cache-control":       "Cache-Control",
+	} {
+		if v := q.Get(param); v != "" {
+			header.Set(name, v)
+		}
+	}
+
+	data, status := obj.data, http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		size := int64(len(obj.data))
+		var start, end int64
+		spec := strings.TrimPrefix(rng, "bytes=")
+		from, to, _ := strings.Cut(spec, "-")
+		switch {
+		case from == "":
+			n, _ := strconv.ParseInt(to, 10, 64)
+			start, end = max(size-n, 0), size-1
+		default:
+			start, _ = strconv.ParseInt(from, 10, 64)
+			end = size - 1
+			if to != "" {
+				end, _ = strconv.ParseInt(to, 10, 64)
+				end = min(end, size-1)
+			}
+		}
+		if start >= size {
+			resp := fakeError(r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			resp.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			return resp
+		}
+		data, status = obj.data[start:end+1], http.StatusPartialContent
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+
+	if r.Method == http.MethodHead {
+		resp := fakeResponse(r, status, header, nil)
+		resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+		resp.ContentLength = int64(len(data))
+		return resp
+	}
+	return fakeResponse(r, status, header, data)
+}
+
+// uploadPartCopy - UploadPartCopy с x-amz-copy-source-range
+func (f *fakeS3) uploadPartCopy(r *http.Request, src *fakeObject) *http.Response {
+	q := r.URL.Query()
+	up := f.uploads[q.Get("uploadId")]
+	if up == nil {
+		return fakeError(r, http.StatusNotFound, "NoSuchUpload")
+	}
+	data := src.data
+	if rng := r.Header.Get("X-Amz-Copy-Source-Range"); rng != "" {
+		from, to, _ := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+		start, _ := strconv.ParseInt(from, 10, 64)
+		end, _ := strconv.ParseInt(to, 10, 64)
+		if start > end || end >= int64(len(data)) {
+			return fakeError(r, http.StatusBadRequest, "InvalidArgument")
+		}
+		data = data[start : end+1]
+	}
+	n, _ := strconv.Atoi(q.Get("partNumber"))
+	up.parts[n] = append([]byte(nil), data...)
+	return fakeXML(r, struct {
+		XMLName xml.Name `xml:"CopyPartResult"`
+		ETag    string
+	}{ETag: `"` + md5Hex(data) + `"`})
+}
+
+type fakeListObject struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+	StorageClass string
+}
+
+type fakeListPrefix struct {
+	Prefix string
+}
+
+// listObjects - ListObjectsV2 с prefix, delimiter, max-keys и постраничной выдачей
+func (f *fakeS3) listObjects(r *http.Request, bucket string) *http.Response {
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	after := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		after = token
+	}
+	maxKeys := 1000
+	if v := q.Get("max-keys"); v != "" {
+		maxKeys, _ = strconv.Atoi(v)
+	}
+
+	var keys []string
+	for id := range f.objects {
+		if key, ok := strings.CutPrefix(id, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Prefix                string
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string           `xml:",omitempty"`
+		Contents              []fakeListObject `xml:"Contents"`
+		CommonPrefixes        []fakeListPrefix `xml:"CommonPrefixes"`
+	}
+	result.Prefix = prefix
+	seen := map[string]bool{}
+	last := ""
+	for _, key := range keys {
+		// токен продолжения - последний выданный ключ или общий префикс
+		if key <= after || (delimiter != "" && strings.HasSuffix(after, delimiter) && strings.HasPrefix(key, after)) {
+			continue
+		}
+		entry := key
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				entry = key[:len(prefix)+i+len(delimiter)]
+				if entry <= after || seen[entry] {
+					continue
+				}
+			}
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+		result.KeyCount++
+		if entry != key {
+			seen[entry] = true
+			result.CommonPrefixes = append(result.CommonPrefixes, fakeListPrefix{Prefix: entry})
+			last = entry
+			continue
+		}
+		obj := f.objects[bucket+"/"+key]
+		result.Contents = append(result.Contents, fakeListObject{
+			Key:          key,
+			LastModified: obj.modified.Format(time.RFC3339Nano),
+			ETag:         `"` + obj.etag + `"`,
+			Size:         len(obj.data),
+			StorageClass: "STANDARD",
+		})
+		last = key
+	}
+	return fakeXML(r, result)
+}
+
+// listUploads - ListMultipartUploads без постраничной выдачи
+func (f *fakeS3) listUploads(r *http.Request, bucket string) *http.Response {
+	prefix := r.URL.Query().Get("prefix")
+	type upload struct {
+		Key       string
+		UploadId  string
+		Initiated string
+	}
+	var result struct {
+		XMLName     xml.Name `xml:"ListMultipartUploadsResult"`
+		IsTruncated bool
+		Uploads     []upload `xml:"Upload"`
+	}
+	ids := make([]string, 0, len(f.uploads))
+	for id := range f.uploads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		up := f.uploads[id]
+		if up.bucket == bucket && strings.HasPrefix(up.key, prefix) {
+			result.Uploads = append(result.Uploads, upload{Key: up.key, UploadId: id, Initiated: up.initiated.Format(time.RFC3339Nano)})
+		}
+	}
+	return fakeXML(r, result)
+}
+
+// listParts - ListParts с max-parts и part-number-marker
+func (f *fakeS3) listParts(r *http.Request, uploadID string) *http.Response {
+	up := f.uploads[uploadID]
+	if up == nil {
+		return fakeError(r, http.StatusNotFound, "NoSuchUpload")
+	}
+	q := r.URL.Query()
+	maxParts := 1000
+	if f.maxParts > 0 {
+		maxParts = f.maxParts
+	}
+	if v := q.Get("max-parts"); v != "" {
+		maxParts, _ = strconv.Atoi(v)
+	}
+	marker, _ := strconv.Atoi(q.Get("part-number-marker"))
+
+	type part struct {
+		PartNumber int
+		ETag       string
+		Size       int
+	}
+	var result struct {
+		XMLName              xml.Name `xml:"ListPartsResult"`
+		UploadId             string
+		IsTruncated          bool
+		NextPartNumberMarker int    `xml:",omitempty"`
+		Parts                []part `xml:"Part"`
+	}
+	result.UploadId = uploadID
+	numbers := make([]int, 0, len(up.parts))
+	for n := range up.parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		if len(result.Parts) == maxParts {
+			result.IsTruncated = true
+			result.NextPartNumberMarker = result.Parts[len(result.Parts)-1].PartNumber
+			break
+		}
+		result.Parts = append(result.Parts, part{PartNumber: n, ETag: `"` + md5Hex(up.parts[n]) + `"`, Size: len(up.parts[n])})
+	}
+	return fakeXML(r, result)
+}
+
+func TestS3AccelerateAndDualStack(t *testing.T) {
+	tests := []struct {
+		name       string
+		accelerate bool
+		dualStack  bool
+		host       string
+	}{
+		{"default", false, false, "bucket.s3.amazonaws.com"},
+		{"accelerate", true, false, "bucket.s3-accelerate.amazonaws.com"},
+		{"dual stack", false, true, "bucket.s3.dualstack.us-east-1.amazonaws.com"},
+		{"accelerate and dual stack", true, true, "bucket.s3-accelerate.dualstack.amazonaws.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &s3Recorder{}
+			s := newTestS3(t, S3Config{UseAccelerate: tt.accelerate, UseDualStack: tt.dualStack}, roundTripFunc(rec.roundTrip))
+
+			if got := aws.BoolValue(s.client.Config.S3UseAccelerate); got != tt.accelerate {
+				t.Errorf("S3UseAccelerate = %v, want %v", got, tt.accelerate)
+			}
+			if err := s.CreateFile("a.txt", []byte("data"), nil, nil); err != nil {
+				t.Fatalf("CreateFile: %v", err)
+			}
+			if host := rec.last(t).URL.Host; host != tt.host {
+				t.Errorf("request host = %q, want %q", host, tt.host)
+			}
+		})
+	}
+}
+
+// contentHeaders - заголовки объекта, которые должны переживать копирование
+var contentHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Content-Language", "Cache-Control"}
+
+func TestS3SwapFilesKeepsHeaders(t *testing.T) {
+	s, fake := newFakeS3(t, S3Config{})
+	fake.put("a.txt", []byte("aaa"), http.Header{
+		"Content-Type":        {"text/plain"},
+		"Content-Encoding":    {"gzip"},
+		"Content-Disposition": {`attachment; filename="a.txt"`},
+		"Content-Language":    {"en"},
+		"Cache-Control":       {"max-age=60"},
+		"X-Amz-Meta-Owner":    {"alice"},
+	})
+	fake.put("b.json", []byte("{}"), http.Header{
+		"Content-Type":        {"application/json"},
+		"Content-Encoding":    {"br"},
+		"Content-Disposition": {"inline"},
+		"Content-Language":    {"ru"},
+		"Cache-Control":       {"no-cache"},
+		"X-Amz-Meta-Owner":    {"bob"},
+	})
+	wantA := fake.object("b.json").header.Clone()
+	wantB := fake.object("a.txt").header.Clone()
+
+	if err := s.SwapFiles("a.txt", "b.json"); err != nil {
+		t.Fatalf("SwapFiles: %v", err)
+	}
+
+	for _, tt := range []struct {
+		key    string
+		data   string
+		header http.Header
+	}{
+		{"a.txt", "{}", wantA},
+		{"b.json", "aaa", wantB},
+	} {
+		obj := fake.object(tt.key)
+		if obj == nil {
+			t.Fatalf("%s is missing after the swap", tt.key)
+		}
+		if string(obj.data) != tt.data {
+			t.Errorf("%s = %q, want %q", tt.key, obj.data, tt.data)
+		}
+		for _, h := range append(contentHeaders, "X-Amz-Meta-Owner") {
+			if got, want := obj.header.Get(h), tt.header.Get(h); got != want {
+				t.Errorf("%s %s = %q, want %q", tt.key, h, got, want)
+			}
+		}
+	}
+
+	// временный объект и блокировки удалены
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.objects) != 2 {
+		keys := make([]string, 0, len(fake.objects))
+		for k := range fake.objects {
+			keys = append(keys, k)
+		}
+		t.Errorf("objects after the swap = %v, want only the two swapped files", keys)
+	}
+}
+
+func TestS3CopyFileToBucket(t *testing.T) {
+	s, fake := newFakeS3(t, S3Config{})
+	fake.put("src/a.txt", []byte("data"), http.Header{
+		"Content-Type":     {"text/plain"},
+		"Content-Encoding": {"gzip"},
+		"X-Amz-Meta-Owner": {"alice"},
+		"X-Amz-Meta-Stage": {"raw"},
+	})
+
+	if err := s.CopyFileToBucket("src/a.txt", "backup", "dst/a.txt", map[string]string{"Stage": "backup"}); err != nil {
+		t.Fatalf("CopyFileToBucket: %v", err)
+	}
+
+	copies := fake.requestsTo(http.MethodPut, "")
+	if len(copies) != 1 {
+		t.Fatalf("%d PUT requests, want one CopyObject", len(copies))
+	}
+	req := copies[0]
+	if got := req.Header.Get("X-Amz-Copy-Source"); got != "bucket/src/a.txt" {
+		t.Errorf("CopySource = %q, want %q", got, "bucket/src/a.txt")
+	}
+	if bucket, key := bucketKey(req); bucket != "backup" || key != "dst/a.txt" {
+		t.Errorf("copied into %s/%s, want backup/dst/a.txt", bucket, key)
+	}
+
+	obj := fake.objectIn("backup", "dst/a.txt")
+	if obj == nil {
+		t.Fatal("destination object is missing")
+	}
+	if string(obj.data) != "data" {
+		t.Errorf("destination = %q, want %q", obj.data, "data")
+	}
+	for h, want := range map[string]string{
+		"Content-Type":     "text/plain",
+		"Content-Encoding": "gzip",
+		"X-Amz-Meta-Owner": "alice",
+		"X-Amz-Meta-Stage": "backup",
+	} {
+		if got := obj.header.Get(h); got != want {
+			t.Errorf("destination %s = %q, want %q", h, got, want)
+		}
+	}
+	if fake.object("dst/a.txt") != nil {
+		t.Error("object was copied into the store's own bucket")
+	}
+
+	t.Run("missing source", func(t *testing.T) {
+		err := s.CopyFileToBucket("missing.txt", "backup", "dst/missing.txt", nil)
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("error = %v, want ErrFileNotFound", err)
+		}
+	})
+}
+
+func TestS3ContentTypeResolver(t *testing.T) {
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="1" height="1"></svg>`)
+
+	tests := []struct {
+		name  string
+		write func(s *S3) error
+	}{
+		{"CreateFile", func(s *S3) error {
+			return s.CreateFile("img/logo.svg", svg, nil, nil)
+		}},
+		{"StreamToFile", func(s *S3) error {
+			return s.StreamToFile(bytes.NewReader(svg), "img/logo.svg", nil)
+		}},
+		{"FileWriter", func(s *S3) error {
+			w, err := s.FileWriter("img/logo.svg", nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(svg); err != nil {
+				return err
+			}
+			return w.Close()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := newFakeS3(t, S3Config{})
+			if err := tt.write(s); err != nil {
+				t.Fatal(err)
+			}
+			// по содержимому SVG определился бы как text/xml
+			if got := fake.object("img/logo.svg").header.Get("Content-Type"); got != "image/svg+xml" {
+				t.Errorf("Content-Type = %q, want image/svg+xml", got)
+			}
+		})
+	}
+
+	t.Run("custom resolver sees the stream", func(t *testing.T) {
+		var seen []byte
+		s, fake := newFakeS3(t, S3Config{ContentType: func(path string, content []byte) string {
+			seen = append([]byte(nil), content...)
+			return "image/x-custom"
+		}})
+		if err := s.StreamToFile(bytes.NewReader(svg), "img/logo.svg", nil); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(seen, svg) {
+			t.Errorf("resolver got %q, want the stream content", seen)
+		}
+		if got := fake.object("img/logo.svg").header.Get("Content-Type"); got != "image/x-custom" {
+			t.Errorf("Content-Type = %q, want image/x-custom", got)
+		}
+	})
+
+	t.Run("large CreateJsonFile", func(t *testing.T) {
+		s, fake := newFakeS3(t, S3Config{})
+		doc := map[string]string{"blob": strings.Repeat("x", S3PartSize+1)}
+		if err := s.CreateJsonFile("doc.json", doc, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got := fake.object("doc.json").header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		if n := len(fake.requestsTo(http.MethodPost, "uploads")); n != 1 {
+			t.Errorf("%d multipart uploads, want the streamed path", n)
+		}
+	})
+}
+
+func TestS3MetadataLimit(t *testing.T) {
+	// metaOfSize - метаданные с суммой длин ключей и значений size
+	metaOfSize := func(size int) map[string]string {
+		return map[string]string{"Note": strings.Repeat("x", size-len("Note"))}
+	}
+	oversized := metaOfSize(s3MaxMetadataSize + 1)
+
+	tests := []struct {
+		name  string
+		write func(s *S3) error
+	}{
+		{"CreateFile", func(s *S3) error {
+			return s.CreateFile("a.txt", []byte("a"), nil, oversized)
+		}},
+		{"CreateFileWithOptions", func(s *S3) error {
+			return s.CreateFileWithOptions("a.txt", []byte("a"), PutOptions{Meta: oversized})
+		}},
+		{"FileWriter", func(s *S3) error {
+			w, err := s.FileWriter("a.txt", nil, oversized)
+			if err != nil {
+				return err
+			}
+			w.Write([]byte("a"))
+			return w.Close()
+		}},
+		{"CopyFile over the limit with the source meta", func(s *S3) error {
+			// метаданные источника и добавленные при копировании вместе больше 2KB
+			if err := s.CreateFile("src.txt", []byte("a"), nil, metaOfSize(1500)); err != nil {
+				return err
+			}
+			return s.CopyFile("src.txt", "a.txt", nil, map[string]string{"Extra": strings.Repeat("y", 600)})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newFakeS3(t, S3Config{})
+			err := tt.write(s)
+			if !errors.Is(err, ErrMetadataTooLarge) {
+				t.Fatalf("error = %v, want ErrMetadataTooLarge", err)
+			}
+			if !strings.Contains(err.Error(), "limit 2048") {
+				t.Errorf("error %q does not state the limit", err)
+			}
+			// запрос с такими метаданными в S3 не уходит
+			if f.object("a.txt") != nil {
+				t.Error("a.txt was written despite the error")
+			}
+			if n := len(f.requestsTo(http.MethodPost, "uploads")); n != 0 {
+				t.Errorf("%d multipart uploads started, want none", n)
+			}
+		})
+	}
+
+	t.Run("exactly at the limit", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		if err := s.CreateFile("a.txt", []byte("a"), nil, metaOfSize(s3MaxMetadataSize)); err != nil {
+			t.Fatalf("CreateFile with 2048 bytes of meta = %v, want nil", err)
+		}
+		if f.object("a.txt") == nil {
+			t.Error("a.txt not written")
+		}
+	})
+
+	t.Run("sidecar backends have no limit", func(t *testing.T) {
+		w, _ := newTestWebDavDir(t, WebDavConfig{})
+		l, dir := newTestLocal(t, LocalConfig{}), t.TempDir()
+		for s, path := range map[StoreIFace]string{w: "a.txt", l: dir + "/a.txt"} {
+			if err := s.CreateFile(path, []byte("a"), nil, oversized); err != nil {
+				t.Errorf("%s CreateFile = %v, want no metadata limit", s.Backend(), err)
+			}
+		}
+	})
+}
+
+func TestS3ArchivedObjects(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	data := []byte("cold data")
+	f.put("hot.bin", data, nil)
+	f.put("cold.bin", data, http.Header{"X-Amz-Storage-Class": {"GLACIER"}})
+	f.put("deep.bin", data, http.Header{"X-Amz-Storage-Class": {"DEEP_ARCHIVE"}})
+
+	for path, want := range map[string]string{"hot.bin": "STANDARD", "cold.bin": "GLACIER", "deep.bin": "DEEP_ARCHIVE"} {
+		if got, err := s.StorageClass(path); err != nil || got != want {
+			t.Errorf("StorageClass(%s) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if _, err := s.StorageClass("missing.bin"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("StorageClass of a missing object = %v, want ErrFileNotFound", err)
+	}
+
+	reads := []struct {
+		name string
+		read func(s *S3, path string) error
+	}{
+		{"GetFile", func(s *S3, path string) error {
+			_, err := s.GetFile(path)
+			return err
+		}},
+		{"FileReader", func(s *S3, path string) error {
+			_, err := s.FileReader(path, 0, 0)
+			return err
+		}},
+		{"GetFileIfModifiedSince", func(s *S3, path string) error {
+			_, _, err := s.GetFileIfModifiedSince(path, time.Time{})
+			return err
+		}},
+		{"GetFileVerified", func(s *S3, path string) error {
+			_, err := s.GetFileVerified(path)
+			return err
+		}},
+		{"WriteTo", func(s *S3, path string) error {
+			_, err := s.WriteTo(path, io.Discard)
+			return err
+		}},
+	}
+	for _, rd := range reads {
+		for _, path := range []string{"cold.bin", "deep.bin"} {
+			if err := rd.read(s, path); !errors.Is(err, ErrObjectArchived) || errors.Is(err, ErrFileNotFound) {
+				t.Errorf("%s(%s) = %v, want ErrObjectArchived", rd.name, path, err)
+			}
+		}
+		if err := rd.read(s, "hot.bin"); err != nil {
+			t.Errorf("%s(hot.bin) = %v, want nil", rd.name, err)
+		}
+	}
+
+	t.Run("AutoDecompress", func(t *testing.T) {
+		gz, gf := newFakeS3(t, S3Config{AutoDecompress: true})
+		gf.put("cold.bin", data, http.Header{"X-Amz-Storage-Class": {"GLACIER"}})
+		if _, err := gz.GetFile("cold.bin"); !errors.Is(err, ErrObjectArchived) {
+			t.Errorf("GetFile = %v, want ErrObjectArchived", err)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		if err := s.RestoreObject("cold.bin", 3, "Bulk"); err != nil {
+			t.Fatalf("RestoreObject: %v", err)
+		}
+		restores := f.requestsTo(http.MethodPost, "restore")
+		if len(restores) != 1 {
+			t.Fatalf("%d restore requests, want 1", len(restores))
+		}
+		rc, _ := restores[0].GetBody()
+		body, _ := io.ReadAll(rc)
+		for _, want := range []string{"<Days>3</Days>", "<Tier>Bulk</Tier>"} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("restore request %s has no %s", body, want)
+			}
+		}
+
+		// восстановление уже идет - не ошибка, объект по-прежнему в архиве
+		if err := s.RestoreObject("cold.bin", 3, ""); err != nil {
+			t.Errorf("RestoreObject during a restore = %v, want nil", err)
+		}
+		if restores = f.requestsTo(http.MethodPost, "restore"); len(restores) == 2 {
+			rc, _ := restores[1].GetBody()
+			if body, _ := io.ReadAll(rc); bytes.Contains(body, []byte("<Tier>")) {
+				t.Errorf("restore request %s without a tier sets one", body)
+			}
+		}
+		if _, err := s.GetFile("cold.bin"); !errors.Is(err, ErrObjectArchived) {
+			t.Errorf("GetFile during a restore = %v, want ErrObjectArchived", err)
+		}
+
+		// восстановленная копия читается
+		f.object("cold.bin").header.Set("X-Amz-Restore", `ongoing-request="false", expiry-date="Fri, 21 Dec 2035 00:00:00 GMT"`)
+		if got, err := s.GetFile("cold.bin"); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("GetFile after the restore = %q, %v; want %q", got, err, data)
+		}
+
+		if err := s.RestoreObject("missing.bin", 1, ""); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("RestoreObject of a missing object = %v, want ErrFileNotFound", err)
+		}
+	})
+}
+
+func TestS3ObjectLock(t *testing.T) {
+	until := time.Date(2031, 6, 1, 12, 0, 0, 0, time.UTC)
+	lock := &ObjectLock{Mode: "COMPLIANCE", RetainUntil: until, LegalHold: true}
+
+	t.Run("at write time", func(t *testing.T) {
+		writes := []struct {
+			name  string
+			write func(s *S3) error
+		}{
+			{"CreateFileWithOptions", func(s *S3) error {
+				return s.CreateFileWithOptions("records/a.pdf", []byte("a"), PutOptions{Lock: lock})
+			}},
+			{"CopyFileWithOptions", func(s *S3) error {
+				if err := s.CreateFile("draft.pdf", []byte("a"), nil, nil); err != nil {
+					return err
+				}
+				return s.CopyFileWithOptions("draft.pdf", "records/a.pdf", PutOptions{Lock: lock})
+			}},
+		}
+		for _, w := range writes {
+			t.Run(w.name, func(t *testing.T) {
+				s, f := newFakeS3(t, S3Config{})
+				if err := w.write(s); err != nil {
+					t.Fatal(err)
+				}
+				puts := f.requestsTo(http.MethodPut, "")
+				h := puts[len(puts)-1].Header
+				for name, want := range map[string]string{
+					"X-Amz-Object-Lock-Mode":              "COMPLIANCE",
+					"X-Amz-Object-Lock-Retain-Until-Date": "2031-06-01T12:00:00Z",
+					"X-Amz-Object-Lock-Legal-Hold":        "ON",
+				} {
+					if got := h.Get(name); got != want {
+						t.Errorf("%s = %q, want %q", name, got, want)
+					}
+				}
+				got, err := s.GetObjectLock("records/a.pdf")
+				if err != nil || got.Mode != "COMPLIANCE" || !got.RetainUntil.Equal(until) || !got.LegalHold {
+					t.Errorf("GetObjectLock = %+v, %v; want %+v", got, err, *lock)
+				}
+			})
+		}
+
+		// legal hold без срока хранения не передает режим
+		s, f := newFakeS3(t, S3Config{})
+		if err := s.CreateFileWithOptions("hold.pdf", []byte("a"), PutOptions{Lock: &ObjectLock{LegalHold: true}}); err != nil {
+			t.Fatal(err)
+		}
+		h := f.requestsTo(http.MethodPut, "")[0].Header
+		if h.Get("X-Amz-Object-Lock-Mode") != "" || h.Get("X-Amz-Object-Lock-Retain-Until-Date") != "" || h.Get("X-Amz-Object-Lock-Legal-Hold") != "ON" {
+			t.Errorf("lock headers = %v, want only the legal hold", h)
+		}
+	})
+
+	t.Run("on an existing object", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("a.pdf", []byte("a"), nil)
+		if got, err := s.GetObjectLock("a.pdf"); err != nil || got != (ObjectLock{}) {
+			t.Errorf("GetObjectLock of an unlocked object = %+v, %v; want empty", got, err)
+		}
+
+		if err := s.SetObjectLock("a.pdf", "GOVERNANCE", until); err != nil {
+			t.Fatalf("SetObjectLock: %v", err)
+		}
+		retention := f.requestsTo(http.MethodPut, "retention")
+		if len(retention) != 1 {
+			t.Fatalf("%d retention requests, want 1", len(retention))
+		}
+		rc, _ := retention[0].GetBody()
+		body, _ := io.ReadAll(rc)
+		for _, want := range []string{"<Mode>GOVERNANCE</Mode>", "<RetainUntilDate>2031-06-01T12:00:00Z</RetainUntilDate>"} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("retention request %s lacks %s", body, want)
+			}
+		}
+
+		if err := s.SetLegalHold("a.pdf", true); err != nil {
+			t.Fatalf("SetLegalHold(true): %v", err)
+		}
+		got, err := s.GetObjectLock("a.pdf")
+		if err != nil || got.Mode != "GOVERNANCE" || !got.RetainUntil.Equal(until) || !got.LegalHold {
+			t.Errorf("GetObjectLock = %+v, %v; want GOVERNANCE until %v with legal hold", got, err, until)
+		}
+
+		if err := s.SetLegalHold("a.pdf", false); err != nil {
+			t.Fatalf("SetLegalHold(false): %v", err)
+		}
+		holds := f.requestsTo(http.MethodPut, "legal-hold")
+		rc, _ = holds[len(holds)-1].GetBody()
+		if body, _ := io.ReadAll(rc); !bytes.Contains(body, []byte("<Status>OFF</Status>")) {
+			t.Errorf("legal hold request %s, want status OFF", body)
+		}
+		if got, err := s.GetObjectLock("a.pdf"); err != nil || got.LegalHold {
+			t.Errorf("GetObjectLock after releasing the hold = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		if err := s.SetObjectLock("missing.pdf", "GOVERNANCE", until); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("SetObjectLock = %v, want ErrFileNotFound", err)
+		}
+		if err := s.SetLegalHold("missing.pdf", true); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("SetLegalHold = %v, want ErrFileNotFound", err)
+		}
+		if _, err := s.GetObjectLock("missing.pdf"); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("GetObjectLock = %v, want ErrFileNotFound", err)
+		}
+	})
+}
+
+// countingRetryer - политика повторов с паузой 0, считающая свои вызовы
+type countingRetryer struct {
+	mu          sync.Mutex
+	max         int
+	retry       bool
+	shouldRetry int
+	rules       int
+}
+
+func (c *countingRetryer) MaxRetries() int {
+	return c.max
+}
+
+func (c *countingRetryer) ShouldRetry(*request.Request) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shouldRetry++
+	return c.retry
+}
+
+func (c *countingRetryer) RetryRules(*request.Request) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules++
+	return 0
+}
+
+func TestS3Retries(t *testing.T) {
+	// failing - S3, отвечающий 503 на первые fail запросов GET
+	failing := func(t *testing.T, cfg S3Config, fail int) (*S3, *fakeS3) {
+		s, f := newFakeS3(t, cfg)
+		f.put("a.txt", []byte("data"), nil)
+		var mu sync.Mutex
+		f.intercept = func(r *http.Request) *http.Response {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.Method != http.MethodGet || fail == 0 {
+				return nil
+			}
+			fail--
+			return fakeError(r, http.StatusServiceUnavailable, "ServiceUnavailable")
+		}
+		return s, f
+	}
+
+	t.Run("MaxRetries", func(t *testing.T) {
+		for _, retries := range []int{0, 2} {
+			s, f := failing(t, S3Config{MaxRetries: aws.Int(retries)}, 100)
+			if _, err := s.GetFile("a.txt"); ErrorCode(err) != CodeUnavailable {
+				t.Errorf("MaxRetries %d: GetFile = %v, want the 503", retries, err)
+			}
+			if n := len(f.requestsTo(http.MethodGet, "")); n != retries+1 {
+				t.Errorf("MaxRetries %d: %d GET requests, want %d", retries, n, retries+1)
+			}
+		}
+	})
+
+	t.Run("custom retryer", func(t *testing.T) {
+		// Retryer заменяет MaxRetries
+		retryer := &countingRetryer{max: 5, retry: true}
+		s, f := failing(t, S3Config{MaxRetries: aws.Int(0), Retryer: retryer}, 100)
+		if _, err := s.GetFile("a.txt"); ErrorCode(err) != CodeUnavailable {
+			t.Errorf("GetFile = %v, want the 503", err)
+		}
+		if n := len(f.requestsTo(http.MethodGet, "")); n != 6 {
+			t.Errorf("%d GET requests, want 6 with the retryer allowing 5 retries", n)
+		}
+		if retryer.shouldRetry == 0 || retryer.rules != 5 {
+			t.Errorf("retryer asked %d times, delayed %d times; want it consulted before each of the 5 retries", retryer.shouldRetry, retryer.rules)
+		}
+	})
+
+	t.Run("custom retryer recovers", func(t *testing.T) {
+		retryer := &countingRetryer{max: 5, retry: true}
+		s, f := failing(t, S3Config{Retryer: retryer}, 2)
+		if got, err := s.GetFile("a.txt"); err != nil || string(got) != "data" {
+			t.Errorf("GetFile = %q, %v; want data after 2 retries", got, err)
+		}
+		if n := len(f.requestsTo(http.MethodGet, "")); n != 3 {
+			t.Errorf("%d GET requests, want 3", n)
+		}
+	})
+
+	t.Run("custom retryer refuses", func(t *testing.T) {
+		retryer := &countingRetryer{max: 5}
+		s, f := failing(t, S3Config{Retryer: retryer}, 100)
+		if _, err := s.GetFile("a.txt"); ErrorCode(err) != CodeUnavailable {
+			t.Errorf("GetFile = %v, want the 503", err)
+		}
+		if n := len(f.requestsTo(http.MethodGet, "")); n != 1 || retryer.shouldRetry == 0 {
+			t.Errorf("%d GET requests after %d ShouldRetry calls, want 1 with the retryer consulted", n, retryer.shouldRetry)
+		}
+	})
+}
+
+// headsOf - количество HEAD-запросов к ключу key
+func headsOf(f *fakeS3, key string) int {
+	n := 0
+	for _, r := range f.requestsTo(http.MethodHead, "") {
+		if _, k := bucketKey(r); k == key {
+			n++
+		}
+	}
+	return n
+}
+
+func TestS3MoveFileWait(t *testing.T) {
+	// hidden - S3, у которого HEAD key всегда отвечает 404
+	hidden := func(t *testing.T, cfg S3Config, key string) (StoreIFace, *fakeS3) {
+		s, f := newFakeS3(t, cfg)
+		f.put("src.txt", []byte("data"), nil)
+		f.intercept = func(r *http.Request) *http.Response {
+			if _, k := bucketKey(r); r.Method == http.MethodHead && k == key {
+				return fakeResponse(r, http.StatusNotFound, nil, nil)
+			}
+			return nil
+		}
+		return s, f
+	}
+
+	t.Run("waits once each way", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{MoveWaitDelay: time.Millisecond})
+		f.put("src.txt", []byte("data"), nil)
+		if err := s.MoveFile("src.txt", "dst.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if dst, src := headsOf(f, "dst.txt"), headsOf(f, "src.txt"); dst != 1 || src != 1 {
+			t.Errorf("HEAD dst = %d, src = %d; want 1 and 1", dst, src)
+		}
+		readThrough(t, s, "dst.txt", "data")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{SkipMoveWait: true, MoveWaitAttempts: 3})
+		f.put("src.txt", []byte("data"), nil)
+		if err := s.MoveFile("src.txt", "dst.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(f.requestsTo(http.MethodHead, "")); n != 0 {
+			t.Errorf("%d HEAD requests with SkipMoveWait, want 0", n)
+		}
+		readThrough(t, s, "dst.txt", "data")
+	})
+
+	t.Run("attempts and delay", func(t *testing.T) {
+		const delay = 20 * time.Millisecond
+		s, f := hidden(t, S3Config{MoveWaitAttempts: 3, MoveWaitDelay: delay}, "dst.txt")
+		start := time.Now()
+		if err := s.MoveFile("src.txt", "dst.txt"); err == nil {
+			t.Fatal("MoveFile succeeded while the copy never appeared")
+		}
+		if elapsed := time.Since(start); elapsed < 2*delay || elapsed > time.Second {
+			t.Errorf("MoveFile took %v, want 2 delays of %v between 3 attempts", elapsed, delay)
+		}
+		if n := headsOf(f, "dst.txt"); n != 3 {
+			t.Errorf("HEAD dst = %d, want MoveWaitAttempts (3)", n)
+		}
+		// исходный объект не удаляется, пока копия не подтверждена
+		if f.object("src.txt") == nil {
+			t.Error("src.txt deleted before the copy was confirmed")
+		}
+	})
+
+	t.Run("not exists attempts", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{MoveWaitAttempts: 2, MoveWaitDelay: time.Millisecond})
+		f.put("src.txt", []byte("data"), nil)
+		// удаление src не видно: HEAD src продолжает отвечать 200
+		f.intercept = func(r *http.Request) *http.Response {
+			if _, k := bucketKey(r); r.Method == http.MethodHead && k == "src.txt" {
+				return fakeResponse(r, http.StatusOK, nil, nil)
+			}
+			return nil
+		}
+		if err := s.MoveFile("src.txt", "dst.txt"); err == nil {
+			t.Fatal("MoveFile succeeded while src.txt never disappeared")
+		}
+		if n := headsOf(f, "src.txt"); n != 2 {
+			t.Errorf("HEAD src = %d, want MoveWaitAttempts (2)", n)
+		}
+	})
+
+	t.Run("context cancels the wait", func(t *testing.T) {
+		// без отмены ожидание длилось бы 20 попыток SDK по часу
+		s, f := hidden(t, S3Config{MoveWaitDelay: time.Hour}, "dst.txt")
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := s.MoveFileWithContext(ctx, "src.txt", "dst.txt")
+		if !errors.Is(err, context.DeadlineExceeded) || ErrorCode(err) != CodeUnavailable {
+			t.Errorf("MoveFileWithContext = %v, want context.DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("MoveFileWithContext took %v after the context expired", elapsed)
+		}
+		if f.object("src.txt") == nil {
+			t.Error("src.txt deleted after a cancelled wait")
+		}
+	})
+}
+
+// countingBody - тело ответа, считающее вызовы Read
+type countingBody struct {
+	io.Reader
+	reads atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	b.reads.Add(1)
+	return b.Reader.Read(p)
+}
+
+func (b *countingBody) Close() error { return nil }
+
+func TestS3ReadBufferSize(t *testing.T) {
+	const (
+		size  = 256 << 10
+		chunk = 16
+	)
+	data := bytes.Repeat([]byte("0123456789abcdef"), size/16)
+
+	tests := []struct {
+		name       string
+		bufferSize int
+		// minReads, maxReads - допустимое число чтений тела ответа
+		minReads, maxReads int64
+	}{
+		{"default", 0, size / defaultReadBufferSize, size/defaultReadBufferSize + 2},
+		{"custom", 4 << 10, size / (4 << 10), size/(4<<10) + 2},
+		{"disabled", -1, size / chunk, size/chunk + 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newFakeS3(t, S3Config{ReadBufferSize: tt.bufferSize})
+			f.put("big.bin", data, nil)
+			body := &countingBody{Reader: bytes.NewReader(data)}
+			f.intercept = func(r *http.Request) *http.Response {
+				if r.Method != http.MethodGet {
+					return nil
+				}
+				resp := fakeResponse(r, http.StatusOK, nil, data)
+				resp.Body = body
+				return resp
+			}
+
+			r, err := s.FileReader("big.bin", 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			// потребитель читает мелкими порциями
+			var got bytes.Buffer
+			buf := make([]byte, chunk)
+			for {
+				n, err := r.Read(buf)
+				got.Write(buf[:n])
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got.Bytes(), data) {
+				t.Fatalf("read %d bytes, want the %d byte object", got.Len(), len(data))
+			}
+			if n := body.reads.Load(); n < tt.minReads || n > tt.maxReads {
+				t.Errorf("%d reads of the response body, want %d..%d", n, tt.minReads, tt.maxReads)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const (
+	// confirmPollInterval - первая пауза между HeadObject в ConfirmWritten, далее удваивается
+	confirmPollInterval = 100 * time.Millisecond
+	// confirmMaxPollInterval - наибольшая пауза между HeadObject в ConfirmWritten
+	confirmMaxPollInterval = 2 * time.Second
+)
+
+// NotConfirmedError - ConfirmWritten не дождался объекта нужного размера
+// Size - размер объекта при последней проверке, -1 - объект не найден
+// Err - ошибка последнего HeadObject, кроме "не найден"
+// errors.Is(err, ErrNotConfirmed) для нее истинно
+type NotConfirmedError struct {
+	Path         string
+	ExpectedSize int64
+	Size         int64
+	Polls        int
+	Err          error
+}
+
+func (e *NotConfirmedError) Error() string {
+	msg := fmt.Sprintf("%s: %s: expected size %d", ErrNotConfirmed, e.Path, e.ExpectedSize)
+	if e.Size >= 0 {
+		msg += fmt.Sprintf(", got %d", e.Size)
+	} else {
+		msg += ", object not found"
+	}
+	msg += fmt.Sprintf(" after %d polls", e.Polls)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *NotConfirmedError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrNotConfirmed, e.Err}
+	}
+	return []error{ErrNotConfirmed}
+}
+
+// ConfirmWritten - дожидается, пока записанный объект станет виден с ожидаемым размером
+// path - путь к файлу
+// expectedSize - ожидаемый размер, отрицательный - подходит любой размер
+// timeout - сколько ждать; по истечении возвращается *NotConfirmedError
+// HeadObject повторяется с паузой от 100ms, удваивающейся до 2s
+func (s *S3) ConfirmWritten(path string, expectedSize int64, timeout time.Duration) error {
+	return s.ConfirmWrittenWithContext(context.Background(), path, expectedSize, timeout)
+}
+
+// ConfirmWrittenWithContext - дожидается, пока записанный объект станет виден с ожидаемым размером
+// path - путь к файлу
+// expectedSize - ожидаемый размер, отрицательный - подходит любой размер
+// timeout - сколько ждать; по истечении возвращается *NotConfirmedError
+// при отмене ctx возвращается ошибка контекста
+func (s *S3) ConfirmWrittenWithContext(ctx context.Context, path string, expectedSize int64, timeout time.Duration) error {
+	// timeout ограничивает и зависший HeadObject, а не только паузы между ними
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	notConfirmed := &NotConfirmedError{Path: path, ExpectedSize: expectedSize, Size: -1}
+	interval := confirmPollInterval
+	for {
+		notConfirmed.Polls++
+		head, err := s.client.HeadObjectWithContext(
+			pollCtx,
+			&s3.HeadObjectInput{
+				Bucket: s.S3Bucket,
+				Key:    aws.String(path),
+			})
+
+		switch {
+		case err == nil:
+			notConfirmed.Size = aws.Int64Value(head.ContentLength)
+			notConfirmed.Err = nil
+			if expectedSize < 0 || notConfirmed.Size == expectedSize {
+				return nil
+			}
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case pollCtx.Err() != nil:
+			// прерванная проверка ничего не сообщает об объекте
+			notConfirmed.Polls--
+			return notConfirmed
+		case isS3NotFound(err):
+			notConfirmed.Size = -1
+			notConfirmed.Err = nil
+		default:
+			notConfirmed.Err = err
+		}
+
+		select {
+		case <-pollCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return notConfirmed
+		case <-time.After(interval):
+		}
+		interval = min(2*interval, confirmMaxPollInterval)
+	}
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// laggingS3 - S3, в котором HEAD не видит объект первые hidden запросов
+func laggingS3(t *testing.T, hidden int) (*S3, *fakeS3) {
+	s, f := newFakeS3(t, S3Config{})
+	var mu sync.Mutex
+	f.intercept = func(r *http.Request) *http.Response {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != http.MethodHead || hidden == 0 {
+			return nil
+		}
+		hidden--
+		return fakeError(r, http.StatusNotFound, "NotFound")
+	}
+	return s, f
+}
+
+func TestConfirmWritten(t *testing.T) {
+	t.Run("appears after a couple of polls", func(t *testing.T) {
+		s, f := laggingS3(t, 2)
+		f.put("a.txt", []byte("data"), nil)
+		if err := s.ConfirmWritten("a.txt", 4, 5*time.Second); err != nil {
+			t.Fatalf("ConfirmWritten = %v", err)
+		}
+		if n := len(f.requestsTo(http.MethodHead, "")); n != 3 {
+			t.Errorf("%d HEAD requests, want 3", n)
+		}
+	})
+
+	t.Run("any size", func(t *testing.T) {
+		s, f := laggingS3(t, 0)
+		f.put("a.txt", []byte("data"), nil)
+		if err := s.ConfirmWritten("a.txt", -1, time.Second); err != nil {
+			t.Fatalf("ConfirmWritten = %v", err)
+		}
+		if n := len(f.requestsTo(http.MethodHead, "")); n != 1 {
+			t.Errorf("%d HEAD requests, want 1", n)
+		}
+	})
+
+	t.Run("size settles", func(t *testing.T) {
+		s, f := laggingS3(t, 0)
+		f.put("a.txt", []byte("old"), nil)
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			f.put("a.txt", []byte("newer"), nil)
+		}()
+		if err := s.ConfirmWritten("a.txt", 5, 5*time.Second); err != nil {
+			t.Fatalf("ConfirmWritten = %v", err)
+		}
+	})
+}
+
+func TestConfirmWrittenTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(f *fakeS3)
+		wantSize int64
+		wantCode string
+	}{
+		{"never appears", func(f *fakeS3) {}, -1, ""},
+		{"wrong size", func(f *fakeS3) { f.put("a.txt", []byte("short"), nil) }, 5, ""},
+		{"access denied", func(f *fakeS3) {
+			f.intercept = func(r *http.Request) *http.Response {
+				return fakeError(r, http.StatusForbidden, "Forbidden")
+			}
+		}, -1, "Forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newFakeS3(t, S3Config{})
+			tt.setup(f)
+
+			start := time.Now()
+			err := s.ConfirmWritten("a.txt", 100, 250*time.Millisecond)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("returned after %v, want about the 250ms timeout", elapsed)
+			}
+
+			var notConfirmed *NotConfirmedError
+			if !errors.As(err, &notConfirmed) || !errors.Is(err, ErrNotConfirmed) {
+				t.Fatalf("ConfirmWritten = %v, want *NotConfirmedError", err)
+			}
+			if ErrorCode(err) != CodeUnavailable {
+				t.Errorf("ErrorCode = %v, want CodeUnavailable", ErrorCode(err))
+			}
+			if notConfirmed.Path != "a.txt" || notConfirmed.ExpectedSize != 100 || notConfirmed.Size != tt.wantSize {
+				t.Errorf("error = %+v, want a.txt expected at 100 bytes with size %d", notConfirmed, tt.wantSize)
+			}
+			// паузы 100ms и 200ms: до таймаута успевают две проверки
+			if n := len(f.requestsTo(http.MethodHead, "")); notConfirmed.Polls != n || n < 2 {
+				t.Errorf("%d polls reported, %d HEAD requests sent; want the same, at least 2", notConfirmed.Polls, n)
+			}
+			if tt.wantCode == "" && notConfirmed.Err != nil {
+				t.Errorf("Err = %v, want none", notConfirmed.Err)
+			}
+			if tt.wantCode != "" && ErrorCode(notConfirmed.Err) != CodePermission {
+				t.Errorf("Err = %v, want the last HEAD error", notConfirmed.Err)
+			}
+		})
+	}
+}
+
+func TestConfirmWrittenInterrupted(t *testing.T) {
+	t.Run("hanging HEAD", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.intercept = func(r *http.Request) *http.Response {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return fakeError(r, http.StatusInternalServerError, "InternalError")
+		}
+
+		start := time.Now()
+		err := s.ConfirmWritten("a.txt", 4, 100*time.Millisecond)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("returned after %v, want the timeout to cut the HEAD short", elapsed)
+		}
+		var notConfirmed *NotConfirmedError
+		if !errors.As(err, &notConfirmed) || notConfirmed.Polls != 0 || notConfirmed.Err != nil {
+			t.Errorf("ConfirmWritten = %#v, want *NotConfirmedError without completed polls", err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(150*time.Millisecond, cancel)
+
+		err := s.ConfirmWrittenWithContext(ctx, "a.txt", 4, 5*time.Second)
+		if !errors.Is(err, context.Canceled) || errors.Is(err, ErrNotConfirmed) {
+			t.Errorf("ConfirmWrittenWithContext = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// PostConditions - ограничения presigned POST, которые S3 проверяет при загрузке
+// MinSize, MaxSize - допустимый размер файла (content-length-range), MaxSize 0 - без ограничения
+// ContentType - точное значение Content-Type
+// ContentTypePrefix - начало Content-Type, например "image/"; игнорируется при заданном ContentType
+// ACL - видимость загруженного файла
+// Meta - метаданные, которые форма обязана передать как есть
+type PostConditions struct {
+	MinSize           int64
+	MaxSize           int64
+	ContentType       string
+	ContentTypePrefix string
+	ACL               ACL
+	Meta              map[string]string
+}
+
+// PostPolicy - данные для HTML формы загрузки напрямую в S3
+// URL - адрес action формы
+// Fields - скрытые поля формы; файл передается последним полем "file"
+// поле key содержит ${filename}, S3 подставляет вместо него имя загружаемого файла,
+// при необходимости форма может заменить key любым значением с тем же префиксом
+type PostPolicy struct {
+	URL    string
+	Fields map[string]string
+}
+
+// PresignPost - формирует подписанную политику POST загрузки из браузера
+// keyPrefix - префикс ключей, в которые разрешена загрузка
+// conditions - ограничения загрузки
+// expires - время жизни политики
+func (s *S3) PresignPost(keyPrefix string, conditions PostConditions, expires time.Duration) (PostPolicy, error) {
+	return s.PresignPostWithContext(context.Background(), keyPrefix, conditions, expires)
+}
+
+// PresignPostWithContext - формирует подписанную политику POST загрузки из браузера
+// keyPrefix - префикс ключей, в которые разрешена загрузка
+// conditions - ограничения загрузки
+// expires - время жизни политики
+func (s *S3) PresignPostWithContext(ctx context.Context, keyPrefix string, conditions PostConditions, expires time.Duration) (PostPolicy, error) {
+	creds, err := s.client.Config.Credentials.GetWithContext(ctx)
+	if err != nil {
+		return PostPolicy{}, err
+	}
+
+	bucketURL, err := s.bucketURL()
+	if err != nil {
+		return PostPolicy{}, err
+	}
+
+	region := s.client.SigningRegion
+	if region == "" {
+		region = aws.StringValue(s.client.Config.Region)
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+
+	fields := map[string]string{
+		"key":              keyPrefix + "${filename}",
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": fmt.Sprintf("%s/%s/%s/s3/aws4_request", creds.AccessKeyID, date, region),
+		"x-amz-date":       now.Format("20060102T150405Z"),
+	}
+	if creds.SessionToken != "" {
+		fields["x-amz-security-token"] = creds.SessionToken
+	}
+	if conditions.ContentType != "" {
+		fields["Content-Type"] = conditions.ContentType
+	}
+	if acl := s3ACL(conditions.ACL); acl != nil {
+		fields["acl"] = *acl
+	}
+	for k, v := range conditions.Meta {
+		fields["x-amz-meta-"+k] = v
+	}
+
+	// все поля формы, кроме key, должны совпадать точно; key - по префиксу
+	policyConditions := []interface{}{
+		map[string]string{"bucket": aws.StringValue(s.S3Bucket)},
+		[]string{"starts-with", "$key", keyPrefix},
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "key" {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		policyConditions = append(policyConditions, map[string]string{k: fields[k]})
+	}
+	if conditions.ContentType == "" && conditions.ContentTypePrefix != "" {
+		policyConditions = append(policyConditions, []string{"starts-with", "$Content-Type", conditions.ContentTypePrefix})
+	}
+	if conditions.MaxSize > 0 {
+		policyConditions = append(policyConditions, []interface{}{"content-length-range", conditions.MinSize, conditions.MaxSize})
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": now.Add(expires).Format("2006-01-02T15:04:05.000Z"),
+		"conditions": policyConditions,
+	})
+	if err != nil {
+		return PostPolicy{}, err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(policy)
+	fields["policy"] = encoded
+	fields["x-amz-signature"] = hex.EncodeToString(
+		hmacSHA256(postSigningKey(creds.SecretAccessKey, date, region), encoded),
+	)
+
+	return PostPolicy{URL: bucketURL, Fields: fields}, nil
+}
+
+// postSigningKey - ключ подписи Signature Version 4 для сервиса s3
+func postSigningKey(secret, date, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
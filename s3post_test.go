@@ -0,0 +1,155 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// postPolicyDoc - раскодированное поле policy
+type postPolicyDoc struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// decodePostPolicy - документ политики и проверка подписи ключом secret
+func decodePostPolicy(t *testing.T, p PostPolicy, secret string) postPolicyDoc {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(p.Fields["policy"])
+	if err != nil {
+		t.Fatalf("policy is not base64: %v", err)
+	}
+	var doc postPolicyDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("policy is not JSON: %v", err)
+	}
+
+	// подпись Signature Version 4: ключ из даты, региона и сервиса из x-amz-credential
+	scope := strings.Split(p.Fields["x-amz-credential"], "/")
+	if len(scope) != 5 {
+		t.Fatalf("x-amz-credential = %q", p.Fields["x-amz-credential"])
+	}
+	key := []byte("AWS4" + secret)
+	for _, part := range append(scope[1:4], "aws4_request") {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(part))
+		key = h.Sum(nil)
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(p.Fields["policy"]))
+	if want := hex.EncodeToString(h.Sum(nil)); p.Fields["x-amz-signature"] != want {
+		t.Errorf("x-amz-signature = %s, want %s", p.Fields["x-amz-signature"], want)
+	}
+	return doc
+}
+
+// hasCondition - есть ли в политике условие, равное want после JSON
+func hasCondition(doc postPolicyDoc, want interface{}) bool {
+	raw, _ := json.Marshal(want)
+	var norm interface{}
+	json.Unmarshal(raw, &norm)
+	for _, c := range doc.Conditions {
+		if reflect.DeepEqual(c, norm) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestS3PresignPost(t *testing.T) {
+	s, _ := newFakeS3(t, S3Config{})
+	start := time.Now().UTC()
+
+	p, err := s.PresignPost("uploads/user-1/", PostConditions{
+		MinSize:           1,
+		MaxSize:           10 << 20,
+		ContentTypePrefix: "image/",
+		ACL:               ACLPublicRead,
+		Meta:              map[string]string{"owner": "user-1"},
+	}, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPost: %v", err)
+	}
+
+	if p.URL != "https://bucket.s3.amazonaws.com" {
+		t.Errorf("URL = %q, want the bucket endpoint", p.URL)
+	}
+	if p.Fields["key"] != "uploads/user-1/${filename}" {
+		t.Errorf("key = %q, want the prefix with ${filename}", p.Fields["key"])
+	}
+	date := start.Format("20060102")
+	if want := "id/" + date + "/us-east-1/s3/aws4_request"; p.Fields["x-amz-credential"] != want {
+		t.Errorf("x-amz-credential = %q, want %q", p.Fields["x-amz-credential"], want)
+	}
+	if _, ok := p.Fields["x-amz-security-token"]; ok {
+		t.Error("x-amz-security-token set for credentials without a session token")
+	}
+
+	doc := decodePostPolicy(t, p, "secret")
+	expiration, err := time.Parse("2006-01-02T15:04:05.000Z", doc.Expiration)
+	if err != nil || expiration.Before(start.Add(15*time.Minute-time.Second)) || expiration.After(time.Now().Add(15*time.Minute)) {
+		t.Errorf("expiration = %q, want 15 minutes from now", doc.Expiration)
+	}
+
+	for _, want := range []interface{}{
+		map[string]string{"bucket": "bucket"},
+		[]string{"starts-with", "$key", "uploads/user-1/"},
+		[]interface{}{"content-length-range", 1, 10 << 20},
+		[]string{"starts-with", "$Content-Type", "image/"},
+		map[string]string{"acl": "public-read"},
+		map[string]string{"x-amz-meta-owner": "user-1"},
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": p.Fields["x-amz-credential"]},
+		map[string]string{"x-amz-date": p.Fields["x-amz-date"]},
+	} {
+		if !hasCondition(doc, want) {
+			t.Errorf("policy conditions %v lack %v", doc.Conditions, want)
+		}
+	}
+	// каждое поле формы, кроме key и самих policy и подписи, закреплено условием
+	for name, value := range p.Fields {
+		if name == "key" || name == "policy" || name == "x-amz-signature" {
+			continue
+		}
+		if !hasCondition(doc, map[string]string{name: value}) {
+			t.Errorf("field %s=%q is not pinned by the policy", name, value)
+		}
+	}
+
+	t.Run("exact content type without a size limit", func(t *testing.T) {
+		p, err := s.PresignPost("docs/", PostConditions{ContentType: "application/pdf", ContentTypePrefix: "image/"}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := decodePostPolicy(t, p, "secret")
+		if p.Fields["Content-Type"] != "application/pdf" || !hasCondition(doc, map[string]string{"Content-Type": "application/pdf"}) {
+			t.Errorf("Content-Type field %q, conditions %v; want it pinned to application/pdf", p.Fields["Content-Type"], doc.Conditions)
+		}
+		for _, c := range doc.Conditions {
+			if list, ok := c.([]interface{}); ok && (list[0] == "content-length-range" || list[1] == "$Content-Type") {
+				t.Errorf("unexpected condition %v", c)
+			}
+		}
+	})
+
+	t.Run("session token", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		s.client.Config.Credentials = credentials.NewStaticCredentials("id", "secret", "token")
+		p, err := s.PresignPost("a/", PostConditions{}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := decodePostPolicy(t, p, "secret")
+		if p.Fields["x-amz-security-token"] != "token" || !hasCondition(doc, map[string]string{"x-amz-security-token": "token"}) {
+			t.Errorf("x-amz-security-token = %q, conditions %v; want the session token pinned", p.Fields["x-amz-security-token"], doc.Conditions)
+		}
+	})
+}
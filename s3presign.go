@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// PresignGetOptions - параметры подписанной ссылки на скачивание
+// ResponseContentDisposition - Content-Disposition ответа вместо сохраненного у объекта,
+// например `attachment; filename="report.pdf"`, чтобы задать имя файла для этой ссылки
+// ResponseContentType - Content-Type ответа вместо сохраненного у объекта
+// пустые значения не передаются, S3 отдает заголовки объекта
+type PresignGetOptions struct {
+	ResponseContentDisposition string
+	ResponseContentType        string
+}
+
+// PresignGetURL - формирует подписанную ссылку на скачивание объекта
+// path - путь к файлу
+// expires - время жизни ссылки, не больше 7 дней
+// opts - заголовки ответа; передаются параметрами response-* ссылки и входят в подпись,
+// поэтому у одного объекта могут быть ссылки с разными именами файла
+func (s *S3) PresignGetURL(path string, expires time.Duration, opts PresignGetOptions) (string, error) {
+	return s.PresignGetURLWithContext(context.Background(), path, expires, opts)
+}
+
+// PresignGetURLWithContext - формирует подписанную ссылку на скачивание объекта
+// path - путь к файлу
+// expires - время жизни ссылки, не больше 7 дней
+// opts - заголовки ответа
+func (s *S3) PresignGetURLWithContext(ctx context.Context, path string, expires time.Duration, opts PresignGetOptions) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     s.S3Bucket,
+		Key:                        aws.String(path),
+		ResponseContentDisposition: s3OptionalString(opts.ResponseContentDisposition),
+		ResponseContentType:        s3OptionalString(opts.ResponseContentType),
+	})
+	req.SetContext(ctx)
+	return req.Presign(expires)
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestS3PresignGetURL(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PresignGetOptions
+		// want - параметры response-* ссылки, "" - параметра нет
+		disposition, contentType string
+	}{
+		{"object headers", PresignGetOptions{}, "", ""},
+		{"filename", PresignGetOptions{ResponseContentDisposition: `attachment; filename="report Q1.pdf"`},
+			`attachment; filename="report Q1.pdf"`, ""},
+		{"filename and type", PresignGetOptions{ResponseContentDisposition: "inline", ResponseContentType: "application/pdf"},
+			"inline", "application/pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newFakeS3(t, S3Config{})
+			raw, err := s.PresignGetURL("docs/report.pdf", 15*time.Minute, tt.opts)
+			if err != nil {
+				t.Fatalf("PresignGetURL: %v", err)
+			}
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("parse %s: %v", raw, err)
+			}
+			if !strings.HasSuffix(u.Path, "/docs/report.pdf") {
+				t.Errorf("URL path = %s, want the object key", u.Path)
+			}
+
+			q := u.Query()
+			for param, want := range map[string]string{
+				"response-content-disposition": tt.disposition,
+				"response-content-type":        tt.contentType,
+			} {
+				if got, ok := q[param]; want == "" && ok {
+					t.Errorf("%s = %q, want it omitted", param, got)
+				} else if want != "" && q.Get(param) != want {
+					t.Errorf("%s = %q, want %q", param, q.Get(param), want)
+				}
+			}
+			// параметры входят в подписанную ссылку
+			if q.Get("X-Amz-Signature") == "" || q.Get("X-Amz-Expires") != "900" {
+				t.Errorf("signature %q, expires %q; want a signed URL valid for 900s", q.Get("X-Amz-Signature"), q.Get("X-Amz-Expires"))
+			}
+			// подпись формируется без запросов к S3
+			if n := len(f.requestsTo("", "")); n != 0 {
+				t.Errorf("%d requests sent, want none", n)
+			}
+		})
+	}
+
+	t.Run("links differ per filename", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		a, errA := s.PresignGetURL("a.pdf", time.Minute, PresignGetOptions{ResponseContentDisposition: `attachment; filename="a.pdf"`})
+		b, errB := s.PresignGetURL("a.pdf", time.Minute, PresignGetOptions{ResponseContentDisposition: `attachment; filename="b.pdf"`})
+		if errA != nil || errB != nil || a == b {
+			t.Errorf("links = %s, %s (%v, %v); want two different links", a, b, errA, errB)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3BucketRegionHeader - заголовок ответа S3 с регионом бакета
+const s3BucketRegionHeader = "X-Amz-Bucket-Region"
+
+// followBucketRegion - обработчик Retry: если S3 ответил, что бакет в другом регионе
+// (301 PermanentRedirect или 400 AuthorizationHeaderMalformed), запоминает регион
+// из x-amz-bucket-region и повторяет запрос в нем
+func (s *S3) followBucketRegion(r *request.Request) {
+	if r.Error == nil || r.HTTPResponse == nil {
+		return
+	}
+	switch r.HTTPResponse.StatusCode {
+	case http.StatusMovedPermanently, http.StatusBadRequest:
+	default:
+		return
+	}
+
+	region := r.HTTPResponse.Header.Get(s3BucketRegionHeader)
+	if region == "" || region == requestRegion(r) {
+		return
+	}
+
+	if err := useRegion(r, region); err != nil {
+		return
+	}
+	s.bucketRegion.Store(region)
+	r.Retryable = aws.Bool(true)
+}
+
+// applyBucketRegion - обработчик Build: направляет запрос в регион бакета,
+// определенный по одному из предыдущих ответов
+func (s *S3) applyBucketRegion(r *request.Request) {
+	region, _ := s.bucketRegion.Load().(string)
+	if region == "" || region == requestRegion(r) {
+		return
+	}
+	if err := useRegion(r, region); err != nil {
+		r.Error = err
+	}
+}
+
+// requestRegion - регион, в котором подписывается запрос
+func requestRegion(r *request.Request) string {
+	if r.ClientInfo.SigningRegion != "" {
+		return r.ClientInfo.SigningRegion
+	}
+	return aws.StringValue(r.Config.Region)
+}
+
+// useRegion - переводит запрос в другой регион: меняет регион подписи и,
+// если эндпоинт не задан явно и не используется Transfer Acceleration,
+// хост эндпоинта на эндпоинт S3 этого региона
+func useRegion(r *request.Request, region string) error {
+	if aws.StringValue(r.Config.Endpoint) == "" && !aws.BoolValue(r.Config.S3UseAccelerate) {
+		resolver := r.Config.EndpointResolver
+		if resolver == nil {
+			resolver = endpoints.DefaultResolver()
+		}
+		endpoint, err := resolver.EndpointFor(s3.EndpointsID, region, func(o *endpoints.Options) {
+			o.UseDualStackEndpoint = r.Config.UseDualStackEndpoint
+			o.UseFIPSEndpoint = r.Config.UseFIPSEndpoint
+			o.S3UsEast1RegionalEndpoint = r.Config.S3UsEast1RegionalEndpoint
+		})
+		if err != nil {
+			return fmt.Errorf("s3: resolve endpoint for region %q: %w", region, err)
+		}
+
+		from, err := url.Parse(r.ClientInfo.Endpoint)
+		if err != nil {
+			return err
+		}
+		to, err := url.Parse(endpoint.URL)
+		if err != nil {
+			return err
+		}
+
+		// в virtual-hosted адресе перед хостом эндпоинта стоит имя бакета
+		host := r.HTTPRequest.URL.Host
+		if !strings.HasSuffix(host, from.Host) {
+			return fmt.Errorf("s3: unexpected host %q for endpoint %q", host, r.ClientInfo.Endpoint)
+		}
+		r.HTTPRequest.URL.Host = strings.TrimSuffix(host, from.Host) + to.Host
+		r.HTTPRequest.Host = ""
+		r.ClientInfo.Endpoint = endpoint.URL
+	}
+
+	r.ClientInfo.SigningRegion = region
+	r.Config.Region = aws.String(region)
+	return nil
+}
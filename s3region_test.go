@@ -0,0 +1,94 @@
+package store
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// regionRedirect - перед fakeS3: бакет живет в регионе region, запросы к эндпоинтам
+// других регионов получают 301 PermanentRedirect с x-amz-bucket-region
+type regionRedirect struct {
+	*fakeS3
+	region string
+
+	mu    sync.Mutex
+	hosts []string
+	auths []string
+}
+
+func (rr *regionRedirect) RoundTrip(r *http.Request) (*http.Response, error) {
+	rr.mu.Lock()
+	rr.hosts = append(rr.hosts, r.URL.Host)
+	rr.auths = append(rr.auths, r.Header.Get("Authorization"))
+	rr.mu.Unlock()
+
+	if !strings.Contains(r.URL.Host, rr.region) {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		resp := fakeError(r, http.StatusMovedPermanently, "PermanentRedirect")
+		resp.Header.Set(s3BucketRegionHeader, rr.region)
+		return resp, nil
+	}
+	return rr.fakeS3.RoundTrip(r)
+}
+
+// requests - хосты и заголовки Authorization запросов
+func (rr *regionRedirect) requests() ([]string, []string) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	return append([]string(nil), rr.hosts...), append([]string(nil), rr.auths...)
+}
+
+func TestS3BucketRegionRedirect(t *testing.T) {
+	newStore := func(t *testing.T, retries int) (*S3, *regionRedirect) {
+		f := &fakeS3{objects: map[string]*fakeObject{}, uploads: map[string]*fakeUpload{}}
+		rr := &regionRedirect{fakeS3: f, region: "eu-west-1"}
+		f.put("a.txt", []byte("data"), nil)
+		return newTestS3(t, S3Config{MaxRetries: aws.Int(retries)}, rr), rr
+	}
+
+	t.Run("first request follows the redirect", func(t *testing.T) {
+		s, rr := newStore(t, 1)
+
+		got, err := s.GetFile("a.txt")
+		if err != nil || string(got) != "data" {
+			t.Fatalf("GetFile = %q, %v; want data", got, err)
+		}
+		hosts, auths := rr.requests()
+		if len(hosts) != 2 || strings.Contains(hosts[0], "eu-west-1") || !strings.Contains(hosts[1], "eu-west-1") {
+			t.Fatalf("request hosts = %v, want the configured region, then eu-west-1", hosts)
+		}
+		if !strings.Contains(auths[1], "/eu-west-1/s3/aws4_request") {
+			t.Errorf("retry Authorization = %q, want it signed for eu-west-1", auths[1])
+		}
+
+		// регион запоминается: следующие запросы сразу идут в него
+		if err := s.CreateFile("b.txt", []byte("b"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		hosts, auths = rr.requests()
+		if len(hosts) != 3 || !strings.Contains(hosts[2], "eu-west-1") || !strings.Contains(auths[2], "/eu-west-1/") {
+			t.Errorf("later request went to %v, want eu-west-1 without a redirect", hosts[2:])
+		}
+	})
+
+	t.Run("without retries the region applies from the next request", func(t *testing.T) {
+		s, rr := newStore(t, 0)
+
+		if _, err := s.GetFile("a.txt"); err == nil {
+			t.Fatal("GetFile without retries succeeded through a redirect")
+		}
+		got, err := s.GetFile("a.txt")
+		if err != nil || string(got) != "data" {
+			t.Fatalf("second GetFile = %q, %v; want data", got, err)
+		}
+		if hosts, _ := rr.requests(); len(hosts) != 2 || !strings.Contains(hosts[1], "eu-west-1") {
+			t.Errorf("request hosts = %v, want the second one in eu-west-1", hosts)
+		}
+	})
+}
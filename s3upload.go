@@ -0,0 +1,199 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// UploadSession - возобновляемая multipart загрузка в S3
+// Состояние сессии (ключ, UploadId и загруженные части) сериализуется через Marshal
+// и восстанавливается через ResumeUpload, в том числе после перезапуска процесса.
+type UploadSession struct {
+	Key      string         `json:"key"`
+	UploadId string         `json:"upload_id"`
+	Parts    []UploadedPart `json:"parts"`
+
+	s  *S3
+	mu sync.Mutex
+}
+
+// UploadedPart - загруженная часть multipart загрузки
+type UploadedPart struct {
+	Number int64  `json:"number"`
+	ETag   string `json:"etag"`
+}
+
+// StartUpload - начинает возобновляемую multipart загрузку
+// path - путь к файлу
+func (s *S3) StartUpload(path string) (*UploadSession, error) {
+	return s.StartUploadWithContext(context.Background(), path)
+}
+
+// StartUploadWithContext - начинает возобновляемую multipart загрузку
+// path - путь к файлу
+func (s *S3) StartUploadWithContext(ctx context.Context, path string) (*UploadSession, error) {
+	resp, err := s.client.CreateMultipartUploadWithContext(
+		ctx,
+		&s3.CreateMultipartUploadInput{
+			Bucket: s.S3Bucket,
+			Key:    aws.String(path),
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadSession{
+		Key:      path,
+		UploadId: aws.StringValue(resp.UploadId),
+		s:        s,
+	}, nil
+}
+
+// ResumeUpload - восстанавливает сессию загрузки из результата UploadSession.Marshal
+// data - сериализованная сессия
+func (s *S3) ResumeUpload(data []byte) (*UploadSession, error) {
+	u := &UploadSession{s: s}
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Marshal - сериализует состояние сессии для последующего ResumeUpload
+func (u *UploadSession) Marshal() ([]byte, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return json.Marshal(u)
+}
+
+// UploadPart - загружает часть с номером n
+// n - номер части, начиная с 1; повторная загрузка заменяет часть
+// data - содержимое части, все части кроме последней не меньше 5MB
+func (u *UploadSession) UploadPart(n int, data []byte) error {
+	return u.UploadPartWithContext(context.Background(), n, data)
+}
+
+// UploadPartWithContext - загружает часть с номером n
+// n - номер части, начиная с 1; повторная загрузка заменяет часть
+// data - содержимое части, все части кроме последней не меньше 5MB
+func (u *UploadSession) UploadPartWithContext(ctx context.Context, n int, data []byte) error {
+	out, err := u.s.client.UploadPartWithContext(
+		ctx,
+		&s3.UploadPartInput{
+			Bucket:     u.s.S3Bucket,
+			Key:        aws.String(u.Key),
+			UploadId:   aws.String(u.UploadId),
+			PartNumber: aws.Int64(int64(n)),
+			Body:       bytes.NewReader(data),
+		})
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	part := UploadedPart{Number: int64(n), ETag: aws.StringValue(out.ETag)}
+	for i := range u.Parts {
+		if u.Parts[i].Number == part.Number {
+			u.Parts[i] = part
+			return nil
+		}
+	}
+	u.Parts = append(u.Parts, part)
+
+	return nil
+}
+
+// UploadedSize - сколько байт уже загружено в сессии, т.е. с какого смещения продолжать
+// int64 - сумма размеров частей, которые есть в S3 (ListParts)
+func (u *UploadSession) UploadedSize() (int64, error) {
+	return u.UploadedSizeWithContext(context.Background())
+}
+
+// UploadedSizeWithContext - сколько байт уже загружено в сессии, т.е. с какого смещения продолжать
+// int64 - сумма размеров частей, которые есть в S3 (ListParts)
+// Размеры берутся у S3, а не из Parts сессии, поэтому учитываются и части, загруженные
+// после последнего Marshal
+func (u *UploadSession) UploadedSizeWithContext(ctx context.Context) (int64, error) {
+	var size int64
+	err := u.s.client.ListPartsPagesWithContext(
+		ctx,
+		&s3.ListPartsInput{
+			Bucket:   u.s.S3Bucket,
+			Key:      aws.String(u.Key),
+			UploadId: aws.String(u.UploadId),
+		},
+		func(page *s3.ListPartsOutput, lastPage bool) bool {
+			for _, p := range page.Parts {
+				size += aws.Int64Value(p.Size)
+			}
+			return true
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// Complete - завершает загрузку из загруженных частей
+func (u *UploadSession) Complete() error {
+	return u.CompleteWithContext(context.Background())
+}
+
+// CompleteWithContext - завершает загрузку из загруженных частей
+func (u *UploadSession) CompleteWithContext(ctx context.Context) error {
+	u.mu.Lock()
+	parts := make([]*s3.CompletedPart, 0, len(u.Parts))
+	for _, p := range u.Parts {
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       aws.String(p.ETag),
+			PartNumber: aws.Int64(p.Number),
+		})
+	}
+	u.mu.Unlock()
+
+	// S3 требует части в порядке возрастания номеров
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+
+	_, err := u.s.client.CompleteMultipartUploadWithContext(
+		ctx,
+		&s3.CompleteMultipartUploadInput{
+			Bucket:   u.s.S3Bucket,
+			Key:      aws.String(u.Key),
+			UploadId: aws.String(u.UploadId),
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: parts,
+			},
+		})
+
+	return err
+}
+
+// Abort - прерывает загрузку и удаляет загруженные части
+func (u *UploadSession) Abort() error {
+	return u.AbortWithContext(context.Background())
+}
+
+// AbortWithContext - прерывает загрузку и удаляет загруженные части
+func (u *UploadSession) AbortWithContext(ctx context.Context) error {
+	_, err := u.s.client.AbortMultipartUploadWithContext(
+		ctx,
+		&s3.AbortMultipartUploadInput{
+			Bucket:   u.s.S3Bucket,
+			Key:      aws.String(u.Key),
+			UploadId: aws.String(u.UploadId),
+		})
+
+	return err
+}
@@ -0,0 +1,349 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestS3UploadSessionResume(t *testing.T) {
+	part1 := bytes.Repeat([]byte("a"), 5<<20)
+	part2 := bytes.Repeat([]byte("b"), 5<<20)
+	part3 := []byte("tail")
+
+	f := &fakeS3{objects: map[string]*fakeObject{}, uploads: map[string]*fakeUpload{}}
+	first := newTestS3(t, S3Config{}, f)
+
+	session, err := first.StartUpload("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.UploadPart(1, part1); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := session.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// часть 2 загружена после сохранения состояния: процесс упал до следующего Marshal
+	if err := session.UploadPart(2, part2); err != nil {
+		t.Fatal(err)
+	}
+
+	// новый экземпляр хранилища, как после перезапуска процесса
+	second := newTestS3(t, S3Config{}, f)
+	resumed, err := second.ResumeUpload(saved)
+	if err != nil {
+		t.Fatalf("ResumeUpload: %v", err)
+	}
+	if resumed.Key != "big.bin" || resumed.UploadId != session.UploadId || len(resumed.Parts) != 1 {
+		t.Fatalf("resumed session = %s %s with %d parts, want big.bin %s with 1 part",
+			resumed.Key, resumed.UploadId, len(resumed.Parts), session.UploadId)
+	}
+
+	// S3 знает и о части, не попавшей в сохраненное состояние
+	size, err := resumed.UploadedSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(part1) + len(part2)); size != want {
+		t.Errorf("UploadedSize = %d, want %d", size, want)
+	}
+
+	// часть 2 загружается заново, части передаются не по порядку
+	if err := resumed.UploadPart(3, part3); err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.UploadPart(2, part2); err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.Complete(); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	got, err := second.GetFile("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(append(append([]byte(nil), part1...), part2...), part3...); !bytes.Equal(got, want) {
+		t.Errorf("uploaded %d bytes, want %d in part order", len(got), len(want))
+	}
+	if n := len(f.requestsTo(http.MethodPost, "uploads")); n != 1 {
+		t.Errorf("%d multipart uploads started, want 1 resumed upload", n)
+	}
+}
+
+func TestS3UploadSessionAbort(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	session, err := s.StartUpload("big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.UploadPart(1, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := session.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := s.ResumeUpload(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.Abort(); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+	if err := resumed.Complete(); err == nil {
+		t.Error("Complete after Abort succeeded")
+	}
+	if f.object("big.bin") != nil {
+		t.Error("aborted upload created the object")
+	}
+}
+
+// addUploads - незавершенные загрузки бакета "bucket": id -> ключ и время начала
+func addUploads(f *fakeS3, uploads map[string]fakeUpload) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for id, up := range uploads {
+		up.bucket, up.parts = "bucket", map[int][]byte{}
+		f.uploads[id] = &up
+	}
+}
+
+func TestS3IncompleteUploads(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	seed := map[string]fakeUpload{
+		"u1": {key: "logs/old.log", initiated: now.Add(-72 * time.Hour)},
+		"u2": {key: "logs/day.log", initiated: now.Add(-25 * time.Hour)},
+		"u3": {key: "logs/new.log", initiated: now.Add(-time.Hour)},
+		"u4": {key: "media/old.mp4", initiated: now.Add(-48 * time.Hour)},
+	}
+
+	t.Run("list by prefix", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		addUploads(f, seed)
+
+		got, err := s.ListIncompleteUploads("logs/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []IncompleteUpload{
+			{Key: "logs/old.log", UploadId: "u1", Initiated: now.Add(-72 * time.Hour)},
+			{Key: "logs/day.log", UploadId: "u2", Initiated: now.Add(-25 * time.Hour)},
+			{Key: "logs/new.log", UploadId: "u3", Initiated: now.Add(-time.Hour)},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ListIncompleteUploads = %+v, want %+v", got, want)
+		}
+
+		if all, err := s.ListIncompleteUploads(""); err != nil || len(all) != len(seed) {
+			t.Errorf("ListIncompleteUploads(\"\") = %d uploads, %v; want %d", len(all), err, len(seed))
+		}
+	})
+
+	t.Run("abort older than", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		addUploads(f, seed)
+
+		n, err := s.AbortIncompleteUploads(now.Add(-24 * time.Hour))
+		if err != nil || n != 3 {
+			t.Fatalf("AbortIncompleteUploads = %d, %v; want 3", n, err)
+		}
+		left, err := s.ListIncompleteUploads("")
+		if err != nil || len(left) != 1 || left[0].UploadId != "u3" {
+			t.Errorf("uploads left = %+v, %v; want only the recent u3", left, err)
+		}
+
+		// повторный запуск прерывать нечего
+		if n, err := s.AbortIncompleteUploads(now.Add(-24 * time.Hour)); err != nil || n != 0 {
+			t.Errorf("second AbortIncompleteUploads = %d, %v; want 0", n, err)
+		}
+	})
+
+	t.Run("abort failure reports the count so far", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		addUploads(f, seed)
+		f.intercept = func(r *http.Request) *http.Response {
+			if r.Method == http.MethodDelete && r.URL.Query().Get("uploadId") == "u2" {
+				return fakeError(r, http.StatusForbidden, "AccessDenied")
+			}
+			return nil
+		}
+
+		n, err := s.AbortIncompleteUploads(now)
+		if err == nil || n != 1 {
+			t.Errorf("AbortIncompleteUploads = %d, %v; want 1 and the AccessDenied error", n, err)
+		}
+	})
+}
+
+func TestS3PartRetry(t *testing.T) {
+	data := append(bytes.Repeat([]byte("a"), S3PartSize), bytes.Repeat([]byte("b"), S3PartSize/2)...)
+
+	tests := []struct {
+		name    string
+		retries int
+		// fails - сколько раз подряд отказывает вторая часть, status и code - ответ
+		fails  int
+		status int
+		code   string
+		// wantErr - загрузка прервана; wantSends - сколько раз отправлялась вторая часть
+		wantErr   bool
+		wantSends int
+	}{
+		{"transient failure retried", 2, 1, http.StatusInternalServerError, "InternalError", false, 2},
+		{"slow down retried twice", 2, 2, http.StatusServiceUnavailable, "SlowDown", false, 3},
+		{"retries exhausted", 1, 5, http.StatusInternalServerError, "InternalError", true, 2},
+		{"no retries configured", 0, 1, http.StatusInternalServerError, "InternalError", true, 1},
+		{"access denied not retried", 3, 1, http.StatusForbidden, "AccessDenied", true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newFakeS3(t, S3Config{PartRetries: tt.retries, PartRetryBackoff: time.Millisecond})
+			var sent [][]byte
+			f.intercept = func(r *http.Request) *http.Response {
+				q := r.URL.Query()
+				if r.Method != http.MethodPut || q.Get("partNumber") != "2" {
+					return nil
+				}
+				body, _ := io.ReadAll(r.Body)
+				sent = append(sent, body)
+				if len(sent) <= tt.fails {
+					return fakeError(r, tt.status, tt.code)
+				}
+				return nil
+			}
+
+			err := s.StreamToFile(bytes.NewReader(data), "big.bin", nil)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("StreamToFile = %v, want error %v", err, tt.wantErr)
+			}
+			if len(sent) != tt.wantSends {
+				t.Errorf("part 2 sent %d times, want %d", len(sent), tt.wantSends)
+			}
+			// повтор отправляет ту же часть целиком
+			for i, body := range sent {
+				if !bytes.Equal(body, data[S3PartSize:]) {
+					t.Errorf("attempt %d sent %d bytes, want the buffered part", i+1, len(body))
+				}
+			}
+
+			if tt.wantErr {
+				if f.object("big.bin") != nil {
+					t.Error("failed upload created the object")
+				}
+				if n := len(f.requestsTo(http.MethodDelete, "uploadId")); n != 1 {
+					t.Errorf("%d aborts, want the upload aborted once", n)
+				}
+				return
+			}
+			if obj := f.object("big.bin"); obj == nil || !bytes.Equal(obj.data, data) {
+				t.Error("object content differs from the stream")
+			}
+			// первая часть не перезагружалась
+			if n := len(f.requestsTo(http.MethodPut, "partNumber")); n != 1+len(sent) {
+				t.Errorf("%d part uploads, want part 1 once and part 2 %d times", n, len(sent))
+			}
+		})
+	}
+
+	t.Run("cancel during backoff", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{PartRetries: 3, PartRetryBackoff: time.Hour})
+		ctx, cancel := context.WithCancel(context.Background())
+		f.intercept = func(r *http.Request) *http.Response {
+			if r.Method == http.MethodPut && r.URL.Query().Has("partNumber") {
+				time.AfterFunc(10*time.Millisecond, cancel)
+				return fakeError(r, http.StatusInternalServerError, "InternalError")
+			}
+			return nil
+		}
+
+		start := time.Now()
+		err := s.StreamToFileWithContext(ctx, bytes.NewReader(data), "big.bin", nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("StreamToFile = %v, want context.Canceled", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("StreamToFile returned after %v, want the backoff interrupted", elapsed)
+		}
+		// загрузка прерывается и после отмены контекста
+		if n := len(f.requestsTo(http.MethodDelete, "uploadId")); n != 1 || len(f.uploads) != 0 {
+			t.Errorf("%d aborts, %d uploads left; want the upload aborted", n, len(f.uploads))
+		}
+	})
+}
+
+func TestS3UploadedSize(t *testing.T) {
+	t.Run("parts so far", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		// страница ListParts из двух частей: размер собирается со всех страниц
+		f.maxParts = 2
+		session, err := s.StartUpload("big.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size, err := session.UploadedSize(); err != nil || size != 0 {
+			t.Fatalf("UploadedSize of a new upload = %d, %v; want 0", size, err)
+		}
+
+		parts := map[int]string{1: "aaaa", 2: "bb", 4: "c", 5: "ddd", 3: "eeeee"}
+		want := int64(0)
+		for _, n := range []int{1, 2, 4, 5, 3} {
+			if err := session.UploadPart(n, []byte(parts[n])); err != nil {
+				t.Fatal(err)
+			}
+			want += int64(len(parts[n]))
+			if size, err := session.UploadedSize(); err != nil || size != want {
+				t.Errorf("UploadedSize after part %d = %d, %v; want %d", n, size, err, want)
+			}
+		}
+		if n := len(f.requestsTo(http.MethodGet, "part-number-marker")); n == 0 {
+			t.Error("ListParts never asked for a second page")
+		}
+
+		// повторно загруженная часть заменяет прежнюю, а не добавляется
+		if err := session.UploadPart(2, []byte("b")); err != nil {
+			t.Fatal(err)
+		}
+		if size, err := session.UploadedSize(); err != nil || size != want-1 {
+			t.Errorf("UploadedSize after replacing part 2 = %d, %v; want %d", size, err, want-1)
+		}
+	})
+
+	t.Run("unknown upload", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		session, err := s.ResumeUpload([]byte(`{"key":"big.bin","upload_id":"missing"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size, err := session.UploadedSize(); err == nil || size != 0 {
+			t.Errorf("UploadedSize of an unknown upload = %d, %v; want NoSuchUpload", size, err)
+		}
+	})
+
+	t.Run("completed upload", func(t *testing.T) {
+		s, _ := newFakeS3(t, S3Config{})
+		session, err := s.StartUpload("big.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := session.UploadPart(1, []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		if err := session.Complete(); err != nil {
+			t.Fatal(err)
+		}
+		// после Complete продолжать нечего: загрузки больше нет
+		if _, err := session.UploadedSize(); err == nil {
+			t.Error("UploadedSize after Complete succeeded")
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	pathpkg "path"
+	"strconv"
+	"strings"
+)
+
+// ServeContent - отдает файл хранилища в HTTP ответ
+// w - ответ
+// r - запрос
+// s - хранилище
+// path - путь к файлу
+// Content-Type, ETag и Last-Modified берутся из StatObject, условные запросы и Range
+// обрабатывает http.ServeContent, а диапазоны читаются FileReader без скачивания файла.
+// Если клиент принимает gzip, а тип сжимаемый (текст, JSON, XML, JavaScript, SVG),
+// тело сжимается на лету: Content-Length не передается, Range игнорируется.
+// Изображения, видео, архивы и файлы со своим Content-Encoding отдаются как есть.
+// Ошибка StatObject возвращается до записи ответа, статус пишет вызывающий код
+// (см. ErrorCode)
+func ServeContent(w http.ResponseWriter, r *http.Request, s StoreIFace, path string) error {
+	ctx := r.Context()
+	obj, err := s.StatObjectWithContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	if obj.IsDir {
+		return ErrFileNotFound
+	}
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(pathpkg.Ext(path))
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if obj.CacheControl != "" {
+		w.Header().Set("Cache-Control", obj.CacheControl)
+	}
+
+	content := &storeReadSeeker{ctx: ctx, s: s, path: path, size: obj.Size}
+	defer content.Close()
+
+	etag := obj.ETag
+	if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+		etag = `"` + etag + `"`
+	}
+
+	if obj.ContentEncoding != "" || !compressibleType(contentType) {
+		if obj.ContentEncoding != "" {
+			w.Header().Set("Content-Encoding", obj.ContentEncoding)
+		}
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, pathpkg.Base(path), obj.ModTime, content)
+		return nil
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, pathpkg.Base(path), obj.ModTime, content)
+		return nil
+	}
+
+	// сжатое представление отличается от исходного байтами, поэтому ETag свой
+	if etag != "" {
+		w.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+`-gzip"`)
+	}
+	r = r.Clone(ctx)
+	r.Header.Del("Range")
+	gw := &gzipResponseWriter{ResponseWriter: w}
+	http.ServeContent(gw, r, pathpkg.Base(path), obj.ModTime, content)
+	return gw.Close()
+}
+
+// compressibleType - стоит ли сжимать содержимое этого типа
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/xml",
+		"application/javascript", "application/x-javascript",
+		"application/wasm", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// acceptsGzip - клиент принимает gzip (Accept-Encoding без gzip;q=0)
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(part, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter - сжимает тело ответа 200, остальные ответы (304, 412, ошибки)
+// передаются как есть
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	if code == http.StatusOK {
+		g.Header().Del("Content-Length")
+		g.Header().Set("Content-Encoding", "gzip")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// Close - дописывает конец gzip потока
+func (g *gzipResponseWriter) Close() error {
+	if g.gz != nil {
+		return g.gz.Close()
+	}
+	return nil
+}
+
+// storeReadSeeker - io.ReadSeeker поверх FileReader для http.ServeContent:
+// Seek только запоминает позицию, поток открывается с нее при следующем Read
+type storeReadSeeker struct {
+	ctx  context.Context
+	s    StoreIFace
+	path string
+	size int64
+	pos  int64
+	cur  io.ReadCloser
+}
+
+func (rs *storeReadSeeker) Read(b []byte) (int, error) {
+	if rs.pos >= rs.size {
+		return 0, io.EOF
+	}
+	if rs.cur == nil {
+		r, err := rs.s.FileReaderWithContext(rs.ctx, rs.path, rs.pos, 0)
+		if err == nil && r == nil {
+			err = ErrFileNotFound
+		}
+		if err != nil {
+			return 0, err
+		}
+		rs.cur = r
+	}
+
+	n, err := rs.cur.Read(b)
+	rs.pos += int64(n)
+	return n, err
+}
+
+func (rs *storeReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos := rs.pos
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = rs.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	if pos != rs.pos {
+		rs.Close()
+		rs.pos = pos
+	}
+	return pos, nil
+}
+
+func (rs *storeReadSeeker) Close() error {
+	if rs.cur == nil {
+		return nil
+	}
+	err := rs.cur.Close()
+	rs.cur = nil
+	return err
+}
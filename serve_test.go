@@ -0,0 +1,233 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serve - ответ ServeContent на GET path с заголовками header
+func serve(t *testing.T, s StoreIFace, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/"+path, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	if err := ServeContent(w, r, s, path); err != nil {
+		t.Fatalf("ServeContent: %v", err)
+	}
+	return w
+}
+
+// gunzip - распакованное тело ответа
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("body is not gzip: %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	return data
+}
+
+func TestServeContentGzip(t *testing.T) {
+	page := []byte(strings.Repeat("<p>hello</p>\n", 500))
+	photo := bytes.Repeat([]byte{0xff, 0xd8, 0x00}, 500)
+	compressed := func() []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(page)
+		zw.Close()
+		return buf.Bytes()
+	}()
+
+	newStore := func(t *testing.T) StoreIFace {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("page.html", page, http.Header{"Content-Type": {"text/html; charset=utf-8"}})
+		f.put("photo.jpg", photo, http.Header{"Content-Type": {"image/jpeg"}})
+		f.put("page.html.gz", compressed, http.Header{"Content-Type": {"text/html"}, "Content-Encoding": {"gzip"}})
+		return s
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		// wantGzip - тело сжато ServeContent; wantVary - ответ зависит от Accept-Encoding
+		wantGzip bool
+		wantVary bool
+	}{
+		{"text accepted", "page.html", "gzip, deflate, br", true, true},
+		{"any coding accepted", "page.html", "*", true, true},
+		{"text without Accept-Encoding", "page.html", "", false, true},
+		{"gzip refused with q=0", "page.html", "gzip;q=0, deflate", false, true},
+		{"only other codings accepted", "page.html", "br", false, true},
+		{"image is not compressed", "photo.jpg", "gzip", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			header := http.Header{}
+			if tt.acceptEncoding != "" {
+				header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := serve(t, s, tt.path, header)
+			want, _ := s.GetFile(tt.path)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q, want Accept-Encoding: %v", w.Header().Get("Vary"), tt.wantVary)
+			}
+
+			if !tt.wantGzip {
+				if enc := w.Header().Get("Content-Encoding"); enc != "" {
+					t.Errorf("Content-Encoding = %q, want none", enc)
+				}
+				if !bytes.Equal(w.Body.Bytes(), want) {
+					t.Errorf("body differs from the stored file")
+				}
+				if w.Header().Get("Content-Length") == "" {
+					t.Error("no Content-Length for an uncompressed body")
+				}
+				return
+			}
+
+			if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+				t.Fatalf("Content-Encoding = %q, want gzip", enc)
+			}
+			if cl := w.Header().Get("Content-Length"); cl != "" {
+				t.Errorf("Content-Length = %s, want none for a body compressed on the fly", cl)
+			}
+			if w.Body.Len() >= len(want) {
+				t.Errorf("compressed body is %d bytes, want less than %d", w.Body.Len(), len(want))
+			}
+			if got := gunzip(t, w.Body.Bytes()); !bytes.Equal(got, want) {
+				t.Errorf("decompressed body differs from the stored file")
+			}
+			if etag := w.Header().Get("ETag"); !strings.HasSuffix(etag, `-gzip"`) {
+				t.Errorf("ETag = %s, want a separate one for the gzip representation", etag)
+			}
+		})
+	}
+
+	t.Run("stored encoding is passed through", func(t *testing.T) {
+		w := serve(t, newStore(t), "page.html.gz", http.Header{"Accept-Encoding": {"gzip"}})
+		if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want the stored gzip", enc)
+		}
+		// тело не сжимается второй раз
+		if got := gunzip(t, w.Body.Bytes()); !bytes.Equal(got, page) {
+			t.Errorf("body is not the stored gzip of the page")
+		}
+	})
+
+	t.Run("range is ignored when compressing", func(t *testing.T) {
+		w := serve(t, newStore(t), "page.html", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}})
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("status = %d, Content-Encoding = %q; want the whole page compressed", w.Code, w.Header().Get("Content-Encoding"))
+		}
+		if got := gunzip(t, w.Body.Bytes()); !bytes.Equal(got, page) {
+			t.Errorf("decompressed body differs from the page")
+		}
+	})
+
+	t.Run("range without gzip", func(t *testing.T) {
+		w := serve(t, newStore(t), "page.html", http.Header{"Range": {"bytes=0-9"}})
+		if w.Code != http.StatusPartialContent || w.Body.String() != string(page[:10]) {
+			t.Errorf("status = %d, body = %q; want 206 with the first 10 bytes", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("not modified is not compressed", func(t *testing.T) {
+		s := newStore(t)
+		etag := serve(t, s, "page.html", http.Header{"Accept-Encoding": {"gzip"}}).Header().Get("ETag")
+
+		w := serve(t, s, "page.html", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {etag}})
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("status = %d, want 304 for the gzip ETag", w.Code)
+		}
+		if enc := w.Header().Get("Content-Encoding"); enc != "" || w.Body.Len() != 0 {
+			t.Errorf("Content-Encoding = %q with %d body bytes, want an empty 304", enc, w.Body.Len())
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/missing.html", nil)
+		w := httptest.NewRecorder()
+		if err := ServeContent(w, r, newStore(t), "missing.html"); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("ServeContent = %v, want ErrFileNotFound", err)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("response written for a missing file: %q", w.Body.String())
+		}
+	})
+}
+
+func TestCacheControlRoundTrip(t *testing.T) {
+	const immutable = "public, max-age=31536000, immutable"
+
+	for _, b := range testBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir := b.store(t)
+			files := []struct {
+				name, cacheControl string
+			}{
+				{"thumb.png", immutable},
+				{"index.html", "no-cache"},
+				{"plain.txt", ""},
+			}
+			for _, f := range files {
+				err := s.CreateFileWithOptions(joinKey(dir, f.name), []byte(f.name), PutOptions{
+					CacheControl: f.cacheControl,
+					Meta:         map[string]string{"Owner": "alice"},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, f := range files {
+				path := joinKey(dir, f.name)
+				obj, err := s.StatObject(path)
+				if err != nil || obj.CacheControl != f.cacheControl {
+					t.Errorf("StatObject(%s).CacheControl = %q, %v; want %q", f.name, obj.CacheControl, err, f.cacheControl)
+				}
+				// служебный ключ мета-файла не виден среди пользовательских метаданных
+				if want := map[string]string{"Owner": "alice"}; !reflect.DeepEqual(obj.Meta, want) {
+					t.Errorf("StatObject(%s).Meta = %v, want %v", f.name, obj.Meta, want)
+				}
+				if got := serve(t, s, path, nil).Header().Get("Cache-Control"); got != f.cacheControl {
+					t.Errorf("ServeContent(%s) Cache-Control = %q, want %q", f.name, got, f.cacheControl)
+				}
+			}
+
+			// копия сохраняет Cache-Control, перезапись заменяет его
+			copied := joinKey(dir, "copy.png")
+			if err := s.CopyFile(joinKey(dir, "thumb.png"), copied, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if obj, err := s.StatObject(copied); err != nil || obj.CacheControl != immutable {
+				t.Errorf("copy CacheControl = %q, %v; want %q", obj.CacheControl, err, immutable)
+			}
+			if err := s.CreateFileWithOptions(joinKey(dir, "thumb.png"), []byte("v2"), PutOptions{CacheControl: "no-store"}); err != nil {
+				t.Fatal(err)
+			}
+			if obj, err := s.StatObject(joinKey(dir, "thumb.png")); err != nil || obj.CacheControl != "no-store" {
+				t.Errorf("CacheControl after overwrite = %q, %v; want no-store", obj.CacheControl, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package store
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// sharding - раскладка файлов по поддиректориям из хеша имени:
+// перед именем файла вставляются depth директорий из "_" и двух символов хеша,
+// a/b.txt -> a/_3f/_a1/b.txt. Имя файла не меняется, поэтому при листинге
+// логический путь восстанавливается отбрасыванием директорий шарда.
+// Префикс "_" отличает директории шарда от логических директорий вроде месяцев "01"-"12"
+type sharding struct {
+	depth int
+	hash  func(name string) string
+}
+
+// shardPrefix - префикс имени директории шарда
+const shardPrefix = "_"
+
+// sha1Hex - хеш имени файла по умолчанию
+func sha1Hex(name string) string {
+	sum := sha1.Sum([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// path - путь файла на диске для логического пути key
+func (sh sharding) path(key string) (string, error) {
+	dir, name := path.Split(key)
+	if err := checkShardFree(dir); err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	if name == "" {
+		return key, nil
+	}
+
+	sum := sh.hash(name)
+	if len(sum) < 2*sh.depth {
+		return "", fmt.Errorf("%w: shard hash %q of %q is too short", ErrInvalidKey, sum, name)
+	}
+
+	var b strings.Builder
+	b.WriteString(dir)
+	for i := 0; i < sh.depth; i++ {
+		level := shardPrefix + sum[2*i:2*i+2]
+		if !isShardDir(level) {
+			return "", fmt.Errorf("%w: shard hash %q of %q is not lowercase hex", ErrInvalidKey, sum, name)
+		}
+		b.WriteString(level)
+		b.WriteByte('/')
+	}
+	b.WriteString(name)
+	return b.String(), nil
+}
+
+// checkShardFree - ни одна директория пути не совпадает по виду с директорией шарда,
+// иначе листинг принял бы ее за уровень шарда и потерял бы ее файлы
+func checkShardFree(dir string) error {
+	for _, part := range strings.Split(dir, "/") {
+		if isShardDir(part) {
+			return fmt.Errorf("%w: directory %q is reserved for shard levels", ErrInvalidKey, part)
+		}
+	}
+	return nil
+}
+
+// isShardDir - является ли директория уровнем шарда ("_" и два символа [0-9a-f])
+func isShardDir(name string) bool {
+	hexPart, ok := strings.CutPrefix(name, shardPrefix)
+	if !ok || len(hexPart) != 2 {
+		return false
+	}
+	for _, c := range hexPart {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// shardedStore - хранилище с шардированием файлов (см. LocalConfig.ShardDepth)
+// пути файлов переводятся в пути на диске через keyNormalized, а методы,
+// работающие с директориями, переопределены: листинг обходит директории шарда
+// и отдает файлы под логическими путями
+type shardedStore struct {
+	*keyNormalized
+	raw StoreIFace
+	sharding
+}
+
+func newShardedStore(raw StoreIFace, sh sharding) *shardedStore {
+	return &shardedStore{
+		keyNormalized: &keyNormalized{StoreIFace: raw, normalize: sh.path},
+		raw:           raw,
+		sharding:      sh,
+	}
+}
+
+func (s *shardedStore) MkdirAll(path string) error {
+	return s.MkdirAllWithContext(context.Background(), path)
+}
+
+func (s *shardedStore) MkdirAllWithContext(ctx context.Context, path string) error {
+	if err := checkShardFree(path); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return s.raw.MkdirAllWithContext(ctx, path)
+}
+
+func (s *shardedStore) ClearDir(path string) error {
+	return s.raw.ClearDir(path)
+}
+
+func (s *shardedStore) ClearDirWithContext(ctx context.Context, path string) error {
+	return s.raw.ClearDirWithContext(ctx, path)
+}
+
+func (s *shardedStore) ListDirChan(path string) <-chan DirEntry {
+	return s.ListDirChanWithContext(context.Background(), path)
+}
+
+// ListDirChanWithContext - файлы из директорий шарда отдаются как файлы самой директории,
+// поддиректории с именами из "_" и двух символов [0-9a-f] считаются шардом и не отдаются
+func (s *shardedStore) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		// останавливает листинги шарда, если читатель перестал читать канал
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		s.listShard(ctx, ch, path, 0)
+	}()
+
+	return ch
+}
+
+// listShard - отдает файлы директории уровня шарда level; false - листинг прерван
+func (s *shardedStore) listShard(ctx context.Context, ch chan<- DirEntry, dir string, level int) bool {
+	for entry := range s.raw.ListDirChanWithContext(ctx, dir) {
+		if entry.Err != nil {
+			sendDirEntry(ctx, ch, entry)
+			return false
+		}
+
+		switch {
+		case entry.Info.IsDir() && level < s.depth && isShardDir(entry.Info.Name()):
+			if !s.listShard(ctx, ch, joinKey(dir, entry.Info.Name()), level+1) {
+				return false
+			}
+		case level > 0 && entry.Info.IsDir():
+			// в директориях шарда логических поддиректорий нет
+		case level > 0 && level < s.depth:
+			// файл не на своем уровне шарда
+		default:
+			if !sendDirEntry(ctx, ch, entry) {
+				return false
+			}
+		}
+	}
+	return ctx.Err() == nil
+}
+
+func (s *shardedStore) Latest(path string) (os.FileInfo, error) {
+	return s.LatestWithContext(context.Background(), path)
+}
+
+func (s *shardedStore) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var latest os.FileInfo
+	for entry := range s.ListDirChanWithContext(ctx, path) {
+		if entry.Err != nil {
+			return nil, entry.Err
+		}
+		if entry.Info.IsDir() {
+			continue
+		}
+		if latest == nil || entry.Info.ModTime().After(latest.ModTime()) {
+			latest = entry.Info
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, ErrFileNotFound
+	}
+	return latest, nil
+}
+
+func (s *shardedStore) IsEmpty(path string) (bool, error) {
+	return s.IsEmptyWithContext(context.Background(), path)
+}
+
+// IsEmptyWithContext - пустые директории шардов логическую директорию не наполняют
+func (s *shardedStore) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for entry := range s.ListDirChanWithContext(ctx, path) {
+		if entry.Err != nil {
+			return false, entry.Err
+		}
+		return false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *shardedStore) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return s.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+func (s *shardedStore) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	return listModifiedSince(ctx, s, path, since)
+}
+
+func (s *shardedStore) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return s.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
+
+func (s *shardedStore) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	return listDirDepth(ctx, s, path, maxDepth)
+}
+
+func (s *shardedStore) Rotate(path string) (string, error) {
+	return s.RotateWithContext(context.Background(), path)
+}
+
+// RotateWithContext - имя с меткой времени хешируется заново, поэтому перенос выполняется
+// через логические пути, а возвращается логический путь
+func (s *shardedStore) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated := rotatedPath(path, time.Now())
+	if err := rotateTo(ctx, s, path, rotated); err != nil {
+		return "", err
+	}
+	return rotated, nil
+}
+
+func (s *shardedStore) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return s.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+func (s *shardedStore) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	return archiveDir(ctx, s, path, w, format)
+}
+
+func (s *shardedStore) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return s.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+func (s *shardedStore) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, s, r, path, format, extractTarget{
+		mkdir: true,
+		chtimes: func(path string, t time.Time) error {
+			path, err := s.path(path)
+			if err != nil {
+				return err
+			}
+			return os.Chtimes(path, t, t)
+		},
+	})
+}
+
+func (s *shardedStore) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return s.ManifestWithContext(context.Background(), path, algo)
+}
+
+func (s *shardedStore) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return manifest(ctx, s, path, algo)
+}
+
+func (s *shardedStore) ListMeta(path string) (map[string]map[string]string, error) {
+	return s.ListMetaWithContext(context.Background(), path)
+}
+
+func (s *shardedStore) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	return listMeta(ctx, s, path)
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// listNames - имена записей ListDirChan, директории с завершающим "/"
+func listNames(t *testing.T, s StoreIFace, dir string) []string {
+	t.Helper()
+	var names []string
+	for entry := range s.ListDirChan(dir) {
+		if entry.Err != nil {
+			t.Fatalf("ListDirChan(%s): %v", dir, entry.Err)
+		}
+		name := entry.Info.Name()
+		if entry.Info.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestShardedLocal(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		hash  func(string) string
+		// disk - путь файла на диске относительно корня для логического name
+		disk func(name string) string
+	}{
+		{"sha1 depth 2", 2, nil, func(name string) string {
+			sum := sha1Hex(name)
+			return "_" + sum[0:2] + "/_" + sum[2:4] + "/" + name
+		}},
+		{"sha1 depth 1", 1, nil, func(name string) string {
+			return "_" + sha1Hex(name)[0:2] + "/" + name
+		}},
+		{"custom hash", 2, func(string) string { return "ab12cd" }, func(name string) string {
+			return "_ab/_12/" + name
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			raw, err := NewLocal(LocalConfig{SkipValidation: true, ShardDepth: tt.depth, ShardHash: tt.hash})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files := map[string]string{"a.txt": "a", "b.txt": "bb", "sub/c.txt": "ccc"}
+			for name, body := range files {
+				if err := raw.CreateFile(filepath.Join(root, name), []byte(body), nil, nil); err != nil {
+					t.Fatalf("CreateFile(%s): %v", name, err)
+				}
+			}
+
+			// раскладка на диске совпадает с хешем имени
+			for name, body := range files {
+				dir, base := filepath.Split(name)
+				disk := filepath.Join(root, filepath.FromSlash(dir+tt.disk(base)))
+				got, err := os.ReadFile(disk)
+				if err != nil || string(got) != body {
+					t.Errorf("%s on disk at %s = %q, %v; want %q", name, disk, got, err, body)
+				}
+				if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Errorf("%s exists at its logical path, want only the sharded one", name)
+				}
+			}
+
+			// листинг отдает логические имена без директорий шарда
+			if got, want := listNames(t, raw, root), []string{"a.txt", "b.txt", "sub/"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("root listing = %v, want %v", got, want)
+			}
+			if got, want := listNames(t, raw, filepath.Join(root, "sub")), []string{"c.txt"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("sub listing = %v, want %v", got, want)
+			}
+
+			// чтение, Stat и удаление по логическим путям
+			for name, body := range files {
+				path := filepath.Join(root, name)
+				got, err := raw.GetFile(path)
+				if err != nil || string(got) != body {
+					t.Errorf("GetFile(%s) = %q, %v; want %q", name, got, err, body)
+				}
+				obj, err := raw.StatObject(path)
+				if err != nil || obj.Size != int64(len(body)) {
+					t.Errorf("StatObject(%s) = %+v, %v; want size %d", name, obj, err, len(body))
+				}
+			}
+			if err := raw.RemoveFile(filepath.Join(root, "a.txt")); err != nil {
+				t.Fatal(err)
+			}
+			if raw.IsExist(filepath.Join(root, "a.txt")) {
+				t.Errorf("a.txt exists after RemoveFile")
+			}
+			if _, err := os.Stat(filepath.Join(root, tt.disk("a.txt"))); !os.IsNotExist(err) {
+				t.Errorf("sharded a.txt still on disk after RemoveFile")
+			}
+			if got, want := listNames(t, raw, root), []string{"b.txt", "sub/"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("root listing after RemoveFile = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestShardedLocalHexDirs(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewLocal(LocalConfig{SkipValidation: true, ShardDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// логические директории месяцев не путаются с уровнями шарда
+	files := map[string]string{"logs/2024/01/a.log": "a", "logs/2024/b.log": "b", "logs/2024/ab/c.log": "c"}
+	for name, body := range files {
+		if err := s.CreateFile(filepath.Join(root, name), []byte(body), nil, nil); err != nil {
+			t.Fatalf("CreateFile(%s): %v", name, err)
+		}
+	}
+	infos, err := s.ListDirDepth(filepath.Join(root, "logs/2024"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			got = append(got, info.Name())
+		}
+	}
+	sort.Strings(got)
+	if want := []string{"01/a.log", "ab/c.log", "b.log"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDirDepth = %v, want %v", got, want)
+	}
+	if got, want := listNames(t, s, filepath.Join(root, "logs/2024")), []string{"01/", "ab/", "b.log"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listing = %v, want %v", got, want)
+	}
+	if got, err := s.GetFile(filepath.Join(root, "logs/2024/01/a.log")); err != nil || string(got) != "a" {
+		t.Errorf("GetFile(01/a.log) = %q, %v; want a", got, err)
+	}
+
+	// директории вида уровня шарда зарезервированы
+	for _, name := range []string{"_3f/a.log", "logs/_a1/b/c.log"} {
+		if err := s.CreateFile(filepath.Join(root, name), []byte("x"), nil, nil); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("CreateFile(%s) = %v, want ErrInvalidKey", name, err)
+		}
+	}
+	if err := s.MkdirAll(filepath.Join(root, "logs/_0f")); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("MkdirAll(logs/_0f) = %v, want ErrInvalidKey", err)
+	}
+
+	// хеш не из [0-9a-f] дал бы директории, которые листинг не узнает
+	upper, err := NewLocal(LocalConfig{SkipValidation: true, ShardDepth: 1, ShardHash: func(string) string { return "AB" }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := upper.CreateFile(filepath.Join(root, "d.log"), []byte("d"), nil, nil); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("CreateFile with an uppercase hash = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// SidecarFormat - формат мета-файла Local и WebDav
+type SidecarFormat int
+
+const (
+	// SidecarKeyValue - строки key=value. Метаданные, которые так не записать
+	// (перевод строки в ключе или значении, '=' в ключе), пишутся JSON объектом
+	// со строковыми значениями, чтобы сохранить их без искажений
+	SidecarKeyValue SidecarFormat = iota
+	// SidecarJSON - JSON объект. Значение, которое само является JSON объектом
+	// или массивом, хранится вложенным, а при чтении возвращается его компактной
+	// JSON записью (см. SetMetaJSON, GetMetaJSON). Мета-файлы обоих форматов
+	// читаются независимо от настройки
+	SidecarJSON
+)
+
+// encode - содержимое мета-файла в этом формате
+func (f SidecarFormat) encode(meta map[string]string) []byte {
+	if f != SidecarJSON {
+		if keyValueSafe(meta) {
+			return meta2Bytes(meta)
+		}
+		return encodeJsonSidecar(meta, false)
+	}
+	return encodeJsonSidecar(meta, true)
+}
+
+// keyValueSafe - метаданные записываются строками key=value и читаются обратно без изменений
+func keyValueSafe(meta map[string]string) bool {
+	for key, value := range meta {
+		if strings.ContainsAny(key, "=\r\n") || strings.ContainsAny(value, "\r\n") {
+			return false
+		}
+		// такой мета-файл при чтении был бы принят за JSON
+		if strings.HasPrefix(strings.TrimSpace(key), "{") {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeJsonSidecar - мета-файл в виде JSON объекта
+// nest - значения, которые сами являются JSON объектом или массивом, хранятся вложенными,
+// иначе все значения хранятся строками
+func encodeJsonSidecar(meta map[string]string, nest bool) []byte {
+	obj := make(map[string]json.RawMessage, len(meta))
+	for key, value := range meta {
+		if nest && isJsonContainer(value) {
+			obj[key] = json.RawMessage(value)
+			continue
+		}
+		// ошибка невозможна: строка всегда кодируется
+		raw, _ := json.Marshal(value)
+		obj[key] = raw
+	}
+	// ошибка невозможна: все значения - корректный JSON
+	b, _ := json.Marshal(obj)
+	return b
+}
+
+// isJsonContainer - value - корректный JSON объект или массив
+func isJsonContainer(value string) bool {
+	trimmed := bytes.TrimSpace([]byte(value))
+	if len(trimmed) == 0 || trimmed[0] != '{' && trimmed[0] != '[' {
+		return false
+	}
+	return json.Valid(trimmed)
+}
+
+// decodeJsonSidecar - разбирает мета-файл в формате SidecarJSON
+// bool - содержимое является JSON объектом
+func decodeJsonSidecar(b []byte) (map[string]string, bool) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &obj); err != nil {
+		return nil, false
+	}
+
+	meta := make(map[string]string, len(obj))
+	for key, raw := range obj {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			meta[key] = s
+			continue
+		}
+		compact := new(bytes.Buffer)
+		if err := json.Compact(compact, raw); err != nil {
+			return nil, false
+		}
+		meta[key] = compact.String()
+	}
+	return meta, true
+}
+
+// SetMetaJSON - записывает в метаданные значение любого типа в виде JSON
+// meta - метаданные
+// key - ключ
+// v - значение; в мета-файле SidecarJSON объекты и массивы хранятся вложенными
+func SetMetaJSON(meta map[string]string, key string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	meta[key] = string(b)
+	return nil
+}
+
+// GetMetaJSON - читает из метаданных значение, записанное SetMetaJSON
+// meta - метаданные
+// key - ключ
+// v - указатель на значение
+// bool - ключ есть в метаданных
+func GetMetaJSON(meta map[string]string, key string, v interface{}) (bool, error) {
+	value, ok := meta[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal([]byte(value), v)
+}
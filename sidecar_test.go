@@ -0,0 +1,194 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSidecarRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		meta map[string]string
+	}{
+		{"plain", map[string]string{"a": "1", "owner": "web"}},
+		{"value with '='", map[string]string{"query": "a=1&b=2"}},
+		{"value with a newline", map[string]string{"note": "first\nsecond", "a": "1"}},
+		{"value with a trailing newline", map[string]string{"note": "line\n"}},
+		{"value with CR", map[string]string{"note": "first\r\nsecond"}},
+		{"key with '='", map[string]string{"a=b": "c"}},
+		{"key like JSON", map[string]string{"{x": "y"}},
+		{"JSON value", map[string]string{"obj": "{\n  \"a\": 1\n}"}},
+	}
+
+	for _, format := range []SidecarFormat{SidecarKeyValue, SidecarJSON} {
+		for _, tt := range tests {
+			// SidecarJSON хранит JSON значения вложенными и возвращает их компактными
+			if format == SidecarJSON && tt.name == "JSON value" {
+				continue
+			}
+			t.Run(tt.name, func(t *testing.T) {
+				s := newTestLocal(t, LocalConfig{SidecarFormat: format})
+				path := filepath.Join(t.TempDir(), "a.txt")
+				if err := s.CreateFile(path, []byte("data"), nil, tt.meta); err != nil {
+					t.Fatal(err)
+				}
+
+				_, meta, err := s.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(meta, tt.meta) {
+					t.Errorf("format %d: meta = %q, want %q", format, meta, tt.meta)
+				}
+			})
+		}
+	}
+}
+
+func TestSidecarKeyValueFormat(t *testing.T) {
+	s := newTestLocal(t, LocalConfig{})
+	dir := t.TempDir()
+
+	t.Run("written as key=value", func(t *testing.T) {
+		path := filepath.Join(dir, "plain.txt")
+		if err := s.CreateFile(path, []byte("data"), nil, map[string]string{"a": "1"}); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(path + META_PREFIX); err != nil || string(b) != "a=1\n" {
+			t.Errorf("sidecar = %q, %v; want %q", b, err, "a=1\n")
+		}
+	})
+
+	t.Run("existing key=value file", func(t *testing.T) {
+		path := filepath.Join(dir, "legacy.txt")
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+META_PREFIX, []byte("a=1\nb=x=y\n\nbroken\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, meta, err := s.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := map[string]string{"a": "1", "b": "x=y"}; !reflect.DeepEqual(meta, want) {
+			t.Errorf("meta = %q, want %q", meta, want)
+		}
+	})
+}
+
+func TestSidecarJSONValue(t *testing.T) {
+	type value struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	want := value{Name: "a\nb", Tags: []string{"x", "y"}}
+
+	for _, format := range []SidecarFormat{SidecarKeyValue, SidecarJSON} {
+		s := newTestLocal(t, LocalConfig{SidecarFormat: format})
+		path := filepath.Join(t.TempDir(), "a.txt")
+
+		meta := map[string]string{}
+		if err := SetMetaJSON(meta, "value", want); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateFile(path, []byte("data"), nil, meta); err != nil {
+			t.Fatal(err)
+		}
+		_, meta, err := s.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got value
+		if ok, err := GetMetaJSON(meta, "value", &got); err != nil || !ok {
+			t.Fatalf("format %d: GetMetaJSON = %v, %v", format, ok, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("format %d: value = %+v, want %+v", format, got, want)
+		}
+	}
+}
+
+func TestGarbageCollectMeta(t *testing.T) {
+	// metaCollector - хранилища с мета-файлами
+	type metaCollector interface {
+		StoreIFace
+		GarbageCollectMeta(path string) (int, error)
+	}
+	backends := []struct {
+		name string
+		// store - хранилище, директория для путей хранилища и она же на диске
+		store func(t *testing.T) (metaCollector, string, string)
+	}{
+		{"local", func(t *testing.T) (metaCollector, string, string) {
+			root := t.TempDir()
+			return newTestLocal(t, LocalConfig{}), root, root
+		}},
+		{"webdav", func(t *testing.T) (metaCollector, string, string) {
+			w, root := newTestWebDavDir(t, WebDavConfig{})
+			return w, "", root
+		}},
+	}
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s, dir, disk := b.store(t)
+			if err := os.MkdirAll(filepath.Join(disk, "sub", "deep"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			meta := map[string]string{"Owner": "bob"}
+			for _, name := range []string{"keep.txt", "gone.txt", "sub/keep.txt", "sub/deep/gone.txt"} {
+				if err := s.CreateFile(joinKey(dir, name), []byte(name), nil, meta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// основные файлы удалены в обход хранилища, мета-файлы остались
+			for _, name := range []string{"gone.txt", "sub/deep/gone.txt"} {
+				if err := os.Remove(filepath.Join(disk, filepath.FromSlash(name))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// мета-файл без файла вовсе и мета-файл директории
+			writeTree(t, disk, map[string]string{"stray.txt" + META_PREFIX: "Owner=x\n", "sub" + META_PREFIX: "Owner=x\n"}, time.Now())
+
+			removed, err := s.GarbageCollectMeta(dir)
+			if err != nil || removed != 3 {
+				t.Fatalf("GarbageCollectMeta = %d, %v; want 3 orphans removed", removed, err)
+			}
+			for _, name := range []string{"gone.txt", "sub/deep/gone.txt", "stray.txt"} {
+				if _, err := os.Stat(filepath.Join(disk, filepath.FromSlash(name)+META_PREFIX)); !os.IsNotExist(err) {
+					t.Errorf("orphan %s%s left: %v", name, META_PREFIX, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(disk, "sub"+META_PREFIX)); err != nil {
+				t.Errorf("sidecar of the sub directory removed: %v", err)
+			}
+			for _, name := range []string{"keep.txt", "sub/keep.txt"} {
+				obj, err := s.StatObject(joinKey(dir, name))
+				if err != nil || !reflect.DeepEqual(obj.Meta, meta) {
+					t.Errorf("%s after collection = %+v, %v; want its meta kept", name, obj, err)
+				}
+			}
+
+			// повторный запуск ничего не находит
+			if removed, err := s.GarbageCollectMeta(dir); err != nil || removed != 0 {
+				t.Errorf("second GarbageCollectMeta = %d, %v; want 0", removed, err)
+			}
+
+			// обход поддиректории по пути с завершающим "/"
+			writeTree(t, disk, map[string]string{"sub/orphan.txt" + META_PREFIX: "Owner=x\n"}, time.Now())
+			if removed, err := s.GarbageCollectMeta(joinKey(dir, "sub/")); err != nil || removed != 1 {
+				t.Errorf("GarbageCollectMeta(sub/) = %d, %v; want 1", removed, err)
+			}
+
+			if _, err := s.GarbageCollectMeta(joinKey(dir, "missing")); !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("GarbageCollectMeta of a missing dir = %v, want ErrFileNotFound", err)
+			}
+		})
+	}
+}
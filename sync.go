@@ -0,0 +1,216 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SyncOptions - параметры Sync
+// Delete - удалять в приемнике файлы, которых нет в источнике
+// Checksum - сравнивать файлы одного размера по sha256 содержимого, а не по времени изменения;
+// если у обоих файлов sha256 есть в метаданных (MetaSHA256), содержимое не читается
+type SyncOptions struct {
+	Delete   bool
+	Checksum bool
+}
+
+// SyncStats - итоги Sync
+// Copied - скопировано новых и измененных файлов
+// Skipped - пропущено неизмененных файлов
+// Deleted - удалено лишних файлов в приемнике
+// Bytes - скопировано байт
+type SyncStats struct {
+	Copied  int
+	Skipped int
+	Deleted int
+	Bytes   int64
+}
+
+// Sync - зеркалирует директорию одного хранилища в другое, копируя только новые и измененные файлы
+// src - хранилище-источник
+// srcPrefix - директория в источнике
+// dst - хранилище-приемник
+// dstPrefix - директория в приемнике
+// opts - параметры
+// файл считается измененным, если отличается размер или он новее копии в приемнике
+// (при Checksum - если отличается содержимое); метаданные копируются вместе с файлом
+// при ошибке Sync останавливается и возвращает итоги выполненной части
+func Sync(src StoreIFace, srcPrefix string, dst StoreIFace, dstPrefix string, opts SyncOptions) (SyncStats, error) {
+	return SyncWithContext(context.Background(), src, srcPrefix, dst, dstPrefix, opts)
+}
+
+// SyncWithContext - зеркалирует директорию одного хранилища в другое, копируя только новые и измененные файлы
+// src - хранилище-источник
+// srcPrefix - директория в источнике
+// dst - хранилище-приемник
+// dstPrefix - директория в приемнике
+// opts - параметры
+func SyncWithContext(ctx context.Context, src StoreIFace, srcPrefix string, dst StoreIFace, dstPrefix string, opts SyncOptions) (SyncStats, error) {
+	var stats SyncStats
+	seen := make(map[string]bool)
+
+	err := walkDir(ctx, src, srcPrefix, func(rel string, info os.FileInfo) error {
+		seen[rel] = true
+		srcPath, dstPath := joinKey(srcPrefix, rel), joinKey(dstPrefix, rel)
+
+		changed, err := syncChanged(ctx, src, srcPath, info, dst, dstPath, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", srcPath, err)
+		}
+		if !changed {
+			stats.Skipped++
+			return nil
+		}
+
+		n, err := syncCopy(ctx, src, srcPath, dst, dstPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", srcPath, err)
+		}
+		stats.Copied++
+		stats.Bytes += n
+		return nil
+	})
+	if err != nil || !opts.Delete {
+		return stats, err
+	}
+
+	err = walkDir(ctx, dst, dstPrefix, func(rel string, info os.FileInfo) error {
+		if seen[rel] {
+			return nil
+		}
+
+		dstPath := joinKey(dstPrefix, rel)
+		if err := dst.RemoveFileWithContext(ctx, dstPath); err != nil {
+			return fmt.Errorf("%s: %w", dstPath, err)
+		}
+		stats.Deleted++
+		return nil
+	})
+	// приемника еще нет - удалять нечего
+	if errors.Is(err, ErrFileNotFound) {
+		err = nil
+	}
+
+	return stats, err
+}
+
+// syncChanged - нужно ли копировать файл в приемник
+func syncChanged(ctx context.Context, src StoreIFace, srcPath string, srcInfo os.FileInfo, dst StoreIFace, dstPath string, opts SyncOptions) (bool, error) {
+	dstInfo, dstMeta, err := dst.StatWithContext(ctx, dstPath)
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	if dstInfo == nil || dstInfo.Size() != srcInfo.Size() {
+		return true, nil
+	}
+
+	if !opts.Checksum {
+		return srcInfo.ModTime().After(dstInfo.ModTime()), nil
+	}
+
+	_, srcMeta, err := src.StatWithContext(ctx, srcPath)
+	if err != nil {
+		return false, err
+	}
+	if srcMeta[MetaSHA256] != "" && dstMeta[MetaSHA256] != "" {
+		return srcMeta[MetaSHA256] != dstMeta[MetaSHA256], nil
+	}
+
+	srcSum, err := syncSHA256(ctx, src, srcPath)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := syncSHA256(ctx, dst, dstPath)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(srcSum, dstSum), nil
+}
+
+// syncSHA256 - sha256 содержимого файла
+func syncSHA256(ctx context.Context, s StoreIFace, path string) ([]byte, error) {
+	h := sha256.New()
+	if _, err := s.WriteToWithContext(ctx, path, h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// syncCopy - копирует файл вместе с метаданными потоком
+// int64 - количество скопированных байт
+func syncCopy(ctx context.Context, src StoreIFace, srcPath string, dst StoreIFace, dstPath string) (int64, error) {
+	_, meta, err := src.StatWithContext(ctx, srcPath)
+	if err != nil {
+		return 0, err
+	}
+
+	stream, err := openSource(ctx, src, srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	w, err := dst.FileWriterWithContext(ctx, dstPath, nil, meta)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(w, stream)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
+
+// walkDir - обходит файлы директории рекурсивно
+// fn получает путь файла относительно prefix; мета-файлы не обходятся
+func walkDir(ctx context.Context, s StoreIFace, prefix string, fn func(rel string, info os.FileInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	// прерывает листинг, если fn вернула ошибку
+	defer cancel()
+
+	var dirs []string
+	for entry := range s.ListDirChanWithContext(ctx, prefix) {
+		if entry.Err != nil {
+			return entry.Err
+		}
+		if entry.Info.IsDir() {
+			dirs = append(dirs, entry.Info.Name())
+			continue
+		}
+		if err := fn(entry.Info.Name(), entry.Info); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		err := walkDir(ctx, s, joinKey(prefix, dir), func(rel string, info os.FileInfo) error {
+			return fn(dir+"/"+rel, info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinKey - добавляет к директории относительный путь
+func joinKey(prefix, rel string) string {
+	if prefix == "" {
+		return rel
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + rel
+}
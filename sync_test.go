@@ -0,0 +1,180 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTree - создает файлы относительно root с временем изменения modTime
+func writeTree(t *testing.T, root string, files map[string]string, modTime time.Time) {
+	t.Helper()
+	for name, body := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree - содержимое всех файлов под root по относительным путям, без мета-файлов
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	for _, rel := range listTree(t, root) {
+		if strings.HasSuffix(rel, META_PREFIX) {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[rel] = string(body)
+	}
+	return files
+}
+
+func TestSync(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		opts      SyncOptions
+		src       map[string]string
+		srcTime   time.Time
+		dst       map[string]string
+		dstTime   time.Time
+		wantStats SyncStats
+		wantDst   map[string]string
+	}{
+		{
+			name:      "new files",
+			src:       map[string]string{"a.txt": "aaa", "sub/b.txt": "bb"},
+			srcTime:   older,
+			wantStats: SyncStats{Copied: 2, Bytes: 5},
+			wantDst:   map[string]string{"a.txt": "aaa", "sub/b.txt": "bb"},
+		},
+		{
+			name:      "empty file",
+			src:       map[string]string{"empty.txt": ""},
+			srcTime:   older,
+			wantStats: SyncStats{Copied: 1},
+			wantDst:   map[string]string{"empty.txt": ""},
+		},
+		{
+			name:      "changed size",
+			src:       map[string]string{"a.txt": "longer"},
+			srcTime:   older,
+			dst:       map[string]string{"a.txt": "old"},
+			dstTime:   newer,
+			wantStats: SyncStats{Copied: 1, Bytes: 6},
+			wantDst:   map[string]string{"a.txt": "longer"},
+		},
+		{
+			name:      "changed newer source of the same size",
+			src:       map[string]string{"a.txt": "new"},
+			srcTime:   newer,
+			dst:       map[string]string{"a.txt": "old"},
+			dstTime:   older,
+			wantStats: SyncStats{Copied: 1, Bytes: 3},
+			wantDst:   map[string]string{"a.txt": "new"},
+		},
+		{
+			name:      "unchanged",
+			src:       map[string]string{"a.txt": "same", "sub/b.txt": "same"},
+			srcTime:   older,
+			dst:       map[string]string{"a.txt": "same", "sub/b.txt": "same"},
+			dstTime:   older,
+			wantStats: SyncStats{Skipped: 2},
+			wantDst:   map[string]string{"a.txt": "same", "sub/b.txt": "same"},
+		},
+		{
+			name:      "unchanged by checksum despite an older copy",
+			opts:      SyncOptions{Checksum: true},
+			src:       map[string]string{"a.txt": "same"},
+			srcTime:   newer,
+			dst:       map[string]string{"a.txt": "same"},
+			dstTime:   older,
+			wantStats: SyncStats{Skipped: 1},
+			wantDst:   map[string]string{"a.txt": "same"},
+		},
+		{
+			name:      "changed by checksum despite a newer copy",
+			opts:      SyncOptions{Checksum: true},
+			src:       map[string]string{"a.txt": "new"},
+			srcTime:   older,
+			dst:       map[string]string{"a.txt": "old"},
+			dstTime:   newer,
+			wantStats: SyncStats{Copied: 1, Bytes: 3},
+			wantDst:   map[string]string{"a.txt": "new"},
+		},
+		{
+			name:      "deleted with Delete",
+			opts:      SyncOptions{Delete: true},
+			src:       map[string]string{"a.txt": "a"},
+			srcTime:   older,
+			dst:       map[string]string{"a.txt": "a", "gone.txt": "g", "sub/gone.txt": "g"},
+			dstTime:   older,
+			wantStats: SyncStats{Skipped: 1, Deleted: 2},
+			wantDst:   map[string]string{"a.txt": "a"},
+		},
+		{
+			name:      "deleted kept without Delete",
+			src:       map[string]string{"a.txt": "a"},
+			srcTime:   older,
+			dst:       map[string]string{"a.txt": "a", "gone.txt": "g"},
+			dstTime:   older,
+			wantStats: SyncStats{Skipped: 1},
+			wantDst:   map[string]string{"a.txt": "a", "gone.txt": "g"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcRoot := filepath.Join(t.TempDir(), "src")
+			dstRoot := filepath.Join(t.TempDir(), "dst")
+			writeTree(t, srcRoot, tt.src, tt.srcTime)
+			writeTree(t, dstRoot, tt.dst, tt.dstTime)
+			// в приемнике директории создаются по мере копирования
+			src, dst := newTestLocal(t, LocalConfig{}), newTestLocal(t, LocalConfig{CreateParents: true})
+
+			stats, err := Sync(src, srcRoot, dst, dstRoot, tt.opts)
+			if err != nil {
+				t.Fatalf("Sync: %v", err)
+			}
+			if stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if got := readTree(t, dstRoot); !reflect.DeepEqual(got, tt.wantDst) {
+				t.Errorf("destination = %v, want %v", got, tt.wantDst)
+			}
+
+			// повторный запуск ничего не копирует и не удаляет
+			again, err := Sync(src, srcRoot, dst, dstRoot, tt.opts)
+			if err != nil {
+				t.Fatalf("second Sync: %v", err)
+			}
+			if again.Copied != 0 || again.Deleted != 0 {
+				t.Errorf("second Sync stats = %+v, want nothing copied or deleted", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,443 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// WithBandwidthLimit - оборачивает хранилище ограничением скорости передачи данных
+// s - хранилище
+// bytesPerSec - максимальная скорость в байтах в секунду, 0 - без ограничения
+// Ограничение применяется к потокам StreamToFile(N), FileWriter (и JsonArrayWriter поверх него),
+// FileReader, MultiReader, WriteTo, GetFilePartially, Peek, ArchiveDir и ExtractArchive,
+// а значит и к Copy между хранилищами.
+// GetFile, GetFileVerified, GetFileIfModifiedSince, GetJsonFile и GetJsonMap читают файл
+// средствами хранилища (распаковка, ошибки архивных объектов), после чего выдерживается время,
+// за которое прочитанный объем прошел бы со скоростью bytesPerSec; CreateFile(WithOptions)
+// выдерживает его перед записью.
+// CopyFile(WithOptions) и ExtractRange копируют данные на стороне хранилища, и поток
+// замедлить нельзя: время выдерживается перед копированием.
+// Без ограничения остаются CreateJsonFile, CopyFileIfChanged, Truncate, Manifest
+// и операции, перемещающие файлы (MoveFile, SwapFiles, Rotate).
+func WithBandwidthLimit(s StoreIFace, bytesPerSec int64) StoreIFace {
+	if bytesPerSec <= 0 {
+		return s
+	}
+	return &bandwidthLimited{StoreIFace: s, bytesPerSec: bytesPerSec}
+}
+
+type bandwidthLimited struct {
+	StoreIFace
+	bytesPerSec int64
+}
+
+func (b *bandwidthLimited) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return b.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (b *bandwidthLimited) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	return b.StoreIFace.StreamToFileWithContext(ctx, newRateLimitedReader(ctx, stream, b.bytesPerSec), path, ttl)
+}
+
+func (b *bandwidthLimited) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return b.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+func (b *bandwidthLimited) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return b.StoreIFace.StreamToFileNWithContext(ctx, newRateLimitedReader(ctx, stream, b.bytesPerSec), path, ttl)
+}
+
+func (b *bandwidthLimited) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return b.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+func (b *bandwidthLimited) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	w, err := b.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rateLimitedWriteCloser{
+		rateLimitedWriter: newRateLimitedWriter(ctx, w, b.bytesPerSec),
+		closer:            w,
+	}, nil
+}
+
+func (b *bandwidthLimited) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return b.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+func (b *bandwidthLimited) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	stream, err := b.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	if err != nil || stream == nil {
+		return stream, err
+	}
+
+	return &rateLimitedReadCloser{
+		rateLimitedReader: newRateLimitedReader(ctx, stream, b.bytesPerSec),
+		closer:            stream,
+	}, nil
+}
+
+// MultiReader - ограничение скорости действует на каждый файл
+func (b *bandwidthLimited) MultiReader(paths []string) (io.ReadCloser, error) {
+	return b.MultiReaderWithContext(context.Background(), paths)
+}
+
+func (b *bandwidthLimited) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, b, paths)
+}
+
+func (b *bandwidthLimited) WriteTo(path string, w io.Writer) (int64, error) {
+	return b.WriteToWithContext(context.Background(), path, w)
+}
+
+// WriteToWithContext - хранилище пишет в w через ограничитель, поэтому его быстрый путь
+// (os.File.WriteTo, тело GetObject) сохраняется, но скорость ограничена
+func (b *bandwidthLimited) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	return b.StoreIFace.WriteToWithContext(ctx, path, newRateLimitedWriter(ctx, w, b.bytesPerSec))
+}
+
+func (b *bandwidthLimited) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return b.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+func (b *bandwidthLimited) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, b, path, blockSize)
+}
+
+func (b *bandwidthLimited) GetFile(path string) ([]byte, error) {
+	return b.GetFileWithContext(context.Background(), path)
+}
+
+func (b *bandwidthLimited) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	file, err := b.StoreIFace.GetFileWithContext(ctx, path)
+	if err != nil {
+		return file, err
+	}
+	if err := b.pace(ctx, int64(len(file))); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (b *bandwidthLimited) GetFileVerified(path string) ([]byte, error) {
+	return b.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+func (b *bandwidthLimited) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	file, err := b.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+	if err != nil {
+		return file, err
+	}
+	if err := b.pace(ctx, int64(len(file))); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (b *bandwidthLimited) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	return b.GetFileIfModifiedSinceWithContext(context.Background(), path, t)
+}
+
+func (b *bandwidthLimited) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	file, modified, err := b.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, t)
+	if err != nil {
+		return file, modified, err
+	}
+	if err := b.pace(ctx, int64(len(file))); err != nil {
+		return nil, false, err
+	}
+	return file, modified, nil
+}
+
+func (b *bandwidthLimited) GetJsonFile(path string, file interface{}) error {
+	return b.GetJsonFileWithContext(context.Background(), path, file)
+}
+
+func (b *bandwidthLimited) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	content, err := b.GetFileWithContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	if content == nil {
+		return nil
+	}
+	return json.Unmarshal(content, file)
+}
+
+func (b *bandwidthLimited) GetJsonMap(path string) (map[string]interface{}, error) {
+	return b.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (b *bandwidthLimited) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	content, err := b.GetFileWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, ErrFileNotFound
+	}
+	return decodeJsonMap(content)
+}
+
+func (b *bandwidthLimited) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return b.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (b *bandwidthLimited) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	if err := b.pace(ctx, int64(len(file))); err != nil {
+		return err
+	}
+	return b.StoreIFace.CreateFileWithContext(ctx, path, file, ttl, meta)
+}
+
+func (b *bandwidthLimited) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return b.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+func (b *bandwidthLimited) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	if err := b.pace(ctx, int64(len(file))); err != nil {
+		return err
+	}
+	return b.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+}
+
+func (b *bandwidthLimited) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return b.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+// ArchiveDirWithContext - ограничивается поток архива, поэтому и чтение файлов для него
+func (b *bandwidthLimited) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	return b.StoreIFace.ArchiveDirWithContext(ctx, path, newRateLimitedWriter(ctx, w, b.bytesPerSec), format)
+}
+
+func (b *bandwidthLimited) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return b.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - ограничивается поток архива, поэтому и запись файлов из него
+func (b *bandwidthLimited) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return b.StoreIFace.ExtractArchiveWithContext(ctx, newRateLimitedReader(ctx, r, b.bytesPerSec), path, format)
+}
+
+func (b *bandwidthLimited) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return b.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+func (b *bandwidthLimited) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	stream, err := b.FileReaderWithContext(ctx, path, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		// отсутствующий или пустой файл: передавать нечего, ответ как у хранилища
+		return b.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+	}
+	defer stream.Close()
+
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		return nil, err
+	}
+	// смещение за концом файла FileReader не считает ошибкой
+	if len(data) == 0 && offset > 0 {
+		if info, err := b.StoreIFace.StatLiteWithContext(ctx, path); err == nil && offset > info.Size() {
+			return []byte{}, ErrRangeNotSatisfiable
+		}
+	}
+	return data, nil
+}
+
+func (b *bandwidthLimited) Peek(path string, n int) ([]byte, error) {
+	return b.PeekWithContext(context.Background(), path, n)
+}
+
+func (b *bandwidthLimited) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	if n <= 0 {
+		return b.StoreIFace.PeekWithContext(ctx, path, n)
+	}
+
+	stream, err := b.FileReaderWithContext(ctx, path, 0, int64(n))
+	if err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return b.StoreIFace.PeekWithContext(ctx, path, n)
+	}
+	defer stream.Close()
+
+	return io.ReadAll(stream)
+}
+
+func (b *bandwidthLimited) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return b.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+func (b *bandwidthLimited) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	if err := b.paceCopy(ctx, src, 0, 0); err != nil {
+		return err
+	}
+	return b.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+}
+
+func (b *bandwidthLimited) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return b.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+func (b *bandwidthLimited) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	if err := b.paceCopy(ctx, src, 0, 0); err != nil {
+		return err
+	}
+	return b.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+}
+
+func (b *bandwidthLimited) ExtractRange(src string, offset, length int64, dst string) error {
+	return b.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+func (b *bandwidthLimited) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	if err := b.paceCopy(ctx, src, offset, length); err != nil {
+		return err
+	}
+	return b.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+}
+
+// paceCopy - выдерживает время передачи части src, которую скопирует хранилище
+// ошибку Stat (например, отсутствующий src) вернет сама операция копирования
+func (b *bandwidthLimited) paceCopy(ctx context.Context, src string, offset, length int64) error {
+	info, err := b.StoreIFace.StatLiteWithContext(ctx, src)
+	if err != nil {
+		return nil
+	}
+	n, err := partialLength(info.Size(), offset, length)
+	if err != nil {
+		return nil
+	}
+	return b.pace(ctx, n)
+}
+
+// pace - выдерживает время передачи n байт, которые хранилище передает целиком
+func (b *bandwidthLimited) pace(ctx context.Context, n int64) error {
+	return newRateLimiter(ctx, b.bytesPerSec).add(n)
+}
+
+// rateLimiter - учитывает переданные байты и выдерживает среднюю скорость не выше bytesPerSec
+// ожидание прерывается при отмене контекста
+type rateLimiter struct {
+	ctx         context.Context
+	bytesPerSec int64
+	start       time.Time
+	done        int64
+}
+
+func newRateLimiter(ctx context.Context, bytesPerSec int64) *rateLimiter {
+	return &rateLimiter{
+		ctx:         ctx,
+		bytesPerSec: bytesPerSec,
+		start:       time.Now(),
+	}
+}
+
+// chunk - сколько из n байт передать за раз: не больше, чем допустимо за секунду
+func (l *rateLimiter) chunk(n int) int {
+	if int64(n) > l.bytesPerSec {
+		return int(l.bytesPerSec)
+	}
+	return n
+}
+
+// add - учитывает n переданных байт и ждет, пока средняя скорость не опустится до допустимой
+func (l *rateLimiter) add(n int64) error {
+	l.done += n
+	expected := time.Duration(float64(l.done) / float64(l.bytesPerSec) * float64(time.Second))
+	delay := expected - time.Since(l.start)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-l.ctx.Done():
+		return l.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitedReader - io.Reader, отдающий данные не быстрее заданной скорости
+// ожидание прерывается при отмене контекста
+type rateLimitedReader struct {
+	*rateLimiter
+	r io.Reader
+}
+
+func newRateLimitedReader(ctx context.Context, r io.Reader, bytesPerSec int64) *rateLimitedReader {
+	return &rateLimitedReader{rateLimiter: newRateLimiter(ctx, bytesPerSec), r: r}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.r.Read(p[:r.chunk(len(p))])
+	if waitErr := r.add(int64(n)); waitErr != nil {
+		return n, waitErr
+	}
+
+	return n, err
+}
+
+type rateLimitedReadCloser struct {
+	*rateLimitedReader
+	closer io.Closer
+}
+
+func (r *rateLimitedReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// rateLimitedWriter - io.Writer, принимающий данные не быстрее заданной скорости
+// ожидание прерывается при отмене контекста
+type rateLimitedWriter struct {
+	*rateLimiter
+	w io.Writer
+}
+
+func newRateLimitedWriter(ctx context.Context, w io.Writer, bytesPerSec int64) *rateLimitedWriter {
+	return &rateLimitedWriter{rateLimiter: newRateLimiter(ctx, bytesPerSec), w: w}
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if err := w.ctx.Err(); err != nil {
+			return written, err
+		}
+
+		n, err := w.w.Write(p[:w.chunk(len(p))])
+		written += n
+		p = p[n:]
+		if waitErr := w.add(int64(n)); waitErr != nil {
+			return written, waitErr
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+type rateLimitedWriteCloser struct {
+	*rateLimitedWriter
+	closer io.Closer
+}
+
+func (w *rateLimitedWriteCloser) Close() error {
+	return w.closer.Close()
+}
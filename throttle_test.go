@@ -0,0 +1,236 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestLocal - Local без проверки рабочей директории
+func newTestLocal(t *testing.T, cfg LocalConfig) *Local {
+	t.Helper()
+	cfg.SkipValidation = true
+	s, err := NewLocal(cfg)
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	return s.(*Local)
+}
+
+func TestBandwidthLimit(t *testing.T) {
+	const (
+		bytesPerSec = 200 << 10
+		size        = 100 << 10
+	)
+	// JSON-объект, чтобы его можно было прочитать и GetJsonFile/GetJsonMap
+	data := append(append([]byte(`{"k":"`), bytes.Repeat([]byte("0"), size-8)...), `"}`...)
+	archive := buildArchive(t, ArchiveTar, []archiveEntry{{"a.json", string(data)}})
+
+	tests := []struct {
+		name string
+		// op - передает файл src размера size, dst - свободный путь
+		op func(s StoreIFace, src, dst string) error
+	}{
+		{"StreamToFile", func(s StoreIFace, src, dst string) error {
+			return s.StreamToFile(bytes.NewReader(data), dst, nil)
+		}},
+		{"FileReader", func(s StoreIFace, src, dst string) error {
+			r, err := s.FileReader(src, 0, 0)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			_, err = io.Copy(io.Discard, r)
+			return err
+		}},
+		{"WriteTo", func(s StoreIFace, src, dst string) error {
+			n, err := s.WriteTo(src, io.Discard)
+			if err == nil && n != size {
+				t.Errorf("WriteTo wrote %d bytes, want %d", n, size)
+			}
+			return err
+		}},
+		{"GetFile", func(s StoreIFace, src, dst string) error {
+			_, err := s.GetFile(src)
+			return err
+		}},
+		{"GetFileVerified", func(s StoreIFace, src, dst string) error {
+			_, err := s.GetFileVerified(src)
+			return err
+		}},
+		{"GetFileIfModifiedSince", func(s StoreIFace, src, dst string) error {
+			_, _, err := s.GetFileIfModifiedSince(src, time.Time{})
+			return err
+		}},
+		{"GetJsonFile", func(s StoreIFace, src, dst string) error {
+			var v map[string]string
+			return s.GetJsonFile(src, &v)
+		}},
+		{"GetJsonMap", func(s StoreIFace, src, dst string) error {
+			_, err := s.GetJsonMap(src)
+			return err
+		}},
+		{"GetFilePartially", func(s StoreIFace, src, dst string) error {
+			_, err := s.GetFilePartially(src, 0, size)
+			return err
+		}},
+		{"Peek", func(s StoreIFace, src, dst string) error {
+			_, err := s.Peek(src, size)
+			return err
+		}},
+		{"CreateFile", func(s StoreIFace, src, dst string) error {
+			return s.CreateFile(dst, data, nil, nil)
+		}},
+		{"CreateFileWithOptions", func(s StoreIFace, src, dst string) error {
+			return s.CreateFileWithOptions(dst, data, PutOptions{})
+		}},
+		{"ArchiveDir", func(s StoreIFace, src, dst string) error {
+			return s.ArchiveDir(filepath.Dir(src), io.Discard, ArchiveTar)
+		}},
+		{"ExtractArchive", func(s StoreIFace, src, dst string) error {
+			return s.ExtractArchive(bytes.NewReader(archive), dst, ArchiveTar)
+		}},
+		{"FileWriter", func(s StoreIFace, src, dst string) error {
+			w, err := s.FileWriter(dst, nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				w.Close()
+				return err
+			}
+			return w.Close()
+		}},
+		{"JsonArrayWriter", func(s StoreIFace, src, dst string) error {
+			w, err := NewJsonArrayWriter(s, dst, nil, nil)
+			if err != nil {
+				return err
+			}
+			if err := w.Append(string(data)); err != nil {
+				w.Close()
+				return err
+			}
+			return w.Close()
+		}},
+		{"CopyFile", func(s StoreIFace, src, dst string) error {
+			return s.CopyFile(src, dst, nil, nil)
+		}},
+		{"ExtractRange", func(s StoreIFace, src, dst string) error {
+			return s.ExtractRange(src, 0, size, dst)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+			local := newTestLocal(t, LocalConfig{})
+			if err := local.CreateFile(src, data, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			s := WithBandwidthLimit(local, bytesPerSec)
+
+			start := time.Now()
+			if err := tt.op(s, src, dst); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			elapsed := time.Since(start)
+
+			// size байт со скоростью bytesPerSec - не быстрее 500ms
+			if rate := float64(size) / elapsed.Seconds(); rate > bytesPerSec*1.05 {
+				t.Errorf("throughput %.0f B/s over %v exceeds the cap %d B/s", rate, elapsed, bytesPerSec)
+			}
+		})
+	}
+}
+
+func TestBandwidthLimitPreservesSemantics(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	local := newTestLocal(t, LocalConfig{})
+	if err := local.CreateFile(path, []byte("0123456789"), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty.txt")
+	if err := local.CreateFile(empty, []byte{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	s := WithBandwidthLimit(local, 1<<20)
+
+	// GetFile хранилища распаковывает gzip и сообщает об архивных объектах
+	raw, f := newFakeS3(t, S3Config{AutoDecompress: true})
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("0123456789"))
+	zw.Close()
+	f.put("encoded.log", buf.Bytes(), http.Header{"Content-Encoding": {"gzip"}})
+	f.put("cold.bin", []byte("cold"), http.Header{"X-Amz-Storage-Class": {"GLACIER"}})
+	gz := WithBandwidthLimit(raw, 1<<20)
+
+	tests := []struct {
+		name    string
+		call    func() ([]byte, error)
+		want    []byte
+		wantErr error
+	}{
+		{"partial", func() ([]byte, error) { return s.GetFilePartially(path, 2, 3) }, []byte("234"), nil},
+		{"partial to the end", func() ([]byte, error) { return s.GetFilePartially(path, 7, 0) }, []byte("789"), nil},
+		{"partial past the end", func() ([]byte, error) { return s.GetFilePartially(path, 11, 1) }, []byte{}, ErrRangeNotSatisfiable},
+		{"peek", func() ([]byte, error) { return s.Peek(path, 4) }, []byte("0123"), nil},
+		{"peek longer than file", func() ([]byte, error) { return s.Peek(path, 100) }, []byte("0123456789"), nil},
+		{"peek missing", func() ([]byte, error) { return s.Peek(filepath.Join(dir, "missing"), 4) }, nil, ErrFileNotFound},
+		{"s3 decompressed", func() ([]byte, error) { return gz.GetFile("encoded.log") }, []byte("0123456789"), nil},
+		{"s3 archived", func() ([]byte, error) { return gz.GetFile("cold.bin") }, nil, ErrObjectArchived},
+	}
+
+	// GetFile отвечает так же, как хранилище, вплоть до nil вместо пустого среза
+	for _, p := range []string{path, empty, filepath.Join(dir, "missing")} {
+		want, wantErr := local.GetFile(p)
+		got, err := s.GetFile(p)
+		if !bytes.Equal(got, want) || (got == nil) != (want == nil) || !errors.Is(err, wantErr) {
+			t.Errorf("GetFile(%s) = %#v, %v; want %#v, %v", filepath.Base(p), got, err, want, wantErr)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBandwidthLimitCancel(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big")
+	local := newTestLocal(t, LocalConfig{})
+	if err := local.CreateFile(path, make([]byte, 1<<20), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 1MB со скоростью 64KB/s - 16 секунд, отмена должна прервать чтение сразу
+	s := WithBandwidthLimit(local, 64<<10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := s.GetFileWithContext(ctx, path)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled read took %v", elapsed)
+	}
+}
@@ -0,0 +1,619 @@
+package store
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Атрибуты спанов WithTracing
+// семантических соглашений OpenTelemetry для хранилищ файлов нет, поэтому атрибуты
+// названы по образцу db.*; error.type - стандартный атрибут соглашений
+const (
+	TraceAttrBackend    = "store.backend"
+	TraceAttrOperation  = "store.operation"
+	TraceAttrPathLength = "store.path.length"
+	TraceAttrBytes      = "store.bytes"
+	TraceAttrErrorType  = "error.type"
+)
+
+// WithTracing - оборачивает хранилище спаном на каждый вызов метода
+// s - хранилище
+// tracer - трейсер OpenTelemetry, например otel.Tracer("github.com/Citix-ltd/go-store")
+// спан называется "store.<метод>", методы WithContext получают контекст спана,
+// поэтому спаны вложенных вызовов (MultiStore, Sync и т.п.) становятся его потомками;
+// методы без контекста начинают спан от context.Background().
+// Ошибка записывается в спан (RecordError) и переводит его в статус Error.
+// Спан FileReader и FileWriter заканчивается при открытии потока, а ListDirChan - при закрытии канала.
+func WithTracing(s StoreIFace, tracer trace.Tracer) StoreIFace {
+	return &traced{StoreIFace: s, tracer: tracer, backend: s.Backend()}
+}
+
+type traced struct {
+	StoreIFace
+	tracer  trace.Tracer
+	backend string
+}
+
+// start - начинает спан операции op над файлом path
+func (tr *traced) start(ctx context.Context, op, path string) (context.Context, trace.Span) {
+	return tr.tracer.Start(ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(TraceAttrBackend, tr.backend),
+			attribute.String(TraceAttrOperation, op),
+			attribute.Int64(TraceAttrPathLength, int64(len(path))),
+		),
+	)
+}
+
+// end - записывает результат операции и заканчивает спан
+// n - количество записанных или прочитанных байт, -1 - неизвестно
+func (tr *traced) end(span trace.Span, n int64, err error) {
+	if n >= 0 && err == nil {
+		span.SetAttributes(attribute.Int64(TraceAttrBytes, n))
+	}
+	if err != nil {
+		span.SetAttributes(attribute.String(TraceAttrErrorType, ErrorCode(err).String()))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endDirEntries - пересылает листинг и заканчивает спан при закрытии канала
+func (tr *traced) endDirEntries(ctx context.Context, span trace.Span, in <-chan DirEntry) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		var err error
+		for entry := range in {
+			if entry.Err != nil {
+				err = entry.Err
+			}
+			if !sendDirEntry(ctx, ch, entry) {
+				err = ctx.Err()
+				break
+			}
+		}
+		tr.end(span, -1, err)
+	}()
+
+	return ch
+}
+
+func (tr *traced) IsExist(path string) bool {
+	_, span := tr.start(context.Background(), "IsExist", path)
+	ok := tr.StoreIFace.IsExist(path)
+	tr.end(span, -1, nil)
+	return ok
+}
+
+func (tr *traced) ExistMany(paths []string) (map[string]bool, error) {
+	return tr.ExistManyWithContext(context.Background(), paths)
+}
+
+func (tr *traced) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return tr.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+func (tr *traced) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return tr.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+func (tr *traced) Reserve(path string) error {
+	return tr.ReserveWithContext(context.Background(), path)
+}
+
+func (tr *traced) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return tr.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+func (tr *traced) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return tr.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+func (tr *traced) ExtractRange(src string, offset, length int64, dst string) error {
+	return tr.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+func (tr *traced) Truncate(path string, size int64) error {
+	return tr.TruncateWithContext(context.Background(), path, size)
+}
+
+func (tr *traced) CopyFileIfChanged(src, dst string) (bool, error) {
+	return tr.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+func (tr *traced) MoveFile(src, dst string) error {
+	return tr.MoveFileWithContext(context.Background(), src, dst)
+}
+
+func (tr *traced) MoveFileNoOverwrite(src, dst string) error {
+	return tr.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
+}
+
+func (tr *traced) SwapFiles(a, b string) error {
+	return tr.SwapFilesWithContext(context.Background(), a, b)
+}
+
+func (tr *traced) Rotate(path string) (string, error) {
+	return tr.RotateWithContext(context.Background(), path)
+}
+
+func (tr *traced) CopyMeta(src, dst string) error {
+	return tr.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+func (tr *traced) Symlink(oldname, newname string) error {
+	return tr.SymlinkWithContext(context.Background(), oldname, newname)
+}
+
+func (tr *traced) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return tr.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+func (tr *traced) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return tr.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+func (tr *traced) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return tr.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+func (tr *traced) GetFile(path string) ([]byte, error) {
+	return tr.GetFileWithContext(context.Background(), path)
+}
+
+func (tr *traced) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return tr.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+func (tr *traced) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	return tr.GetFileIfModifiedSinceWithContext(context.Background(), path, t)
+}
+
+func (tr *traced) Peek(path string, n int) ([]byte, error) {
+	return tr.PeekWithContext(context.Background(), path, n)
+}
+
+func (tr *traced) GetFileVerified(path string) ([]byte, error) {
+	return tr.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+func (tr *traced) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return tr.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+func (tr *traced) MultiReader(paths []string) (io.ReadCloser, error) {
+	return tr.MultiReaderWithContext(context.Background(), paths)
+}
+
+func (tr *traced) WriteTo(path string, w io.Writer) (int64, error) {
+	return tr.WriteToWithContext(context.Background(), path, w)
+}
+
+func (tr *traced) RemoveFile(path string) error {
+	return tr.RemoveFileWithContext(context.Background(), path)
+}
+
+func (tr *traced) RemoveFileIfMatch(path, etag string) (bool, error) {
+	return tr.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+func (tr *traced) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return tr.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+func (tr *traced) ClearDir(path string) error {
+	return tr.ClearDirWithContext(context.Background(), path)
+}
+
+func (tr *traced) GetJsonFile(path string, file interface{}) error {
+	return tr.GetJsonFileWithContext(context.Background(), path, file)
+}
+
+func (tr *traced) GetJsonMap(path string) (map[string]interface{}, error) {
+	return tr.GetJsonMapWithContext(context.Background(), path)
+}
+
+func (tr *traced) Stat(path string) (os.FileInfo, map[string]string, error) {
+	return tr.StatWithContext(context.Background(), path)
+}
+
+func (tr *traced) StatLite(path string) (os.FileInfo, error) {
+	return tr.StatLiteWithContext(context.Background(), path)
+}
+
+func (tr *traced) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	return tr.LstatWithContext(context.Background(), path)
+}
+
+func (tr *traced) StatObject(path string) (ObjectInfo, error) {
+	return tr.StatObjectWithContext(context.Background(), path)
+}
+
+func (tr *traced) PublicURL(path string) (string, error) {
+	_, span := tr.start(context.Background(), "PublicURL", path)
+	u, err := tr.StoreIFace.PublicURL(path)
+	tr.end(span, -1, err)
+	return u, err
+}
+
+func (tr *traced) Latest(path string) (os.FileInfo, error) {
+	return tr.LatestWithContext(context.Background(), path)
+}
+
+func (tr *traced) IsEmpty(path string) (bool, error) {
+	return tr.IsEmptyWithContext(context.Background(), path)
+}
+
+func (tr *traced) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return tr.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+func (tr *traced) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return tr.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
+
+func (tr *traced) ListDirChan(path string) <-chan DirEntry {
+	return tr.ListDirChanWithContext(context.Background(), path)
+}
+
+func (tr *traced) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return tr.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+func (tr *traced) ExtractArchive(stream io.Reader, path string, format ArchiveFormat) error {
+	return tr.ExtractArchiveWithContext(context.Background(), stream, path, format)
+}
+
+func (tr *traced) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return tr.ManifestWithContext(context.Background(), path, algo)
+}
+
+func (tr *traced) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return tr.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+func (tr *traced) ListMeta(path string) (map[string]map[string]string, error) {
+	return tr.ListMetaWithContext(context.Background(), path)
+}
+
+func (tr *traced) MkdirAll(path string) error {
+	return tr.MkdirAllWithContext(context.Background(), path)
+}
+
+func (tr *traced) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	ctx, span := tr.start(ctx, "ExistMany", "")
+	exist, err := tr.StoreIFace.ExistManyWithContext(ctx, paths)
+	tr.end(span, -1, err)
+	return exist, err
+}
+
+func (tr *traced) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	ctx, span := tr.start(ctx, "CreateFile", path)
+	err := tr.StoreIFace.CreateFileWithContext(ctx, path, file, ttl, meta)
+	tr.end(span, int64(len(file)), err)
+	return err
+}
+
+func (tr *traced) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	ctx, span := tr.start(ctx, "CreateFileWithOptions", path)
+	err := tr.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+	tr.end(span, int64(len(file)), err)
+	return err
+}
+
+func (tr *traced) ReserveWithContext(ctx context.Context, path string) error {
+	ctx, span := tr.start(ctx, "Reserve", path)
+	err := tr.StoreIFace.ReserveWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	ctx, span := tr.start(ctx, "CopyFile", src)
+	err := tr.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	ctx, span := tr.start(ctx, "CopyFileWithOptions", src)
+	err := tr.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	ctx, span := tr.start(ctx, "ExtractRange", src)
+	err := tr.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	ctx, span := tr.start(ctx, "Truncate", path)
+	err := tr.StoreIFace.TruncateWithContext(ctx, path, size)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	ctx, span := tr.start(ctx, "CopyFileIfChanged", src)
+	copied, err := tr.StoreIFace.CopyFileIfChangedWithContext(ctx, src, dst)
+	tr.end(span, -1, err)
+	return copied, err
+}
+
+func (tr *traced) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	ctx, span := tr.start(ctx, "MoveFile", src)
+	err := tr.StoreIFace.MoveFileWithContext(ctx, src, dst)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	ctx, span := tr.start(ctx, "MoveFileNoOverwrite", src)
+	err := tr.StoreIFace.MoveFileNoOverwriteWithContext(ctx, src, dst)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) SwapFilesWithContext(ctx context.Context, a, b string) error {
+	ctx, span := tr.start(ctx, "SwapFiles", a)
+	err := tr.StoreIFace.SwapFilesWithContext(ctx, a, b)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) RotateWithContext(ctx context.Context, path string) (string, error) {
+	ctx, span := tr.start(ctx, "Rotate", path)
+	rotated, err := tr.StoreIFace.RotateWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return rotated, err
+}
+
+func (tr *traced) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	ctx, span := tr.start(ctx, "CopyMeta", src)
+	err := tr.StoreIFace.CopyMetaWithContext(ctx, src, dst)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	ctx, span := tr.start(ctx, "Symlink", oldname)
+	err := tr.StoreIFace.SymlinkWithContext(ctx, oldname, newname)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	ctx, span := tr.start(ctx, "StreamToFile", path)
+	err := tr.StoreIFace.StreamToFileWithContext(ctx, stream, path, ttl)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	ctx, span := tr.start(ctx, "StreamToFileN", path)
+	n, err := tr.StoreIFace.StreamToFileNWithContext(ctx, stream, path, ttl)
+	tr.end(span, n, err)
+	return n, err
+}
+
+func (tr *traced) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	ctx, span := tr.start(ctx, "FileWriter", path)
+	writer, err := tr.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+	tr.end(span, -1, err)
+	return writer, err
+}
+
+func (tr *traced) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	ctx, span := tr.start(ctx, "GetFile", path)
+	file, err := tr.StoreIFace.GetFileWithContext(ctx, path)
+	tr.end(span, int64(len(file)), err)
+	return file, err
+}
+
+func (tr *traced) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	ctx, span := tr.start(ctx, "GetFilePartially", path)
+	file, err := tr.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+	tr.end(span, int64(len(file)), err)
+	return file, err
+}
+
+func (tr *traced) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	ctx, span := tr.start(ctx, "GetFileIfModifiedSince", path)
+	file, modified, err := tr.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, t)
+	tr.end(span, int64(len(file)), err)
+	return file, modified, err
+}
+
+func (tr *traced) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	ctx, span := tr.start(ctx, "Peek", path)
+	file, err := tr.StoreIFace.PeekWithContext(ctx, path, n)
+	tr.end(span, int64(len(file)), err)
+	return file, err
+}
+
+func (tr *traced) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	ctx, span := tr.start(ctx, "GetFileVerified", path)
+	file, err := tr.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+	tr.end(span, int64(len(file)), err)
+	return file, err
+}
+
+func (tr *traced) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	ctx, span := tr.start(ctx, "FileReader", path)
+	stream, err := tr.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	tr.end(span, -1, err)
+	return stream, err
+}
+
+func (tr *traced) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	ctx, span := tr.start(ctx, "MultiReader", "")
+	stream, err := tr.StoreIFace.MultiReaderWithContext(ctx, paths)
+	tr.end(span, -1, err)
+	return stream, err
+}
+
+func (tr *traced) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	ctx, span := tr.start(ctx, "WriteTo", path)
+	n, err := tr.StoreIFace.WriteToWithContext(ctx, path, w)
+	tr.end(span, n, err)
+	return n, err
+}
+
+func (tr *traced) RemoveFileWithContext(ctx context.Context, path string) error {
+	ctx, span := tr.start(ctx, "RemoveFile", path)
+	err := tr.StoreIFace.RemoveFileWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) RemoveFileIfMatchWithContext(ctx context.Context, path, etag string) (bool, error) {
+	ctx, span := tr.start(ctx, "RemoveFileIfMatch", path)
+	removed, err := tr.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+	tr.end(span, -1, err)
+	return removed, err
+}
+
+func (tr *traced) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	ctx, span := tr.start(ctx, "CreateJsonFile", path)
+	err := tr.StoreIFace.CreateJsonFileWithContext(ctx, path, data, ttl, meta)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) ClearDirWithContext(ctx context.Context, path string) error {
+	ctx, span := tr.start(ctx, "ClearDir", path)
+	err := tr.StoreIFace.ClearDirWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) GetJsonFileWithContext(ctx context.Context, path string, file interface{}) error {
+	ctx, span := tr.start(ctx, "GetJsonFile", path)
+	err := tr.StoreIFace.GetJsonFileWithContext(ctx, path, file)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	ctx, span := tr.start(ctx, "GetJsonMap", path)
+	m, err := tr.StoreIFace.GetJsonMapWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return m, err
+}
+
+func (tr *traced) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	ctx, span := tr.start(ctx, "Stat", path)
+	info, meta, err := tr.StoreIFace.StatWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return info, meta, err
+}
+
+func (tr *traced) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	ctx, span := tr.start(ctx, "StatLite", path)
+	info, err := tr.StoreIFace.StatLiteWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return info, err
+}
+
+func (tr *traced) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	ctx, span := tr.start(ctx, "Lstat", path)
+	info, meta, err := tr.StoreIFace.LstatWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return info, meta, err
+}
+
+func (tr *traced) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	ctx, span := tr.start(ctx, "StatObject", path)
+	obj, err := tr.StoreIFace.StatObjectWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return obj, err
+}
+
+func (tr *traced) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	ctx, span := tr.start(ctx, "Latest", path)
+	info, err := tr.StoreIFace.LatestWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return info, err
+}
+
+func (tr *traced) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	ctx, span := tr.start(ctx, "IsEmpty", path)
+	empty, err := tr.StoreIFace.IsEmptyWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return empty, err
+}
+
+func (tr *traced) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	ctx, span := tr.start(ctx, "ListModifiedSince", path)
+	infos, err := tr.StoreIFace.ListModifiedSinceWithContext(ctx, path, since)
+	tr.end(span, -1, err)
+	return infos, err
+}
+
+func (tr *traced) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	ctx, span := tr.start(ctx, "ListDirDepth", path)
+	infos, err := tr.StoreIFace.ListDirDepthWithContext(ctx, path, maxDepth)
+	tr.end(span, -1, err)
+	return infos, err
+}
+
+func (tr *traced) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ctx, span := tr.start(ctx, "ListDirChan", path)
+	return tr.endDirEntries(ctx, span, tr.StoreIFace.ListDirChanWithContext(ctx, path))
+}
+
+func (tr *traced) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	ctx, span := tr.start(ctx, "ArchiveDir", path)
+	err := tr.StoreIFace.ArchiveDirWithContext(ctx, path, w, format)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) ExtractArchiveWithContext(ctx context.Context, stream io.Reader, path string, format ArchiveFormat) error {
+	ctx, span := tr.start(ctx, "ExtractArchive", path)
+	err := tr.StoreIFace.ExtractArchiveWithContext(ctx, stream, path, format)
+	tr.end(span, -1, err)
+	return err
+}
+
+func (tr *traced) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	ctx, span := tr.start(ctx, "Manifest", path)
+	entries, err := tr.StoreIFace.ManifestWithContext(ctx, path, algo)
+	tr.end(span, -1, err)
+	return entries, err
+}
+
+func (tr *traced) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	ctx, span := tr.start(ctx, "BlockChecksums", path)
+	blocks, err := tr.StoreIFace.BlockChecksumsWithContext(ctx, path, blockSize)
+	tr.end(span, -1, err)
+	return blocks, err
+}
+
+func (tr *traced) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	ctx, span := tr.start(ctx, "ListMeta", path)
+	metas, err := tr.StoreIFace.ListMetaWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return metas, err
+}
+
+func (tr *traced) MkdirAllWithContext(ctx context.Context, path string) error {
+	ctx, span := tr.start(ctx, "MkdirAll", path)
+	err := tr.StoreIFace.MkdirAllWithContext(ctx, path)
+	tr.end(span, -1, err)
+	return err
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracing(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(s StoreIFace, path string) error
+		span     string
+		attrs    map[attribute.Key]attribute.Value
+		wantCode codes.Code
+	}{
+		{"write", func(s StoreIFace, path string) error {
+			return s.CreateFile(path, []byte("hello"), nil, nil)
+		}, "store.CreateFile", map[attribute.Key]attribute.Value{
+			TraceAttrBackend:   attribute.StringValue(LocalStore),
+			TraceAttrOperation: attribute.StringValue("CreateFile"),
+			TraceAttrBytes:     attribute.Int64Value(5),
+		}, codes.Unset},
+		{"read", func(s StoreIFace, path string) error {
+			_, err := s.GetFile(path)
+			return err
+		}, "store.GetFile", map[attribute.Key]attribute.Value{
+			TraceAttrOperation: attribute.StringValue("GetFile"),
+			TraceAttrBytes:     attribute.Int64Value(4),
+		}, codes.Unset},
+		{"error", func(s StoreIFace, path string) error {
+			_, err := s.StatLite(path + ".missing")
+			return err
+		}, "store.StatLite", map[attribute.Key]attribute.Value{
+			TraceAttrOperation: attribute.StringValue("StatLite"),
+			TraceAttrErrorType: attribute.StringValue(ErrorCode(ErrFileNotFound).String()),
+		}, codes.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.txt")
+			local := newTestLocal(t, LocalConfig{})
+			if err := local.CreateFile(path, []byte("data"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			s := WithTracing(local, provider.Tracer("test"))
+
+			err := tt.call(s, path)
+			if (err != nil) != (tt.wantCode == codes.Error) {
+				t.Fatalf("call error = %v", err)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != tt.span {
+				t.Errorf("span name = %q, want %q", span.Name(), tt.span)
+			}
+			got := map[attribute.Key]attribute.Value{}
+			for _, kv := range span.Attributes() {
+				got[kv.Key] = kv.Value
+			}
+			for key, want := range tt.attrs {
+				if got[key] != want {
+					t.Errorf("attribute %s = %v, want %v", key, got[key].Emit(), want.Emit())
+				}
+			}
+			if got[TraceAttrPathLength] == (attribute.Value{}) {
+				t.Errorf("attribute %s is missing", TraceAttrPathLength)
+			}
+			if span.Status().Code != tt.wantCode {
+				t.Errorf("status = %v, want %v", span.Status().Code, tt.wantCode)
+			}
+			if tt.wantCode == codes.Error && len(span.Events()) == 0 {
+				t.Error("error is not recorded as a span event")
+			}
+		})
+	}
+
+	t.Run("parent span from context", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		tracer := provider.Tracer("test")
+		s := WithTracing(newTestLocal(t, LocalConfig{}), tracer)
+
+		ctx, parent := tracer.Start(context.Background(), "request")
+		s.StatLiteWithContext(ctx, filepath.Join(t.TempDir(), "a.txt"))
+		parent.End()
+
+		spans := recorder.Ended()
+		if len(spans) != 2 {
+			t.Fatalf("recorded %d spans, want 2", len(spans))
+		}
+		if spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("store span parent = %v, want %v", spans[0].Parent().SpanID(), parent.SpanContext().SpanID())
+		}
+	})
+}
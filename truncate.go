@@ -0,0 +1,90 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zeroReader - бесконечный поток нулевых байт, которым Truncate дополняет файл
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// withoutChecksums - копия meta без контрольных сумм (MetaSHA256, MetaMD5),
+// которые после изменения содержимого стали бы неверными
+// bool - были ли в meta контрольные суммы
+func withoutChecksums(meta map[string]string) (map[string]string, bool) {
+	dropped := false
+	result := make(map[string]string, len(meta))
+	for k, v := range meta {
+		switch strings.ToLower(k) {
+		case MetaSHA256, MetaMD5:
+			dropped = true
+		default:
+			result[k] = v
+		}
+	}
+	return result, dropped
+}
+
+// truncateRewrite - Truncate перезаписью: начало файла и нули пишутся через FileWriter
+// во временный файл рядом с path, который затем переносится MoveFile
+// используется хранилищами, у которых содержимое не совпадает с файлом бэкенда
+// (разбиение на части, метаданные в заголовке); TTL не переносится
+func truncateRewrite(ctx context.Context, s StoreIFace, path string, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+
+	info, meta, err := s.StatWithContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	if info.Size() == size {
+		return nil
+	}
+
+	keep := min(size, info.Size())
+	var prefix io.Reader = bytes.NewReader(nil)
+	if keep > 0 {
+		r, err := s.FileReaderWithContext(ctx, path, 0, keep)
+		if err == nil && r == nil {
+			err = ErrFileNotFound
+		}
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		prefix = &exactReader{r: r, left: keep}
+	}
+
+	meta, _ = withoutChecksums(meta)
+	tmp := path + ".truncate-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	w, err := s.FileWriterWithContext(ctx, tmp, nil, meta)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, io.MultiReader(prefix, io.LimitReader(zeroReader{}, size-keep)))
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = s.MoveFileWithContext(ctx, tmp, path)
+	}
+	if err != nil {
+		if removeErr := s.RemoveFileWithContext(context.WithoutCancel(ctx), tmp); removeErr != nil && !errors.Is(removeErr, ErrFileNotFound) {
+			return errors.Join(err, removeErr)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		want    string
+		wantErr bool
+	}{
+		{"shrink", 4, "0123", false},
+		{"shrink to empty", 0, "", false},
+		{"same size", 10, "0123456789", false},
+		{"grow with zeros", 13, "0123456789\x00\x00\x00", false},
+		{"negative size", -1, "0123456789", true},
+	}
+
+	for _, b := range testBackends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				s, dir := b.store(t)
+				path := joinKey(dir, "ring.log")
+				meta := map[string]string{"Owner": "logger", MetaSHA256: "stale"}
+				if err := s.CreateFile(path, []byte("0123456789"), nil, meta); err != nil {
+					t.Fatal(err)
+				}
+
+				err := s.Truncate(path, tt.size)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("Truncate(%d) error = %v, want error %t", tt.size, err, tt.wantErr)
+				}
+
+				got, err := s.GetFile(path)
+				if err != nil || string(got) != tt.want {
+					t.Errorf("content = %q, %v; want %q", got, err, tt.want)
+				}
+				obj, err := s.StatObject(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if obj.Size != int64(len(tt.want)) || obj.Meta["Owner"] != "logger" {
+					t.Errorf("size %d, meta %v; want %d bytes and Owner kept", obj.Size, obj.Meta, len(tt.want))
+				}
+				// контрольная сумма прежнего содержимого удаляется при изменении длины
+				if changed := tt.size != 10 && !tt.wantErr; changed && obj.Meta[MetaSHA256] != "" {
+					t.Errorf("%s = %q after the content changed, want it dropped", MetaSHA256, obj.Meta[MetaSHA256])
+				}
+			})
+		}
+
+		t.Run(b.name+"/missing file", func(t *testing.T) {
+			s, dir := b.store(t)
+			if err := s.Truncate(joinKey(dir, "missing.log"), 4); !errors.Is(err, ErrFileNotFound) {
+				t.Errorf("Truncate of a missing file = %v, want ErrFileNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3TruncateCopiesOnServer(t *testing.T) {
+	s, f := newFakeS3(t, S3Config{})
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	f.put("ring.log", data, http.Header{"Content-Type": {"text/plain"}})
+	etag := f.object("ring.log").etag
+
+	if err := s.Truncate("ring.log", 25); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+
+	copies := 0
+	for _, r := range f.requestsTo(http.MethodPut, "uploadId") {
+		if r.Header.Get("X-Amz-Copy-Source") == "" {
+			t.Errorf("shrinking uploaded a part instead of copying it on the server")
+			continue
+		}
+		copies++
+		if got := r.Header.Get("X-Amz-Copy-Source-Range"); got != "bytes=0-24" {
+			t.Errorf("copy range = %q, want bytes=0-24", got)
+		}
+		if got := strings.Trim(r.Header.Get("X-Amz-Copy-Source-If-Match"), `"`); got != etag {
+			t.Errorf("copy If-Match = %q, want the source ETag %q", got, etag)
+		}
+	}
+	if copies != 1 {
+		t.Errorf("%d UploadPartCopy requests, want 1", copies)
+	}
+	if n := len(f.requestsTo(http.MethodGet, "")); n != 0 {
+		t.Errorf("%d GET requests, want the object not to be downloaded", n)
+	}
+
+	obj := f.object("ring.log")
+	if !bytes.Equal(obj.data, data[:25]) || obj.header.Get("Content-Type") != "text/plain" {
+		t.Errorf("object = %q (%s), want the first 25 bytes as text/plain", obj.data, obj.header.Get("Content-Type"))
+	}
+
+	t.Run("source changed during the copy", func(t *testing.T) {
+		s, f := newFakeS3(t, S3Config{})
+		f.put("ring.log", data, nil)
+		// объект меняется между HeadObject и копированием
+		var once bool
+		s.client.Handlers.Send.PushFront(func(r *request.Request) {
+			if !once && r.Operation.Name == "UploadPartCopy" {
+				once = true
+				f.put("ring.log", []byte("replaced"), nil)
+			}
+		})
+
+		err := s.Truncate("ring.log", 25)
+		if err == nil {
+			t.Fatal("Truncate succeeded over a concurrently replaced object")
+		}
+		if got := f.object("ring.log").data; string(got) != "replaced" {
+			t.Errorf("object = %q, want the concurrent write kept", got)
+		}
+		if n := len(f.requestsTo(http.MethodDelete, "uploadId")); n != 1 {
+			t.Errorf("%d aborted uploads, want 1", n)
+		}
+	})
+}
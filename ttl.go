@@ -0,0 +1,170 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// WithDefaultTTL - оборачивает хранилище временем жизни по умолчанию
+// s - хранилище
+// d - время жизни, 0 - без времени жизни по умолчанию
+// Если в CreateFile, CopyFile, StreamToFile(N), FileWriter или CreateJsonFile ttl равен nil
+// (в *WithOptions - PutOptions.TTL), подставляется now+d; явно переданный ttl не меняется.
+// Файлы, созданные ExtractRange, CopyFileIfChanged и ExtractArchive, тоже получают now+d.
+// Заглушка Reserve тоже получает now+d, чтобы незаполненный путь не оставался занятым навсегда.
+// Хранилище применяет его так же, как явный ttl.
+func WithDefaultTTL(s StoreIFace, d time.Duration) StoreIFace {
+	if d <= 0 {
+		return s
+	}
+	return &defaultTTL{StoreIFace: s, ttl: d}
+}
+
+type defaultTTL struct {
+	StoreIFace
+	ttl time.Duration
+}
+
+// expiry - ttl вызова или now+ttl по умолчанию
+func (d *defaultTTL) expiry(ttl *time.Time) *time.Time {
+	if ttl != nil {
+		return ttl
+	}
+	t := time.Now().Add(d.ttl)
+	return &t
+}
+
+func (d *defaultTTL) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return d.StoreIFace.CreateFile(path, file, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return d.StoreIFace.CreateFileWithContext(ctx, path, file, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	opts.TTL = d.expiry(opts.TTL)
+	return d.StoreIFace.CreateFileWithOptions(path, file, opts)
+}
+
+func (d *defaultTTL) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	opts.TTL = d.expiry(opts.TTL)
+	return d.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+}
+
+func (d *defaultTTL) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return d.StoreIFace.CopyFile(src, dst, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	return d.StoreIFace.CopyFileWithContext(ctx, src, dst, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	opts.TTL = d.expiry(opts.TTL)
+	return d.StoreIFace.CopyFileWithOptions(src, dst, opts)
+}
+
+func (d *defaultTTL) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	opts.TTL = d.expiry(opts.TTL)
+	return d.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+}
+
+func (d *defaultTTL) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return d.StoreIFace.StreamToFile(stream, path, d.expiry(ttl))
+}
+
+func (d *defaultTTL) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	return d.StoreIFace.StreamToFileWithContext(ctx, stream, path, d.expiry(ttl))
+}
+
+func (d *defaultTTL) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return d.StoreIFace.StreamToFileN(stream, path, d.expiry(ttl))
+}
+
+func (d *defaultTTL) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return d.StoreIFace.StreamToFileNWithContext(ctx, stream, path, d.expiry(ttl))
+}
+
+func (d *defaultTTL) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return d.StoreIFace.FileWriter(path, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return d.StoreIFace.FileWriterWithContext(ctx, path, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return d.StoreIFace.CreateJsonFile(path, data, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return d.StoreIFace.CreateJsonFileWithContext(ctx, path, data, d.expiry(ttl), meta)
+}
+
+func (d *defaultTTL) ExtractRange(src string, offset, length int64, dst string) error {
+	return d.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+// ExtractRangeWithContext - часть src пишется через StreamToFile, которому можно передать ttl;
+// копирование на стороне сервера S3 при этом не используется
+func (d *defaultTTL) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	obj, err := d.StoreIFace.StatObjectWithContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	length, err = partialLength(obj.Size, offset, length)
+	if err != nil {
+		return err
+	}
+
+	stream, err := d.StoreIFace.FileReaderWithContext(ctx, src, offset, length)
+	if err != nil {
+		return err
+	}
+	// Local не открывает пустой файл
+	if stream == nil {
+		stream = io.NopCloser(bytes.NewReader(nil))
+	}
+	defer stream.Close()
+
+	return d.StoreIFace.StreamToFileWithContext(ctx, stream, dst, d.expiry(nil))
+}
+
+func (d *defaultTTL) CopyFileIfChanged(src, dst string) (bool, error) {
+	return d.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+func (d *defaultTTL) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	return copyFileIfChanged(ctx, d, src, dst)
+}
+
+func (d *defaultTTL) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return d.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - файлы пишутся через StreamToFile со временем жизни по умолчанию;
+// время изменения из архива не сохраняется
+func (d *defaultTTL) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, d, r, path, format, extractTarget{mkdir: d.StoreIFace.Backend() != S3Store})
+}
+
+func (d *defaultTTL) Reserve(path string) error {
+	return d.ReserveWithContext(context.Background(), path)
+}
+
+// ReserveWithContext - заглушка пишется CreateFileWithOptions без перезаписи (OverwriteFail),
+// который, как и Reserve, проверяет и занимает путь атомарно
+func (d *defaultTTL) ReserveWithContext(ctx context.Context, path string) error {
+	return d.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, []byte{}, PutOptions{
+		TTL:       d.expiry(nil),
+		Overwrite: OverwriteFail,
+	})
+}
+
+// appendTarget - дописанный файл сохраняет свое время жизни
+func (d *defaultTTL) appendTarget(path string) (*Local, string, bool) {
+	return appendTarget(d.StoreIFace, path)
+}
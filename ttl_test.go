@@ -0,0 +1,134 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTTLPersisted(t *testing.T) {
+	write := map[string]func(s StoreIFace, path string, ttl *time.Time) error{
+		"CreateFile": func(s StoreIFace, path string, ttl *time.Time) error {
+			return s.CreateFile(path, []byte("data"), ttl, nil)
+		},
+		"StreamToFile": func(s StoreIFace, path string, ttl *time.Time) error {
+			return s.StreamToFile(bytes.NewReader([]byte("data")), path, ttl)
+		},
+		"StreamToFileN": func(s StoreIFace, path string, ttl *time.Time) error {
+			_, err := s.StreamToFileN(bytes.NewReader([]byte("data")), path, ttl)
+			return err
+		},
+		"CopyFile": func(s StoreIFace, path string, ttl *time.Time) error {
+			// исходный файл без мета-файла, чтобы время жизни не перешло от него
+			if err := os.WriteFile(path+".src", []byte("data"), 0644); err != nil {
+				return err
+			}
+			return s.CopyFile(path+".src", path, ttl, nil)
+		},
+		"FileWriter": func(s StoreIFace, path string, ttl *time.Time) error {
+			w, err := s.FileWriter(path, ttl, map[string]string{"k": "v"})
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte("data")); err != nil {
+				w.Close()
+				return err
+			}
+			return w.Close()
+		},
+	}
+
+	explicit := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	tests := []struct {
+		name       string
+		atomic     bool
+		defaultTTL time.Duration
+		ttl        *time.Time
+		// want - ожидаемое время жизни; нулевое - без срока
+		want time.Time
+	}{
+		{"explicit ttl", false, 0, &explicit, explicit},
+		{"explicit ttl atomic", true, 0, &explicit, explicit},
+		{"default ttl", false, time.Hour, nil, time.Now().Add(time.Hour)},
+		{"explicit ttl wins over default", false, time.Minute, &explicit, explicit},
+		{"no ttl", false, 0, nil, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		for method, call := range write {
+			t.Run(tt.name+"/"+method, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "a.txt")
+				s := WithDefaultTTL(newTestLocal(t, LocalConfig{AtomicWrites: tt.atomic}), tt.defaultTTL)
+
+				if err := call(s, path, tt.ttl); err != nil {
+					t.Fatalf("%s: %v", method, err)
+				}
+				obj, err := s.StatObject(path)
+				if err != nil {
+					t.Fatalf("StatObject: %v", err)
+				}
+				if tt.want.IsZero() {
+					if !obj.Expires.IsZero() {
+						t.Errorf("Expires = %v, want none", obj.Expires)
+					}
+					return
+				}
+				if diff := obj.Expires.Sub(tt.want); diff < -time.Minute || diff > time.Minute {
+					t.Errorf("Expires = %v, want about %v", obj.Expires, tt.want)
+				}
+				if method == "FileWriter" && obj.Meta["k"] != "v" {
+					t.Errorf("meta = %v, want the caller's meta kept next to the ttl", obj.Meta)
+				}
+			})
+		}
+	}
+}
+
+func TestDefaultTTLDerivedWrites(t *testing.T) {
+	archive := buildArchive(t, ArchiveTar, []archiveEntry{{"dst.txt", "data"}})
+	tests := []struct {
+		name string
+		// write - создает dst из src
+		write func(s StoreIFace, src, dir string) error
+	}{
+		{"CopyFile", func(s StoreIFace, src, dir string) error {
+			return s.CopyFile(src, joinKey(dir, "dst.txt"), nil, nil)
+		}},
+		{"ExtractRange", func(s StoreIFace, src, dir string) error {
+			return s.ExtractRange(src, 1, 2, joinKey(dir, "dst.txt"))
+		}},
+		{"CopyFileIfChanged", func(s StoreIFace, src, dir string) error {
+			_, err := s.CopyFileIfChanged(src, joinKey(dir, "dst.txt"))
+			return err
+		}},
+		{"ExtractArchive", func(s StoreIFace, src, dir string) error {
+			return s.ExtractArchive(bytes.NewReader(archive), dir, ArchiveTar)
+		}},
+	}
+
+	for _, b := range testBackends {
+		for _, tt := range tests {
+			t.Run(b.name+"/"+tt.name, func(t *testing.T) {
+				raw, dir := b.store(t)
+				src := joinKey(dir, "src.txt")
+				if err := raw.CreateFile(src, []byte("data"), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				s := WithDefaultTTL(raw, time.Hour)
+
+				if err := tt.write(s, src, dir); err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				obj, err := s.StatObject(joinKey(dir, "dst.txt"))
+				if err != nil {
+					t.Fatalf("StatObject: %v", err)
+				}
+				if want := time.Now().Add(time.Hour); obj.Expires.Sub(want).Abs() > time.Minute {
+					t.Errorf("Expires = %v, want about %v", obj.Expires, want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"golang.org/x/net/webdav"
+)
+
+// chdir - меняет рабочую директорию на время теста
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestLocalValidation(t *testing.T) {
+	t.Run("writable working directory", func(t *testing.T) {
+		dir := t.TempDir()
+		chdir(t, dir)
+		if _, err := NewLocal(LocalConfig{}); err != nil {
+			t.Fatalf("NewLocal: %v", err)
+		}
+		// проверочный файл не остается
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("working directory has %d entries after validation, want none", len(entries))
+		}
+	})
+
+	t.Run("removed working directory", func(t *testing.T) {
+		dir := t.TempDir() + "/gone"
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		chdir(t, dir)
+		if err := os.Remove(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := NewLocal(LocalConfig{})
+		if err == nil || !strings.Contains(err.Error(), "working directory is not writable") {
+			t.Errorf("NewLocal = %v, want a not writable error", err)
+		}
+		if _, err := NewLocal(LocalConfig{SkipValidation: true}); err != nil {
+			t.Errorf("NewLocal with SkipValidation = %v, want nil", err)
+		}
+	})
+}
+
+func TestWebDavValidation(t *testing.T) {
+	dav := &webdav.Handler{FileSystem: webdav.Dir(t.TempDir()), LockSystem: webdav.NewMemLS()}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		skip    bool
+		wantErr error
+	}{
+		{"reachable", dav, false, nil},
+		{"unauthorized", statusHandler(http.StatusUnauthorized), false, ErrPermission},
+		{"forbidden", statusHandler(http.StatusForbidden), false, ErrPermission},
+		{"skipped", statusHandler(http.StatusUnauthorized), true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				tt.handler.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			_, err := NewWebDav(WebDavConfig{WebDavHost: srv.URL, SkipValidation: tt.skip})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("NewWebDav = %v, want nil", err)
+				}
+			} else if !errors.Is(err, tt.wantErr) || !strings.Contains(err.Error(), srv.URL) {
+				t.Fatalf("NewWebDav = %v, want %v naming the host", err, tt.wantErr)
+			}
+			if tt.skip && requests.Load() != 0 {
+				t.Errorf("%d requests with SkipValidation, want none", requests.Load())
+			}
+		})
+	}
+
+	t.Run("unreachable host", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		if _, err := NewWebDav(WebDavConfig{WebDavHost: srv.URL}); err == nil {
+			t.Error("NewWebDav to a closed server succeeded, want an error")
+		}
+	})
+}
+
+func TestS3Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		skip   bool
+		ok     bool
+	}{
+		{"existing bucket", http.StatusOK, false, true},
+		{"missing bucket", http.StatusNotFound, false, false},
+		{"forbidden bucket", http.StatusForbidden, false, false},
+		{"skipped", http.StatusNotFound, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_CA_BUNDLE", "")
+			var heads []string
+			rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				heads = append(heads, r.Method+" "+r.URL.Path)
+				return &http.Response{
+					StatusCode: tt.status,
+					Header:     http.Header{"Content-Length": {"0"}},
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    r,
+				}, nil
+			})
+
+			cfg := S3Config{S3Bucket: "reports", SkipValidation: tt.skip}
+			cfg.Region = aws.String("us-east-1")
+			cfg.MaxRetries = aws.Int(0)
+			cfg.S3ForcePathStyle = aws.Bool(true)
+			cfg.Credentials = credentials.NewStaticCredentials("id", "secret", "")
+			cfg.HTTPClient = &http.Client{Transport: rt}
+
+			_, err := NewS3(cfg)
+			if tt.ok != (err == nil) {
+				t.Fatalf("NewS3 = %v, want ok %v", err, tt.ok)
+			}
+			if err != nil && !strings.Contains(err.Error(), `bucket "reports" is not accessible`) {
+				t.Errorf("error %q does not name the bucket", err)
+			}
+
+			want := []string{"HEAD /reports"}
+			if tt.skip {
+				want = nil
+			}
+			if strings.Join(heads, ",") != strings.Join(want, ",") {
+				t.Errorf("requests = %v, want %v", heads, want)
+			}
+		})
+	}
+}
+
+func TestNewSkipValidation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	cfg := Config{StoreType: WebDavStore, WebDavConfig: WebDavConfig{WebDavHost: srv.URL}}
+	if _, err := New(cfg); !errors.Is(err, ErrPermission) {
+		t.Fatalf("New = %v, want the validation error", err)
+	}
+	cfg.SkipValidation = true
+	if _, err := New(cfg); err != nil {
+		t.Errorf("New with Config.SkipValidation = %v, want nil", err)
+	}
+}
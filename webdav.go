@@ -4,22 +4,132 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/studio-b12/gowebdav"
 )
 
 type WebDav struct {
-	client *gowebdav.Client
+	client         *gowebdav.Client
+	skipExistCheck bool
+	atomicWrites   bool
+	publicBaseURL  string
+	createParents  bool
+	contentType    ContentTypeResolver
+	sidecarFormat  SidecarFormat
+	// removeMu - сериализует сравнение и удаление в RemoveFileIfMatch
+	removeMu sync.Mutex
+	// auth - заменяемая авторизация (RefreshCredentials, RefreshToken)
+	auth     *rotatingAuth
+	authType WebDavAuthType
 }
 
 func (w *WebDav) init(cfg WebDavConfig) error {
-	w.client = gowebdav.NewClient(cfg.WebDavHost, cfg.WebDavUser, cfg.WebDavPass)
+	auth, err := webdavAuthorizer(cfg)
+	if err != nil {
+		return err
+	}
+	w.auth = newRotatingAuth(auth)
+	w.authType = cfg.AuthType
+	w.client = gowebdav.NewAuthClient(cfg.WebDavHost, w.auth)
+	if cfg.MaxIdleConns > 0 || cfg.MaxIdleConnsPerHost > 0 || cfg.IdleConnTimeout > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if cfg.MaxIdleConns > 0 {
+			transport.MaxIdleConns = cfg.MaxIdleConns
+		}
+		if cfg.MaxIdleConnsPerHost > 0 {
+			transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+		}
+		if cfg.IdleConnTimeout > 0 {
+			transport.IdleConnTimeout = cfg.IdleConnTimeout
+		}
+		w.client.SetTransport(transport)
+	}
+	w.skipExistCheck = cfg.SkipExistCheck
+	w.atomicWrites = cfg.AtomicWrites
+	w.createParents = cfg.CreateParents
+	w.contentType = cfg.ContentType
+	w.sidecarFormat = cfg.SidecarFormat
+	w.publicBaseURL = cfg.PublicBaseURL
+	if w.publicBaseURL == "" {
+		w.publicBaseURL = withoutUserinfo(cfg.WebDavHost)
+	}
+
+	if cfg.SkipValidation {
+		return nil
+	}
+	if err := w.client.Connect(); err != nil {
+		return fmt.Errorf("webdav: %s is not accessible: %w", cfg.WebDavHost, webdavError(err))
+	}
 	return nil
 }
 
+// withoutUserinfo - адрес сервера без учетных данных вида user:password@
+func withoutUserinfo(host string) string {
+	u, err := url.Parse(host)
+	if err != nil || u.User == nil {
+		return host
+	}
+	u.User = nil
+	return u.String()
+}
+
+// webdavError - приводит ошибку WebDav к типизированной ошибке пакета
+// 404 - ErrFileNotFound
+// 401, 403 - ErrPermission
+// 405, 412 - ErrAlreadyExists
+// 507 - ErrInsufficientStorage
+// исходная ошибка оборачивается и доступна через errors.Unwrap
+func webdavError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case gowebdav.IsErrNotFound(err):
+		return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+	case gowebdav.IsErrCode(err, http.StatusUnauthorized),
+		gowebdav.IsErrCode(err, http.StatusForbidden):
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	case gowebdav.IsErrCode(err, http.StatusMethodNotAllowed),
+		gowebdav.IsErrCode(err, http.StatusPreconditionFailed):
+		return fmt.Errorf("%w: %w", ErrAlreadyExists, err)
+	case gowebdav.IsErrCode(err, http.StatusInsufficientStorage):
+		return fmt.Errorf("%w: %w", ErrInsufficientStorage, err)
+	case gowebdav.IsErrCode(err, http.StatusRequestedRangeNotSatisfiable):
+		return fmt.Errorf("%w: %w", ErrRangeNotSatisfiable, err)
+	default:
+		return err
+	}
+}
+
+// maxDrainBytes - сколько непрочитанных байт ответа дочитывается при закрытии,
+// больший остаток дешевле оборвать вместе с соединением
+const maxDrainBytes = 256 * 1024
+
+// drainingReadCloser - при закрытии дочитывает остаток ответа, чтобы соединение
+// вернулось в пул keep-alive, а не закрывалось
+type drainingReadCloser struct {
+	io.ReadCloser
+}
+
+func (d drainingReadCloser) Close() error {
+	io.CopyN(io.Discard, d.ReadCloser, maxDrainBytes)
+	return d.ReadCloser.Close()
+}
+
+// Backend - возвращает тип хранилища (WebDavStore)
+func (w *WebDav) Backend() string {
+	return WebDavStore
+}
+
 // IsExist - проверяет существование файла
 // filePath - путь к файлу
 func (w *WebDav) IsExist(filePath string) bool {
@@ -27,18 +137,96 @@ func (w *WebDav) IsExist(filePath string) bool {
 	return err == nil && info.Size() > 0
 }
 
+// ExistMany - проверяет существование нескольких файлов параллельно
+// paths - пути к файлам
+// как и в IsExist, пустой файл считается отсутствующим; пути, которые не удалось
+// проверить, в результат не попадают, а их ошибки объединяются в возвращаемую ошибку
+func (w *WebDav) ExistMany(paths []string) (map[string]bool, error) {
+	return w.ExistManyWithContext(context.Background(), paths)
+}
+
+// ExistManyWithContext - проверяет существование нескольких файлов параллельно
+// paths - пути к файлам
+func (w *WebDav) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	return existMany(ctx, paths, func(ctx context.Context, path string) (bool, error) {
+		info, err := w.client.Stat(path)
+		if err != nil {
+			if gowebdav.IsErrNotFound(err) {
+				return false, nil
+			}
+			return false, webdavError(err)
+		}
+		return info.Size() > 0, nil
+	})
+}
+
 // CreateFile - создает файл
 // path - путь к файлу
 // file - содержимое файла
 // meta - метаданные файла
 func (w *WebDav) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return w.CreateFileWithOptions(path, file, PutOptions{TTL: ttl, Meta: meta})
+}
+
+// createFile - записывает мета-файл и файл
+func (w *WebDav) createFile(path string, file []byte, meta map[string]string) error {
+	if err := w.prepareParent(path); err != nil {
+		return err
+	}
+
+	// мета-файл пишется первым: когда появляется файл, метаданные уже на месте
 	if meta != nil {
-		if err := w.client.Write(path+META_PREFIX, meta2Bytes(meta), perm); err != nil {
+		if err := w.write(path+META_PREFIX, w.sidecarFormat.encode(meta)); err != nil {
 			return err
 		}
 	}
 
-	return w.client.Write(path, file, perm)
+	return w.write(path, file)
+}
+
+// prepareParent - проверяет родительскую коллекцию path перед записью,
+// при CreateParents создает ее
+func (w *WebDav) prepareParent(path string) error {
+	i := strings.LastIndex(strings.TrimSuffix(path, "/"), "/")
+	if i <= 0 {
+		// корень существует всегда
+		return nil
+	}
+	dir := path[:i]
+
+	if w.createParents {
+		return webdavError(w.client.MkdirAll(dir, perm))
+	}
+
+	info, err := w.client.Stat(dir)
+	if err != nil {
+		if gowebdav.IsErrNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrParentNotExist, dir)
+		}
+		return webdavError(err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrIsNotDir, dir)
+	}
+	return nil
+}
+
+// write - записывает файл, при AtomicWrites во временный путь с последующим MOVE
+func (w *WebDav) write(path string, data []byte) error {
+	if !w.atomicWrites {
+		return webdavError(w.client.Write(path, data, perm))
+	}
+
+	tmp := path + ".tmp-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := w.client.Write(tmp, data, perm); err != nil {
+		return webdavError(err)
+	}
+
+	if err := w.client.Rename(tmp, path, true); err != nil {
+		w.client.Remove(tmp)
+		return webdavError(err)
+	}
+	return nil
 }
 
 // CreateFileWithContext - создает файл
@@ -54,37 +242,122 @@ func (w *WebDav) CreateFileWithContext(ctx context.Context, path string, file []
 	}
 }
 
+// Reserve - резервирует путь: создает пустой файл, только если его еще нет
+// path - путь к файлу
+// пустой файл - заглушка, которую позже перезапишут; если путь занят, возвращается ErrAlreadyExists.
+// Пустой файл пишется под временным именем и переносится MOVE с Overwrite: F,
+// поэтому занятость пути атомарно проверяет сервер
+func (w *WebDav) Reserve(path string) error {
+	return w.createExclusive(path, nil, nil)
+}
+
+// ReserveWithContext - резервирует путь: создает пустой файл, только если его еще нет
+// path - путь к файлу
+func (w *WebDav) ReserveWithContext(ctx context.Context, path string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.Reserve(path)
+	}
+}
+
+// CreateFileWithOptions - создает файл
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи, ACL в WebDav не поддерживается и игнорируется
+func (w *WebDav) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	contentType := resolveContentType(opts.ContentType, w.contentType, path, file)
+	meta := withContentType(opts.sidecarMeta(), path, contentType)
+	if opts.Overwrite == OverwriteAllow {
+		return w.createFile(path, file, meta)
+	}
+	return opts.Overwrite.result(w.createExclusive(path, file, meta))
+}
+
+// createExclusive - создает файл, только если его еще нет, иначе ErrAlreadyExists
+// Файл пишется под временным именем и переносится MOVE с Overwrite: F, поэтому занятость
+// пути атомарно проверяет сервер; мета-файл пишется после, чтобы не затереть метаданные существующего
+func (w *WebDav) createExclusive(path string, file []byte, meta map[string]string) error {
+	if err := w.prepareParent(path); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := w.client.Write(tmp, file, perm); err != nil {
+		return webdavError(err)
+	}
+	if err := w.client.Rename(tmp, path, false); err != nil {
+		w.client.Remove(tmp)
+		return webdavError(err)
+	}
+
+	if meta != nil {
+		return w.write(path+META_PREFIX, w.sidecarFormat.encode(meta))
+	}
+	return nil
+}
+
+// CreateFileWithOptionsWithContext - создает файл
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи, ACL в WebDav не поддерживается и игнорируется
+func (w *WebDav) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.CreateFileWithOptions(path, file, opts)
+	}
+}
+
 // CopyFile - копирует файл
 // src - исходный путь к файлу
 // dst - путь куда копировать
-// ttl - время жизни
+// ttl - время жизни, nil - время жизни src сохраняется
 // meta - метаданные
+// Сначала копируется файл, затем пишется мета-файл, чтобы сервер не перезаписал его
+// при COPY. Если мета-файл записать не удалось, копия удаляется и возвращается ошибка:
+// файл без своих метаданных не остается. Мета-файл, оставшийся от прежнего dst,
+// удаляется, если у копии метаданных нет
 func (w *WebDav) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
-	currMetaIsExist := w.IsExist(src + META_PREFIX)
+	if err := w.prepareParent(dst); err != nil {
+		return err
+	}
 
-	if currMetaIsExist {
+	// метаданные читаются до копирования, чтобы соответствовать скопированному содержимому
+	meta = withExpires(meta, ttl)
+	dstMeta := meta
+	if w.IsExist(src + META_PREFIX) {
 		currentMeta, err := w.GetFile(src + META_PREFIX)
 		if err != nil {
 			return err
 		}
 
-		currentMetaMap := bytes2Meta(currentMeta)
-
+		dstMeta = bytes2Meta(currentMeta)
 		for k, v := range meta {
-			currentMetaMap[k] = v
-		}
-
-		if err := w.client.Write(dst+META_PREFIX, meta2Bytes(currentMetaMap), perm); err != nil {
-			return err
+			dstMeta[k] = v
 		}
 	}
 
-	err := w.client.Copy(src, dst, true)
+	if err := w.client.Copy(src, dst, true); err != nil {
+		return webdavError(err)
+	}
 
-	if err != nil && gowebdav.IsErrNotFound(err) {
-		return ErrFileNotFound
+	if dstMeta == nil {
+		if err := w.client.Remove(dst + META_PREFIX); err != nil {
+			return fmt.Errorf("%s: remove stale metadata: %w", dst, webdavError(err))
+		}
+		return nil
+	}
+	if err := w.client.Write(dst+META_PREFIX, w.sidecarFormat.encode(dstMeta), perm); err != nil {
+		err = fmt.Errorf("%s: metadata not written, copy removed: %w", dst, webdavError(err))
+		if rmErr := w.client.Remove(dst); rmErr != nil {
+			return errors.Join(err, fmt.Errorf("%s: remove copy: %w", dst, webdavError(rmErr)))
+		}
+		return err
 	}
-	return err
+	return nil
 }
 
 // CopyFileWithContext - копирует файл
@@ -101,17 +374,55 @@ func (w *WebDav) CopyFileWithContext(ctx context.Context, src, dst string, ttl *
 	}
 }
 
+// CopyFileWithOptions - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// opts - параметры записи, ACL в WebDav не поддерживается и игнорируется
+func (w *WebDav) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return w.CopyFile(src, dst, opts.TTL, opts.sidecarMeta())
+}
+
+// CopyFileWithOptionsWithContext - копирует файл
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// opts - параметры записи, ACL в WebDav не поддерживается и игнорируется
+func (w *WebDav) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.CopyFileWithOptions(src, dst, opts)
+	}
+}
+
+// CopyFileIfChanged - копирует файл, только если содержимое src и dst отличается
+// src - исходный путь к файлу
+// dst - путь куда копировать
+// bool - выполнено ли копирование; отсутствующий dst копируется всегда.
+// Сравниваются размер, затем sha256 из метаданных, иначе sha256 содержимого обоих файлов
+func (w *WebDav) CopyFileIfChanged(src, dst string) (bool, error) {
+	return w.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+// CopyFileIfChangedWithContext - копирует файл, только если содержимое src и dst отличается
+// src - исходный путь к файлу
+// dst - путь куда копировать
+func (w *WebDav) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	return copyFileIfChanged(ctx, w, src, dst)
+}
+
 // MoveFile - перемещает файл
 // src - исходный путь к файлу
 // dst - путь куда переместить
 func (w *WebDav) MoveFile(src, dst string) error {
+	if err := w.prepareParent(dst); err != nil {
+		return err
+	}
+
 	w.client.Rename(src+META_PREFIX, dst+META_PREFIX, true)
 	err := w.client.Rename(src, dst, true)
 
-	if err != nil && gowebdav.IsErrNotFound(err) {
-		return ErrFileNotFound
-	}
-	return err
+	return webdavError(err)
 }
 
 // MoveFileWithContext - перемещает файл
@@ -126,15 +437,286 @@ func (w *WebDav) MoveFileWithContext(ctx context.Context, src, dst string) error
 	}
 }
 
+// MoveFileNoOverwrite - перемещает файл, если dst не существует
+// src - исходный путь к файлу
+// dst - путь куда переместить
+// Сервер сам отклоняет MOVE с Overwrite: F, если dst существует (412)
+func (w *WebDav) MoveFileNoOverwrite(src, dst string) error {
+	if err := w.prepareParent(dst); err != nil {
+		return err
+	}
+
+	if err := w.client.Rename(src, dst, false); err != nil {
+		return webdavError(err)
+	}
+
+	w.client.Rename(src+META_PREFIX, dst+META_PREFIX, true)
+	return nil
+}
+
+// MoveFileNoOverwriteWithContext - перемещает файл, если dst не существует
+// src - исходный путь к файлу
+// dst - путь куда переместить
+func (w *WebDav) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.MoveFileNoOverwrite(src, dst)
+	}
+}
+
+// SwapFiles - меняет местами содержимое и метаданные двух файлов
+// a - путь к первому файлу
+// b - путь ко второму файлу
+// Оба файла копируются (COPY) под временные имена и переносятся (MOVE) поверх друг друга,
+// поэтому ни один путь не пропадает, если сервер заменяет файл при MOVE атомарно;
+// на время обмена берется Lock обоих путей, поэтому одновременные SwapFiles с этими путями,
+// в том числе других клиентов, выполняются по очереди
+func (w *WebDav) SwapFiles(a, b string) error {
+	return w.SwapFilesWithContext(context.Background(), a, b)
+}
+
+// SwapFilesWithContext - меняет местами содержимое и метаданные двух файлов
+// a - путь к первому файлу
+// b - путь ко второму файлу
+func (w *WebDav) SwapFilesWithContext(ctx context.Context, a, b string) (err error) {
+	unlock, err := lockPaths(ctx, w, a, b)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+
+	for _, path := range []string{a, b} {
+		if _, err := w.client.Stat(path); err != nil {
+			return webdavError(err)
+		}
+	}
+
+	if err := w.swapPaths(a, b); err != nil {
+		return err
+	}
+
+	metaA := w.IsExist(a + META_PREFIX)
+	metaB := w.IsExist(b + META_PREFIX)
+	switch {
+	case metaA && metaB:
+		return w.swapPaths(a+META_PREFIX, b+META_PREFIX)
+	case metaA:
+		return webdavError(w.client.Rename(a+META_PREFIX, b+META_PREFIX, true))
+	case metaB:
+		return webdavError(w.client.Rename(b+META_PREFIX, a+META_PREFIX, true))
+	}
+	return nil
+}
+
+// swapPaths - меняет местами два существующих файла через временные копии
+func (w *WebDav) swapPaths(a, b string) error {
+	suffix := ".swap-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	tmpA, tmpB := a+suffix, b+suffix
+
+	if err := w.client.Copy(a, tmpA, true); err != nil {
+		return webdavError(err)
+	}
+	if err := w.client.Copy(b, tmpB, true); err != nil {
+		w.client.Remove(tmpA)
+		return webdavError(err)
+	}
+	if err := w.client.Rename(tmpB, a, true); err != nil {
+		w.client.Remove(tmpA)
+		w.client.Remove(tmpB)
+		return webdavError(err)
+	}
+	return webdavError(w.client.Rename(tmpA, b, true))
+}
+
+// Rotate - переносит файл под имя с меткой времени и создает на его месте пустой
+// path - путь к файлу
+// string - путь, под которым сохранено прежнее содержимое
+func (w *WebDav) Rotate(path string) (string, error) {
+	return w.RotateWithContext(context.Background(), path)
+}
+
+// RotateWithContext - переносит файл под имя с меткой времени и создает на его месте пустой
+// path - путь к файлу
+// string - путь, под которым сохранено прежнее содержимое
+func (w *WebDav) RotateWithContext(ctx context.Context, path string) (string, error) {
+	rotated := rotatedPath(path, time.Now())
+	if err := rotateTo(ctx, w, path, rotated); err != nil {
+		return "", err
+	}
+	return rotated, nil
+}
+
+// ExtractRange - создает файл dst из части содержимого src
+// src - исходный путь к файлу
+// offset - смещение от начала src
+// length - длина части, 0 и меньше - до конца файла; часть, выходящая за конец файла, обрезается по нему
+// dst - путь к новому файлу, метаданные src не копируются
+// WebDav не умеет копировать часть файла, поэтому часть читается запросом Range и записывается заново.
+// Длина части считается по размеру src заранее: сервер отвечает 416 на диапазон с конца файла
+func (w *WebDav) ExtractRange(src string, offset, length int64, dst string) error {
+	info, err := w.client.Stat(src)
+	if err != nil {
+		return webdavError(err)
+	}
+	length, err = partialLength(info.Size(), offset, length)
+	if err != nil {
+		return err
+	}
+	if length == 0 {
+		return w.StreamToFile(bytes.NewReader(nil), dst, nil)
+	}
+
+	stream, err := w.FileReader(src, offset, length)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return w.StreamToFile(stream, dst, nil)
+}
+
+// ExtractRangeWithContext - создает файл dst из части содержимого src
+// src - исходный путь к файлу
+// offset - смещение от начала src
+// length - длина части, 0 и меньше - до конца файла
+// dst - путь к новому файлу
+func (w *WebDav) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.ExtractRange(src, offset, length, dst)
+	}
+}
+
+// Truncate - изменяет длину файла
+// path - путь к файлу
+// size - новая длина; файл короче size дополняется нулевыми байтами
+// WebDav не умеет менять длину файла, поэтому сохраняемое начало читается запросом Range
+// и вместе с дополнением записывается во временный файл, который переносится на место path.
+// Контрольные суммы из мета-файла (MetaSHA256, MetaMD5) удаляются
+func (w *WebDav) Truncate(path string, size int64) error {
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+
+	info, meta, err := w.stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() == size {
+		return nil
+	}
+
+	keep := min(size, info.Size())
+	var prefix io.Reader = bytes.NewReader(nil)
+	if keep > 0 {
+		stream, err := w.client.ReadStreamRange(path, 0, keep)
+		if err != nil {
+			return webdavError(err)
+		}
+		defer stream.Close()
+		prefix = &exactReader{r: stream, left: keep}
+	}
+
+	tmp := path + ".tmp-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	content := io.MultiReader(prefix, io.LimitReader(zeroReader{}, size-keep))
+	if err := w.client.WriteStream(tmp, content, perm); err != nil {
+		w.client.Remove(tmp)
+		return webdavError(err)
+	}
+	if err := w.client.Rename(tmp, path, true); err != nil {
+		w.client.Remove(tmp)
+		return webdavError(err)
+	}
+
+	if meta, dropped := withoutChecksums(meta); dropped {
+		if len(meta) == 0 {
+			return webdavError(w.client.Remove(path + META_PREFIX))
+		}
+		return webdavError(w.client.Write(path+META_PREFIX, w.sidecarFormat.encode(meta), perm))
+	}
+	return nil
+}
+
+// TruncateWithContext - изменяет длину файла
+// path - путь к файлу
+// size - новая длина; файл короче size дополняется нулевыми байтами
+func (w *WebDav) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.Truncate(path, size)
+	}
+}
+
+// CopyMeta - копирует метаданные src в dst, не изменяя содержимое dst
+// src - путь к файлу, метаданные которого копируются
+// dst - путь к файлу, которому они назначаются
+// если у src нет метаданных, метаданные dst удаляются; CacheControl, Content-Type и TTL dst сохраняются
+func (w *WebDav) CopyMeta(src, dst string) error {
+	_, meta, err := w.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	_, dstMeta, err := w.stat(dst)
+	if err != nil {
+		return err
+	}
+	contentType, expires := dstMeta[metaContentType], dstMeta[metaExpires]
+	_, cacheControl := splitCacheControl(dstMeta)
+	meta = withContentType(withCacheControl(meta, cacheControl), dst, contentType)
+	meta = withMetaValue(meta, metaExpires, expires)
+
+	if len(meta) == 0 {
+		return webdavError(w.client.Remove(dst + META_PREFIX))
+	}
+
+	return w.write(dst+META_PREFIX, w.sidecarFormat.encode(meta))
+}
+
+// CopyMetaWithContext - копирует метаданные src в dst, не изменяя содержимое dst
+// src - путь к файлу, метаданные которого копируются
+// dst - путь к файлу, которому они назначаются
+func (w *WebDav) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return w.CopyMeta(src, dst)
+	}
+}
+
 // StreamToFile - записывает содержимое потока в файл
 // stream - поток
 // path - путь к файлу
+// ttl - время жизни
 func (w *WebDav) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
-	err := w.client.WriteStream(path, stream, perm)
-	if err != nil && gowebdav.IsErrNotFound(err) {
-		return ErrFileNotFound
+	if err := w.prepareParent(path); err != nil {
+		return err
+	}
+
+	// время жизни хранится в мета-файле, как в CreateFile
+	if meta := withExpires(nil, ttl); meta != nil {
+		if err := w.write(path+META_PREFIX, w.sidecarFormat.encode(meta)); err != nil {
+			return err
+		}
 	}
-	return err
+	return w.writeStream(path, stream)
+}
+
+// writeStream - записывает поток в path без мета-файла
+func (w *WebDav) writeStream(path string, stream io.Reader) error {
+	err := w.client.WriteStream(path, stream, perm)
+	return webdavError(err)
 }
 
 // StreamToFileWithContext - записывает содержимое потока в файл
@@ -150,13 +732,72 @@ func (w *WebDav) StreamToFileWithContext(ctx context.Context, stream io.Reader,
 
 }
 
+// StreamToFileN - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (w *WebDav) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return w.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFileNWithContext - записывает содержимое потока в файл
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (w *WebDav) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	counter := &countingReader{r: stream}
+	err := w.StreamToFileWithContext(ctx, counter, path, ttl)
+	return counter.n, err
+}
+
+// FileWriter - открывает файл на запись
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные файла
+// запись завершается и ошибка загрузки возвращается при Close
+func (w *WebDav) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	if err := w.prepareParent(path); err != nil {
+		return nil, err
+	}
+
+	if meta = withExpires(meta, ttl); meta != nil {
+		if err := w.client.Write(path+META_PREFIX, w.sidecarFormat.encode(meta), perm); err != nil {
+			return nil, webdavError(err)
+		}
+	}
+
+	return newPipeWriter(func(r io.Reader) error {
+		return w.writeStream(path, r)
+	}), nil
+}
+
+// FileWriterWithContext - открывает файл на запись
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные файла
+// запись завершается и ошибка загрузки возвращается при Close
+func (w *WebDav) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.FileWriter(path, ttl, meta)
+	}
+}
+
 // GetFile - возвращает содержимое файла
 // path - путь к файлу
 func (w *WebDav) GetFile(path string) ([]byte, error) {
-	if !w.IsExist(path) {
+	if !w.skipExistCheck && !w.IsExist(path) {
 		return nil, nil
 	}
-	return w.client.Read(path)
+	data, err := w.client.Read(path)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	return data, nil
 }
 
 // GetFileWithContext - возвращает содержимое файла
@@ -173,14 +814,32 @@ func (w *WebDav) GetFileWithContext(ctx context.Context, path string) ([]byte, e
 // GetFilePartially - возвращает часть содержимого файла
 // path - путь к файлу
 // offset - смещение
-// length - длина
+// length - длина, 0 и меньше - до конца файла; часть, выходящая за конец файла, обрезается по нему
+// смещение, равное размеру файла, дает пустую часть, а смещение за концом файла -
+// пустую часть и ErrRangeNotSatisfiable
 func (w *WebDav) GetFilePartially(path string, offset, length int64) ([]byte, error) {
-	if !w.IsExist(path) {
-		return nil, nil
+	if !w.skipExistCheck {
+		info, err := w.client.Stat(path)
+		if err != nil || info.Size() == 0 {
+			return nil, nil
+		}
+		// сервер без поддержки Range отдает файл целиком, и gowebdav обрезает его по length,
+		// поэтому length должна быть известна заранее
+		length, err = partialLength(info.Size(), offset, length)
+		if err != nil {
+			return []byte{}, err
+		}
+		if length == 0 {
+			return []byte{}, nil
+		}
 	}
 
 	stream, err := w.client.ReadStreamRange(path, offset, length)
 	if err != nil {
+		err = webdavError(err)
+		if errors.Is(err, ErrRangeNotSatisfiable) {
+			return w.emptyPart(path, offset, err)
+		}
 		return nil, err
 	}
 	defer stream.Close()
@@ -190,19 +849,128 @@ func (w *WebDav) GetFilePartially(path string, offset, length int64) ([]byte, er
 	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return buf.Bytes(), nil
+}
+
+// emptyPart - ответ GetFilePartially на 416 сервера: смещение, равное размеру файла,
+// дает пустую часть без ошибки
+func (w *WebDav) emptyPart(path string, offset int64, err error) ([]byte, error) {
+	info, statErr := w.client.Stat(path)
+	if statErr == nil && info.Size() == offset {
+		return []byte{}, nil
+	}
+	return []byte{}, err
+}
+
+// GetFilePartiallyWithContext - возвращает часть содержимого файла
+// path - путь к файлу
+// offset - смещение
+// length - длина
+func (w *WebDav) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.GetFilePartially(path, offset, length)
+	}
+}
+
+// GetFileIfModifiedSince - возвращает содержимое файла, если он изменен после t
+// path - путь к файлу
+// t - время последней известной версии
+// bool - true, если файл изменен и содержимое прочитано
+func (w *WebDav) GetFileIfModifiedSince(path string, t time.Time) ([]byte, bool, error) {
+	info, err := w.client.Stat(path)
+	if err != nil {
+		return nil, false, webdavError(err)
+	}
+
+	if !info.ModTime().After(t) {
+		return nil, false, nil
+	}
+
+	content, err := w.client.Read(path)
+	if err != nil {
+		return nil, false, webdavError(err)
+	}
+	return content, true, nil
+}
+
+// GetFileIfModifiedSinceWithContext - возвращает содержимое файла, если он изменен после t
+// path - путь к файлу
+// t - время последней известной версии
+// bool - true, если файл изменен и содержимое прочитано
+func (w *WebDav) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, t time.Time) ([]byte, bool, error) {
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	default:
+		return w.GetFileIfModifiedSince(path, t)
+	}
+}
+
+// Peek - возвращает не более n первых байт файла
+// path - путь к файлу
+// n - количество байт
+func (w *WebDav) Peek(path string, n int) ([]byte, error) {
+	if n <= 0 {
+		if _, err := w.client.Stat(path); err != nil {
+			return nil, webdavError(err)
+		}
+		return []byte{}, nil
+	}
+
+	stream, err := w.client.ReadStreamRange(path, 0, int64(n))
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	defer drainingReadCloser{stream}.Close()
+
+	// сервер может проигнорировать Range, поэтому ограничиваем чтение сами
+	return io.ReadAll(io.LimitReader(stream, int64(n)))
+}
+
+// PeekWithContext - возвращает не более n первых байт файла
+// path - путь к файлу
+// n - количество байт
+func (w *WebDav) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.Peek(path, n)
+	}
+}
+
+// GetFileVerified - возвращает содержимое файла, проверяя контрольную сумму из метаданных
+// path - путь к файлу
+// при расхождении с sha256/md5 из метаданных возвращается ErrChecksumMismatch
+func (w *WebDav) GetFileVerified(path string) ([]byte, error) {
+	_, meta, err := w.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := w.GetFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyChecksum(content, meta, ""); err != nil {
+		return nil, err
+	}
+	return content, nil
 }
 
-// GetFilePartiallyWithContext - возвращает часть содержимого файла
+// GetFileVerifiedWithContext - возвращает содержимое файла, проверяя контрольную сумму из метаданных
 // path - путь к файлу
-// offset - смещение
-// length - длина
-func (w *WebDav) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+// при расхождении с sha256/md5 из метаданных возвращается ErrChecksumMismatch
+func (w *WebDav) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
-		return w.GetFilePartially(path, offset, length)
+		return w.GetFileVerified(path)
 	}
 }
 
@@ -212,10 +980,10 @@ func (w *WebDav) GetFilePartiallyWithContext(ctx context.Context, path string, o
 // length - длина
 func (w *WebDav) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
 	reader, err := w.client.ReadStreamRange(path, offset, length)
-	if err != nil && gowebdav.IsErrNotFound(err) {
-		return nil, ErrFileNotFound
+	if err != nil {
+		return nil, webdavError(err)
 	}
-	return reader, err
+	return drainingReadCloser{reader}, nil
 }
 
 // FileReaderWithContext - возвращает io.ReadCloser для чтения файла
@@ -231,15 +999,52 @@ func (w *WebDav) FileReaderWithContext(ctx context.Context, path string, offset,
 	}
 }
 
+// MultiReader - поток, в котором файлы идут один за другим в порядке paths
+// paths - пути к файлам
+// Файлы открываются по очереди, по мере чтения; отсутствующий файл приводит
+// к ошибке Read с его путем
+func (w *WebDav) MultiReader(paths []string) (io.ReadCloser, error) {
+	return w.MultiReaderWithContext(context.Background(), paths)
+}
+
+// MultiReaderWithContext - поток, в котором файлы идут один за другим в порядке paths
+// paths - пути к файлам
+func (w *WebDav) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, w, paths)
+}
+
+// WriteTo - записывает содержимое файла в w
+// path - путь к файлу
+// int64 - количество записанных байт
+func (w *WebDav) WriteTo(path string, dst io.Writer) (int64, error) {
+	stream, err := w.client.ReadStream(path)
+	if err != nil {
+		return 0, webdavError(err)
+	}
+	// при ошибке записи в dst тело дочитывается, чтобы переиспользовать соединение
+	defer drainingReadCloser{stream}.Close()
+
+	return io.Copy(dst, stream)
+}
+
+// WriteToWithContext - записывает содержимое файла в w
+// path - путь к файлу
+// int64 - количество записанных байт
+func (w *WebDav) WriteToWithContext(ctx context.Context, path string, dst io.Writer) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+		return w.WriteTo(path, dst)
+	}
+}
+
 // RemoveFile - удаляет файл
 // path - путь к файлу
 func (w *WebDav) RemoveFile(path string) error {
 	w.client.Remove(path + META_PREFIX)
 	err := w.client.Remove(path)
-	if err != nil && gowebdav.IsErrNotFound(err) {
-		return ErrFileNotFound
-	}
-	return err
+	return webdavError(err)
 }
 
 // RemoveFileWithContext - удаляет файл
@@ -253,17 +1058,69 @@ func (w *WebDav) RemoveFileWithContext(ctx context.Context, path string) error {
 	}
 }
 
+// RemoveFileIfMatch - удаляет файл, только если он не изменился
+// path - путь к файлу
+// etag - ETag из StatObject; если сервер не отдает ETag, он строится из времени изменения и размера
+// bool - true, если файл удален; отсутствующий файл не удаляется и не считается ошибкой,
+// измененный не удаляется и возвращает ErrConflict
+// сравнение и удаление выполняются под блокировкой этого хранилища;
+// записи других клиентов сервера блокировкой не учитываются
+func (w *WebDav) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	w.removeMu.Lock()
+	defer w.removeMu.Unlock()
+
+	obj, err := w.StatObject(path)
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if obj.ETag != strings.Trim(etag, `"`) {
+		return false, fmt.Errorf("%w: %s", ErrConflict, path)
+	}
+
+	if err := w.RemoveFile(path); err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoveFileIfMatchWithContext - удаляет файл, только если он не изменился
+// path - путь к файлу
+// etag - ETag из StatObject
+func (w *WebDav) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+		return w.RemoveFileIfMatch(path, etag)
+	}
+}
+
 // Stat - возвращает информацию о файле и метаданные
 // path - путь к файлу
+// для отсутствующего файла возвращается ошибка, оборачивающая ErrFileNotFound
 func (w *WebDav) Stat(path string) (os.FileInfo, map[string]string, error) {
-	info, err := w.client.Stat(path)
+	info, meta, err := w.stat(path)
 	if err != nil {
-		if gowebdav.IsErrNotFound(err) {
-			return nil, nil, ErrFileNotFound
-		}
 		return nil, nil, err
 	}
 
+	meta, _ = splitCacheControl(meta)
+	return info, meta, nil
+}
+
+// stat - возвращает информацию о файле и содержимое мета-файла вместе со служебными ключами
+func (w *WebDav) stat(path string) (os.FileInfo, map[string]string, error) {
+	info, err := w.client.Stat(path)
+	if err != nil {
+		return nil, nil, webdavError(err)
+	}
+
 	isExist := w.IsExist(path + META_PREFIX)
 	if !isExist {
 		return info, nil, nil
@@ -271,7 +1128,7 @@ func (w *WebDav) Stat(path string) (os.FileInfo, map[string]string, error) {
 
 	meta, err := w.client.Read(path + META_PREFIX)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, webdavError(err)
 	}
 
 	return info, bytes2Meta(meta), nil
@@ -288,13 +1145,332 @@ func (w *WebDav) StatWithContext(ctx context.Context, path string) (os.FileInfo,
 	}
 }
 
+// StatLite - возвращает информацию о файле одним PROPFIND, без чтения мета-файла
+// path - путь к файлу
+func (w *WebDav) StatLite(path string) (os.FileInfo, error) {
+	info, err := w.client.Stat(path)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	return info, nil
+}
+
+// StatLiteWithContext - возвращает информацию о файле одним PROPFIND, без чтения мета-файла
+// path - путь к файлу
+func (w *WebDav) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.StatLite(path)
+	}
+}
+
+// Lstat - возвращает информацию о файле и метаданные
+// path - путь к файлу
+// символических ссылок в WebDav нет, поэтому Lstat совпадает со Stat
+func (w *WebDav) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	return w.Stat(path)
+}
+
+// LstatWithContext - возвращает информацию о файле и метаданные
+// path - путь к файлу
+func (w *WebDav) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	return w.StatWithContext(ctx, path)
+}
+
+// Symlink - символические ссылки в WebDav не поддерживаются
+func (w *WebDav) Symlink(oldname, newname string) error {
+	return fmt.Errorf("webdav: symlink: %w", errors.ErrUnsupported)
+}
+
+// SymlinkWithContext - символические ссылки в WebDav не поддерживаются
+func (w *WebDav) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	return w.Symlink(oldname, newname)
+}
+
+// StatObject - возвращает полную информацию о файле
+// path - путь к файлу
+// если сервер не отдает ETag, он строится из времени изменения и размера, как в Local
+func (w *WebDav) StatObject(path string) (ObjectInfo, error) {
+	info, meta, err := w.stat(path)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	contentType, expires := meta[metaContentType], sidecarExpires(meta)
+	meta, cacheControl := splitCacheControl(meta)
+
+	obj := ObjectInfo{
+		Name:         info.Name(),
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		IsDir:        info.IsDir(),
+		Meta:         meta,
+		CacheControl: cacheControl,
+		Expires:      expires,
+	}
+
+	// gowebdav.File отдает свойства из PROPFIND
+	if f, ok := info.(interface {
+		ContentType() string
+		ETag() string
+	}); ok {
+		obj.ContentType = f.ContentType()
+		obj.ETag = strings.Trim(f.ETag(), `"`)
+	}
+	// тип из мета-файла задан при записи и точнее типа, который вывел сервер
+	if contentType != "" {
+		obj.ContentType = contentType
+	}
+	if obj.ETag == "" && !obj.IsDir {
+		obj.ETag = localETag(info)
+	}
+
+	return obj, nil
+}
+
+// StatObjectWithContext - возвращает полную информацию о файле
+// path - путь к файлу
+func (w *WebDav) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	select {
+	case <-ctx.Done():
+		return ObjectInfo{}, ctx.Err()
+	default:
+		return w.StatObject(path)
+	}
+}
+
+// PublicURL - возвращает постоянный URL файла
+// path - путь к файлу
+// URL строится от PublicBaseURL, а без него от WebDavHost; учетные данные в URL не попадают
+func (w *WebDav) PublicURL(path string) (string, error) {
+	return joinURL(w.publicBaseURL, path), nil
+}
+
+// Latest - возвращает самый новый по времени изменения файл директории
+// path - путь к директории
+// мета-файлы и поддиректории не учитываются
+func (w *WebDav) Latest(path string) (os.FileInfo, error) {
+	files, err := w.client.ReadDir(path)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+
+	var latest os.FileInfo
+	for _, file := range files {
+		if file.IsDir() || strings.HasSuffix(file.Name(), META_PREFIX) {
+			continue
+		}
+		if latest == nil || file.ModTime().After(latest.ModTime()) {
+			latest = file
+		}
+	}
+
+	if latest == nil {
+		return nil, ErrFileNotFound
+	}
+	return latest, nil
+}
+
+// LatestWithContext - возвращает самый новый по времени изменения файл директории
+// path - путь к директории
+// мета-файлы и поддиректории не учитываются
+func (w *WebDav) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.Latest(path)
+	}
+}
+
+// IsEmpty - проверяет, что в директории нет файлов и поддиректорий
+// path - путь к директории
+// мета-файлы не учитываются
+func (w *WebDav) IsEmpty(path string) (bool, error) {
+	files, err := w.client.ReadDir(path)
+	if err != nil {
+		return false, webdavError(err)
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), META_PREFIX) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// IsEmptyWithContext - проверяет, что в директории нет файлов и поддиректорий
+// path - путь к директории
+// мета-файлы не учитываются
+func (w *WebDav) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+		return w.IsEmpty(path)
+	}
+}
+
+// ListDirChan - отдает файлы директории в канал
+// path - путь к директории
+// мета-файлы не отдаются; канал закрывается после последнего файла или ошибки
+// PROPFIND возвращает директорию одним ответом, поэтому в канал отдается уже прочитанный список
+// канал нужно дочитать до конца, иначе используйте ListDirChanWithContext и отменяйте контекст
+func (w *WebDav) ListDirChan(path string) <-chan DirEntry {
+	return w.ListDirChanWithContext(context.Background(), path)
+}
+
+// ListDirChanWithContext - отдает файлы директории в канал
+// path - путь к директории
+// после отмены контекста листинг прерывается и канал закрывается
+func (w *WebDav) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	ch := make(chan DirEntry)
+
+	go func() {
+		defer close(ch)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		files, err := w.client.ReadDir(path)
+		if err != nil {
+			sendDirEntry(ctx, ch, DirEntry{Err: webdavError(err)})
+			return
+		}
+
+		for _, file := range files {
+			if strings.HasSuffix(file.Name(), META_PREFIX) {
+				continue
+			}
+			if !sendDirEntry(ctx, ch, DirEntry{Info: file}) {
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// ArchiveDir - пишет в w архив файлов директории со всеми поддиректориями
+// path - путь к директории
+// dst - куда писать архив
+// format - формат архива
+// пути в архиве относительны path, время изменения файлов сохраняется
+func (w *WebDav) ArchiveDir(path string, dst io.Writer, format ArchiveFormat) error {
+	return w.ArchiveDirWithContext(context.Background(), path, dst, format)
+}
+
+// ArchiveDirWithContext - пишет в w архив файлов директории со всеми поддиректориями
+// path - путь к директории
+// dst - куда писать архив
+// format - формат архива
+func (w *WebDav) ArchiveDirWithContext(ctx context.Context, path string, dst io.Writer, format ArchiveFormat) error {
+	return archiveDir(ctx, w, path, dst, format)
+}
+
+// ExtractArchive - распаковывает архив в директорию
+// r - поток архива
+// path - путь к директории, куда распаковывается архив
+// format - формат архива
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey
+func (w *WebDav) ExtractArchive(r io.Reader, path string, format ArchiveFormat) error {
+	return w.ExtractArchiveWithContext(context.Background(), r, path, format)
+}
+
+// ExtractArchiveWithContext - распаковывает архив в директорию
+// r - поток архива
+// path - путь к директории, куда распаковывается архив
+// format - формат архива
+// записи с абсолютным путем или с ".." отклоняются с ErrInvalidKey
+func (w *WebDav) ExtractArchiveWithContext(ctx context.Context, r io.Reader, path string, format ArchiveFormat) error {
+	return extractArchive(ctx, w, r, path, format, extractTarget{mkdir: true})
+}
+
+// Manifest - манифест директории со всеми поддиректориями: путь, размер и контрольная сумма каждого файла
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+// записи отсортированы по пути, мета-файлы не включаются
+func (w *WebDav) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return w.ManifestWithContext(context.Background(), path, algo)
+}
+
+// ManifestWithContext - манифест директории со всеми поддиректориями: путь, размер и контрольная сумма каждого файла
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+func (w *WebDav) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return manifest(ctx, w, path, algo)
+}
+
+// BlockChecksums - контрольные суммы блоков файла для rsync-подобной синхронизации
+// path - путь к файлу
+// blockSize - размер блока в байтах
+// файл читается одним потоком FileReader; разница двух списков - BlockDiff,
+// применение - ApplyBlockPatch
+func (w *WebDav) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return w.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+// BlockChecksumsWithContext - контрольные суммы блоков файла для rsync-подобной синхронизации
+// path - путь к файлу
+// blockSize - размер блока в байтах
+func (w *WebDav) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, w, path, blockSize)
+}
+
+// ListModifiedSince - файлы директории со всеми поддиректориями, измененные не раньше since
+// path - путь к директории
+// since - момент времени
+// Name() результата - путь файла относительно path, мета-файлы не включаются
+func (w *WebDav) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return w.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+// ListModifiedSinceWithContext - файлы директории со всеми поддиректориями, измененные не раньше since
+// path - путь к директории
+// since - момент времени
+func (w *WebDav) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	return listModifiedSince(ctx, w, path, since)
+}
+
+// ListDirDepth - файлы и поддиректории директории не глубже maxDepth уровней
+// path - путь к директории
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+// Name() результата - путь относительно path, мета-файлы не включаются
+func (w *WebDav) ListDirDepth(path string, maxDepth int) ([]os.FileInfo, error) {
+	return w.ListDirDepthWithContext(context.Background(), path, maxDepth)
+}
+
+// ListDirDepthWithContext - файлы и поддиректории директории не глубже maxDepth уровней
+// path - путь к директории
+// maxDepth - 1 - только непосредственные потомки, 0 и меньше - без ограничения
+func (w *WebDav) ListDirDepthWithContext(ctx context.Context, path string, maxDepth int) ([]os.FileInfo, error) {
+	return listDirDepth(ctx, w, path, maxDepth)
+}
+
+// ListMeta - метаданные всех файлов директории со всеми поддиректориями
+// path - путь к директории
+// ключ результата - путь файла относительно path, мета-файлы не включаются
+// метаданные читаются из мета-файлов
+func (w *WebDav) ListMeta(path string) (map[string]map[string]string, error) {
+	return w.ListMetaWithContext(context.Background(), path)
+}
+
+// ListMetaWithContext - метаданные всех файлов директории со всеми поддиректориями
+// path - путь к директории
+func (w *WebDav) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	return listMeta(ctx, w, path)
+}
+
 // ClearDir - очищает директорию
 // path - путь к директории
 func (w *WebDav) ClearDir(path string) error {
 	files, _ := w.client.ReadDir(path)
 	for _, file := range files {
 		if err := w.client.Remove(path + "/" + file.Name()); err != nil {
-			return err
+			return webdavError(err)
 		}
 	}
 	return nil
@@ -311,10 +1487,119 @@ func (w *WebDav) ClearDirWithContext(ctx context.Context, path string) error {
 	}
 }
 
+// GarbageCollectMeta - удаляет мета-файлы, у которых нет основного файла
+// path - путь к директории, обходится со всеми поддиректориями
+// int - количество удаленных мета-файлов
+// повторный запуск безопасен: мета-файлы существующих файлов не трогаются
+func (w *WebDav) GarbageCollectMeta(path string) (int, error) {
+	return w.GarbageCollectMetaWithContext(context.Background(), path)
+}
+
+// GarbageCollectMetaWithContext - удаляет мета-файлы, у которых нет основного файла
+// path - путь к директории, обходится со всеми поддиректориями
+// int - количество удаленных мета-файлов
+func (w *WebDav) GarbageCollectMetaWithContext(ctx context.Context, path string) (int, error) {
+	files, err := w.client.ReadDir(path)
+	if err != nil {
+		return 0, webdavError(err)
+	}
+
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[file.Name()] = true
+	}
+
+	removed := 0
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		name := file.Name()
+		if file.IsDir() {
+			n, err := w.GarbageCollectMetaWithContext(ctx, joinKey(path, name))
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+			continue
+		}
+		if !strings.HasSuffix(name, META_PREFIX) || names[strings.TrimSuffix(name, META_PREFIX)] {
+			continue
+		}
+
+		if err := w.client.Remove(joinKey(path, name)); err != nil && !gowebdav.IsErrNotFound(err) {
+			return removed, webdavError(err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// StartExpiryScavenger - запускает фоновое удаление файлов с истекшим временем жизни
+// root - путь к директории, обходится со всеми поддиректориями
+// interval - период обхода
+// TTL, переданный при записи, хранится в мета-файле, но сам по себе файл не удаляет;
+// первый обход выполняется сразу, и его ошибка возвращается, дальше обход повторяется
+// каждые interval до отмены ctx. Файл, перезаписанный во время обхода, не удаляется
+func (w *WebDav) StartExpiryScavenger(ctx context.Context, root string, interval time.Duration) error {
+	return startExpiryScavenger(ctx, w, root, interval)
+}
+
+// ListDir - возвращает список файлов директории без мета-файлов
+// path - путь к директории
+// withMeta - прочитать мета-файлы и приложить метаданные к элементам списка
+func (w *WebDav) ListDir(path string, withMeta bool) ([]FileEntry, error) {
+	files, err := w.client.ReadDir(path)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+
+	sidecars := make(map[string]bool)
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), META_PREFIX) {
+			sidecars[file.Name()] = true
+		}
+	}
+
+	entries := make([]FileEntry, 0, len(files)-len(sidecars))
+	for _, file := range files {
+		if sidecars[file.Name()] {
+			continue
+		}
+
+		entry := FileEntry{Info: file}
+		// мета-файл читаем только если он есть в списке, без лишнего Stat
+		if withMeta && sidecars[file.Name()+META_PREFIX] {
+			meta, err := w.client.Read(strings.TrimSuffix(path, "/") + "/" + file.Name() + META_PREFIX)
+			if err != nil {
+				return nil, webdavError(err)
+			}
+			entry.Meta, _ = splitCacheControl(bytes2Meta(meta))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ListDirWithContext - возвращает список файлов директории без мета-файлов
+// path - путь к директории
+// withMeta - прочитать мета-файлы и приложить метаданные к элементам списка
+func (w *WebDav) ListDirWithContext(ctx context.Context, path string, withMeta bool) ([]FileEntry, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.ListDir(path, withMeta)
+	}
+}
+
 // MkdirAll - создает директорию
 // path - путь к директории
 func (w *WebDav) MkdirAll(path string) error {
-	return w.client.MkdirAll(path, perm)
+	return webdavError(w.client.MkdirAll(path, perm))
 }
 
 // MkdirAllWithContext - создает директорию
@@ -332,12 +1617,13 @@ func (w *WebDav) MkdirAllWithContext(ctx context.Context, path string) error {
 // path - путь к файлу
 // data - данные
 // meta - метаданные
+// документ больше 5MB кодируется и записывается потоком, не занимая память целиком
 func (w *WebDav) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
-	content, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return err
-	}
-	return w.CreateFile(path, content, ttl, meta)
+	return createJsonFile(data, func(content []byte) error {
+		return w.CreateFile(path, content, ttl, meta)
+	}, func(r io.Reader) error {
+		return writeJsonStream(w, path, r, ttl, meta)
+	})
 }
 
 // CreateJsonFileWithContext - создает файл с данными в формате JSON
@@ -378,3 +1664,26 @@ func (w *WebDav) GetJsonFileWithContext(ctx context.Context, path string, file i
 		return w.GetJsonFile(path, file)
 	}
 }
+
+// GetJsonMap - возвращает содержимое JSON файла в виде map
+// path - путь к файлу
+// числа возвращаются как json.Number
+func (w *WebDav) GetJsonMap(path string) (map[string]interface{}, error) {
+	content, err := w.client.Read(path)
+	if err != nil {
+		return nil, webdavError(err)
+	}
+	return decodeJsonMap(content)
+}
+
+// GetJsonMapWithContext - возвращает содержимое JSON файла в виде map
+// path - путь к файлу
+// числа возвращаются как json.Number
+func (w *WebDav) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+		return w.GetJsonMap(path)
+	}
+}
@@ -0,0 +1,700 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/studio-b12/gowebdav"
+	"golang.org/x/net/webdav"
+)
+
+// newTestWebDav - WebDav поверх тестового сервера с обработчиком handler
+func newTestWebDav(t *testing.T, cfg WebDavConfig, handler http.Handler) *WebDav {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg.WebDavHost = srv.URL
+	cfg.SkipValidation = true
+	s, err := NewWebDav(cfg)
+	if err != nil {
+		t.Fatalf("NewWebDav: %v", err)
+	}
+	return s.(*WebDav)
+}
+
+// newTestWebDavDir - WebDav поверх сервера golang.org/x/net/webdav,
+// который хранит файлы во временной директории root
+func newTestWebDavDir(t *testing.T, cfg WebDavConfig) (w *WebDav, root string) {
+	t.Helper()
+	root = t.TempDir()
+	return newTestWebDav(t, cfg, &webdav.Handler{
+		FileSystem: webdav.Dir(root),
+		LockSystem: webdav.NewMemLS(),
+	}), root
+}
+
+// statusHandler - сервер, отвечающий status на любой запрос
+func statusHandler(status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+}
+
+func TestWebDavStatusErrors(t *testing.T) {
+	create := func(w *WebDav) error { return w.CreateFile("dir/a.txt", []byte("data"), nil, nil) }
+	get := func(w *WebDav) error {
+		_, err := w.GetFile("dir/a.txt")
+		return err
+	}
+	stat := func(w *WebDav) error {
+		_, _, err := w.Stat("dir/a.txt")
+		return err
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		call   func(*WebDav) error
+		want   error
+	}{
+		{"507 on create", http.StatusInsufficientStorage, create, ErrInsufficientStorage},
+		{"404 on get", http.StatusNotFound, get, ErrFileNotFound},
+		{"404 on stat", http.StatusNotFound, stat, ErrFileNotFound},
+		{"401 on create", http.StatusUnauthorized, create, ErrPermission},
+		{"403 on create", http.StatusForbidden, create, ErrPermission},
+		{"403 on get", http.StatusForbidden, get, ErrPermission},
+		{"405 on create", http.StatusMethodNotAllowed, create, ErrAlreadyExists},
+		{"412 on create", http.StatusPreconditionFailed, create, ErrAlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTestWebDav(t, WebDavConfig{SkipExistCheck: true}, statusHandler(tt.status))
+
+			err := tt.call(w)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			// исходная ошибка gowebdav сохраняется
+			var pathErr *os.PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("error %v does not wrap *os.PathError", err)
+			}
+			if status, ok := pathErr.Err.(gowebdav.StatusError); !ok || status.Status != tt.status {
+				t.Errorf("wrapped status = %v, want %d", pathErr.Err, tt.status)
+			}
+		})
+	}
+}
+
+func TestWebDavErrorKeepsOtherErrors(t *testing.T) {
+	orig := gowebdav.NewPathError("Write", "a.txt", http.StatusInternalServerError)
+	if err := webdavError(orig); err != orig {
+		t.Errorf("webdavError(500) = %v, want the original error", err)
+	}
+	if err := webdavError(nil); err != nil {
+		t.Errorf("webdavError(nil) = %v, want nil", err)
+	}
+}
+
+// authRecorder - обработчик, пропускающий к next только запросы, прошедшие check,
+// остальным отвечает 401 с заголовком WWW-Authenticate challenge
+type authRecorder struct {
+	check     func(r *http.Request) bool
+	challenge string
+	next      http.Handler
+
+	mu      sync.Mutex
+	headers []string
+}
+
+func (a *authRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	a.headers = append(a.headers, r.Header.Get("Authorization"))
+	a.mu.Unlock()
+
+	if !a.check(r) {
+		w.Header().Set("WWW-Authenticate", a.challenge)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	a.next.ServeHTTP(w, r)
+}
+
+// authorizations - заголовки Authorization всех запросов
+func (a *authRecorder) authorizations() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.headers...)
+}
+
+var digestParam = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+// digestParams - параметры заголовка Authorization: Digest
+func digestParams(header string) map[string]string {
+	params := map[string]string{}
+	for _, m := range digestParam.FindAllStringSubmatch(strings.TrimPrefix(header, "Digest "), -1) {
+		params[m[1]] = m[2] + m[3]
+	}
+	return params
+}
+
+// validDigest - проверяет ответ Digest (RFC 7616, MD5, qop=auth) для user/pass
+func validDigest(r *http.Request, realm, nonce, user, pass string) bool {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Digest ") {
+		return false
+	}
+	p := digestParams(header)
+	if p["username"] != user || p["realm"] != realm || p["nonce"] != nonce || p["qop"] != "auth" {
+		return false
+	}
+	md5Of := func(s string) string { return md5Hex([]byte(s)) }
+	ha1 := md5Of(user + ":" + realm + ":" + pass)
+	ha2 := md5Of(r.Method + ":" + p["uri"])
+	return p["response"] == md5Of(strings.Join([]string{ha1, nonce, p["nc"], p["cnonce"], "auth", ha2}, ":"))
+}
+
+func TestWebDavAuth(t *testing.T) {
+	const (
+		realm = "store"
+		nonce = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+	)
+	files := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+
+	tests := []struct {
+		name      string
+		cfg       WebDavConfig
+		check     func(r *http.Request) bool
+		challenge string
+		// preemptive - учетные данные отправляются с первым запросом, без ответа 401
+		preemptive bool
+		wantPrefix string
+		wantErr    error
+	}{
+		{
+			name: "basic",
+			cfg:  WebDavConfig{AuthType: WebDavAuthBasic, WebDavUser: "user", WebDavPass: "secret"},
+			check: func(r *http.Request) bool {
+				user, pass, ok := r.BasicAuth()
+				return ok && user == "user" && pass == "secret"
+			},
+			challenge:  `Basic realm="store"`,
+			preemptive: true,
+			wantPrefix: "Basic ",
+		},
+		{
+			name: "digest",
+			cfg:  WebDavConfig{AuthType: WebDavAuthDigest, WebDavUser: "user", WebDavPass: "secret"},
+			check: func(r *http.Request) bool {
+				return validDigest(r, realm, nonce, "user", "secret")
+			},
+			challenge:  `Digest realm="store", nonce="` + nonce + `", qop="auth", algorithm=MD5`,
+			wantPrefix: "Digest ",
+		},
+		{
+			name: "digest with a wrong password",
+			cfg:  WebDavConfig{AuthType: WebDavAuthDigest, WebDavUser: "user", WebDavPass: "wrong"},
+			check: func(r *http.Request) bool {
+				return validDigest(r, realm, nonce, "user", "secret")
+			},
+			challenge: `Digest realm="store", nonce="` + nonce + `", qop="auth", algorithm=MD5`,
+			wantErr:   ErrPermission,
+		},
+		{
+			name: "bearer",
+			cfg:  WebDavConfig{AuthType: WebDavAuthBearer, WebDavToken: "tok-123"},
+			check: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer tok-123"
+			},
+			challenge:  `Bearer realm="store"`,
+			preemptive: true,
+			wantPrefix: "Bearer tok-123",
+		},
+		{
+			name: "bearer with a wrong token",
+			cfg:  WebDavConfig{AuthType: WebDavAuthBearer, WebDavToken: "expired"},
+			check: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer tok-123"
+			},
+			challenge: `Bearer realm="store"`,
+			wantErr:   ErrPermission,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &authRecorder{check: tt.check, challenge: tt.challenge, next: files}
+			w := newTestWebDav(t, tt.cfg, rec)
+
+			err := w.CreateFile("/"+strings.ReplaceAll(tt.name, " ", "-")+".txt", []byte("data"), nil, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateFile error = %v, want %v", err, tt.wantErr)
+			}
+			headers := rec.authorizations()
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tt.preemptive && headers[0] == "" {
+				t.Errorf("first request has no Authorization, want preemptive %s", tt.wantPrefix)
+			}
+			if !tt.preemptive && headers[0] != "" {
+				t.Errorf("first request Authorization = %q, want none before the challenge", headers[0])
+			}
+			last := headers[len(headers)-1]
+			if !strings.HasPrefix(last, tt.wantPrefix) {
+				t.Errorf("Authorization = %q, want prefix %q", last, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+// requestLog - обработчик, запоминающий методы запросов перед передачей next
+type requestLog struct {
+	next http.Handler
+
+	mu      sync.Mutex
+	methods []string
+}
+
+func (l *requestLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	l.methods = append(l.methods, r.Method)
+	l.mu.Unlock()
+	l.next.ServeHTTP(w, r)
+}
+
+// take - методы запросов с прошлого вызова
+func (l *requestLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	methods := l.methods
+	l.methods = nil
+	return methods
+}
+
+func TestWebDavSkipExistCheckRoundTrips(t *testing.T) {
+	getFile := func(w *WebDav, path string) ([]byte, error) { return w.GetFile(path) }
+	getPart := func(w *WebDav, path string) ([]byte, error) { return w.GetFilePartially(path, 2, 3) }
+
+	tests := []struct {
+		name string
+		skip bool
+		read func(w *WebDav, path string) ([]byte, error)
+		want string
+		// wantMethods - запросы чтения существующего файла
+		wantMethods []string
+	}{
+		{"GetFile checked", false, getFile, "0123456789", []string{"PROPFIND", "GET"}},
+		{"GetFile fast path", true, getFile, "0123456789", []string{"GET"}},
+		{"GetFilePartially checked", false, getPart, "234", []string{"PROPFIND", "GET"}},
+		{"GetFilePartially fast path", true, getPart, "234", []string{"GET"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if err := os.WriteFile(root+"/a.txt", []byte("0123456789"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			log := &requestLog{next: &webdav.Handler{FileSystem: webdav.Dir(root), LockSystem: webdav.NewMemLS()}}
+			w := newTestWebDav(t, WebDavConfig{SkipExistCheck: tt.skip}, log)
+			// первый запрос клиента повторяется при согласовании авторизации, поэтому не считается
+			w.IsExist("a.txt")
+			log.take()
+
+			got, err := tt.read(w, "a.txt")
+			if err != nil || string(got) != tt.want {
+				t.Fatalf("read = %q, %v; want %q", got, err, tt.want)
+			}
+			if methods := log.take(); !reflect.DeepEqual(methods, tt.wantMethods) {
+				t.Errorf("requests = %v, want %v", methods, tt.wantMethods)
+			}
+
+			// отсутствующий файл: без проверки 404 чтения - ErrFileNotFound за один запрос,
+			// с проверкой - прежний ответ nil, nil без чтения
+			got, err = tt.read(w, "missing.txt")
+			methods := log.take()
+			if tt.skip {
+				if !errors.Is(err, ErrFileNotFound) || len(methods) != 1 {
+					t.Errorf("missing file = %q, %v after %v; want ErrFileNotFound in one request", got, err, methods)
+				}
+			} else if got != nil || err != nil || !reflect.DeepEqual(methods, []string{"PROPFIND"}) {
+				t.Errorf("missing file = %q, %v after %v; want nil, nil after one PROPFIND", got, err, methods)
+			}
+		})
+	}
+}
+
+func TestWebDavListDir(t *testing.T) {
+	formats := []struct {
+		name   string
+		format SidecarFormat
+	}{{"key-value", SidecarKeyValue}, {"json", SidecarJSON}}
+
+	for _, f := range formats {
+		for _, withMeta := range []bool{false, true} {
+			format := f.format
+			t.Run(fmt.Sprintf("%s sidecars, withMeta %v", f.name, withMeta), func(t *testing.T) {
+				root := t.TempDir()
+				log := &requestLog{next: &webdav.Handler{FileSystem: webdav.Dir(root), LockSystem: webdav.NewMemLS()}}
+				w := newTestWebDav(t, WebDavConfig{SidecarFormat: format}, log)
+
+				if err := w.MkdirAll("dir/sub"); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.CreateFile("dir/a.txt", []byte("a"), nil, map[string]string{"Owner": "alice", "Cache-Control": "no-cache"}); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.CreateFile("dir/b.txt", []byte("bb"), nil, nil); err != nil {
+					t.Fatal(err)
+				}
+				// мета-файл без своего файла в список не попадает
+				if err := os.WriteFile(root+"/dir/gone.txt"+META_PREFIX, []byte("Owner=bob\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				log.take()
+
+				entries, err := w.ListDir("dir", withMeta)
+				if err != nil {
+					t.Fatalf("ListDir: %v", err)
+				}
+				got := map[string]map[string]string{}
+				for _, entry := range entries {
+					got[entry.Info.Name()] = entry.Meta
+				}
+				want := map[string]map[string]string{"a.txt": nil, "b.txt": nil, "sub": nil}
+				if withMeta {
+					want["a.txt"] = map[string]string{"Owner": "alice"}
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("ListDir = %v, want %v", got, want)
+				}
+
+				// мета-файлы читаются только для файлов, у которых они есть
+				wantMethods := []string{"PROPFIND"}
+				if withMeta {
+					wantMethods = append(wantMethods, "GET")
+				}
+				if methods := log.take(); !reflect.DeepEqual(methods, wantMethods) {
+					t.Errorf("requests = %v, want %v", methods, wantMethods)
+				}
+			})
+		}
+	}
+
+	t.Run("missing dir", func(t *testing.T) {
+		w, _ := newTestWebDavDir(t, WebDavConfig{})
+		if _, err := w.ListDir("missing", true); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("ListDir = %v, want ErrFileNotFound", err)
+		}
+	})
+}
+
+// newCountingWebDavDir - WebDav над временной директорией и счетчик соединений, открытых сервером
+func newCountingWebDavDir(t *testing.T, cfg WebDavConfig) (*WebDav, string, *atomic.Int32) {
+	t.Helper()
+	root := t.TempDir()
+	dials := &atomic.Int32{}
+	srv := httptest.NewUnstartedServer(&webdav.Handler{
+		FileSystem: webdav.Dir(root),
+		LockSystem: webdav.NewMemLS(),
+	})
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	cfg.WebDavHost = srv.URL
+	cfg.SkipValidation = true
+	s, err := NewWebDav(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*WebDav), root, dials
+}
+
+// readConcurrently - workers потоков по reads раз читают path: недочитанный
+// FileReader, Peek и WriteTo; возвращает первую ошибку
+func readConcurrently(w *WebDav, path string, workers, reads int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*reads*3)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < reads; j++ {
+				// поток закрывается недочитанным: остаток дочитывается, соединение возвращается в пул
+				stream, err := w.FileReader(path, 0, 0)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if _, err := io.ReadFull(stream, make([]byte, 10)); err != nil {
+					errs <- err
+				}
+				stream.Close()
+
+				if _, err := w.Peek(path, 4); err != nil {
+					errs <- err
+				}
+				if _, err := w.WriteTo(path, io.Discard); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func TestWebDavConnectionReuse(t *testing.T) {
+	const workers, reads = 8, 10
+	body := bytes.Repeat([]byte("0123456789"), 10<<10)
+
+	w, root, dials := newCountingWebDavDir(t, WebDavConfig{MaxIdleConnsPerHost: workers, IdleConnTimeout: time.Minute})
+	if err := os.WriteFile(filepath.Join(root, "a.bin"), body, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := readConcurrently(w, "a.bin", workers, reads); err != nil {
+		t.Fatal(err)
+	}
+	// 240 запросов от 8 потоков укладываются в 8 соединений; транспорт может открыть
+	// лишнее, если соединение вернулось в пул уже после начала нового dial
+	if n := dials.Load(); n > 2*workers {
+		t.Errorf("%d connections for %d requests from %d workers, want at most %d", n, workers*reads*3, workers, 2*workers)
+	}
+}
+
+func TestWebDavCaseInsensitive(t *testing.T) {
+	// newStore - WebDav поверх временной директории; сервер, как и диск, различает регистр
+	newStore := func(t *testing.T, caseInsensitive bool) (StoreIFace, string) {
+		root := t.TempDir()
+		srv := httptest.NewServer(&webdav.Handler{FileSystem: webdav.Dir(root), LockSystem: webdav.NewMemLS()})
+		t.Cleanup(srv.Close)
+		s, err := NewWebDav(WebDavConfig{WebDavHost: srv.URL, SkipValidation: true, CaseInsensitive: caseInsensitive})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s, root
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		s, root := newStore(t, true)
+		if err := s.MkdirAll("Docs"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateFile("Docs/Foo.txt", []byte("v1"), nil, map[string]string{"Owner": "bob"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{"docs/foo.txt", "DOCS/FOO.TXT", "Docs/Foo.txt"} {
+			if !s.IsExist(path) {
+				t.Errorf("IsExist(%s) = false", path)
+			}
+			got, err := s.GetFile(path)
+			if err != nil || string(got) != "v1" {
+				t.Errorf("GetFile(%s) = %q, %v; want v1", path, got, err)
+			}
+			obj, err := s.StatObject(path)
+			if err != nil || obj.Meta["Owner"] != "bob" {
+				t.Errorf("StatObject(%s) = %+v, %v; want the meta of Docs/Foo.txt", path, obj, err)
+			}
+		}
+
+		exists, err := s.ExistMany([]string{"docs/foo.txt", "Docs/FOO.txt", "docs/bar.txt"})
+		if want := map[string]bool{"docs/foo.txt": true, "Docs/FOO.txt": true, "docs/bar.txt": false}; err != nil || !reflect.DeepEqual(exists, want) {
+			t.Errorf("ExistMany = %v, %v; want %v", exists, err, want)
+		}
+
+		// перезапись другим написанием заменяет тот же объект, а не создает второй
+		if err := s.CreateFile("docs/FOO.txt", []byte("v2"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := s.GetFile("Docs/Foo.txt"); err != nil || string(got) != "v2" {
+			t.Errorf("GetFile after overwrite = %q, %v; want v2", got, err)
+		}
+		if got, want := listNames(t, s, "DOCS"), []string{"foo.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("listing = %v, want %v", got, want)
+		}
+		// на сервер уходят пути в нижнем регистре
+		if body, err := os.ReadFile(filepath.Join(root, "docs", "foo.txt")); err != nil || string(body) != "v2" {
+			t.Errorf("server file docs/foo.txt = %q, %v; want v2", body, err)
+		}
+
+		if err := s.RemoveFile("DOCS/foo.TXT"); err != nil {
+			t.Fatal(err)
+		}
+		if s.IsExist("Docs/Foo.txt") {
+			t.Error("Docs/Foo.txt exists after removing DOCS/foo.TXT")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s, _ := newStore(t, false)
+		if err := s.CreateFile("Foo.txt", []byte("upper"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if s.IsExist("foo.txt") {
+			t.Error("foo.txt exists on a case-sensitive store")
+		}
+		if err := s.CreateFile("foo.txt", []byte("lower"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := listNames(t, s, ""), []string{"Foo.txt", "foo.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("listing = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestWebDavCopyFileMeta(t *testing.T) {
+	// newStore - WebDav поверх диска; hook вызывается до обработки запроса и,
+	// вернув статус, отвечает им вместо сервера
+	newStore := func(t *testing.T, hook func(r *http.Request) int) (*WebDav, string, *[]string) {
+		root := t.TempDir()
+		dav := &webdav.Handler{FileSystem: webdav.Dir(root), LockSystem: webdav.NewMemLS()}
+		var mu sync.Mutex
+		var writes []string
+		w := newTestWebDav(t, WebDavConfig{}, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Method == "COPY" || r.Method == http.MethodPut || r.Method == http.MethodDelete {
+				mu.Lock()
+				writes = append(writes, r.Method+" "+r.URL.Path)
+				mu.Unlock()
+			}
+			if hook != nil {
+				if status := hook(r); status != 0 {
+					rw.WriteHeader(status)
+					return
+				}
+			}
+			dav.ServeHTTP(rw, r)
+		}))
+		return w, root, &writes
+	}
+	create := func(t *testing.T, w *WebDav, path, body string, meta map[string]string) {
+		t.Helper()
+		if err := w.CreateFile(path, []byte(body), nil, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	metaOf := func(t *testing.T, w *WebDav, path string) map[string]string {
+		t.Helper()
+		_, meta, err := w.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat(%s): %v", path, err)
+		}
+		return meta
+	}
+
+	t.Run("body before metadata", func(t *testing.T) {
+		w, _, writes := newStore(t, nil)
+		create(t, w, "src.txt", "data", map[string]string{"A": "1"})
+		*writes = nil
+
+		if err := w.CopyFile("src.txt", "dst.txt", nil, map[string]string{"B": "2"}); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"COPY /src.txt", "PUT /dst.txt" + META_PREFIX}; !reflect.DeepEqual(*writes, want) {
+			t.Errorf("writes = %v, want %v", *writes, want)
+		}
+		if got, want := metaOf(t, w, "dst.txt"), map[string]string{"A": "1", "B": "2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("dst meta = %v, want %v", got, want)
+		}
+		if got, want := metaOf(t, w, "src.txt"), map[string]string{"A": "1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("src meta = %v, want it unchanged %v", got, want)
+		}
+	})
+
+	t.Run("server copy overwrites the sidecar", func(t *testing.T) {
+		var root string
+		w, root, _ := newStore(t, func(r *http.Request) int {
+			// сервер при COPY сам пишет мета-файл копии
+			if r.Method == "COPY" {
+				os.WriteFile(filepath.Join(root, "dst.txt"+META_PREFIX), []byte("Stale=1"), 0644)
+			}
+			return 0
+		})
+		create(t, w, "src.txt", "data", map[string]string{"A": "1"})
+		if err := w.CopyFile("src.txt", "dst.txt", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := metaOf(t, w, "dst.txt"), map[string]string{"A": "1"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("dst meta = %v, want the source meta %v", got, want)
+		}
+	})
+
+	t.Run("stale destination metadata removed", func(t *testing.T) {
+		w, _, _ := newStore(t, nil)
+		create(t, w, "src.txt", "new", nil)
+		create(t, w, "dst.txt", "old", map[string]string{"Old": "1"})
+		if err := w.CopyFile("src.txt", "dst.txt", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := w.GetFile("dst.txt"); err != nil || string(got) != "new" {
+			t.Errorf("dst = %q, %v; want new", got, err)
+		}
+		if got := metaOf(t, w, "dst.txt"); len(got) != 0 {
+			t.Errorf("dst meta = %v, want none left from the old dst", got)
+		}
+	})
+
+	t.Run("interrupted before metadata", func(t *testing.T) {
+		w, _, _ := newStore(t, func(r *http.Request) int {
+			if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, META_PREFIX) {
+				return http.StatusInsufficientStorage
+			}
+			return 0
+		})
+		create(t, w, "src.txt", "data", nil)
+
+		err := w.CopyFile("src.txt", "dst.txt", nil, map[string]string{"B": "2"})
+		if err == nil || !strings.HasPrefix(err.Error(), "dst.txt: ") || ErrorCode(err) != CodeUnavailable {
+			t.Fatalf("CopyFile = %v, want the sidecar error naming dst.txt", err)
+		}
+		// копия без метаданных удалена, исходный файл на месте
+		if w.IsExist("dst.txt") {
+			t.Error("dst.txt left without its metadata")
+		}
+		if got, err := w.GetFile("src.txt"); err != nil || string(got) != "data" {
+			t.Errorf("src = %q, %v; want data", got, err)
+		}
+	})
+
+	t.Run("rollback fails", func(t *testing.T) {
+		w, _, _ := newStore(t, func(r *http.Request) int {
+			if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, META_PREFIX) {
+				return http.StatusInsufficientStorage
+			}
+			if r.Method == http.MethodDelete {
+				return http.StatusForbidden
+			}
+			return 0
+		})
+		create(t, w, "src.txt", "data", nil)
+
+		err := w.CopyFile("src.txt", "dst.txt", nil, map[string]string{"B": "2"})
+		if err == nil || !strings.Contains(err.Error(), "metadata not written") || !strings.Contains(err.Error(), "remove copy") {
+			t.Errorf("CopyFile = %v, want both the sidecar and the rollback errors", err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/studio-b12/gowebdav"
+)
+
+// webdavAuthorizer - авторизация gowebdav клиента по WebDavConfig.AuthType
+func webdavAuthorizer(cfg WebDavConfig) (gowebdav.Authorizer, error) {
+	switch cfg.AuthType {
+	case WebDavAuthAuto:
+		return gowebdav.NewAutoAuth(cfg.WebDavUser, cfg.WebDavPass), nil
+	case WebDavAuthBasic:
+		return gowebdav.NewPreemptiveAuth(&basicAuth{user: cfg.WebDavUser, pass: cfg.WebDavPass}), nil
+	case WebDavAuthDigest:
+		auth := gowebdav.NewEmptyAuth()
+		auth.AddAuthenticator("digest", func(c *http.Client, rs *http.Response, path string) (gowebdav.Authenticator, error) {
+			return gowebdav.NewDigestAuth(cfg.WebDavUser, cfg.WebDavPass, rs)
+		})
+		return auth, nil
+	case WebDavAuthBearer:
+		if cfg.WebDavToken == "" {
+			return nil, fmt.Errorf("webdav: bearer auth requires WebDavToken")
+		}
+		return gowebdav.NewPreemptiveAuth(&bearerAuth{token: cfg.WebDavToken}), nil
+	default:
+		return nil, fmt.Errorf("webdav: unknown auth type %d", cfg.AuthType)
+	}
+}
+
+// basicAuth - Basic авторизация без предварительного запроса без учетных данных
+type basicAuth struct {
+	user, pass string
+}
+
+func (a *basicAuth) Authorize(c *http.Client, rq *http.Request, path string) error {
+	rq.SetBasicAuth(a.user, a.pass)
+	return nil
+}
+
+func (a *basicAuth) Verify(c *http.Client, rs *http.Response, path string) (bool, error) {
+	return false, verifyAuth(rs, path)
+}
+
+func (a *basicAuth) Clone() gowebdav.Authenticator {
+	return a
+}
+
+func (a *basicAuth) Close() error {
+	return nil
+}
+
+// bearerAuth - авторизация токеном в заголовке Authorization
+type bearerAuth struct {
+	token string
+}
+
+func (a *bearerAuth) Authorize(c *http.Client, rq *http.Request, path string) error {
+	rq.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a *bearerAuth) Verify(c *http.Client, rs *http.Response, path string) (bool, error) {
+	return false, verifyAuth(rs, path)
+}
+
+func (a *bearerAuth) Clone() gowebdav.Authenticator {
+	return a
+}
+
+func (a *bearerAuth) Close() error {
+	return nil
+}
+
+// verifyAuth - 401 в ответе - ошибка авторизации (webdavError вернет ErrPermission)
+func verifyAuth(rs *http.Response, path string) error {
+	if rs.StatusCode == http.StatusUnauthorized {
+		return gowebdav.NewPathError("Authorize", path, rs.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,973 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrWriteBehindClosed - запись в закрытый WriteBehind
+var ErrWriteBehindClosed = errors.New("write-behind store is closed")
+
+// WriteBehindOptions - параметры WriteBehind
+// QueueSize - сколько записей может ждать в очереди, 0 - 1024; при полной очереди CreateFile ждет
+// Workers - сколько записей выполняется одновременно, 0 - 4
+// OnError - вызывается с ошибкой каждой неудавшейся фоновой записи
+type WriteBehindOptions struct {
+	QueueSize int
+	Workers   int
+	OnError   func(path string, err error)
+}
+
+// WriteBehind - хранилище, в котором CreateFile возвращается сразу, а запись
+// выполняется в фоне пулом воркеров.
+// Данные в очереди теряются при падении процесса: CreateFile без ошибки не означает,
+// что файл записан. Дождаться записи очереди можно через Flush, Close дожидается ее перед закрытием.
+// В очередь ставится только CreateFile. IsExist, GetFile, GetFilePartially, Peek, FileReader
+// и WriteTo читают файл, еще не записанный в фоне, из памяти; остальные методы, читающие
+// или пишущие путь (Stat, StreamToFile, FileWriter, CopyFile, MoveFile, RemoveFile и т.д.),
+// сначала дожидаются очереди, если в ней есть запись этого пути, а методы над директориями
+// (ClearDir, ListMeta, Manifest и т.д.) - всей очереди. Поэтому запись, поставленная раньше,
+// не затирает более позднюю, а чтение видит последнюю запись.
+// Записи одного пути выполняются в порядке вызова.
+type WriteBehind struct {
+	StoreIFace
+	opts   WriteBehindOptions
+	queues []chan *pendingWrite
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending map[string]*pendingWrite
+	queued  int
+	errs    []error
+	closed  bool
+}
+
+// pendingWrite - запись CreateFile, ожидающая выполнения
+type pendingWrite struct {
+	path string
+	file []byte
+	ttl  *time.Time
+	meta map[string]string
+}
+
+// WithWriteBehind - оборачивает хранилище фоновой записью CreateFile
+// s - хранилище
+// opts - параметры
+func WithWriteBehind(s StoreIFace, opts WriteBehindOptions) *WriteBehind {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+
+	b := &WriteBehind{
+		StoreIFace: s,
+		opts:       opts,
+		queues:     make([]chan *pendingWrite, opts.Workers),
+		pending:    make(map[string]*pendingWrite),
+	}
+	b.idle = sync.NewCond(&b.mu)
+
+	size := (opts.QueueSize + opts.Workers - 1) / opts.Workers
+	for i := range b.queues {
+		b.queues[i] = make(chan *pendingWrite, size)
+		b.wg.Add(1)
+		go b.worker(b.queues[i])
+	}
+	return b
+}
+
+// worker - выполняет записи своей очереди
+// путь всегда попадает в одну и ту же очередь, поэтому записи одного пути не обгоняют друг друга
+func (b *WriteBehind) worker(queue <-chan *pendingWrite) {
+	defer b.wg.Done()
+
+	for w := range queue {
+		err := b.StoreIFace.CreateFileWithContext(context.Background(), w.path, w.file, w.ttl, w.meta)
+
+		b.mu.Lock()
+		if b.pending[w.path] == w {
+			delete(b.pending, w.path)
+		}
+		if err != nil {
+			b.errs = append(b.errs, fmt.Errorf("%s: %w", w.path, err))
+		}
+		b.queued--
+		if b.queued == 0 {
+			b.idle.Broadcast()
+		}
+		b.mu.Unlock()
+
+		if err != nil && b.opts.OnError != nil {
+			b.opts.OnError(w.path, err)
+		}
+	}
+}
+
+// queue - очередь воркера для пути
+func (b *WriteBehind) queue(path string) chan *pendingWrite {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return b.queues[h.Sum32()%uint32(len(b.queues))]
+}
+
+// CreateFile - ставит запись файла в очередь и возвращается, не дожидаясь записи
+// path - путь к файлу
+// file - содержимое, копируется
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) CreateFile(path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	return b.CreateFileWithContext(context.Background(), path, file, ttl, meta)
+}
+
+// CreateFileWithContext - ставит запись файла в очередь и возвращается, не дожидаясь записи
+// path - путь к файлу
+// file - содержимое, копируется
+// ttl - время жизни
+// meta - метаданные
+// ctx ограничивает только ожидание места в очереди, но не саму запись
+func (b *WriteBehind) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	w := &pendingWrite{
+		path: path,
+		file: bytes.Clone(file),
+		ttl:  ttl,
+		meta: meta,
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrWriteBehindClosed
+	}
+	b.pending[path] = w
+	b.queued++
+	b.mu.Unlock()
+
+	select {
+	case b.queue(path) <- w:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		if b.pending[path] == w {
+			delete(b.pending, path)
+		}
+		b.queued--
+		if b.queued == 0 {
+			b.idle.Broadcast()
+		}
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Flush - дожидается выполнения всех записей, поставленных в очередь
+// error - ошибки фоновых записей с прошлого Flush
+func (b *WriteBehind) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.wait()
+	err := errors.Join(b.errs...)
+	b.errs = nil
+	return err
+}
+
+// wait - дожидается пустой очереди, вызывается под b.mu
+func (b *WriteBehind) wait() {
+	for b.queued > 0 {
+		b.idle.Wait()
+	}
+}
+
+// Close - дожидается выполнения очереди и останавливает воркеры
+// после Close CreateFile возвращает ErrWriteBehindClosed
+// error - ошибки фоновых записей с прошлого Flush
+func (b *WriteBehind) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	err := b.Flush()
+	for _, queue := range b.queues {
+		close(queue)
+	}
+	b.wg.Wait()
+	return err
+}
+
+// queuedFile - содержимое файла, ожидающего записи
+func (b *WriteBehind) queuedFile(path string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, ok := b.pending[path]
+	if !ok {
+		return nil, false
+	}
+	return w.file, true
+}
+
+// flushPaths - дожидается очереди, если в ней есть запись одного из paths
+// вызывается перед операциями, которые читают или пишут paths в обход очереди
+func (b *WriteBehind) flushPaths(paths ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, path := range paths {
+		if _, ok := b.pending[path]; ok {
+			b.wait()
+			return
+		}
+	}
+}
+
+// flushAll - дожидается всей очереди
+// вызывается перед операциями над директориями, в которых могут лежать файлы из очереди
+func (b *WriteBehind) flushAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.wait()
+}
+
+// IsExist - проверяет существование файла, файл в очереди записи существует
+// path - путь к файлу
+func (b *WriteBehind) IsExist(path string) bool {
+	if _, ok := b.queuedFile(path); ok {
+		return true
+	}
+	return b.StoreIFace.IsExist(path)
+}
+
+// ExistMany - проверяет существование файлов, файлы в очереди записи существуют
+// paths - пути к файлам
+func (b *WriteBehind) ExistMany(paths []string) (map[string]bool, error) {
+	return b.ExistManyWithContext(context.Background(), paths)
+}
+
+// ExistManyWithContext - проверяет существование файлов, файлы в очереди записи существуют
+// paths - пути к файлам
+func (b *WriteBehind) ExistManyWithContext(ctx context.Context, paths []string) (map[string]bool, error) {
+	queued := make(map[string]bool)
+	rest := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, ok := b.queuedFile(path); ok {
+			queued[path] = true
+		} else {
+			rest = append(rest, path)
+		}
+	}
+
+	exist, err := b.StoreIFace.ExistManyWithContext(ctx, rest)
+	if err != nil {
+		return nil, err
+	}
+	for path := range queued {
+		exist[path] = true
+	}
+	return exist, nil
+}
+
+// CreateFileWithOptions - записывает файл сразу, дождавшись записей path из очереди
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи
+func (b *WriteBehind) CreateFileWithOptions(path string, file []byte, opts PutOptions) error {
+	return b.CreateFileWithOptionsWithContext(context.Background(), path, file, opts)
+}
+
+// CreateFileWithOptionsWithContext - записывает файл сразу, дождавшись записей path из очереди
+// path - путь к файлу
+// file - содержимое файла
+// opts - параметры записи
+func (b *WriteBehind) CreateFileWithOptionsWithContext(ctx context.Context, path string, file []byte, opts PutOptions) error {
+	b.flushPaths(path)
+	return b.StoreIFace.CreateFileWithOptionsWithContext(ctx, path, file, opts)
+}
+
+// CreateJsonFile - записывает JSON-файл сразу, дождавшись записей path из очереди
+// path - путь к файлу
+// data - данные
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) CreateJsonFile(path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	return b.CreateJsonFileWithContext(context.Background(), path, data, ttl, meta)
+}
+
+// CreateJsonFileWithContext - записывает JSON-файл сразу, дождавшись записей path из очереди
+// path - путь к файлу
+// data - данные
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) CreateJsonFileWithContext(ctx context.Context, path string, data interface{}, ttl *time.Time, meta map[string]string) error {
+	b.flushPaths(path)
+	return b.StoreIFace.CreateJsonFileWithContext(ctx, path, data, ttl, meta)
+}
+
+// StreamToFile - записывает поток сразу, дождавшись записей path из очереди,
+// чтобы ранее поставленный CreateFile не затер новые данные
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+func (b *WriteBehind) StreamToFile(stream io.Reader, path string, ttl *time.Time) error {
+	return b.StreamToFileWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFileWithContext - записывает поток сразу, дождавшись записей path из очереди
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+func (b *WriteBehind) StreamToFileWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) error {
+	b.flushPaths(path)
+	return b.StoreIFace.StreamToFileWithContext(ctx, stream, path, ttl)
+}
+
+// StreamToFileN - записывает поток сразу, дождавшись записей path из очереди
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (b *WriteBehind) StreamToFileN(stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	return b.StreamToFileNWithContext(context.Background(), stream, path, ttl)
+}
+
+// StreamToFileNWithContext - записывает поток сразу, дождавшись записей path из очереди
+// stream - поток
+// path - путь к файлу
+// ttl - время жизни
+// int64 - количество записанных байт
+func (b *WriteBehind) StreamToFileNWithContext(ctx context.Context, stream io.Reader, path string, ttl *time.Time) (int64, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.StreamToFileNWithContext(ctx, stream, path, ttl)
+}
+
+// FileWriter - открывает файл на запись, дождавшись записей path из очереди
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) FileWriter(path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	return b.FileWriterWithContext(context.Background(), path, ttl, meta)
+}
+
+// FileWriterWithContext - открывает файл на запись, дождавшись записей path из очереди
+// path - путь к файлу
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) FileWriterWithContext(ctx context.Context, path string, ttl *time.Time, meta map[string]string) (io.WriteCloser, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.FileWriterWithContext(ctx, path, ttl, meta)
+}
+
+// GetFile - возвращает содержимое файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+func (b *WriteBehind) GetFile(path string) ([]byte, error) {
+	return b.GetFileWithContext(context.Background(), path)
+}
+
+// GetFileWithContext - возвращает содержимое файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+func (b *WriteBehind) GetFileWithContext(ctx context.Context, path string) ([]byte, error) {
+	if file, ok := b.queuedFile(path); ok {
+		return bytes.Clone(file), nil
+	}
+	return b.StoreIFace.GetFileWithContext(ctx, path)
+}
+
+// GetFilePartially - возвращает часть файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+// offset - смещение
+// length - длина, 0 - до конца файла
+func (b *WriteBehind) GetFilePartially(path string, offset, length int64) ([]byte, error) {
+	return b.GetFilePartiallyWithContext(context.Background(), path, offset, length)
+}
+
+// GetFilePartiallyWithContext - возвращает часть файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+// offset - смещение
+// length - длина, 0 - до конца файла
+func (b *WriteBehind) GetFilePartiallyWithContext(ctx context.Context, path string, offset, length int64) ([]byte, error) {
+	file, ok := b.queuedFile(path)
+	if !ok {
+		return b.StoreIFace.GetFilePartiallyWithContext(ctx, path, offset, length)
+	}
+
+	length, err := partialLength(int64(len(file)), offset, length)
+	if err != nil {
+		return []byte{}, err
+	}
+	return bytes.Clone(file[offset : offset+length]), nil
+}
+
+// Peek - возвращает первые n байт файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+// n - количество байт
+func (b *WriteBehind) Peek(path string, n int) ([]byte, error) {
+	return b.PeekWithContext(context.Background(), path, n)
+}
+
+// PeekWithContext - возвращает первые n байт файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+// n - количество байт
+func (b *WriteBehind) PeekWithContext(ctx context.Context, path string, n int) ([]byte, error) {
+	file, ok := b.queuedFile(path)
+	if !ok {
+		return b.StoreIFace.PeekWithContext(ctx, path, n)
+	}
+	return bytes.Clone(file[:min(n, len(file))]), nil
+}
+
+// GetFileIfModifiedSince - возвращает файл, если он изменен после since; дожидается записей path из очереди
+// path - путь к файлу
+// since - время
+func (b *WriteBehind) GetFileIfModifiedSince(path string, since time.Time) ([]byte, bool, error) {
+	return b.GetFileIfModifiedSinceWithContext(context.Background(), path, since)
+}
+
+// GetFileIfModifiedSinceWithContext - возвращает файл, если он изменен после since; дожидается записей path из очереди
+// path - путь к файлу
+// since - время
+func (b *WriteBehind) GetFileIfModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]byte, bool, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.GetFileIfModifiedSinceWithContext(ctx, path, since)
+}
+
+// GetFileVerified - возвращает файл с проверкой контрольной суммы; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) GetFileVerified(path string) ([]byte, error) {
+	return b.GetFileVerifiedWithContext(context.Background(), path)
+}
+
+// GetFileVerifiedWithContext - возвращает файл с проверкой контрольной суммы; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) GetFileVerifiedWithContext(ctx context.Context, path string) ([]byte, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.GetFileVerifiedWithContext(ctx, path)
+}
+
+// FileReader - открывает файл на чтение, файл из очереди записи читается из памяти
+// path - путь к файлу
+// offset - смещение
+// length - длина, 0 - до конца файла
+func (b *WriteBehind) FileReader(path string, offset, length int64) (io.ReadCloser, error) {
+	return b.FileReaderWithContext(context.Background(), path, offset, length)
+}
+
+// FileReaderWithContext - открывает файл на чтение, файл из очереди записи читается из памяти
+// path - путь к файлу
+// offset - смещение
+// length - длина, 0 - до конца файла
+func (b *WriteBehind) FileReaderWithContext(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	file, ok := b.queuedFile(path)
+	if !ok {
+		return b.StoreIFace.FileReaderWithContext(ctx, path, offset, length)
+	}
+
+	length, err := partialLength(int64(len(file)), offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(file[offset : offset+length])), nil
+}
+
+// MultiReader - поток из файлов paths, файлы из очереди записи читаются из памяти
+// paths - пути к файлам
+func (b *WriteBehind) MultiReader(paths []string) (io.ReadCloser, error) {
+	return b.MultiReaderWithContext(context.Background(), paths)
+}
+
+// MultiReaderWithContext - поток из файлов paths, файлы из очереди записи читаются из памяти
+// paths - пути к файлам
+func (b *WriteBehind) MultiReaderWithContext(ctx context.Context, paths []string) (io.ReadCloser, error) {
+	return multiReader(ctx, b, paths)
+}
+
+// WriteTo - записывает содержимое файла в w, файл из очереди записи читается из памяти
+// path - путь к файлу
+// w - получатель
+func (b *WriteBehind) WriteTo(path string, w io.Writer) (int64, error) {
+	return b.WriteToWithContext(context.Background(), path, w)
+}
+
+// WriteToWithContext - записывает содержимое файла в w, файл из очереди записи читается из памяти
+// path - путь к файлу
+// w - получатель
+func (b *WriteBehind) WriteToWithContext(ctx context.Context, path string, w io.Writer) (int64, error) {
+	file, ok := b.queuedFile(path)
+	if !ok {
+		return b.StoreIFace.WriteToWithContext(ctx, path, w)
+	}
+	return bytes.NewReader(file).WriteTo(w)
+}
+
+// GetJsonFile - читает JSON-файл в data; дожидается записей path из очереди
+// path - путь к файлу
+// data - получатель
+func (b *WriteBehind) GetJsonFile(path string, data interface{}) error {
+	return b.GetJsonFileWithContext(context.Background(), path, data)
+}
+
+// GetJsonFileWithContext - читает JSON-файл в data; дожидается записей path из очереди
+// path - путь к файлу
+// data - получатель
+func (b *WriteBehind) GetJsonFileWithContext(ctx context.Context, path string, data interface{}) error {
+	b.flushPaths(path)
+	return b.StoreIFace.GetJsonFileWithContext(ctx, path, data)
+}
+
+// GetJsonMap - читает JSON-файл в map; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) GetJsonMap(path string) (map[string]interface{}, error) {
+	return b.GetJsonMapWithContext(context.Background(), path)
+}
+
+// GetJsonMapWithContext - читает JSON-файл в map; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) GetJsonMapWithContext(ctx context.Context, path string) (map[string]interface{}, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.GetJsonMapWithContext(ctx, path)
+}
+
+// Stat - возвращает информацию о файле и метаданные; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) Stat(path string) (os.FileInfo, map[string]string, error) {
+	return b.StatWithContext(context.Background(), path)
+}
+
+// StatWithContext - возвращает информацию о файле и метаданные; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) StatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.StatWithContext(ctx, path)
+}
+
+// StatLite - возвращает информацию о файле без метаданных; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) StatLite(path string) (os.FileInfo, error) {
+	return b.StatLiteWithContext(context.Background(), path)
+}
+
+// StatLiteWithContext - возвращает информацию о файле без метаданных; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) StatLiteWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.StatLiteWithContext(ctx, path)
+}
+
+// Lstat - возвращает информацию о файле или ссылке и метаданные; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) Lstat(path string) (os.FileInfo, map[string]string, error) {
+	return b.LstatWithContext(context.Background(), path)
+}
+
+// LstatWithContext - возвращает информацию о файле или ссылке и метаданные; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) LstatWithContext(ctx context.Context, path string) (os.FileInfo, map[string]string, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.LstatWithContext(ctx, path)
+}
+
+// StatObject - возвращает сведения об объекте; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) StatObject(path string) (ObjectInfo, error) {
+	return b.StatObjectWithContext(context.Background(), path)
+}
+
+// StatObjectWithContext - возвращает сведения об объекте; дожидается записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) StatObjectWithContext(ctx context.Context, path string) (ObjectInfo, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.StatObjectWithContext(ctx, path)
+}
+
+// BlockChecksums - контрольные суммы блоков файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+// blockSize - размер блока
+func (b *WriteBehind) BlockChecksums(path string, blockSize int) ([]BlockHash, error) {
+	return b.BlockChecksumsWithContext(context.Background(), path, blockSize)
+}
+
+// BlockChecksumsWithContext - контрольные суммы блоков файла, файл из очереди записи читается из памяти
+// path - путь к файлу
+// blockSize - размер блока
+func (b *WriteBehind) BlockChecksumsWithContext(ctx context.Context, path string, blockSize int) ([]BlockHash, error) {
+	return blockChecksums(ctx, b, path, blockSize)
+}
+
+// Reserve - резервирует путь, путь с записью в очереди считается занятым
+// path - путь к файлу
+func (b *WriteBehind) Reserve(path string) error {
+	return b.ReserveWithContext(context.Background(), path)
+}
+
+// ReserveWithContext - резервирует путь, путь с записью в очереди считается занятым
+// path - путь к файлу
+func (b *WriteBehind) ReserveWithContext(ctx context.Context, path string) error {
+	if _, ok := b.queuedFile(path); ok {
+		return ErrAlreadyExists
+	}
+	return b.StoreIFace.ReserveWithContext(ctx, path)
+}
+
+// RemoveFile - удаляет файл, дождавшись записей path из очереди,
+// чтобы запись, поставленная раньше, не вернула удаленный файл
+// path - путь к файлу
+func (b *WriteBehind) RemoveFile(path string) error {
+	return b.RemoveFileWithContext(context.Background(), path)
+}
+
+// RemoveFileWithContext - удаляет файл, дождавшись записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) RemoveFileWithContext(ctx context.Context, path string) error {
+	b.flushPaths(path)
+	return b.StoreIFace.RemoveFileWithContext(ctx, path)
+}
+
+// RemoveFileIfMatch - удаляет файл, если его ETag равен etag; дожидается записей path из очереди,
+// чтобы ETag сравнивался с последней записью
+// path - путь к файлу
+// etag - ожидаемый ETag
+func (b *WriteBehind) RemoveFileIfMatch(path string, etag string) (bool, error) {
+	return b.RemoveFileIfMatchWithContext(context.Background(), path, etag)
+}
+
+// RemoveFileIfMatchWithContext - удаляет файл, если его ETag равен etag; дожидается записей path из очереди
+// path - путь к файлу
+// etag - ожидаемый ETag
+func (b *WriteBehind) RemoveFileIfMatchWithContext(ctx context.Context, path string, etag string) (bool, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.RemoveFileIfMatchWithContext(ctx, path, etag)
+}
+
+// CopyFile - копирует файл, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) CopyFile(src, dst string, ttl *time.Time, meta map[string]string) error {
+	return b.CopyFileWithContext(context.Background(), src, dst, ttl, meta)
+}
+
+// CopyFileWithContext - копирует файл, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+// ttl - время жизни
+// meta - метаданные
+func (b *WriteBehind) CopyFileWithContext(ctx context.Context, src, dst string, ttl *time.Time, meta map[string]string) error {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.CopyFileWithContext(ctx, src, dst, ttl, meta)
+}
+
+// CopyFileWithOptions - копирует файл, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+// opts - параметры записи
+func (b *WriteBehind) CopyFileWithOptions(src, dst string, opts PutOptions) error {
+	return b.CopyFileWithOptionsWithContext(context.Background(), src, dst, opts)
+}
+
+// CopyFileWithOptionsWithContext - копирует файл, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+// opts - параметры записи
+func (b *WriteBehind) CopyFileWithOptionsWithContext(ctx context.Context, src, dst string, opts PutOptions) error {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.CopyFileWithOptionsWithContext(ctx, src, dst, opts)
+}
+
+// CopyFileIfChanged - копирует файл, если он отличается; дожидается записей src и dst из очереди,
+// чтобы сравнивались последние записи
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) CopyFileIfChanged(src, dst string) (bool, error) {
+	return b.CopyFileIfChangedWithContext(context.Background(), src, dst)
+}
+
+// CopyFileIfChangedWithContext - копирует файл, если он отличается; дожидается записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) CopyFileIfChangedWithContext(ctx context.Context, src, dst string) (bool, error) {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.CopyFileIfChangedWithContext(ctx, src, dst)
+}
+
+// CopyMeta - копирует метаданные, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) CopyMeta(src, dst string) error {
+	return b.CopyMetaWithContext(context.Background(), src, dst)
+}
+
+// CopyMetaWithContext - копирует метаданные, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) CopyMetaWithContext(ctx context.Context, src, dst string) error {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.CopyMetaWithContext(ctx, src, dst)
+}
+
+// ExtractRange - копирует часть src в dst, дождавшись записей src и dst из очереди,
+// чтобы часть бралась из последней записи src и не была перезаписана ранее поставленной записью dst
+// src - исходный путь
+// offset - смещение
+// length - длина, 0 - до конца файла
+// dst - путь назначения
+func (b *WriteBehind) ExtractRange(src string, offset, length int64, dst string) error {
+	return b.ExtractRangeWithContext(context.Background(), src, offset, length, dst)
+}
+
+// ExtractRangeWithContext - копирует часть src в dst, дождавшись записей src и dst из очереди
+// src - исходный путь
+// offset - смещение
+// length - длина, 0 - до конца файла
+// dst - путь назначения
+func (b *WriteBehind) ExtractRangeWithContext(ctx context.Context, src string, offset, length int64, dst string) error {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.ExtractRangeWithContext(ctx, src, offset, length, dst)
+}
+
+// Truncate - укорачивает файл, дождавшись записей path из очереди
+// path - путь к файлу
+// size - новый размер
+func (b *WriteBehind) Truncate(path string, size int64) error {
+	return b.TruncateWithContext(context.Background(), path, size)
+}
+
+// TruncateWithContext - укорачивает файл, дождавшись записей path из очереди
+// path - путь к файлу
+// size - новый размер
+func (b *WriteBehind) TruncateWithContext(ctx context.Context, path string, size int64) error {
+	b.flushPaths(path)
+	return b.StoreIFace.TruncateWithContext(ctx, path, size)
+}
+
+// MoveFile - переносит файл, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) MoveFile(src, dst string) error {
+	return b.MoveFileWithContext(context.Background(), src, dst)
+}
+
+// MoveFileWithContext - переносит файл, дождавшись записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) MoveFileWithContext(ctx context.Context, src, dst string) error {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.MoveFileWithContext(ctx, src, dst)
+}
+
+// MoveFileNoOverwrite - переносит файл, если dst еще нет; дожидается записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) MoveFileNoOverwrite(src, dst string) error {
+	return b.MoveFileNoOverwriteWithContext(context.Background(), src, dst)
+}
+
+// MoveFileNoOverwriteWithContext - переносит файл, если dst еще нет; дожидается записей src и dst из очереди
+// src - исходный путь
+// dst - путь назначения
+func (b *WriteBehind) MoveFileNoOverwriteWithContext(ctx context.Context, src, dst string) error {
+	b.flushPaths(src, dst)
+	return b.StoreIFace.MoveFileNoOverwriteWithContext(ctx, src, dst)
+}
+
+// SwapFiles - меняет файлы местами, дождавшись записей x и y из очереди,
+// чтобы запись, поставленная раньше, не легла поверх уже переставленного файла
+// x - путь к первому файлу
+// y - путь ко второму файлу
+func (b *WriteBehind) SwapFiles(x, y string) error {
+	return b.SwapFilesWithContext(context.Background(), x, y)
+}
+
+// SwapFilesWithContext - меняет файлы местами, дождавшись записей x и y из очереди
+// x - путь к первому файлу
+// y - путь ко второму файлу
+func (b *WriteBehind) SwapFilesWithContext(ctx context.Context, x, y string) error {
+	b.flushPaths(x, y)
+	return b.StoreIFace.SwapFilesWithContext(ctx, x, y)
+}
+
+// Rotate - переносит файл под имя с меткой времени, дождавшись записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) Rotate(path string) (string, error) {
+	return b.RotateWithContext(context.Background(), path)
+}
+
+// RotateWithContext - переносит файл под имя с меткой времени, дождавшись записей path из очереди
+// path - путь к файлу
+func (b *WriteBehind) RotateWithContext(ctx context.Context, path string) (string, error) {
+	b.flushPaths(path)
+	return b.StoreIFace.RotateWithContext(ctx, path)
+}
+
+// Symlink - создает ссылку newname на oldname, дождавшись записей обоих путей из очереди
+// oldname - путь, на который указывает ссылка
+// newname - путь к ссылке
+func (b *WriteBehind) Symlink(oldname, newname string) error {
+	return b.SymlinkWithContext(context.Background(), oldname, newname)
+}
+
+// SymlinkWithContext - создает ссылку newname на oldname, дождавшись записей обоих путей из очереди
+// oldname - путь, на который указывает ссылка
+// newname - путь к ссылке
+func (b *WriteBehind) SymlinkWithContext(ctx context.Context, oldname, newname string) error {
+	b.flushPaths(oldname, newname)
+	return b.StoreIFace.SymlinkWithContext(ctx, oldname, newname)
+}
+
+// ClearDir - очищает директорию, дождавшись всей очереди,
+// чтобы записи, поставленные раньше, не вернули удаленные файлы
+// path - путь к директории
+func (b *WriteBehind) ClearDir(path string) error {
+	return b.ClearDirWithContext(context.Background(), path)
+}
+
+// ClearDirWithContext - очищает директорию, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) ClearDirWithContext(ctx context.Context, path string) error {
+	b.flushAll()
+	return b.StoreIFace.ClearDirWithContext(ctx, path)
+}
+
+// ExtractArchive - распаковывает архив в директорию, дождавшись всей очереди
+// stream - архив
+// path - путь к директории
+// format - формат архива
+func (b *WriteBehind) ExtractArchive(stream io.Reader, path string, format ArchiveFormat) error {
+	return b.ExtractArchiveWithContext(context.Background(), stream, path, format)
+}
+
+// ExtractArchiveWithContext - распаковывает архив в директорию, дождавшись всей очереди
+// stream - архив
+// path - путь к директории
+// format - формат архива
+func (b *WriteBehind) ExtractArchiveWithContext(ctx context.Context, stream io.Reader, path string, format ArchiveFormat) error {
+	b.flushAll()
+	return b.StoreIFace.ExtractArchiveWithContext(ctx, stream, path, format)
+}
+
+// ArchiveDir - архивирует директорию, дождавшись всей очереди
+// path - путь к директории
+// w - получатель архива
+// format - формат архива
+func (b *WriteBehind) ArchiveDir(path string, w io.Writer, format ArchiveFormat) error {
+	return b.ArchiveDirWithContext(context.Background(), path, w, format)
+}
+
+// ArchiveDirWithContext - архивирует директорию, дождавшись всей очереди
+// path - путь к директории
+// w - получатель архива
+// format - формат архива
+func (b *WriteBehind) ArchiveDirWithContext(ctx context.Context, path string, w io.Writer, format ArchiveFormat) error {
+	b.flushAll()
+	return b.StoreIFace.ArchiveDirWithContext(ctx, path, w, format)
+}
+
+// Manifest - список файлов директории с контрольными суммами, дождавшись всей очереди
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+func (b *WriteBehind) Manifest(path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	return b.ManifestWithContext(context.Background(), path, algo)
+}
+
+// ManifestWithContext - список файлов директории с контрольными суммами, дождавшись всей очереди
+// path - путь к директории
+// algo - алгоритм контрольной суммы
+func (b *WriteBehind) ManifestWithContext(ctx context.Context, path string, algo ChecksumAlgo) ([]ManifestEntry, error) {
+	b.flushAll()
+	return b.StoreIFace.ManifestWithContext(ctx, path, algo)
+}
+
+// ListMeta - метаданные файлов директории, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) ListMeta(path string) (map[string]map[string]string, error) {
+	return b.ListMetaWithContext(context.Background(), path)
+}
+
+// ListMetaWithContext - метаданные файлов директории, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) ListMetaWithContext(ctx context.Context, path string) (map[string]map[string]string, error) {
+	b.flushAll()
+	return b.StoreIFace.ListMetaWithContext(ctx, path)
+}
+
+// Latest - последний измененный файл директории, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) Latest(path string) (os.FileInfo, error) {
+	return b.LatestWithContext(context.Background(), path)
+}
+
+// LatestWithContext - последний измененный файл директории, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) LatestWithContext(ctx context.Context, path string) (os.FileInfo, error) {
+	b.flushAll()
+	return b.StoreIFace.LatestWithContext(ctx, path)
+}
+
+// IsEmpty - проверяет, пуста ли директория, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) IsEmpty(path string) (bool, error) {
+	return b.IsEmptyWithContext(context.Background(), path)
+}
+
+// IsEmptyWithContext - проверяет, пуста ли директория, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) IsEmptyWithContext(ctx context.Context, path string) (bool, error) {
+	b.flushAll()
+	return b.StoreIFace.IsEmptyWithContext(ctx, path)
+}
+
+// ListModifiedSince - файлы директории, измененные после since, дождавшись всей очереди
+// path - путь к директории
+// since - время
+func (b *WriteBehind) ListModifiedSince(path string, since time.Time) ([]os.FileInfo, error) {
+	return b.ListModifiedSinceWithContext(context.Background(), path, since)
+}
+
+// ListModifiedSinceWithContext - файлы директории, измененные после since, дождавшись всей очереди
+// path - путь к директории
+// since - время
+func (b *WriteBehind) ListModifiedSinceWithContext(ctx context.Context, path string, since time.Time) ([]os.FileInfo, error) {
+	b.flushAll()
+	return b.StoreIFace.ListModifiedSinceWithContext(ctx, path, since)
+}
+
+// ListDirDepth - файлы директории до глубины depth, дождавшись всей очереди
+// path - путь к директории
+// depth - глубина
+func (b *WriteBehind) ListDirDepth(path string, depth int) ([]os.FileInfo, error) {
+	return b.ListDirDepthWithContext(context.Background(), path, depth)
+}
+
+// ListDirDepthWithContext - файлы директории до глубины depth, дождавшись всей очереди
+// path - путь к директории
+// depth - глубина
+func (b *WriteBehind) ListDirDepthWithContext(ctx context.Context, path string, depth int) ([]os.FileInfo, error) {
+	b.flushAll()
+	return b.StoreIFace.ListDirDepthWithContext(ctx, path, depth)
+}
+
+// ListDirChan - файлы директории в канале, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) ListDirChan(path string) <-chan DirEntry {
+	return b.ListDirChanWithContext(context.Background(), path)
+}
+
+// ListDirChanWithContext - файлы директории в канале, дождавшись всей очереди
+// path - путь к директории
+func (b *WriteBehind) ListDirChanWithContext(ctx context.Context, path string) <-chan DirEntry {
+	b.flushAll()
+	return b.StoreIFace.ListDirChanWithContext(ctx, path)
+}
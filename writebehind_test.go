@@ -0,0 +1,237 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// slowCreate - хранилище, в котором CreateFile выполняется с задержкой,
+// чтобы запись WriteBehind гарантированно оставалась в очереди во время проверки
+type slowCreate struct {
+	StoreIFace
+	delay time.Duration
+}
+
+func (s *slowCreate) CreateFileWithContext(ctx context.Context, path string, file []byte, ttl *time.Time, meta map[string]string) error {
+	time.Sleep(s.delay)
+	return s.StoreIFace.CreateFileWithContext(ctx, path, file, ttl, meta)
+}
+
+// newTestWriteBehind - WriteBehind поверх Local с медленной фоновой записью
+func newTestWriteBehind(t *testing.T) (*WriteBehind, *Local) {
+	t.Helper()
+	local := newTestLocal(t, LocalConfig{})
+	b := WithWriteBehind(&slowCreate{StoreIFace: local, delay: 50 * time.Millisecond}, WriteBehindOptions{})
+	t.Cleanup(func() { b.Close() })
+	return b, local
+}
+
+func TestWriteBehindReadYourWrites(t *testing.T) {
+	const (
+		old   = `{"v":"old"}`
+		newer = `{"v":"newer"}`
+	)
+
+	tests := []struct {
+		name string
+		// read - значение, прочитанное из path
+		read func(b *WriteBehind, path string) (string, error)
+		want string
+	}{
+		{"GetFile", func(b *WriteBehind, path string) (string, error) {
+			data, err := b.GetFile(path)
+			return string(data), err
+		}, newer},
+		{"FileReader", func(b *WriteBehind, path string) (string, error) {
+			var buf bytes.Buffer
+			r, err := b.FileReader(path, 0, 0)
+			if err != nil {
+				return "", err
+			}
+			defer r.Close()
+			_, err = buf.ReadFrom(r)
+			return buf.String(), err
+		}, newer},
+		{"GetFilePartially", func(b *WriteBehind, path string) (string, error) {
+			data, err := b.GetFilePartially(path, 6, 5)
+			return string(data), err
+		}, "newer"},
+		{"Peek", func(b *WriteBehind, path string) (string, error) {
+			data, err := b.Peek(path, 100)
+			return string(data), err
+		}, newer},
+		{"WriteTo", func(b *WriteBehind, path string) (string, error) {
+			var buf bytes.Buffer
+			_, err := b.WriteTo(path, &buf)
+			return buf.String(), err
+		}, newer},
+		{"GetJsonFile", func(b *WriteBehind, path string) (string, error) {
+			var v struct{ V string }
+			err := b.GetJsonFile(path, &v)
+			return v.V, err
+		}, "newer"},
+		{"GetJsonMap", func(b *WriteBehind, path string) (string, error) {
+			m, err := b.GetJsonMap(path)
+			return fmt.Sprint(m["v"]), err
+		}, "newer"},
+		{"Stat", func(b *WriteBehind, path string) (string, error) {
+			info, _, err := b.Stat(path)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprint(info.Size()), nil
+		}, fmt.Sprint(len(newer))},
+		{"StatLite", func(b *WriteBehind, path string) (string, error) {
+			info, err := b.StatLite(path)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprint(info.Size()), nil
+		}, fmt.Sprint(len(newer))},
+		{"StatObject", func(b *WriteBehind, path string) (string, error) {
+			obj, err := b.StatObject(path)
+			return fmt.Sprint(obj.Size), err
+		}, fmt.Sprint(len(newer))},
+		{"CopyFile source", func(b *WriteBehind, path string) (string, error) {
+			dst := path + ".copy"
+			if err := b.CopyFile(path, dst, nil, nil); err != nil {
+				return "", err
+			}
+			data, err := os.ReadFile(dst)
+			return string(data), err
+		}, newer},
+		{"MoveFile source", func(b *WriteBehind, path string) (string, error) {
+			dst := path + ".moved"
+			if err := b.MoveFile(path, dst); err != nil {
+				return "", err
+			}
+			// запись из очереди не должна вернуть перенесенный файл
+			if err := b.Flush(); err != nil {
+				return "", err
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				return "", fmt.Errorf("moved file reappeared: %v", err)
+			}
+			data, err := os.ReadFile(dst)
+			return string(data), err
+		}, newer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.json")
+			b, local := newTestWriteBehind(t)
+			if err := local.CreateFile(path, []byte(old), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := b.CreateFile(path, []byte(newer), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.read(b, path)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteBehindOrdering(t *testing.T) {
+	tests := []struct {
+		name string
+		// write - пишет "direct" в path в обход очереди
+		write func(b *WriteBehind, path string) error
+	}{
+		{"StreamToFile", func(b *WriteBehind, path string) error {
+			return b.StreamToFile(bytes.NewReader([]byte("direct")), path, nil)
+		}},
+		{"StreamToFileN", func(b *WriteBehind, path string) error {
+			_, err := b.StreamToFileN(bytes.NewReader([]byte("direct")), path, nil)
+			return err
+		}},
+		{"FileWriter", func(b *WriteBehind, path string) error {
+			w, err := b.FileWriter(path, nil, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte("direct")); err != nil {
+				w.Close()
+				return err
+			}
+			return w.Close()
+		}},
+		{"CreateFileWithOptions", func(b *WriteBehind, path string) error {
+			return b.CreateFileWithOptions(path, []byte("direct"), PutOptions{})
+		}},
+		{"CreateJsonFile", func(b *WriteBehind, path string) error {
+			return b.CreateJsonFile(path, "direct", nil, nil)
+		}},
+		{"CopyFile destination", func(b *WriteBehind, path string) error {
+			src := path + ".src"
+			if err := os.WriteFile(src, []byte("direct"), 0644); err != nil {
+				return err
+			}
+			return b.CopyFile(src, path, nil, nil)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.txt")
+			b, _ := newTestWriteBehind(t)
+
+			if err := b.CreateFile(path, []byte("queued"), nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.write(b, path); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if err := b.Flush(); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(got, []byte("direct")) {
+				t.Errorf("file = %q, the earlier queued CreateFile overwrote the later write", got)
+			}
+		})
+	}
+}
+
+func TestWriteBehindCloseFlushes(t *testing.T) {
+	dir := t.TempDir()
+	local := newTestLocal(t, LocalConfig{})
+	b := WithWriteBehind(&slowCreate{StoreIFace: local, delay: 10 * time.Millisecond}, WriteBehindOptions{Workers: 2})
+
+	const files = 10
+	for i := 0; i < files; i++ {
+		if err := b.CreateFile(filepath.Join(dir, fmt.Sprint(i)), []byte(fmt.Sprint(i)), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for i := 0; i < files; i++ {
+		got, err := os.ReadFile(filepath.Join(dir, fmt.Sprint(i)))
+		if err != nil || string(got) != fmt.Sprint(i) {
+			t.Errorf("file %d after Close = %q, %v", i, got, err)
+		}
+	}
+	if err := b.CreateFile(filepath.Join(dir, "late"), []byte("x"), nil, nil); !errors.Is(err, ErrWriteBehindClosed) {
+		t.Errorf("CreateFile after Close = %v, want ErrWriteBehindClosed", err)
+	}
+}
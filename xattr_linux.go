@@ -0,0 +1,111 @@
+package store
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+)
+
+// xattrPrefix - пространство имен расширенных атрибутов метаданных
+const xattrPrefix = "user."
+
+// getXattrMeta - метаданные из атрибутов user.* файла
+// ФС без поддержки атрибутов возвращает ошибку, для которой errors.Is(err, errors.ErrUnsupported)
+func getXattrMeta(path string) (map[string]string, error) {
+	names, err := listXattr(path)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := make(map[string]string)
+	for _, name := range names {
+		if !strings.HasPrefix(name, xattrPrefix) {
+			continue
+		}
+		value, err := getXattr(path, name)
+		if err == syscall.ENODATA {
+			// атрибут удален между чтением списка и значения
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		meta[strings.TrimPrefix(name, xattrPrefix)] = string(value)
+	}
+	return meta, nil
+}
+
+// setXattrMeta - заменяет атрибуты user.* файла метаданными meta
+func setXattrMeta(path string, meta map[string]string) error {
+	names, err := listXattr(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, xattrPrefix) {
+			continue
+		}
+		if _, ok := meta[strings.TrimPrefix(name, xattrPrefix)]; ok {
+			continue
+		}
+		if err := syscall.Removexattr(path, name); err != nil && err != syscall.ENODATA {
+			return err
+		}
+	}
+
+	for key, value := range meta {
+		if err := syscall.Setxattr(path, xattrPrefix+key, []byte(value), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listXattr - имена атрибутов файла
+func listXattr(path string) ([]string, error) {
+	for {
+		size, err := syscall.Listxattr(path, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, size)
+		n, err := syscall.Listxattr(path, buf)
+		if err == syscall.ERANGE {
+			// список вырос между запросами размера и данных
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var names []string
+		for _, name := range bytes.Split(buf[:n], []byte{0}) {
+			if len(name) > 0 {
+				names = append(names, string(name))
+			}
+		}
+		return names, nil
+	}
+}
+
+// getXattr - значение атрибута файла
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// requireXattr - пропускает тест, если ФС временной директории не поддерживает user.* атрибуты
+func requireXattr(t *testing.T, dir string) {
+	t.Helper()
+	probe := filepath.Join(dir, "probe")
+	if err := os.WriteFile(probe, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(probe)
+	if err := syscall.Setxattr(probe, "user.probe", []byte("1"), 0); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skipf("no user xattrs in %s: %v", dir, err)
+		}
+		t.Fatal(err)
+	}
+}
+
+// userXattrs - атрибуты user.* файла в обход Local
+func userXattrs(t *testing.T, path string) map[string]string {
+	t.Helper()
+	attrs, err := getXattrMeta(path)
+	if err != nil {
+		t.Fatalf("xattrs of %s: %v", path, err)
+	}
+	return attrs
+}
+
+// assertNoSidecar - рядом с файлом нет мета-файла
+func assertNoSidecar(t *testing.T, path string) {
+	t.Helper()
+	if _, err := os.Stat(path + META_PREFIX); !os.IsNotExist(err) {
+		t.Errorf("sidecar of %s exists: %v", filepath.Base(path), err)
+	}
+}
+
+func TestLocalXattrMeta(t *testing.T) {
+	dir := t.TempDir()
+	requireXattr(t, dir)
+	s := newTestLocal(t, LocalConfig{MetaBackend: MetaXattr})
+	path := filepath.Join(dir, "a.txt")
+	meta := map[string]string{"Owner": "bob", "note": "a=b\nc", "empty": ""}
+
+	if err := s.CreateFile(path, []byte("data"), nil, meta); err != nil {
+		t.Fatal(err)
+	}
+	assertNoSidecar(t, path)
+	if got := userXattrs(t, path); !reflect.DeepEqual(got, meta) {
+		t.Errorf("xattrs = %q, want %q", got, meta)
+	}
+	if _, got, err := s.Stat(path); err != nil || !reflect.DeepEqual(got, meta) {
+		t.Errorf("Stat meta = %q, %v; want %q", got, err, meta)
+	}
+
+	// новые метаданные заменяют прежние целиком
+	if err := s.CreateFile(path, []byte("data"), nil, map[string]string{"Owner": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := userXattrs(t, path); !reflect.DeepEqual(got, map[string]string{"Owner": "alice"}) {
+		t.Errorf("xattrs after overwrite = %q, want only Owner=alice", got)
+	}
+
+	t.Run("service keys", func(t *testing.T) {
+		path := filepath.Join(dir, "cached.txt")
+		ttl := time.Now().Add(time.Hour).Truncate(time.Second)
+		opts := PutOptions{Meta: map[string]string{"Owner": "bob"}, CacheControl: "no-cache", TTL: &ttl}
+		if err := s.CreateFileWithOptions(path, []byte("data"), opts); err != nil {
+			t.Fatal(err)
+		}
+		assertNoSidecar(t, path)
+		obj, err := s.StatObject(path)
+		if err != nil || obj.CacheControl != "no-cache" || !obj.Expires.Equal(ttl) || !reflect.DeepEqual(obj.Meta, opts.Meta) {
+			t.Errorf("StatObject = %+v, %v; want the cache control, expiry and meta round-tripped", obj, err)
+		}
+	})
+
+	t.Run("copy and move", func(t *testing.T) {
+		copied, moved := filepath.Join(dir, "copied.txt"), filepath.Join(dir, "moved.txt")
+		if err := s.CopyFile(path, copied, nil, map[string]string{"Copy": "yes"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := userXattrs(t, copied), map[string]string{"Owner": "alice", "Copy": "yes"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("copy xattrs = %q, want %q", got, want)
+		}
+		if got := userXattrs(t, path); !reflect.DeepEqual(got, map[string]string{"Owner": "alice"}) {
+			t.Errorf("source xattrs after copy = %q, want unchanged", got)
+		}
+
+		if err := s.MoveFile(copied, moved); err != nil {
+			t.Fatal(err)
+		}
+		assertNoSidecar(t, moved)
+		if _, got, err := s.Stat(moved); err != nil || got["Copy"] != "yes" || got["Owner"] != "alice" {
+			t.Errorf("moved meta = %q, %v; want the copy's meta", got, err)
+		}
+	})
+
+	t.Run("streamed writes", func(t *testing.T) {
+		path := filepath.Join(dir, "written.txt")
+		w, err := s.FileWriter(path, nil, map[string]string{"Owner": "writer"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		assertNoSidecar(t, path)
+		if got := userXattrs(t, path); got["Owner"] != "writer" {
+			t.Errorf("FileWriter xattrs = %q, want Owner=writer", got)
+		}
+	})
+
+	t.Run("legacy sidecar", func(t *testing.T) {
+		// файл с мета-файлом, записанный до включения атрибутов
+		path := filepath.Join(dir, "legacy.txt")
+		legacy := newTestLocal(t, LocalConfig{})
+		if err := legacy.CreateFile(path, []byte("data"), nil, map[string]string{"Owner": "old"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, got, err := s.Stat(path); err != nil || got["Owner"] != "old" {
+			t.Errorf("meta of a legacy file = %q, %v; want it read from the sidecar", got, err)
+		}
+
+		// запись метаданных переносит их в атрибуты и убирает мета-файл
+		if err := s.CopyMeta(path, path); err != nil {
+			t.Fatal(err)
+		}
+		assertNoSidecar(t, path)
+		if got := userXattrs(t, path); got["Owner"] != "old" {
+			t.Errorf("xattrs after rewriting the meta = %q, want Owner=old", got)
+		}
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		names := listNames(t, s, dir)
+		for _, name := range names {
+			if strings.HasSuffix(name, META_PREFIX) {
+				t.Errorf("listing has sidecar %s", name)
+			}
+		}
+		all, err := s.ListMeta(dir)
+		if err != nil || all["a.txt"]["Owner"] != "alice" || all["written.txt"]["Owner"] != "writer" {
+			t.Errorf("ListMeta = %v, %v; want the xattr meta", all, err)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := s.RemoveFile(path); err != nil {
+			t.Fatal(err)
+		}
+		// на месте удаленного файла новый создается без прежних атрибутов
+		if err := s.CreateFile(path, []byte("data"), nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, got, err := s.Stat(path); err != nil || len(got) != 0 {
+			t.Errorf("meta of a recreated file = %q, %v; want none", got, err)
+		}
+	})
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package store
+
+import "errors"
+
+// getXattrMeta - расширенные атрибуты поддерживаются только на Linux, иначе мета-файл
+func getXattrMeta(path string) (map[string]string, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// setXattrMeta - расширенные атрибуты поддерживаются только на Linux, иначе мета-файл
+func setXattrMeta(path string, meta map[string]string) error {
+	return errors.ErrUnsupported
+}